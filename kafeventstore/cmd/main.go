@@ -2,12 +2,18 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"log/slog"
+	"math"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -15,117 +21,2089 @@ import (
 
 	"github.com/jittakal/kafeventstore/internal/config"
 	"github.com/jittakal/kafeventstore/internal/config/dto"
+	"github.com/jittakal/kafeventstore/internal/confluent"
+	"github.com/jittakal/kafeventstore/internal/encoder"
+	"github.com/jittakal/kafeventstore/internal/envelope"
+	"github.com/jittakal/kafeventstore/internal/evolution"
 	"github.com/jittakal/kafeventstore/internal/kafka"
 	"github.com/jittakal/kafeventstore/internal/observability"
+	"github.com/jittakal/kafeventstore/internal/redact"
+	"github.com/jittakal/kafeventstore/internal/schema"
 	"github.com/jittakal/kafeventstore/internal/server"
 	"github.com/jittakal/kafeventstore/internal/storage"
+	"github.com/jittakal/kafeventstore/internal/validator"
 	"github.com/jittakal/kafeventstore/pkg/event"
+	pkgstorage "github.com/jittakal/kafeventstore/pkg/storage"
 )
 
 func main() {
+	// "dlq redrive" replays a DLQ topic and "decrypt" decrypts a stored file;
+	// anything else runs the event store
+	if len(os.Args) > 2 && os.Args[1] == "dlq" && os.Args[2] == "redrive" {
+		if err := runRedrive(os.Args[3:]); err != nil {
+			log.Fatalf("dlq redrive error: %v", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "decrypt" {
+		if err := runDecrypt(os.Args[2:]); err != nil {
+			log.Fatalf("decrypt error: %v", err)
+		}
+		return
+	}
+
 	if err := run(); err != nil {
 		log.Fatalf("application error: %v", err)
 	}
 }
 
-func run() error {
-	// Parse command-line flags
-	configPath := flag.String("config", "", "path to configuration file")
-	flag.Parse()
+func run() error {
+	// Parse command-line flags
+	configPath := flag.String("config", "", "path to configuration file")
+	flag.Parse()
+
+	// Load configuration
+	loader := config.NewLoader()
+	cfg, err := loader.Load(resolveConfigPath(*configPath))
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	// Initialize observability
+	logger := observability.NewLogger(observability.LoggingConfig{
+		Level:  cfg.Observability.Logging.Level,
+		Format: cfg.Observability.Logging.Format,
+	})
+	logger.Info("starting kafka event store",
+		"version", cfg.Application.Version,
+		"environment", cfg.Application.Environment,
+	)
+
+	registry := prometheus.NewRegistry()
+	metrics := observability.NewMetrics(registry)
+
+	// Track cleanup functions
+	var cleanupFuncs []func() error
+	addCleanup := func(name string, fn func() error) {
+		cleanupFuncs = append(cleanupFuncs, func() error {
+			if err := fn(); err != nil {
+				return fmt.Errorf("failed to close %s: %w", name, err)
+			}
+			return nil
+		})
+		logger.Debug("registered cleanup", "component", name)
+	}
+
+	// Initialize infrastructure
+	consumerConfig := newConsumerConfig(cfg)
+	consumer, err := kafka.NewSaramaConsumer(consumerConfig, logger, metrics)
+	if err != nil {
+		return fmt.Errorf("failed to create consumer: %w", err)
+	}
+	addCleanup("kafka-consumer", consumer.Close)
+
+	// Subscribe to topics, discovering the topics matching the pattern
+	if err := consumer.Subscribe(context.Background(), cfg.Kafka.Consumer.Topics); err != nil {
+		return fmt.Errorf("failed to subscribe to topics: %w", err)
+	}
+
+	// The publisher is enabled when the DLQ is enabled globally or for any topic
+	dlqConfig := kafka.DLQConfig{
+		Enabled:          cfg.Kafka.DLQ.Enabled || topicDLQEnabled(cfg),
+		TopicSuffix:      cfg.Kafka.DLQ.TopicSuffix,
+		MaxRetries:       cfg.Kafka.DLQ.MaxRetries,
+		RetryTopicSuffix: cfg.Kafka.DLQ.RetryTopicSuffix,
+		RetryBackoff:     time.Duration(cfg.Kafka.DLQ.RetryBackoffMS) * time.Millisecond,
+
+		AutoCreateTopics:       cfg.Kafka.DLQ.AutoCreateTopics,
+		TopicPartitions:        cfg.Kafka.DLQ.TopicPartitions,
+		TopicReplicationFactor: cfg.Kafka.DLQ.TopicReplicationFactor,
+		TopicRetention:         time.Duration(cfg.Kafka.DLQ.TopicRetentionMS) * time.Millisecond,
+	}
+
+	dlqPublisher, err := kafka.NewDLQPublisher(cfg.Kafka.BootstrapServers, consumerConfig, dlqConfig, logger, cfg.Application.Name, metrics)
+	if err != nil {
+		return fmt.Errorf("failed to create DLQ publisher: %w", err)
+	}
+	addCleanup("dlq-publisher", dlqPublisher.Close)
+
+	// Storage failures of topics with a DLQ pass through retry topics, which
+	// a consumer group of their own reprocesses, before they reach the DLQ.
+	// The retry topics of the subscribed topics are created before their
+	// consumer subscribes to them; those of topics discovered later are
+	// created with their first retry
+	var retryConsumer *kafka.SaramaConsumer
+	if dlqConfig.Enabled && dlqConfig.MaxRetries > 0 {
+		retryTopics := kafka.RetryTopics(retriedTopics(cfg, consumer.Topics()), dlqConfig.RetryTopicSuffix, dlqConfig.MaxRetries)
+		if err := dlqPublisher.EnsureTopics(retryTopics); err != nil {
+			return fmt.Errorf("failed to create retry topics: %w", err)
+		}
+		retryConsumer, err = newRetryConsumer(cfg, consumerConfig, logger, metrics)
+		if err != nil {
+			return err
+		}
+		if retryConsumer != nil {
+			addCleanup("kafka-retry-consumer", retryConsumer.Close)
+
+			// Retry messages that are not DLQ records are dead-lettered as
+			// raw messages of the topic they were retried for
+			retryConsumer.SetPoisonHandler(func(ctx context.Context, message kafka.RawMessage, parseErr error) error {
+				message.Topic = kafka.RetriedTopic(message, cfg.Kafka.DLQ.RetryTopicSuffix)
+				return publishRawToDLQ(ctx, cfg, dlqPublisher, message, parseErr)
+			})
+		}
+	}
+
+	// Messages that are not CloudEvents are dead-lettered with their raw bytes
+	consumer.SetPoisonHandler(func(ctx context.Context, message kafka.RawMessage, parseErr error) error {
+		return publishRawToDLQ(ctx, cfg, dlqPublisher, message, parseErr)
+	})
+
+	// Create the storage writers and the per-topic pipelines
+	writer, pipelines, err := newTopicPipelines(cfg, logger, metrics)
+	if err != nil {
+		return err
+	}
+	addCleanup("storage-writer", writer.Close)
+	addCleanup("topic-pipelines", pipelines.Close)
+
+	// Events the DLQ rejects are quarantined in the storage of their topic
+	dlqPublisher.SetQuarantineHandler(func(ctx context.Context, dlqEvent *kafka.DLQEvent) error {
+		return quarantineEvent(ctx, pipelines, dlqEvent, logger, metrics)
+	})
+
+	// Initialize partition completion tracker (nil disables _SUCCESS markers)
+	var completion *storage.CompletionTracker
+	if cfg.Storage.Completion.SuccessMarker {
+		completion = storage.NewCompletionTracker(time.Duration(cfg.Storage.Completion.AllowedLatenessSeconds) * time.Second)
+	}
+
+	// Initialize buffer manager
+	bufferSizeBytes := int64(cfg.Processing.BufferSizeMB * 1024 * 1024)
+	bufferMgr := newSimpleBufferManager(bufferSizeBytes, cfg.FileRotation.MaxRecordsPerFile)
+
+	// Create simple health checker
+	healthChecker := &simpleHealthChecker{isHealthy: true}
+
+	// Start HTTP server
+	httpServer := server.NewServer(
+		cfg.Observability.Health.Port,
+		cfg.Observability.Metrics.Port,
+		healthChecker,
+		registry,
+		logger,
+	)
+
+	if err := httpServer.Start(); err != nil {
+		return fmt.Errorf("failed to start HTTP server: %w", err)
+	}
+	addCleanup("http-server", func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return httpServer.Shutdown(ctx)
+	})
+
+	logger.Info("application started successfully")
+
+	// Create context with cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Start consuming
+	eventChan, errorChan, err := consumer.Consume(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start consuming: %w", err)
+	}
+
+	// Retried events are processed together with newly consumed ones
+	eventChan = mergeEvents(ctx, eventChan, consumeRetries(ctx, retryConsumer, logger))
+
+	// Start consume loop in background; it stops before the consumers so the
+	// offsets of the records it flushes at shutdown are still committed
+	processCtx, stopProcessing := context.WithCancel(ctx)
+	defer stopProcessing()
+	processor := newEventProcessor(pipelines, completion, dlqPublisher, bufferMgr,
+		backoff{
+			initial:    time.Duration(cfg.Retry.InitialBackoffMS) * time.Millisecond,
+			max:        time.Duration(cfg.Retry.MaxBackoffMS) * time.Millisecond,
+			multiplier: cfg.Retry.BackoffMultiplier,
+		},
+		cfg.Shutdown.GracePeriodSeconds*time.Second, logger, metrics)
+	processDone := make(chan struct{})
+	go func() {
+		defer close(processDone)
+		processor.run(processCtx, eventChan, errorChan)
+	}()
+
+	// Wait for termination signal
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	var runErr error
+	select {
+	case <-sigChan:
+		logger.Info("received termination signal")
+	case <-processDone:
+		runErr = errors.New("consume loop stopped unexpectedly")
+		logger.Error("consume error", "error", runErr)
+	}
+
+	// Graceful shutdown: the consume loop flushes its buffers within the
+	// grace period, then the consumers and the other components are closed
+	logger.Info("initiating graceful shutdown")
+	stopProcessing()
+	select {
+	case <-processDone:
+	case <-time.After(cfg.Shutdown.ForceTimeoutSeconds * time.Second):
+		logger.Error("consume loop did not stop in time, buffered events will be reprocessed")
+	}
+	cancel()
+
+	for i := len(cleanupFuncs) - 1; i >= 0; i-- {
+		if err := cleanupFuncs[i](); err != nil {
+			logger.Error("cleanup failed", "error", err)
+		}
+	}
+
+	if runErr != nil {
+		return runErr
+	}
+	logger.Info("application stopped successfully")
+	return nil
+}
+
+// runRedrive implements "dlq redrive": it replays the events of a DLQ topic
+// to their original topics or straight into storage.
+func runRedrive(args []string) error {
+	flags := flag.NewFlagSet("dlq redrive", flag.ContinueOnError)
+	configPath := flags.String("config", "", "path to configuration file")
+	dlqTopic := flags.String("topic", "", "DLQ topic to redrive (required)")
+	target := flags.String("target", "topic", "where to redrive events: topic (original topic) or storage")
+	reasons := flags.String("reason", "", "comma-separated failure reasons to redrive, e.g. storage_failed")
+	originalTopics := flags.String("original-topic", "", "comma-separated original topics to redrive")
+	since := flags.String("since", "", "redrive events that failed at or after this RFC 3339 time")
+	until := flags.String("until", "", "redrive events that failed before this RFC 3339 time")
+	ratePerSecond := flags.Float64("rate", 0, "maximum events redriven per second (0 is unlimited)")
+	limit := flags.Int("limit", 0, "stop after this many matching events (0 redrives all)")
+	dryRun := flags.Bool("dry-run", false, "print the matching events instead of redriving them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *dlqTopic == "" {
+		return errors.New("--topic is required")
+	}
+	if *target != "topic" && *target != "storage" {
+		return fmt.Errorf("unsupported --target: %s (supported: topic, storage)", *target)
+	}
+
+	filter := kafka.RedriveFilter{
+		FailureReasons: splitList(*reasons),
+		OriginalTopics: splitList(*originalTopics),
+	}
+	var err error
+	if filter.Since, err = parseTimeFlag("since", *since); err != nil {
+		return err
+	}
+	if filter.Until, err = parseTimeFlag("until", *until); err != nil {
+		return err
+	}
+
+	cfg, err := config.NewLoader().Load(resolveConfigPath(*configPath))
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	logger := observability.NewLogger(observability.LoggingConfig{
+		Level:  cfg.Observability.Logging.Level,
+		Format: cfg.Observability.Logging.Format,
+	})
+	consumerConfig := newConsumerConfig(cfg)
+
+	redriver, err := kafka.NewRedriver(cfg.Kafka.BootstrapServers, consumerConfig, kafka.RedriveConfig{
+		DLQTopic:      *dlqTopic,
+		Filter:        filter,
+		RatePerSecond: *ratePerSecond,
+		Limit:         *limit,
+	}, logger)
+	if err != nil {
+		return fmt.Errorf("failed to create redriver: %w", err)
+	}
+	defer redriver.Close()
+
+	var sink kafka.RedriveSink
+	switch {
+	case *dryRun:
+		sink = kafka.NewDryRunRedriveSink(os.Stdout)
+	case *target == "storage":
+		writer, pipelines, err := newTopicPipelines(cfg, logger, observability.NewMetrics(prometheus.NewRegistry()))
+		if err != nil {
+			return err
+		}
+		defer writer.Close()
+		defer pipelines.Close()
+		sink = newPipelineRedriveSink(pipelines, cfg.Storage.Completion.SuccessMarker, logger)
+	default:
+		topicSink, err := kafka.NewTopicRedriveSink(cfg.Kafka.BootstrapServers, consumerConfig, logger)
+		if err != nil {
+			return fmt.Errorf("failed to create redrive producer: %w", err)
+		}
+		defer topicSink.Close()
+		sink = topicSink
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	stats, err := redriver.Run(ctx, sink)
+	logger.Info("dlq redrive finished",
+		"topic", *dlqTopic,
+		"target", *target,
+		"dry_run", *dryRun,
+		"read", stats.Read,
+		"matched", stats.Matched,
+		"redriven", stats.Redriven,
+		"failed", stats.Failed,
+	)
+	if err != nil {
+		return err
+	}
+	if stats.Failed > 0 {
+		return fmt.Errorf("%d DLQ messages could not be redriven", stats.Failed)
+	}
+	return nil
+}
+
+// runDecrypt decrypts a data file written with client-side encryption. The
+// envelope is read from the file's sidecar manifest, or given as the object
+// metadata of a downloaded cloud object.
+func runDecrypt(args []string) error {
+	flags := flag.NewFlagSet("decrypt", flag.ContinueOnError)
+	keyFile := flags.String("key-file", "", "keyfile holding the KEK the data key was wrapped with (required)")
+	in := flags.String("in", "", "encrypted data file (required)")
+	out := flags.String("out", "", "decrypted output file (default: --in without the "+envelope.FileSuffix+" suffix)")
+	manifestPath := flags.String("manifest", "", "sidecar manifest of the file (default: the manifest next to --in)")
+	algorithm := flags.String("algorithm", envelope.Algorithm, envelope.MetaAlgorithm+" object metadata")
+	keyID := flags.String("key-id", "", envelope.MetaKeyID+" object metadata, instead of a manifest")
+	wrappedKey := flags.String("wrapped-key", "", envelope.MetaWrappedKey+" object metadata, instead of a manifest")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *keyFile == "" || *in == "" {
+		return errors.New("--key-file and --in are required")
+	}
+
+	outPath := *out
+	if outPath == "" {
+		outPath = strings.TrimSuffix(*in, envelope.FileSuffix)
+		if outPath == *in {
+			return fmt.Errorf("--out is required when --in does not end in %s", envelope.FileSuffix)
+		}
+	}
+
+	env, err := fileEnvelope(*in, *manifestPath, *algorithm, *keyID, *wrappedKey)
+	if err != nil {
+		return err
+	}
+	provider, err := envelope.NewKeyfileProvider(*keyFile)
+	if err != nil {
+		return err
+	}
+
+	src, err := os.Open(*in)
+	if err != nil {
+		return fmt.Errorf("failed to open encrypted file: %w", err)
+	}
+	defer src.Close()
+
+	// Never overwrite an existing file with plaintext
+	dst, err := os.OpenFile(outPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	err = envelope.NewDecryptor(provider).Decrypt(context.Background(), env, dst, src)
+	if closeErr := dst.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close output file: %w", closeErr)
+	}
+	if err != nil {
+		os.Remove(outPath)
+		return fmt.Errorf("failed to decrypt %s: %w", *in, err)
+	}
+
+	log.Printf("decrypted %s to %s", *in, outPath)
+	return nil
+}
+
+// fileEnvelope returns the envelope of an encrypted file from object metadata
+// flags or, when none are given, from the file's sidecar manifest.
+func fileEnvelope(in, manifestPath, algorithm, keyID, wrappedKey string) (*envelope.Envelope, error) {
+	if keyID != "" || wrappedKey != "" {
+		return envelope.FromMetadata(map[string]string{
+			envelope.MetaAlgorithm:  algorithm,
+			envelope.MetaKeyID:      keyID,
+			envelope.MetaWrappedKey: wrappedKey,
+		})
+	}
+
+	if manifestPath == "" {
+		manifestPath = filepath.Join(filepath.Dir(in), storage.ManifestName(filepath.Base(in)))
+	}
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	var manifest storage.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", manifestPath, err)
+	}
+	if manifest.Encryption == nil {
+		return nil, fmt.Errorf("manifest %s describes an unencrypted file", manifestPath)
+	}
+	return manifest.Encryption, nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseTimeFlag parses an optional RFC 3339 flag value.
+func parseTimeFlag(name, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --%s: %w", name, err)
+	}
+	return t, nil
+}
+
+// pipelineRedriveSink writes redriven events straight through the storage
+// pipeline of their original topic, bypassing Kafka. Events are batched per
+// original partition like consumed events. Redriven events usually land in
+// paths already marked complete, whose _SUCCESS markers are rewritten after
+// the write when successMarkers is set.
+type pipelineRedriveSink struct {
+	pipelines      *pipelineResolver
+	successMarkers bool
+	logger         *slog.Logger
+	batches        map[event.PartitionID][]event.Record
+}
+
+func newPipelineRedriveSink(pipelines *pipelineResolver, successMarkers bool, logger *slog.Logger) *pipelineRedriveSink {
+	return &pipelineRedriveSink{
+		pipelines:      pipelines,
+		successMarkers: successMarkers,
+		logger:         logger,
+		batches:        make(map[event.PartitionID][]event.Record),
+	}
+}
+
+// Redrive adds an event to its partition batch and writes full batches.
+func (s *pipelineRedriveSink) Redrive(ctx context.Context, record *kafka.RedriveRecord) error {
+	cloudEvent, err := record.CloudEvent()
+	if err != nil {
+		return err
+	}
+	timestamp := record.Event.OriginalTimestamp
+	if timestamp.IsZero() {
+		timestamp = record.Event.FailureTimestamp
+	}
+
+	pipeline := s.pipelines.resolve(record.Event.OriginalTopic)
+	if err := pipeline.decodeEvent(ctx, cloudEvent); err != nil {
+		return err
+	}
+	// Time skew is checked as of when the event was first consumed, so events
+	// do not expire while they wait in the DLQ
+	if err := pipeline.validateEvent(validator.WithReferenceTime(ctx, timestamp), cloudEvent); err != nil {
+		return fmt.Errorf("invalid cloud event: %w", err)
+	}
+	if !record.Event.Redacted {
+		if err := pipeline.redactEvent(cloudEvent); err != nil {
+			return err
+		}
+	}
+
+	partitionID := event.PartitionID{
+		Topic:     record.Event.OriginalTopic,
+		Partition: record.Event.OriginalPartition,
+	}
+	s.batches[partitionID] = append(s.batches[partitionID], event.Record{
+		Event: cloudEvent,
+		Kafka: event.KafkaMetadata{
+			Topic:     record.Event.OriginalTopic,
+			Partition: record.Event.OriginalPartition,
+			Offset:    record.Event.OriginalOffset,
+			Timestamp: timestamp,
+		},
+		Offset:      record.Event.OriginalOffset,
+		ProcessedAt: time.Now(),
+	})
+
+	if len(s.batches[partitionID]) >= pipeline.maxRecords {
+		return s.write(ctx, partitionID)
+	}
+	return nil
+}
+
+// Flush writes the remaining batches.
+func (s *pipelineRedriveSink) Flush(ctx context.Context) error {
+	var errs []error
+	for partitionID := range s.batches {
+		if err := s.write(ctx, partitionID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// write writes the batch of a partition to the paths of its events.
+func (s *pipelineRedriveSink) write(ctx context.Context, partitionID event.PartitionID) error {
+	records := s.batches[partitionID]
+	delete(s.batches, partitionID)
+	if len(records) == 0 {
+		return nil
+	}
+
+	pipeline := s.pipelines.resolve(partitionID.Topic)
+	batches, rejected, err := pipeline.plan(ctx, partitionID, records)
+	if err != nil {
+		return fmt.Errorf("failed to resolve schemas of redriven batch for %s/%d: %w", partitionID.Topic, partitionID.Partition, err)
+	}
+	var errs []error
+	for _, rec := range rejected {
+		errs = append(errs, fmt.Errorf("redriven event %s at offset %d: %w", rec.record.Event.ID, rec.record.Offset, rec.err))
+	}
+	for _, batch := range batches {
+		bytesWritten, err := pipeline.writer.Write(ctx, batch.records, batch.path, pipeline.format)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to write redriven batch for %s/%d: %w", partitionID.Topic, partitionID.Partition, err))
+			continue
+		}
+		s.logger.Info("wrote redriven batch to storage",
+			"topic", partitionID.Topic,
+			"partition", partitionID.Partition,
+			"records", len(batch.records),
+			"bytes", bytesWritten,
+			"path", batch.path,
+		)
+
+		if s.successMarkers {
+			if _, err := refreshSuccessMarker(ctx, pipeline.writer, batch.path); err != nil {
+				errs = append(errs, fmt.Errorf("failed to rewrite success marker of %s: %w", batch.path, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// resolveConfigPath returns the configuration file path.
+// Priority: CLI flag > CONFIG_PATH env var > default path
+func resolveConfigPath(flagValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if envPath := os.Getenv("CONFIG_PATH"); envPath != "" {
+		return envPath
+	}
+	return "config/application.yaml"
+}
+
+// newConsumerConfig maps the Kafka configuration to the consumer settings,
+// which the DLQ publisher and the redrive tooling reuse for security.
+func newConsumerConfig(cfg *dto.ApplicationConfig) kafka.ConsumerConfig {
+	return kafka.ConsumerConfig{
+		BootstrapServers:    cfg.Kafka.BootstrapServers,
+		GroupID:             cfg.Kafka.Consumer.GroupID,
+		SecurityProtocol:    cfg.Kafka.SecurityProtocol,
+		SASLMechanism:       cfg.Kafka.SASLMechanism,
+		SASLUsername:        cfg.Kafka.SASLUsername,
+		SASLPassword:        cfg.Kafka.SASLPassword,
+		AutoOffsetReset:     cfg.Kafka.Consumer.AutoOffsetReset,
+		EnableAutoCommit:    cfg.Kafka.Consumer.EnableAutoCommit,
+		MaxPollIntervalMS:   cfg.Kafka.Consumer.MaxPollIntervalMS,
+		SessionTimeoutMS:    cfg.Kafka.Consumer.SessionTimeoutMS,
+		HeartbeatIntervalMS: cfg.Kafka.Consumer.HeartbeatIntervalMS,
+		TLS: kafka.TLSConfig{
+			CAFile:             cfg.Kafka.TLS.CAFile,
+			CertFile:           cfg.Kafka.TLS.CertFile,
+			KeyFile:            cfg.Kafka.TLS.KeyFile,
+			ServerName:         cfg.Kafka.TLS.ServerName,
+			MinVersion:         cfg.Kafka.TLS.MinVersion,
+			InsecureSkipVerify: cfg.Kafka.TLS.InsecureSkipVerify,
+		},
+		AWSRegion: cfg.Kafka.AWSRegion,
+		OAuth: kafka.OAuthConfig{
+			TokenURL:     cfg.Kafka.OAuth.TokenURL,
+			ClientID:     cfg.Kafka.OAuth.ClientID,
+			ClientSecret: cfg.Kafka.OAuth.ClientSecret,
+			Scopes:       cfg.Kafka.OAuth.Scopes,
+			Extensions:   cfg.Kafka.OAuth.ExtensionMap(),
+		},
+
+		TopicPattern:         cfg.Kafka.Consumer.TopicPattern,
+		TopicRefreshInterval: time.Duration(cfg.Kafka.Consumer.TopicRefreshIntervalSeconds) * time.Second,
+		ExcludeTopicSuffixes: dlqTopicSuffixes(cfg),
+
+		KafkaVersion:      cfg.Kafka.Version,
+		RebalanceStrategy: cfg.Kafka.Consumer.RebalanceStrategy,
+		GroupInstanceID:   cfg.Kafka.Consumer.GroupInstanceID,
+		EventBufferSize:   cfg.Kafka.Consumer.MaxPollRecords,
+		FetchMinBytes:     cfg.Kafka.Consumer.FetchMinBytes,
+		FetchMaxBytes:     cfg.Kafka.Consumer.FetchMaxBytes,
+		ChannelBufferSize: cfg.Kafka.Consumer.ChannelBufferSize,
+	}
+}
+
+// newTopicPipelines creates the default storage writer and the per-topic
+// pipelines. Callers close both the writer and the resolver.
+func newTopicPipelines(
+	cfg *dto.ApplicationConfig,
+	logger *slog.Logger,
+	metrics *observability.Metrics,
+) (storageWriter, *pipelineResolver, error) {
+	// Get file format and compression
+	format, compression := storageFormat(cfg.Storage.Format, cfg.Storage.Compression)
+
+	// Object metadata and tags attached to cloud uploads
+	objectMetadata := storage.ObjectMetadataConfig{
+		Enabled: cfg.Storage.Metadata.Enabled,
+		Tags:    cfg.Storage.Metadata.Tags,
+	}
+
+	// Provenance embedded in the metadata of every written file
+	provenance := encoder.Provenance{
+		ConsumerGroup:      cfg.Kafka.Consumer.GroupID,
+		ApplicationName:    cfg.Application.Name,
+		ApplicationVersion: cfg.Application.Version,
+	}
+
+	// Typed data columns of the Parquet files of event types
+	typed, err := newTypedSchemas(cfg.Parquet)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Create the storage writer and partition router: a fan-out writer over
+	// all configured sinks, or a single writer for the storage backend
+	var writer storageWriter
+	var router *storage.DefaultRouter
+	if len(cfg.Storage.Sinks) > 0 {
+		writer, router, err = newFanoutWriter(cfg.Storage, provenance, objectMetadata, typed, logger, metrics)
+	} else {
+		router = newStorageRouter(cfg.Storage, getStorageBasePath(cfg.Storage))
+		writer, err = newStorageWriter(cfg.Storage, format, compression, provenance, objectMetadata, parquetTypedSchemas(format, typed), logger, metrics)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Resolve per-topic pipelines; topics without overrides use the default one
+	schemas := make(schemaRegistries)
+	eventValidator, err := newEventValidator(cfg.Validation, schemas, metrics)
+	if err != nil {
+		_ = writer.Close()
+		return nil, nil, err
+	}
+	registry, err := newRegistryClient(cfg.SchemaRegistry)
+	if err != nil {
+		_ = writer.Close()
+		return nil, nil, err
+	}
+	decoder := newEventDecoder(registry)
+	redactor, err := newEventRedactor(cfg.Redaction, metrics)
+	if err != nil {
+		_ = writer.Close()
+		return nil, nil, err
+	}
+	schemaTracker := newSchemaTracker(cfg.SchemaEvolution, registry, writer, router, logger, metrics)
+	defaultPipeline := &topicPipeline{
+		writer:     writer,
+		router:     router,
+		policy:     newRotationPolicy(cfg.FileRotation),
+		format:     format,
+		maxRecords: cfg.FileRotation.MaxRecordsPerFile,
+		decoder:    decoder,
+		validator:  eventValidator,
+		redactor:   redactor,
+		typed:      storageTypedSchemas(cfg.Storage, typed),
+		schemas:    schemaTracker,
+		dlq:        cfg.Kafka.DLQ,
+	}
+	pipelines, err := newPipelineResolver(cfg, defaultPipeline, schemas, registry, provenance, objectMetadata, typed, logger, metrics)
+	if err != nil {
+		_ = writer.Close()
+		return nil, nil, err
+	}
+	return writer, pipelines, nil
+}
+
+// Timings of the consume loop.
+const (
+	// checkInterval is how often buffers are checked for time-based rotation
+	// and partition windows for completion.
+	checkInterval = 10 * time.Second
+)
+
+// backoff is an exponentially growing delay between attempts.
+type backoff struct {
+	initial    time.Duration
+	max        time.Duration
+	multiplier float64
+}
+
+// delay returns the delay after the given attempt, counted from 1.
+func (b backoff) delay(attempt int) time.Duration {
+	delay := float64(b.initial) * math.Pow(max(b.multiplier, 1), float64(attempt-1))
+	if b.max > 0 && delay > float64(b.max) {
+		return b.max
+	}
+	return time.Duration(delay)
+}
+
+// eventProcessor buffers consumed events per partition and writes them to
+// the storage of their topic. It is used by the consume loop only.
+//
+// Offsets are committed once their events are stored or dead-lettered: the
+// commits of a partition wait until its buffer is flushed, and events the
+// DLQ fails to take are retried until it does, pausing consumption.
+type eventProcessor struct {
+	pipelines   *pipelineResolver
+	completion  *storage.CompletionTracker
+	dlq         *kafka.DLQPublisher
+	buffers     *simpleBufferManager
+	logger      *slog.Logger
+	metrics     *observability.Metrics
+	retry       backoff
+	gracePeriod time.Duration
+	fileStats   map[event.PartitionID]event.FileStats
+	commits     map[event.PartitionID][]func() error
+	restored    map[event.PartitionID]bool
+}
+
+// newEventProcessor creates the processor of the consume loop. Buffers are
+// flushed within gracePeriod when the loop stops.
+func newEventProcessor(
+	pipelines *pipelineResolver,
+	completion *storage.CompletionTracker,
+	dlq *kafka.DLQPublisher,
+	buffers *simpleBufferManager,
+	retry backoff,
+	gracePeriod time.Duration,
+	logger *slog.Logger,
+	metrics *observability.Metrics,
+) *eventProcessor {
+	return &eventProcessor{
+		pipelines:   pipelines,
+		completion:  completion,
+		dlq:         dlq,
+		buffers:     buffers,
+		logger:      logger,
+		metrics:     metrics,
+		retry:       retry,
+		gracePeriod: gracePeriod,
+		fileStats:   make(map[event.PartitionID]event.FileStats),
+		commits:     make(map[event.PartitionID][]func() error),
+		restored:    make(map[event.PartitionID]bool),
+	}
+}
+
+// run processes consumed events until ctx is done or the event channel is
+// closed, then flushes the buffers and commits their offsets. The consumer
+// must keep running until run returns so the final commits are not lost.
+func (p *eventProcessor) run(ctx context.Context, eventChan <-chan *event.ConsumedEvent, errorChan <-chan error) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.logger.Info("context cancelled, stopping processing")
+			p.shutdown()
+			return
+		case err := <-errorChan:
+			if err != nil {
+				p.logger.Error("consumer error", "error", err)
+			}
+		case <-ticker.C:
+			if err := p.check(ctx); err != nil {
+				p.stop(err)
+				return
+			}
+		case consumedEvent, ok := <-eventChan:
+			if !ok {
+				p.logger.Info("event channel closed")
+				p.shutdown()
+				return
+			}
+			if err := p.handle(ctx, consumedEvent); err != nil {
+				p.stop(err)
+				return
+			}
+		}
+	}
+}
+
+// stop flushes the buffers after processing was interrupted. Processing only
+// fails once ctx is done, leaving the interrupted event uncommitted.
+func (p *eventProcessor) stop(err error) {
+	p.logger.Info("processing interrupted, stopping processing", "error", err)
+	p.shutdown()
+}
+
+// handle decodes, validates and redacts a consumed event, buffers it and
+// flushes the buffer of its partition once it is due. It only fails once ctx
+// is done.
+func (p *eventProcessor) handle(ctx context.Context, consumedEvent *event.ConsumedEvent) error {
+	logger := p.logger
+	partitionID := event.PartitionID{
+		Topic:     consumedEvent.Metadata.Topic,
+		Partition: consumedEvent.Metadata.Partition,
+	}
+	pipeline := p.pipelines.resolve(partitionID.Topic)
+	p.restoreCompletion(ctx, partitionID, pipeline)
+
+	// Decode schema registry framed data to JSON before validating it
+	err := p.awaitSchema(ctx, pipeline, consumedEvent.Metadata, func(ctx context.Context) error {
+		return pipeline.decodeEvent(ctx, consumedEvent.Event)
+	})
+	if schemaUnavailable(err) {
+		return p.retryUnavailable(ctx, pipeline, consumedEvent, err)
+	}
+	if err != nil {
+		logger.Warn("failed to decode cloud event",
+			"topic", partitionID.Topic,
+			"partition", partitionID.Partition,
+			"offset", consumedEvent.Metadata.Offset,
+			"error", err,
+		)
+
+		if err := p.deadLetter(ctx, consumedEvent.Metadata, func(ctx context.Context) error {
+			return publishToDLQ(ctx, p.dlq, pipeline, consumedEvent.Event, consumedEvent.Metadata, kafka.ReasonDecodingFailed, err)
+		}); err != nil {
+			return err
+		}
+		p.commit(partitionID, consumedEvent)
+		return nil
+	}
+
+	// Validate event unless validation is disabled for the topic
+	err = p.awaitSchema(ctx, pipeline, consumedEvent.Metadata, func(ctx context.Context) error {
+		return pipeline.validateEvent(ctx, consumedEvent.Event)
+	})
+	if schemaUnavailable(err) {
+		return p.retryUnavailable(ctx, pipeline, consumedEvent, err)
+	}
+	if err != nil {
+		logger.Warn("invalid cloud event",
+			"topic", partitionID.Topic,
+			"partition", partitionID.Partition,
+			"offset", consumedEvent.Metadata.Offset,
+			"error", err,
+		)
+
+		// Send to DLQ, then commit the offset to skip the bad message
+		reason := kafka.ReasonValidationFailed
+		if validator.IsSchemaError(err) {
+			reason = kafka.ReasonSchemaValidationFailed
+		}
+		if err := p.deadLetter(ctx, consumedEvent.Metadata, func(ctx context.Context) error {
+			return publishToDLQ(ctx, p.dlq, pipeline, consumedEvent.Event, consumedEvent.Metadata, reason, err)
+		}); err != nil {
+			return err
+		}
+		p.commit(partitionID, consumedEvent)
+		return nil
+	}
+
+	// Redact personal data before the event is buffered; events
+	// that cannot be redacted are never stored
+	if err := p.redact(pipeline, consumedEvent); err != nil {
+		logger.Warn("failed to redact cloud event",
+			"topic", partitionID.Topic,
+			"partition", partitionID.Partition,
+			"offset", consumedEvent.Metadata.Offset,
+			"error", err,
+		)
+
+		if err := p.deadLetter(ctx, consumedEvent.Metadata, func(ctx context.Context) error {
+			return publishToDLQ(ctx, p.dlq, pipeline, consumedEvent.Event, consumedEvent.Metadata, kafka.ReasonRedactionFailed, err)
+		}); err != nil {
+			return err
+		}
+		p.commit(partitionID, consumedEvent)
+		return nil
+	}
+
+	// Create storage record
+	record := event.Record{
+		Event:       consumedEvent.Event,
+		Kafka:       consumedEvent.Metadata,
+		Offset:      consumedEvent.Metadata.Offset,
+		ProcessedAt: time.Now(),
+	}
+
+	// Add to buffer; its offset is committed once the buffer is flushed
+	p.buffers.append(partitionID, record)
+	p.commit(partitionID, consumedEvent)
+
+	// Advance the partition watermark
+	if p.completion != nil {
+		p.completion.Observe(partitionID, record.GetEventTime())
+	}
+
+	// Update file stats; the first record starts the rotation duration
+	stats := p.fileStats[partitionID]
+	if stats.RecordCount == 0 {
+		stats.FirstWriteTime = record.ProcessedAt
+	}
+	stats.RecordCount++
+	stats.LastWriteTime = record.ProcessedAt
+	if len(consumedEvent.Event.Data) > 0 {
+		stats.SizeBytes += int64(len(consumedEvent.Event.Data))
+	}
+	p.fileStats[partitionID] = stats
+
+	// Check if we should flush
+	if p.buffers.shouldFlush(partitionID, pipeline.policy, pipeline.maxRecords, stats) {
+		return p.flush(ctx, partitionID, pipeline)
+	}
+	return nil
+}
+
+// commit commits the offset of a consumed event. While records of its
+// partition are buffered, committing would skip them on a restart, so the
+// commit waits until the buffer is flushed.
+func (p *eventProcessor) commit(partitionID event.PartitionID, consumedEvent *event.ConsumedEvent) {
+	if consumedEvent.CommitFunc == nil {
+		return
+	}
+	if len(p.buffers.getRecords(partitionID)) > 0 {
+		p.commits[partitionID] = append(p.commits[partitionID], consumedEvent.CommitFunc)
+		return
+	}
+	if err := consumedEvent.CommitFunc(); err != nil {
+		p.logger.Error("failed to commit offset",
+			"topic", partitionID.Topic,
+			"partition", partitionID.Partition,
+			"offset", consumedEvent.Metadata.Offset,
+			"error", err,
+		)
+	}
+}
+
+// commitFlushed commits the offsets that waited for the buffer of a
+// partition to be flushed.
+func (p *eventProcessor) commitFlushed(partitionID event.PartitionID) {
+	for _, commit := range p.commits[partitionID] {
+		if err := commit(); err != nil {
+			p.logger.Error("failed to commit offset",
+				"topic", partitionID.Topic,
+				"partition", partitionID.Partition,
+				"error", err,
+			)
+		}
+	}
+	delete(p.commits, partitionID)
+}
+
+// redact redacts the data of a consumed event once and marks it redacted.
+// Retried events that were redacted before they failed to be stored are left
+// as they are, since redacting them again would hash or tokenize their
+// redacted values.
+func (p *eventProcessor) redact(pipeline *topicPipeline, consumedEvent *event.ConsumedEvent) error {
+	if pipeline.redactor == nil || kafka.IsRedacted(consumedEvent.Metadata) {
+		return nil
+	}
+	if err := pipeline.redactEvent(consumedEvent.Event); err != nil {
+		return err
+	}
+	kafka.MarkRedacted(&consumedEvent.Metadata)
+	return nil
+}
+
+// awaitSchema runs step, which decodes or validates an event with its schema.
+// Without a DLQ to retry them, events whose schema is unavailable wait for it
+// with backoff, since skipping them would lose them; consumption pauses
+// meanwhile. It returns the unavailable schema error once ctx is done.
+func (p *eventProcessor) awaitSchema(ctx context.Context, pipeline *topicPipeline, metadata event.KafkaMetadata, step func(context.Context) error) error {
+	err := step(ctx)
+	if p.dlq != nil && pipeline.dlq.Enabled {
+		return err
+	}
+	for attempt := 1; schemaUnavailable(err); attempt++ {
+		delay := p.retry.delay(attempt)
+		p.logger.Warn("event schema is unavailable, waiting for it",
+			"topic", metadata.Topic,
+			"partition", metadata.Partition,
+			"offset", metadata.Offset,
+			"attempt", attempt,
+			"retry_in", delay,
+			"error", err,
+		)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+		err = step(ctx)
+	}
+	return err
+}
+
+// retryUnavailable sends an event whose schema could not be loaded to its
+// next retry topic and commits it. The event may be valid, so it is retried
+// rather than rejected. It only fails once ctx is done, leaving the event
+// uncommitted.
+func (p *eventProcessor) retryUnavailable(ctx context.Context, pipeline *topicPipeline, consumedEvent *event.ConsumedEvent, err error) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("failed to load event schema: %w", err)
+	}
+	p.logger.Warn("event schema is unavailable, retrying event",
+		"topic", consumedEvent.Metadata.Topic,
+		"partition", consumedEvent.Metadata.Partition,
+		"offset", consumedEvent.Metadata.Offset,
+		"error", err,
+	)
+
+	if err := p.deadLetter(ctx, consumedEvent.Metadata, func(ctx context.Context) error {
+		return retryOrDLQ(ctx, p.dlq, pipeline, consumedEvent.Event, consumedEvent.Metadata, kafka.ReasonSchemaUnavailable)
+	}); err != nil {
+		return err
+	}
+	p.commit(event.PartitionID{Topic: consumedEvent.Metadata.Topic, Partition: consumedEvent.Metadata.Partition}, consumedEvent)
+	return nil
+}
+
+// schemaUnavailable reports whether err is a failure to load the schema of
+// an event from its registry, rather than a rejection of the event.
+func schemaUnavailable(err error) bool {
+	return validator.IsSchemaUnavailable(err) || confluent.IsUnavailable(err)
+}
+
+// deadLetter sends an event to a retry topic, the DLQ or the quarantine,
+// retrying with backoff until one of them takes it. Consumption pauses
+// meanwhile, since skipping the event would lose it. It fails once ctx is
+// done.
+func (p *eventProcessor) deadLetter(ctx context.Context, metadata event.KafkaMetadata, publish func(context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		err := publish(ctx)
+		if err == nil {
+			return nil
+		}
+
+		delay := p.retry.delay(attempt)
+		p.logger.Error("failed to dead-letter event, retrying",
+			"topic", metadata.Topic,
+			"partition", metadata.Partition,
+			"offset", metadata.Offset,
+			"attempt", attempt,
+			"retry_in", delay,
+			"error", err,
+		)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("failed to dead-letter event: %w", err)
+		}
+	}
+}
+
+// flush writes the buffered records of a partition to storage, clears its
+// buffer and commits their offsets. Records that fail to be stored go to the
+// retry topics or the DLQ. It only fails once ctx is done, leaving the buffer
+// and its offsets in place.
+func (p *eventProcessor) flush(ctx context.Context, partitionID event.PartitionID, pipeline *topicPipeline) error {
+	logger := p.logger
+	records := p.buffers.getRecords(partitionID)
+	if len(records) == 0 {
+		return nil
+	}
+
+	// Events of types with typed Parquet schemas are written to
+	// files of their own, and events of each schema epoch under
+	// its path version; records are batched by the path their
+	// event time and spec_version route them to
+	batches, rejected, err := pipeline.plan(ctx, partitionID, records)
+	if err != nil {
+		logger.Error("failed to resolve event schemas",
+			"topic", partitionID.Topic,
+			"partition", partitionID.Partition,
+			"error", err,
+		)
+
+		// Send to the retry topics, or the DLQ once retries are exhausted
+		for _, rec := range records {
+			if err := p.deadLetter(ctx, rec.Kafka, func(ctx context.Context) error {
+				return retryOrDLQ(ctx, p.dlq, pipeline, rec.Event, rec.Kafka, kafka.ReasonStorageFailed)
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	for _, rec := range rejected {
+		logger.Warn("incompatible event schema",
+			"topic", partitionID.Topic,
+			"partition", partitionID.Partition,
+			"offset", rec.record.Offset,
+			"error", rec.err,
+		)
+		if err := p.deadLetter(ctx, rec.record.Kafka, func(ctx context.Context) error {
+			return publishToDLQ(ctx, p.dlq, pipeline, rec.record.Event, rec.record.Kafka, kafka.ReasonSchemaIncompatible, rec.err)
+		}); err != nil {
+			return err
+		}
+	}
+	for _, batch := range batches {
+		// Write to storage
+		bytesWritten, err := pipeline.writer.Write(ctx, batch.records, batch.path, pipeline.format)
+		if err != nil {
+			logger.Error("failed to write to storage",
+				"topic", partitionID.Topic,
+				"partition", partitionID.Partition,
+				"path", batch.path,
+				"error", err,
+			)
+
+			// Send to the retry topics, or the DLQ once retries are exhausted
+			for _, rec := range batch.records {
+				if err := p.deadLetter(ctx, rec.Kafka, func(ctx context.Context) error {
+					return retryOrDLQ(ctx, p.dlq, pipeline, rec.Event, rec.Kafka, kafka.ReasonStorageFailed)
+				}); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		logger.Info("wrote batch to storage",
+			"topic", partitionID.Topic,
+			"partition", partitionID.Partition,
+			"records", len(batch.records),
+			"bytes", bytesWritten,
+			"path", batch.path,
+		)
+
+		if p.completion != nil {
+			p.completion.Track(partitionID, batch.path, pipeline.router.WindowEnd(batch.records[0].GetEventTimeUnix()))
+		}
+	}
+
+	// Clear buffer and reset stats, then commit the flushed offsets
+	p.buffers.clear(partitionID)
+	delete(p.fileStats, partitionID)
+	p.commitFlushed(partitionID)
+
+	// Mark partition windows the watermark has passed as complete
+	if p.completion != nil && len(batches) > 0 {
+		p.markComplete(ctx, partitionID, pipeline, true)
+	}
+	return nil
+}
+
+// check flushes the buffers whose rotation duration has passed and marks the
+// windows of partitions without buffered records complete, advancing the
+// watermarks of idle partitions first.
+func (p *eventProcessor) check(ctx context.Context) error {
+	for _, partitionID := range p.buffers.partitions() {
+		pipeline := p.pipelines.resolve(partitionID.Topic)
+		if p.buffers.shouldFlush(partitionID, pipeline.policy, pipeline.maxRecords, p.fileStats[partitionID]) {
+			if err := p.flush(ctx, partitionID, pipeline); err != nil {
+				return err
+			}
+		}
+	}
+
+	if p.completion == nil {
+		return nil
+	}
+	p.completion.AdvanceIdle(time.Now())
+	for _, partitionID := range p.completion.Partitions() {
+		if len(p.buffers.getRecords(partitionID)) == 0 {
+			p.markComplete(ctx, partitionID, p.pipelines.resolve(partitionID.Topic), false)
+		}
+	}
+	return nil
+}
+
+// shutdown flushes the buffers, committing their offsets, and marks the
+// completed windows of every partition. It gets a grace period of its own,
+// since the context of the loop is already done.
+func (p *eventProcessor) shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), p.gracePeriod)
+	defer cancel()
+
+	for _, partitionID := range p.buffers.partitions() {
+		if err := p.flush(ctx, partitionID, p.pipelines.resolve(partitionID.Topic)); err != nil {
+			p.logger.Error("failed to flush buffer at shutdown",
+				"topic", partitionID.Topic,
+				"partition", partitionID.Partition,
+				"records", len(p.buffers.getRecords(partitionID)),
+				"error", err,
+			)
+		}
+	}
+
+	if p.completion == nil {
+		return
+	}
+	for _, partitionID := range p.completion.Partitions() {
+		if len(p.buffers.getRecords(partitionID)) == 0 {
+			p.markComplete(ctx, partitionID, p.pipelines.resolve(partitionID.Topic), false)
+		}
+	}
+}
+
+// markComplete writes a _SUCCESS marker into every completed path of a
+// partition and persists its completion state when it changed. Paths whose
+// marker cannot be written are tracked again so a later check retries them.
+func (p *eventProcessor) markComplete(ctx context.Context, partitionID event.PartitionID, pipeline *topicPipeline, tracked bool) {
+	watermark := p.completion.Watermark(partitionID)
+	completed := p.completion.Complete(partitionID)
+	for _, path := range completed {
+		if err := pipeline.writer.WriteMarker(ctx, path, storage.SuccessMarker, nil); err != nil {
+			p.logger.Error("failed to write success marker",
+				"topic", partitionID.Topic,
+				"partition", partitionID.Partition,
+				"path", path,
+				"error", err,
+			)
+			p.completion.Track(partitionID, path, time.Time{})
+			continue
+		}
+		p.logger.Info("partition window complete",
+			"topic", partitionID.Topic,
+			"partition", partitionID.Partition,
+			"path", path,
+			"watermark", watermark,
+		)
+	}
+
+	if tracked || len(completed) > 0 {
+		p.saveCompletion(ctx, partitionID, pipeline)
+	}
+}
+
+// refreshSuccessMarker rewrites the _SUCCESS marker of a path after a late
+// write into it, so jobs notified of marker writes pick up the late files.
+// It reports whether the path was marked complete.
+func refreshSuccessMarker(ctx context.Context, writer storageWriter, path string) (bool, error) {
+	_, err := writer.ReadMarker(ctx, path, storage.SuccessMarker)
+	if errors.Is(err, pkgstorage.ErrMarkerNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, writer.WriteMarker(ctx, path, storage.SuccessMarker, nil)
+}
+
+// saveCompletion persists the completion state of a partition so pending
+// windows survive a restart.
+func (p *eventProcessor) saveCompletion(ctx context.Context, partitionID event.PartitionID, pipeline *topicPipeline) {
+	data, err := json.Marshal(p.completion.State(partitionID))
+	if err == nil {
+		err = pipeline.writer.WriteMarker(ctx, pipeline.router.CompletionPath(partitionID), storage.CompletionStateName, data)
+	}
+	if err != nil {
+		p.logger.Error("failed to save completion state",
+			"topic", partitionID.Topic,
+			"partition", partitionID.Partition,
+			"error", err,
+		)
+	}
+}
+
+// restoreCompletion restores the persisted completion state of a partition
+// the first time one of its events is handled.
+func (p *eventProcessor) restoreCompletion(ctx context.Context, partitionID event.PartitionID, pipeline *topicPipeline) {
+	if p.completion == nil || p.restored[partitionID] {
+		return
+	}
+	p.restored[partitionID] = true
+
+	data, err := pipeline.writer.ReadMarker(ctx, pipeline.router.CompletionPath(partitionID), storage.CompletionStateName)
+	if errors.Is(err, pkgstorage.ErrMarkerNotFound) {
+		return
+	}
+	var state storage.CompletionState
+	if err == nil {
+		err = json.Unmarshal(data, &state)
+	}
+	if err != nil {
+		p.logger.Warn("failed to restore completion state",
+			"topic", partitionID.Topic,
+			"partition", partitionID.Partition,
+			"error", err,
+		)
+		return
+	}
+	p.completion.Restore(partitionID, state)
+	p.logger.Info("restored completion state",
+		"topic", partitionID.Topic,
+		"partition", partitionID.Partition,
+		"watermark", state.Watermark,
+		"pending", len(state.Pending),
+	)
+}
+
+// publishToDLQ publishes a failed event, with the cause of its failure, to the
+// DLQ topic of its pipeline. It does nothing when the DLQ is disabled for the
+// topic. An error means the event reached neither the DLQ nor the quarantine.
+func publishToDLQ(
+	ctx context.Context,
+	dlq *kafka.DLQPublisher,
+	pipeline *topicPipeline,
+	evt *event.CloudEvent,
+	metadata event.KafkaMetadata,
+	reason string,
+	cause error,
+) error {
+	if dlq == nil || !pipeline.dlq.Enabled {
+		return nil
+	}
+	return dlq.PublishRejected(ctx, metadata.Topic+pipeline.dlq.TopicSuffix, evt, metadata, reason, cause)
+}
+
+// publishRawToDLQ publishes a message that could not be parsed to the DLQ
+// topic of its topic. It does nothing when the DLQ is disabled for the topic.
+// It only reads the configuration, so it is safe for concurrent use.
+func publishRawToDLQ(
+	ctx context.Context,
+	cfg *dto.ApplicationConfig,
+	dlq *kafka.DLQPublisher,
+	message kafka.RawMessage,
+	parseErr error,
+) error {
+	dlqConfig := cfg.Kafka.DLQ
+	if i := cfg.MatchTopic(message.Topic); i >= 0 {
+		dlqConfig = cfg.Topics[i].DLQFor(dlqConfig)
+	}
+	if !dlqConfig.Enabled {
+		return nil
+	}
+	return dlq.PublishRaw(ctx, message.Topic+dlqConfig.TopicSuffix, message, kafka.ReasonDeserializationFailed, parseErr)
+}
+
+// retryOrDLQ publishes an event that failed to be stored to its next retry
+// topic, or to the DLQ topic of its pipeline once retries are exhausted.
+// It does nothing when the DLQ is disabled for the topic. An error means the
+// event reached neither a retry topic, the DLQ nor the quarantine.
+func retryOrDLQ(
+	ctx context.Context,
+	dlq *kafka.DLQPublisher,
+	pipeline *topicPipeline,
+	evt *event.CloudEvent,
+	metadata event.KafkaMetadata,
+	reason string,
+) error {
+	if dlq == nil || !pipeline.dlq.Enabled {
+		return nil
+	}
+	return dlq.Retry(ctx, metadata.Topic+pipeline.dlq.TopicSuffix, evt, metadata, reason)
+}
+
+// quarantineEvent writes an event the DLQ rejected, with its failure reason,
+// under the _quarantine/ prefix of the storage of its topic. Data that may
+// hold unredacted personal data is removed first.
+func quarantineEvent(
+	ctx context.Context,
+	pipelines *pipelineResolver,
+	dlqEvent *kafka.DLQEvent,
+	logger *slog.Logger,
+	metrics *observability.Metrics,
+) error {
+	pipeline := pipelines.resolve(dlqEvent.OriginalTopic)
+	partitionID := event.PartitionID{Topic: dlqEvent.OriginalTopic, Partition: dlqEvent.OriginalPartition}
+	path := pipeline.router.QuarantinePath(partitionID, dlqEvent.FailureReason, dlqEvent.FailureTimestamp)
+	name := fmt.Sprintf("%d.json", dlqEvent.OriginalOffset)
+
+	data, err := json.Marshal(withoutUnredactedData(pipeline, dlqEvent))
+	if err != nil {
+		return fmt.Errorf("failed to marshal quarantined event: %w", err)
+	}
+	if err := pipeline.writer.WriteMarker(ctx, path, name, data); err != nil {
+		metrics.IncEventsQuarantined(dlqEvent.OriginalTopic, dlqEvent.FailureReason, "error")
+		return fmt.Errorf("failed to write quarantined event: %w", err)
+	}
+	metrics.IncEventsQuarantined(dlqEvent.OriginalTopic, dlqEvent.FailureReason, "success")
+
+	logger.Warn("quarantined event",
+		"topic", dlqEvent.OriginalTopic,
+		"partition", dlqEvent.OriginalPartition,
+		"offset", dlqEvent.OriginalOffset,
+		"reason", dlqEvent.FailureReason,
+		"path", path+name,
+	)
+	return nil
+}
+
+// withoutUnredactedData returns dlqEvent without the data it holds
+// unredacted when its pipeline redacts data. Events that failed before or
+// during redaction keep their attributes but lose their data, and raw
+// messages their original value, since neither can be redacted reliably.
+func withoutUnredactedData(pipeline *topicPipeline, dlqEvent *kafka.DLQEvent) *kafka.DLQEvent {
+	if pipeline.redactor == nil || dlqEvent.Redacted {
+		return dlqEvent
+	}
+
+	stripped := *dlqEvent
+	stripped.OriginalEvent = nil
+	stripped.OriginalValue = nil
+	stripped.Redacted = true
+	var original event.CloudEvent
+	if err := json.Unmarshal(dlqEvent.OriginalEvent, &original); err == nil {
+		original.Data = nil
+		if data, err := json.Marshal(original); err == nil {
+			stripped.OriginalEvent = data
+		}
+	}
+	return &stripped
+}
+
+// newRetryConsumer creates and subscribes the consumer of the retry topics.
+// It joins its own consumer group so retry delays never hold back new events.
+// Retry topics are discovered like pattern subscriptions, so those created
+// later are picked up; the retry topics of the static topics with a DLQ must
+// exist at startup. It returns nil when no topic has retry topics.
+func newRetryConsumer(
+	cfg *dto.ApplicationConfig,
+	consumerConfig kafka.ConsumerConfig,
+	logger *slog.Logger,
+	metrics *observability.Metrics,
+) (*kafka.SaramaConsumer, error) {
+	retryConfig := consumerConfig
+	retryConfig.GroupID = cfg.Kafka.Consumer.GroupID + cfg.Kafka.DLQ.RetryTopicSuffix
+	retryConfig.Retry = true
+	retryConfig.ExcludeTopicSuffixes = nil
+	topics := retriedTopics(cfg, cfg.Kafka.Consumer.Topics)
+	retryConfig.TopicPattern = kafka.RetryTopicPattern(topics, cfg.Kafka.Consumer.TopicPattern, cfg.Kafka.DLQ.RetryTopicSuffix)
+	if retryConfig.TopicPattern == "" {
+		return nil, nil
+	}
+
+	retryConsumer, err := kafka.NewSaramaConsumer(retryConfig, logger, metrics)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create retry consumer: %w", err)
+	}
+	if err := retryConsumer.Subscribe(context.Background(), nil); err != nil {
+		_ = retryConsumer.Close()
+		return nil, fmt.Errorf("failed to subscribe to retry topics: %w", err)
+	}
+
+	subscribed := make(map[string]bool)
+	for _, topic := range retryConsumer.Topics() {
+		subscribed[topic] = true
+	}
+	var missing []string
+	for _, topic := range kafka.RetryTopics(topics, cfg.Kafka.DLQ.RetryTopicSuffix, cfg.Kafka.DLQ.MaxRetries) {
+		if !subscribed[topic] {
+			missing = append(missing, topic)
+		}
+	}
+	if len(missing) > 0 {
+		_ = retryConsumer.Close()
+		return nil, fmt.Errorf("retry topics %v do not exist: create them or set kafka.dlq.auto_create_topics", missing)
+	}
+	return retryConsumer, nil
+}
+
+// retriedTopics returns the topics among topics whose DLQ is enabled, whose
+// storage failures pass through retry topics.
+func retriedTopics(cfg *dto.ApplicationConfig, topics []string) []string {
+	var retried []string
+	for _, topic := range topics {
+		dlqConfig := cfg.Kafka.DLQ
+		if i := cfg.MatchTopic(topic); i >= 0 {
+			dlqConfig = cfg.Topics[i].DLQFor(dlqConfig)
+		}
+		if dlqConfig.Enabled {
+			retried = append(retried, topic)
+		}
+	}
+	return retried
+}
+
+// consumeRetries starts the retry consumer in the background and returns its
+// events. The consumer only becomes ready once it joins its group, so it must
+// not hold up the main consumer. A nil consumer returns a nil channel.
+func consumeRetries(ctx context.Context, retryConsumer *kafka.SaramaConsumer, logger *slog.Logger) <-chan *event.ConsumedEvent {
+	if retryConsumer == nil {
+		return nil
+	}
+
+	retryEvents := make(chan *event.ConsumedEvent)
+	go func() {
+		defer close(retryEvents)
+
+		events, errs, err := retryConsumer.Consume(ctx)
+		if err != nil {
+			logger.Error("failed to start retry consumer", "error", err)
+			return
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err, ok := <-errs:
+				if !ok {
+					errs = nil
+					continue
+				}
+				logger.Error("retry consumer error", "error", err)
+			case evt, ok := <-events:
+				if !ok {
+					return
+				}
+				select {
+				case retryEvents <- evt:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return retryEvents
+}
+
+// mergeEvents forwards the events of primary and secondary to one channel,
+// which is closed when primary is closed or ctx is done. A nil or closed
+// secondary channel is ignored.
+func mergeEvents(ctx context.Context, primary, secondary <-chan *event.ConsumedEvent) <-chan *event.ConsumedEvent {
+	if secondary == nil {
+		return primary
+	}
+
+	merged := make(chan *event.ConsumedEvent)
+	go func() {
+		defer close(merged)
+		for {
+			var evt *event.ConsumedEvent
+			var ok bool
+			select {
+			case <-ctx.Done():
+				return
+			case evt, ok = <-primary:
+				if !ok {
+					return
+				}
+			case evt, ok = <-secondary:
+				if !ok {
+					secondary = nil
+					continue
+				}
+			}
+			select {
+			case merged <- evt:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return merged
+}
+
+// topicPipeline holds the storage, rotation, decoding, validation, redaction
+// and DLQ settings used for the events of a topic.
+type topicPipeline struct {
+	writer     storageWriter
+	router     *storage.DefaultRouter
+	policy     *storage.CompositePolicy
+	format     event.FileFormat
+	maxRecords int
+	decoder    *confluent.Decoder    // nil without a schema registry
+	validator  event.Validator       // nil when validation is disabled
+	redactor   event.Transformer     // nil without redaction rules
+	typed      *encoder.TypedSchemas // nil unless writing Parquet
+	schemas    *evolution.Tracker    // nil without schema evolution tracking
+	dlq        dto.DLQConfig
+}
+
+// pathBatch is a batch of records written to one storage path.
+type pathBatch struct {
+	path    string
+	records []event.Record
+}
+
+// rejectedRecord is a record whose schema change was rejected.
+type rejectedRecord struct {
+	record event.Record
+	err    error
+}
+
+// plan resolves the schema epochs of the buffered records of a partition and
+// splits them into the batches written to storage and the records whose
+// schema change was rejected.
+func (p *topicPipeline) plan(ctx context.Context, partitionID event.PartitionID, records []event.Record) ([]pathBatch, []rejectedRecord, error) {
+	if p.schemas == nil {
+		return p.batches(partitionID, records, nil), nil, nil
+	}
+	resolutions, err := p.schemas.Resolve(ctx, partitionID.Topic, records)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var rejected []rejectedRecord
+	accepted := make([]event.Record, 0, len(records))
+	epochs := make([]int, 0, len(records))
+	for i, resolution := range resolutions {
+		if resolution.Err != nil {
+			rejected = append(rejected, rejectedRecord{record: records[i], err: resolution.Err})
+			continue
+		}
+		accepted = append(accepted, records[i])
+		epochs = append(epochs, resolution.Epoch)
+	}
+	return p.batches(partitionID, accepted, epochs), rejected, nil
+}
+
+// batches splits records into the batches written to storage, one per
+// routed path. Events of types with a typed Parquet schema are routed to a
+// path of their type, the others to the partition path, and events of later
+// schema epochs, aligned with records or nil, under their own path version.
+// Each record is routed by its own event time and spec_version, so late or
+// retried records never share a batch, or a dt= path, with newer ones.
+func (p *topicPipeline) batches(partitionID event.PartitionID, records []event.Record, epochs []int) []pathBatch {
+	var paths []string
+	byPath := make(map[string][]event.Record)
+	for i, record := range records {
+		epoch := 0
+		if epochs != nil {
+			epoch = epochs[i]
+		}
+		eventType := ""
+		if record.Event != nil && p.typed.Has(record.Event.Type) {
+			eventType = record.Event.Type
+		}
+		path := p.router.RouteSchema(partitionID, record.GetEventTimeUnix(), specVersion(record), eventType, epoch)
+		if _, ok := byPath[path]; !ok {
+			paths = append(paths, path)
+		}
+		byPath[path] = append(byPath[path], record)
+	}
+
+	batches := make([]pathBatch, 0, len(paths))
+	for _, path := range paths {
+		batches = append(batches, pathBatch{path: path, records: byPath[path]})
+	}
+	return batches
+}
+
+// specVersion returns the spec_version of the event of record, if any.
+func specVersion(record event.Record) string {
+	if record.Event == nil {
+		return ""
+	}
+	return record.Event.SpecVersion
+}
+
+// decodeEvent decodes schema registry framed data of evt to JSON when a
+// schema registry is configured.
+func (p *topicPipeline) decodeEvent(ctx context.Context, evt *event.CloudEvent) error {
+	if p.decoder == nil {
+		return nil
+	}
+	if _, err := p.decoder.DecodeEvent(ctx, evt); err != nil {
+		return fmt.Errorf("failed to decode event data: %w", err)
+	}
+	return nil
+}
+
+// validateEvent validates evt when validation is enabled for the pipeline,
+// loading its schema within ctx.
+func (p *topicPipeline) validateEvent(ctx context.Context, evt *event.CloudEvent) error {
+	if p.validator == nil {
+		return nil
+	}
+	if contextValidator, ok := p.validator.(validator.ContextValidator); ok {
+		return contextValidator.ValidateContext(ctx, evt)
+	}
+	return p.validator.Validate(evt)
+}
+
+// redactEvent redacts the data of evt when the pipeline has redaction rules.
+func (p *topicPipeline) redactEvent(evt *event.CloudEvent) error {
+	if p.redactor == nil {
+		return nil
+	}
+	return p.redactor.Transform(evt)
+}
+
+// newEventValidator creates the validation chain of the validation settings,
+// or nil when validation is disabled.
+func newEventValidator(
+	validation dto.ValidationConfig,
+	schemas schemaRegistries,
+	metrics *observability.Metrics,
+) (event.Validator, error) {
+	if !validation.IsEnabled() {
+		return nil, nil
+	}
+	registry, err := schemas.get(validation.Schema)
+	if err != nil {
+		return nil, err
+	}
+	return validator.NewRulesChain(validator.Rules{
+		AllowedSources:     validation.AllowedSources,
+		DeniedSources:      validation.DeniedSources,
+		AllowedTypes:       validation.AllowedTypes,
+		DeniedTypes:        validation.DeniedTypes,
+		RequiredExtensions: validation.RequiredExtensions,
+		MaxDataBytes:       validation.MaxDataBytes,
+		MaxPastSkew:        time.Duration(validation.MaxPastSkewSeconds) * time.Second,
+		MaxFutureSkew:      time.Duration(validation.MaxFutureSkewSeconds) * time.Second,
+		Schemas:            registry,
+	}, metrics), nil
+}
 
-	// Load configuration
-	// Priority: CLI flag > CONFIG_PATH env var > default path
-	var cfgPath string
-	if *configPath != "" {
-		cfgPath = *configPath
-	} else if envPath := os.Getenv("CONFIG_PATH"); envPath != "" {
-		cfgPath = envPath
-	} else {
-		cfgPath = "config/application.yaml"
+// newRegistryClient creates the schema registry client, or returns nil when
+// no schema registry is configured.
+func newRegistryClient(cfg dto.SchemaRegistryConfig) (*confluent.Client, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	client, err := confluent.NewClient(confluent.ClientConfig{
+		URL:      cfg.URL,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		Timeout:  time.Duration(cfg.TimeoutMS) * time.Millisecond,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema registry client: %w", err)
 	}
+	return client, nil
+}
 
-	loader := config.NewLoader()
-	cfg, err := loader.Load(cfgPath)
+// newEventRedactor creates the redactor of the redaction settings, or nil
+// when there are no rules.
+func newEventRedactor(redaction dto.RedactionConfig, metrics *observability.Metrics) (event.Transformer, error) {
+	if len(redaction.Rules) == 0 {
+		return nil, nil
+	}
+	rules := make([]redact.Rule, 0, len(redaction.Rules))
+	for _, rule := range redaction.Rules {
+		rules = append(rules, redact.Rule{
+			Type:        rule.Type,
+			Path:        rule.Path,
+			Action:      redact.Action(rule.Action),
+			Length:      rule.Length,
+			Pattern:     rule.Pattern,
+			Replacement: rule.Replacement,
+		})
+	}
+	redactor, err := redact.NewRedactor(redact.Config{Salt: redaction.Salt, Rules: rules}, metrics)
 	if err != nil {
-		return fmt.Errorf("failed to load configuration: %w", err)
+		return nil, fmt.Errorf("failed to create redactor: %w", err)
 	}
+	return redactor, nil
+}
 
-	// Initialize observability
-	logger := observability.NewLogger(observability.LoggingConfig{
-		Level:  cfg.Observability.Logging.Level,
-		Format: cfg.Observability.Logging.Format,
-	})
-	logger.Info("starting kafka event store",
-		"version", cfg.Application.Version,
-		"environment", cfg.Application.Environment,
-	)
+// newEventDecoder creates the decoder of schema registry framed data, or nil
+// when no schema registry is configured.
+func newEventDecoder(registry *confluent.Client) *confluent.Decoder {
+	if registry == nil {
+		return nil
+	}
+	return confluent.NewDecoder(registry)
+}
 
-	registry := prometheus.NewRegistry()
-	metrics := observability.NewMetrics(registry)
+// newSchemaTracker creates the schema tracker of a storage writer, keeping
+// schema histories under the schema path of its router, or returns nil when
+// schema evolution tracking is disabled.
+func newSchemaTracker(
+	cfg dto.SchemaEvolutionConfig,
+	registry *confluent.Client,
+	writer storageWriter,
+	router *storage.DefaultRouter,
+	logger *slog.Logger,
+	metrics *observability.Metrics,
+) *evolution.Tracker {
+	if !cfg.Enabled {
+		return nil
+	}
+	trackerCfg := evolution.TrackerConfig{
+		Compatibility:  evolution.Compatibility(cfg.Compatibility),
+		OnIncompatible: evolution.Action(cfg.OnIncompatible),
+		Router:         router,
+	}
+	if registry != nil {
+		trackerCfg.Registry = registry
+	}
+	return evolution.NewTracker(trackerCfg, writer, logger, metrics)
+}
 
-	// Track cleanup functions
-	var cleanupFuncs []func() error
-	addCleanup := func(name string, fn func() error) {
-		cleanupFuncs = append(cleanupFuncs, fn)
-		logger.Debug("registered cleanup", "component", name)
+// newTypedSchemas loads the typed Parquet schemas of event types, or returns
+// nil when none are configured.
+func newTypedSchemas(cfg dto.ParquetConfig) (*encoder.TypedSchemas, error) {
+	if len(cfg.TypedSchemas) == 0 {
+		return nil, nil
+	}
+	typed := encoder.NewTypedSchemas()
+	for _, schemaCfg := range cfg.TypedSchemas {
+		format := schemaCfg.Format
+		if format == "" {
+			format = encoder.TypedSchemaJSON
+			if filepath.Ext(schemaCfg.Schema) == ".avsc" {
+				format = encoder.TypedSchemaAvro
+			}
+		}
+		schema, err := os.ReadFile(schemaCfg.Schema)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read typed schema of %s: %w", schemaCfg.Type, err)
+		}
+		if err := typed.Add(schemaCfg.Type, format, schema); err != nil {
+			return nil, err
+		}
 	}
+	return typed, nil
+}
 
-	// Initialize event validator
-	validator := newCloudEventsValidator()
+// parquetTypedSchemas returns typed when files are written in Parquet, the
+// only format with typed data columns.
+func parquetTypedSchemas(format event.FileFormat, typed *encoder.TypedSchemas) *encoder.TypedSchemas {
+	if format != event.FormatParquet {
+		return nil
+	}
+	return typed
+}
 
-	// Initialize partition router
-	protocol := getStorageProtocol(cfg.Storage.Backend)
-	bucket := getStorageBucket(cfg)
-	basePath := getStorageBasePath(cfg)
-	router := storage.NewRouter(protocol, bucket, basePath, "v1")
+// storageTypedSchemas returns typed when the storage backend, or any of its
+// sinks, writes Parquet. Events of typed types are then batched per type for
+// every sink, since sinks share the paths batches are routed to; only the
+// Parquet sinks add typed data columns.
+func storageTypedSchemas(storageCfg dto.StorageConfig, typed *encoder.TypedSchemas) *encoder.TypedSchemas {
+	if len(storageCfg.Sinks) == 0 {
+		format, _ := storageFormat(storageCfg.Format, storageCfg.Compression)
+		return parquetTypedSchemas(format, typed)
+	}
+	for _, sinkCfg := range storageCfg.Sinks {
+		if format, _ := sinkFormat(storageCfg, sinkCfg); format == event.FormatParquet {
+			return typed
+		}
+	}
+	return nil
+}
 
-	// Initialize rotation policy
-	policy := storage.NewPolicy(storage.PolicyConfig{
-		MaxFileSizeMB:      cfg.FileRotation.MaxFileSizeMB,
-		MaxRecordsPerFile:  cfg.FileRotation.MaxRecordsPerFile,
-		MaxDurationSeconds: cfg.FileRotation.MaxDurationSeconds,
-		Strategy:           cfg.FileRotation.Strategy,
-	})
+// sinkFormat returns the file format and compression of a sink, inheriting
+// those of the storage backend when the sink sets none.
+func sinkFormat(storageCfg dto.StorageConfig, sinkCfg dto.SinkConfig) (event.FileFormat, string) {
+	formatName := sinkCfg.Format
+	if formatName == "" {
+		formatName = storageCfg.Format
+	}
+	compression := sinkCfg.Compression
+	if compression == "" && formatName == storageCfg.Format {
+		compression = storageCfg.Compression
+	}
+	return storageFormat(formatName, compression)
+}
 
-	// Initialize infrastructure
-	consumerConfig := kafka.ConsumerConfig{
-		BootstrapServers:    cfg.Kafka.BootstrapServers,
-		GroupID:             cfg.Kafka.Consumer.GroupID,
-		SecurityProtocol:    cfg.Kafka.SecurityProtocol,
-		SASLMechanism:       cfg.Kafka.SASLMechanism,
-		SASLUsername:        cfg.Kafka.SASLUsername,
-		SASLPassword:        cfg.Kafka.SASLPassword,
-		AutoOffsetReset:     cfg.Kafka.Consumer.AutoOffsetReset,
-		EnableAutoCommit:    cfg.Kafka.Consumer.EnableAutoCommit,
-		MaxPollIntervalMS:   cfg.Kafka.Consumer.MaxPollIntervalMS,
-		SessionTimeoutMS:    cfg.Kafka.Consumer.SessionTimeoutMS,
-		HeartbeatIntervalMS: cfg.Kafka.Consumer.HeartbeatIntervalMS,
+// schemaRegistries shares a schema registry, and so its cache of compiled
+// schemas, between pipelines with the same schema settings.
+type schemaRegistries map[string]*schema.Registry
+
+// get returns the registry of the schema settings, or nil when schema
+// validation is disabled.
+func (r schemaRegistries) get(cfg dto.SchemaValidationConfig) (*schema.Registry, error) {
+	if !cfg.Enabled() {
+		return nil, nil
 	}
-	consumer, err := kafka.NewSaramaConsumer(consumerConfig, logger, metrics)
-	if err != nil {
-		return fmt.Errorf("failed to create consumer: %w", err)
+	key := fmt.Sprintf("%+v", cfg)
+	if registry, ok := r[key]; ok {
+		return registry, nil
 	}
-	addCleanup("kafka-consumer", consumer.Close)
 
-	dlqConfig := kafka.DLQConfig{
-		Enabled:     cfg.Kafka.DLQ.Enabled,
-		TopicSuffix: cfg.Kafka.DLQ.TopicSuffix,
-		MaxRetries:  cfg.Kafka.DLQ.MaxRetries,
+	var fetcher schema.Fetcher
+	if cfg.RegistryURL != "" {
+		httpFetcher, err := schema.NewHTTPFetcher(cfg.RegistryURL, time.Duration(cfg.TimeoutMS)*time.Millisecond)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create schema registry client: %w", err)
+		}
+		fetcher = httpFetcher
+	} else {
+		fetcher = schema.NewDirFetcher(cfg.Directory)
 	}
-	dlqPublisher, err := kafka.NewDLQPublisher(cfg.Kafka.BootstrapServers, consumerConfig, dlqConfig, logger, cfg.Application.Name)
-	if err != nil {
-		return fmt.Errorf("failed to create DLQ publisher: %w", err)
+	registry := schema.NewRegistry(fetcher, cfg.TypeMap())
+	r[key] = registry
+	return registry, nil
+}
+
+// pipelineResolver picks the pipeline of each topic from the topic overrides.
+// It is safe for concurrent use, since the DLQ quarantine resolves pipelines
+// from the consumer goroutines.
+type pipelineResolver struct {
+	mu        sync.Mutex
+	cfg       *dto.ApplicationConfig
+	fallback  *topicPipeline
+	overrides []*topicPipeline // aligned with cfg.Topics
+	writers   []storageWriter  // writers created for storage overrides
+	byTopic   map[string]*topicPipeline
+}
+
+// newPipelineResolver builds a pipeline for every topic override.
+// Overrides without storage settings share the fallback writer and router.
+func newPipelineResolver(
+	cfg *dto.ApplicationConfig,
+	fallback *topicPipeline,
+	schemas schemaRegistries,
+	registry *confluent.Client,
+	provenance encoder.Provenance,
+	objectMetadata storage.ObjectMetadataConfig,
+	typed *encoder.TypedSchemas,
+	logger *slog.Logger,
+	metrics *observability.Metrics,
+) (*pipelineResolver, error) {
+	r := &pipelineResolver{
+		cfg:       cfg,
+		fallback:  fallback,
+		overrides: make([]*topicPipeline, 0, len(cfg.Topics)),
+		byTopic:   make(map[string]*topicPipeline),
 	}
-	addCleanup("dlq-publisher", dlqPublisher.Close)
 
-	// Get file format
+	for i := range cfg.Topics {
+		topic := &cfg.Topics[i]
+		rotation := topic.FileRotationFor(cfg.FileRotation)
+		eventValidator, err := newEventValidator(topic.ValidationFor(cfg.Validation), schemas, metrics)
+		if err != nil {
+			_ = r.Close()
+			return nil, fmt.Errorf("failed to create validator for topic override %d: %w", i, err)
+		}
+		redactor, err := newEventRedactor(topic.RedactionFor(cfg.Redaction), metrics)
+		if err != nil {
+			_ = r.Close()
+			return nil, fmt.Errorf("failed to create redactor for topic override %d: %w", i, err)
+		}
+		pipeline := &topicPipeline{
+			writer:     fallback.writer,
+			router:     fallback.router,
+			policy:     newRotationPolicy(rotation),
+			format:     fallback.format,
+			maxRecords: rotation.MaxRecordsPerFile,
+			decoder:    fallback.decoder,
+			validator:  eventValidator,
+			redactor:   redactor,
+			typed:      fallback.typed,
+			schemas:    fallback.schemas,
+			dlq:        topic.DLQFor(cfg.Kafka.DLQ),
+		}
+
+		if topic.HasStorage() {
+			storageCfg := topic.StorageFor(cfg.Storage)
+			format, compression := storageFormat(storageCfg.Format, storageCfg.Compression)
+			basePath := topic.Storage.BasePath
+			if basePath == "" {
+				basePath = getStorageBasePath(storageCfg)
+			}
+
+			writer, err := newStorageWriter(storageCfg, format, compression, provenance, objectMetadata, parquetTypedSchemas(format, typed), logger, metrics)
+			if err != nil {
+				_ = r.Close()
+				return nil, fmt.Errorf("failed to create storage writer for topic override %d: %w", i, err)
+			}
+			r.writers = append(r.writers, writer)
+
+			pipeline.writer = writer
+			pipeline.router = newStorageRouter(storageCfg, basePath)
+			pipeline.format = format
+			pipeline.typed = parquetTypedSchemas(format, typed)
+			pipeline.schemas = newSchemaTracker(cfg.SchemaEvolution, registry, writer, pipeline.router, logger, metrics)
+		}
+
+		logger.Info("topic pipeline configured",
+			"name", topic.Name,
+			"pattern", topic.Pattern,
+			"prefix", pipeline.router.Prefix(),
+			"format", pipeline.format,
+			"decode", pipeline.decoder != nil,
+			"validate", pipeline.validator != nil,
+			"redact", pipeline.redactor != nil,
+			"track_schemas", pipeline.schemas != nil,
+			"dlq_enabled", pipeline.dlq.Enabled,
+		)
+		r.overrides = append(r.overrides, pipeline)
+	}
+	return r, nil
+}
+
+// resolve returns the pipeline for topic, caching the match.
+func (r *pipelineResolver) resolve(topic string) *topicPipeline {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if pipeline, ok := r.byTopic[topic]; ok {
+		return pipeline
+	}
+	pipeline := r.fallback
+	if i := r.cfg.MatchTopic(topic); i >= 0 {
+		pipeline = r.overrides[i]
+	}
+	r.byTopic[topic] = pipeline
+	return pipeline
+}
+
+// Close closes the writers created for storage overrides.
+func (r *pipelineResolver) Close() error {
+	var errs []error
+	for _, writer := range r.writers {
+		if err := writer.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// topicDLQEnabled reports whether any topic override enables the DLQ.
+func topicDLQEnabled(cfg *dto.ApplicationConfig) bool {
+	for i := range cfg.Topics {
+		if cfg.Topics[i].DLQFor(cfg.Kafka.DLQ).Enabled {
+			return true
+		}
+	}
+	return false
+}
+
+// dlqTopicSuffixes returns the global and per-topic DLQ topic suffixes and
+// the retry topic suffixes, which are excluded from pattern subscriptions.
+func dlqTopicSuffixes(cfg *dto.ApplicationConfig) []string {
+	suffixes := []string{cfg.Kafka.DLQ.TopicSuffix}
+	suffixes = append(suffixes, kafka.RetryTopicSuffixes(cfg.Kafka.DLQ.RetryTopicSuffix, cfg.Kafka.DLQ.MaxRetries)...)
+	for i := range cfg.Topics {
+		if suffix := cfg.Topics[i].DLQ.TopicSuffix; suffix != "" {
+			suffixes = append(suffixes, suffix)
+		}
+	}
+	return suffixes
+}
+
+// newRotationPolicy creates the file rotation policy for rotation settings.
+func newRotationPolicy(rotation dto.FileRotationConfig) *storage.CompositePolicy {
+	return storage.NewPolicy(storage.PolicyConfig{
+		MaxFileSizeMB:      rotation.MaxFileSizeMB,
+		MaxRecordsPerFile:  rotation.MaxRecordsPerFile,
+		MaxDurationSeconds: rotation.MaxDurationSeconds,
+		Strategy:           rotation.Strategy,
+	})
+}
+
+// storageFormat returns the file format and compression for storage settings.
+// An empty compression uses the format-specific default.
+func storageFormat(formatName, compression string) (event.FileFormat, string) {
 	format := event.FormatParquet
-	if cfg.Storage.Format == "avro" {
+	if formatName == "avro" {
 		format = event.FormatAvro
 	}
 
-	// Get compression (default to format-specific default if not specified)
-	compression := cfg.Storage.Compression
 	if compression == "" {
 		if format == event.FormatParquet {
 			compression = "snappy"
@@ -133,274 +2111,183 @@ func run() error {
 			compression = "gzip"
 		}
 	}
+	return format, compression
+}
 
-	// Create storage writer based on backend
-	var writer interface {
-		Write(ctx context.Context, records []event.Record, path string, format event.FileFormat) (int64, error)
-		Close() error
+// newStorageRouter creates the partition router for a storage backend.
+func newStorageRouter(storageCfg dto.StorageConfig, basePath string) *storage.DefaultRouter {
+	return storage.NewRouter(getStorageProtocol(storageCfg.Backend), getStorageBucket(storageCfg), basePath, "v1")
+}
+
+// newStorageWriter creates the writer for the configured storage backend.
+func newStorageWriter(
+	storageCfg dto.StorageConfig,
+	format event.FileFormat,
+	compression string,
+	provenance encoder.Provenance,
+	objectMetadata storage.ObjectMetadataConfig,
+	typed *encoder.TypedSchemas,
+	logger *slog.Logger,
+	metrics *observability.Metrics,
+) (storageWriter, error) {
+	encryptor, err := newEncryptor(storageCfg.Encryption)
+	if err != nil {
+		return nil, err
 	}
-	switch cfg.Storage.Backend {
+
+	switch storageCfg.Backend {
 	case "file":
 		fileConfig := storage.FileConfig{
-			BasePath: cfg.Storage.File.BasePath,
+			BasePath:     storageCfg.File.BasePath,
+			Provenance:   provenance,
+			TypedSchemas: typed,
+			Encryptor:    encryptor,
 		}
-		writer, err = storage.NewFileWriter(fileConfig, format, compression, logger, metrics)
+		writer, err := storage.NewFileWriter(fileConfig, format, compression, logger, metrics)
 		if err != nil {
-			return fmt.Errorf("failed to create filesystem writer: %w", err)
+			return nil, fmt.Errorf("failed to create filesystem writer: %w", err)
 		}
+		return writer, nil
 	case "s3":
 		s3Config := storage.S3Config{
-			Bucket:       cfg.Storage.S3.Bucket,
-			Region:       cfg.Storage.S3.Region,
-			Endpoint:     cfg.Storage.S3.Endpoint,
-			UsePathStyle: cfg.Storage.S3.UsePathStyle,
-			SSEEnabled:   cfg.Storage.S3.SSEEnabled,
-			SSEKMSKeyID:  cfg.Storage.S3.SSEKMSKeyID,
-		}
-		writer, err = storage.NewS3Writer(s3Config, format, compression, logger, metrics)
+			Bucket:       storageCfg.S3.Bucket,
+			Region:       storageCfg.S3.Region,
+			Endpoint:     storageCfg.S3.Endpoint,
+			UsePathStyle: storageCfg.S3.UsePathStyle,
+			SSEEnabled:   storageCfg.S3.SSEEnabled,
+			SSEKMSKeyID:  storageCfg.S3.SSEKMSKeyID,
+			Provenance:   provenance,
+			Metadata:     objectMetadata,
+			TypedSchemas: typed,
+			Encryptor:    encryptor,
+		}
+		writer, err := storage.NewS3Writer(s3Config, format, compression, logger, metrics)
 		if err != nil {
-			return fmt.Errorf("failed to create S3 writer: %w", err)
+			return nil, fmt.Errorf("failed to create S3 writer: %w", err)
 		}
+		return writer, nil
 	case "azure":
+		authMethod := storageCfg.Azure.AuthMethod
+		if authMethod == "" && storageCfg.Azure.UseManagedIdentity {
+			authMethod = storage.AzureAuthDefaultCredential
+		}
 		azureConfig := storage.AzureConfig{
-			AccountName:   cfg.Storage.Azure.AccountName,
+			AccountName:   storageCfg.Azure.AccountName,
 			AccountKey:    os.Getenv("AZURE_STORAGE_ACCOUNT_KEY"),
-			ContainerName: cfg.Storage.Azure.Container,
-			Endpoint:      "",
+			SASToken:      os.Getenv("AZURE_STORAGE_SAS_TOKEN"),
+			ContainerName: storageCfg.Azure.Container,
+			AuthMethod:    authMethod,
+			Endpoint:      storageCfg.Azure.Endpoint,
+			Provenance:    provenance,
+			Metadata:      objectMetadata,
+			TypedSchemas:  typed,
+			Encryptor:     encryptor,
 		}
-		writer, err = storage.NewAzureWriter(azureConfig, format, compression, logger, metrics)
+		writer, err := storage.NewAzureWriter(azureConfig, format, compression, logger, metrics)
 		if err != nil {
-			return fmt.Errorf("failed to create Azure Blob writer: %w", err)
+			return nil, fmt.Errorf("failed to create Azure Blob writer: %w", err)
 		}
+		return writer, nil
 	case "gcs":
 		gcsConfig := storage.GCSConfig{
-			Bucket:               cfg.Storage.GCS.Bucket,
-			ProjectID:            cfg.Storage.GCS.ProjectID,
-			CredentialsFile:      cfg.Storage.GCS.CredentialsFile,
-			CredentialsJSON:      os.Getenv("GCP_CREDENTIALS_JSON"),
-			UseDefaultCredential: cfg.Storage.GCS.UseDefaultCredential,
+			Bucket:                storageCfg.GCS.Bucket,
+			ProjectID:             storageCfg.GCS.ProjectID,
+			CredentialsFile:       storageCfg.GCS.CredentialsFile,
+			CredentialsJSON:       os.Getenv("GCP_CREDENTIALS_JSON"),
+			UseDefaultCredential:  storageCfg.GCS.UseDefaultCredential,
+			Endpoint:              storageCfg.GCS.Endpoint,
+			WithoutAuthentication: storageCfg.GCS.WithoutAuthentication,
+			ChunkSize:             storageCfg.GCS.ChunkSizeBytes,
+			Retry: storage.GCSRetryConfig{
+				MaxAttempts:    storageCfg.GCS.Retry.MaxAttempts,
+				InitialBackoff: time.Duration(storageCfg.GCS.Retry.InitialBackoffMS) * time.Millisecond,
+				MaxBackoff:     time.Duration(storageCfg.GCS.Retry.MaxBackoffMS) * time.Millisecond,
+				Multiplier:     storageCfg.GCS.Retry.BackoffMultiplier,
+				Policy:         storageCfg.GCS.Retry.Policy,
+			},
+			Provenance:   provenance,
+			Metadata:     objectMetadata,
+			TypedSchemas: typed,
+			Encryptor:    encryptor,
 		}
-		writer, err = storage.NewGCSWriter(gcsConfig, format, compression, logger, metrics)
+		writer, err := storage.NewGCSWriter(gcsConfig, format, compression, logger, metrics)
 		if err != nil {
-			return fmt.Errorf("failed to create GCS writer: %w", err)
+			return nil, fmt.Errorf("failed to create GCS writer: %w", err)
 		}
+		return writer, nil
 	default:
-		return fmt.Errorf("unsupported storage backend: %s (supported: file, s3, azure, gcs)", cfg.Storage.Backend)
-	}
-	addCleanup("storage-writer", writer.Close)
-
-	// Initialize buffer manager
-	bufferSizeBytes := int64(cfg.Processing.BufferSizeMB * 1024 * 1024)
-	bufferMgr := newSimpleBufferManager(bufferSizeBytes, cfg.FileRotation.MaxRecordsPerFile)
-
-	// Create simple health checker
-	healthChecker := &simpleHealthChecker{isHealthy: true}
-
-	// Start HTTP server
-	httpServer := server.NewServer(
-		cfg.Observability.Health.Port,
-		cfg.Observability.Metrics.Port,
-		healthChecker,
-		registry,
-		logger,
-	)
-
-	if err := httpServer.Start(); err != nil {
-		return fmt.Errorf("failed to start HTTP server: %w", err)
+		return nil, fmt.Errorf("unsupported storage backend: %s (supported: file, s3, azure, gcs)", storageCfg.Backend)
 	}
-	addCleanup("http-server", func() error {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		return httpServer.Shutdown(ctx)
-	})
-
-	logger.Info("application started successfully")
+}
 
-	// Subscribe to topics
-	if err := consumer.Subscribe(context.Background(), cfg.Kafka.Consumer.Topics); err != nil {
-		return fmt.Errorf("failed to subscribe to topics: %w", err)
+// newEncryptor creates the encryptor of encrypted storage, or nil when files
+// are stored unencrypted.
+func newEncryptor(encryption dto.EncryptionConfig) (*envelope.Encryptor, error) {
+	if !encryption.IsEnabled() {
+		return nil, nil
 	}
-
-	// Create context with cancellation
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	// Start consuming
-	eventChan, errorChan, err := consumer.Consume(ctx)
+	provider, err := envelope.NewKeyfileProvider(encryption.KeyFile)
 	if err != nil {
-		return fmt.Errorf("failed to start consuming: %w", err)
-	}
-
-	// Start consume loop in background
-	consumeErrChan := make(chan error, 1)
-	go func() {
-		consumeErrChan <- processEvents(ctx, eventChan, errorChan, validator, writer, router, policy, dlqPublisher, bufferMgr, format, logger, metrics)
-	}()
-
-	// Wait for termination signal
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-
-	select {
-	case <-sigChan:
-		logger.Info("received termination signal")
-	case err := <-consumeErrChan:
-		if err != nil {
-			logger.Error("consume error", "error", err)
-			return err
-		}
+		return nil, fmt.Errorf("failed to create encryption key provider: %w", err)
 	}
-
-	// Graceful shutdown
-	logger.Info("initiating graceful shutdown")
-	cancel()
-
-	// Allow time for in-flight operations to complete
-	shutdownTimeout := time.Duration(cfg.Shutdown.GracePeriodSeconds) * time.Second
-	time.Sleep(shutdownTimeout)
-
-	logger.Info("application stopped successfully")
-	return nil
+	return envelope.NewEncryptor(provider), nil
 }
 
-func processEvents(
-	ctx context.Context,
-	eventChan <-chan *event.ConsumedEvent,
-	errorChan <-chan error,
-	validator *simpleValidator,
-	writer interface {
-		Write(ctx context.Context, records []event.Record, path string, format event.FileFormat) (int64, error)
-		Close() error
-	},
-	router *storage.DefaultRouter,
-	policy *storage.CompositePolicy,
-	dlq *kafka.DLQPublisher,
-	bufferMgr *simpleBufferManager,
-	format event.FileFormat,
+// newFanoutWriter creates a writer that fans batches out to all configured sinks.
+// The first sink's router is the primary router that paths are routed with.
+func newFanoutWriter(
+	storageCfg dto.StorageConfig,
+	provenance encoder.Provenance,
+	objectMetadata storage.ObjectMetadataConfig,
+	typed *encoder.TypedSchemas,
 	logger *slog.Logger,
 	metrics *observability.Metrics,
-) error {
-	fileStats := make(map[event.PartitionID]event.FileStats)
-
-	for {
-		select {
-		case <-ctx.Done():
-			logger.Info("context cancelled, stopping processing")
-			return nil
-		case err := <-errorChan:
-			if err != nil {
-				logger.Error("consumer error", "error", err)
-			}
-		case consumedEvent, ok := <-eventChan:
-			if !ok {
-				logger.Info("event channel closed")
-				return nil
-			}
-
-			partitionID := event.PartitionID{
-				Topic:     consumedEvent.Metadata.Topic,
-				Partition: consumedEvent.Metadata.Partition,
-			}
-
-			// Validate event
-			if err := validator.Validate(consumedEvent.Event); err != nil {
-				logger.Warn("invalid cloud event",
-					"topic", partitionID.Topic,
-					"partition", partitionID.Partition,
-					"offset", consumedEvent.Metadata.Offset,
-					"error", err,
-				)
-
-				// Send to DLQ
-				if dlq != nil {
-					_ = dlq.Publish(ctx, consumedEvent.Event, consumedEvent.Metadata, "validation_failed")
-				}
-
-				// Commit the offset to skip bad message
-				if consumedEvent.CommitFunc != nil {
-					_ = consumedEvent.CommitFunc()
-				}
-				continue
-			}
-
-			// Create storage record
-			record := event.Record{
-				Event:       consumedEvent.Event,
-				Kafka:       consumedEvent.Metadata,
-				Offset:      consumedEvent.Metadata.Offset,
-				ProcessedAt: time.Now(),
-			}
-
-			// Add to buffer
-			bufferMgr.append(partitionID, record)
+) (*storage.FanoutWriter, *storage.DefaultRouter, error) {
+	sinks := make([]storage.Sink, 0, len(storageCfg.Sinks))
+	closeSinks := func() {
+		for _, sink := range sinks {
+			_ = sink.Writer.Close()
+		}
+	}
 
-			// Update file stats
-			stats := fileStats[partitionID]
-			stats.RecordCount++
-			if len(consumedEvent.Event.Data) > 0 {
-				stats.SizeBytes += int64(len(consumedEvent.Event.Data))
-			}
-			fileStats[partitionID] = stats
-
-			// Check if we should flush
-			if bufferMgr.shouldFlush(partitionID, policy, stats) {
-				records := bufferMgr.getRecords(partitionID)
-				if len(records) > 0 {
-					// Get event time and spec_version from first record
-					// All records in batch should have similar timestamps (within rotation window)
-					eventTime := records[0].GetEventTimeUnix()
-					specVersion := ""
-					if records[0].Event != nil {
-						specVersion = records[0].Event.SpecVersion
-					}
-
-					// Get storage path using event time (not processing time)
-					path := router.Route(partitionID, eventTime, specVersion)
-
-					// Write to storage
-					bytesWritten, err := writer.Write(ctx, records, path, format)
-					if err != nil {
-						logger.Error("failed to write to storage",
-							"topic", partitionID.Topic,
-							"partition", partitionID.Partition,
-							"error", err,
-						)
-
-						// Send to DLQ
-						if dlq != nil {
-							for _, rec := range records {
-								_ = dlq.Publish(ctx, rec.Event, rec.Kafka, "storage_failed")
-							}
-						}
-					} else {
-						logger.Info("wrote batch to storage",
-							"topic", partitionID.Topic,
-							"partition", partitionID.Partition,
-							"records", len(records),
-							"bytes", bytesWritten,
-							"path", path,
-						)
-					}
-
-					// Clear buffer and reset stats
-					bufferMgr.clear(partitionID)
-					delete(fileStats, partitionID)
-				}
-			}
+	for _, sinkCfg := range storageCfg.Sinks {
+		// Each sink uses its own backend settings and inherits the rest
+		sinkStorage := storageCfg
+		sinkStorage.Backend = sinkCfg.Backend
+		sinkStorage.S3 = sinkCfg.S3
+		sinkStorage.Azure = sinkCfg.Azure
+		sinkStorage.GCS = sinkCfg.GCS
+		sinkStorage.File = sinkCfg.File
+		sinkStorage.Sinks = nil
+
+		format, compression := sinkFormat(storageCfg, sinkCfg)
+
+		basePath := sinkCfg.BasePath
+		if basePath == "" {
+			basePath = getStorageBasePath(sinkStorage)
+		}
 
-			// Commit offset
-			if consumedEvent.CommitFunc != nil {
-				if err := consumedEvent.CommitFunc(); err != nil {
-					logger.Error("failed to commit offset",
-						"topic", partitionID.Topic,
-						"partition", partitionID.Partition,
-						"offset", consumedEvent.Metadata.Offset,
-						"error", err,
-					)
-				}
-			}
+		writer, err := newStorageWriter(sinkStorage, format, compression, provenance, objectMetadata, parquetTypedSchemas(format, typed), logger, metrics)
+		if err != nil {
+			closeSinks()
+			return nil, nil, fmt.Errorf("failed to create storage sink %s: %w", sinkCfg.Name, err)
 		}
+		sinks = append(sinks, storage.Sink{
+			Name:   sinkCfg.Name,
+			Writer: writer,
+			Router: newStorageRouter(sinkStorage, basePath),
+			Format: format,
+			Policy: sinkCfg.Policy,
+		})
+	}
+
+	primary := sinks[0].Router
+	writer, err := storage.NewFanoutWriter(sinks, primary, logger, metrics)
+	if err != nil {
+		closeSinks()
+		return nil, nil, fmt.Errorf("failed to create fan-out writer: %w", err)
 	}
+	return writer, primary, nil
 }
 
 func getStorageProtocol(backend string) string {
@@ -418,14 +2305,14 @@ func getStorageProtocol(backend string) string {
 	}
 }
 
-func getStorageBucket(cfg *dto.ApplicationConfig) string {
-	switch cfg.Storage.Backend {
+func getStorageBucket(storageCfg dto.StorageConfig) string {
+	switch storageCfg.Backend {
 	case "s3":
-		return cfg.Storage.S3.Bucket
+		return storageCfg.S3.Bucket
 	case "azure":
-		return cfg.Storage.Azure.Container
+		return storageCfg.Azure.Container
 	case "gcs":
-		return cfg.Storage.GCS.Bucket
+		return storageCfg.GCS.Bucket
 	case "file":
 		return "" // File backend uses basePath only, no bucket
 	default:
@@ -433,12 +2320,12 @@ func getStorageBucket(cfg *dto.ApplicationConfig) string {
 	}
 }
 
-func getStorageBasePath(cfg *dto.ApplicationConfig) string {
-	switch cfg.Storage.Backend {
+func getStorageBasePath(storageCfg dto.StorageConfig) string {
+	switch storageCfg.Backend {
 	case "s3":
-		return cfg.Storage.S3.BasePath
+		return storageCfg.S3.BasePath
 	case "gcs":
-		return cfg.Storage.GCS.BasePath
+		return storageCfg.GCS.BasePath
 	case "azure":
 		return ""
 	case "file":
@@ -448,6 +2335,14 @@ func getStorageBasePath(cfg *dto.ApplicationConfig) string {
 	}
 }
 
+// storageWriter is implemented by every storage backend writer.
+type storageWriter interface {
+	pkgstorage.Writer
+	pkgstorage.MarkerWriter
+	pkgstorage.MarkerReader
+	pkgstorage.ConditionalMarkerStore
+}
+
 // simpleHealthChecker implements server.HealthChecker interface
 type simpleHealthChecker struct {
 	isHealthy bool
@@ -471,32 +2366,6 @@ func (h *simpleHealthChecker) GetStatus() map[string]string {
 	}
 }
 
-// simpleValidator validates CloudEvents
-type simpleValidator struct{}
-
-func newCloudEventsValidator() *simpleValidator {
-	return &simpleValidator{}
-}
-
-func (v *simpleValidator) Validate(evt *event.CloudEvent) error {
-	if evt == nil {
-		return fmt.Errorf("event is nil")
-	}
-	if evt.ID == "" {
-		return fmt.Errorf("event ID is required")
-	}
-	if evt.Source == "" {
-		return fmt.Errorf("event source is required")
-	}
-	if evt.Type == "" {
-		return fmt.Errorf("event type is required")
-	}
-	if evt.SpecVersion == "" {
-		return fmt.Errorf("event spec version is required")
-	}
-	return nil
-}
-
 // simpleBufferManager manages per-partition buffers
 type simpleBufferManager struct {
 	buffers             map[event.PartitionID]*partitionBuffer
@@ -528,12 +2397,12 @@ func (m *simpleBufferManager) append(partitionID event.PartitionID, record event
 	buf.size += int64(len(record.Event.Data))
 }
 
-func (m *simpleBufferManager) shouldFlush(partitionID event.PartitionID, policy *storage.CompositePolicy, stats event.FileStats) bool {
+func (m *simpleBufferManager) shouldFlush(partitionID event.PartitionID, policy *storage.CompositePolicy, maxRecords int, stats event.FileStats) bool {
 	buf, exists := m.buffers[partitionID]
 	if !exists {
 		return false
 	}
-	return policy.ShouldRotate(stats) || len(buf.records) >= m.maxRecordsPerBuffer
+	return policy.ShouldRotate(stats) || len(buf.records) >= maxRecords
 }
 
 func (m *simpleBufferManager) getRecords(partitionID event.PartitionID) []event.Record {
@@ -544,6 +2413,15 @@ func (m *simpleBufferManager) getRecords(partitionID event.PartitionID) []event.
 	return buf.records
 }
 
+// partitions returns the partitions with buffered records.
+func (m *simpleBufferManager) partitions() []event.PartitionID {
+	partitions := make([]event.PartitionID, 0, len(m.buffers))
+	for partitionID := range m.buffers {
+		partitions = append(partitions, partitionID)
+	}
+	return partitions
+}
+
 func (m *simpleBufferManager) clear(partitionID event.PartitionID) {
 	delete(m.buffers, partitionID)
 }
@@ -2,21 +2,27 @@ package dto
 
 import (
 	"fmt"
+	"regexp"
 	"time"
 )
 
 // ApplicationConfig is the root configuration structure
 type ApplicationConfig struct {
-	Application   ApplicationInfo     `mapstructure:"application"`
-	Kafka         KafkaConfig         `mapstructure:"kafka"`
-	Storage       StorageConfig       `mapstructure:"storage"`
-	FileRotation  FileRotationConfig  `mapstructure:"file_rotation"`
-	Parquet       ParquetConfig       `mapstructure:"parquet"`
-	Avro          AvroConfig          `mapstructure:"avro"`
-	Processing    ProcessingConfig    `mapstructure:"processing"`
-	Retry         RetryConfig         `mapstructure:"retry"`
-	Observability ObservabilityConfig `mapstructure:"observability"`
-	Shutdown      ShutdownConfig      `mapstructure:"shutdown"`
+	Application     ApplicationInfo       `mapstructure:"application"`
+	Kafka           KafkaConfig           `mapstructure:"kafka"`
+	Storage         StorageConfig         `mapstructure:"storage"`
+	FileRotation    FileRotationConfig    `mapstructure:"file_rotation"`
+	Parquet         ParquetConfig         `mapstructure:"parquet"`
+	Avro            AvroConfig            `mapstructure:"avro"`
+	Processing      ProcessingConfig      `mapstructure:"processing"`
+	Retry           RetryConfig           `mapstructure:"retry"`
+	Observability   ObservabilityConfig   `mapstructure:"observability"`
+	Shutdown        ShutdownConfig        `mapstructure:"shutdown"`
+	Validation      ValidationConfig      `mapstructure:"validation"`
+	SchemaRegistry  SchemaRegistryConfig  `mapstructure:"schema_registry"`
+	SchemaEvolution SchemaEvolutionConfig `mapstructure:"schema_evolution"`
+	Redaction       RedactionConfig       `mapstructure:"redaction"`
+	Topics          []TopicConfig         `mapstructure:"topics"`
 }
 
 // ApplicationInfo contains application metadata
@@ -33,8 +39,52 @@ type KafkaConfig struct {
 	SASLMechanism    string         `mapstructure:"sasl_mechanism"`
 	SASLUsername     string         `mapstructure:"sasl_username"`
 	SASLPassword     string         `mapstructure:"sasl_password"`
+	Version          string         `mapstructure:"version"` // Kafka protocol version, e.g. 3.6.0
+	TLS              KafkaTLSConfig `mapstructure:"tls"`
 	Consumer         ConsumerConfig `mapstructure:"consumer"`
 	DLQ              DLQConfig      `mapstructure:"dlq"`
+	// AWSRegion is used for AWS_MSK_IAM; empty derives it from the broker host names or AWS_REGION
+	AWSRegion string           `mapstructure:"aws_region"`
+	OAuth     KafkaOAuthConfig `mapstructure:"oauth"`
+}
+
+// KafkaOAuthConfig contains OAuth 2.0 client credentials settings for the OAUTHBEARER mechanism
+type KafkaOAuthConfig struct {
+	TokenURL     string   `mapstructure:"token_url"`
+	ClientID     string   `mapstructure:"client_id"`
+	ClientSecret string   `mapstructure:"client_secret"`
+	Scopes       []string `mapstructure:"scopes"`
+	// Extensions are SASL extensions such as Confluent Cloud's logicalCluster.
+	// They are a list because viper lowercases map keys.
+	Extensions []SASLExtension `mapstructure:"extensions"`
+}
+
+// SASLExtension is a SASL/OAUTHBEARER extension key and value
+type SASLExtension struct {
+	Key   string `mapstructure:"key"`
+	Value string `mapstructure:"value"`
+}
+
+// ExtensionMap returns the SASL extensions keyed by name
+func (c KafkaOAuthConfig) ExtensionMap() map[string]string {
+	if len(c.Extensions) == 0 {
+		return nil
+	}
+	extensions := make(map[string]string, len(c.Extensions))
+	for _, ext := range c.Extensions {
+		extensions[ext.Key] = ext.Value
+	}
+	return extensions
+}
+
+// KafkaTLSConfig contains TLS settings for SSL and SASL_SSL connections
+type KafkaTLSConfig struct {
+	CAFile             string `mapstructure:"ca_file"`
+	CertFile           string `mapstructure:"cert_file"`
+	KeyFile            string `mapstructure:"key_file"`
+	ServerName         string `mapstructure:"server_name"`
+	MinVersion         string `mapstructure:"min_version"`          // 1.2, 1.3
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"` // local development only
 }
 
 // ConsumerConfig contains Kafka consumer configuration
@@ -43,29 +93,229 @@ type ConsumerConfig struct {
 	Topics              []string `mapstructure:"topics"`
 	AutoOffsetReset     string   `mapstructure:"auto_offset_reset"`
 	EnableAutoCommit    bool     `mapstructure:"enable_auto_commit"`
-	MaxPollRecords      int      `mapstructure:"max_poll_records"`
+	MaxPollRecords      int      `mapstructure:"max_poll_records"` // size of the event channel, not a per-fetch limit
 	MaxPollIntervalMS   int      `mapstructure:"max_poll_interval_ms"`
 	SessionTimeoutMS    int      `mapstructure:"session_timeout_ms"`
 	HeartbeatIntervalMS int      `mapstructure:"heartbeat_interval_ms"`
+	// TopicPattern also subscribes to every topic whose whole name matches the regular expression
+	TopicPattern                string `mapstructure:"topic_pattern"`
+	TopicRefreshIntervalSeconds int    `mapstructure:"topic_refresh_interval_seconds"`
+	// GroupInstanceID enables static group membership, e.g. ${POD_NAME}
+	GroupInstanceID   string `mapstructure:"group_instance_id"`
+	RebalanceStrategy string `mapstructure:"rebalance_strategy"` // roundrobin, range, sticky
+	FetchMinBytes     int32  `mapstructure:"fetch_min_bytes"`
+	FetchMaxBytes     int32  `mapstructure:"fetch_max_bytes"`
+	ChannelBufferSize int    `mapstructure:"channel_buffer_size"`
 }
 
 // DLQConfig contains dead letter queue configuration
 type DLQConfig struct {
 	Enabled     bool   `mapstructure:"enabled"`
 	TopicSuffix string `mapstructure:"topic_suffix"`
-	MaxRetries  int    `mapstructure:"max_retries"`
+	// MaxRetries is the number of <topic><retry_topic_suffix>-N retry topics a
+	// storage failure passes through before it reaches the DLQ
+	MaxRetries       int    `mapstructure:"max_retries"`
+	RetryTopicSuffix string `mapstructure:"retry_topic_suffix"`
+	RetryBackoffMS   int    `mapstructure:"retry_backoff_ms"` // delay before the first retry, doubled per attempt
+	// AutoCreateTopics creates missing DLQ and retry topics with the topic_* settings
+	AutoCreateTopics       bool  `mapstructure:"auto_create_topics"`
+	TopicPartitions        int32 `mapstructure:"topic_partitions"`
+	TopicReplicationFactor int16 `mapstructure:"topic_replication_factor"`
+	TopicRetentionMS       int64 `mapstructure:"topic_retention_ms"` // 0 keeps the broker default, -1 keeps events forever
 }
 
 // StorageConfig contains storage backend configuration
 type StorageConfig struct {
-	Backend      string      `mapstructure:"backend"`
-	Format       string      `mapstructure:"format"`
-	Compression  string      `mapstructure:"compression"`
-	PathTemplate string      `mapstructure:"path_template"`
-	S3           S3Config    `mapstructure:"s3"`
-	Azure        AzureConfig `mapstructure:"azure"`
-	GCS          GCSConfig   `mapstructure:"gcs"`
-	File         FileConfig  `mapstructure:"file"`
+	Backend      string           `mapstructure:"backend"`
+	Format       string           `mapstructure:"format"`
+	Compression  string           `mapstructure:"compression"`
+	PathTemplate string           `mapstructure:"path_template"`
+	S3           S3Config         `mapstructure:"s3"`
+	Azure        AzureConfig      `mapstructure:"azure"`
+	GCS          GCSConfig        `mapstructure:"gcs"`
+	File         FileConfig       `mapstructure:"file"`
+	Completion   CompletionConfig `mapstructure:"completion"`
+	Metadata     MetadataConfig   `mapstructure:"object_metadata"`
+	Encryption   EncryptionConfig `mapstructure:"encryption"`
+	Sinks        []SinkConfig     `mapstructure:"sinks"`
+}
+
+// SinkConfig contains the settings of one storage sink for multi-sink fan-out.
+// Empty format and compression fall back to the top-level storage settings.
+type SinkConfig struct {
+	Name        string      `mapstructure:"name"`
+	Backend     string      `mapstructure:"backend"`
+	Format      string      `mapstructure:"format"`
+	Compression string      `mapstructure:"compression"`
+	Policy      string      `mapstructure:"policy"`    // required, best_effort
+	BasePath    string      `mapstructure:"base_path"` // router base path under the bucket
+	S3          S3Config    `mapstructure:"s3"`
+	Azure       AzureConfig `mapstructure:"azure"`
+	GCS         GCSConfig   `mapstructure:"gcs"`
+	File        FileConfig  `mapstructure:"file"`
+}
+
+// TopicConfig overrides pipeline settings for the topics it matches.
+// Entries are a list rather than a map keyed by topic because the config
+// loader lowercases keys and splits them on dots.
+// Zero values inherit the global settings.
+type TopicConfig struct {
+	Name         string             `mapstructure:"name"`    // exact topic name
+	Pattern      string             `mapstructure:"pattern"` // regular expression matched against the whole topic name
+	Storage      TopicStorageConfig `mapstructure:"storage"`
+	FileRotation FileRotationConfig `mapstructure:"file_rotation"`
+	Validation   ValidationConfig   `mapstructure:"validation"`
+	Redaction    RedactionConfig    `mapstructure:"redaction"`
+	DLQ          TopicDLQConfig     `mapstructure:"dlq"`
+
+	pattern *regexp.Regexp // compiled Pattern, set by CompilePattern
+}
+
+// TopicStorageConfig overrides storage settings for a topic.
+// A non-empty backend section replaces the global one.
+type TopicStorageConfig struct {
+	Backend     string           `mapstructure:"backend"`
+	Format      string           `mapstructure:"format"`
+	Compression string           `mapstructure:"compression"`
+	BasePath    string           `mapstructure:"base_path"` // router base path under the bucket
+	S3          S3Config         `mapstructure:"s3"`
+	Azure       AzureConfig      `mapstructure:"azure"`
+	GCS         GCSConfig        `mapstructure:"gcs"`
+	File        FileConfig       `mapstructure:"file"`
+	Encryption  EncryptionConfig `mapstructure:"encryption"`
+}
+
+// ValidationConfig contains event validation settings. Source and type
+// entries match exactly, or by prefix when they end in *.
+type ValidationConfig struct {
+	Enabled              *bool                  `mapstructure:"enabled"` // nil keeps validation enabled
+	AllowedSources       []string               `mapstructure:"allowed_sources"`
+	DeniedSources        []string               `mapstructure:"denied_sources"`
+	AllowedTypes         []string               `mapstructure:"allowed_types"`
+	DeniedTypes          []string               `mapstructure:"denied_types"`
+	RequiredExtensions   []string               `mapstructure:"required_extensions"`
+	MaxDataBytes         int                    `mapstructure:"max_data_bytes"`          // 0 disables the limit
+	MaxPastSkewSeconds   int                    `mapstructure:"max_past_skew_seconds"`   // 0 disables the limit
+	MaxFutureSkewSeconds int                    `mapstructure:"max_future_skew_seconds"` // 0 disables the limit
+	Schema               SchemaValidationConfig `mapstructure:"schema"`
+}
+
+// SchemaValidationConfig configures JSON Schema validation of event data.
+// Schemas are loaded from a local directory or an HTTP registry; setting
+// neither disables schema validation.
+type SchemaValidationConfig struct {
+	Directory   string `mapstructure:"directory"`
+	RegistryURL string `mapstructure:"registry_url"`
+	TimeoutMS   int    `mapstructure:"timeout_ms"` // registry request timeout
+	// Types maps event types to the schema of events without a dataschema.
+	Types []SchemaTypeMapping `mapstructure:"types"`
+}
+
+// SchemaTypeMapping maps an event type to a schema reference, resolved
+// against the schema directory or registry.
+type SchemaTypeMapping struct {
+	Type   string `mapstructure:"type"`
+	Schema string `mapstructure:"schema"`
+}
+
+// Enabled reports whether a schema source is configured.
+func (c SchemaValidationConfig) Enabled() bool {
+	return c.Directory != "" || c.RegistryURL != ""
+}
+
+// TypeMap returns the schema references by event type.
+func (c SchemaValidationConfig) TypeMap() map[string]string {
+	if len(c.Types) == 0 {
+		return nil
+	}
+	types := make(map[string]string, len(c.Types))
+	for _, mapping := range c.Types {
+		types[mapping.Type] = mapping.Schema
+	}
+	return types
+}
+
+// SchemaRegistryConfig configures the Confluent compatible Schema Registry
+// used to decode Avro, Protobuf and JSON Schema payloads in its wire format
+// to JSON. An empty URL disables decoding.
+type SchemaRegistryConfig struct {
+	URL       string `mapstructure:"url"`
+	Username  string `mapstructure:"username"` // basic auth, optional
+	Password  string `mapstructure:"password"`
+	TimeoutMS int    `mapstructure:"timeout_ms"`
+}
+
+// Enabled reports whether a schema registry is configured.
+func (c SchemaRegistryConfig) Enabled() bool {
+	return c.URL != ""
+}
+
+// SchemaEvolutionConfig configures schema tracking per topic and event type.
+// Schemas come from the Schema Registry for decoded events and are inferred
+// from JSON data otherwise; their history is kept under the _schemas prefix.
+type SchemaEvolutionConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Compatibility is none, backward, forward or full
+	Compatibility string `mapstructure:"compatibility"`
+	// OnIncompatible is version, to write under a new path version, or
+	// reject, to send the events to the DLQ
+	OnIncompatible string `mapstructure:"on_incompatible"`
+}
+
+// RedactionConfig contains the rules redacting personal data in event data
+// after validation. No rules disables redaction.
+type RedactionConfig struct {
+	Salt  string                `mapstructure:"salt"` // keys the sha256 and tokenize actions
+	Rules []RedactionRuleConfig `mapstructure:"rules"`
+}
+
+// RedactionRuleConfig redacts the values a JSON path selects in the data of
+// events of a type.
+type RedactionRuleConfig struct {
+	Type        string `mapstructure:"type"`        // exact, or a prefix ending in *; empty matches all types
+	Path        string `mapstructure:"path"`        // e.g. $.memberEmail or $.loans[*].memberName
+	Action      string `mapstructure:"action"`      // drop, sha256, tokenize, truncate, mask
+	Length      int    `mapstructure:"length"`      // characters truncate keeps
+	Pattern     string `mapstructure:"pattern"`     // regular expression mask replaces
+	Replacement string `mapstructure:"replacement"` // mask replacement; empty masks with *
+}
+
+// IsEnabled reports whether events are validated.
+func (c ValidationConfig) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// TopicDLQConfig overrides dead letter queue settings for a topic. Retry
+// settings (max_retries, retry_topic_suffix, retry_backoff_ms) are global.
+type TopicDLQConfig struct {
+	Enabled     *bool  `mapstructure:"enabled"`
+	TopicSuffix string `mapstructure:"topic_suffix"`
+}
+
+// MetadataConfig contains object metadata and tag settings for cloud uploads
+type MetadataConfig struct {
+	Enabled bool              `mapstructure:"enabled"`
+	Tags    map[string]string `mapstructure:"tags"`
+}
+
+// EncryptionConfig contains client-side envelope encryption settings. Each
+// file is encrypted with its own data key, which is stored wrapped by the
+// provider's key encryption key (KEK).
+type EncryptionConfig struct {
+	Enabled  *bool  `mapstructure:"enabled"`  // nil keeps encryption disabled
+	Provider string `mapstructure:"provider"` // keyfile
+	KeyFile  string `mapstructure:"key_file"` // KEK of the keyfile provider
+}
+
+// IsEnabled reports whether files are encrypted.
+func (c EncryptionConfig) IsEnabled() bool {
+	return c.Enabled != nil && *c.Enabled
+}
+
+// CompletionConfig contains partition completion marker settings
+type CompletionConfig struct {
+	SuccessMarker          bool `mapstructure:"success_marker"`
+	AllowedLatenessSeconds int  `mapstructure:"allowed_lateness_seconds"`
 }
 
 // S3Config contains AWS S3 configuration
@@ -83,6 +333,8 @@ type S3Config struct {
 type AzureConfig struct {
 	AccountName        string `mapstructure:"account_name"`
 	Container          string `mapstructure:"container"`
+	Endpoint           string `mapstructure:"endpoint"`
+	AuthMethod         string `mapstructure:"auth_method"` // shared_key, sas, default_credential
 	UseManagedIdentity bool   `mapstructure:"use_managed_identity"`
 }
 
@@ -94,6 +346,20 @@ type GCSConfig struct {
 	CredentialsFile      string `mapstructure:"credentials_file"`
 	CredentialsJSON      string `mapstructure:"credentials_json"`
 	UseDefaultCredential bool   `mapstructure:"use_default_credential"`
+	Endpoint             string `mapstructure:"endpoint"`
+	// WithoutAuthentication disables credentials, for emulators such as fake-gcs-server
+	WithoutAuthentication bool           `mapstructure:"without_authentication"`
+	ChunkSizeBytes        int            `mapstructure:"chunk_size_bytes"`
+	Retry                 GCSRetryConfig `mapstructure:"retry"`
+}
+
+// GCSRetryConfig contains retry settings for GCS object operations
+type GCSRetryConfig struct {
+	MaxAttempts       int     `mapstructure:"max_attempts"`
+	InitialBackoffMS  int     `mapstructure:"initial_backoff_ms"`
+	MaxBackoffMS      int     `mapstructure:"max_backoff_ms"`
+	BackoffMultiplier float64 `mapstructure:"backoff_multiplier"`
+	Policy            string  `mapstructure:"policy"` // idempotent, always, never
 }
 
 // FileConfig contains local filesystem configuration
@@ -116,6 +382,19 @@ type ParquetConfig struct {
 	PageSizeKB       int    `mapstructure:"page_size_kb"`
 	EnableStatistics bool   `mapstructure:"enable_statistics"`
 	EnableDictionary bool   `mapstructure:"enable_dictionary"`
+	// TypedSchemas add typed data columns to the Parquet files of event types
+	TypedSchemas []ParquetTypedSchemaConfig `mapstructure:"typed_schemas"`
+}
+
+// ParquetTypedSchemaConfig maps an event type to the schema of its data.
+// Events of the type are written to files of their own, with the data
+// columns of the schema next to the raw JSON data.
+type ParquetTypedSchemaConfig struct {
+	Type   string `mapstructure:"type"`   // CloudEvent type
+	Schema string `mapstructure:"schema"` // path of the schema file
+	// Format is json_schema or avro; empty infers avro from a .avsc
+	// extension and json_schema otherwise
+	Format string `mapstructure:"format"`
 }
 
 // AvroConfig contains Avro format settings
@@ -227,6 +506,11 @@ func (c *AzureConfig) Validate() error {
 	if c.Container == "" {
 		return fmt.Errorf("azure container is required")
 	}
+	switch c.AuthMethod {
+	case "", "shared_key", "sas", "default_credential":
+	default:
+		return fmt.Errorf("unsupported azure auth method: %s", c.AuthMethod)
+	}
 	return nil
 }
 
@@ -237,3 +521,178 @@ func (c *FileConfig) Validate() error {
 	}
 	return nil
 }
+
+// MatchTopic returns the index of the topic override for topic.
+// Exact names take precedence over patterns; among patterns the first match wins.
+// Patterns only match once compiled by CompilePattern, which the loader does.
+// It returns -1 when no override matches.
+func (c *ApplicationConfig) MatchTopic(topic string) int {
+	for i, t := range c.Topics {
+		if t.Name != "" && t.Name == topic {
+			return i
+		}
+	}
+	for i, t := range c.Topics {
+		if t.pattern != nil && t.pattern.MatchString(topic) {
+			return i
+		}
+	}
+	return -1
+}
+
+// CompilePattern compiles the pattern of the topic so it is matched against
+// whole topic names. It does nothing for topics matched by name.
+func (c *TopicConfig) CompilePattern() error {
+	if c.Pattern == "" {
+		c.pattern = nil
+		return nil
+	}
+	pattern, err := regexp.Compile("^(?:" + c.Pattern + ")$")
+	if err != nil {
+		return err
+	}
+	c.pattern = pattern
+	return nil
+}
+
+// HasStorage reports whether the topic overrides any storage setting.
+func (c *TopicConfig) HasStorage() bool {
+	return c.Storage != TopicStorageConfig{}
+}
+
+// StorageFor returns the storage configuration for the topic, applying its
+// overrides to base. Compression is inherited only when the format is unchanged,
+// and topics with storage overrides never use the base sinks.
+func (c *TopicConfig) StorageFor(base StorageConfig) StorageConfig {
+	if !c.HasStorage() {
+		return base
+	}
+	storage := base
+	storage.Sinks = nil
+
+	override := c.Storage
+	if override.Backend != "" {
+		storage.Backend = override.Backend
+	}
+	if override.Format != "" && override.Format != base.Format {
+		storage.Format = override.Format
+		storage.Compression = ""
+	}
+	if override.Compression != "" {
+		storage.Compression = override.Compression
+	}
+	if override.S3 != (S3Config{}) {
+		storage.S3 = override.S3
+	}
+	if override.Azure != (AzureConfig{}) {
+		storage.Azure = override.Azure
+	}
+	if override.GCS != (GCSConfig{}) {
+		storage.GCS = override.GCS
+	}
+	if override.File != (FileConfig{}) {
+		storage.File = override.File
+	}
+	if override.Encryption.Enabled != nil {
+		storage.Encryption.Enabled = override.Encryption.Enabled
+	}
+	if override.Encryption.Provider != "" {
+		storage.Encryption.Provider = override.Encryption.Provider
+	}
+	if override.Encryption.KeyFile != "" {
+		storage.Encryption.KeyFile = override.Encryption.KeyFile
+	}
+	return storage
+}
+
+// FileRotationFor returns the file rotation settings for the topic.
+// Zero-valued fields inherit from base.
+func (c *TopicConfig) FileRotationFor(base FileRotationConfig) FileRotationConfig {
+	rotation := base
+	if c.FileRotation.MaxFileSizeMB > 0 {
+		rotation.MaxFileSizeMB = c.FileRotation.MaxFileSizeMB
+	}
+	if c.FileRotation.MaxRecordsPerFile > 0 {
+		rotation.MaxRecordsPerFile = c.FileRotation.MaxRecordsPerFile
+	}
+	if c.FileRotation.MaxDurationSeconds > 0 {
+		rotation.MaxDurationSeconds = c.FileRotation.MaxDurationSeconds
+	}
+	if c.FileRotation.Strategy != "" {
+		rotation.Strategy = c.FileRotation.Strategy
+	}
+	return rotation
+}
+
+// ValidationEnabled reports whether events on the topic are validated.
+func (c *TopicConfig) ValidationEnabled() bool {
+	return c.Validation.IsEnabled()
+}
+
+// ValidationFor returns the validation settings for the topic.
+// Non-nil lists replace the base lists, so an empty list clears a global
+// rule; zero limits inherit from base. A schema section with a directory or
+// registry replaces the base one; otherwise its types replace the base types.
+func (c *TopicConfig) ValidationFor(base ValidationConfig) ValidationConfig {
+	validation := base
+	override := c.Validation
+	if override.Enabled != nil {
+		validation.Enabled = override.Enabled
+	}
+	if override.AllowedSources != nil {
+		validation.AllowedSources = override.AllowedSources
+	}
+	if override.DeniedSources != nil {
+		validation.DeniedSources = override.DeniedSources
+	}
+	if override.AllowedTypes != nil {
+		validation.AllowedTypes = override.AllowedTypes
+	}
+	if override.DeniedTypes != nil {
+		validation.DeniedTypes = override.DeniedTypes
+	}
+	if override.RequiredExtensions != nil {
+		validation.RequiredExtensions = override.RequiredExtensions
+	}
+	if override.MaxDataBytes > 0 {
+		validation.MaxDataBytes = override.MaxDataBytes
+	}
+	if override.MaxPastSkewSeconds > 0 {
+		validation.MaxPastSkewSeconds = override.MaxPastSkewSeconds
+	}
+	if override.MaxFutureSkewSeconds > 0 {
+		validation.MaxFutureSkewSeconds = override.MaxFutureSkewSeconds
+	}
+	if override.Schema.Enabled() {
+		validation.Schema = override.Schema
+	} else if override.Schema.Types != nil {
+		validation.Schema.Types = override.Schema.Types
+	}
+	return validation
+}
+
+// RedactionFor returns the redaction settings for the topic. Non-nil rules
+// replace the base rules, so an empty list disables redaction; an empty salt
+// inherits from base.
+func (c *TopicConfig) RedactionFor(base RedactionConfig) RedactionConfig {
+	redaction := base
+	if c.Redaction.Salt != "" {
+		redaction.Salt = c.Redaction.Salt
+	}
+	if c.Redaction.Rules != nil {
+		redaction.Rules = c.Redaction.Rules
+	}
+	return redaction
+}
+
+// DLQFor returns the dead letter queue settings for the topic.
+func (c *TopicConfig) DLQFor(base DLQConfig) DLQConfig {
+	dlq := base
+	if c.DLQ.Enabled != nil {
+		dlq.Enabled = *c.DLQ.Enabled
+	}
+	if c.DLQ.TopicSuffix != "" {
+		dlq.TopicSuffix = c.DLQ.TopicSuffix
+	}
+	return dlq
+}
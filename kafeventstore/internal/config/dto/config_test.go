@@ -1,6 +1,7 @@
 package dto
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -525,3 +526,332 @@ func TestFullApplicationConfig(t *testing.T) {
 		t.Error("Shutdown config invalid")
 	}
 }
+
+func TestApplicationConfig_MatchTopic(t *testing.T) {
+	config := &ApplicationConfig{
+		Topics: []TopicConfig{
+			{Pattern: `.*\.events`},
+			{Name: "audit.events"},
+			{Pattern: `click.*`},
+		},
+	}
+	for i := range config.Topics {
+		if err := config.Topics[i].CompilePattern(); err != nil {
+			t.Fatalf("CompilePattern() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		topic string
+		want  int
+	}{
+		{topic: "audit.events", want: 1},  // exact name beats an earlier pattern
+		{topic: "orders.events", want: 0}, // first matching pattern wins
+		{topic: "clickstream", want: 2},
+		{topic: "orders.events.v2", want: -1}, // patterns match the whole name
+		{topic: "payments", want: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.topic, func(t *testing.T) {
+			if got := config.MatchTopic(tt.topic); got != tt.want {
+				t.Errorf("MatchTopic(%q) = %d, want %d", tt.topic, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTopicConfig_CompilePattern(t *testing.T) {
+	uncompiled := &ApplicationConfig{Topics: []TopicConfig{{Pattern: `.*\.events`}}}
+	if got := uncompiled.MatchTopic("orders.events"); got != -1 {
+		t.Errorf("MatchTopic() of an uncompiled pattern = %d, want -1", got)
+	}
+
+	invalid := TopicConfig{Pattern: `(`}
+	if err := invalid.CompilePattern(); err == nil {
+		t.Error("CompilePattern() of an invalid pattern succeeded")
+	}
+}
+
+func TestTopicConfig_StorageFor(t *testing.T) {
+	base := StorageConfig{
+		Backend:     "s3",
+		Format:      "parquet",
+		Compression: "snappy",
+		S3:          S3Config{Bucket: "events-prod", Region: "us-east-1"},
+		Sinks:       []SinkConfig{{Name: "mirror"}},
+	}
+
+	tests := []struct {
+		name            string
+		override        TopicStorageConfig
+		wantBackend     string
+		wantFormat      string
+		wantCompression string
+		wantBucket      string
+		wantSinks       int
+	}{
+		{
+			name:            "no override keeps base",
+			wantBackend:     "s3",
+			wantFormat:      "parquet",
+			wantCompression: "snappy",
+			wantBucket:      "events-prod",
+			wantSinks:       1,
+		},
+		{
+			name:            "compression only",
+			override:        TopicStorageConfig{Compression: "zstd"},
+			wantBackend:     "s3",
+			wantFormat:      "parquet",
+			wantCompression: "zstd",
+			wantBucket:      "events-prod",
+		},
+		{
+			name:        "format change drops inherited compression",
+			override:    TopicStorageConfig{Format: "avro", S3: S3Config{Bucket: "audit", Region: "eu-west-1"}},
+			wantBackend: "s3",
+			wantFormat:  "avro",
+			wantBucket:  "audit",
+		},
+		{
+			name:            "backend change",
+			override:        TopicStorageConfig{Backend: "file", File: FileConfig{BasePath: "/mnt/events"}},
+			wantBackend:     "file",
+			wantFormat:      "parquet",
+			wantCompression: "snappy",
+			wantBucket:      "events-prod",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &TopicConfig{Storage: tt.override}
+			got := topic.StorageFor(base)
+			if got.Backend != tt.wantBackend || got.Format != tt.wantFormat || got.Compression != tt.wantCompression {
+				t.Errorf("StorageFor() = %s/%s/%s, want %s/%s/%s",
+					got.Backend, got.Format, got.Compression, tt.wantBackend, tt.wantFormat, tt.wantCompression)
+			}
+			if got.S3.Bucket != tt.wantBucket {
+				t.Errorf("StorageFor() bucket = %s, want %s", got.S3.Bucket, tt.wantBucket)
+			}
+			if len(got.Sinks) != tt.wantSinks {
+				t.Errorf("StorageFor() sinks = %d, want %d", len(got.Sinks), tt.wantSinks)
+			}
+		})
+	}
+}
+
+func TestTopicConfig_StorageFor_Encryption(t *testing.T) {
+	enabled, disabled := true, false
+	base := StorageConfig{
+		Backend:    "s3",
+		Format:     "parquet",
+		S3:         S3Config{Bucket: "events-prod"},
+		Encryption: EncryptionConfig{Provider: "keyfile", KeyFile: "/etc/kafeventstore/kek"},
+	}
+
+	tests := []struct {
+		name        string
+		override    EncryptionConfig
+		wantEnabled bool
+		wantKeyFile string
+	}{
+		{name: "enabled with the base key", override: EncryptionConfig{Enabled: &enabled}, wantEnabled: true, wantKeyFile: "/etc/kafeventstore/kek"},
+		{name: "own key", override: EncryptionConfig{Enabled: &enabled, KeyFile: "/etc/kafeventstore/regulated-kek"}, wantEnabled: true, wantKeyFile: "/etc/kafeventstore/regulated-kek"},
+		{name: "disabled", override: EncryptionConfig{Enabled: &disabled}, wantEnabled: false, wantKeyFile: "/etc/kafeventstore/kek"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &TopicConfig{Storage: TopicStorageConfig{Encryption: tt.override}}
+			if !topic.HasStorage() {
+				t.Fatal("HasStorage() = false, want true")
+			}
+			got := topic.StorageFor(base).Encryption
+			if got.IsEnabled() != tt.wantEnabled || got.KeyFile != tt.wantKeyFile || got.Provider != "keyfile" {
+				t.Errorf("StorageFor() encryption = %+v, want enabled %v with %s", got, tt.wantEnabled, tt.wantKeyFile)
+			}
+		})
+	}
+
+	if (EncryptionConfig{}).IsEnabled() {
+		t.Error("expected encryption disabled by default")
+	}
+}
+
+func TestTopicConfig_FileRotationFor(t *testing.T) {
+	base := FileRotationConfig{MaxFileSizeMB: 128, MaxRecordsPerFile: 100000, MaxDurationSeconds: 300, Strategy: "any"}
+	topic := &TopicConfig{FileRotation: FileRotationConfig{MaxDurationSeconds: 86400, Strategy: "all"}}
+
+	got := topic.FileRotationFor(base)
+	want := FileRotationConfig{MaxFileSizeMB: 128, MaxRecordsPerFile: 100000, MaxDurationSeconds: 86400, Strategy: "all"}
+	if got != want {
+		t.Errorf("FileRotationFor() = %+v, want %+v", got, want)
+	}
+}
+
+func TestTopicConfig_ValidationAndDLQ(t *testing.T) {
+	disabled, enabled := false, true
+	base := DLQConfig{Enabled: false, TopicSuffix: ".dlq", MaxRetries: 3}
+
+	topic := &TopicConfig{}
+	if !topic.ValidationEnabled() {
+		t.Error("expected validation enabled by default")
+	}
+	if got := topic.DLQFor(base); got != base {
+		t.Errorf("DLQFor() = %+v, want %+v", got, base)
+	}
+
+	topic = &TopicConfig{
+		Validation: ValidationConfig{Enabled: &disabled},
+		DLQ:        TopicDLQConfig{Enabled: &enabled, TopicSuffix: ".audit-dlq"},
+	}
+	if topic.ValidationEnabled() {
+		t.Error("expected validation disabled")
+	}
+	want := DLQConfig{Enabled: true, TopicSuffix: ".audit-dlq", MaxRetries: 3}
+	if got := topic.DLQFor(base); got != want {
+		t.Errorf("DLQFor() = %+v, want %+v", got, want)
+	}
+}
+
+func TestTopicConfig_ValidationFor(t *testing.T) {
+	disabled := false
+	base := ValidationConfig{
+		AllowedSources:     []string{"/billing/*"},
+		DeniedTypes:        []string{"debug.*"},
+		RequiredExtensions: []string{"tenant"},
+		MaxDataBytes:       1024,
+		MaxPastSkewSeconds: 3600,
+	}
+
+	topic := &TopicConfig{}
+	if got := topic.ValidationFor(base); !reflect.DeepEqual(got, base) {
+		t.Errorf("ValidationFor() = %+v, want %+v", got, base)
+	}
+	if !base.IsEnabled() {
+		t.Error("expected validation enabled by default")
+	}
+
+	topic = &TopicConfig{Validation: ValidationConfig{
+		Enabled:              &disabled,
+		AllowedSources:       []string{},
+		AllowedTypes:         []string{"order.*"},
+		MaxDataBytes:         2048,
+		MaxFutureSkewSeconds: 60,
+	}}
+	got := topic.ValidationFor(base)
+	want := ValidationConfig{
+		Enabled:              &disabled,
+		AllowedSources:       []string{},
+		AllowedTypes:         []string{"order.*"},
+		DeniedTypes:          []string{"debug.*"},
+		RequiredExtensions:   []string{"tenant"},
+		MaxDataBytes:         2048,
+		MaxPastSkewSeconds:   3600,
+		MaxFutureSkewSeconds: 60,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ValidationFor() = %+v, want %+v", got, want)
+	}
+	if got.IsEnabled() {
+		t.Error("expected validation disabled")
+	}
+}
+
+func TestTopicConfig_RedactionFor(t *testing.T) {
+	base := RedactionConfig{
+		Salt:  "pepper",
+		Rules: []RedactionRuleConfig{{Path: "$.memberEmail", Action: "sha256"}},
+	}
+
+	tests := []struct {
+		name      string
+		redaction RedactionConfig
+		want      RedactionConfig
+	}{
+		{name: "inherit", want: base},
+		{
+			name:      "replace rules",
+			redaction: RedactionConfig{Rules: []RedactionRuleConfig{{Path: "$.memberName", Action: "drop"}}},
+			want:      RedactionConfig{Salt: "pepper", Rules: []RedactionRuleConfig{{Path: "$.memberName", Action: "drop"}}},
+		},
+		{
+			name:      "disable",
+			redaction: RedactionConfig{Rules: []RedactionRuleConfig{}},
+			want:      RedactionConfig{Salt: "pepper", Rules: []RedactionRuleConfig{}},
+		},
+		{
+			name:      "salt",
+			redaction: RedactionConfig{Salt: "topic-pepper"},
+			want:      RedactionConfig{Salt: "topic-pepper", Rules: base.Rules},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := &TopicConfig{Redaction: tt.redaction}
+			if got := topic.RedactionFor(base); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RedactionFor() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemaValidationConfig(t *testing.T) {
+	schema := SchemaValidationConfig{}
+	if schema.Enabled() || schema.TypeMap() != nil {
+		t.Errorf("expected empty schema config to be disabled: %+v", schema)
+	}
+
+	schema = SchemaValidationConfig{
+		Directory: "/etc/schemas",
+		Types:     []SchemaTypeMapping{{Type: "order.created", Schema: "orders/v1.json"}},
+	}
+	if !schema.Enabled() {
+		t.Error("expected schema validation enabled")
+	}
+	if got := schema.TypeMap(); !reflect.DeepEqual(got, map[string]string{"order.created": "orders/v1.json"}) {
+		t.Errorf("TypeMap() = %v", got)
+	}
+
+	base := ValidationConfig{Schema: schema}
+	registry := SchemaValidationConfig{RegistryURL: "https://schemas.example.com/"}
+	topic := &TopicConfig{Validation: ValidationConfig{Schema: registry}}
+	if got := topic.ValidationFor(base).Schema; !reflect.DeepEqual(got, registry) {
+		t.Errorf("ValidationFor().Schema = %+v, want %+v", got, registry)
+	}
+
+	types := []SchemaTypeMapping{{Type: "order.created", Schema: "orders/v2.json"}}
+	topic = &TopicConfig{Validation: ValidationConfig{Schema: SchemaValidationConfig{Types: types}}}
+	want := SchemaValidationConfig{Directory: "/etc/schemas", Types: types}
+	if got := topic.ValidationFor(base).Schema; !reflect.DeepEqual(got, want) {
+		t.Errorf("ValidationFor().Schema = %+v, want %+v", got, want)
+	}
+}
+
+func TestKafkaOAuthConfig_ExtensionMap(t *testing.T) {
+	if got := (KafkaOAuthConfig{}).ExtensionMap(); got != nil {
+		t.Errorf("ExtensionMap() = %v, want nil", got)
+	}
+
+	config := KafkaOAuthConfig{
+		Extensions: []SASLExtension{
+			{Key: "logicalCluster", Value: "lkc-123"},
+			{Key: "identityPoolId", Value: "pool-abc"},
+		},
+	}
+	got := config.ExtensionMap()
+	if len(got) != 2 || got["logicalCluster"] != "lkc-123" || got["identityPoolId"] != "pool-abc" {
+		t.Errorf("ExtensionMap() = %v", got)
+	}
+}
+
+func TestSchemaRegistryConfig_Enabled(t *testing.T) {
+	if (SchemaRegistryConfig{}).Enabled() {
+		t.Error("expected empty schema registry config to be disabled")
+	}
+	if !(SchemaRegistryConfig{URL: "http://schema-registry:8081"}).Enabled() {
+		t.Error("expected schema registry enabled")
+	}
+}
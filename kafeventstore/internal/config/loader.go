@@ -3,10 +3,13 @@ package config
 import (
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	"regexp"
 	"strings"
 
 	"github.com/jittakal/kafeventstore/internal/config/dto"
+	"github.com/jittakal/kafeventstore/internal/storage"
 	"github.com/spf13/viper"
 )
 
@@ -73,21 +76,34 @@ func (l *Loader) setDefaults() {
 	// Kafka defaults
 	l.v.SetDefault("kafka.security_protocol", "SASL_SSL")
 	l.v.SetDefault("kafka.sasl_mechanism", "PLAIN")
+	l.v.SetDefault("kafka.version", "2.8.0")
+	l.v.SetDefault("kafka.consumer.rebalance_strategy", "roundrobin")
 	l.v.SetDefault("kafka.consumer.auto_offset_reset", "earliest")
 	l.v.SetDefault("kafka.consumer.enable_auto_commit", false)
 	l.v.SetDefault("kafka.consumer.max_poll_records", 1000)
 	l.v.SetDefault("kafka.consumer.max_poll_interval_ms", 300000)
 	l.v.SetDefault("kafka.consumer.session_timeout_ms", 30000)
 	l.v.SetDefault("kafka.consumer.heartbeat_interval_ms", 10000)
+	l.v.SetDefault("kafka.consumer.topic_refresh_interval_seconds", 60)
 	l.v.SetDefault("kafka.dlq.enabled", true)
 	l.v.SetDefault("kafka.dlq.topic_suffix", "-dlq")
 	l.v.SetDefault("kafka.dlq.max_retries", 3)
+	l.v.SetDefault("kafka.dlq.retry_topic_suffix", "-retry")
+	l.v.SetDefault("kafka.dlq.retry_backoff_ms", 30000)
+	l.v.SetDefault("kafka.dlq.auto_create_topics", false)
+	l.v.SetDefault("kafka.dlq.topic_partitions", 1)
+	l.v.SetDefault("kafka.dlq.topic_replication_factor", 3)
+	l.v.SetDefault("kafka.dlq.topic_retention_ms", 0)
 
 	// Storage defaults
 	l.v.SetDefault("storage.backend", "file")
 	l.v.SetDefault("storage.format", "parquet")
 	l.v.SetDefault("storage.s3.use_path_style", false)
 	l.v.SetDefault("storage.s3.sse_enabled", true)
+	l.v.SetDefault("storage.completion.success_marker", true)
+	l.v.SetDefault("storage.completion.allowed_lateness_seconds", 300)
+	l.v.SetDefault("storage.object_metadata.enabled", false)
+	l.v.SetDefault("storage.encryption.provider", "keyfile")
 
 	// File rotation defaults
 	l.v.SetDefault("file_rotation.max_file_size_mb", 128)
@@ -134,50 +150,367 @@ func (l *Loader) setDefaults() {
 	l.v.SetDefault("observability.health.liveness_path", "/health/live")
 	l.v.SetDefault("observability.health.readiness_path", "/health/ready")
 
+	// Validation defaults; zero limits are not enforced
+	l.v.SetDefault("validation.max_data_bytes", 0)
+	l.v.SetDefault("validation.max_past_skew_seconds", 0)
+	l.v.SetDefault("validation.max_future_skew_seconds", 0)
+	l.v.SetDefault("validation.schema.timeout_ms", 5000)
+
+	// Schema registry defaults; decoding is disabled without a URL
+	l.v.SetDefault("schema_registry.timeout_ms", 5000)
+
+	// Schema evolution defaults
+	l.v.SetDefault("schema_evolution.enabled", false)
+	l.v.SetDefault("schema_evolution.compatibility", "backward")
+	l.v.SetDefault("schema_evolution.on_incompatible", "version")
+
 	// Shutdown defaults
 	l.v.SetDefault("shutdown.grace_period_seconds", 30)
 	l.v.SetDefault("shutdown.force_timeout_seconds", 60)
 }
 
+// validateStorageBackend validates the settings of a storage backend.
+// prefix is the configuration key holding the backend settings.
+func validateStorageBackend(
+	prefix string,
+	backend string,
+	s3 dto.S3Config,
+	azure dto.AzureConfig,
+	gcs dto.GCSConfig,
+	file dto.FileConfig,
+) error {
+	switch backend {
+	case "s3":
+		if s3.Bucket == "" {
+			return fmt.Errorf("%s.s3.bucket is required for S3 backend", prefix)
+		}
+		if s3.Region == "" {
+			return fmt.Errorf("%s.s3.region is required for S3 backend", prefix)
+		}
+	case "azure":
+		if azure.AccountName == "" {
+			return fmt.Errorf("%s.azure.account_name is required for Azure backend", prefix)
+		}
+		if azure.Container == "" {
+			return fmt.Errorf("%s.azure.container is required for Azure backend", prefix)
+		}
+		switch azure.AuthMethod {
+		case "", "shared_key", "sas", "default_credential":
+		default:
+			return fmt.Errorf("unsupported %s.azure.auth_method: %s", prefix, azure.AuthMethod)
+		}
+	case "gcs":
+		if gcs.Bucket == "" {
+			return fmt.Errorf("%s.gcs.bucket is required for GCS backend", prefix)
+		}
+		if gcs.ChunkSizeBytes < 0 {
+			return fmt.Errorf("%s.gcs.chunk_size_bytes must be non-negative", prefix)
+		}
+		switch gcs.Retry.Policy {
+		case "", "idempotent", "always", "never":
+		default:
+			return fmt.Errorf("unsupported %s.gcs.retry.policy: %s", prefix, gcs.Retry.Policy)
+		}
+	case "file":
+		if file.BasePath == "" {
+			return fmt.Errorf("%s.file.base_path is required for file backend", prefix)
+		}
+	default:
+		return fmt.Errorf("unsupported %s backend: %s", prefix, backend)
+	}
+	return nil
+}
+
+// validateSinks validates the storage sinks used for multi-sink fan-out.
+func validateSinks(sinks []dto.SinkConfig) error {
+	names := make(map[string]bool, len(sinks))
+	for i, sink := range sinks {
+		prefix := fmt.Sprintf("storage.sinks[%d]", i)
+		if sink.Name == "" {
+			return fmt.Errorf("%s.name is required", prefix)
+		}
+		if names[sink.Name] {
+			return fmt.Errorf("duplicate storage sink name: %s", sink.Name)
+		}
+		names[sink.Name] = true
+
+		if err := validateStorageBackend(prefix, sink.Backend, sink.S3, sink.Azure, sink.GCS, sink.File); err != nil {
+			return err
+		}
+		if sink.Format != "" && sink.Format != "parquet" && sink.Format != "avro" {
+			return fmt.Errorf("unsupported %s.format: %s", prefix, sink.Format)
+		}
+		switch sink.Policy {
+		case "", "required", "best_effort":
+		default:
+			return fmt.Errorf("unsupported %s.policy: %s", prefix, sink.Policy)
+		}
+	}
+	return nil
+}
+
+// validateValidation validates event validation rules.
+// prefix is the configuration key holding the rules.
+func validateValidation(prefix string, validation dto.ValidationConfig) error {
+	if validation.MaxDataBytes < 0 || validation.MaxPastSkewSeconds < 0 || validation.MaxFutureSkewSeconds < 0 {
+		return fmt.Errorf("%s limits must be non-negative", prefix)
+	}
+	lists := []struct {
+		key     string
+		entries []string
+	}{
+		{"allowed_sources", validation.AllowedSources},
+		{"denied_sources", validation.DeniedSources},
+		{"allowed_types", validation.AllowedTypes},
+		{"denied_types", validation.DeniedTypes},
+		{"required_extensions", validation.RequiredExtensions},
+	}
+	for _, list := range lists {
+		for _, entry := range list.entries {
+			if strings.TrimSpace(entry) == "" {
+				return fmt.Errorf("%s.%s entries must not be empty", prefix, list.key)
+			}
+		}
+	}
+
+	schema := validation.Schema
+	if schema.Directory != "" && schema.RegistryURL != "" {
+		return fmt.Errorf("%s.schema must set only one of directory and registry_url", prefix)
+	}
+	if schema.RegistryURL != "" {
+		u, err := url.Parse(schema.RegistryURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid %s.schema.registry_url: %s", prefix, schema.RegistryURL)
+		}
+	}
+	if schema.TimeoutMS < 0 {
+		return fmt.Errorf("%s.schema.timeout_ms must be non-negative", prefix)
+	}
+	types := make(map[string]bool, len(schema.Types))
+	for i, mapping := range schema.Types {
+		if mapping.Type == "" || mapping.Schema == "" {
+			return fmt.Errorf("%s.schema.types[%d] requires a type and a schema", prefix, i)
+		}
+		if types[mapping.Type] {
+			return fmt.Errorf("duplicate %s.schema.types entry: %s", prefix, mapping.Type)
+		}
+		types[mapping.Type] = true
+	}
+	return nil
+}
+
+// validateRedaction validates redaction rules.
+func validateRedaction(prefix string, redaction dto.RedactionConfig) error {
+	for i, rule := range redaction.Rules {
+		if strings.TrimSpace(rule.Path) == "" {
+			return fmt.Errorf("%s.rules[%d].path is required", prefix, i)
+		}
+		switch rule.Action {
+		case "drop":
+		case "sha256", "tokenize":
+			if redaction.Salt == "" {
+				return fmt.Errorf("%s.rules[%d].action %s requires %s.salt", prefix, i, rule.Action, prefix)
+			}
+		case "truncate":
+			if rule.Length < 0 {
+				return fmt.Errorf("%s.rules[%d].length must be non-negative", prefix, i)
+			}
+		case "mask":
+			if rule.Pattern == "" {
+				return fmt.Errorf("%s.rules[%d].pattern is required for mask", prefix, i)
+			}
+			if _, err := regexp.Compile(rule.Pattern); err != nil {
+				return fmt.Errorf("invalid %s.rules[%d].pattern: %w", prefix, i, err)
+			}
+		default:
+			return fmt.Errorf("unsupported %s.rules[%d].action: %s", prefix, i, rule.Action)
+		}
+	}
+	return nil
+}
+
+// validateTypedSchemas validates the typed Parquet schemas of event types.
+func validateTypedSchemas(schemas []dto.ParquetTypedSchemaConfig) error {
+	types := make(map[string]bool, len(schemas))
+	for i, schema := range schemas {
+		if schema.Type == "" {
+			return fmt.Errorf("parquet.typed_schemas[%d].type is required", i)
+		}
+		if schema.Schema == "" {
+			return fmt.Errorf("parquet.typed_schemas[%d].schema is required", i)
+		}
+		if types[schema.Type] {
+			return fmt.Errorf("duplicate parquet.typed_schemas type: %s", schema.Type)
+		}
+		types[schema.Type] = true
+		if schema.Format != "" && schema.Format != "json_schema" && schema.Format != "avro" {
+			return fmt.Errorf("unsupported parquet.typed_schemas[%d].format: %s", i, schema.Format)
+		}
+	}
+	return nil
+}
+
+// validateEncryption validates client-side encryption settings.
+func validateEncryption(prefix string, encryption dto.EncryptionConfig) error {
+	if !encryption.IsEnabled() {
+		return nil
+	}
+	if encryption.Provider != "keyfile" {
+		return fmt.Errorf("unsupported %s.provider: %s", prefix, encryption.Provider)
+	}
+	if encryption.KeyFile == "" {
+		return fmt.Errorf("%s.key_file is required for the keyfile provider", prefix)
+	}
+	return nil
+}
+
+// validateTopics validates the per-topic pipeline overrides.
+func validateTopics(config *dto.ApplicationConfig) error {
+	names := make(map[string]bool, len(config.Topics))
+	for i := range config.Topics {
+		topic := &config.Topics[i]
+		prefix := fmt.Sprintf("topics[%d]", i)
+
+		switch {
+		case topic.Name == "" && topic.Pattern == "":
+			return fmt.Errorf("%s requires a name or a pattern", prefix)
+		case topic.Name != "" && topic.Pattern != "":
+			return fmt.Errorf("%s must set only one of name and pattern", prefix)
+		case topic.Name != "":
+			if names[topic.Name] {
+				return fmt.Errorf("duplicate topic override: %s", topic.Name)
+			}
+			names[topic.Name] = true
+		default:
+			// Compiled once here, so matching topics never compiles them
+			if err := topic.CompilePattern(); err != nil {
+				return fmt.Errorf("invalid %s.pattern: %w", prefix, err)
+			}
+		}
+
+		if topic.HasStorage() {
+			if len(config.Storage.Sinks) > 0 && topic.Storage.Backend == "" {
+				return fmt.Errorf("%s.storage.backend is required when storage.sinks is configured", prefix)
+			}
+			storage := topic.StorageFor(config.Storage)
+			if err := validateStorageBackend(prefix+".storage", storage.Backend,
+				storage.S3, storage.Azure, storage.GCS, storage.File); err != nil {
+				return err
+			}
+			if storage.Format != "parquet" && storage.Format != "avro" {
+				return fmt.Errorf("unsupported %s.storage.format: %s", prefix, storage.Format)
+			}
+			if err := validateEncryption(prefix+".storage.encryption", storage.Encryption); err != nil {
+				return err
+			}
+		}
+
+		if err := validateValidation(prefix+".validation", topic.Validation); err != nil {
+			return err
+		}
+		if err := validateRedaction(prefix+".redaction", topic.RedactionFor(config.Redaction)); err != nil {
+			return err
+		}
+		if schema := topic.ValidationFor(config.Validation).Schema; len(schema.Types) > 0 && !schema.Enabled() {
+			return fmt.Errorf("%s.validation.schema.types requires a schema directory or registry_url", prefix)
+		}
+
+		rotation := topic.FileRotation
+		if rotation.MaxFileSizeMB < 0 || rotation.MaxRecordsPerFile < 0 || rotation.MaxDurationSeconds < 0 {
+			return fmt.Errorf("%s.file_rotation limits must be non-negative", prefix)
+		}
+		if rotation.Strategy != "" && rotation.Strategy != "any" && rotation.Strategy != "all" {
+			return fmt.Errorf("unsupported %s.file_rotation.strategy: %s", prefix, rotation.Strategy)
+		}
+	}
+	return nil
+}
+
 // Validate validates the configuration
 func (l *Loader) Validate(config *dto.ApplicationConfig) error {
 	// Kafka validation
 	if len(config.Kafka.BootstrapServers) == 0 {
 		return errors.New("kafka.bootstrap_servers is required")
 	}
-	if len(config.Kafka.Consumer.Topics) == 0 {
-		return errors.New("kafka.consumer.topics is required")
+	if len(config.Kafka.Consumer.Topics) == 0 && config.Kafka.Consumer.TopicPattern == "" {
+		return errors.New("kafka.consumer.topics or kafka.consumer.topic_pattern is required")
+	}
+	if config.Kafka.Consumer.TopicPattern != "" {
+		if _, err := regexp.Compile(config.Kafka.Consumer.TopicPattern); err != nil {
+			return fmt.Errorf("invalid kafka.consumer.topic_pattern: %w", err)
+		}
+		if config.Kafka.Consumer.TopicRefreshIntervalSeconds <= 0 {
+			return errors.New("kafka.consumer.topic_refresh_interval_seconds must be positive")
+		}
 	}
 	if config.Kafka.Consumer.GroupID == "" {
 		return errors.New("kafka.consumer.group_id is required")
 	}
-
-	// Storage validation
-	switch config.Storage.Backend {
-	case "s3":
-		if config.Storage.S3.Bucket == "" {
-			return errors.New("storage.s3.bucket is required for S3 backend")
+	if (config.Kafka.TLS.CertFile == "") != (config.Kafka.TLS.KeyFile == "") {
+		return errors.New("kafka.tls.cert_file and kafka.tls.key_file must be set together")
+	}
+	if config.Kafka.SASLMechanism == "OAUTHBEARER" &&
+		(config.Kafka.OAuth.TokenURL == "" || config.Kafka.OAuth.ClientID == "") {
+		return errors.New("kafka.oauth.token_url and kafka.oauth.client_id are required for OAUTHBEARER")
+	}
+	for _, ext := range config.Kafka.OAuth.Extensions {
+		if ext.Key == "" {
+			return errors.New("kafka.oauth.extensions key is required")
 		}
-		if config.Storage.S3.Region == "" {
-			return errors.New("storage.s3.region is required for S3 backend")
+	}
+	switch config.Kafka.TLS.MinVersion {
+	case "", "1.2", "1.3":
+	default:
+		return fmt.Errorf("unsupported kafka.tls.min_version: %s", config.Kafka.TLS.MinVersion)
+	}
+	switch config.Kafka.Consumer.RebalanceStrategy {
+	case "", "roundrobin", "range", "sticky":
+	default:
+		return fmt.Errorf("unsupported kafka.consumer.rebalance_strategy: %s", config.Kafka.Consumer.RebalanceStrategy)
+	}
+	if config.Kafka.Consumer.FetchMinBytes < 0 || config.Kafka.Consumer.FetchMaxBytes < 0 || config.Kafka.Consumer.ChannelBufferSize < 0 {
+		return errors.New("kafka.consumer fetch and buffer sizes must be non-negative")
+	}
+	if maxBytes := config.Kafka.Consumer.FetchMaxBytes; maxBytes > 0 && config.Kafka.Consumer.FetchMinBytes > maxBytes {
+		return errors.New("kafka.consumer.fetch_min_bytes must not exceed fetch_max_bytes")
+	}
+	if config.Kafka.DLQ.MaxRetries < 0 || config.Kafka.DLQ.RetryBackoffMS < 0 {
+		return errors.New("kafka.dlq.max_retries and retry_backoff_ms must be non-negative")
+	}
+	if config.Kafka.DLQ.MaxRetries > 0 {
+		if config.Kafka.DLQ.RetryTopicSuffix == "" {
+			return errors.New("kafka.dlq.retry_topic_suffix is required when max_retries is set")
 		}
-	case "azure":
-		if config.Storage.Azure.AccountName == "" {
-			return errors.New("storage.azure.account_name is required for Azure backend")
+		if config.Kafka.DLQ.RetryTopicSuffix == config.Kafka.DLQ.TopicSuffix {
+			return errors.New("kafka.dlq.retry_topic_suffix must differ from topic_suffix")
 		}
-		if config.Storage.Azure.Container == "" {
-			return errors.New("storage.azure.container is required for Azure backend")
+	}
+	if config.Kafka.DLQ.AutoCreateTopics {
+		if config.Kafka.DLQ.TopicPartitions < 1 || config.Kafka.DLQ.TopicReplicationFactor < 1 {
+			return errors.New("kafka.dlq.topic_partitions and topic_replication_factor must be positive when auto_create_topics is set")
 		}
-	case "gcs":
-		if config.Storage.GCS.Bucket == "" {
-			return errors.New("storage.gcs.bucket is required for GCS backend")
+		if config.Kafka.DLQ.TopicRetentionMS < -1 {
+			return errors.New("kafka.dlq.topic_retention_ms must be -1 or more")
 		}
-	case "file":
-		if config.Storage.File.BasePath == "" {
-			return errors.New("storage.file.base_path is required for file backend")
+	}
+
+	// Storage validation; with sinks configured the top-level backend is unused
+	if len(config.Storage.Sinks) == 0 {
+		if err := validateStorageBackend("storage", config.Storage.Backend,
+			config.Storage.S3, config.Storage.Azure, config.Storage.GCS, config.Storage.File); err != nil {
+			return err
 		}
-	default:
-		return fmt.Errorf("unsupported storage backend: %s", config.Storage.Backend)
+	}
+	if err := validateSinks(config.Storage.Sinks); err != nil {
+		return err
+	}
+
+	if err := validateEncryption("storage.encryption", config.Storage.Encryption); err != nil {
+		return err
+	}
+
+	if len(config.Storage.Metadata.Tags) > storage.MaxStaticObjectTags {
+		return fmt.Errorf("storage.object_metadata.tags supports at most %d entries, got %d", storage.MaxStaticObjectTags, len(config.Storage.Metadata.Tags))
 	}
 
 	// Format validation
@@ -185,11 +518,59 @@ func (l *Loader) Validate(config *dto.ApplicationConfig) error {
 		return fmt.Errorf("unsupported storage format: %s", config.Storage.Format)
 	}
 
+	if err := validateTypedSchemas(config.Parquet.TypedSchemas); err != nil {
+		return err
+	}
+
 	// File rotation validation
 	if config.FileRotation.Strategy != "any" && config.FileRotation.Strategy != "all" {
 		return fmt.Errorf("unsupported rotation strategy: %s", config.FileRotation.Strategy)
 	}
 
+	// Event validation rules
+	if err := validateValidation("validation", config.Validation); err != nil {
+		return err
+	}
+	if len(config.Validation.Schema.Types) > 0 && !config.Validation.Schema.Enabled() {
+		return errors.New("validation.schema.types requires a schema directory or registry_url")
+	}
+
+	// Schema registry validation
+	if registry := config.SchemaRegistry; registry.Enabled() {
+		u, err := url.Parse(registry.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid schema_registry.url: %s", registry.URL)
+		}
+		if registry.TimeoutMS < 0 {
+			return errors.New("schema_registry.timeout_ms must be non-negative")
+		}
+		if registry.Password != "" && registry.Username == "" {
+			return errors.New("schema_registry.password requires a username")
+		}
+	}
+
+	// Redaction validation
+	if err := validateRedaction("redaction", config.Redaction); err != nil {
+		return err
+	}
+
+	// Schema evolution validation
+	if evolution := config.SchemaEvolution; evolution.Enabled {
+		switch evolution.Compatibility {
+		case "none", "backward", "forward", "full":
+		default:
+			return fmt.Errorf("unsupported schema_evolution.compatibility: %s", evolution.Compatibility)
+		}
+		if evolution.OnIncompatible != "version" && evolution.OnIncompatible != "reject" {
+			return fmt.Errorf("unsupported schema_evolution.on_incompatible: %s", evolution.OnIncompatible)
+		}
+	}
+
+	// Per-topic override validation
+	if err := validateTopics(config); err != nil {
+		return err
+	}
+
 	// Port validation
 	if config.Observability.Metrics.Port < 1 || config.Observability.Metrics.Port > 65535 {
 		return fmt.Errorf("invalid metrics port: %d", config.Observability.Metrics.Port)
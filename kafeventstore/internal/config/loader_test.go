@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/jittakal/kafeventstore/internal/config/dto"
@@ -222,6 +223,49 @@ func TestLoader_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "azure backend unsupported auth method",
+			config: &dto.ApplicationConfig{
+				Kafka: dto.KafkaConfig{
+					BootstrapServers: []string{"localhost:9092"},
+					Consumer: dto.ConsumerConfig{
+						GroupID: "test-group",
+						Topics:  []string{"test-topic"},
+					},
+				},
+				Storage: dto.StorageConfig{
+					Backend: "azure",
+					Format:  "parquet",
+					Azure: dto.AzureConfig{
+						AccountName: "testaccount",
+						Container:   "test-container",
+						AuthMethod:  "password",
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "gcs backend unsupported retry policy",
+			config: &dto.ApplicationConfig{
+				Kafka: dto.KafkaConfig{
+					BootstrapServers: []string{"localhost:9092"},
+					Consumer: dto.ConsumerConfig{
+						GroupID: "test-group",
+						Topics:  []string{"test-topic"},
+					},
+				},
+				Storage: dto.StorageConfig{
+					Backend: "gcs",
+					Format:  "parquet",
+					GCS: dto.GCSConfig{
+						Bucket: "test-bucket",
+						Retry:  dto.GCSRetryConfig{Policy: "sometimes"},
+					},
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "unsupported storage backend",
 			config: &dto.ApplicationConfig{
@@ -304,18 +348,1003 @@ func TestLoader_Validate(t *testing.T) {
 	}
 }
 
-func TestLoader_setDefaults(t *testing.T) {
-	loader := NewLoader()
-	loader.setDefaults()
+func TestLoader_ValidateSinks(t *testing.T) {
+	s3Sink := dto.SinkConfig{
+		Name:    "s3-primary",
+		Backend: "s3",
+		S3:      dto.S3Config{Bucket: "events-prod", Region: "us-east-1"},
+	}
+	fileSink := dto.SinkConfig{
+		Name:    "onprem-mirror",
+		Backend: "file",
+		Format:  "avro",
+		Policy:  "best_effort",
+		File:    dto.FileConfig{BasePath: "/mnt/events"},
+	}
 
-	// Verify some key defaults are set
-	if loader.v.GetString("application.name") != "kafka-event-store" {
-		t.Error("default application.name not set correctly")
+	tests := []struct {
+		name    string
+		sinks   []dto.SinkConfig
+		wantErr bool
+	}{
+		{name: "valid sinks", sinks: []dto.SinkConfig{s3Sink, fileSink}, wantErr: false},
+		{name: "missing name", sinks: []dto.SinkConfig{{Backend: "file", File: dto.FileConfig{BasePath: "/tmp"}}}, wantErr: true},
+		{name: "duplicate name", sinks: []dto.SinkConfig{s3Sink, s3Sink}, wantErr: true},
+		{name: "invalid backend settings", sinks: []dto.SinkConfig{{Name: "s3", Backend: "s3"}}, wantErr: true},
+		{name: "unsupported backend", sinks: []dto.SinkConfig{{Name: "hdfs", Backend: "hdfs"}}, wantErr: true},
+		{
+			name:    "unsupported format",
+			sinks:   []dto.SinkConfig{{Name: "f", Backend: "file", Format: "csv", File: dto.FileConfig{BasePath: "/tmp"}}},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported policy",
+			sinks:   []dto.SinkConfig{{Name: "f", Backend: "file", Policy: "sometimes", File: dto.FileConfig{BasePath: "/tmp"}}},
+			wantErr: true,
+		},
 	}
-	if loader.v.GetString("storage.backend") != "file" {
-		t.Error("default storage.backend not set correctly")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &dto.ApplicationConfig{
+				Kafka: dto.KafkaConfig{
+					BootstrapServers: []string{"localhost:9092"},
+					Consumer: dto.ConsumerConfig{
+						GroupID: "test-group",
+						Topics:  []string{"test-topic"},
+					},
+				},
+				// The top-level backend is not validated when sinks are configured
+				Storage: dto.StorageConfig{
+					Format: "parquet",
+					Sinks:  tt.sinks,
+				},
+				FileRotation: dto.FileRotationConfig{Strategy: "any"},
+				Observability: dto.ObservabilityConfig{
+					Metrics: dto.MetricsConfig{Port: 9090},
+					Health:  dto.HealthConfig{Port: 8080},
+				},
+			}
+
+			err := NewLoader().Validate(config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
 	}
-	if loader.v.GetString("storage.format") != "parquet" {
-		t.Error("default storage.format not set correctly")
+}
+
+func TestLoader_ValidateTopics(t *testing.T) {
+	fileStorage := dto.StorageConfig{
+		Backend: "file",
+		Format:  "parquet",
+		File:    dto.FileConfig{BasePath: "/tmp/events"},
+	}
+	s3Sinks := fileStorage
+	s3Sinks.Sinks = []dto.SinkConfig{{
+		Name:    "s3",
+		Backend: "s3",
+		S3:      dto.S3Config{Bucket: "events-prod", Region: "us-east-1"},
+	}}
+
+	tests := []struct {
+		name    string
+		storage dto.StorageConfig
+		topics  []dto.TopicConfig
+		wantErr bool
+	}{
+		{
+			name:    "valid overrides",
+			storage: fileStorage,
+			topics: []dto.TopicConfig{
+				{Name: "clickstream", Storage: dto.TopicStorageConfig{Compression: "zstd"}},
+				{
+					Pattern: `audit\..*`,
+					Storage: dto.TopicStorageConfig{
+						Backend: "s3",
+						Format:  "avro",
+						S3:      dto.S3Config{Bucket: "audit", Region: "us-east-1"},
+					},
+					FileRotation: dto.FileRotationConfig{MaxDurationSeconds: 86400, Strategy: "all"},
+				},
+			},
+			wantErr: false,
+		},
+		{name: "missing name and pattern", storage: fileStorage, topics: []dto.TopicConfig{{}}, wantErr: true},
+		{name: "name and pattern", storage: fileStorage, topics: []dto.TopicConfig{{Name: "a", Pattern: "a.*"}}, wantErr: true},
+		{name: "duplicate name", storage: fileStorage, topics: []dto.TopicConfig{{Name: "a"}, {Name: "a"}}, wantErr: true},
+		{name: "invalid pattern", storage: fileStorage, topics: []dto.TopicConfig{{Pattern: "(orders"}}, wantErr: true},
+		{
+			name:    "incomplete backend override",
+			storage: fileStorage,
+			topics:  []dto.TopicConfig{{Name: "a", Storage: dto.TopicStorageConfig{Backend: "s3"}}},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported format",
+			storage: fileStorage,
+			topics:  []dto.TopicConfig{{Name: "a", Storage: dto.TopicStorageConfig{Format: "csv"}}},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported rotation strategy",
+			storage: fileStorage,
+			topics:  []dto.TopicConfig{{Name: "a", FileRotation: dto.FileRotationConfig{Strategy: "some"}}},
+			wantErr: true,
+		},
+		{
+			name:    "storage override without backend when sinks are configured",
+			storage: s3Sinks,
+			topics:  []dto.TopicConfig{{Name: "a", Storage: dto.TopicStorageConfig{Format: "avro"}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &dto.ApplicationConfig{
+				Kafka: dto.KafkaConfig{
+					BootstrapServers: []string{"localhost:9092"},
+					Consumer: dto.ConsumerConfig{
+						GroupID: "test-group",
+						Topics:  []string{"test-topic"},
+					},
+				},
+				Storage:      tt.storage,
+				FileRotation: dto.FileRotationConfig{Strategy: "any"},
+				Observability: dto.ObservabilityConfig{
+					Metrics: dto.MetricsConfig{Port: 9090},
+					Health:  dto.HealthConfig{Port: 8080},
+				},
+				Topics: tt.topics,
+			}
+
+			err := NewLoader().Validate(config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoader_ValidateTopicPattern(t *testing.T) {
+	tests := []struct {
+		name            string
+		topics          []string
+		pattern         string
+		refreshInterval int
+		wantErr         bool
+	}{
+		{name: "topics only", topics: []string{"orders"}, wantErr: false},
+		{name: "pattern only", pattern: `.*\.events`, refreshInterval: 60, wantErr: false},
+		{name: "topics and pattern", topics: []string{"orders"}, pattern: `.*\.events`, refreshInterval: 60, wantErr: false},
+		{name: "neither", wantErr: true},
+		{name: "invalid pattern", pattern: "(orders", refreshInterval: 60, wantErr: true},
+		{name: "non-positive refresh interval", pattern: `.*\.events`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &dto.ApplicationConfig{
+				Kafka: dto.KafkaConfig{
+					BootstrapServers: []string{"localhost:9092"},
+					Consumer: dto.ConsumerConfig{
+						GroupID:                     "test-group",
+						Topics:                      tt.topics,
+						TopicPattern:                tt.pattern,
+						TopicRefreshIntervalSeconds: tt.refreshInterval,
+					},
+				},
+				Storage: dto.StorageConfig{
+					Backend: "file",
+					Format:  "parquet",
+					File:    dto.FileConfig{BasePath: "/tmp/events"},
+				},
+				FileRotation: dto.FileRotationConfig{Strategy: "any"},
+				Observability: dto.ObservabilityConfig{
+					Metrics: dto.MetricsConfig{Port: 9090},
+					Health:  dto.HealthConfig{Port: 8080},
+				},
+			}
+
+			err := NewLoader().Validate(config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoader_ValidateConsumerTuning(t *testing.T) {
+	tests := []struct {
+		name     string
+		consumer dto.ConsumerConfig
+		wantErr  bool
+	}{
+		{name: "defaults", consumer: dto.ConsumerConfig{}, wantErr: false},
+		{
+			name: "sticky with static membership",
+			consumer: dto.ConsumerConfig{
+				RebalanceStrategy: "sticky",
+				GroupInstanceID:   "event-store-0",
+				FetchMinBytes:     1024,
+				FetchMaxBytes:     1048576,
+				ChannelBufferSize: 512,
+			},
+			wantErr: false,
+		},
+		{name: "unsupported strategy", consumer: dto.ConsumerConfig{RebalanceStrategy: "cooperative"}, wantErr: true},
+		{name: "negative fetch size", consumer: dto.ConsumerConfig{FetchMinBytes: -1}, wantErr: true},
+		{name: "min above max", consumer: dto.ConsumerConfig{FetchMinBytes: 2048, FetchMaxBytes: 1024}, wantErr: true},
+		{name: "negative channel buffer", consumer: dto.ConsumerConfig{ChannelBufferSize: -1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			consumer := tt.consumer
+			consumer.GroupID = "test-group"
+			consumer.Topics = []string{"test-topic"}
+			config := &dto.ApplicationConfig{
+				Kafka: dto.KafkaConfig{
+					BootstrapServers: []string{"localhost:9092"},
+					Consumer:         consumer,
+				},
+				Storage: dto.StorageConfig{
+					Backend: "file",
+					Format:  "parquet",
+					File:    dto.FileConfig{BasePath: "/tmp/events"},
+				},
+				FileRotation: dto.FileRotationConfig{Strategy: "any"},
+				Observability: dto.ObservabilityConfig{
+					Metrics: dto.MetricsConfig{Port: 9090},
+					Health:  dto.HealthConfig{Port: 8080},
+				},
+			}
+
+			err := NewLoader().Validate(config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoader_ValidateKafkaTLS(t *testing.T) {
+	tests := []struct {
+		name    string
+		tls     dto.KafkaTLSConfig
+		wantErr bool
+	}{
+		{name: "empty", tls: dto.KafkaTLSConfig{}, wantErr: false},
+		{
+			name: "mutual TLS",
+			tls: dto.KafkaTLSConfig{
+				CAFile:     "/etc/kafka/tls/ca.crt",
+				CertFile:   "/etc/kafka/tls/tls.crt",
+				KeyFile:    "/etc/kafka/tls/tls.key",
+				MinVersion: "1.3",
+			},
+			wantErr: false,
+		},
+		{name: "cert without key", tls: dto.KafkaTLSConfig{CertFile: "/etc/kafka/tls/tls.crt"}, wantErr: true},
+		{name: "key without cert", tls: dto.KafkaTLSConfig{KeyFile: "/etc/kafka/tls/tls.key"}, wantErr: true},
+		{name: "unsupported min version", tls: dto.KafkaTLSConfig{MinVersion: "1.0"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &dto.ApplicationConfig{
+				Kafka: dto.KafkaConfig{
+					BootstrapServers: []string{"localhost:9092"},
+					TLS:              tt.tls,
+					Consumer: dto.ConsumerConfig{
+						GroupID: "test-group",
+						Topics:  []string{"test-topic"},
+					},
+				},
+				Storage: dto.StorageConfig{
+					Backend: "file",
+					Format:  "parquet",
+					File:    dto.FileConfig{BasePath: "/tmp/events"},
+				},
+				FileRotation: dto.FileRotationConfig{Strategy: "any"},
+				Observability: dto.ObservabilityConfig{
+					Metrics: dto.MetricsConfig{Port: 9090},
+					Health:  dto.HealthConfig{Port: 8080},
+				},
+			}
+
+			err := NewLoader().Validate(config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoader_ValidateKafkaOAuth(t *testing.T) {
+	tests := []struct {
+		name      string
+		mechanism string
+		oauth     dto.KafkaOAuthConfig
+		wantErr   bool
+	}{
+		{name: "plain ignores oauth", mechanism: "PLAIN", wantErr: false},
+		{
+			name:      "oauthbearer",
+			mechanism: "OAUTHBEARER",
+			oauth: dto.KafkaOAuthConfig{
+				TokenURL:   "https://idp.example.com/oauth2/token",
+				ClientID:   "event-store",
+				Extensions: []dto.SASLExtension{{Key: "logicalCluster", Value: "lkc-123"}},
+			},
+			wantErr: false,
+		},
+		{name: "missing token url", mechanism: "OAUTHBEARER", oauth: dto.KafkaOAuthConfig{ClientID: "event-store"}, wantErr: true},
+		{name: "missing client id", mechanism: "OAUTHBEARER", oauth: dto.KafkaOAuthConfig{TokenURL: "https://idp.example.com/oauth2/token"}, wantErr: true},
+		{
+			name:      "extension without key",
+			mechanism: "OAUTHBEARER",
+			oauth: dto.KafkaOAuthConfig{
+				TokenURL:   "https://idp.example.com/oauth2/token",
+				ClientID:   "event-store",
+				Extensions: []dto.SASLExtension{{Value: "lkc-123"}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &dto.ApplicationConfig{
+				Kafka: dto.KafkaConfig{
+					BootstrapServers: []string{"localhost:9092"},
+					SASLMechanism:    tt.mechanism,
+					OAuth:            tt.oauth,
+					Consumer: dto.ConsumerConfig{
+						GroupID: "test-group",
+						Topics:  []string{"test-topic"},
+					},
+				},
+				Storage: dto.StorageConfig{
+					Backend: "file",
+					Format:  "parquet",
+					File:    dto.FileConfig{BasePath: "/tmp/events"},
+				},
+				FileRotation: dto.FileRotationConfig{Strategy: "any"},
+				Observability: dto.ObservabilityConfig{
+					Metrics: dto.MetricsConfig{Port: 9090},
+					Health:  dto.HealthConfig{Port: 8080},
+				},
+			}
+
+			err := NewLoader().Validate(config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoader_ValidateDLQRetries(t *testing.T) {
+	tests := []struct {
+		name    string
+		dlq     dto.DLQConfig
+		wantErr bool
+	}{
+		{name: "no retries", dlq: dto.DLQConfig{Enabled: true, TopicSuffix: "-dlq"}, wantErr: false},
+		{
+			name:    "retries",
+			dlq:     dto.DLQConfig{Enabled: true, TopicSuffix: "-dlq", MaxRetries: 3, RetryTopicSuffix: "-retry", RetryBackoffMS: 30000},
+			wantErr: false,
+		},
+		{name: "negative max retries", dlq: dto.DLQConfig{MaxRetries: -1}, wantErr: true},
+		{name: "negative backoff", dlq: dto.DLQConfig{RetryBackoffMS: -1}, wantErr: true},
+		{name: "missing retry suffix", dlq: dto.DLQConfig{TopicSuffix: "-dlq", MaxRetries: 3}, wantErr: true},
+		{
+			name:    "retry suffix equals dlq suffix",
+			dlq:     dto.DLQConfig{TopicSuffix: "-dlq", MaxRetries: 3, RetryTopicSuffix: "-dlq"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &dto.ApplicationConfig{
+				Kafka: dto.KafkaConfig{
+					BootstrapServers: []string{"localhost:9092"},
+					DLQ:              tt.dlq,
+					Consumer: dto.ConsumerConfig{
+						GroupID: "test-group",
+						Topics:  []string{"test-topic"},
+					},
+				},
+				Storage: dto.StorageConfig{
+					Backend: "file",
+					Format:  "parquet",
+					File:    dto.FileConfig{BasePath: "/tmp/events"},
+				},
+				FileRotation: dto.FileRotationConfig{Strategy: "any"},
+				Observability: dto.ObservabilityConfig{
+					Metrics: dto.MetricsConfig{Port: 9090},
+					Health:  dto.HealthConfig{Port: 8080},
+				},
+			}
+
+			err := NewLoader().Validate(config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoader_ValidateDLQAutoCreate(t *testing.T) {
+	tests := []struct {
+		name    string
+		dlq     dto.DLQConfig
+		wantErr bool
+	}{
+		{name: "disabled", dlq: dto.DLQConfig{TopicSuffix: "-dlq"}, wantErr: false},
+		{
+			name:    "enabled",
+			dlq:     dto.DLQConfig{TopicSuffix: "-dlq", AutoCreateTopics: true, TopicPartitions: 3, TopicReplicationFactor: 3, TopicRetentionMS: 604800000},
+			wantErr: false,
+		},
+		{
+			name:    "infinite retention",
+			dlq:     dto.DLQConfig{TopicSuffix: "-dlq", AutoCreateTopics: true, TopicPartitions: 1, TopicReplicationFactor: 1, TopicRetentionMS: -1},
+			wantErr: false,
+		},
+		{name: "no partitions", dlq: dto.DLQConfig{TopicSuffix: "-dlq", AutoCreateTopics: true, TopicReplicationFactor: 3}, wantErr: true},
+		{name: "no replication", dlq: dto.DLQConfig{TopicSuffix: "-dlq", AutoCreateTopics: true, TopicPartitions: 1}, wantErr: true},
+		{
+			name:    "invalid retention",
+			dlq:     dto.DLQConfig{TopicSuffix: "-dlq", AutoCreateTopics: true, TopicPartitions: 1, TopicReplicationFactor: 1, TopicRetentionMS: -2},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &dto.ApplicationConfig{
+				Kafka: dto.KafkaConfig{
+					BootstrapServers: []string{"localhost:9092"},
+					DLQ:              tt.dlq,
+					Consumer: dto.ConsumerConfig{
+						GroupID: "test-group",
+						Topics:  []string{"test-topic"},
+					},
+				},
+				Storage: dto.StorageConfig{
+					Backend: "file",
+					Format:  "parquet",
+					File:    dto.FileConfig{BasePath: "/tmp/events"},
+				},
+				FileRotation: dto.FileRotationConfig{Strategy: "any"},
+				Observability: dto.ObservabilityConfig{
+					Metrics: dto.MetricsConfig{Port: 9090},
+					Health:  dto.HealthConfig{Port: 8080},
+				},
+			}
+
+			err := NewLoader().Validate(config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoader_ValidateValidationRules(t *testing.T) {
+	tests := []struct {
+		name       string
+		validation dto.ValidationConfig
+		topic      dto.ValidationConfig
+		wantErr    bool
+	}{
+		{name: "no rules", wantErr: false},
+		{
+			name: "rules",
+			validation: dto.ValidationConfig{
+				AllowedSources:       []string{"/billing/*"},
+				DeniedTypes:          []string{"debug.*"},
+				RequiredExtensions:   []string{"tenant"},
+				MaxDataBytes:         1048576,
+				MaxPastSkewSeconds:   86400,
+				MaxFutureSkewSeconds: 300,
+			},
+			wantErr: false,
+		},
+		{name: "negative data size", validation: dto.ValidationConfig{MaxDataBytes: -1}, wantErr: true},
+		{name: "negative skew", validation: dto.ValidationConfig{MaxPastSkewSeconds: -1}, wantErr: true},
+		{name: "empty source", validation: dto.ValidationConfig{AllowedSources: []string{""}}, wantErr: true},
+		{name: "blank extension", validation: dto.ValidationConfig{RequiredExtensions: []string{" "}}, wantErr: true},
+		{name: "topic negative skew", topic: dto.ValidationConfig{MaxFutureSkewSeconds: -5}, wantErr: true},
+		{name: "topic empty type", topic: dto.ValidationConfig{DeniedTypes: []string{""}}, wantErr: true},
+		{name: "topic clears list", topic: dto.ValidationConfig{AllowedSources: []string{}}, wantErr: false},
+		{
+			name: "schema directory",
+			validation: dto.ValidationConfig{Schema: dto.SchemaValidationConfig{
+				Directory: "/etc/schemas",
+				Types:     []dto.SchemaTypeMapping{{Type: "order.created", Schema: "orders/v1.json"}},
+			}},
+			wantErr: false,
+		},
+		{
+			name:       "schema registry",
+			validation: dto.ValidationConfig{Schema: dto.SchemaValidationConfig{RegistryURL: "https://schemas.example.com/", TimeoutMS: 5000}},
+			wantErr:    false,
+		},
+		{
+			name: "schema directory and registry",
+			validation: dto.ValidationConfig{Schema: dto.SchemaValidationConfig{
+				Directory:   "/etc/schemas",
+				RegistryURL: "https://schemas.example.com/",
+			}},
+			wantErr: true,
+		},
+		{name: "invalid registry URL", validation: dto.ValidationConfig{Schema: dto.SchemaValidationConfig{RegistryURL: "schemas.example.com"}}, wantErr: true},
+		{name: "negative registry timeout", validation: dto.ValidationConfig{Schema: dto.SchemaValidationConfig{RegistryURL: "http://registry", TimeoutMS: -1}}, wantErr: true},
+		{
+			name:       "schema types without source",
+			validation: dto.ValidationConfig{Schema: dto.SchemaValidationConfig{Types: []dto.SchemaTypeMapping{{Type: "a", Schema: "a.json"}}}},
+			wantErr:    true,
+		},
+		{
+			name: "incomplete schema type",
+			validation: dto.ValidationConfig{Schema: dto.SchemaValidationConfig{
+				Directory: "/etc/schemas",
+				Types:     []dto.SchemaTypeMapping{{Type: "a"}},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "duplicate schema type",
+			validation: dto.ValidationConfig{Schema: dto.SchemaValidationConfig{
+				Directory: "/etc/schemas",
+				Types:     []dto.SchemaTypeMapping{{Type: "a", Schema: "a.json"}, {Type: "a", Schema: "b.json"}},
+			}},
+			wantErr: true,
+		},
+		{
+			name:       "topic schema types inherit source",
+			validation: dto.ValidationConfig{Schema: dto.SchemaValidationConfig{Directory: "/etc/schemas"}},
+			topic:      dto.ValidationConfig{Schema: dto.SchemaValidationConfig{Types: []dto.SchemaTypeMapping{{Type: "a", Schema: "a.json"}}}},
+			wantErr:    false,
+		},
+		{
+			name:    "topic schema types without source",
+			topic:   dto.ValidationConfig{Schema: dto.SchemaValidationConfig{Types: []dto.SchemaTypeMapping{{Type: "a", Schema: "a.json"}}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &dto.ApplicationConfig{
+				Kafka: dto.KafkaConfig{
+					BootstrapServers: []string{"localhost:9092"},
+					Consumer: dto.ConsumerConfig{
+						GroupID: "test-group",
+						Topics:  []string{"test-topic"},
+					},
+				},
+				Storage: dto.StorageConfig{
+					Backend: "file",
+					Format:  "parquet",
+					File:    dto.FileConfig{BasePath: "/tmp/events"},
+				},
+				FileRotation: dto.FileRotationConfig{Strategy: "any"},
+				Validation:   tt.validation,
+				Topics:       []dto.TopicConfig{{Name: "test-topic", Validation: tt.topic}},
+				Observability: dto.ObservabilityConfig{
+					Metrics: dto.MetricsConfig{Port: 9090},
+					Health:  dto.HealthConfig{Port: 8080},
+				},
+			}
+
+			err := NewLoader().Validate(config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoader_ValidateSchemaRegistry(t *testing.T) {
+	tests := []struct {
+		name     string
+		registry dto.SchemaRegistryConfig
+		wantErr  bool
+	}{
+		{name: "disabled", wantErr: false},
+		{name: "registry", registry: dto.SchemaRegistryConfig{URL: "http://schema-registry:8081", TimeoutMS: 5000}, wantErr: false},
+		{name: "basic auth", registry: dto.SchemaRegistryConfig{URL: "https://psrc.example.com", Username: "key", Password: "secret"}, wantErr: false},
+		{name: "missing scheme", registry: dto.SchemaRegistryConfig{URL: "schema-registry:8081"}, wantErr: true},
+		{name: "unsupported scheme", registry: dto.SchemaRegistryConfig{URL: "ftp://schema-registry"}, wantErr: true},
+		{name: "negative timeout", registry: dto.SchemaRegistryConfig{URL: "http://schema-registry:8081", TimeoutMS: -1}, wantErr: true},
+		{name: "password without username", registry: dto.SchemaRegistryConfig{URL: "http://schema-registry:8081", Password: "secret"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &dto.ApplicationConfig{
+				Kafka: dto.KafkaConfig{
+					BootstrapServers: []string{"localhost:9092"},
+					Consumer: dto.ConsumerConfig{
+						GroupID: "test-group",
+						Topics:  []string{"test-topic"},
+					},
+				},
+				Storage: dto.StorageConfig{
+					Backend: "file",
+					Format:  "parquet",
+					File:    dto.FileConfig{BasePath: "/tmp/events"},
+				},
+				FileRotation:   dto.FileRotationConfig{Strategy: "any"},
+				SchemaRegistry: tt.registry,
+				Observability: dto.ObservabilityConfig{
+					Metrics: dto.MetricsConfig{Port: 9090},
+					Health:  dto.HealthConfig{Port: 8080},
+				},
+			}
+
+			err := NewLoader().Validate(config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoader_ValidateRedaction(t *testing.T) {
+	tests := []struct {
+		name      string
+		redaction dto.RedactionConfig
+		topics    []dto.TopicConfig
+		wantErr   bool
+	}{
+		{name: "none", wantErr: false},
+		{
+			name: "all actions",
+			redaction: dto.RedactionConfig{Salt: "pepper", Rules: []dto.RedactionRuleConfig{
+				{Type: "com.library.*", Path: "$.memberEmail", Action: "sha256"},
+				{Path: "$.memberId", Action: "tokenize"},
+				{Path: "$.memberName", Action: "drop"},
+				{Path: "$.postcode", Action: "truncate", Length: 3},
+				{Path: "$.phone", Action: "mask", Pattern: `\d(\d{2})$`, Replacement: "*$1"},
+			}},
+			wantErr: false,
+		},
+		{name: "missing path", redaction: dto.RedactionConfig{Rules: []dto.RedactionRuleConfig{{Action: "drop"}}}, wantErr: true},
+		{name: "unsupported action", redaction: dto.RedactionConfig{Rules: []dto.RedactionRuleConfig{{Path: "$.a", Action: "encrypt"}}}, wantErr: true},
+		{name: "sha256 without salt", redaction: dto.RedactionConfig{Rules: []dto.RedactionRuleConfig{{Path: "$.a", Action: "sha256"}}}, wantErr: true},
+		{name: "negative length", redaction: dto.RedactionConfig{Rules: []dto.RedactionRuleConfig{{Path: "$.a", Action: "truncate", Length: -1}}}, wantErr: true},
+		{name: "mask without pattern", redaction: dto.RedactionConfig{Rules: []dto.RedactionRuleConfig{{Path: "$.a", Action: "mask"}}}, wantErr: true},
+		{name: "invalid mask pattern", redaction: dto.RedactionConfig{Rules: []dto.RedactionRuleConfig{{Path: "$.a", Action: "mask", Pattern: "("}}}, wantErr: true},
+		{
+			name:      "topic rules inherit the salt",
+			redaction: dto.RedactionConfig{Salt: "pepper"},
+			topics: []dto.TopicConfig{{Name: "library-events", Redaction: dto.RedactionConfig{
+				Rules: []dto.RedactionRuleConfig{{Path: "$.memberEmail", Action: "tokenize"}},
+			}}},
+			wantErr: false,
+		},
+		{
+			name: "topic rules without salt",
+			topics: []dto.TopicConfig{{Name: "library-events", Redaction: dto.RedactionConfig{
+				Rules: []dto.RedactionRuleConfig{{Path: "$.memberEmail", Action: "tokenize"}},
+			}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &dto.ApplicationConfig{
+				Kafka: dto.KafkaConfig{
+					BootstrapServers: []string{"localhost:9092"},
+					Consumer: dto.ConsumerConfig{
+						GroupID: "test-group",
+						Topics:  []string{"test-topic"},
+					},
+				},
+				Storage: dto.StorageConfig{
+					Backend: "file",
+					Format:  "parquet",
+					File:    dto.FileConfig{BasePath: "/tmp/events"},
+				},
+				FileRotation: dto.FileRotationConfig{Strategy: "any"},
+				Redaction:    tt.redaction,
+				Topics:       tt.topics,
+				Observability: dto.ObservabilityConfig{
+					Metrics: dto.MetricsConfig{Port: 9090},
+					Health:  dto.HealthConfig{Port: 8080},
+				},
+			}
+
+			err := NewLoader().Validate(config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoader_ValidateEncryption(t *testing.T) {
+	enabled := true
+	tests := []struct {
+		name       string
+		encryption dto.EncryptionConfig
+		topics     []dto.TopicConfig
+		wantErr    bool
+	}{
+		{name: "disabled", wantErr: false},
+		{name: "keyfile", encryption: dto.EncryptionConfig{Enabled: &enabled, Provider: "keyfile", KeyFile: "/etc/kafeventstore/kek"}, wantErr: false},
+		{name: "missing key file", encryption: dto.EncryptionConfig{Enabled: &enabled, Provider: "keyfile"}, wantErr: true},
+		{name: "unsupported provider", encryption: dto.EncryptionConfig{Enabled: &enabled, Provider: "vault", KeyFile: "/etc/kafeventstore/kek"}, wantErr: true},
+		{
+			name:       "topic enables encryption with the global key file",
+			encryption: dto.EncryptionConfig{Provider: "keyfile", KeyFile: "/etc/kafeventstore/kek"},
+			topics: []dto.TopicConfig{{Name: "member-events", Storage: dto.TopicStorageConfig{
+				Encryption: dto.EncryptionConfig{Enabled: &enabled},
+			}}},
+			wantErr: false,
+		},
+		{
+			name:       "topic enables encryption without a key file",
+			encryption: dto.EncryptionConfig{Provider: "keyfile"},
+			topics: []dto.TopicConfig{{Name: "member-events", Storage: dto.TopicStorageConfig{
+				Encryption: dto.EncryptionConfig{Enabled: &enabled},
+			}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &dto.ApplicationConfig{
+				Kafka: dto.KafkaConfig{
+					BootstrapServers: []string{"localhost:9092"},
+					Consumer: dto.ConsumerConfig{
+						GroupID: "test-group",
+						Topics:  []string{"test-topic"},
+					},
+				},
+				Storage: dto.StorageConfig{
+					Backend:    "file",
+					Format:     "parquet",
+					File:       dto.FileConfig{BasePath: "/tmp/events"},
+					Encryption: tt.encryption,
+				},
+				FileRotation: dto.FileRotationConfig{Strategy: "any"},
+				Topics:       tt.topics,
+				Observability: dto.ObservabilityConfig{
+					Metrics: dto.MetricsConfig{Port: 9090},
+					Health:  dto.HealthConfig{Port: 8080},
+				},
+			}
+
+			err := NewLoader().Validate(config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoader_ValidateSchemaEvolution(t *testing.T) {
+	tests := []struct {
+		name      string
+		evolution dto.SchemaEvolutionConfig
+		wantErr   bool
+	}{
+		{name: "disabled", wantErr: false},
+		{name: "backward versioning", evolution: dto.SchemaEvolutionConfig{Enabled: true, Compatibility: "backward", OnIncompatible: "version"}, wantErr: false},
+		{name: "full rejecting", evolution: dto.SchemaEvolutionConfig{Enabled: true, Compatibility: "full", OnIncompatible: "reject"}, wantErr: false},
+		{name: "unsupported compatibility", evolution: dto.SchemaEvolutionConfig{Enabled: true, Compatibility: "transitive", OnIncompatible: "version"}, wantErr: true},
+		{name: "unsupported action", evolution: dto.SchemaEvolutionConfig{Enabled: true, Compatibility: "backward", OnIncompatible: "drop"}, wantErr: true},
+		{name: "disabled with invalid settings", evolution: dto.SchemaEvolutionConfig{Compatibility: "transitive"}, wantErr: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &dto.ApplicationConfig{
+				Kafka: dto.KafkaConfig{
+					BootstrapServers: []string{"localhost:9092"},
+					Consumer: dto.ConsumerConfig{
+						GroupID: "test-group",
+						Topics:  []string{"test-topic"},
+					},
+				},
+				Storage: dto.StorageConfig{
+					Backend: "file",
+					Format:  "parquet",
+					File:    dto.FileConfig{BasePath: "/tmp/events"},
+				},
+				FileRotation:    dto.FileRotationConfig{Strategy: "any"},
+				SchemaEvolution: tt.evolution,
+				Observability: dto.ObservabilityConfig{
+					Metrics: dto.MetricsConfig{Port: 9090},
+					Health:  dto.HealthConfig{Port: 8080},
+				},
+			}
+
+			err := NewLoader().Validate(config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoader_ValidateTypedSchemas(t *testing.T) {
+	tests := []struct {
+		name    string
+		schemas []dto.ParquetTypedSchemaConfig
+		wantErr bool
+	}{
+		{name: "none", wantErr: false},
+		{
+			name: "json schema and avro",
+			schemas: []dto.ParquetTypedSchemaConfig{
+				{Type: "com.library.books.issued", Schema: "schemas/issued.json"},
+				{Type: "com.library.books.returned", Schema: "schemas/returned.avsc", Format: "avro"},
+			},
+			wantErr: false,
+		},
+		{name: "missing type", schemas: []dto.ParquetTypedSchemaConfig{{Schema: "schemas/issued.json"}}, wantErr: true},
+		{name: "missing schema", schemas: []dto.ParquetTypedSchemaConfig{{Type: "com.library.books.issued"}}, wantErr: true},
+		{
+			name:    "unsupported format",
+			schemas: []dto.ParquetTypedSchemaConfig{{Type: "com.library.books.issued", Schema: "issued.proto", Format: "protobuf"}},
+			wantErr: true,
+		},
+		{
+			name: "duplicate type",
+			schemas: []dto.ParquetTypedSchemaConfig{
+				{Type: "com.library.books.issued", Schema: "schemas/issued.json"},
+				{Type: "com.library.books.issued", Schema: "schemas/issued.avsc"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &dto.ApplicationConfig{
+				Kafka: dto.KafkaConfig{
+					BootstrapServers: []string{"localhost:9092"},
+					Consumer: dto.ConsumerConfig{
+						GroupID: "test-group",
+						Topics:  []string{"test-topic"},
+					},
+				},
+				Storage: dto.StorageConfig{
+					Backend: "file",
+					Format:  "parquet",
+					File:    dto.FileConfig{BasePath: "/tmp/events"},
+				},
+				Parquet:      dto.ParquetConfig{TypedSchemas: tt.schemas},
+				FileRotation: dto.FileRotationConfig{Strategy: "any"},
+				Observability: dto.ObservabilityConfig{
+					Metrics: dto.MetricsConfig{Port: 9090},
+					Health:  dto.HealthConfig{Port: 8080},
+				},
+			}
+
+			err := NewLoader().Validate(config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoader_LoadTopics(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	configContent := `
+kafka:
+  bootstrap_servers:
+    - localhost:9092
+  consumer:
+    group_id: test-group
+    topics:
+      - clickstream.events
+      - audit.events
+
+storage:
+  backend: file
+  format: parquet
+  file:
+    base_path: /tmp/test
+
+validation:
+  allowed_sources:
+    - /web/*
+  max_data_bytes: 65536
+
+topics:
+  - name: clickstream.events
+    validation:
+      required_extensions:
+        - sessionid
+    storage:
+      compression: zstd
+    file_rotation:
+      max_duration_seconds: 300
+  - pattern: "audit\\..*"
+    validation:
+      enabled: false
+    dlq:
+      enabled: true
+      topic_suffix: .audit-dlq
+`
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("failed to create test config file: %v", err)
+	}
+
+	config, err := NewLoader().Load(configFile)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(config.Topics) != 2 {
+		t.Fatalf("expected 2 topic overrides, got %d", len(config.Topics))
+	}
+
+	clickstream := config.Topics[0]
+	if clickstream.Name != "clickstream.events" || clickstream.Storage.Compression != "zstd" {
+		t.Errorf("unexpected clickstream override: %+v", clickstream)
+	}
+	if clickstream.FileRotation.MaxDurationSeconds != 300 {
+		t.Errorf("expected clickstream max_duration_seconds 300, got %d", clickstream.FileRotation.MaxDurationSeconds)
+	}
+
+	validation := clickstream.ValidationFor(config.Validation)
+	if !reflect.DeepEqual(validation.AllowedSources, []string{"/web/*"}) || validation.MaxDataBytes != 65536 {
+		t.Errorf("unexpected clickstream validation: %+v", validation)
+	}
+	if !reflect.DeepEqual(validation.RequiredExtensions, []string{"sessionid"}) {
+		t.Errorf("expected clickstream required_extensions [sessionid], got %v", validation.RequiredExtensions)
+	}
+
+	audit := config.Topics[1]
+	if audit.ValidationEnabled() {
+		t.Error("expected validation disabled for audit topics")
+	}
+	if audit.DLQ.Enabled == nil || !*audit.DLQ.Enabled || audit.DLQ.TopicSuffix != ".audit-dlq" {
+		t.Errorf("unexpected audit DLQ override: %+v", audit.DLQ)
+	}
+	if got := config.MatchTopic("audit.events"); got != 1 {
+		t.Errorf("MatchTopic(audit.events) = %d, want 1", got)
+	}
+}
+
+func TestLoader_setDefaults(t *testing.T) {
+	loader := NewLoader()
+	loader.setDefaults()
+
+	// Verify some key defaults are set
+	if loader.v.GetString("application.name") != "kafka-event-store" {
+		t.Error("default application.name not set correctly")
+	}
+	if loader.v.GetString("storage.backend") != "file" {
+		t.Error("default storage.backend not set correctly")
+	}
+	if loader.v.GetString("storage.format") != "parquet" {
+		t.Error("default storage.format not set correctly")
+	}
+	if loader.v.GetInt("schema_registry.timeout_ms") != 5000 {
+		t.Error("default schema_registry.timeout_ms not set correctly")
+	}
+	if loader.v.GetString("schema_evolution.compatibility") != "backward" {
+		t.Error("default schema_evolution.compatibility not set correctly")
+	}
+	if loader.v.GetString("schema_evolution.on_incompatible") != "version" {
+		t.Error("default schema_evolution.on_incompatible not set correctly")
+	}
+	if loader.v.GetBool("storage.object_metadata.enabled") {
+		t.Error("storage.object_metadata.enabled should default to false")
 	}
 }
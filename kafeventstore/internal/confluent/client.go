@@ -0,0 +1,204 @@
+// Package confluent implements a client of the Schema Registry REST API.
+package confluent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Schema types of the Schema Registry. An empty type is Avro.
+const (
+	SchemaTypeAvro     = "AVRO"
+	SchemaTypeProtobuf = "PROTOBUF"
+	SchemaTypeJSON     = "JSON"
+)
+
+// maxResponseBytes limits the size of Schema Registry responses.
+const maxResponseBytes = 8 << 20
+
+// Schema is a schema registered in the Schema Registry.
+type Schema struct {
+	ID         int         `json:"id,omitempty"`
+	Subject    string      `json:"subject,omitempty"`
+	Version    int         `json:"version,omitempty"`
+	SchemaType string      `json:"schemaType,omitempty"`
+	Schema     string      `json:"schema"`
+	References []Reference `json:"references,omitempty"`
+}
+
+// Type returns the schema type, defaulting to Avro.
+func (s *Schema) Type() string {
+	if s.SchemaType == "" {
+		return SchemaTypeAvro
+	}
+	return s.SchemaType
+}
+
+// Reference is a schema imported by another schema.
+type Reference struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+// RegistryError is a Schema Registry request that failed without a schema
+// to parse.
+type RegistryError struct {
+	Path       string
+	StatusCode int    // zero when no response was received
+	Message    string // the response body
+	Err        error  // set when no response was received
+}
+
+func (e *RegistryError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("failed to fetch schema %s: %v", e.Path, e.Err)
+	}
+	return fmt.Sprintf("failed to fetch schema %s: status %d: %s", e.Path, e.StatusCode, e.Message)
+}
+
+func (e *RegistryError) Unwrap() error {
+	return e.Err
+}
+
+// IsRetryable reports whether the request may succeed when repeated: the
+// registry was unreachable, timed out, throttled or failed itself. Unknown
+// schemas and rejected credentials are not retryable.
+func (e *RegistryError) IsRetryable() bool {
+	return e.StatusCode == 0 ||
+		e.StatusCode == http.StatusRequestTimeout ||
+		e.StatusCode == http.StatusTooManyRequests ||
+		e.StatusCode >= http.StatusInternalServerError
+}
+
+// IsUnavailable reports whether err is a retryable failure to reach the
+// Schema Registry, rather than a payload or schema that cannot be decoded.
+func IsUnavailable(err error) bool {
+	var registryErr *RegistryError
+	return errors.As(err, &registryErr) && registryErr.IsRetryable()
+}
+
+// ClientConfig configures the Schema Registry client.
+type ClientConfig struct {
+	URL      string
+	Username string // basic auth, optional
+	Password string
+	Timeout  time.Duration
+}
+
+// Client fetches schemas from a Schema Registry compatible REST API.
+// Schemas are immutable, so they are cached without expiry. It is safe for
+// concurrent use.
+type Client struct {
+	base     *url.URL
+	username string
+	password string
+	client   *http.Client
+
+	mu      sync.Mutex
+	schemas map[string]*Schema
+}
+
+// NewClient creates a Schema Registry client.
+func NewClient(config ClientConfig) (*Client, error) {
+	base, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema registry URL: %w", err)
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported schema registry URL scheme: %s", base.Scheme)
+	}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+	return &Client{
+		base:     base,
+		username: config.Username,
+		password: config.Password,
+		client:   &http.Client{Timeout: config.Timeout},
+		schemas:  make(map[string]*Schema),
+	}, nil
+}
+
+// SchemaByID returns the schema registered with id. Serialized Protobuf
+// schemas are base64-encoded FileDescriptorProtos instead of .proto text.
+func (c *Client) SchemaByID(ctx context.Context, id int, serialized bool) (*Schema, error) {
+	schema, err := c.get(ctx, "schemas/ids/"+strconv.Itoa(id), serialized)
+	if err != nil {
+		return nil, err
+	}
+	// The response has no ID; copy the cached schema to set it
+	withID := *schema
+	withID.ID = id
+	return &withID, nil
+}
+
+// SchemaByVersion returns a version of the schema of subject.
+func (c *Client) SchemaByVersion(ctx context.Context, subject string, version int, serialized bool) (*Schema, error) {
+	v := "latest"
+	if version > 0 {
+		v = strconv.Itoa(version)
+	}
+	return c.get(ctx, "subjects/"+url.PathEscape(subject)+"/versions/"+v, serialized)
+}
+
+// get fetches and caches the schema at path. Latest versions are not cached.
+func (c *Client) get(ctx context.Context, path string, serialized bool) (*Schema, error) {
+	u := c.base.JoinPath(path)
+	if serialized {
+		u.RawQuery = "format=serialized"
+	}
+	key := u.String()
+	cacheable := !strings.HasSuffix(path, "/latest")
+
+	c.mu.Lock()
+	schema, ok := c.schemas[key]
+	c.mu.Unlock()
+	if ok {
+		return schema, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema registry request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json, application/json")
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, &RegistryError{Path: path, Err: err}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, &RegistryError{Path: path, Err: fmt.Errorf("failed to read response: %w", err)}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &RegistryError{Path: path, StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+	}
+
+	schema = &Schema{}
+	if err := json.Unmarshal(body, schema); err != nil {
+		return nil, fmt.Errorf("failed to parse schema %s: %w", path, err)
+	}
+
+	if cacheable {
+		c.mu.Lock()
+		c.schemas[key] = schema
+		c.mu.Unlock()
+	}
+	return schema, nil
+}
@@ -0,0 +1,179 @@
+package confluent
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewClient(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{url: "http://registry:8081"},
+		{url: "https://registry.example.com/api/"},
+		{url: "ftp://registry", wantErr: true},
+		{url: "registry:8081", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			_, err := NewClient(ClientConfig{URL: tt.url})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClient_SchemaByID(t *testing.T) {
+	registry := NewMockRegistry()
+	id := registry.Register("orders-value", Schema{Schema: `"string"`})
+	server := httptest.NewServer(registry)
+	defer server.Close()
+
+	client, err := NewClient(ClientConfig{URL: server.URL, Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		schema, err := client.SchemaByID(context.Background(), id, false)
+		if err != nil {
+			t.Fatalf("SchemaByID() error = %v", err)
+		}
+		if schema.ID != id || schema.Schema != `"string"` || schema.Type() != SchemaTypeAvro {
+			t.Errorf("SchemaByID() = %+v", schema)
+		}
+	}
+	if got := registry.Requests(); got != 1 {
+		t.Errorf("requests = %d, want 1 (cached)", got)
+	}
+
+	// Serialized schemas are cached apart
+	if _, err := client.SchemaByID(context.Background(), id, true); err != nil {
+		t.Fatalf("SchemaByID(serialized) error = %v", err)
+	}
+	if got := registry.Requests(); got != 2 {
+		t.Errorf("requests = %d, want 2", got)
+	}
+
+	// Failures are not cached
+	for i := 0; i < 2; i++ {
+		if _, err := client.SchemaByID(context.Background(), 99, false); err == nil {
+			t.Error("SchemaByID(99) error = nil, want error")
+		}
+	}
+	if got := registry.Requests(); got != 4 {
+		t.Errorf("requests = %d, want 4", got)
+	}
+}
+
+func TestClient_SchemaByVersion(t *testing.T) {
+	registry := NewMockRegistry()
+	registry.Register("orders-value", Schema{Schema: `"string"`})
+	registry.Register("orders-value", Schema{Schema: `"long"`})
+	server := httptest.NewServer(registry)
+	defer server.Close()
+
+	client, err := NewClient(ClientConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	schema, err := client.SchemaByVersion(context.Background(), "orders-value", 1, false)
+	if err != nil || schema.Schema != `"string"` || schema.Version != 1 {
+		t.Errorf("SchemaByVersion(1) = %+v, %v", schema, err)
+	}
+	schema, err = client.SchemaByVersion(context.Background(), "orders-value", 0, false)
+	if err != nil || schema.Schema != `"long"` || schema.ID != 2 {
+		t.Errorf("SchemaByVersion(latest) = %+v, %v", schema, err)
+	}
+
+	// Latest versions are fetched every time
+	before := registry.Requests()
+	if _, err := client.SchemaByVersion(context.Background(), "orders-value", 0, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := registry.Requests(); got != before+1 {
+		t.Errorf("requests = %d, want %d", got, before+1)
+	}
+}
+
+func TestClient_Request(t *testing.T) {
+	var gotQuery, gotUser, gotPassword string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.RawQuery
+		gotUser, gotPassword, _ = r.BasicAuth()
+		if r.URL.Path != "/api/schemas/ids/1" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"schemaType":"PROTOBUF","schema":"CgA="}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(ClientConfig{URL: server.URL + "/api", Username: "key", Password: "secret"})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	schema, err := client.SchemaByID(context.Background(), 1, true)
+	if err != nil {
+		t.Fatalf("SchemaByID() error = %v", err)
+	}
+	if schema.Type() != SchemaTypeProtobuf || gotQuery != "format=serialized" || gotUser != "key" || gotPassword != "secret" {
+		t.Errorf("SchemaByID() = %+v, query %q, auth %q:%q", schema, gotQuery, gotUser, gotPassword)
+	}
+}
+
+func TestIsUnavailable(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		want   bool
+	}{
+		{"unknown schema", http.StatusNotFound, false},
+		{"unauthorized", http.StatusUnauthorized, false},
+		{"throttled", http.StatusTooManyRequests, true},
+		{"unavailable", http.StatusServiceUnavailable, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "failed", tt.status)
+			}))
+			defer server.Close()
+
+			client, err := NewClient(ClientConfig{URL: server.URL})
+			if err != nil {
+				t.Fatalf("NewClient() error = %v", err)
+			}
+			_, err = NewDecoder(client).Decode(context.Background(), Frame(1, []byte{0}))
+			if err == nil {
+				t.Fatal("Decode() error = nil, want error")
+			}
+			if got := IsUnavailable(err); got != tt.want {
+				t.Errorf("IsUnavailable(%v) = %v, want %v", err, got, tt.want)
+			}
+		})
+	}
+
+	// An unreachable registry is unavailable
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	client, err := NewClient(ClientConfig{URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if _, err := client.SchemaByID(context.Background(), 1, false); !IsUnavailable(err) {
+		t.Errorf("IsUnavailable(%v) = false, want true", err)
+	}
+	if IsUnavailable(errors.New("payload is not valid JSON")) {
+		t.Error("IsUnavailable() of a decoding error = true, want false")
+	}
+}
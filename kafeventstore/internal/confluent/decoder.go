@@ -0,0 +1,278 @@
+// Package confluent implements decoding of wire format payloads to JSON.
+package confluent
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/linkedin/goavro/v2"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	// Well-known types that Protobuf schemas import without references
+	_ "google.golang.org/protobuf/types/known/anypb"
+	_ "google.golang.org/protobuf/types/known/durationpb"
+	_ "google.golang.org/protobuf/types/known/emptypb"
+	_ "google.golang.org/protobuf/types/known/structpb"
+	_ "google.golang.org/protobuf/types/known/timestamppb"
+	_ "google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/jittakal/kafeventstore/pkg/event"
+)
+
+// ExtensionSchemaID is the CloudEvent extension holding the schema ID of
+// decoded data.
+const ExtensionSchemaID = "schemaid"
+
+// Decoded is a wire format payload decoded to JSON.
+type Decoded struct {
+	SchemaID   int
+	SchemaType string
+	JSON       json.RawMessage
+}
+
+// compiledSchema is a schema ready to decode payloads.
+type compiledSchema struct {
+	schemaType string
+	avro       *goavro.Codec
+	proto      protoreflect.FileDescriptor
+}
+
+// Decoder decodes wire format payloads to JSON with the schemas of a Schema
+// Registry. Compiled schemas are cached by ID. It is safe for concurrent use.
+type Decoder struct {
+	client *Client
+
+	mu      sync.Mutex
+	schemas map[int]*compiledSchema
+}
+
+// NewDecoder creates a decoder of the schemas of client.
+func NewDecoder(client *Client) *Decoder {
+	return &Decoder{client: client, schemas: make(map[int]*compiledSchema)}
+}
+
+// Decode decodes a wire format payload to JSON. Avro records and Protobuf
+// messages are written as plain JSON objects; JSON payloads are kept as they
+// are.
+func (d *Decoder) Decode(ctx context.Context, payload []byte) (*Decoded, error) {
+	schemaID, body, err := Parse(payload)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := d.schema(ctx, schemaID)
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded []byte
+	switch schema.schemaType {
+	case SchemaTypeAvro:
+		decoded, err = decodeAvro(schema.avro, body)
+	case SchemaTypeProtobuf:
+		decoded, err = decodeProtobuf(schema.proto, body)
+	case SchemaTypeJSON:
+		if !json.Valid(body) {
+			err = errors.New("payload is not valid JSON")
+		}
+		decoded = body
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s payload of schema %d: %w", schema.schemaType, schemaID, err)
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, decoded); err != nil {
+		return nil, fmt.Errorf("failed to decode %s payload of schema %d: %w", schema.schemaType, schemaID, err)
+	}
+	return &Decoded{SchemaID: schemaID, SchemaType: schema.schemaType, JSON: compact.Bytes()}, nil
+}
+
+// DecodeEvent replaces wire format event data with its JSON decoding. The
+// data of structured events holds the payload as a base64 JSON string, like
+// the data of binary events that is not JSON. The content type becomes
+// application/json and the schema ID is kept in the schemaid extension. It
+// reports whether the data was decoded; data in any other form is left as it
+// is.
+func (d *Decoder) DecodeEvent(ctx context.Context, cloudEvent *event.CloudEvent) (bool, error) {
+	payload, ok := framedData(cloudEvent.Data)
+	if !ok {
+		return false, nil
+	}
+	decoded, err := d.Decode(ctx, payload)
+	if err != nil {
+		return false, err
+	}
+
+	contentType := "application/json"
+	cloudEvent.Data = decoded.JSON
+	cloudEvent.DataContentType = &contentType
+	if cloudEvent.Extensions == nil {
+		cloudEvent.Extensions = make(map[string]interface{})
+	}
+	cloudEvent.Extensions[ExtensionSchemaID] = decoded.SchemaID
+	return true, nil
+}
+
+// framedData returns the wire format payload held by event data as a base64
+// JSON string.
+func framedData(data json.RawMessage) ([]byte, bool) {
+	if len(data) < 2 || data[0] != '"' {
+		return nil, false
+	}
+	var encoded string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return nil, false
+	}
+	payload, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || !IsFramed(payload) {
+		return nil, false
+	}
+	return payload, true
+}
+
+// schema returns the compiled schema of id, fetching it on first use.
+// Failures are not cached; IsUnavailable reports those of the registry.
+func (d *Decoder) schema(ctx context.Context, id int) (*compiledSchema, error) {
+	d.mu.Lock()
+	schema, ok := d.schemas[id]
+	d.mu.Unlock()
+	if ok {
+		return schema, nil
+	}
+
+	registered, err := d.client.SchemaByID(ctx, id, false)
+	if err != nil {
+		return nil, err
+	}
+
+	schema = &compiledSchema{schemaType: registered.Type()}
+	switch schema.schemaType {
+	case SchemaTypeAvro:
+		if len(registered.References) > 0 {
+			return nil, fmt.Errorf("avro schema %d has references, which are not supported", id)
+		}
+		if schema.avro, err = goavro.NewCodecForStandardJSONFull(registered.Schema); err != nil {
+			return nil, fmt.Errorf("failed to compile avro schema %d: %w", id, err)
+		}
+	case SchemaTypeProtobuf:
+		// Protobuf schemas are compiled from their serialized descriptors
+		serialized, err := d.client.SchemaByID(ctx, id, true)
+		if err != nil {
+			return nil, err
+		}
+		if schema.proto, err = d.protoFile(ctx, serialized, new(protoregistry.Files)); err != nil {
+			return nil, fmt.Errorf("failed to compile protobuf schema %d: %w", id, err)
+		}
+	case SchemaTypeJSON:
+	default:
+		return nil, fmt.Errorf("unsupported schema type %s of schema %d", schema.schemaType, id)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if cached, ok := d.schemas[id]; ok {
+		return cached, nil
+	}
+	d.schemas[id] = schema
+	return schema, nil
+}
+
+// protoFile builds the file descriptor of a serialized Protobuf schema,
+// registering its references in files first.
+func (d *Decoder) protoFile(ctx context.Context, schema *Schema, files *protoregistry.Files) (protoreflect.FileDescriptor, error) {
+	for _, ref := range schema.References {
+		if _, err := files.FindFileByPath(ref.Name); err == nil {
+			continue
+		}
+		referenced, err := d.client.SchemaByVersion(ctx, ref.Subject, ref.Version, true)
+		if err != nil {
+			return nil, err
+		}
+		file, err := d.protoFile(ctx, referenced, files)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile reference %s: %w", ref.Name, err)
+		}
+		if err := files.RegisterFile(file); err != nil {
+			return nil, fmt.Errorf("failed to register reference %s: %w", ref.Name, err)
+		}
+	}
+
+	descriptor, err := base64.StdEncoding.DecodeString(schema.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode serialized schema: %w", err)
+	}
+	var fileProto descriptorpb.FileDescriptorProto
+	if err := proto.Unmarshal(descriptor, &fileProto); err != nil {
+		return nil, fmt.Errorf("failed to parse serialized schema: %w", err)
+	}
+	return protodesc.NewFile(&fileProto, resolver{files})
+}
+
+// resolver resolves the imports of a Protobuf schema from its references,
+// then from the well-known types.
+type resolver struct {
+	files *protoregistry.Files
+}
+
+func (r resolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	if file, err := r.files.FindFileByPath(path); err == nil {
+		return file, nil
+	}
+	return protoregistry.GlobalFiles.FindFileByPath(path)
+}
+
+func (r resolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if descriptor, err := r.files.FindDescriptorByName(name); err == nil {
+		return descriptor, nil
+	}
+	return protoregistry.GlobalFiles.FindDescriptorByName(name)
+}
+
+// decodeAvro decodes an Avro binary payload to standard JSON, where unions
+// are written as their value.
+func decodeAvro(codec *goavro.Codec, body []byte) ([]byte, error) {
+	native, rest, err := codec.NativeFromBinary(body)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("%d trailing bytes", len(rest))
+	}
+	return codec.TextualFromNative(nil, native)
+}
+
+// decodeProtobuf decodes a Protobuf payload prefixed with its message
+// indexes to JSON with the field names of the schema.
+func decodeProtobuf(file protoreflect.FileDescriptor, body []byte) ([]byte, error) {
+	indexes, body, err := parseMessageIndexes(body)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := file.Messages()
+	var descriptor protoreflect.MessageDescriptor
+	for _, index := range indexes {
+		if index >= messages.Len() {
+			return nil, fmt.Errorf("message index %v not found in schema", indexes)
+		}
+		descriptor = messages.Get(index)
+		messages = descriptor.Messages()
+	}
+
+	message := dynamicpb.NewMessage(descriptor)
+	if err := proto.Unmarshal(body, message); err != nil {
+		return nil, err
+	}
+	return protojson.MarshalOptions{UseProtoNames: true}.Marshal(message)
+}
@@ -0,0 +1,271 @@
+package confluent
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/jittakal/kafeventstore/pkg/event"
+)
+
+const orderAvroSchema = `{
+	"type": "record",
+	"name": "Order",
+	"fields": [
+		{"name": "id", "type": "string"},
+		{"name": "amount", "type": "double"},
+		{"name": "note", "type": ["null", "string"], "default": null}
+	]
+}`
+
+// newTestDecoder serves registry from an in-process server.
+func newTestDecoder(t *testing.T, registry *MockRegistry) *Decoder {
+	t.Helper()
+	server := httptest.NewServer(registry)
+	t.Cleanup(server.Close)
+	client, err := NewClient(ClientConfig{URL: server.URL, Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return NewDecoder(client)
+}
+
+// serializedFile returns the base64 serialized form of a file descriptor.
+func serializedFile(t *testing.T, file *descriptorpb.FileDescriptorProto) string {
+	t.Helper()
+	data, err := proto.Marshal(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// protoFiles returns the descriptors of money.proto, which defines
+// shop.Money, and order.proto, which imports it and the Timestamp type.
+func protoFiles() (*descriptorpb.FileDescriptorProto, *descriptorpb.FileDescriptorProto) {
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	money := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("money.proto"),
+		Package: proto.String("shop"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Money"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("currency"), Number: proto.Int32(1), Label: optional, Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), JsonName: proto.String("currency")},
+				{Name: proto.String("units"), Number: proto.Int32(2), Label: optional, Type: descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(), JsonName: proto.String("units")},
+			},
+		}},
+	}
+	order := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("order.proto"),
+		Package:    proto.String("shop"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"money.proto", "google/protobuf/timestamp.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("Unused")},
+			{
+				Name: proto.String("Order"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{Name: proto.String("order_id"), Number: proto.Int32(1), Label: optional, Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), JsonName: proto.String("orderId")},
+					{Name: proto.String("total"), Number: proto.Int32(2), Label: optional, Type: descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(), TypeName: proto.String(".shop.Money"), JsonName: proto.String("total")},
+					{Name: proto.String("created_at"), Number: proto.Int32(3), Label: optional, Type: descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(), TypeName: proto.String(".google.protobuf.Timestamp"), JsonName: proto.String("createdAt")},
+				},
+			},
+		},
+	}
+	return money, order
+}
+
+func TestDecoder_DecodeAvro(t *testing.T) {
+	registry := NewMockRegistry()
+	id := registry.Register("orders-value", Schema{Schema: orderAvroSchema})
+	decoder := newTestDecoder(t, registry)
+
+	codec, err := goavro.NewCodec(orderAvroSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := codec.BinaryFromNative(nil, map[string]interface{}{
+		"id":     "o-1",
+		"amount": 12.5,
+		"note":   goavro.Union("string", "gift"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := decoder.Decode(context.Background(), Frame(id, body))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	assertJSON(t, decoded.JSON, `{"id":"o-1","amount":12.5,"note":"gift"}`)
+	if decoded.SchemaID != id || decoded.SchemaType != SchemaTypeAvro {
+		t.Errorf("Decode() = schema %d %s", decoded.SchemaID, decoded.SchemaType)
+	}
+
+	// Compiled schemas are cached
+	before := registry.Requests()
+	if _, err := decoder.Decode(context.Background(), Frame(id, body)); err != nil {
+		t.Fatal(err)
+	}
+	if registry.Requests() != before {
+		t.Error("Decode() fetched a cached schema again")
+	}
+
+	// Truncated payloads are rejected
+	if _, err := decoder.Decode(context.Background(), Frame(id, body[:3])); err == nil {
+		t.Error("Decode(truncated) error = nil, want error")
+	}
+}
+
+func TestDecoder_DecodeProtobuf(t *testing.T) {
+	moneyFile, orderFile := protoFiles()
+	registry := NewMockRegistry()
+	registry.Register("money.proto", Schema{SchemaType: SchemaTypeProtobuf, Schema: serializedFile(t, moneyFile)})
+	id := registry.Register("orders-value", Schema{
+		SchemaType: SchemaTypeProtobuf,
+		Schema:     serializedFile(t, orderFile),
+		References: []Reference{{Name: "money.proto", Subject: "money.proto", Version: 1}},
+	})
+	decoder := newTestDecoder(t, registry)
+
+	// Encode an Order with descriptors built apart from the decoder's
+	files := new(protoregistry.Files)
+	money, err := protodesc.NewFile(moneyFile, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := files.RegisterFile(money); err != nil {
+		t.Fatal(err)
+	}
+	order, err := protodesc.NewFile(orderFile, resolver{files})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orderDesc := order.Messages().Get(1)
+	total := dynamicpb.NewMessage(money.Messages().Get(0))
+	total.Set(total.Descriptor().Fields().ByName("currency"), protoValue("EUR"))
+	total.Set(total.Descriptor().Fields().ByName("units"), protoValue(int64(12)))
+	message := dynamicpb.NewMessage(orderDesc)
+	message.Set(orderDesc.Fields().ByName("order_id"), protoValue("o-1"))
+	message.Set(orderDesc.Fields().ByName("total"), protoMessage(total))
+	message.Set(orderDesc.Fields().ByName("created_at"), protoMessage(timestamppb.New(time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC))))
+	body, err := proto.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := decoder.Decode(context.Background(), FrameProtobuf(id, []int{1}, body))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	assertJSON(t, decoded.JSON, `{"order_id":"o-1","total":{"currency":"EUR","units":"12"},"created_at":"2024-01-15T10:30:00Z"}`)
+
+	// Unknown message indexes are rejected
+	if _, err := decoder.Decode(context.Background(), FrameProtobuf(id, []int{5}, body)); err == nil {
+		t.Error("Decode(index 5) error = nil, want error")
+	}
+}
+
+func TestDecoder_DecodeJSON(t *testing.T) {
+	registry := NewMockRegistry()
+	id := registry.Register("orders-value", Schema{SchemaType: SchemaTypeJSON, Schema: `{"type":"object"}`})
+	decoder := newTestDecoder(t, registry)
+
+	decoded, err := decoder.Decode(context.Background(), Frame(id, []byte(`{ "id": "o-1" }`)))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if string(decoded.JSON) != `{"id":"o-1"}` {
+		t.Errorf("Decode() = %s", decoded.JSON)
+	}
+	if _, err := decoder.Decode(context.Background(), Frame(id, []byte("not json"))); err == nil {
+		t.Error("Decode(not json) error = nil, want error")
+	}
+}
+
+func TestDecoder_DecodeErrors(t *testing.T) {
+	registry := NewMockRegistry()
+	refID := registry.Register("orders-value", Schema{Schema: orderAvroSchema, References: []Reference{{Name: "x", Subject: "x", Version: 1}}})
+	badID := registry.Register("bad-value", Schema{Schema: `{"type": "nope"}`})
+	decoder := newTestDecoder(t, registry)
+
+	for name, payload := range map[string][]byte{
+		"not framed":        []byte(`{"id":"o-1"}`),
+		"unknown schema":    Frame(99, []byte{0}),
+		"avro references":   Frame(refID, []byte{0}),
+		"invalid avro type": Frame(badID, []byte{0}),
+	} {
+		if _, err := decoder.Decode(context.Background(), payload); err == nil {
+			t.Errorf("Decode(%s) error = nil, want error", name)
+		}
+	}
+}
+
+func TestDecoder_DecodeEvent(t *testing.T) {
+	registry := NewMockRegistry()
+	id := registry.Register("orders-value", Schema{SchemaType: SchemaTypeJSON, Schema: `{}`})
+	decoder := newTestDecoder(t, registry)
+
+	encoded, _ := json.Marshal(base64.StdEncoding.EncodeToString(Frame(id, []byte(`{"id":"o-1"}`))))
+	contentType := "application/octet-stream"
+	cloudEvent := &event.CloudEvent{ID: "1", DataContentType: &contentType, Data: encoded}
+	decodedEvent, err := decoder.DecodeEvent(context.Background(), cloudEvent)
+	if err != nil || !decodedEvent {
+		t.Fatalf("DecodeEvent() = %v, %v, want true", decodedEvent, err)
+	}
+	if string(cloudEvent.Data) != `{"id":"o-1"}` || *cloudEvent.DataContentType != "application/json" || cloudEvent.Extensions[ExtensionSchemaID] != id {
+		t.Errorf("DecodeEvent() event = %s %s %v", cloudEvent.Data, *cloudEvent.DataContentType, cloudEvent.Extensions)
+	}
+
+	// Data that is not framed is left as it is
+	for _, data := range []string{`{"id":"o-1"}`, `"plain text"`, `"aGVsbG8gd29ybGQ="`, ``} {
+		cloudEvent := &event.CloudEvent{ID: "1", Data: json.RawMessage(data)}
+		if decodedEvent, err := decoder.DecodeEvent(context.Background(), cloudEvent); err != nil || decodedEvent || string(cloudEvent.Data) != data {
+			t.Errorf("DecodeEvent(%s) = %v, %v, data %s", data, decodedEvent, err, cloudEvent.Data)
+		}
+	}
+
+	// Framed data of an unknown schema fails
+	encoded, _ = json.Marshal(base64.StdEncoding.EncodeToString(Frame(99, []byte(`{}`))))
+	if _, err := decoder.DecodeEvent(context.Background(), &event.CloudEvent{ID: "1", Data: encoded}); err == nil {
+		t.Error("DecodeEvent(unknown schema) error = nil, want error")
+	}
+}
+
+// assertJSON compares JSON documents regardless of formatting.
+func assertJSON(t *testing.T, got []byte, want string) {
+	t.Helper()
+	var gotValue, wantValue interface{}
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("invalid JSON %s: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatal(err)
+	}
+	gotJSON, _ := json.Marshal(gotValue)
+	wantJSON, _ := json.Marshal(wantValue)
+	if string(gotJSON) != string(wantJSON) {
+		t.Errorf("JSON = %s, want %s", got, want)
+	}
+}
+
+// protoValue wraps a scalar field value.
+func protoValue(v interface{}) protoreflect.Value { return protoreflect.ValueOf(v) }
+
+// protoMessage wraps a message field value.
+func protoMessage(m protoreflect.ProtoMessage) protoreflect.Value {
+	return protoreflect.ValueOfMessage(m.ProtoReflect())
+}
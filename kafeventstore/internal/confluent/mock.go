@@ -0,0 +1,107 @@
+// Package confluent implements an in-process Schema Registry for tests.
+package confluent
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MockRegistry is an in-process Schema Registry serving registered schemas,
+// for use with httptest.NewServer. Protobuf schemas are registered and served
+// in their serialized form, whatever the requested format.
+type MockRegistry struct {
+	mu       sync.Mutex
+	nextID   int
+	schemas  map[int]Schema
+	subjects map[string][]int
+	requests int
+}
+
+// NewMockRegistry creates an empty mock registry.
+func NewMockRegistry() *MockRegistry {
+	return &MockRegistry{
+		nextID:   1,
+		schemas:  make(map[int]Schema),
+		subjects: make(map[string][]int),
+	}
+}
+
+// Register adds a version of schema to subject and returns its ID.
+func (m *MockRegistry) Register(subject string, schema Schema) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	schema.ID = m.nextID
+	schema.Subject = subject
+	schema.Version = len(m.subjects[subject]) + 1
+	m.nextID++
+	m.schemas[schema.ID] = schema
+	m.subjects[subject] = append(m.subjects[subject], schema.ID)
+	return schema.ID
+}
+
+// Requests returns the number of requests served.
+func (m *MockRegistry) Requests() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.requests
+}
+
+// ServeHTTP serves GET /schemas/ids/{id} and
+// GET /subjects/{subject}/versions/{version|latest}.
+func (m *MockRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests++
+
+	if r.Method != http.MethodGet {
+		writeRegistryError(w, http.StatusMethodNotAllowed, 405, "Method not allowed")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 3 && parts[0] == "schemas" && parts[1] == "ids":
+		id, err := strconv.Atoi(parts[2])
+		schema, ok := m.schemas[id]
+		if err != nil || !ok {
+			writeRegistryError(w, http.StatusNotFound, 40403, "Schema not found")
+			return
+		}
+		// Lookups by ID only return the schema itself
+		writeRegistryJSON(w, Schema{SchemaType: schema.SchemaType, Schema: schema.Schema, References: schema.References})
+	case len(parts) == 4 && parts[0] == "subjects" && parts[2] == "versions":
+		ids, ok := m.subjects[parts[1]]
+		if !ok {
+			writeRegistryError(w, http.StatusNotFound, 40401, "Subject not found")
+			return
+		}
+		version := len(ids)
+		if parts[3] != "latest" {
+			var err error
+			if version, err = strconv.Atoi(parts[3]); err != nil || version < 1 || version > len(ids) {
+				writeRegistryError(w, http.StatusNotFound, 40402, "Version not found")
+				return
+			}
+		}
+		writeRegistryJSON(w, m.schemas[ids[version-1]])
+	default:
+		writeRegistryError(w, http.StatusNotFound, 404, "Not found")
+	}
+}
+
+// writeRegistryJSON writes a Schema Registry response.
+func writeRegistryJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeRegistryError writes a Schema Registry error response.
+func writeRegistryError(w http.ResponseWriter, status, code int, message string) {
+	w.Header().Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{"error_code": code, "message": message})
+}
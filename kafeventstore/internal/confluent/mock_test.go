@@ -0,0 +1,56 @@
+package confluent
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMockRegistry(t *testing.T) {
+	registry := NewMockRegistry()
+	first := registry.Register("orders-value", Schema{Schema: `"string"`})
+	second := registry.Register("orders-value", Schema{SchemaType: SchemaTypeJSON, Schema: `{}`})
+	if first != 1 || second != 2 {
+		t.Fatalf("Register() = %d, %d, want 1, 2", first, second)
+	}
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		want       Schema
+	}{
+		{path: "/schemas/ids/1", wantStatus: http.StatusOK, want: Schema{Schema: `"string"`}},
+		{path: "/schemas/ids/3", wantStatus: http.StatusNotFound},
+		{path: "/subjects/orders-value/versions/1", wantStatus: http.StatusOK, want: Schema{ID: 1, Subject: "orders-value", Version: 1, Schema: `"string"`}},
+		{path: "/subjects/orders-value/versions/latest", wantStatus: http.StatusOK, want: Schema{ID: 2, Subject: "orders-value", Version: 2, SchemaType: SchemaTypeJSON, Schema: `{}`}},
+		{path: "/subjects/orders-value/versions/3", wantStatus: http.StatusNotFound},
+		{path: "/subjects/payments-value/versions/1", wantStatus: http.StatusNotFound},
+		{path: "/config", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			registry.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var got Schema
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.ID != tt.want.ID || got.Subject != tt.want.Subject || got.Version != tt.want.Version ||
+				got.SchemaType != tt.want.SchemaType || got.Schema != tt.want.Schema {
+				t.Errorf("schema = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if got := registry.Requests(); got != len(tests) {
+		t.Errorf("Requests() = %d, want %d", got, len(tests))
+	}
+}
@@ -0,0 +1,89 @@
+// Package confluent decodes payloads in the Confluent Schema Registry wire
+// format: a zero magic byte, a 4-byte big-endian schema ID and the encoded
+// payload. Protobuf payloads also carry the indexes of their message type
+// between the schema ID and the payload.
+package confluent
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// MagicByte starts every payload in the wire format.
+const MagicByte byte = 0x0
+
+// headerSize is the size of the magic byte and the schema ID.
+const headerSize = 5
+
+// ErrNotFramed is returned for payloads that are not in the wire format.
+var ErrNotFramed = errors.New("payload is not in the schema registry wire format")
+
+// IsFramed reports whether payload starts with the wire format header.
+func IsFramed(payload []byte) bool {
+	return len(payload) >= headerSize && payload[0] == MagicByte
+}
+
+// Parse splits a framed payload into its schema ID and the encoded payload.
+func Parse(payload []byte) (int, []byte, error) {
+	if !IsFramed(payload) {
+		return 0, nil, ErrNotFramed
+	}
+	return int(binary.BigEndian.Uint32(payload[1:headerSize])), payload[headerSize:], nil
+}
+
+// Frame prefixes an encoded payload with the wire format header of schemaID.
+func Frame(schemaID int, payload []byte) []byte {
+	framed := make([]byte, headerSize, headerSize+len(payload))
+	framed[0] = MagicByte
+	binary.BigEndian.PutUint32(framed[1:headerSize], uint32(schemaID))
+	return append(framed, payload...)
+}
+
+// parseMessageIndexes reads the message indexes that prefix a Protobuf
+// payload: a zigzag varint count followed by that many zigzag varint indexes.
+// A count of zero stands for the first message of the schema, [0].
+func parseMessageIndexes(payload []byte) ([]int, []byte, error) {
+	count, n := binary.Varint(payload)
+	if n <= 0 {
+		return nil, nil, errors.New("invalid protobuf message index count")
+	}
+	payload = payload[n:]
+	if count == 0 {
+		return []int{0}, payload, nil
+	}
+	if count < 0 || count > int64(len(payload)) {
+		return nil, nil, fmt.Errorf("invalid protobuf message index count: %d", count)
+	}
+
+	indexes := make([]int, 0, count)
+	for i := int64(0); i < count; i++ {
+		index, n := binary.Varint(payload)
+		if n <= 0 || index < 0 {
+			return nil, nil, errors.New("invalid protobuf message index")
+		}
+		indexes = append(indexes, int(index))
+		payload = payload[n:]
+	}
+	return indexes, payload, nil
+}
+
+// FrameProtobuf prefixes an encoded Protobuf message with the wire format
+// header of schemaID and the indexes of its message type in the schema.
+func FrameProtobuf(schemaID int, indexes []int, payload []byte) []byte {
+	framed := appendMessageIndexes(Frame(schemaID, nil), indexes)
+	return append(framed, payload...)
+}
+
+// appendMessageIndexes appends the message indexes of a Protobuf payload,
+// using the single zero byte for the first message.
+func appendMessageIndexes(dst []byte, indexes []int) []byte {
+	if len(indexes) == 1 && indexes[0] == 0 {
+		return binary.AppendVarint(dst, 0)
+	}
+	dst = binary.AppendVarint(dst, int64(len(indexes)))
+	for _, index := range indexes {
+		dst = binary.AppendVarint(dst, int64(index))
+	}
+	return dst
+}
@@ -0,0 +1,87 @@
+package confluent
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+		wantID  int
+		want    []byte
+		wantErr bool
+	}{
+		{name: "framed", payload: []byte{0, 0, 0, 1, 2, 'a', 'b'}, wantID: 258, want: []byte("ab")},
+		{name: "header only", payload: []byte{0, 0, 0, 0, 7}, wantID: 7, want: []byte{}},
+		{name: "wrong magic byte", payload: []byte{1, 0, 0, 0, 7, 'a'}, wantErr: true},
+		{name: "too short", payload: []byte{0, 0, 0, 7}, wantErr: true},
+		{name: "json", payload: []byte(`{"a":1}`), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, body, err := Parse(tt.payload)
+			if tt.wantErr {
+				if !errors.Is(err, ErrNotFramed) {
+					t.Errorf("Parse() error = %v, want ErrNotFramed", err)
+				}
+				return
+			}
+			if err != nil || id != tt.wantID || !bytes.Equal(body, tt.want) {
+				t.Errorf("Parse() = %d, %q, %v, want %d, %q", id, body, err, tt.wantID, tt.want)
+			}
+		})
+	}
+}
+
+func TestFrame(t *testing.T) {
+	framed := Frame(258, []byte("ab"))
+	if want := []byte{0, 0, 0, 1, 2, 'a', 'b'}; !bytes.Equal(framed, want) {
+		t.Errorf("Frame() = %v, want %v", framed, want)
+	}
+	if !IsFramed(framed) {
+		t.Error("IsFramed() = false, want true")
+	}
+}
+
+func TestMessageIndexes(t *testing.T) {
+	tests := []struct {
+		name    string
+		indexes []int
+		encoded []byte
+	}{
+		{name: "first message", indexes: []int{0}, encoded: []byte{0}},
+		{name: "second message", indexes: []int{1}, encoded: []byte{2, 2}},
+		{name: "nested message", indexes: []int{1, 0, 2}, encoded: []byte{6, 2, 0, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := appendMessageIndexes(nil, tt.indexes)
+			if !bytes.Equal(encoded, tt.encoded) {
+				t.Errorf("appendMessageIndexes() = %v, want %v", encoded, tt.encoded)
+			}
+			indexes, rest, err := parseMessageIndexes(append(encoded, 'x'))
+			if err != nil || !reflect.DeepEqual(indexes, tt.indexes) || string(rest) != "x" {
+				t.Errorf("parseMessageIndexes() = %v, %q, %v, want %v", indexes, rest, err, tt.indexes)
+			}
+		})
+	}
+
+	for _, invalid := range [][]byte{nil, {1}, {4, 2}, {2, 1}} {
+		if _, _, err := parseMessageIndexes(invalid); err == nil {
+			t.Errorf("parseMessageIndexes(%v) error = nil, want error", invalid)
+		}
+	}
+}
+
+func TestFrameProtobuf(t *testing.T) {
+	framed := FrameProtobuf(3, []int{1}, []byte("ab"))
+	if want := []byte{0, 0, 0, 0, 3, 2, 2, 'a', 'b'}; !bytes.Equal(framed, want) {
+		t.Errorf("FrameProtobuf() = %v, want %v", framed, want)
+	}
+}
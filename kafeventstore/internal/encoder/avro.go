@@ -25,6 +25,7 @@ var _ encoder.Encoder = (*AvroEncoder)(nil)
 type AvroEncoder struct {
 	codec       *goavro.Codec
 	compression string
+	provenance  Provenance
 }
 
 // NewAvroEncoder creates a new Avro encoder with specified compression.
@@ -41,6 +42,10 @@ func NewAvroEncoder(compression string) (*AvroEncoder, error) {
 	}, nil
 }
 
+// AvroSchemaVersion is the version of the StorageRecord Avro schema.
+// Bump it whenever fields are added, removed or change type.
+const AvroSchemaVersion = "1"
+
 // avroSchema returns the Avro schema for storage records.
 func avroSchema() string {
 	return `{
@@ -79,20 +84,23 @@ func (e *AvroEncoder) Encode(filePath string, records []event.Record) (*event.Fi
 	}
 	defer file.Close()
 
-	var writer io.Writer = file
+	// Checksum the file contents as they are written
+	checksums := newChecksumWriter(file)
+	var writer io.Writer = checksums
 	var gzipWriter *gzip.Writer
 
 	// Apply compression if specified
 	if e.compression == "gzip" || e.compression == "GZIP" {
-		gzipWriter = gzip.NewWriter(file)
+		gzipWriter = gzip.NewWriter(checksums)
 		writer = gzipWriter
 		defer gzipWriter.Close()
 	}
 
 	// Create OCF writer (Object Container File)
 	ocfWriter, err := goavro.NewOCFWriter(goavro.OCFConfig{
-		W:     writer,
-		Codec: e.codec,
+		W:        writer,
+		Codec:    e.codec,
+		MetaData: e.ocfMetadata(records),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create OCF writer: %w", err)
@@ -132,11 +140,22 @@ func (e *AvroEncoder) Encode(filePath string, records []event.Record) (*event.Fi
 		SizeBytes:      fileInfo.Size(),
 		FirstWriteTime: time.Now(),
 		LastWriteTime:  time.Now(),
+		Checksums:      checksums.Sum(),
 	}
 
 	return stats, nil
 }
 
+// ocfMetadata returns the provenance metadata for the OCF header.
+func (e *AvroEncoder) ocfMetadata(records []event.Record) map[string][]byte {
+	metadata := FileMetadata(records, e.provenance, e.SchemaVersion())
+	ocfMetadata := make(map[string][]byte, len(metadata))
+	for key, value := range metadata {
+		ocfMetadata[key] = []byte(value)
+	}
+	return ocfMetadata
+}
+
 // convertToAvroMap converts a Record to Avro map representation.
 func (e *AvroEncoder) convertToAvroMap(record event.Record) (map[string]interface{}, error) {
 	// Serialize data to JSON string
@@ -204,8 +223,9 @@ func (e *AvroEncoder) EncodeToBytes(records []event.Record) ([]byte, error) {
 
 	// Create OCF writer
 	ocfWriter, err := goavro.NewOCFWriter(goavro.OCFConfig{
-		W:     writer,
-		Codec: e.codec,
+		W:        writer,
+		Codec:    e.codec,
+		MetaData: e.ocfMetadata(records),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create OCF writer: %w", err)
@@ -244,3 +264,8 @@ func (e *AvroEncoder) FileExtension() string {
 	}
 	return ".avro"
 }
+
+// SchemaVersion returns the Avro record schema version.
+func (e *AvroEncoder) SchemaVersion() string {
+	return AvroSchemaVersion
+}
@@ -0,0 +1,50 @@
+// Package encoder implements checksums computed while files are encoded.
+package encoder
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"hash"
+	"hash/crc32"
+	"io"
+
+	"github.com/jittakal/kafeventstore/pkg/event"
+)
+
+// crc32cTable is the CRC32 table for the Castagnoli polynomial used by S3 and GCS.
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// checksumWriter writes to an underlying writer while hashing the written bytes.
+type checksumWriter struct {
+	w      io.Writer
+	crc32c hash.Hash32
+	md5    hash.Hash
+	sha256 hash.Hash
+	multi  io.Writer
+}
+
+// newChecksumWriter wraps w so that every byte written is checksummed.
+func newChecksumWriter(w io.Writer) *checksumWriter {
+	cw := &checksumWriter{
+		w:      w,
+		crc32c: crc32.New(crc32cTable),
+		md5:    md5.New(),
+		sha256: sha256.New(),
+	}
+	cw.multi = io.MultiWriter(w, cw.crc32c, cw.md5, cw.sha256)
+	return cw
+}
+
+// Write implements io.Writer.
+func (cw *checksumWriter) Write(p []byte) (int, error) {
+	return cw.multi.Write(p)
+}
+
+// Sum returns the checksums of all bytes written so far.
+func (cw *checksumWriter) Sum() *event.Checksums {
+	return &event.Checksums{
+		CRC32C: cw.crc32c.Sum32(),
+		MD5:    cw.md5.Sum(nil),
+		SHA256: cw.sha256.Sum(nil),
+	}
+}
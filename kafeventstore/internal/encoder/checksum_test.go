@@ -0,0 +1,83 @@
+package encoder
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jittakal/kafeventstore/pkg/event"
+)
+
+func TestChecksumWriter(t *testing.T) {
+	data := []byte("hello, checksums")
+
+	var buf bytes.Buffer
+	cw := newChecksumWriter(&buf)
+	if _, err := cw.Write(data[:5]); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if _, err := cw.Write(data[5:]); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("underlying writer got %q, want %q", buf.Bytes(), data)
+	}
+
+	sums := cw.Sum()
+	assertChecksums(t, sums, data)
+}
+
+func TestEncoder_Checksums(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      event.FileFormat
+		compression string
+	}{
+		{name: "parquet snappy", format: event.FormatParquet, compression: "snappy"},
+		{name: "avro deflate", format: event.FormatAvro, compression: "deflate"},
+		{name: "avro gzip", format: event.FormatAvro, compression: "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc, err := NewFactory(tt.format, tt.compression).CreateEncoder()
+			if err != nil {
+				t.Fatalf("CreateEncoder() error = %v", err)
+			}
+
+			filePath := filepath.Join(t.TempDir(), "events"+enc.FileExtension())
+			stats, err := enc.Encode(filePath, metadataTestRecords())
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if stats.Checksums == nil {
+				t.Fatal("Encode() returned no checksums")
+			}
+
+			data, err := os.ReadFile(filePath)
+			if err != nil {
+				t.Fatalf("failed to read encoded file: %v", err)
+			}
+			assertChecksums(t, stats.Checksums, data)
+		})
+	}
+}
+
+func assertChecksums(t *testing.T, sums *event.Checksums, data []byte) {
+	t.Helper()
+
+	if want := crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli)); sums.CRC32C != want {
+		t.Errorf("CRC32C = %08x, want %08x", sums.CRC32C, want)
+	}
+	if want := md5.Sum(data); !bytes.Equal(sums.MD5, want[:]) {
+		t.Errorf("MD5 = %x, want %x", sums.MD5, want)
+	}
+	if want := sha256.Sum256(data); !bytes.Equal(sums.SHA256, want[:]) {
+		t.Errorf("SHA256 = %x, want %x", sums.SHA256, want)
+	}
+}
@@ -85,6 +85,38 @@
 //   - Avro: Uses predefined Avro schema JSON
 //
 // Both schemas are optimized for the CloudEvent + Kafka metadata structure.
+// SchemaVersion() reports the version of the schema an encoder writes.
+//
+// # File Metadata
+//
+// Every file carries provenance metadata (Parquet key/value metadata,
+// Avro OCF header metadata) so it can be traced back to its source range:
+//
+//	kafka.topic, kafka.partition, kafka.offset.min, kafka.offset.max,
+//	event.time.min, event.time.max, kafka.consumer.group,
+//	application.name, application.version, schema.version
+//
+// Configure the application fields through the factory:
+//
+//	factory := encoder.NewFactory(event.FormatParquet, "snappy",
+//	    encoder.WithProvenance(encoder.Provenance{ConsumerGroup: "event-store"}))
+//
+// # Typed Data Columns
+//
+// TypedSchemas map event types to JSON Schema or Avro schemas of their data.
+// Parquet batches whose events all share such a type add a data_typed group
+// with a column per schema property next to the raw JSON data:
+//
+//	typed := encoder.NewTypedSchemas()
+//	err := typed.Add("com.example.order.created", encoder.TypedSchemaJSON, schema)
+//	factory := encoder.NewFactory(event.FormatParquet, "snappy",
+//	    encoder.WithTypedSchemas(typed))
+//
+// # Checksums
+//
+// Encode computes CRC32C, MD5 and SHA-256 checksums of the file while it is
+// written and returns them in FileStats.Checksums, so storage writers can send
+// them with uploads without reading the file again.
 //
 // # Thread Safety
 //
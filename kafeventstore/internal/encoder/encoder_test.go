@@ -160,6 +160,20 @@ func TestParquetEncoder_FileExtension(t *testing.T) {
 	}
 }
 
+func TestEncoder_SchemaVersion(t *testing.T) {
+	avroEncoder, err := NewAvroEncoder("gzip")
+	if err != nil {
+		t.Fatalf("NewAvroEncoder() error = %v", err)
+	}
+
+	if got := NewParquetEncoder("snappy").SchemaVersion(); got != ParquetSchemaVersion {
+		t.Errorf("Parquet SchemaVersion() = %v, want %v", got, ParquetSchemaVersion)
+	}
+	if got := avroEncoder.SchemaVersion(); got != AvroSchemaVersion {
+		t.Errorf("Avro SchemaVersion() = %v, want %v", got, AvroSchemaVersion)
+	}
+}
+
 func TestParquetEncoder_Encode(t *testing.T) {
 	tempDir := os.TempDir()
 	testFile := filepath.Join(tempDir, "test-encode.parquet")
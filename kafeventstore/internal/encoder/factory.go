@@ -12,23 +12,55 @@ import (
 type Factory struct {
 	format      event.FileFormat
 	compression string
+	provenance  Provenance
+	typed       *TypedSchemas
+}
+
+// FactoryOption configures optional Factory settings.
+type FactoryOption func(*Factory)
+
+// WithProvenance embeds the given provenance in the metadata of every encoded file.
+func WithProvenance(provenance Provenance) FactoryOption {
+	return func(f *Factory) {
+		f.provenance = provenance
+	}
+}
+
+// WithTypedSchemas adds typed data columns to Parquet files of event types
+// with a typed schema.
+func WithTypedSchemas(typed *TypedSchemas) FactoryOption {
+	return func(f *Factory) {
+		f.typed = typed
+	}
 }
 
 // NewFactory creates a new encoder factory.
-func NewFactory(format event.FileFormat, compression string) *Factory {
-	return &Factory{
+func NewFactory(format event.FileFormat, compression string, opts ...FactoryOption) *Factory {
+	f := &Factory{
 		format:      format,
 		compression: compression,
 	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
 }
 
 // CreateEncoder creates an encoder based on the configured format.
 func (f *Factory) CreateEncoder() (encoder.Encoder, error) {
 	switch f.format {
 	case event.FormatParquet:
-		return NewParquetEncoder(f.compression), nil
+		enc := NewParquetEncoder(f.compression)
+		enc.provenance = f.provenance
+		enc.typedSchemas = f.typed
+		return enc, nil
 	case event.FormatAvro:
-		return NewAvroEncoder(f.compression)
+		enc, err := NewAvroEncoder(f.compression)
+		if err != nil {
+			return nil, err
+		}
+		enc.provenance = f.provenance
+		return enc, nil
 	default:
 		return nil, fmt.Errorf("unsupported file format: %s", f.format)
 	}
@@ -0,0 +1,83 @@
+// Package encoder implements file-level provenance metadata.
+package encoder
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/jittakal/kafeventstore/pkg/event"
+)
+
+// File metadata keys embedded in Parquet key/value metadata and Avro OCF headers.
+const (
+	MetaKafkaTopic         = "kafka.topic"
+	MetaKafkaPartition     = "kafka.partition"
+	MetaKafkaOffsetMin     = "kafka.offset.min"
+	MetaKafkaOffsetMax     = "kafka.offset.max"
+	MetaKafkaConsumerGroup = "kafka.consumer.group"
+	MetaEventTimeMin       = "event.time.min"
+	MetaEventTimeMax       = "event.time.max"
+	MetaApplicationName    = "application.name"
+	MetaApplicationVersion = "application.version"
+	MetaSchemaVersion      = "schema.version"
+	// MetaDataType holds the event type of Parquet files with typed data columns.
+	MetaDataType = "data.type"
+)
+
+// Provenance identifies the application that produced a file.
+type Provenance struct {
+	ConsumerGroup      string
+	ApplicationName    string
+	ApplicationVersion string
+}
+
+// FileMetadata returns the provenance metadata for a file holding records.
+// Records are expected to belong to a single topic partition. Empty
+// provenance fields are omitted.
+func FileMetadata(records []event.Record, provenance Provenance, schemaVersion string) map[string]string {
+	metadata := map[string]string{
+		MetaSchemaVersion: schemaVersion,
+	}
+
+	if provenance.ConsumerGroup != "" {
+		metadata[MetaKafkaConsumerGroup] = provenance.ConsumerGroup
+	}
+	if provenance.ApplicationName != "" {
+		metadata[MetaApplicationName] = provenance.ApplicationName
+	}
+	if provenance.ApplicationVersion != "" {
+		metadata[MetaApplicationVersion] = provenance.ApplicationVersion
+	}
+
+	if len(records) == 0 {
+		return metadata
+	}
+
+	minOffset, maxOffset := records[0].Kafka.Offset, records[0].Kafka.Offset
+	minTime := records[0].GetEventTime()
+	maxTime := minTime
+	for _, record := range records[1:] {
+		if record.Kafka.Offset < minOffset {
+			minOffset = record.Kafka.Offset
+		}
+		if record.Kafka.Offset > maxOffset {
+			maxOffset = record.Kafka.Offset
+		}
+		eventTime := record.GetEventTime()
+		if eventTime.Before(minTime) {
+			minTime = eventTime
+		}
+		if eventTime.After(maxTime) {
+			maxTime = eventTime
+		}
+	}
+
+	metadata[MetaKafkaTopic] = records[0].Kafka.Topic
+	metadata[MetaKafkaPartition] = strconv.FormatInt(int64(records[0].Kafka.Partition), 10)
+	metadata[MetaKafkaOffsetMin] = strconv.FormatInt(minOffset, 10)
+	metadata[MetaKafkaOffsetMax] = strconv.FormatInt(maxOffset, 10)
+	metadata[MetaEventTimeMin] = minTime.UTC().Format(time.RFC3339Nano)
+	metadata[MetaEventTimeMax] = maxTime.UTC().Format(time.RFC3339Nano)
+
+	return metadata
+}
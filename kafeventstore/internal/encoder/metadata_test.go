@@ -0,0 +1,134 @@
+package encoder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/jittakal/kafeventstore/pkg/event"
+)
+
+func metadataTestRecords() []event.Record {
+	first := time.Date(2025, 12, 18, 10, 0, 0, 0, time.UTC)
+	last := first.Add(time.Hour)
+	return []event.Record{
+		{
+			Event: &event.CloudEvent{SpecVersion: "1.0", ID: "1", Source: "src", Type: "t", Time: &last, Data: []byte(`{}`)},
+			Kafka: event.KafkaMetadata{Topic: "orders", Partition: 3, Offset: 42, Timestamp: last},
+		},
+		{
+			Event: &event.CloudEvent{SpecVersion: "1.0", ID: "2", Source: "src", Type: "t", Time: &first, Data: []byte(`{}`)},
+			Kafka: event.KafkaMetadata{Topic: "orders", Partition: 3, Offset: 40, Timestamp: first},
+		},
+	}
+}
+
+var testProvenance = Provenance{
+	ConsumerGroup:      "event-store",
+	ApplicationName:    "kafka-event-store",
+	ApplicationVersion: "1.2.3",
+}
+
+func TestFileMetadata(t *testing.T) {
+	metadata := FileMetadata(metadataTestRecords(), testProvenance, "1")
+
+	want := map[string]string{
+		MetaKafkaTopic:         "orders",
+		MetaKafkaPartition:     "3",
+		MetaKafkaOffsetMin:     "40",
+		MetaKafkaOffsetMax:     "42",
+		MetaEventTimeMin:       "2025-12-18T10:00:00Z",
+		MetaEventTimeMax:       "2025-12-18T11:00:00Z",
+		MetaKafkaConsumerGroup: "event-store",
+		MetaApplicationName:    "kafka-event-store",
+		MetaApplicationVersion: "1.2.3",
+		MetaSchemaVersion:      "1",
+	}
+	for key, value := range want {
+		if metadata[key] != value {
+			t.Errorf("metadata[%q] = %q, want %q", key, metadata[key], value)
+		}
+	}
+}
+
+func TestFileMetadata_OmitsEmptyProvenance(t *testing.T) {
+	metadata := FileMetadata(nil, Provenance{}, "1")
+
+	if len(metadata) != 1 || metadata[MetaSchemaVersion] != "1" {
+		t.Errorf("FileMetadata() = %v, want only schema version", metadata)
+	}
+}
+
+func TestParquetEncoder_EmbedsFileMetadata(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "meta.parquet")
+
+	enc, err := NewFactory(event.FormatParquet, "snappy", WithProvenance(testProvenance)).CreateEncoder()
+	if err != nil {
+		t.Fatalf("CreateEncoder() error = %v", err)
+	}
+	if _, err := enc.Encode(filePath, metadataTestRecords()); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	info, _ := file.Stat()
+
+	pf, err := parquet.OpenFile(file, info.Size())
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+
+	for key, want := range map[string]string{
+		MetaKafkaTopic:         "orders",
+		MetaKafkaOffsetMax:     "42",
+		MetaKafkaConsumerGroup: "event-store",
+		MetaSchemaVersion:      ParquetSchemaVersion,
+	} {
+		if got, ok := pf.Lookup(key); !ok || got != want {
+			t.Errorf("Lookup(%q) = %q, %v; want %q", key, got, ok, want)
+		}
+	}
+}
+
+func TestAvroEncoder_EmbedsFileMetadata(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "meta.avro")
+
+	enc, err := NewFactory(event.FormatAvro, "uncompressed", WithProvenance(testProvenance)).CreateEncoder()
+	if err != nil {
+		t.Fatalf("CreateEncoder() error = %v", err)
+	}
+	if _, err := enc.Encode(filePath, metadataTestRecords()); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	reader, err := goavro.NewOCFReader(file)
+	if err != nil {
+		t.Fatalf("NewOCFReader() error = %v", err)
+	}
+
+	metadata := reader.MetaData()
+	for key, want := range map[string]string{
+		MetaKafkaPartition:     "3",
+		MetaKafkaOffsetMin:     "40",
+		MetaApplicationVersion: "1.2.3",
+		MetaSchemaVersion:      AvroSchemaVersion,
+	} {
+		if got := string(metadata[key]); got != want {
+			t.Errorf("metadata[%q] = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -4,7 +4,9 @@ package encoder
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"sort"
 	"time"
 
 	"github.com/jittakal/kafeventstore/pkg/encoder"
@@ -15,6 +17,10 @@ import (
 // Ensure implementation satisfies interface at compile time.
 var _ encoder.Encoder = (*ParquetEncoder)(nil)
 
+// ParquetSchemaVersion is the version of the CloudEventParquet schema.
+// Bump it whenever columns are added, removed or change type.
+const ParquetSchemaVersion = "1"
+
 // CloudEventParquet represents the Parquet schema for CloudEvents storage.
 // Uses native Parquet types for Athena compatibility, including TIMESTAMP_MICROS for time fields.
 type CloudEventParquet struct {
@@ -46,6 +52,8 @@ type CloudEventParquet struct {
 // Supports multiple compression codecs: SNAPPY (default), GZIP, LZ4, ZSTD.
 type ParquetEncoder struct {
 	compressionName string
+	provenance      Provenance
+	typedSchemas    *TypedSchemas
 }
 
 // NewParquetEncoder creates a new Parquet encoder with specified compression.
@@ -100,26 +108,34 @@ func (e *ParquetEncoder) Encode(filePath string, records []event.Record) (*event
 		parquetRecords[i] = *parquetRec
 	}
 
-	// Create schema from struct
+	// Create schema from struct; records of a type with a typed schema also
+	// get typed data columns
 	schema := parquet.SchemaOf(new(CloudEventParquet))
+	metadata := FileMetadata(records, e.provenance, e.SchemaVersion())
+	typed := e.typedSchemas.match(records)
+	if typed != nil {
+		schema = typed.Schema()
+		metadata[MetaDataType] = typed.eventType
+	}
 
-	// Write Parquet file with compression
-	writer := parquet.NewGenericWriter[CloudEventParquet](
-		file,
+	// Write Parquet file with compression and provenance key/value metadata
+	options := []parquet.WriterOption{
 		schema,
 		compressionCodec(e.compressionName),
 		parquet.CreatedBy("kafka-event-blob-store", "1.0", "0"),
-	)
-
-	// Write all records
-	if _, err := writer.Write(parquetRecords); err != nil {
-		writer.Close()
-		return nil, fmt.Errorf("failed to write records: %w", err)
 	}
+	options = append(options, keyValueMetadata(metadata)...)
 
-	// Flush and close writer
-	if err := writer.Close(); err != nil {
-		return nil, fmt.Errorf("failed to close writer: %w", err)
+	// Checksum the file contents as they are written
+	checksums := newChecksumWriter(file)
+	if typed != nil {
+		err = writeTypedRows(checksums, options, typed, parquetRecords, records)
+	} else {
+		err = writeRows(checksums, options, parquetRecords)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
 	}
 
 	// Close file before getting stats to ensure all data is flushed
@@ -137,11 +153,68 @@ func (e *ParquetEncoder) Encode(filePath string, records []event.Record) (*event
 		SizeBytes:      fileInfo.Size(),
 		FirstWriteTime: time.Now(),
 		LastWriteTime:  time.Now(),
+		Checksums:      checksums.Sum(),
 	}
 
 	return stats, nil
 }
 
+// writeRows writes CloudEventParquet rows.
+func writeRows(w io.Writer, options []parquet.WriterOption, rows []CloudEventParquet) error {
+	writer := parquet.NewGenericWriter[CloudEventParquet](w, options...)
+
+	// Write all records
+	if _, err := writer.Write(rows); err != nil {
+		writer.Close()
+		return fmt.Errorf("failed to write records: %w", err)
+	}
+
+	// Flush and close writer
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to close writer: %w", err)
+	}
+	return nil
+}
+
+// writeTypedRows writes rows of the typed schema of records.
+func writeTypedRows(
+	w io.Writer,
+	options []parquet.WriterOption,
+	typed *TypedSchema,
+	converted []CloudEventParquet,
+	records []event.Record,
+) error {
+	rows := make([]map[string]any, len(records))
+	for i := range records {
+		rows[i] = typed.row(&converted[i], records[i])
+	}
+
+	writer := parquet.NewGenericWriter[map[string]any](w, options...)
+	if _, err := writer.Write(rows); err != nil {
+		writer.Close()
+		return fmt.Errorf("failed to write records: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to close writer: %w", err)
+	}
+	return nil
+}
+
+// keyValueMetadata converts file metadata to Parquet writer options in key order.
+func keyValueMetadata(metadata map[string]string) []parquet.WriterOption {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	options := make([]parquet.WriterOption, 0, len(keys))
+	for _, key := range keys {
+		options = append(options, parquet.KeyValueMetadata(key, metadata[key]))
+	}
+	return options
+}
+
 // convertToParquetRecord converts a Record to CloudEventParquet with native types.
 func (e *ParquetEncoder) convertToParquetRecord(record event.Record) (*CloudEventParquet, error) {
 	// Serialize data to JSON string
@@ -189,3 +262,8 @@ func (e *ParquetEncoder) Format() event.FileFormat {
 func (e *ParquetEncoder) FileExtension() string {
 	return ".parquet"
 }
+
+// SchemaVersion returns the Parquet record schema version.
+func (e *ParquetEncoder) SchemaVersion() string {
+	return ParquetSchemaVersion
+}
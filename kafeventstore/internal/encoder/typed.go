@@ -0,0 +1,649 @@
+// Package encoder implements typed Parquet columns for the data of known event types.
+package encoder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/jittakal/kafeventstore/internal/schema"
+	"github.com/jittakal/kafeventstore/pkg/event"
+	"github.com/parquet-go/parquet-go"
+)
+
+// Formats of the schemas typed columns are derived from.
+const (
+	TypedSchemaJSON = "json_schema"
+	TypedSchemaAvro = "avro"
+)
+
+// ColumnDataTyped is the group column holding the typed fields of the event data.
+const ColumnDataTyped = "data_typed"
+
+// maxTypedDepth bounds the nesting of typed columns. Deeper values, including
+// those of recursive schemas, are stored as JSON strings.
+const maxTypedDepth = 16
+
+// decimalPrecision is the precision of decimal columns, the most an INT64 holds.
+const decimalPrecision = 18
+
+// typedKind is the kind of value a typed column holds.
+type typedKind int
+
+const (
+	kindJSON typedKind = iota // any value, stored as its JSON encoding
+	kindString
+	kindInt
+	kindDouble
+	kindBoolean
+	kindTimestamp
+	kindDate
+	kindDecimal
+	kindRecord
+	kindArray
+)
+
+// typedField is a typed column, or a group of typed columns.
+type typedField struct {
+	kind   typedKind
+	scale  int                    // decimal scale
+	unit   time.Duration          // unit of numeric timestamps
+	fields map[string]*typedField // record fields
+	elem   *typedField            // array elements
+}
+
+// node returns the Parquet node of the field's values.
+func (f *typedField) node() parquet.Node {
+	switch f.kind {
+	case kindInt:
+		return parquet.Int(64)
+	case kindDouble:
+		return parquet.Leaf(parquet.DoubleType)
+	case kindBoolean:
+		return parquet.Leaf(parquet.BooleanType)
+	case kindTimestamp:
+		return parquet.Timestamp(parquet.Microsecond)
+	case kindDate:
+		return parquet.Date()
+	case kindDecimal:
+		return parquet.Decimal(f.scale, decimalPrecision, parquet.Int64Type)
+	case kindRecord:
+		group := make(parquet.Group, len(f.fields))
+		for name, field := range f.fields {
+			group[name] = field.column()
+		}
+		return group
+	default:
+		return parquet.String()
+	}
+}
+
+// column returns the node of the field as a column of a group: repeated for
+// arrays and optional otherwise, since events may omit any field.
+func (f *typedField) column() parquet.Node {
+	if f.kind == kindArray {
+		return parquet.Repeated(f.elem.node())
+	}
+	return parquet.Optional(f.node())
+}
+
+// value converts a JSON value decoded with UseNumber to the value of the
+// column. Values that do not match the column type yield nil, a null; the
+// raw data column still holds them.
+func (f *typedField) value(v any) any {
+	if v == nil {
+		return nil
+	}
+	switch f.kind {
+	case kindString:
+		if s, ok := v.(string); ok {
+			return s
+		}
+		return jsonString(v)
+	case kindInt:
+		if n, ok := v.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				return i
+			}
+		}
+	case kindDouble:
+		if n, ok := v.(json.Number); ok {
+			if d, err := n.Float64(); err == nil {
+				return d
+			}
+		}
+	case kindBoolean:
+		if b, ok := v.(bool); ok {
+			return b
+		}
+	case kindTimestamp:
+		return timestampValue(v, f.unit)
+	case kindDate:
+		return dateValue(v)
+	case kindDecimal:
+		return decimalValue(v, f.scale)
+	case kindRecord:
+		object, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		row := make(map[string]any, len(f.fields))
+		for name, field := range f.fields {
+			if value := field.value(object[name]); value != nil {
+				row[name] = optionalValue(value)
+			}
+		}
+		return row
+	case kindArray:
+		items, ok := v.([]any)
+		if !ok {
+			return nil
+		}
+		values := make([]any, 0, len(items))
+		for _, item := range items {
+			if value := f.elem.value(item); value != nil {
+				values = append(values, value)
+			}
+		}
+		return values
+	default:
+		return jsonString(v)
+	}
+	return nil
+}
+
+// optionalValue returns a pointer to leaf values, since optional columns
+// write zero values such as false or 0 as null otherwise.
+func optionalValue(v any) any {
+	switch value := v.(type) {
+	case string:
+		return &value
+	case int64:
+		return &value
+	case int32:
+		return &value
+	case float64:
+		return &value
+	case bool:
+		return &value
+	case time.Time:
+		return &value
+	}
+	return v
+}
+
+// jsonString returns the JSON encoding of v.
+func jsonString(v any) any {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return string(encoded)
+}
+
+// timestampValue converts an RFC 3339 string, or a number of units since the
+// Unix epoch, to a time.
+func timestampValue(v any, unit time.Duration) any {
+	switch value := v.(type) {
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return t
+		}
+	case json.Number:
+		if n, err := value.Int64(); err == nil {
+			return time.Unix(0, 0).Add(time.Duration(n) * unit).UTC()
+		}
+	}
+	return nil
+}
+
+// dateValue converts a YYYY-MM-DD string, or a number of days since the Unix
+// epoch, to a date.
+func dateValue(v any) any {
+	switch value := v.(type) {
+	case string:
+		if t, err := time.Parse(time.DateOnly, value); err == nil {
+			return int32(t.Unix() / 86400)
+		}
+	case json.Number:
+		if n, err := value.Int64(); err == nil {
+			return int32(n)
+		}
+	}
+	return nil
+}
+
+// decimalValue converts a number, or a numeric string, to the unscaled value
+// of a decimal with scale. Values with more digits than the scale, or too
+// large for the precision, yield nil.
+func decimalValue(v any, scale int) any {
+	var text string
+	switch value := v.(type) {
+	case json.Number:
+		text = value.String()
+	case string:
+		text = value
+	default:
+		return nil
+	}
+	r, ok := new(big.Rat).SetString(text)
+	if !ok {
+		return nil
+	}
+	r.Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)))
+	if !r.IsInt() || !r.Num().IsInt64() {
+		return nil
+	}
+	unscaled := r.Num().Int64()
+	if unscaled >= 1e18 || unscaled <= -1e18 {
+		return nil
+	}
+	return unscaled
+}
+
+// TypedSchema is the Parquet layout of the events of one type: the columns of
+// CloudEventParquet plus the data_typed group derived from the schema of
+// their data.
+type TypedSchema struct {
+	eventType string
+	data      *typedField
+	schema    *parquet.Schema
+}
+
+// newTypedSchema creates the layout of eventType with typed data columns.
+func newTypedSchema(eventType string, data *typedField) (*TypedSchema, error) {
+	if data.kind != kindRecord {
+		return nil, fmt.Errorf("schema of %s does not describe an object with properties", eventType)
+	}
+
+	group := parquet.Group{}
+	for _, field := range parquet.SchemaOf(new(CloudEventParquet)).Fields() {
+		group[field.Name()] = field
+	}
+	group[ColumnDataTyped] = parquet.Optional(data.node())
+
+	return &TypedSchema{
+		eventType: eventType,
+		data:      data,
+		schema:    parquet.NewSchema("CloudEventParquet", group),
+	}, nil
+}
+
+// Schema returns the Parquet schema of the layout.
+func (s *TypedSchema) Schema() *parquet.Schema {
+	return s.schema
+}
+
+// row returns the Parquet row of a record and its converted CloudEventParquet.
+func (s *TypedSchema) row(converted *CloudEventParquet, record event.Record) map[string]any {
+	row := map[string]any{
+		"spec_version":    converted.SpecVersion,
+		"id":              converted.ID,
+		"source":          converted.Source,
+		"type":            converted.Type,
+		"data":            converted.Data,
+		"kafka_topic":     converted.KafkaTopic,
+		"kafka_partition": converted.KafkaPartition,
+		"kafka_offset":    converted.KafkaOffset,
+		"kafka_timestamp": converted.KafkaTimestamp,
+		"ingested_at":     converted.IngestedAt,
+	}
+	if converted.Subject != nil {
+		row["subject"] = converted.Subject
+	}
+	if converted.DataContentType != nil {
+		row["data_content_type"] = converted.DataContentType
+	}
+	if converted.DataSchema != nil {
+		row["data_schema"] = converted.DataSchema
+	}
+	if converted.Time != nil {
+		row["time"] = converted.Time
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(record.Event.Data))
+	decoder.UseNumber()
+	var data any
+	if err := decoder.Decode(&data); err == nil {
+		if value := s.data.value(data); value != nil {
+			row[ColumnDataTyped] = value
+		}
+	}
+	return row
+}
+
+// TypedSchemas maps event types to the layouts of their Parquet files.
+// It is safe for concurrent use once built.
+type TypedSchemas struct {
+	byType map[string]*TypedSchema
+}
+
+// NewTypedSchemas creates an empty set of typed schemas.
+func NewTypedSchemas() *TypedSchemas {
+	return &TypedSchemas{byType: make(map[string]*TypedSchema)}
+}
+
+// Add derives the typed columns of eventType from a JSON Schema or an Avro
+// schema of its data. The schema must describe an object, or an Avro record.
+func (s *TypedSchemas) Add(eventType, format string, schema []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(schema))
+	decoder.UseNumber()
+	var document any
+	if err := decoder.Decode(&document); err != nil {
+		return fmt.Errorf("failed to parse schema of %s: %w", eventType, err)
+	}
+
+	var data *typedField
+	switch format {
+	case TypedSchemaJSON, "":
+		data = (&jsonSchemaCompiler{root: document}).field(document, 0)
+	case TypedSchemaAvro:
+		data = (&avroCompiler{names: make(map[string]*typedField)}).field(document, "", 0)
+	default:
+		return fmt.Errorf("unsupported schema format of %s: %s", eventType, format)
+	}
+
+	typed, err := newTypedSchema(eventType, data)
+	if err != nil {
+		return err
+	}
+	s.byType[eventType] = typed
+	return nil
+}
+
+// Has reports whether eventType has a typed schema.
+func (s *TypedSchemas) Has(eventType string) bool {
+	return s.Lookup(eventType) != nil
+}
+
+// Lookup returns the typed schema of eventType, or nil.
+func (s *TypedSchemas) Lookup(eventType string) *TypedSchema {
+	if s == nil {
+		return nil
+	}
+	return s.byType[eventType]
+}
+
+// match returns the typed schema shared by all records, or nil when they
+// have different types or their type has no typed schema.
+func (s *TypedSchemas) match(records []event.Record) *TypedSchema {
+	if s == nil || len(records) == 0 || records[0].Event == nil {
+		return nil
+	}
+	eventType := records[0].Event.Type
+	for _, record := range records[1:] {
+		if record.Event == nil || record.Event.Type != eventType {
+			return nil
+		}
+	}
+	return s.Lookup(eventType)
+}
+
+// jsonSchemaCompiler derives typed columns from a JSON Schema. Schemas it
+// cannot map to a single column type become JSON string columns.
+type jsonSchemaCompiler struct {
+	root any
+}
+
+// field returns the typed column of a JSON Schema.
+func (c *jsonSchemaCompiler) field(def any, depth int) *typedField {
+	object, ok := def.(map[string]any)
+	if !ok || depth > maxTypedDepth {
+		return &typedField{kind: kindJSON}
+	}
+
+	if ref, ok := object["$ref"].(string); ok {
+		resolved, err := schema.ResolveRef(c.root, ref)
+		if err != nil {
+			return &typedField{kind: kindJSON}
+		}
+		return c.field(resolved, depth+1)
+	}
+	for _, keyword := range []string{"anyOf", "oneOf"} {
+		if branches, ok := object[keyword].([]any); ok {
+			var nonNull []any
+			for _, branch := range branches {
+				if b, ok := branch.(map[string]any); !ok || b["type"] != "null" {
+					nonNull = append(nonNull, branch)
+				}
+			}
+			if len(nonNull) != 1 {
+				return &typedField{kind: kindJSON}
+			}
+			return c.field(nonNull[0], depth+1)
+		}
+	}
+
+	switch jsonSchemaType(object) {
+	case "string":
+		switch object["format"] {
+		case "date-time":
+			return &typedField{kind: kindTimestamp, unit: time.Millisecond}
+		case "date":
+			return &typedField{kind: kindDate}
+		}
+		return &typedField{kind: kindString}
+	case "integer":
+		return &typedField{kind: kindInt}
+	case "number":
+		if scale, ok := decimalScale(object["multipleOf"]); ok {
+			return &typedField{kind: kindDecimal, scale: scale}
+		}
+		return &typedField{kind: kindDouble}
+	case "boolean":
+		return &typedField{kind: kindBoolean}
+	case "object":
+		properties, _ := object["properties"].(map[string]any)
+		if len(properties) == 0 {
+			return &typedField{kind: kindJSON}
+		}
+		fields := make(map[string]*typedField, len(properties))
+		for name, property := range properties {
+			fields[name] = c.field(property, depth+1)
+		}
+		return &typedField{kind: kindRecord, fields: fields}
+	case "array":
+		elem := c.field(object["items"], depth+1)
+		if elem.kind == kindArray {
+			return &typedField{kind: kindJSON}
+		}
+		return &typedField{kind: kindArray, elem: elem}
+	default:
+		return &typedField{kind: kindJSON}
+	}
+}
+
+// jsonSchemaType returns the single non-null type of a schema, "number" for
+// integer or number, or "" when the type is ambiguous. Schemas without a
+// type but with properties are objects.
+func jsonSchemaType(object map[string]any) string {
+	switch t := object["type"].(type) {
+	case string:
+		return t
+	case []any:
+		var types []string
+		for _, item := range t {
+			if name, ok := item.(string); ok && name != "null" {
+				types = append(types, name)
+			}
+		}
+		switch {
+		case len(types) == 1:
+			return types[0]
+		case len(types) == 2 && (types[0] == "integer" && types[1] == "number" || types[0] == "number" && types[1] == "integer"):
+			return "number"
+		}
+		return ""
+	case nil:
+		if _, ok := object["properties"]; ok {
+			return "object"
+		}
+	}
+	return ""
+}
+
+// decimalScale returns the scale of a multipleOf that is a negative power of
+// ten, such as 0.01 for a scale of 2.
+func decimalScale(multipleOf any) (int, bool) {
+	n, ok := multipleOf.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	r, ok := new(big.Rat).SetString(n.String())
+	if !ok || r.Sign() <= 0 || r.Cmp(big.NewRat(1, 1)) >= 0 {
+		return 0, false
+	}
+	inverse := new(big.Rat).Inv(r)
+	if !inverse.IsInt() {
+		return 0, false
+	}
+	digits := inverse.Num().String()
+	if strings.Trim(digits[1:], "0") != "" || digits[0] != '1' || len(digits)-1 > decimalPrecision {
+		return 0, false
+	}
+	return len(digits) - 1, true
+}
+
+// avroCompiler derives typed columns from an Avro schema. Types it cannot
+// map to a single column type, such as maps and unions of several types,
+// become JSON string columns.
+type avroCompiler struct {
+	names map[string]*typedField // named types; nil while being defined
+}
+
+// field returns the typed column of an Avro schema in namespace.
+func (c *avroCompiler) field(schema any, namespace string, depth int) *typedField {
+	if depth > maxTypedDepth {
+		return &typedField{kind: kindJSON}
+	}
+
+	switch s := schema.(type) {
+	case string:
+		switch s {
+		case "string", "bytes":
+			return &typedField{kind: kindString}
+		case "int", "long":
+			return &typedField{kind: kindInt}
+		case "float", "double":
+			return &typedField{kind: kindDouble}
+		case "boolean":
+			return &typedField{kind: kindBoolean}
+		}
+		// Recursive references, still being defined, are nil
+		if field := c.names[avroFullName(s, namespace)]; field != nil {
+			return field
+		}
+		if field := c.names[s]; field != nil {
+			return field
+		}
+		return &typedField{kind: kindJSON}
+	case []any:
+		var nonNull []any
+		for _, branch := range s {
+			if branch != "null" {
+				nonNull = append(nonNull, branch)
+			}
+		}
+		if len(nonNull) != 1 {
+			return &typedField{kind: kindJSON}
+		}
+		return c.field(nonNull[0], namespace, depth+1)
+	case map[string]any:
+		return c.complexField(s, namespace, depth)
+	default:
+		return &typedField{kind: kindJSON}
+	}
+}
+
+// complexField returns the typed column of an Avro schema object.
+func (c *avroCompiler) complexField(schema map[string]any, namespace string, depth int) *typedField {
+	switch schema["logicalType"] {
+	case "timestamp-millis", "local-timestamp-millis":
+		return &typedField{kind: kindTimestamp, unit: time.Millisecond}
+	case "timestamp-micros", "local-timestamp-micros":
+		return &typedField{kind: kindTimestamp, unit: time.Microsecond}
+	case "date":
+		return &typedField{kind: kindDate}
+	case "decimal":
+		precision, _ := schema["precision"].(json.Number)
+		scale, _ := schema["scale"].(json.Number)
+		p, _ := precision.Int64()
+		sc, _ := scale.Int64()
+		if p > 0 && p <= decimalPrecision && sc >= 0 && sc <= p {
+			field := &typedField{kind: kindDecimal, scale: int(sc)}
+			c.define(schema, namespace, field)
+			return field
+		}
+	}
+
+	switch t := schema["type"].(type) {
+	case string:
+		switch t {
+		case "record", "error":
+			name := c.define(schema, namespace, nil)
+			if ns, ok := schema["namespace"].(string); ok {
+				namespace = ns
+			} else if i := strings.LastIndex(name, "."); i >= 0 {
+				namespace = name[:i]
+			}
+			fields := make(map[string]*typedField)
+			list, _ := schema["fields"].([]any)
+			for _, item := range list {
+				fieldSchema, ok := item.(map[string]any)
+				if !ok {
+					continue
+				}
+				if fieldName, ok := fieldSchema["name"].(string); ok {
+					fields[fieldName] = c.field(fieldSchema["type"], namespace, depth+1)
+				}
+			}
+			if len(fields) == 0 {
+				return &typedField{kind: kindJSON}
+			}
+			field := &typedField{kind: kindRecord, fields: fields}
+			c.names[name] = field
+			return field
+		case "enum", "fixed":
+			field := &typedField{kind: kindString}
+			c.define(schema, namespace, field)
+			return field
+		case "array":
+			elem := c.field(schema["items"], namespace, depth+1)
+			if elem.kind == kindArray {
+				return &typedField{kind: kindJSON}
+			}
+			return &typedField{kind: kindArray, elem: elem}
+		case "map":
+			return &typedField{kind: kindJSON}
+		}
+		return c.field(t, namespace, depth+1)
+	default:
+		return c.field(t, namespace, depth+1)
+	}
+}
+
+// define registers a named type and returns its full name. Schemas without
+// a name are not registered.
+func (c *avroCompiler) define(schema map[string]any, namespace string, field *typedField) string {
+	name, ok := schema["name"].(string)
+	if !ok {
+		return ""
+	}
+	if ns, ok := schema["namespace"].(string); ok {
+		namespace = ns
+	}
+	fullName := avroFullName(name, namespace)
+	c.names[fullName] = field
+	return fullName
+}
+
+// avroFullName qualifies a name with namespace unless it is already qualified.
+func avroFullName(name, namespace string) string {
+	if strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
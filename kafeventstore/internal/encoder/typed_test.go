@@ -0,0 +1,351 @@
+package encoder
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jittakal/kafeventstore/pkg/event"
+	"github.com/parquet-go/parquet-go"
+)
+
+const bookIssuedJSONSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"properties": {
+		"bookId": {"type": "string"},
+		"issueDate": {"type": "string", "format": "date-time"},
+		"dueDate": {"type": "string", "format": "date"},
+		"fee": {"type": "number", "multipleOf": 0.01},
+		"renewed": {"type": ["boolean", "null"]},
+		"copies": {"type": "integer"},
+		"rating": {"type": "number"},
+		"member": {"$ref": "#/$defs/member"},
+		"tags": {"type": "array", "items": {"type": "string"}},
+		"extra": {"type": "object"}
+	},
+	"$defs": {
+		"member": {
+			"type": "object",
+			"properties": {
+				"memberId": {"type": "string"},
+				"email": {"anyOf": [{"type": "string"}, {"type": "null"}]}
+			}
+		}
+	}
+}`
+
+const bookIssuedAvroSchema = `{
+	"type": "record",
+	"name": "BookIssued",
+	"namespace": "com.library",
+	"fields": [
+		{"name": "bookId", "type": "string"},
+		{"name": "issueDate", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+		{"name": "dueDate", "type": {"type": "int", "logicalType": "date"}},
+		{"name": "fee", "type": {"type": "bytes", "logicalType": "decimal", "precision": 10, "scale": 2}},
+		{"name": "renewed", "type": ["null", "boolean"], "default": null},
+		{"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["OPEN", "CLOSED"]}},
+		{"name": "member", "type": {"type": "record", "name": "Member", "fields": [{"name": "memberId", "type": "string"}]}},
+		{"name": "previous", "type": ["null", "Member"]},
+		{"name": "attributes", "type": {"type": "map", "values": "string"}},
+		{"name": "tags", "type": {"type": "array", "items": "string"}}
+	]
+}`
+
+// columnKinds flattens a typed field to the kinds of its columns by path.
+func columnKinds(prefix string, field *typedField, kinds map[string]typedKind) map[string]typedKind {
+	switch field.kind {
+	case kindRecord:
+		for name, child := range field.fields {
+			columnKinds(prefix+"."+name, child, kinds)
+		}
+	case kindArray:
+		columnKinds(prefix+"[]", field.elem, kinds)
+	default:
+		kinds[prefix] = field.kind
+	}
+	return kinds
+}
+
+func TestTypedSchemas_AddJSONSchema(t *testing.T) {
+	typed := NewTypedSchemas()
+	if err := typed.Add("com.library.books.issued", TypedSchemaJSON, []byte(bookIssuedJSONSchema)); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	schema := typed.Lookup("com.library.books.issued")
+	if schema == nil || !typed.Has("com.library.books.issued") || typed.Has("com.library.books.returned") {
+		t.Fatal("Lookup() did not return the added schema only")
+	}
+
+	want := map[string]typedKind{
+		"data.bookId":          kindString,
+		"data.issueDate":       kindTimestamp,
+		"data.dueDate":         kindDate,
+		"data.fee":             kindDecimal,
+		"data.renewed":         kindBoolean,
+		"data.copies":          kindInt,
+		"data.rating":          kindDouble,
+		"data.member.memberId": kindString,
+		"data.member.email":    kindString,
+		"data.tags[]":          kindString,
+		"data.extra":           kindJSON,
+	}
+	if got := columnKinds("data", schema.data, map[string]typedKind{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("columns = %v, want %v", got, want)
+	}
+	if schema.data.fields["fee"].scale != 2 {
+		t.Errorf("fee scale = %d, want 2", schema.data.fields["fee"].scale)
+	}
+}
+
+func TestTypedSchemas_AddAvroSchema(t *testing.T) {
+	typed := NewTypedSchemas()
+	if err := typed.Add("com.library.books.issued", TypedSchemaAvro, []byte(bookIssuedAvroSchema)); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	schema := typed.Lookup("com.library.books.issued")
+
+	want := map[string]typedKind{
+		"data.bookId":            kindString,
+		"data.issueDate":         kindTimestamp,
+		"data.dueDate":           kindDate,
+		"data.fee":               kindDecimal,
+		"data.renewed":           kindBoolean,
+		"data.status":            kindString,
+		"data.member.memberId":   kindString,
+		"data.previous.memberId": kindString,
+		"data.attributes":        kindJSON,
+		"data.tags[]":            kindString,
+	}
+	if got := columnKinds("data", schema.data, map[string]typedKind{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("columns = %v, want %v", got, want)
+	}
+	if schema.data.fields["issueDate"].unit != time.Millisecond {
+		t.Errorf("issueDate unit = %v, want 1ms", schema.data.fields["issueDate"].unit)
+	}
+}
+
+func TestTypedSchemas_AddRecursiveSchemas(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		schema string
+	}{
+		{
+			name:   "json schema",
+			format: TypedSchemaJSON,
+			schema: `{"type": "object", "properties": {"name": {"type": "string"}, "parent": {"$ref": "#"}}}`,
+		},
+		{
+			name:   "avro",
+			format: TypedSchemaAvro,
+			schema: `{"type": "record", "name": "Node", "fields": [{"name": "name", "type": "string"}, {"name": "parent", "type": ["null", "Node"]}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			typed := NewTypedSchemas()
+			if err := typed.Add("node", tt.format, []byte(tt.schema)); err != nil {
+				t.Fatalf("Add() error = %v", err)
+			}
+			if kind := typed.Lookup("node").data.fields["name"].kind; kind != kindString {
+				t.Errorf("name kind = %v, want string", kind)
+			}
+		})
+	}
+}
+
+func TestTypedSchemas_AddErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		schema string
+	}{
+		{name: "invalid json", format: TypedSchemaJSON, schema: `{`},
+		{name: "unsupported format", format: "protobuf", schema: `{}`},
+		{name: "not an object", format: TypedSchemaJSON, schema: `{"type": "string"}`},
+		{name: "object without properties", format: TypedSchemaJSON, schema: `{"type": "object"}`},
+		{name: "avro primitive", format: TypedSchemaAvro, schema: `"string"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := NewTypedSchemas().Add("a", tt.format, []byte(tt.schema)); err == nil {
+				t.Error("Add() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestTypedField_Value(t *testing.T) {
+	decode := func(s string) any {
+		decoder := json.NewDecoder(strings.NewReader(s))
+		decoder.UseNumber()
+		var v any
+		if err := decoder.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	issued := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+	one := int64(1)
+
+	tests := []struct {
+		name  string
+		field *typedField
+		value string
+		want  any
+	}{
+		{name: "string", field: &typedField{kind: kindString}, value: `"a"`, want: "a"},
+		{name: "number as string", field: &typedField{kind: kindString}, value: `12`, want: "12"},
+		{name: "int", field: &typedField{kind: kindInt}, value: `0`, want: int64(0)},
+		{name: "fractional int", field: &typedField{kind: kindInt}, value: `1.5`, want: nil},
+		{name: "double", field: &typedField{kind: kindDouble}, value: `1.5`, want: 1.5},
+		{name: "false", field: &typedField{kind: kindBoolean}, value: `false`, want: false},
+		{name: "boolean mismatch", field: &typedField{kind: kindBoolean}, value: `"yes"`, want: nil},
+		{name: "timestamp string", field: &typedField{kind: kindTimestamp}, value: `"2026-03-01T12:30:00Z"`, want: issued},
+		{name: "timestamp millis", field: &typedField{kind: kindTimestamp, unit: time.Millisecond}, value: `1772368200000`, want: issued},
+		{name: "invalid timestamp", field: &typedField{kind: kindTimestamp}, value: `"yesterday"`, want: nil},
+		{name: "date string", field: &typedField{kind: kindDate}, value: `"1970-01-11"`, want: int32(10)},
+		{name: "date days", field: &typedField{kind: kindDate}, value: `10`, want: int32(10)},
+		{name: "decimal", field: &typedField{kind: kindDecimal, scale: 2}, value: `12.5`, want: int64(1250)},
+		{name: "decimal string", field: &typedField{kind: kindDecimal, scale: 2}, value: `"-0.07"`, want: int64(-7)},
+		{name: "decimal beyond scale", field: &typedField{kind: kindDecimal, scale: 2}, value: `0.001`, want: nil},
+		{name: "decimal beyond precision", field: &typedField{kind: kindDecimal, scale: 2}, value: `10000000000000000`, want: nil},
+		{name: "json", field: &typedField{kind: kindJSON}, value: `{"a": [1, 2]}`, want: `{"a":[1,2]}`},
+		{name: "null", field: &typedField{kind: kindString}, value: `null`, want: nil},
+		{
+			name:  "record",
+			field: &typedField{kind: kindRecord, fields: map[string]*typedField{"a": {kind: kindInt}, "b": {kind: kindBoolean}}},
+			value: `{"a": 1, "b": "x", "c": 2}`,
+			want:  map[string]any{"a": &one},
+		},
+		{
+			name:  "array",
+			field: &typedField{kind: kindArray, elem: &typedField{kind: kindInt}},
+			value: `[1, null, "x", 2]`,
+			want:  []any{int64(1), int64(2)},
+		},
+		{name: "array mismatch", field: &typedField{kind: kindArray, elem: &typedField{kind: kindInt}}, value: `{}`, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.field.value(decode(tt.value)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("value(%s) = %#v, want %#v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParquetEncoder_TypedColumns(t *testing.T) {
+	typed := NewTypedSchemas()
+	if err := typed.Add("com.library.books.issued", TypedSchemaJSON, []byte(bookIssuedJSONSchema)); err != nil {
+		t.Fatal(err)
+	}
+	enc, err := NewFactory(event.FormatParquet, "snappy", WithTypedSchemas(typed)).CreateEncoder()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+	newRecord := func(id, eventType, data string) event.Record {
+		return event.Record{
+			Event:       &event.CloudEvent{SpecVersion: "1.0", ID: id, Source: "library", Type: eventType, Time: &now, Data: []byte(data)},
+			Kafka:       event.KafkaMetadata{Topic: "books", Partition: 0, Offset: 1, Timestamp: now},
+			ProcessedAt: now,
+		}
+	}
+	data := `{"bookId":"b-1","issueDate":"2026-03-01T12:30:00Z","dueDate":"2026-03-15","fee":1.25,` +
+		`"renewed":false,"copies":0,"member":{"memberId":"m-1"},"tags":["new","classic"],"extra":{"k":"v"}}`
+	records := []event.Record{
+		newRecord("1", "com.library.books.issued", data),
+		newRecord("2", "com.library.books.issued", `{"bookId":"b-2","copies":"many"}`),
+		newRecord("3", "com.library.books.issued", `"not an object"`),
+	}
+
+	path := filepath.Join(t.TempDir(), "typed.parquet")
+	if _, err := enc.Encode(path, records); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := parquet.OpenFile(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := file.Lookup(MetaDataType); value != "com.library.books.issued" {
+		t.Errorf("metadata %s = %q", MetaDataType, value)
+	}
+	for _, column := range [][]string{{"data"}, {"id"}, {ColumnDataTyped, "issueDate"}, {ColumnDataTyped, "member", "memberId"}} {
+		if _, ok := file.Schema().Lookup(column...); !ok {
+			t.Errorf("column %v missing from schema %s", column, file.Schema())
+		}
+	}
+
+	rows := []map[string]any{{}, {}, {}}
+	reader := parquet.NewGenericReader[map[string]any](bytes.NewReader(content), file.Schema())
+	if n, _ := reader.Read(rows); n != len(records) {
+		t.Fatalf("read %d rows, want %d", n, len(records))
+	}
+
+	first := rows[0][ColumnDataTyped].(map[string]any)
+	if first["bookId"] != "b-1" || first["fee"] != int64(125) || first["renewed"] != false || first["copies"] != int64(0) || first["rating"] != nil {
+		t.Errorf("typed data = %v", first)
+	}
+	if first["member"].(map[string]any)["memberId"] != "m-1" || first["extra"] != `{"k":"v"}` {
+		t.Errorf("typed data = %v", first)
+	}
+	if !reflect.DeepEqual(first["tags"], []any{"new", "classic"}) {
+		t.Errorf("tags = %v", first["tags"])
+	}
+	if rows[0]["data"] != data || rows[0]["id"] != "1" {
+		t.Errorf("raw columns = %v, %v", rows[0]["id"], rows[0]["data"])
+	}
+
+	// Values that do not match the schema are null, and kept in the raw data
+	second := rows[1][ColumnDataTyped].(map[string]any)
+	if second["bookId"] != "b-2" || second["copies"] != nil {
+		t.Errorf("typed data = %v", second)
+	}
+	if rows[2][ColumnDataTyped] != nil || rows[2]["data"] != `"not an object"` {
+		t.Errorf("row 3 = %v", rows[2])
+	}
+}
+
+func TestParquetEncoder_TypedColumnsMixedTypes(t *testing.T) {
+	typed := NewTypedSchemas()
+	if err := typed.Add("com.library.books.issued", TypedSchemaJSON, []byte(bookIssuedJSONSchema)); err != nil {
+		t.Fatal(err)
+	}
+	enc, err := NewFactory(event.FormatParquet, "snappy", WithTypedSchemas(typed)).CreateEncoder()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	records := []event.Record{
+		{Event: &event.CloudEvent{SpecVersion: "1.0", ID: "1", Source: "s", Type: "com.library.books.issued", Data: []byte(`{}`)}, ProcessedAt: now},
+		{Event: &event.CloudEvent{SpecVersion: "1.0", ID: "2", Source: "s", Type: "com.library.books.returned", Data: []byte(`{}`)}, ProcessedAt: now},
+	}
+
+	// Batches of several types keep the plain layout
+	path := filepath.Join(t.TempDir(), "mixed.parquet")
+	if _, err := enc.Encode(path, records); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	read, err := parquet.ReadFile[CloudEventParquet](path)
+	if err != nil || len(read) != 2 {
+		t.Fatalf("ReadFile() = %d rows, %v", len(read), err)
+	}
+}
@@ -0,0 +1,263 @@
+// Package envelope implements client-side envelope encryption of written
+// files: each file is encrypted with AES-256-GCM under its own data key, and
+// the data key is stored wrapped by a KEK next to the file.
+package envelope
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// Algorithm names the encrypted file format.
+const Algorithm = "AES256-GCM-STREAM-64K"
+
+// FileSuffix is appended to the names of encrypted files.
+const FileSuffix = ".enc"
+
+// Object metadata keys holding the envelope of an encrypted file.
+const (
+	MetaAlgorithm  = "encryption_algorithm"
+	MetaKeyID      = "encryption_key_id"
+	MetaWrappedKey = "encryption_wrapped_key"
+)
+
+// SegmentSize is the plaintext size of every segment but the last.
+const SegmentSize = 64 * 1024
+
+// Encrypted files start with a header of the magic and a random nonce prefix,
+// followed by segments sealed with AES-256-GCM. Each segment's nonce is the
+// prefix followed by its big-endian index, and its additional data is the
+// header followed by a byte marking the last segment, so reordered, truncated
+// or extended files fail to decrypt.
+const (
+	magic           = "KESE"
+	noncePrefixSize = 8
+	headerSize      = len(magic) + noncePrefixSize
+	tagSize         = 16
+)
+
+// ErrNotEncrypted is returned by FromMetadata when metadata has no envelope.
+var ErrNotEncrypted = errors.New("object is not encrypted")
+
+// Envelope describes how a file was encrypted: the data key, wrapped by the
+// KEK KeyID. It holds no secret.
+type Envelope struct {
+	Algorithm  string `json:"algorithm"`
+	KeyID      string `json:"key_id"`
+	WrappedKey []byte `json:"wrapped_key"`
+}
+
+// Metadata returns the envelope as object metadata.
+func (e *Envelope) Metadata() map[string]string {
+	return map[string]string{
+		MetaAlgorithm:  e.Algorithm,
+		MetaKeyID:      e.KeyID,
+		MetaWrappedKey: base64.StdEncoding.EncodeToString(e.WrappedKey),
+	}
+}
+
+// FromMetadata returns the envelope in object metadata. Keys are matched
+// case-insensitively since some backends change their case.
+func FromMetadata(metadata map[string]string) (*Envelope, error) {
+	values := make(map[string]string, 3)
+	for key, value := range metadata {
+		switch strings.ToLower(key) {
+		case MetaAlgorithm, MetaKeyID, MetaWrappedKey:
+			values[strings.ToLower(key)] = value
+		}
+	}
+	if len(values) == 0 {
+		return nil, ErrNotEncrypted
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(values[MetaWrappedKey])
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", MetaWrappedKey, err)
+	}
+	env := &Envelope{
+		Algorithm:  values[MetaAlgorithm],
+		KeyID:      values[MetaKeyID],
+		WrappedKey: wrapped,
+	}
+	if err := env.validate(); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// validate checks that the envelope can be decrypted by this package.
+func (e *Envelope) validate() error {
+	if e.Algorithm != Algorithm {
+		return fmt.Errorf("unsupported encryption algorithm: %q", e.Algorithm)
+	}
+	if e.KeyID == "" || len(e.WrappedKey) == 0 {
+		return fmt.Errorf("envelope has no wrapped data key")
+	}
+	return nil
+}
+
+// Encryptor encrypts files with new data keys wrapped by a key provider.
+// It is safe for concurrent use.
+type Encryptor struct {
+	provider KeyProvider
+}
+
+// NewEncryptor creates an encryptor wrapping data keys with provider.
+func NewEncryptor(provider KeyProvider) *Encryptor {
+	return &Encryptor{provider: provider}
+}
+
+// Encrypt encrypts src into dst with a new data key and returns the envelope
+// needed to decrypt it.
+func (e *Encryptor) Encrypt(ctx context.Context, dst io.Writer, src io.Reader) (*Envelope, error) {
+	dataKey := make([]byte, KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	keyID, wrapped, err := e.provider.WrapKey(ctx, dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	if _, err := rand.Read(header[len(magic):]); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce prefix: %w", err)
+	}
+	if _, err := dst.Write(header); err != nil {
+		return nil, fmt.Errorf("failed to write header: %w", err)
+	}
+
+	reader := bufio.NewReaderSize(src, SegmentSize)
+	plaintext := make([]byte, SegmentSize)
+	sealed := make([]byte, 0, SegmentSize+tagSize)
+	for index := uint64(0); ; index++ {
+		if index > math.MaxUint32 {
+			return nil, fmt.Errorf("file is too large to encrypt")
+		}
+		n, err := io.ReadFull(reader, plaintext)
+		last := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !last {
+			return nil, fmt.Errorf("failed to read plaintext: %w", err)
+		}
+		if !last {
+			// A full segment is the last when nothing follows it
+			if _, err := reader.Peek(1); err == io.EOF {
+				last = true
+			} else if err != nil {
+				return nil, fmt.Errorf("failed to read plaintext: %w", err)
+			}
+		}
+
+		sealed = aead.Seal(sealed[:0], segmentNonce(header, uint32(index)), plaintext[:n], segmentData(header, last))
+		if _, err := dst.Write(sealed); err != nil {
+			return nil, fmt.Errorf("failed to write segment: %w", err)
+		}
+		if last {
+			break
+		}
+	}
+
+	return &Envelope{Algorithm: Algorithm, KeyID: keyID, WrappedKey: wrapped}, nil
+}
+
+// Decryptor decrypts files encrypted by an Encryptor.
+type Decryptor struct {
+	provider KeyProvider
+}
+
+// NewDecryptor creates a decryptor unwrapping data keys with provider.
+func NewDecryptor(provider KeyProvider) *Decryptor {
+	return &Decryptor{provider: provider}
+}
+
+// Decrypt decrypts src, encrypted under env, into dst. Segments are written as
+// they are authenticated, so dst may hold a prefix of the plaintext when the
+// file turns out to be corrupt or truncated.
+func (d *Decryptor) Decrypt(ctx context.Context, env *Envelope, dst io.Writer, src io.Reader) error {
+	if err := env.validate(); err != nil {
+		return err
+	}
+	dataKey, err := d.provider.UnwrapKey(ctx, env.KeyID, env.WrappedKey)
+	if err != nil {
+		return fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReaderSize(src, SegmentSize+tagSize)
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return fmt.Errorf("failed to read header: %w", err)
+	}
+	if !bytes.Equal(header[:len(magic)], []byte(magic)) {
+		return fmt.Errorf("file is not encrypted with %s", Algorithm)
+	}
+
+	sealed := make([]byte, SegmentSize+tagSize)
+	plaintext := make([]byte, 0, SegmentSize)
+	for index := uint64(0); ; index++ {
+		if index > math.MaxUint32 {
+			return fmt.Errorf("file has too many segments")
+		}
+		n, err := io.ReadFull(reader, sealed)
+		if err == io.EOF {
+			return fmt.Errorf("file is truncated: segment %d is missing", index)
+		}
+		last := err == io.ErrUnexpectedEOF
+		if err != nil && !last {
+			return fmt.Errorf("failed to read segment %d: %w", index, err)
+		}
+		if !last {
+			if _, err := reader.Peek(1); err == io.EOF {
+				last = true
+			} else if err != nil {
+				return fmt.Errorf("failed to read segment %d: %w", index, err)
+			}
+		}
+
+		plaintext, err = aead.Open(plaintext[:0], segmentNonce(header, uint32(index)), sealed[:n], segmentData(header, last))
+		if err != nil {
+			return fmt.Errorf("failed to decrypt segment %d: %w", index, err)
+		}
+		if _, err := dst.Write(plaintext); err != nil {
+			return fmt.Errorf("failed to write plaintext: %w", err)
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+// segmentNonce returns the nonce of a segment.
+func segmentNonce(header []byte, index uint32) []byte {
+	nonce := make([]byte, noncePrefixSize+4)
+	copy(nonce, header[len(magic):])
+	binary.BigEndian.PutUint32(nonce[noncePrefixSize:], index)
+	return nonce
+}
+
+// segmentData returns the additional authenticated data of a segment.
+func segmentData(header []byte, last bool) []byte {
+	data := make([]byte, len(header)+1)
+	copy(data, header)
+	if last {
+		data[len(header)] = 1
+	}
+	return data
+}
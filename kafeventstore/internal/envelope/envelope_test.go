@@ -0,0 +1,189 @@
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func encrypt(t *testing.T, provider KeyProvider, plaintext []byte) ([]byte, *Envelope) {
+	t.Helper()
+	var ciphertext bytes.Buffer
+	env, err := NewEncryptor(provider).Encrypt(context.Background(), &ciphertext, bytes.NewReader(plaintext))
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	return ciphertext.Bytes(), env
+}
+
+func TestEncryptor_RoundTrip(t *testing.T) {
+	provider := newTestProvider(t, testKEK)
+
+	tests := []struct {
+		name string
+		size int
+	}{
+		{name: "empty", size: 0},
+		{name: "small", size: 100},
+		{name: "one segment", size: SegmentSize},
+		{name: "segment and a byte", size: SegmentSize + 1},
+		{name: "several segments", size: 3*SegmentSize + 17},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plaintext := make([]byte, tt.size)
+			if _, err := rand.Read(plaintext); err != nil {
+				t.Fatal(err)
+			}
+
+			ciphertext, env := encrypt(t, provider, plaintext)
+			if env.Algorithm != Algorithm || env.KeyID != provider.KeyID() || len(env.WrappedKey) == 0 {
+				t.Errorf("envelope = %+v", env)
+			}
+			// Empty plaintext still has one, empty, segment
+			segments := max(1, (tt.size+SegmentSize-1)/SegmentSize)
+			if want := headerSize + tt.size + segments*tagSize; len(ciphertext) != want {
+				t.Errorf("ciphertext size = %d, want %d", len(ciphertext), want)
+			}
+			if tt.size >= 16 && bytes.Contains(ciphertext, plaintext[:16]) {
+				t.Error("ciphertext contains plaintext")
+			}
+
+			var decrypted bytes.Buffer
+			if err := NewDecryptor(provider).Decrypt(context.Background(), env, &decrypted, bytes.NewReader(ciphertext)); err != nil {
+				t.Fatalf("Decrypt() error = %v", err)
+			}
+			if !bytes.Equal(decrypted.Bytes(), plaintext) {
+				t.Error("decrypted plaintext differs")
+			}
+		})
+	}
+}
+
+func TestEncryptor_NewDataKeyPerFile(t *testing.T) {
+	provider := newTestProvider(t, testKEK)
+	plaintext := []byte("same plaintext")
+
+	first, firstEnv := encrypt(t, provider, plaintext)
+	second, secondEnv := encrypt(t, provider, plaintext)
+	if bytes.Equal(first, second) {
+		t.Error("same plaintext encrypted to the same ciphertext")
+	}
+	if bytes.Equal(firstEnv.WrappedKey, secondEnv.WrappedKey) {
+		t.Error("files share a wrapped data key")
+	}
+}
+
+func TestDecryptor_Decrypt_Errors(t *testing.T) {
+	provider := newTestProvider(t, testKEK)
+	plaintext := make([]byte, 2*SegmentSize+10)
+	ciphertext, env := encrypt(t, provider, plaintext)
+	_, otherEnv := encrypt(t, provider, plaintext)
+
+	flip := func(i int) []byte {
+		modified := append([]byte(nil), ciphertext...)
+		modified[i] ^= 1
+		return modified
+	}
+	segment := SegmentSize + tagSize
+	swapped := append([]byte(nil), ciphertext[:headerSize]...)
+	swapped = append(swapped, ciphertext[headerSize+segment:headerSize+2*segment]...)
+	swapped = append(swapped, ciphertext[headerSize:headerSize+segment]...)
+	swapped = append(swapped, ciphertext[headerSize+2*segment:]...)
+
+	tests := []struct {
+		name       string
+		env        *Envelope
+		ciphertext []byte
+		wantErr    string
+	}{
+		{name: "modified segment", env: env, ciphertext: flip(headerSize + 10), wantErr: "segment 0"},
+		{name: "modified header", env: env, ciphertext: flip(len(magic)), wantErr: "segment 0"},
+		{name: "not encrypted", env: env, ciphertext: flip(0), wantErr: "not encrypted"},
+		{name: "truncated at segment boundary", env: env, ciphertext: ciphertext[:headerSize+2*segment], wantErr: "segment 1"},
+		{name: "truncated segment", env: env, ciphertext: ciphertext[:len(ciphertext)-1], wantErr: "segment 2"},
+		{name: "header only", env: env, ciphertext: ciphertext[:headerSize], wantErr: "truncated"},
+		{name: "reordered segments", env: env, ciphertext: swapped, wantErr: "segment 0"},
+		{name: "short header", env: env, ciphertext: ciphertext[:4], wantErr: "header"},
+		{name: "other data key", env: otherEnv, ciphertext: ciphertext, wantErr: "segment 0"},
+		{name: "unsupported algorithm", env: &Envelope{Algorithm: "rot13", KeyID: env.KeyID, WrappedKey: env.WrappedKey}, ciphertext: ciphertext, wantErr: "unsupported"},
+		{name: "other key ID", env: &Envelope{Algorithm: Algorithm, KeyID: "kms:other", WrappedKey: env.WrappedKey}, ciphertext: ciphertext, wantErr: "unwrap"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewDecryptor(provider).Decrypt(context.Background(), tt.env, &bytes.Buffer{}, bytes.NewReader(tt.ciphertext))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Decrypt() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// failingProvider fails to wrap keys.
+type failingProvider struct{}
+
+func (failingProvider) WrapKey(context.Context, []byte) (string, []byte, error) {
+	return "", nil, errors.New("kms unavailable")
+}
+
+func (failingProvider) UnwrapKey(context.Context, string, []byte) ([]byte, error) {
+	return nil, errors.New("kms unavailable")
+}
+
+func TestEncryptor_Encrypt_WrapError(t *testing.T) {
+	var ciphertext bytes.Buffer
+	_, err := NewEncryptor(failingProvider{}).Encrypt(context.Background(), &ciphertext, strings.NewReader("data"))
+	if err == nil || !strings.Contains(err.Error(), "kms unavailable") {
+		t.Errorf("Encrypt() error = %v, want wrap error", err)
+	}
+	if ciphertext.Len() != 0 {
+		t.Error("Encrypt() wrote output after failing to wrap the data key")
+	}
+}
+
+func TestEnvelope_Metadata(t *testing.T) {
+	env := &Envelope{Algorithm: Algorithm, KeyID: "keyfile:0123", WrappedKey: []byte{1, 2, 3}}
+
+	got, err := FromMetadata(env.Metadata())
+	if err != nil {
+		t.Fatalf("FromMetadata() error = %v", err)
+	}
+	if got.Algorithm != env.Algorithm || got.KeyID != env.KeyID || !bytes.Equal(got.WrappedKey, env.WrappedKey) {
+		t.Errorf("FromMetadata() = %+v, want %+v", got, env)
+	}
+
+	// Backends may change the case of metadata keys
+	mixed := map[string]string{"other": "x"}
+	for key, value := range env.Metadata() {
+		mixed[strings.ToUpper(key[:1])+key[1:]] = value
+	}
+	if _, err := FromMetadata(mixed); err != nil {
+		t.Errorf("FromMetadata() of mixed-case keys error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		wantErr  error
+	}{
+		{name: "no envelope", metadata: map[string]string{"kafka_topic": "loans"}, wantErr: ErrNotEncrypted},
+		{name: "nil", metadata: nil, wantErr: ErrNotEncrypted},
+		{name: "invalid wrapped key", metadata: map[string]string{MetaAlgorithm: Algorithm, MetaKeyID: "k", MetaWrappedKey: "!"}},
+		{name: "missing key ID", metadata: map[string]string{MetaAlgorithm: Algorithm, MetaWrappedKey: "AQID"}},
+		{name: "unsupported algorithm", metadata: map[string]string{MetaAlgorithm: "rot13", MetaKeyID: "k", MetaWrappedKey: "AQID"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := FromMetadata(tt.metadata)
+			if err == nil {
+				t.Fatal("FromMetadata() succeeded, want error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("FromMetadata() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+// Package envelope implements key providers that wrap data keys with a KEK
+// read from a local keyfile.
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+)
+
+// Ensure implementation satisfies interface at compile time.
+var _ KeyProvider = (*KeyfileProvider)(nil)
+
+// KeySize is the size of data keys and keyfile KEKs: AES-256.
+const KeySize = 32
+
+// KeyProvider wraps and unwraps data keys with a KEK, such as a local keyfile
+// or a key in a key management service.
+type KeyProvider interface {
+	// WrapKey encrypts a data key and returns the ID of the KEK used.
+	WrapKey(ctx context.Context, dataKey []byte) (keyID string, wrapped []byte, err error)
+	// UnwrapKey decrypts a data key wrapped by the KEK keyID.
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// KeyfileProvider wraps data keys with AES-256-GCM under a KEK read from a
+// local file. Its key ID is derived from the KEK, so files wrapped by another
+// keyfile are detected before decryption is attempted.
+type KeyfileProvider struct {
+	keyID string
+	aead  cipher.AEAD
+}
+
+// NewKeyfileProvider creates a provider with the KEK in path. The file holds
+// 32 bytes, either raw or encoded as hex or base64.
+func NewKeyfileProvider(path string) (*KeyfileProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyfile: %w", err)
+	}
+	kek, err := parseKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid keyfile %s: %w", path, err)
+	}
+	return newKeyfileProvider(kek)
+}
+
+// newKeyfileProvider creates a provider with a KEK.
+func newKeyfileProvider(kek []byte) (*KeyfileProvider, error) {
+	aead, err := newGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint := sha256.Sum256(kek)
+	return &KeyfileProvider{
+		keyID: "keyfile:" + hex.EncodeToString(fingerprint[:8]),
+		aead:  aead,
+	}, nil
+}
+
+// parseKey decodes a 32-byte key stored raw, as hex or as base64.
+func parseKey(data []byte) ([]byte, error) {
+	if len(data) == KeySize {
+		return data, nil
+	}
+	text := string(bytes.TrimSpace(data))
+	if key, err := hex.DecodeString(text); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(text); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+	return nil, fmt.Errorf("key must be %d bytes, raw or encoded as hex or base64", KeySize)
+}
+
+// KeyID returns the ID of the KEK.
+func (p *KeyfileProvider) KeyID() string {
+	return p.keyID
+}
+
+// WrapKey encrypts a data key with the KEK. The wrapped key is the GCM nonce
+// followed by the sealed data key, authenticated with the key ID.
+func (p *KeyfileProvider) WrapKey(ctx context.Context, dataKey []byte) (string, []byte, error) {
+	nonce := make([]byte, p.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return p.keyID, p.aead.Seal(nonce, nonce, dataKey, []byte(p.keyID)), nil
+}
+
+// UnwrapKey decrypts a data key wrapped by WrapKey.
+func (p *KeyfileProvider) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	if keyID != p.keyID {
+		return nil, fmt.Errorf("data key is wrapped by %s, not by keyfile %s", keyID, p.keyID)
+	}
+	nonceSize := p.aead.NonceSize()
+	if len(wrapped) < nonceSize {
+		return nil, fmt.Errorf("wrapped key is too short")
+	}
+	dataKey, err := p.aead.Open(nil, wrapped[:nonceSize], wrapped[nonceSize:], []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	return dataKey, nil
+}
+
+// newGCM returns an AES-256-GCM cipher with key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return aead, nil
+}
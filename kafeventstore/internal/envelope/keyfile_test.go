@@ -0,0 +1,126 @@
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testKEK is a fixed 32-byte KEK.
+var testKEK = bytes.Repeat([]byte{0x42}, KeySize)
+
+func newTestProvider(t *testing.T, kek []byte) *KeyfileProvider {
+	t.Helper()
+	provider, err := newKeyfileProvider(kek)
+	if err != nil {
+		t.Fatalf("newKeyfileProvider() error = %v", err)
+	}
+	return provider
+}
+
+func TestNewKeyfileProvider(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		wantErr bool
+	}{
+		{name: "raw", content: testKEK},
+		{name: "hex", content: []byte(hex.EncodeToString(testKEK) + "\n")},
+		{name: "base64", content: []byte(base64.StdEncoding.EncodeToString(testKEK) + "\n")},
+		{name: "short key", content: []byte(hex.EncodeToString(testKEK[:20])), wantErr: true},
+		{name: "empty", content: nil, wantErr: true},
+	}
+
+	want := newTestProvider(t, testKEK).KeyID()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "kek")
+			if err := os.WriteFile(path, tt.content, 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			provider, err := NewKeyfileProvider(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewKeyfileProvider() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && provider.KeyID() != want {
+				t.Errorf("KeyID() = %s, want %s", provider.KeyID(), want)
+			}
+		})
+	}
+
+	if _, err := NewKeyfileProvider(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("NewKeyfileProvider() of a missing file succeeded")
+	}
+}
+
+func TestKeyfileProvider_WrapKey(t *testing.T) {
+	ctx := context.Background()
+	provider := newTestProvider(t, testKEK)
+	dataKey := bytes.Repeat([]byte{0x07}, KeySize)
+
+	keyID, wrapped, err := provider.WrapKey(ctx, dataKey)
+	if err != nil {
+		t.Fatalf("WrapKey() error = %v", err)
+	}
+	if !strings.HasPrefix(keyID, "keyfile:") {
+		t.Errorf("key ID = %s, want keyfile: prefix", keyID)
+	}
+	if bytes.Contains(wrapped, dataKey) {
+		t.Error("wrapped key contains the data key")
+	}
+
+	_, again, err := provider.WrapKey(ctx, dataKey)
+	if err != nil {
+		t.Fatalf("WrapKey() error = %v", err)
+	}
+	if bytes.Equal(wrapped, again) {
+		t.Error("wrapping the same key twice gave the same wrapped key")
+	}
+
+	unwrapped, err := provider.UnwrapKey(ctx, keyID, wrapped)
+	if err != nil {
+		t.Fatalf("UnwrapKey() error = %v", err)
+	}
+	if !bytes.Equal(unwrapped, dataKey) {
+		t.Errorf("UnwrapKey() = %x, want %x", unwrapped, dataKey)
+	}
+}
+
+func TestKeyfileProvider_UnwrapKey_Errors(t *testing.T) {
+	ctx := context.Background()
+	provider := newTestProvider(t, testKEK)
+	keyID, wrapped, err := provider.WrapKey(ctx, bytes.Repeat([]byte{0x07}, KeySize))
+	if err != nil {
+		t.Fatalf("WrapKey() error = %v", err)
+	}
+
+	tampered := append([]byte(nil), wrapped...)
+	tampered[len(tampered)-1] ^= 1
+
+	other := newTestProvider(t, bytes.Repeat([]byte{0x43}, KeySize))
+
+	tests := []struct {
+		name     string
+		provider *KeyfileProvider
+		keyID    string
+		wrapped  []byte
+	}{
+		{name: "other key ID", provider: provider, keyID: "keyfile:other", wrapped: wrapped},
+		{name: "other keyfile", provider: other, keyID: keyID, wrapped: wrapped},
+		{name: "tampered", provider: provider, keyID: keyID, wrapped: tampered},
+		{name: "too short", provider: provider, keyID: keyID, wrapped: wrapped[:4]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.provider.UnwrapKey(ctx, tt.keyID, tt.wrapped); err == nil {
+				t.Error("UnwrapKey() succeeded, want error")
+			}
+		})
+	}
+}
@@ -41,9 +41,19 @@ type ValidationError struct {
 	EventID string
 	Field   string
 	Reason  string
+	// Code is a short machine-readable reason, e.g. "missing" or "denied",
+	// suitable as a metric label.
+	Code string
+	// Pointer is the JSON pointer of the violating value within the event
+	// data, for schema violations.
+	Pointer string
 }
 
 func (e *ValidationError) Error() string {
+	if e.Pointer != "" {
+		return fmt.Sprintf("validation error: event_id=%s field=%s pointer=%s: %s",
+			e.EventID, e.Field, e.Pointer, e.Reason)
+	}
 	return fmt.Sprintf("validation error: event_id=%s field=%s: %s",
 		e.EventID, e.Field, e.Reason)
 }
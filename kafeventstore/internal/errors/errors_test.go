@@ -66,6 +66,11 @@ func TestValidationError(t *testing.T) {
 	if errMsg == "" {
 		t.Error("ValidationError should have error message")
 	}
+
+	err.Pointer = "/items/0/price"
+	if want := "validation error: event_id=test-123 field=source pointer=/items/0/price: required field missing"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
 }
 
 func TestStorageError(t *testing.T) {
@@ -0,0 +1,129 @@
+// Package evolution implements compatibility checks of schema changes.
+package evolution
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Compatibility is the rule a schema change must follow.
+type Compatibility string
+
+const (
+	// CompatibilityNone accepts every change.
+	CompatibilityNone Compatibility = "none"
+	// CompatibilityBackward requires the new schema to read data of the
+	// previous one: fields may be removed, and added only when optional.
+	CompatibilityBackward Compatibility = "backward"
+	// CompatibilityForward requires the previous schema to read data of the
+	// new one: fields may be added, and removed only when optional.
+	CompatibilityForward Compatibility = "forward"
+	// CompatibilityFull requires both.
+	CompatibilityFull Compatibility = "full"
+)
+
+// Valid reports whether c is a known compatibility.
+func (c Compatibility) Valid() bool {
+	switch c {
+	case CompatibilityNone, CompatibilityBackward, CompatibilityForward, CompatibilityFull:
+		return true
+	}
+	return false
+}
+
+// IncompatibleError reports a schema change that breaks the compatibility
+// rule.
+type IncompatibleError struct {
+	Compatibility Compatibility
+	Issues        []string
+}
+
+func (e *IncompatibleError) Error() string {
+	return fmt.Sprintf("schema change is not %s compatible: %s", e.Compatibility, strings.Join(e.Issues, "; "))
+}
+
+// Check checks the change from previous to next against the compatibility
+// rule, returning an *IncompatibleError if it breaks it.
+func Check(compatibility Compatibility, previous, next *Schema) error {
+	var issues []string
+	if compatibility == CompatibilityBackward || compatibility == CompatibilityFull {
+		issues = append(issues, readIssues("", next, previous)...)
+	}
+	if compatibility == CompatibilityForward || compatibility == CompatibilityFull {
+		issues = append(issues, readIssues("", previous, next)...)
+	}
+	if len(issues) > 0 {
+		return &IncompatibleError{Compatibility: compatibility, Issues: issues}
+	}
+	return nil
+}
+
+// readIssues returns why data of the writer schema cannot be read with the
+// reader schema. Ints are read as doubles, and anything as any; only null
+// values carry no type.
+func readIssues(path string, reader, writer *Schema) []string {
+	switch {
+	case reader.Type == TypeAny, reader.Type == TypeNull, writer.Type == TypeNull:
+		return nil
+	case reader.Type == TypeDouble && writer.Type == TypeInt:
+		return nil
+	case reader.Type != writer.Type:
+		return []string{fmt.Sprintf("%s: %s cannot be read as %s", displayPath(path), writer.Type, reader.Type)}
+	case reader.Type == TypeArray:
+		return readIssues(path+"[]", reader.Items, writer.Items)
+	case reader.Type != TypeRecord:
+		return nil
+	}
+
+	var issues []string
+	for i := range reader.Fields {
+		field := &reader.Fields[i]
+		fieldPath := path + "/" + field.Name
+		written := writer.field(field.Name)
+		if written == nil {
+			if field.Required {
+				issues = append(issues, fmt.Sprintf("%s: required field is missing", fieldPath))
+			}
+			continue
+		}
+		if field.Required && !written.Required {
+			issues = append(issues, fmt.Sprintf("%s: required field is optional", fieldPath))
+		}
+		issues = append(issues, readIssues(fieldPath, &field.Schema, &written.Schema)...)
+	}
+	return issues
+}
+
+// typeChanges returns the values of next whose type differs from previous,
+// other than between numbers. Only values both schemas type are compared.
+func typeChanges(path string, previous, next *Schema) []string {
+	switch {
+	case previous.Type == TypeAny, previous.Type == TypeNull, next.Type == TypeNull:
+		return nil
+	case isNumber(previous.Type) && isNumber(next.Type):
+		return nil
+	case previous.Type != next.Type:
+		return []string{fmt.Sprintf("%s: %s changed to %s", displayPath(path), previous.Type, next.Type)}
+	case previous.Type == TypeArray:
+		return typeChanges(path+"[]", previous.Items, next.Items)
+	case previous.Type != TypeRecord:
+		return nil
+	}
+
+	var issues []string
+	for i := range next.Fields {
+		field := &next.Fields[i]
+		if before := previous.field(field.Name); before != nil {
+			issues = append(issues, typeChanges(path+"/"+field.Name, &before.Schema, &field.Schema)...)
+		}
+	}
+	return issues
+}
+
+// displayPath returns the JSON pointer of the data root for an empty path.
+func displayPath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
@@ -0,0 +1,140 @@
+package evolution
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	base := &Schema{Type: TypeRecord, Fields: []Field{
+		{Name: "id", Required: true, Schema: Schema{Type: TypeString}},
+		{Name: "total", Required: true, Schema: Schema{Type: TypeInt}},
+		{Name: "note", Schema: Schema{Type: TypeString}},
+	}}
+	withFields := func(fields ...Field) *Schema {
+		return &Schema{Type: TypeRecord, Fields: fields}
+	}
+	id := Field{Name: "id", Required: true, Schema: Schema{Type: TypeString}}
+	total := Field{Name: "total", Required: true, Schema: Schema{Type: TypeInt}}
+	note := Field{Name: "note", Schema: Schema{Type: TypeString}}
+
+	tests := []struct {
+		name string
+		next *Schema
+		// compatible changes per rule
+		backward bool
+		forward  bool
+	}{
+		{name: "unchanged", next: base, backward: true, forward: true},
+		{
+			name:     "optional field added",
+			next:     withFields(id, note, total, Field{Name: "tax", Schema: Schema{Type: TypeInt}}),
+			backward: true, forward: true,
+		},
+		{
+			name:     "required field added",
+			next:     withFields(id, note, total, Field{Name: "tax", Required: true, Schema: Schema{Type: TypeInt}}),
+			backward: false, forward: true,
+		},
+		{name: "optional field removed", next: withFields(id, total), backward: true, forward: true},
+		{name: "required field removed", next: withFields(id, note), backward: true, forward: false},
+		{
+			name:     "required field made optional",
+			next:     withFields(id, note, Field{Name: "total", Schema: Schema{Type: TypeInt}}),
+			backward: true, forward: false,
+		},
+		{
+			name:     "int widened to double",
+			next:     withFields(id, note, Field{Name: "total", Required: true, Schema: Schema{Type: TypeDouble}}),
+			backward: true, forward: false,
+		},
+		{
+			name:     "type changed",
+			next:     withFields(id, note, Field{Name: "total", Required: true, Schema: Schema{Type: TypeString}}),
+			backward: false, forward: false,
+		},
+		{
+			name:     "type changed to any",
+			next:     withFields(id, note, Field{Name: "total", Required: true, Schema: Schema{Type: TypeAny}}),
+			backward: true, forward: false,
+		},
+		{name: "not a record", next: &Schema{Type: TypeString}, backward: false, forward: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Check(CompatibilityNone, base, tt.next); err != nil {
+				t.Errorf("Check(none) error = %v", err)
+			}
+			if err := Check(CompatibilityBackward, base, tt.next); (err == nil) != tt.backward {
+				t.Errorf("Check(backward) error = %v, want compatible %v", err, tt.backward)
+			}
+			if err := Check(CompatibilityForward, base, tt.next); (err == nil) != tt.forward {
+				t.Errorf("Check(forward) error = %v, want compatible %v", err, tt.forward)
+			}
+			if err := Check(CompatibilityFull, base, tt.next); (err == nil) != (tt.backward && tt.forward) {
+				t.Errorf("Check(full) error = %v, want compatible %v", err, tt.backward && tt.forward)
+			}
+		})
+	}
+}
+
+func TestCheck_Issues(t *testing.T) {
+	previous := mustInfer(t, `{"order":{"total":1,"lines":[{"sku":"a"}]}}`)
+	next := mustInfer(t, `{"order":{"total":"1","lines":[{"sku":1}]}}`)
+
+	err := Check(CompatibilityBackward, previous, next)
+	var incompatible *IncompatibleError
+	if !errors.As(err, &incompatible) {
+		t.Fatalf("Check() error = %v, want *IncompatibleError", err)
+	}
+	want := []string{
+		"/order/lines[]/sku: string cannot be read as int",
+		"/order/total: int cannot be read as string",
+	}
+	if len(incompatible.Issues) != len(want) {
+		t.Fatalf("Issues = %v, want %v", incompatible.Issues, want)
+	}
+	for i := range want {
+		if incompatible.Issues[i] != want[i] {
+			t.Errorf("Issues[%d] = %s, want %s", i, incompatible.Issues[i], want[i])
+		}
+	}
+}
+
+func TestCompatibility_Valid(t *testing.T) {
+	for _, c := range []Compatibility{CompatibilityNone, CompatibilityBackward, CompatibilityForward, CompatibilityFull} {
+		if !c.Valid() {
+			t.Errorf("%s.Valid() = false", c)
+		}
+	}
+	if Compatibility("transitive").Valid() {
+		t.Error("transitive.Valid() = true")
+	}
+}
+
+func TestTypeChanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous string
+		next     string
+		want     []string
+	}{
+		{name: "same types", previous: `{"a":1,"b":"x"}`, next: `{"a":2,"c":true}`},
+		{name: "numbers", previous: `{"a":1.5}`, next: `{"a":2}`},
+		{name: "null values", previous: `{"a":null,"b":"x"}`, next: `{"a":1,"b":null}`},
+		{name: "changed field", previous: `{"a":{"b":1}}`, next: `{"a":{"b":"1"}}`, want: []string{"/a/b: int changed to string"}},
+		{name: "changed items", previous: `{"a":[1]}`, next: `{"a":[true]}`, want: []string{"/a[]: int changed to boolean"}},
+		{name: "changed root", previous: `{"a":1}`, next: `[1]`, want: []string{"/: record changed to array"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := typeChanges("", mustInfer(t, tt.previous), mustInfer(t, tt.next))
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("typeChanges() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
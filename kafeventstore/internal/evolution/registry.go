@@ -0,0 +1,351 @@
+// Package evolution implements conversion of Schema Registry schemas.
+package evolution
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jittakal/kafeventstore/internal/confluent"
+	"github.com/jittakal/kafeventstore/internal/schema"
+)
+
+// FromRegistry returns the schema of the data decoded from a Schema Registry
+// schema. Avro and JSON schemas are converted; Protobuf schemas are not, and
+// yield ok false so the schema is inferred from the data instead.
+func FromRegistry(registered *confluent.Schema) (schema *Schema, ok bool, err error) {
+	switch registered.Type() {
+	case confluent.SchemaTypeAvro:
+		schema, err = FromAvro([]byte(registered.Schema))
+	case confluent.SchemaTypeJSON:
+		schema, err = FromJSONSchema([]byte(registered.Schema))
+	default:
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return schema, true, nil
+}
+
+// FromAvro returns the schema of the standard JSON encoding of Avro data,
+// where unions are written as their value. Fields are required unless they
+// are nullable or have a default. Maps, unions of several types and
+// recursive references are any.
+func FromAvro(avroSchema []byte) (*Schema, error) {
+	var definition any
+	if err := json.Unmarshal(avroSchema, &definition); err != nil {
+		return nil, fmt.Errorf("failed to parse avro schema: %w", err)
+	}
+	converter := &avroConverter{named: make(map[string]*Schema)}
+	schema, _, err := converter.convert(definition, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// avroConverter converts Avro schemas, resolving named types.
+type avroConverter struct {
+	named map[string]*Schema // nil while a type is being defined
+}
+
+// convert returns the schema of an Avro type and whether it is nullable.
+func (c *avroConverter) convert(definition any, namespace string, depth int) (*Schema, bool, error) {
+	if depth > maxDepth {
+		return &Schema{Type: TypeAny}, false, nil
+	}
+
+	switch def := definition.(type) {
+	case string:
+		switch def {
+		case "null":
+			return &Schema{Type: TypeNull}, true, nil
+		case "boolean":
+			return &Schema{Type: TypeBoolean}, false, nil
+		case "int", "long":
+			return &Schema{Type: TypeInt}, false, nil
+		case "float", "double":
+			return &Schema{Type: TypeDouble}, false, nil
+		case "bytes", "string":
+			return &Schema{Type: TypeString}, false, nil
+		}
+		name := avroFullName(def, namespace)
+		schema, ok := c.named[name]
+		if !ok {
+			return nil, false, fmt.Errorf("unknown avro type %s", def)
+		}
+		if schema == nil {
+			// Recursive reference to a type being defined
+			return &Schema{Type: TypeAny}, false, nil
+		}
+		return schema, false, nil
+	case []any:
+		var types []*Schema
+		nullable := false
+		for _, branch := range def {
+			schema, branchNullable, err := c.convert(branch, namespace, depth+1)
+			if err != nil {
+				return nil, false, err
+			}
+			if schema.Type == TypeNull {
+				nullable = true
+				continue
+			}
+			nullable = nullable || branchNullable
+			types = append(types, schema)
+		}
+		switch len(types) {
+		case 0:
+			return &Schema{Type: TypeNull}, true, nil
+		case 1:
+			return types[0], nullable, nil
+		default:
+			return &Schema{Type: TypeAny}, nullable, nil
+		}
+	case map[string]any:
+		typeName, _ := def["type"].(string)
+		switch typeName {
+		case "record", "error":
+			return c.record(def, namespace, depth)
+		case "enum", "fixed":
+			if name, _ := def["name"].(string); name != "" {
+				c.named[avroFullName(name, avroNamespace(def, namespace))] = &Schema{Type: TypeString}
+			}
+			return &Schema{Type: TypeString}, false, nil
+		case "array":
+			items, _, err := c.convert(def["items"], namespace, depth+1)
+			if err != nil {
+				return nil, false, err
+			}
+			return &Schema{Type: TypeArray, Items: items}, false, nil
+		case "map":
+			if _, _, err := c.convert(def["values"], namespace, depth+1); err != nil {
+				return nil, false, err
+			}
+			return &Schema{Type: TypeAny}, false, nil
+		default:
+			// Primitive types, possibly with a logical type
+			return c.convert(def["type"], namespace, depth+1)
+		}
+	default:
+		return nil, false, fmt.Errorf("invalid avro type %v", definition)
+	}
+}
+
+// record converts an Avro record, defining its name first so fields can
+// refer to it.
+func (c *avroConverter) record(def map[string]any, namespace string, depth int) (*Schema, bool, error) {
+	name, _ := def["name"].(string)
+	if name == "" {
+		return nil, false, fmt.Errorf("avro record without a name")
+	}
+	namespace = avroNamespace(def, namespace)
+	fullName := avroFullName(name, namespace)
+	c.named[fullName] = nil
+
+	fields, _ := def["fields"].([]any)
+	schema := &Schema{Type: TypeRecord, Fields: make([]Field, 0, len(fields))}
+	for _, f := range fields {
+		field, _ := f.(map[string]any)
+		fieldName, _ := field["name"].(string)
+		if fieldName == "" {
+			return nil, false, fmt.Errorf("avro record %s has a field without a name", fullName)
+		}
+		fieldSchema, nullable, err := c.convert(field["type"], namespace, depth+1)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to convert field %s of %s: %w", fieldName, fullName, err)
+		}
+		_, hasDefault := field["default"]
+		schema.Fields = append(schema.Fields, Field{
+			Name:     fieldName,
+			Required: !nullable && !hasDefault,
+			Schema:   *fieldSchema,
+		})
+	}
+	sortFields(schema.Fields)
+	c.named[fullName] = schema
+	return schema, false, nil
+}
+
+// avroNamespace returns the namespace of a named type definition.
+func avroNamespace(def map[string]any, namespace string) string {
+	if ns, ok := def["namespace"].(string); ok {
+		return ns
+	}
+	if name, _ := def["name"].(string); strings.Contains(name, ".") {
+		return name[:strings.LastIndex(name, ".")]
+	}
+	return namespace
+}
+
+// avroFullName returns the full name of a type name in namespace.
+func avroFullName(name, namespace string) string {
+	if strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
+
+// FromJSONSchema returns the schema of data described by a JSON Schema.
+// Properties are required when listed in required and not nullable. Objects
+// without properties, unions of several types and recursive references are
+// any.
+func FromJSONSchema(jsonSchema []byte) (*Schema, error) {
+	var root any
+	if err := json.Unmarshal(jsonSchema, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse json schema: %w", err)
+	}
+	converter := &jsonSchemaConverter{root: root, active: make(map[string]bool)}
+	schema, _, err := converter.convert(root, 0)
+	if err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// jsonSchemaConverter converts JSON Schemas, resolving local references.
+type jsonSchemaConverter struct {
+	root   any
+	active map[string]bool // references being converted
+}
+
+// convert returns the schema of a JSON Schema and whether it allows null.
+func (c *jsonSchemaConverter) convert(node any, depth int) (*Schema, bool, error) {
+	if depth > maxDepth {
+		return &Schema{Type: TypeAny}, false, nil
+	}
+	def, ok := node.(map[string]any)
+	if !ok {
+		// true, false and invalid schemas constrain nothing usable
+		return &Schema{Type: TypeAny}, false, nil
+	}
+
+	if ref, ok := def["$ref"].(string); ok {
+		if c.active[ref] {
+			// Recursive reference
+			return &Schema{Type: TypeAny}, false, nil
+		}
+		target, err := schema.ResolveRef(c.root, ref)
+		if err != nil {
+			return nil, false, err
+		}
+		c.active[ref] = true
+		defer delete(c.active, ref)
+		return c.convert(target, depth+1)
+	}
+	for _, keyword := range []string{"anyOf", "oneOf"} {
+		if branches, ok := def[keyword].([]any); ok {
+			return c.union(branches, depth)
+		}
+	}
+
+	var types []string
+	switch t := def["type"].(type) {
+	case string:
+		types = []string{t}
+	case []any:
+		for _, name := range t {
+			if s, ok := name.(string); ok {
+				types = append(types, s)
+			}
+		}
+	default:
+		// Untyped schemas are typed by their keywords
+		if _, ok := def["properties"]; ok {
+			types = []string{"object"}
+		} else if _, ok := def["items"]; ok {
+			types = []string{"array"}
+		}
+	}
+
+	nullable := false
+	var nonNull []string
+	for _, t := range types {
+		if t == "null" {
+			nullable = true
+		} else {
+			nonNull = append(nonNull, t)
+		}
+	}
+	if len(nonNull) == 2 && ((nonNull[0] == "integer" && nonNull[1] == "number") || (nonNull[0] == "number" && nonNull[1] == "integer")) {
+		nonNull = []string{"number"}
+	}
+	if len(nonNull) != 1 {
+		if len(nonNull) == 0 && nullable {
+			return &Schema{Type: TypeNull}, true, nil
+		}
+		return &Schema{Type: TypeAny}, nullable, nil
+	}
+
+	switch nonNull[0] {
+	case "boolean":
+		return &Schema{Type: TypeBoolean}, nullable, nil
+	case "integer":
+		return &Schema{Type: TypeInt}, nullable, nil
+	case "number":
+		return &Schema{Type: TypeDouble}, nullable, nil
+	case "string":
+		return &Schema{Type: TypeString}, nullable, nil
+	case "array":
+		items, _, err := c.convert(def["items"], depth+1)
+		if err != nil {
+			return nil, false, err
+		}
+		return &Schema{Type: TypeArray, Items: items}, nullable, nil
+	case "object":
+		properties, ok := def["properties"].(map[string]any)
+		if !ok || len(properties) == 0 {
+			return &Schema{Type: TypeAny}, nullable, nil
+		}
+		required := make(map[string]bool)
+		if names, ok := def["required"].([]any); ok {
+			for _, name := range names {
+				if s, ok := name.(string); ok {
+					required[s] = true
+				}
+			}
+		}
+		schema := &Schema{Type: TypeRecord, Fields: make([]Field, 0, len(properties))}
+		for name, property := range properties {
+			fieldSchema, fieldNullable, err := c.convert(property, depth+1)
+			if err != nil {
+				return nil, false, fmt.Errorf("failed to convert property %s: %w", name, err)
+			}
+			schema.Fields = append(schema.Fields, Field{
+				Name:     name,
+				Required: required[name] && !fieldNullable,
+				Schema:   *fieldSchema,
+			})
+		}
+		sortFields(schema.Fields)
+		return schema, nullable, nil
+	default:
+		return &Schema{Type: TypeAny}, nullable, nil
+	}
+}
+
+// union converts anyOf and oneOf branches; a single branch besides null
+// keeps its type.
+func (c *jsonSchemaConverter) union(branches []any, depth int) (*Schema, bool, error) {
+	var types []*Schema
+	nullable := false
+	for _, branch := range branches {
+		schema, branchNullable, err := c.convert(branch, depth+1)
+		if err != nil {
+			return nil, false, err
+		}
+		nullable = nullable || branchNullable
+		if schema.Type != TypeNull {
+			types = append(types, schema)
+		}
+	}
+	switch len(types) {
+	case 0:
+		return &Schema{Type: TypeNull}, true, nil
+	case 1:
+		return types[0], nullable, nil
+	default:
+		return &Schema{Type: TypeAny}, nullable, nil
+	}
+}
@@ -0,0 +1,154 @@
+package evolution
+
+import (
+	"testing"
+
+	"github.com/jittakal/kafeventstore/internal/confluent"
+)
+
+func TestFromAvro(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		want   string
+	}{
+		{name: "primitive", schema: `"long"`, want: `{"type":"int"}`},
+		{
+			name: "record",
+			schema: `{"type":"record","name":"Order","namespace":"com.example","fields":[
+				{"name":"id","type":"string"},
+				{"name":"total","type":{"type":"bytes","logicalType":"decimal","precision":10,"scale":2}},
+				{"name":"created","type":{"type":"long","logicalType":"timestamp-millis"}},
+				{"name":"note","type":["null","string"],"default":null},
+				{"name":"channel","type":"string","default":"web"},
+				{"name":"status","type":{"type":"enum","name":"Status","symbols":["OPEN","CLOSED"]}},
+				{"name":"previous","type":"Status"},
+				{"name":"lines","type":{"type":"array","items":{"type":"record","name":"Line","fields":[{"name":"qty","type":"int"}]}}},
+				{"name":"attributes","type":{"type":"map","values":"string"}},
+				{"name":"parent","type":["null","Order"]}
+			]}`,
+			want: `{"type":"record","fields":[` +
+				`{"name":"attributes","required":true,"type":"any"},` +
+				`{"name":"channel","type":"string"},` +
+				`{"name":"created","required":true,"type":"int"},` +
+				`{"name":"id","required":true,"type":"string"},` +
+				`{"name":"lines","required":true,"type":"array","items":{"type":"record","fields":[{"name":"qty","required":true,"type":"int"}]}},` +
+				`{"name":"note","type":"string"},` +
+				`{"name":"parent","type":"any"},` +
+				`{"name":"previous","required":true,"type":"string"},` +
+				`{"name":"status","required":true,"type":"string"},` +
+				`{"name":"total","required":true,"type":"string"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := FromAvro([]byte(tt.schema))
+			if err != nil {
+				t.Fatalf("FromAvro() error = %v", err)
+			}
+			if got := schemaJSON(t, schema); got != tt.want {
+				t.Errorf("FromAvro() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromAvro_Errors(t *testing.T) {
+	for _, schema := range []string{`{`, `"Unknown"`, `{"type":"record","fields":[]}`, `42`} {
+		if _, err := FromAvro([]byte(schema)); err == nil {
+			t.Errorf("FromAvro(%s) error = nil, want error", schema)
+		}
+	}
+}
+
+func TestFromJSONSchema(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		want   string
+	}{
+		{name: "integer", schema: `{"type":"integer"}`, want: `{"type":"int"}`},
+		{name: "integer or number", schema: `{"type":["integer","number"]}`, want: `{"type":"double"}`},
+		{name: "free-form object", schema: `{"type":"object"}`, want: `{"type":"any"}`},
+		{name: "no type", schema: `{}`, want: `{"type":"any"}`},
+		{
+			name: "object",
+			schema: `{
+				"type": "object",
+				"required": ["id", "total", "note"],
+				"properties": {
+					"id": {"type": "string"},
+					"total": {"type": "number"},
+					"note": {"type": ["string", "null"]},
+					"customer": {"$ref": "#/$defs/customer"},
+					"tags": {"type": "array", "items": {"type": "string"}},
+					"status": {"oneOf": [{"type": "string"}, {"type": "null"}]},
+					"parent": {"$ref": "#"}
+				},
+				"$defs": {
+					"customer": {"properties": {"id": {"type": "string"}}, "required": ["id"]}
+				}
+			}`,
+			want: `{"type":"record","fields":[` +
+				`{"name":"customer","type":"record","fields":[{"name":"id","required":true,"type":"string"}]},` +
+				`{"name":"id","required":true,"type":"string"},` +
+				`{"name":"note","type":"string"},` +
+				`{"name":"parent","type":"record","fields":[` +
+				`{"name":"customer","type":"record","fields":[{"name":"id","required":true,"type":"string"}]},` +
+				`{"name":"id","required":true,"type":"string"},` +
+				`{"name":"note","type":"string"},` +
+				`{"name":"parent","type":"any"},` +
+				`{"name":"status","type":"string"},` +
+				`{"name":"tags","type":"array","items":{"type":"string"}},` +
+				`{"name":"total","required":true,"type":"double"}]},` +
+				`{"name":"status","type":"string"},` +
+				`{"name":"tags","type":"array","items":{"type":"string"}},` +
+				`{"name":"total","required":true,"type":"double"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := FromJSONSchema([]byte(tt.schema))
+			if err != nil {
+				t.Fatalf("FromJSONSchema() error = %v", err)
+			}
+			if got := schemaJSON(t, schema); got != tt.want {
+				t.Errorf("FromJSONSchema() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromJSONSchema_Errors(t *testing.T) {
+	for _, schema := range []string{`{`, `{"$ref":"#/$defs/missing"}`, `{"$ref":"https://example.com/schema.json"}`} {
+		if _, err := FromJSONSchema([]byte(schema)); err == nil {
+			t.Errorf("FromJSONSchema(%s) error = nil, want error", schema)
+		}
+	}
+}
+
+func TestFromRegistry(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema confluent.Schema
+		wantOK bool
+	}{
+		{name: "avro by default", schema: confluent.Schema{Schema: `"string"`}, wantOK: true},
+		{name: "json", schema: confluent.Schema{SchemaType: confluent.SchemaTypeJSON, Schema: `{"type":"string"}`}, wantOK: true},
+		{name: "protobuf", schema: confluent.Schema{SchemaType: confluent.SchemaTypeProtobuf, Schema: `syntax = "proto3";`}, wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, ok, err := FromRegistry(&tt.schema)
+			if err != nil {
+				t.Fatalf("FromRegistry() error = %v", err)
+			}
+			if ok != tt.wantOK || (ok && schema.Type != TypeString) {
+				t.Errorf("FromRegistry() = %v, %v, want ok %v", schema, ok, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+// Package evolution tracks the schemas of event data per topic and type,
+// checks schema changes against compatibility rules and keeps their history
+// on the storage backend.
+package evolution
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+// Type is the type of a schema node.
+type Type string
+
+const (
+	TypeNull    Type = "null" // only null values seen; reads and merges as any type
+	TypeBoolean Type = "boolean"
+	TypeInt     Type = "int"
+	TypeDouble  Type = "double"
+	TypeString  Type = "string"
+	TypeRecord  Type = "record"
+	TypeArray   Type = "array"
+	TypeAny     Type = "any" // mixed or unconstrained values
+)
+
+// maxDepth limits the nesting of schemas; deeper values are any.
+const maxDepth = 32
+
+// Schema is the shape of event data: a type, the fields of records and the
+// items of arrays.
+type Schema struct {
+	Type   Type    `json:"type"`
+	Fields []Field `json:"fields,omitempty"` // records, sorted by name
+	Items  *Schema `json:"items,omitempty"`  // arrays
+}
+
+// Field is a named record field.
+type Field struct {
+	Name string `json:"name"`
+	// Required fields are present and not null in all data of the schema
+	Required bool `json:"required,omitempty"`
+	Schema
+}
+
+// field returns the field called name, or nil.
+func (s *Schema) field(name string) *Field {
+	i := sort.Search(len(s.Fields), func(i int) bool { return s.Fields[i].Name >= name })
+	if i < len(s.Fields) && s.Fields[i].Name == name {
+		return &s.Fields[i]
+	}
+	return nil
+}
+
+// Equal reports whether two schemas are the same.
+func (s *Schema) Equal(other *Schema) bool {
+	a, errA := json.Marshal(s)
+	b, errB := json.Marshal(other)
+	return errA == nil && errB == nil && bytes.Equal(a, b)
+}
+
+// Infer returns the schema of JSON event data. Since a batch only shows the
+// fields its events carry, inferred fields are never required.
+func Infer(data []byte) (*Schema, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return inferValue(value, 0), nil
+}
+
+// inferValue returns the schema of a JSON value decoded with UseNumber.
+func inferValue(value any, depth int) *Schema {
+	if depth > maxDepth {
+		return &Schema{Type: TypeAny}
+	}
+	switch v := value.(type) {
+	case nil:
+		return &Schema{Type: TypeNull}
+	case bool:
+		return &Schema{Type: TypeBoolean}
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return &Schema{Type: TypeInt}
+		}
+		return &Schema{Type: TypeDouble}
+	case string:
+		return &Schema{Type: TypeString}
+	case []any:
+		var items *Schema
+		for _, item := range v {
+			items = Merge(items, inferValue(item, depth+1))
+		}
+		if items == nil {
+			items = &Schema{Type: TypeNull}
+		}
+		return &Schema{Type: TypeArray, Items: items}
+	case map[string]any:
+		schema := &Schema{Type: TypeRecord, Fields: make([]Field, 0, len(v))}
+		for name, field := range v {
+			schema.Fields = append(schema.Fields, Field{Name: name, Schema: *inferValue(field, depth+1)})
+		}
+		sortFields(schema.Fields)
+		return schema
+	default:
+		return &Schema{Type: TypeAny}
+	}
+}
+
+// Merge returns the schema of data of either schema: the union of record
+// fields, which are required only when required in both, with int widened to
+// double and other conflicting types to any. Either schema may be nil.
+func Merge(a, b *Schema) *Schema {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case a.Type == TypeNull:
+		return b
+	case b.Type == TypeNull:
+		return a
+	}
+
+	switch {
+	case a.Type == TypeRecord && b.Type == TypeRecord:
+		merged := &Schema{Type: TypeRecord}
+		for _, field := range a.Fields {
+			if other := b.field(field.Name); other != nil {
+				field.Schema = *Merge(&field.Schema, &other.Schema)
+				field.Required = field.Required && other.Required
+			} else {
+				field.Required = false
+			}
+			merged.Fields = append(merged.Fields, field)
+		}
+		for _, field := range b.Fields {
+			if a.field(field.Name) == nil {
+				field.Required = false
+				merged.Fields = append(merged.Fields, field)
+			}
+		}
+		sortFields(merged.Fields)
+		return merged
+	case a.Type == TypeArray && b.Type == TypeArray:
+		return &Schema{Type: TypeArray, Items: Merge(a.Items, b.Items)}
+	case a.Type == b.Type:
+		return a
+	case isNumber(a.Type) && isNumber(b.Type):
+		return &Schema{Type: TypeDouble}
+	default:
+		return &Schema{Type: TypeAny}
+	}
+}
+
+// isNumber reports whether t is a numeric type.
+func isNumber(t Type) bool {
+	return t == TypeInt || t == TypeDouble
+}
+
+// sortFields sorts fields by name.
+func sortFields(fields []Field) {
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Name < fields[j].Name })
+}
@@ -0,0 +1,124 @@
+package evolution
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// mustInfer infers the schema of JSON data.
+func mustInfer(t *testing.T, data string) *Schema {
+	t.Helper()
+	schema, err := Infer([]byte(data))
+	if err != nil {
+		t.Fatalf("Infer(%s) error = %v", data, err)
+	}
+	return schema
+}
+
+// schemaJSON returns the JSON encoding of a schema.
+func schemaJSON(t *testing.T, schema *Schema) string {
+	t.Helper()
+	data, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestInfer(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "string", data: `"a"`, want: `{"type":"string"}`},
+		{name: "int", data: `12`, want: `{"type":"int"}`},
+		{name: "double", data: `1.5`, want: `{"type":"double"}`},
+		{name: "null", data: `null`, want: `{"type":"null"}`},
+		{
+			name: "record",
+			data: `{"id":"o-1","total":10,"paid":true,"note":null}`,
+			want: `{"type":"record","fields":[{"name":"id","type":"string"},{"name":"note","type":"null"},{"name":"paid","type":"boolean"},{"name":"total","type":"int"}]}`,
+		},
+		{
+			name: "array of mixed numbers",
+			data: `[1, 2.5, null]`,
+			want: `{"type":"array","items":{"type":"double"}}`,
+		},
+		{name: "empty array", data: `[]`, want: `{"type":"array","items":{"type":"null"}}`},
+		{name: "mixed array", data: `[1, "a"]`, want: `{"type":"array","items":{"type":"any"}}`},
+		{
+			name: "nested records",
+			data: `{"customer":{"id":"c-1"},"lines":[{"sku":"a"},{"qty":2}]}`,
+			want: `{"type":"record","fields":[{"name":"customer","type":"record","fields":[{"name":"id","type":"string"}]},` +
+				`{"name":"lines","type":"array","items":{"type":"record","fields":[{"name":"qty","type":"int"},{"name":"sku","type":"string"}]}}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := schemaJSON(t, mustInfer(t, tt.data)); got != tt.want {
+				t.Errorf("Infer() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Infer([]byte(`{`)); err == nil {
+		t.Error("Infer() error = nil, want error for invalid JSON")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	required := &Schema{Type: TypeRecord, Fields: []Field{
+		{Name: "id", Required: true, Schema: Schema{Type: TypeString}},
+		{Name: "total", Required: true, Schema: Schema{Type: TypeInt}},
+	}}
+
+	tests := []struct {
+		name string
+		a    *Schema
+		b    *Schema
+		want string
+	}{
+		{name: "nil", a: nil, b: mustInfer(t, `1`), want: `{"type":"int"}`},
+		{name: "null", a: mustInfer(t, `null`), b: mustInfer(t, `"a"`), want: `{"type":"string"}`},
+		{name: "numbers widen", a: mustInfer(t, `1`), b: mustInfer(t, `1.5`), want: `{"type":"double"}`},
+		{name: "conflicts are any", a: mustInfer(t, `1`), b: mustInfer(t, `"1"`), want: `{"type":"any"}`},
+		{
+			name: "union of fields",
+			a:    mustInfer(t, `{"id":"a","total":1}`),
+			b:    mustInfer(t, `{"id":"b","note":"x"}`),
+			want: `{"type":"record","fields":[{"name":"id","type":"string"},{"name":"note","type":"string"},{"name":"total","type":"int"}]}`,
+		},
+		{
+			name: "fields missing from one schema become optional",
+			a:    required,
+			b:    &Schema{Type: TypeRecord, Fields: []Field{{Name: "id", Required: true, Schema: Schema{Type: TypeString}}}},
+			want: `{"type":"record","fields":[{"name":"id","required":true,"type":"string"},{"name":"total","type":"int"}]}`,
+		},
+		{
+			name: "array items",
+			a:    mustInfer(t, `[{"a":1}]`),
+			b:    mustInfer(t, `[{"b":true}]`),
+			want: `{"type":"array","items":{"type":"record","fields":[{"name":"a","type":"int"},{"name":"b","type":"boolean"}]}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := schemaJSON(t, Merge(tt.a, tt.b)); got != tt.want {
+				t.Errorf("Merge() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchema_Equal(t *testing.T) {
+	a := mustInfer(t, `{"id":"a","total":1}`)
+	if !a.Equal(mustInfer(t, `{"total":2,"id":"b"}`)) {
+		t.Error("Equal() = false for schemas of the same shape")
+	}
+	if a.Equal(mustInfer(t, `{"id":"a","total":1.5}`)) {
+		t.Error("Equal() = true for schemas of different shapes")
+	}
+}
@@ -0,0 +1,536 @@
+// Package evolution implements the schema history tracker.
+package evolution
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jittakal/kafeventstore/internal/confluent"
+	"github.com/jittakal/kafeventstore/pkg/event"
+	"github.com/jittakal/kafeventstore/pkg/storage"
+)
+
+// Action is what happens to events whose schema breaks the compatibility
+// rule.
+type Action string
+
+const (
+	// ActionVersion starts a new schema epoch, written under a new path
+	// version.
+	ActionVersion Action = "version"
+	// ActionReject rejects the events.
+	ActionReject Action = "reject"
+)
+
+// Valid reports whether a is a known action.
+func (a Action) Valid() bool {
+	return a == ActionVersion || a == ActionReject
+}
+
+// Schema sources recorded in the history.
+const (
+	SourceInferred = "inferred"
+	SourceRegistry = "registry"
+)
+
+// HistoryFile is the name of the schema history object of an event type.
+const HistoryFile = "history.json"
+
+// Store reads and writes schema history objects. Histories are written
+// with conditional writes so that several processors can share them.
+type Store interface {
+	storage.MarkerWriter
+	storage.ConditionalMarkerStore
+}
+
+// Router returns the storage path of the schema history of an event type.
+type Router interface {
+	SchemaPath(topic, eventType string) string
+}
+
+// Registry returns Schema Registry schemas by ID.
+type Registry interface {
+	SchemaByID(ctx context.Context, id int, serialized bool) (*confluent.Schema, error)
+}
+
+// MetricsCollector defines schema tracking metrics operations.
+type MetricsCollector interface {
+	// IncSchemaChanges counts schema changes of an event type: version for
+	// a new compatible version, epoch for a new epoch and rejected for a
+	// rejected change.
+	IncSchemaChanges(topic string, eventType string, change string)
+}
+
+// Ensure implementations satisfy interfaces.
+var _ Registry = (*confluent.Client)(nil)
+
+// Version is a version of the schema of an event type.
+type Version struct {
+	Version      int       `json:"version"`
+	Epoch        int       `json:"epoch"`
+	Source       string    `json:"source"`
+	SchemaID     int       `json:"schema_id,omitempty"` // registry schemas
+	RegisteredAt time.Time `json:"registered_at"`
+	Schema       *Schema   `json:"schema"`
+}
+
+// History is the schema history of an event type of a topic.
+type History struct {
+	Topic         string        `json:"topic"`
+	Type          string        `json:"type"`
+	Compatibility Compatibility `json:"compatibility"`
+	Versions      []Version     `json:"versions"`
+}
+
+// latest returns the latest version of the epoch, or of all epochs for 0.
+func (h *History) latest(epoch int) *Version {
+	for i := len(h.Versions) - 1; i >= 0; i-- {
+		if epoch == 0 || h.Versions[i].Epoch == epoch {
+			return &h.Versions[i]
+		}
+	}
+	return nil
+}
+
+// registered returns the latest version of a registry schema ID, or nil.
+func (h *History) registered(schemaID int) *Version {
+	if schemaID == 0 {
+		return nil
+	}
+	for i := len(h.Versions) - 1; i >= 0; i-- {
+		if h.Versions[i].SchemaID == schemaID {
+			return &h.Versions[i]
+		}
+	}
+	return nil
+}
+
+// Resolution is the schema outcome of an event.
+type Resolution struct {
+	// Epoch is the schema epoch the event is written under, from 1.
+	Epoch int
+	// Err is an *IncompatibleError when the event is rejected.
+	Err error
+}
+
+// TrackerConfig configures a Tracker.
+type TrackerConfig struct {
+	Compatibility  Compatibility
+	OnIncompatible Action
+	Router         Router
+	// Registry provides the schemas of events decoded from the Schema
+	// Registry wire format; nil infers every schema from the data.
+	Registry Registry
+}
+
+// maxHistoryConflicts bounds how often a history change is retried after
+// another writer changed the history concurrently.
+const maxHistoryConflicts = 5
+
+// Tracker keeps the schema history of the event types of topics. Each batch
+// is checked against the latest schema of its type; compatible changes are
+// recorded as new versions, and incompatible ones start a new epoch or are
+// rejected. Histories are loaded from the store on first use and cached.
+//
+// Several processors may share the histories: changes are written with
+// conditional writes, and a history changed by another writer is reloaded
+// and the change checked again. Cached histories are only reloaded when a
+// change is written, so a processor may resolve events against a history
+// that another one has extended until it records a change itself. It is safe
+// for concurrent use.
+type Tracker struct {
+	config  TrackerConfig
+	store   Store
+	logger  *slog.Logger
+	metrics MetricsCollector
+
+	mu         sync.Mutex // guards the caches, never held across I/O
+	histories  map[historyKey]*storedHistory
+	registered map[int]*Schema // converted registry schemas; nil for Protobuf
+}
+
+// historyKey identifies the history of an event type of a topic.
+type historyKey struct {
+	topic     string
+	eventType string
+}
+
+// storedHistory is a history as last read or written, with the version of
+// its history object. Cached histories are never modified; changes replace
+// them.
+type storedHistory struct {
+	history *History
+	version string // "" when the history object does not exist
+}
+
+// change is a version to record in a history.
+type change struct {
+	epoch  int
+	schema *Schema
+	kind   string // version or epoch
+}
+
+// NewTracker creates a schema tracker keeping histories in store.
+func NewTracker(config TrackerConfig, store Store, logger *slog.Logger, metrics MetricsCollector) *Tracker {
+	return &Tracker{
+		config:     config,
+		store:      store,
+		logger:     logger,
+		metrics:    metrics,
+		histories:  make(map[historyKey]*storedHistory),
+		registered: make(map[int]*Schema),
+	}
+}
+
+// schemaGroup is the records of a batch with the same type and schema source.
+type schemaGroup struct {
+	eventType string
+	schemaID  int // 0 for inferred schemas
+	indexes   []int
+}
+
+// groupKey identifies a schema group.
+type groupKey struct {
+	eventType string
+	schemaID  int
+}
+
+// Resolve resolves the schema epochs of a batch of events of topic. Events
+// are grouped by type, and by registry schema ID when decoded from the wire
+// format; each group is checked as one schema. Events without JSON data keep
+// the latest epoch of their type. Errors reading or writing histories and
+// fetching registry schemas fail the whole batch.
+func (t *Tracker) Resolve(ctx context.Context, topic string, records []event.Record) ([]Resolution, error) {
+	resolutions := make([]Resolution, len(records))
+	for _, group := range t.groups(records) {
+		schema, source, err := t.groupSchema(ctx, group, records)
+		if err != nil {
+			return nil, err
+		}
+
+		resolution, err := t.resolveGroup(ctx, topic, group, schema, source)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range group.indexes {
+			resolutions[i] = resolution
+		}
+	}
+	return resolutions, nil
+}
+
+// resolveGroup resolves the epoch of the schema of a group and records its
+// change. A history changed concurrently by another writer is reloaded and
+// the schema checked again.
+func (t *Tracker) resolveGroup(ctx context.Context, topic string, group *schemaGroup, schema *Schema, source string) (Resolution, error) {
+	reload := false
+	for attempt := 0; attempt < maxHistoryConflicts; attempt++ {
+		stored, err := t.history(ctx, topic, group.eventType, reload)
+		if err != nil {
+			return Resolution{}, err
+		}
+
+		if schema == nil {
+			epoch := 1
+			if latest := stored.history.latest(0); latest != nil {
+				epoch = latest.Epoch
+			}
+			return Resolution{Epoch: epoch}, nil
+		}
+
+		epoch, next, err := t.observe(stored.history, schema, source, group.schemaID)
+		var incompatible *IncompatibleError
+		if errors.As(err, &incompatible) {
+			return Resolution{Err: err}, nil
+		} else if err != nil {
+			return Resolution{}, err
+		}
+		if next == nil {
+			return Resolution{Epoch: epoch}, nil
+		}
+
+		err = t.record(ctx, stored, next, source, group.schemaID)
+		if !errors.Is(err, storage.ErrMarkerConflict) {
+			return Resolution{Epoch: epoch}, err
+		}
+		t.logger.Info("schema history changed concurrently, reloading",
+			"topic", topic,
+			"type", group.eventType,
+		)
+		reload = true
+	}
+	return Resolution{}, fmt.Errorf("failed to record schema of %s/%s: history changed concurrently %d times", topic, group.eventType, maxHistoryConflicts)
+}
+
+// groups groups records by type and registry schema ID, in order of first
+// appearance.
+func (t *Tracker) groups(records []event.Record) []*schemaGroup {
+	var groups []*schemaGroup
+	byKey := make(map[groupKey]*schemaGroup)
+	for i, record := range records {
+		if record.Event == nil {
+			continue
+		}
+		schemaID := 0
+		if t.config.Registry != nil {
+			schemaID = extensionSchemaID(record.Event)
+		}
+		key := groupKey{eventType: record.Event.Type, schemaID: schemaID}
+		group, ok := byKey[key]
+		if !ok {
+			group = &schemaGroup{eventType: record.Event.Type, schemaID: schemaID}
+			byKey[key] = group
+			groups = append(groups, group)
+		}
+		group.indexes = append(group.indexes, i)
+	}
+	return groups
+}
+
+// groupSchema returns the schema of a group: its registry schema, or the
+// schema inferred from the data of its events. It returns nil when no event
+// has JSON data.
+func (t *Tracker) groupSchema(ctx context.Context, group *schemaGroup, records []event.Record) (*Schema, string, error) {
+	if group.schemaID != 0 {
+		schema, err := t.registrySchema(ctx, group.schemaID)
+		if err != nil {
+			return nil, "", err
+		}
+		if schema != nil {
+			return schema, SourceRegistry, nil
+		}
+	}
+
+	var inferred *Schema
+	for _, i := range group.indexes {
+		data := records[i].Event.Data
+		if len(data) == 0 {
+			continue
+		}
+		schema, err := Infer(data)
+		if err != nil {
+			continue
+		}
+		inferred = Merge(inferred, schema)
+	}
+	return inferred, SourceInferred, nil
+}
+
+// registrySchema returns the converted registry schema of id, or nil for
+// schemas that are inferred from the data instead.
+func (t *Tracker) registrySchema(ctx context.Context, id int) (*Schema, error) {
+	t.mu.Lock()
+	schema, ok := t.registered[id]
+	t.mu.Unlock()
+	if ok {
+		return schema, nil
+	}
+
+	registered, err := t.config.Registry.SchemaByID(ctx, id, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch schema %d: %w", id, err)
+	}
+	schema, ok, err = FromRegistry(registered)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert schema %d: %w", id, err)
+	}
+	if !ok {
+		schema = nil
+	}
+
+	t.mu.Lock()
+	t.registered[id] = schema
+	t.mu.Unlock()
+	return schema, nil
+}
+
+// history returns the history of an event type, loading it on first use or
+// when reload is set.
+func (t *Tracker) history(ctx context.Context, topic, eventType string, reload bool) (*storedHistory, error) {
+	key := historyKey{topic: topic, eventType: eventType}
+	if !reload {
+		t.mu.Lock()
+		stored, ok := t.histories[key]
+		t.mu.Unlock()
+		if ok {
+			return stored, nil
+		}
+	}
+
+	stored := &storedHistory{history: &History{Topic: topic, Type: eventType, Compatibility: t.config.Compatibility}}
+	data, version, err := t.store.ReadMarkerVersion(ctx, t.config.Router.SchemaPath(topic, eventType), HistoryFile)
+	switch {
+	case errors.Is(err, storage.ErrMarkerNotFound):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read schema history of %s/%s: %w", topic, eventType, err)
+	default:
+		if err := json.Unmarshal(data, stored.history); err != nil {
+			return nil, fmt.Errorf("failed to parse schema history of %s/%s: %w", topic, eventType, err)
+		}
+		stored.history.Compatibility = t.config.Compatibility
+		stored.version = version
+	}
+	t.cache(key, stored)
+	return stored, nil
+}
+
+// cache caches a history unless a newer one of the same type is cached,
+// which happens when concurrent batches load or change it.
+func (t *Tracker) cache(key historyKey, stored *storedHistory) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if cached, ok := t.histories[key]; ok && len(cached.history.Versions) > len(stored.history.Versions) {
+		return
+	}
+	t.histories[key] = stored
+}
+
+// observe checks a schema against the history. It returns the epoch of the
+// schema and the change to record, if any, or an *IncompatibleError
+// rejecting it. Compatible schemas stay in the latest epoch; incompatible
+// ones move to the most recent earlier epoch they are compatible with, else
+// start a new epoch.
+func (t *Tracker) observe(history *History, schema *Schema, source string, schemaID int) (int, *change, error) {
+	latest := history.latest(0)
+	if latest == nil {
+		return 1, &change{epoch: 1, schema: schema, kind: "version"}, nil
+	}
+	if known := history.registered(schemaID); known != nil {
+		// Producers may still write with earlier registry schemas
+		return known.Epoch, nil, nil
+	}
+
+	next, incompatible := t.evolve(latest, schema, source)
+	if incompatible == nil {
+		return latest.Epoch, t.update(latest, next, schemaID), nil
+	}
+	if t.config.OnIncompatible == ActionReject {
+		if t.metrics != nil {
+			t.metrics.IncSchemaChanges(history.Topic, history.Type, "rejected")
+		}
+		return 0, nil, incompatible
+	}
+
+	for epoch := latest.Epoch - 1; epoch >= 1; epoch-- {
+		previous := history.latest(epoch)
+		if previous == nil {
+			continue
+		}
+		if next, err := t.evolve(previous, schema, source); err == nil {
+			return epoch, t.update(previous, next, schemaID), nil
+		}
+	}
+
+	epoch := latest.Epoch + 1
+	t.logger.Warn("incompatible schema change, starting a new path version",
+		"topic", history.Topic,
+		"type", history.Type,
+		"epoch", epoch,
+		"error", incompatible,
+	)
+	return epoch, &change{epoch: epoch, schema: schema, kind: "epoch"}, nil
+}
+
+// evolve returns the schema following previous for a batch schema, or an
+// *IncompatibleError. Inferred schemas only show the fields and values of a
+// batch, so they are merged into the previous schema, and values of another
+// type than before are a change even where the merged schema reads them as
+// any.
+func (t *Tracker) evolve(previous *Version, schema *Schema, source string) (*Schema, error) {
+	if source != SourceInferred {
+		return schema, Check(t.config.Compatibility, previous.Schema, schema)
+	}
+	if t.config.Compatibility != CompatibilityNone {
+		if issues := typeChanges("", previous.Schema, schema); len(issues) > 0 {
+			return nil, &IncompatibleError{Compatibility: t.config.Compatibility, Issues: issues}
+		}
+	}
+	next := Merge(previous.Schema, schema)
+	return next, Check(t.config.Compatibility, previous.Schema, next)
+}
+
+// update returns a compatible schema as a new version of the epoch of
+// previous when it changes the schema, else nil.
+func (t *Tracker) update(previous *Version, schema *Schema, schemaID int) *change {
+	if schema.Equal(previous.Schema) && schemaID == previous.SchemaID {
+		return nil
+	}
+	return &change{epoch: previous.Epoch, schema: schema, kind: "version"}
+}
+
+// record appends a version to a stored history and writes it: the history
+// first, only if it is unchanged since it was read, then the version object
+// as a copy of its entry. It returns storage.ErrMarkerConflict when another
+// writer changed the history. The cached history only changes once the
+// history is written.
+func (t *Tracker) record(ctx context.Context, stored *storedHistory, next *change, source string, schemaID int) error {
+	history := stored.history
+	version := Version{
+		Version:      len(history.Versions) + 1,
+		Epoch:        next.epoch,
+		Source:       source,
+		SchemaID:     schemaID,
+		RegisteredAt: time.Now().UTC(),
+		Schema:       next.schema,
+	}
+	updated := *history
+	updated.Versions = append(append([]Version(nil), history.Versions...), version)
+
+	path := t.config.Router.SchemaPath(history.Topic, history.Type)
+	historyData, err := json.MarshalIndent(&updated, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode schema history: %w", err)
+	}
+	written, err := t.store.WriteMarkerIf(ctx, path, HistoryFile, historyData, stored.version)
+	if err != nil {
+		return fmt.Errorf("failed to write schema history of %s/%s: %w", history.Topic, history.Type, err)
+	}
+	t.cache(historyKey{topic: history.Topic, eventType: history.Type}, &storedHistory{history: &updated, version: written})
+
+	versionData, err := json.MarshalIndent(version, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode schema version: %w", err)
+	}
+	if err := t.store.WriteMarker(ctx, path, fmt.Sprintf("v%04d.json", version.Version), versionData); err != nil {
+		return fmt.Errorf("failed to write schema version of %s/%s: %w", history.Topic, history.Type, err)
+	}
+
+	if t.metrics != nil {
+		t.metrics.IncSchemaChanges(history.Topic, history.Type, next.kind)
+	}
+	t.logger.Info("recorded schema version",
+		"topic", history.Topic,
+		"type", history.Type,
+		"version", version.Version,
+		"epoch", version.Epoch,
+		"source", version.Source,
+	)
+	return nil
+}
+
+// extensionSchemaID returns the registry schema ID of a decoded event, or 0.
+func extensionSchemaID(cloudEvent *event.CloudEvent) int {
+	switch id := cloudEvent.Extensions[confluent.ExtensionSchemaID].(type) {
+	case int:
+		return id
+	case int32:
+		return int(id)
+	case int64:
+		return int(id)
+	case float64:
+		return int(id)
+	case json.Number:
+		n, _ := id.Int64()
+		return int(n)
+	case string:
+		n, _ := strconv.Atoi(id)
+		return n
+	}
+	return 0
+}
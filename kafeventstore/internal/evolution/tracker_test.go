@@ -0,0 +1,398 @@
+package evolution
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jittakal/kafeventstore/internal/confluent"
+	"github.com/jittakal/kafeventstore/pkg/event"
+	"github.com/jittakal/kafeventstore/pkg/storage"
+)
+
+// memoryStore keeps marker objects in memory, versioned by a write counter.
+type memoryStore struct {
+	mu       sync.Mutex
+	objects  map[string][]byte
+	versions map[string]int
+	err      error
+	conflict bool // conditional writes always conflict
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{objects: make(map[string][]byte), versions: make(map[string]int)}
+}
+
+func (s *memoryStore) WriteMarker(ctx context.Context, path string, name string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.objects[path+name] = data
+	s.versions[path+name]++
+	return nil
+}
+
+func (s *memoryStore) ReadMarkerVersion(ctx context.Context, path string, name string) ([]byte, string, error) {
+	data, err := s.ReadMarker(ctx, path, name)
+	if err != nil {
+		return nil, "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return data, strconv.Itoa(s.versions[path+name]), nil
+}
+
+func (s *memoryStore) WriteMarkerIf(ctx context.Context, path string, name string, data []byte, version string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return "", s.err
+	}
+	current := ""
+	if _, ok := s.objects[path+name]; ok {
+		current = strconv.Itoa(s.versions[path+name])
+	}
+	if s.conflict || version != current {
+		return "", storage.ErrMarkerConflict
+	}
+	s.objects[path+name] = data
+	s.versions[path+name]++
+	return strconv.Itoa(s.versions[path+name]), nil
+}
+
+func (s *memoryStore) ReadMarker(ctx context.Context, path string, name string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	data, ok := s.objects[path+name]
+	if !ok {
+		return nil, storage.ErrMarkerNotFound
+	}
+	return data, nil
+}
+
+// history returns the stored history of an event type.
+func (s *memoryStore) history(t *testing.T, eventType string) *History {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.objects[testRouter{}.SchemaPath("orders", eventType)+HistoryFile]
+	if !ok {
+		return &History{}
+	}
+	var history History
+	if err := json.Unmarshal(data, &history); err != nil {
+		t.Fatal(err)
+	}
+	return &history
+}
+
+// testRouter routes schema histories under mem://.
+type testRouter struct{}
+
+func (testRouter) SchemaPath(topic, eventType string) string {
+	return "mem://_schemas/" + topic + "/type=" + eventType + "/"
+}
+
+// mockMetrics records schema changes.
+type mockMetrics struct {
+	mu      sync.Mutex
+	changes map[string]int
+}
+
+func (m *mockMetrics) IncSchemaChanges(topic string, eventType string, change string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.changes == nil {
+		m.changes = make(map[string]int)
+	}
+	m.changes[change]++
+}
+
+// newRecord creates a record of an event of eventType with JSON data.
+func newRecord(eventType, data string) event.Record {
+	return event.Record{
+		Event: &event.CloudEvent{SpecVersion: "1.0", ID: "1", Source: "shop", Type: eventType, Data: json.RawMessage(data)},
+	}
+}
+
+// resolve resolves a batch of records of the orders topic.
+func resolve(t *testing.T, tracker *Tracker, records ...event.Record) []Resolution {
+	t.Helper()
+	resolutions, err := tracker.Resolve(context.Background(), "orders", records)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	return resolutions
+}
+
+func newTestTracker(store *memoryStore, action Action, metrics MetricsCollector) *Tracker {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	config := TrackerConfig{
+		Compatibility:  CompatibilityBackward,
+		OnIncompatible: action,
+		Router:         testRouter{},
+	}
+	return NewTracker(config, store, logger, metrics)
+}
+
+func TestTracker_Versions(t *testing.T) {
+	store := newMemoryStore()
+	metrics := &mockMetrics{}
+	tracker := newTestTracker(store, ActionVersion, metrics)
+
+	// The first batch records version 1
+	resolutions := resolve(t, tracker,
+		newRecord("order.created", `{"id":"o-1","total":10}`),
+		newRecord("order.created", `{"id":"o-2","total":12.5}`),
+	)
+	for _, resolution := range resolutions {
+		if resolution.Epoch != 1 || resolution.Err != nil {
+			t.Fatalf("Resolve() = %+v, want epoch 1", resolution)
+		}
+	}
+	history := store.history(t, "order.created")
+	if len(history.Versions) != 1 || history.Versions[0].Source != SourceInferred || history.Compatibility != CompatibilityBackward {
+		t.Fatalf("history = %+v, want one inferred version", history)
+	}
+	if _, ok := store.objects[testRouter{}.SchemaPath("orders", "order.created")+"v0001.json"]; !ok {
+		t.Error("version object v0001.json not written")
+	}
+
+	// Batches with a subset of the fields do not change the schema
+	resolve(t, tracker, newRecord("order.created", `{"id":"o-3"}`))
+	if versions := len(store.history(t, "order.created").Versions); versions != 1 {
+		t.Errorf("versions = %d after a subset batch, want 1", versions)
+	}
+
+	// New fields are a compatible change
+	resolutions = resolve(t, tracker, newRecord("order.created", `{"id":"o-4","total":3,"coupon":"X"}`))
+	history = store.history(t, "order.created")
+	if resolutions[0].Epoch != 1 || len(history.Versions) != 2 || history.Versions[1].Schema.field("coupon") == nil {
+		t.Errorf("history = %+v, want version 2 with coupon in epoch 1", history)
+	}
+
+	// Changing a type starts a new epoch
+	resolutions = resolve(t, tracker, newRecord("order.created", `{"id":"o-5","total":"3.00"}`))
+	if resolutions[0].Epoch != 2 || resolutions[0].Err != nil {
+		t.Errorf("Resolve() = %+v, want epoch 2", resolutions[0])
+	}
+
+	// Events of the previous shape go back to their epoch
+	resolutions = resolve(t, tracker,
+		newRecord("order.created", `{"id":"o-6","total":4}`),
+		newRecord("order.paid", `{"id":"o-6"}`),
+	)
+	if resolutions[0].Epoch != 1 || resolutions[1].Epoch != 1 {
+		t.Errorf("Resolve() = %+v, want epoch 1", resolutions)
+	}
+	if versions := len(store.history(t, "order.created").Versions); versions != 3 {
+		t.Errorf("versions = %d, want 3", versions)
+	}
+
+	if metrics.changes["epoch"] != 1 || metrics.changes["rejected"] != 0 {
+		t.Errorf("schema changes = %v", metrics.changes)
+	}
+
+	// A new tracker continues from the stored history
+	reloaded := newTestTracker(store, ActionVersion, nil)
+	resolutions = resolve(t, reloaded, newRecord("order.created", `{"id":"o-9","total":"5.00"}`))
+	if resolutions[0].Epoch != 2 {
+		t.Errorf("Resolve() epoch = %d after reload, want 2", resolutions[0].Epoch)
+	}
+}
+
+func TestTracker_Reject(t *testing.T) {
+	store := newMemoryStore()
+	metrics := &mockMetrics{}
+	tracker := newTestTracker(store, ActionReject, metrics)
+
+	resolve(t, tracker, newRecord("order.created", `{"id":"o-1","total":10}`))
+	resolutions := resolve(t, tracker,
+		newRecord("order.created", `{"id":"o-2","total":"10"}`),
+		newRecord("order.created", `{"id":"o-3","total":11}`),
+		newRecord("order.paid", `{"id":"o-1"}`),
+	)
+
+	var incompatible *IncompatibleError
+	for i := 0; i < 2; i++ {
+		if !errors.As(resolutions[i].Err, &incompatible) {
+			t.Errorf("resolutions[%d].Err = %v, want *IncompatibleError", i, resolutions[i].Err)
+		}
+	}
+	if resolutions[2].Err != nil || resolutions[2].Epoch != 1 {
+		t.Errorf("resolutions[2] = %+v, want epoch 1", resolutions[2])
+	}
+	if versions := len(store.history(t, "order.created").Versions); versions != 1 {
+		t.Errorf("versions = %d, want the rejected change not recorded", versions)
+	}
+	if metrics.changes["rejected"] != 1 {
+		t.Errorf("schema changes = %v, want 1 rejected", metrics.changes)
+	}
+}
+
+func TestTracker_EventsWithoutJSONData(t *testing.T) {
+	tracker := newTestTracker(newMemoryStore(), ActionVersion, nil)
+
+	resolutions := resolve(t, tracker,
+		newRecord("order.created", ``),
+		newRecord("order.created", `not json`),
+		event.Record{},
+	)
+	for i, resolution := range resolutions[:2] {
+		if resolution.Epoch != 1 || resolution.Err != nil {
+			t.Errorf("resolutions[%d] = %+v, want epoch 1", i, resolution)
+		}
+	}
+}
+
+func TestTracker_StoreErrors(t *testing.T) {
+	store := newMemoryStore()
+	store.err = errors.New("bucket unavailable")
+	tracker := newTestTracker(store, ActionVersion, nil)
+
+	if _, err := tracker.Resolve(context.Background(), "orders", []event.Record{newRecord("order.created", `{}`)}); err == nil {
+		t.Error("Resolve() error = nil, want store error")
+	}
+
+	// Nothing is cached from failed writes
+	store.err = nil
+	resolve(t, tracker, newRecord("order.created", `{"id":"o-1"}`))
+	store.objects = make(map[string][]byte)
+	store.err = errors.New("bucket unavailable")
+	if _, err := tracker.Resolve(context.Background(), "orders", []event.Record{newRecord("order.created", `{"id":1}`)}); err == nil {
+		t.Error("Resolve() error = nil, want store error")
+	}
+	if versions := len(tracker.histories[historyKey{topic: "orders", eventType: "order.created"}].history.Versions); versions != 1 {
+		t.Errorf("cached versions = %d, want 1", versions)
+	}
+}
+
+func TestTracker_ConcurrentWriters(t *testing.T) {
+	store := newMemoryStore()
+	first := newTestTracker(store, ActionVersion, nil)
+	second := newTestTracker(store, ActionVersion, nil)
+
+	resolve(t, first, newRecord("order.created", `{"id":"o-1"}`))
+	resolve(t, second, newRecord("order.created", `{"id":"o-2"}`))
+	resolve(t, first, newRecord("order.created", `{"id":"o-3","total":1}`))
+
+	// The second tracker's cached history is stale: its change conflicts,
+	// and is checked again against the reloaded history
+	resolutions := resolve(t, second, newRecord("order.created", `{"id":"o-4","coupon":"X"}`))
+	if resolutions[0].Epoch != 1 || resolutions[0].Err != nil {
+		t.Fatalf("Resolve() = %+v, want epoch 1", resolutions[0])
+	}
+	history := store.history(t, "order.created")
+	if len(history.Versions) != 3 {
+		t.Fatalf("versions = %d, want 3", len(history.Versions))
+	}
+	for i, version := range history.Versions {
+		if version.Version != i+1 {
+			t.Errorf("versions[%d].Version = %d, want %d", i, version.Version, i+1)
+		}
+	}
+	if latest := history.Versions[2].Schema; latest.field("total") == nil || latest.field("coupon") == nil {
+		t.Errorf("latest schema = %+v, want total and coupon", latest)
+	}
+	if _, ok := store.objects[testRouter{}.SchemaPath("orders", "order.created")+"v0003.json"]; !ok {
+		t.Error("version object v0003.json not written")
+	}
+
+	// Histories that keep changing fail the batch
+	store.conflict = true
+	_, err := second.Resolve(context.Background(), "orders", []event.Record{newRecord("order.created", `{"id":"o-5","tax":1}`)})
+	if err == nil {
+		t.Error("Resolve() error = nil, want a conflict error")
+	}
+}
+
+func TestTracker_RegistrySchemas(t *testing.T) {
+	registry := confluent.NewMockRegistry()
+	v1 := registry.Register("orders-value", confluent.Schema{
+		Schema: `{"type":"record","name":"Order","fields":[{"name":"id","type":"string"}]}`,
+	})
+	v2 := registry.Register("orders-value", confluent.Schema{
+		Schema: `{"type":"record","name":"Order","fields":[{"name":"id","type":"string"},{"name":"total","type":["null","double"],"default":null}]}`,
+	})
+	v3 := registry.Register("orders-value", confluent.Schema{
+		Schema: `{"type":"record","name":"Order","fields":[{"name":"id","type":"string"},{"name":"tax","type":"double"}]}`,
+	})
+	server := httptest.NewServer(registry)
+	defer server.Close()
+	client, err := confluent.NewClient(confluent.ClientConfig{URL: server.URL, Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := newMemoryStore()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	tracker := NewTracker(TrackerConfig{
+		Compatibility:  CompatibilityBackward,
+		OnIncompatible: ActionReject,
+		Router:         testRouter{},
+		Registry:       client,
+	}, store, logger, nil)
+
+	decoded := func(schemaID int, data string) event.Record {
+		record := newRecord("order.created", data)
+		record.Event.Extensions = map[string]interface{}{confluent.ExtensionSchemaID: schemaID}
+		return record
+	}
+
+	resolve(t, tracker, decoded(v1, `{"id":"o-1"}`))
+	resolutions := resolve(t, tracker, decoded(v2, `{"id":"o-2","total":1}`), decoded(v1, `{"id":"o-3"}`))
+	if resolutions[0].Err != nil || resolutions[1].Err != nil {
+		t.Fatalf("Resolve() = %+v, want compatible", resolutions)
+	}
+	history := store.history(t, "order.created")
+	if len(history.Versions) != 2 || history.Versions[1].SchemaID != v2 || history.Versions[1].Source != SourceRegistry {
+		t.Fatalf("history = %+v, want registry schemas %d and %d", history, v1, v2)
+	}
+
+	// A new required field without a default is not backward compatible,
+	// even though the data would infer a compatible schema
+	resolutions = resolve(t, tracker, decoded(v3, `{"id":"o-4","tax":0.5}`))
+	if resolutions[0].Err == nil {
+		t.Error("Resolve() error = nil, want the new required field rejected")
+	}
+
+	// Schemas are fetched and converted once
+	requests := registry.Requests()
+	resolve(t, tracker, decoded(v2, `{"id":"o-5"}`))
+	if registry.Requests() != requests {
+		t.Errorf("registry requests = %d, want %d", registry.Requests(), requests)
+	}
+}
+
+func TestExtensionSchemaID(t *testing.T) {
+	tests := []struct {
+		value any
+		want  int
+	}{
+		{value: 7, want: 7},
+		{value: float64(7), want: 7},
+		{value: json.Number("7"), want: 7},
+		{value: "7", want: 7},
+		{value: "seven", want: 0},
+		{value: nil, want: 0},
+	}
+
+	for _, tt := range tests {
+		cloudEvent := &event.CloudEvent{Extensions: map[string]interface{}{confluent.ExtensionSchemaID: tt.value}}
+		if got := extensionSchemaID(cloudEvent); got != tt.want {
+			t.Errorf("extensionSchemaID(%v) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
@@ -3,7 +3,6 @@ package kafka
 
 import (
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"log/slog"
@@ -35,8 +34,64 @@ type ConsumerConfig struct {
 	MaxPollIntervalMS   int
 	SessionTimeoutMS    int
 	HeartbeatIntervalMS int
+
+	// TopicPattern also subscribes to topics whose whole name matches the
+	// regular expression; they are discovered every TopicRefreshInterval.
+	TopicPattern         string
+	TopicRefreshInterval time.Duration
+	// ExcludeTopicSuffixes keeps topics such as DLQs out of pattern subscriptions.
+	ExcludeTopicSuffixes []string
+
+	// TLS is used for the SSL and SASL_SSL security protocols.
+	TLS TLSConfig
+
+	// AWSRegion is used for AWS_MSK_IAM; empty derives it from the broker
+	// host names or the AWS_REGION environment variable.
+	AWSRegion string
+	// OAuth is used for the OAUTHBEARER SASL mechanism.
+	OAuth OAuthConfig
+
+	// Retry marks a consumer of retry topics. Their messages are unwrapped
+	// into the original events, which are delivered once their retry delay
+	// has passed.
+	Retry bool
+
+	// KafkaVersion is the protocol version, e.g. "3.6.0"; empty uses 2.8.0.
+	KafkaVersion string
+	// RebalanceStrategy is RebalanceRoundRobin, RebalanceRange or RebalanceSticky.
+	RebalanceStrategy string
+	// GroupInstanceID enables static group membership, so a member that
+	// rejoins within the session timeout keeps its partitions.
+	GroupInstanceID string
+	// EventBufferSize is the capacity of the channel that hands consumed
+	// events to the caller, so it bounds how many events are read ahead of
+	// processing. Sarama has no per-fetch record limit.
+	EventBufferSize   int
+	FetchMinBytes     int32
+	FetchMaxBytes     int32
+	ChannelBufferSize int
 }
 
+// Consumer group rebalance strategies.
+const (
+	RebalanceRoundRobin = "roundrobin"
+	RebalanceRange      = "range"
+	RebalanceSticky     = "sticky"
+)
+
+const (
+	// defaultTopicRefreshInterval is used when TopicRefreshInterval is not set.
+	defaultTopicRefreshInterval = time.Minute
+
+	// defaultEventBufferSize is the event channel size when EventBufferSize is not set.
+	defaultEventBufferSize = 100
+
+	// poisonRetryBackoff is the delay before retrying a failed poison
+	// handler; it doubles per attempt up to poisonRetryMaxBackoff.
+	poisonRetryBackoff    = 500 * time.Millisecond
+	poisonRetryMaxBackoff = 30 * time.Second
+)
+
 // MetricsCollector defines metrics operations for Kafka consumer.
 type MetricsCollector interface {
 	IncMessagesConsumed(topic string, partition int32)
@@ -45,17 +100,28 @@ type MetricsCollector interface {
 	ObserveRebalanceDuration(groupID string, duration float64)
 	ObserveCommitLatency(topic string, partition int32, duration float64)
 	SetPartitionsAssigned(topic string, count float64)
+	IncTopicsDiscovered(topic string)
+	SetSubscribedTopics(count float64)
 }
 
+// PoisonHandler handles a message that could not be parsed into a CloudEvent,
+// e.g. by dead-lettering it. The message is marked consumed when it returns nil.
+type PoisonHandler func(ctx context.Context, message RawMessage, parseErr error) error
+
 // SaramaConsumer implements the consumer.Consumer interface using the Sarama library.
 // It provides a production-ready Kafka consumer with support for consumer groups,
 // offset management, and various security protocols including AWS MSK IAM.
 type SaramaConsumer struct {
 	consumerGroup sarama.ConsumerGroup
+	client        sarama.Client // metadata client, set only for pattern subscriptions
+	discovery     *topicDiscovery
 	config        ConsumerConfig
 	logger        *slog.Logger
 	metrics       MetricsCollector
 	topics        []string
+	cancelSession context.CancelFunc
+	onPoison      PoisonHandler
+	topicsAdded   chan struct{} // signalled when discovery adds topics
 	ready         chan bool
 	mu            sync.RWMutex
 	closed        bool
@@ -67,11 +133,80 @@ func NewSaramaConsumer(
 	logger *slog.Logger,
 	metrics MetricsCollector,
 ) (*SaramaConsumer, error) {
+	saramaConfig, err := newConsumerSaramaConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pattern subscriptions share a client with the consumer group so cluster
+	// metadata can be listed for topic discovery
+	if config.TopicPattern != "" {
+		return newPatternConsumer(config, saramaConfig, logger, metrics)
+	}
+
+	// Create consumer group
+	consumerGroup, err := sarama.NewConsumerGroup(
+		config.BootstrapServers,
+		config.GroupID,
+		saramaConfig,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer group: %w", err)
+	}
+
+	logger.Info("kafka consumer created",
+		"group_id", config.GroupID,
+		"group_instance_id", config.GroupInstanceID,
+		"bootstrap_servers", config.BootstrapServers,
+		"kafka_version", saramaConfig.Version,
+		"rebalance_strategy", saramaConfig.Consumer.Group.Rebalance.GroupStrategies[0].Name(),
+		"session_timeout_ms", config.SessionTimeoutMS,
+		"max_poll_interval_ms", config.MaxPollIntervalMS,
+	)
+
+	return &SaramaConsumer{
+		consumerGroup: consumerGroup,
+		config:        config,
+		logger:        logger,
+		metrics:       metrics,
+		ready:         make(chan bool),
+		closed:        false,
+	}, nil
+}
+
+// newConsumerSaramaConfig builds the Sarama configuration for a consumer.
+func newConsumerSaramaConfig(config ConsumerConfig) (*sarama.Config, error) {
 	saramaConfig := sarama.NewConfig()
 
 	// Consumer configuration following AWS MSK best practices
-	saramaConfig.Version = sarama.V2_8_0_0
-	saramaConfig.Consumer.Group.Rebalance.Strategy = sarama.NewBalanceStrategyRoundRobin()
+	version, err := kafkaVersion(config.KafkaVersion)
+	if err != nil {
+		return nil, err
+	}
+	saramaConfig.Version = version
+
+	strategy, err := balanceStrategy(config.RebalanceStrategy)
+	if err != nil {
+		return nil, err
+	}
+	saramaConfig.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{strategy}
+
+	// Static membership: restarts within the session timeout do not rebalance
+	if config.GroupInstanceID != "" {
+		saramaConfig.Consumer.Group.InstanceId = config.GroupInstanceID
+	}
+
+	// Fetch sizing
+	if config.FetchMinBytes > 0 {
+		saramaConfig.Consumer.Fetch.Min = config.FetchMinBytes
+	}
+	if config.FetchMaxBytes > 0 {
+		saramaConfig.Consumer.Fetch.Max = config.FetchMaxBytes
+	}
+	if config.ChannelBufferSize > 0 {
+		saramaConfig.ChannelBufferSize = config.ChannelBufferSize
+	}
+
 	saramaConfig.Consumer.Offsets.Initial = offsetInitial(config.AutoOffsetReset)
 	saramaConfig.Consumer.Offsets.AutoCommit.Enable = config.EnableAutoCommit
 
@@ -95,34 +230,62 @@ func NewSaramaConsumer(
 		return nil, fmt.Errorf("failed to configure security: %w", err)
 	}
 
-	// Create consumer group
-	consumerGroup, err := sarama.NewConsumerGroup(
-		config.BootstrapServers,
-		config.GroupID,
-		saramaConfig,
-	)
+	if err := saramaConfig.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid consumer configuration: %w", err)
+	}
+	return saramaConfig, nil
+}
+
+// newPatternConsumer creates a consumer whose subscription also includes the
+// topics matching config.TopicPattern.
+func newPatternConsumer(
+	config ConsumerConfig,
+	saramaConfig *sarama.Config,
+	logger *slog.Logger,
+	metrics MetricsCollector,
+) (*SaramaConsumer, error) {
+	client, err := sarama.NewClient(config.BootstrapServers, saramaConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka client: %w", err)
+	}
+
+	discovery, err := newTopicDiscovery(client, config.TopicPattern, config.ExcludeTopicSuffixes)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	consumerGroup, err := sarama.NewConsumerGroupFromClient(config.GroupID, client)
 	if err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to create consumer group: %w", err)
 	}
 
 	logger.Info("kafka consumer created",
 		"group_id", config.GroupID,
 		"bootstrap_servers", config.BootstrapServers,
+		"topic_pattern", config.TopicPattern,
 		"session_timeout_ms", config.SessionTimeoutMS,
 		"max_poll_interval_ms", config.MaxPollIntervalMS,
 	)
 
 	return &SaramaConsumer{
 		consumerGroup: consumerGroup,
+		client:        client,
+		discovery:     discovery,
 		config:        config,
 		logger:        logger,
 		metrics:       metrics,
+		topicsAdded:   make(chan struct{}, 1),
 		ready:         make(chan bool),
 		closed:        false,
 	}, nil
 }
 
 // Subscribe subscribes to the specified topics.
+// With a topic pattern configured, the matching topics are added as well.
+// A retry consumer whose pattern matches no topic yet starts without topics
+// and consumes once discovery finds some.
 func (c *SaramaConsumer) Subscribe(ctx context.Context, topics []string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -131,11 +294,117 @@ func (c *SaramaConsumer) Subscribe(ctx context.Context, topics []string) error {
 		return errors.ErrConsumerClosed
 	}
 
+	if c.discovery != nil {
+		discovered, err := c.discovery.discover()
+		if err != nil {
+			return fmt.Errorf("failed to discover topics: %w", err)
+		}
+		topics = mergeTopics(topics, discovered)
+		if len(topics) == 0 && !c.config.Retry {
+			return fmt.Errorf("no topics match pattern: %s", c.config.TopicPattern)
+		}
+	}
+
 	c.topics = topics
+	if c.metrics != nil {
+		c.metrics.SetSubscribedTopics(float64(len(topics)))
+	}
 	c.logger.Info("subscribed to topics", "topics", topics)
 	return nil
 }
 
+// SetPoisonHandler sets the handler for messages that cannot be parsed.
+// Without one, such messages are logged and skipped.
+func (c *SaramaConsumer) SetPoisonHandler(handler PoisonHandler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onPoison = handler
+}
+
+// poisonHandler returns the handler for messages that cannot be parsed.
+func (c *SaramaConsumer) poisonHandler() PoisonHandler {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.onPoison
+}
+
+// subscribedTopics returns the current subscription.
+func (c *SaramaConsumer) subscribedTopics() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.topics
+}
+
+// Topics returns the topics the consumer is subscribed to, including the
+// topics discovered so far.
+func (c *SaramaConsumer) Topics() []string {
+	return append([]string(nil), c.subscribedTopics()...)
+}
+
+// refreshTopics periodically discovers new topics matching the pattern
+// until ctx is cancelled.
+func (c *SaramaConsumer) refreshTopics(ctx context.Context) {
+	interval := c.config.TopicRefreshInterval
+	if interval <= 0 {
+		interval = defaultTopicRefreshInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.refreshSubscription(); err != nil {
+				c.logger.Warn("topic discovery failed", "error", err)
+			}
+		}
+	}
+}
+
+// refreshSubscription adds newly discovered topics to the subscription and
+// ends the current consumer group session so the group rebalances onto them.
+// Topics are never removed from the subscription.
+func (c *SaramaConsumer) refreshSubscription() error {
+	discovered, err := c.discovery.discover()
+	if err != nil {
+		return fmt.Errorf("failed to discover topics: %w", err)
+	}
+
+	c.mu.Lock()
+	added := addedTopics(c.topics, discovered)
+	if len(added) == 0 {
+		c.mu.Unlock()
+		return nil
+	}
+	c.topics = mergeTopics(c.topics, added)
+	count := len(c.topics)
+	cancelSession := c.cancelSession
+	c.mu.Unlock()
+
+	c.logger.Info("discovered new topics, resubscribing",
+		"topics", added,
+		"pattern", c.config.TopicPattern,
+		"subscribed_topics", count,
+	)
+	if c.metrics != nil {
+		for _, topic := range added {
+			c.metrics.IncTopicsDiscovered(topic)
+		}
+		c.metrics.SetSubscribedTopics(float64(count))
+	}
+
+	select {
+	case c.topicsAdded <- struct{}{}:
+	default:
+	}
+	if cancelSession != nil {
+		cancelSession()
+	}
+	return nil
+}
+
 // Consume starts consuming messages and returns channels for events and errors.
 func (c *SaramaConsumer) Consume(ctx context.Context) (<-chan *event.ConsumedEvent, <-chan error, error) {
 	c.mu.RLock()
@@ -145,7 +414,12 @@ func (c *SaramaConsumer) Consume(ctx context.Context) (<-chan *event.ConsumedEve
 	}
 	c.mu.RUnlock()
 
-	eventChan := make(chan *event.ConsumedEvent, 100)
+	// EventBufferSize bounds how many events are read ahead of processing
+	eventBufferSize := defaultEventBufferSize
+	if c.config.EventBufferSize > 0 {
+		eventBufferSize = c.config.EventBufferSize
+	}
+	eventChan := make(chan *event.ConsumedEvent, eventBufferSize)
 	errorChan := make(chan error, 10)
 
 	handler := &consumerGroupHandler{
@@ -155,19 +429,46 @@ func (c *SaramaConsumer) Consume(ctx context.Context) (<-chan *event.ConsumedEve
 		ready:     c.ready,
 	}
 
-	// Start consuming in background
+	// Start consuming in background. stopped is closed when consuming ends,
+	// with stopErr set when the consumer group failed
+	stopped := make(chan struct{})
+	var stopErr error
 	go func() {
+		defer close(stopped)
 		defer close(eventChan)
 		defer close(errorChan)
 
+		if c.discovery != nil {
+			go c.refreshTopics(ctx)
+		}
+
 		for {
 			select {
 			case <-ctx.Done():
 				c.logger.Info("consumer context cancelled")
 				return
 			default:
-				if err := c.consumerGroup.Consume(ctx, c.topics, handler); err != nil {
+				// A retry consumer without topics waits for discovery
+				if len(c.subscribedTopics()) == 0 {
+					select {
+					case <-ctx.Done():
+					case <-c.topicsAdded:
+					}
+					continue
+				}
+
+				// Each session gets its own context so a subscription change
+				// can end it and rejoin the group with the new topics
+				sessionCtx, cancelSession := context.WithCancel(ctx)
+				c.mu.Lock()
+				c.cancelSession = cancelSession
+				c.mu.Unlock()
+
+				err := c.consumerGroup.Consume(sessionCtx, c.subscribedTopics(), handler)
+				cancelSession()
+				if err != nil {
 					c.logger.Error("consumer group error", "error", err)
+					stopErr = err
 					errorChan <- err
 					return
 				}
@@ -180,8 +481,16 @@ func (c *SaramaConsumer) Consume(ctx context.Context) (<-chan *event.ConsumedEve
 		}
 	}()
 
-	// Wait for consumer to be ready
-	<-c.ready
+	// Wait for consumer to be ready. A consumer group that fails first, e.g.
+	// because a topic does not exist, never becomes ready
+	select {
+	case <-c.ready:
+	case <-stopped:
+		if stopErr == nil {
+			stopErr = ctx.Err()
+		}
+		return nil, nil, fmt.Errorf("consumer stopped before joining its group: %w", stopErr)
+	}
 
 	c.logger.Info("kafka consumer started and ready")
 	return eventChan, errorChan, nil
@@ -240,6 +549,14 @@ func (c *SaramaConsumer) Close() error {
 		return err
 	}
 
+	// A client shared with the consumer group is not closed by the group
+	if c.client != nil {
+		if err := c.client.Close(); err != nil {
+			c.logger.Error("error closing kafka client", "error", err)
+			return err
+		}
+	}
+
 	c.logger.Info("kafka consumer closed")
 	return nil
 }
@@ -334,6 +651,36 @@ func (h *consumerGroupHandler) ConsumeClaim(
 				"value_size", len(message.Value),
 			)
 
+			// Retry messages are delivered once their delay has passed
+			if h.consumer.config.Retry {
+				consumedEvent, err := h.retryEvent(session, message)
+				if err != nil {
+					h.consumer.logger.Error("failed to parse retry event",
+						"error", err,
+						"topic", message.Topic,
+						"partition", message.Partition,
+						"offset", message.Offset,
+					)
+					h.errorChan <- fmt.Errorf("failed to parse retry event: %w", err)
+					if !h.handlePoison(session, message, err) {
+						return nil
+					}
+					continue
+				}
+				if consumedEvent == nil {
+					return nil
+				}
+				select {
+				case h.eventChan <- consumedEvent:
+					if h.consumer.metrics != nil {
+						h.consumer.metrics.IncMessagesConsumed(message.Topic, message.Partition)
+					}
+				case <-session.Context().Done():
+					return nil
+				}
+				continue
+			}
+
 			// Parse CloudEvent from message
 			cloudEvent, err := h.parseCloudEvent(message)
 			if err != nil {
@@ -344,6 +691,9 @@ func (h *consumerGroupHandler) ConsumeClaim(
 					"offset", message.Offset,
 				)
 				h.errorChan <- fmt.Errorf("failed to parse cloud event: %w", err)
+				if !h.handlePoison(session, message, err) {
+					return nil
+				}
 				continue
 			}
 
@@ -366,6 +716,7 @@ func (h *consumerGroupHandler) ConsumeClaim(
 					Topic:     message.Topic,
 					Partition: message.Partition,
 					Offset:    message.Offset,
+					Key:       message.Key,
 					Timestamp: message.Timestamp,
 					Headers:   h.extractHeaders(message.Headers),
 				},
@@ -395,12 +746,95 @@ func (h *consumerGroupHandler) ConsumeClaim(
 	}
 }
 
-// parseCloudEvent parses a Kafka message into a CloudEvent.
+// handlePoison passes a message that could not be parsed to the poison
+// handler and marks it consumed once the handler has taken care of it.
+// A failing handler is retried with backoff, holding back the partition, since
+// marking a later message would commit past this one. It returns false when
+// the session ends before the message is handled.
+func (h *consumerGroupHandler) handlePoison(
+	session sarama.ConsumerGroupSession,
+	message *sarama.ConsumerMessage,
+	parseErr error,
+) bool {
+	handler := h.consumer.poisonHandler()
+	if handler == nil {
+		return true
+	}
+	for attempt := 1; ; attempt++ {
+		err := handler(session.Context(), newRawMessage(message), parseErr)
+		if err == nil {
+			break
+		}
+
+		// The attempt is capped so the shift cannot overflow
+		delay := min(retryDelay(poisonRetryBackoff, min(attempt, 10)), poisonRetryMaxBackoff)
+		h.consumer.logger.Error("failed to handle unparseable message, retrying",
+			"error", err,
+			"topic", message.Topic,
+			"partition", message.Partition,
+			"offset", message.Offset,
+			"attempt", attempt,
+			"retry_in", delay,
+		)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-session.Context().Done():
+			timer.Stop()
+			return false
+		}
+	}
+	session.MarkMessage(message, "")
+	return true
+}
+
+// retryEvent unwraps a retry topic message and waits until it may be
+// reprocessed. It returns nil when the session ends while waiting.
+func (h *consumerGroupHandler) retryEvent(
+	session sarama.ConsumerGroupSession,
+	message *sarama.ConsumerMessage,
+) (*event.ConsumedEvent, error) {
+	cloudEvent, metadata, retryAt, err := parseRetryMessage(message, h.extractHeaders(message.Headers))
+	if err != nil {
+		return nil, err
+	}
+
+	// Messages of a retry topic share one delay, so waiting for the oldest
+	// also holds back the rest of the partition
+	if wait := time.Until(retryAt); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-session.Context().Done():
+			return nil, nil
+		}
+	}
+
+	return &event.ConsumedEvent{
+		Event:    cloudEvent,
+		Metadata: metadata,
+		CommitFunc: func() error {
+			session.MarkMessage(message, "")
+			return nil
+		},
+	}, nil
+}
+
+// parseCloudEvent parses a Kafka message into a CloudEvent, in binary mode
+// when it has a ce_specversion header and in structured mode otherwise.
 // Automatically normalizes CloudEvents 0.1 to 1.0 for backward compatibility.
 func (h *consumerGroupHandler) parseCloudEvent(message *sarama.ConsumerMessage) (*event.CloudEvent, error) {
 	var cloudEvent event.CloudEvent
 
-	if err := json.Unmarshal(message.Value, &cloudEvent); err != nil {
+	if isBinaryCloudEvent(message.Headers) {
+		binaryEvent, err := parseBinaryCloudEvent(message.Headers, message.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse binary cloud event: %w", err)
+		}
+		cloudEvent = *binaryEvent
+	} else if err := json.Unmarshal(message.Value, &cloudEvent); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal cloud event: %w", err)
 	}
 
@@ -412,10 +846,14 @@ func (h *consumerGroupHandler) parseCloudEvent(message *sarama.ConsumerMessage)
 	return &cloudEvent, nil
 }
 
-// extractHeaders extracts headers from Kafka message.
+// extractHeaders extracts headers from Kafka message, except HeaderRedacted.
 func (h *consumerGroupHandler) extractHeaders(headers []*sarama.RecordHeader) map[string]string {
 	result := make(map[string]string)
 	for _, header := range headers {
+		// Only retry messages may claim their data is redacted
+		if string(header.Key) == HeaderRedacted {
+			continue
+		}
 		result[string(header.Key)] = string(header.Value)
 	}
 	return result
@@ -444,6 +882,32 @@ func (m *MSKAccessTokenProvider) Token() (*sarama.AccessToken, error) {
 
 // Helper functions
 
+// kafkaVersion parses a Kafka protocol version; empty uses 2.8.0.
+func kafkaVersion(version string) (sarama.KafkaVersion, error) {
+	if version == "" {
+		return sarama.V2_8_0_0, nil
+	}
+	v, err := sarama.ParseKafkaVersion(version)
+	if err != nil {
+		return sarama.KafkaVersion{}, fmt.Errorf("invalid kafka version: %w", err)
+	}
+	return v, nil
+}
+
+// balanceStrategy returns the consumer group rebalance strategy; empty uses round-robin.
+func balanceStrategy(name string) (sarama.BalanceStrategy, error) {
+	switch name {
+	case "", RebalanceRoundRobin:
+		return sarama.NewBalanceStrategyRoundRobin(), nil
+	case RebalanceRange:
+		return sarama.NewBalanceStrategyRange(), nil
+	case RebalanceSticky:
+		return sarama.NewBalanceStrategySticky(), nil
+	default:
+		return nil, fmt.Errorf("unsupported rebalance strategy: %s", name)
+	}
+}
+
 // offsetInitial converts the AutoOffsetReset config to Sarama's offset constant.
 func offsetInitial(autoOffsetReset string) int64 {
 	switch autoOffsetReset {
@@ -497,28 +961,37 @@ func configureSecurity(config *sarama.Config, kafkaConfig ConsumerConfig) error
 			config.Net.SASL.User = "token"
 			config.Net.SASL.Password = "token"
 
+			region, err := mskRegion(kafkaConfig.AWSRegion, kafkaConfig.BootstrapServers)
+			if err != nil {
+				return err
+			}
+
 			// Create IAM token provider using AWS CLI credentials
 			config.Net.SASL.TokenProvider = &MSKAccessTokenProvider{
-				region: "us-east-1", // Extract from broker address if needed
+				region: region,
+			}
+
+		case "OAUTHBEARER":
+			provider, err := NewOIDCTokenProvider(kafkaConfig.OAuth)
+			if err != nil {
+				return fmt.Errorf("failed to configure OAUTHBEARER: %w", err)
 			}
+			config.Net.SASL.Mechanism = sarama.SASLTypeOAuth
+			config.Net.SASL.TokenProvider = provider
 
 		default:
 			return fmt.Errorf("unsupported SASL mechanism: %s", kafkaConfig.SASLMechanism)
 		}
 
 		if kafkaConfig.SecurityProtocol == "SASL_SSL" {
-			config.Net.TLS.Enable = true
-			config.Net.TLS.Config = &tls.Config{
-				MinVersion:         tls.VersionTLS12,
-				InsecureSkipVerify: true, // For local development with self-signed certs
+			if err := configureTLS(config, kafkaConfig.TLS); err != nil {
+				return err
 			}
 		}
 
 	case "SSL":
-		config.Net.TLS.Enable = true
-		config.Net.TLS.Config = &tls.Config{
-			MinVersion:         tls.VersionTLS12,
-			InsecureSkipVerify: true, // For local development with self-signed certs
+		if err := configureTLS(config, kafkaConfig.TLS); err != nil {
+			return err
 		}
 
 	default:
@@ -527,3 +1000,14 @@ func configureSecurity(config *sarama.Config, kafkaConfig ConsumerConfig) error
 
 	return nil
 }
+
+// configureTLS enables TLS on the Sarama configuration.
+func configureTLS(config *sarama.Config, tlsConfig TLSConfig) error {
+	netTLS, err := newTLSConfig(tlsConfig)
+	if err != nil {
+		return fmt.Errorf("failed to configure TLS: %w", err)
+	}
+	config.Net.TLS.Enable = true
+	config.Net.TLS.Config = netTLS
+	return nil
+}
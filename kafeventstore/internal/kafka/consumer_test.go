@@ -2,6 +2,11 @@ package kafka
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
 	"testing"
 	"time"
 
@@ -133,6 +138,7 @@ func TestConsumerConfig_SASLMechanisms(t *testing.T) {
 		{"scram-sha-256", "SCRAM-SHA-256", true},
 		{"scram-sha-512", "SCRAM-SHA-512", true},
 		{"aws_msk_iam", "AWS_MSK_IAM", true},
+		{"oauthbearer", "OAUTHBEARER", true},
 		{"invalid", "INVALID", false},
 		{"empty", "", true}, // empty is valid when no SASL
 	}
@@ -148,7 +154,7 @@ func TestConsumerConfig_SASLMechanisms(t *testing.T) {
 			// Mock validation
 			valid := tt.mechanism == "" || tt.mechanism == "PLAIN" ||
 				tt.mechanism == "SCRAM-SHA-256" || tt.mechanism == "SCRAM-SHA-512" ||
-				tt.mechanism == "AWS_MSK_IAM"
+				tt.mechanism == "AWS_MSK_IAM" || tt.mechanism == "OAUTHBEARER"
 
 			if valid != tt.valid {
 				t.Errorf("Mechanism %v validation = %v, want %v", tt.mechanism, valid, tt.valid)
@@ -325,3 +331,384 @@ func TestConsumerErrorHandling(t *testing.T) {
 		})
 	}
 }
+
+// mockConsumerMetrics implements MetricsCollector for testing.
+type mockConsumerMetrics struct {
+	discovered []string
+	subscribed float64
+}
+
+func (m *mockConsumerMetrics) IncMessagesConsumed(topic string, partition int32)             {}
+func (m *mockConsumerMetrics) IncRebalances(groupID string)                                  {}
+func (m *mockConsumerMetrics) IncOffsetCommits(topic string, partition int32, status string) {}
+func (m *mockConsumerMetrics) ObserveRebalanceDuration(groupID string, duration float64)     {}
+func (m *mockConsumerMetrics) ObserveCommitLatency(topic string, partition int32, d float64) {}
+func (m *mockConsumerMetrics) SetPartitionsAssigned(topic string, count float64)             {}
+
+func (m *mockConsumerMetrics) IncTopicsDiscovered(topic string) {
+	m.discovered = append(m.discovered, topic)
+}
+
+func (m *mockConsumerMetrics) SetSubscribedTopics(count float64) {
+	m.subscribed = count
+}
+
+func TestSaramaConsumer_PatternSubscription(t *testing.T) {
+	lister := &fakeTopicLister{topics: []string{"orders.events", "orders.events-dlq"}}
+	discovery, err := newTopicDiscovery(lister, `.*\.events`, []string{"-dlq"})
+	if err != nil {
+		t.Fatalf("newTopicDiscovery() error = %v", err)
+	}
+	metrics := &mockConsumerMetrics{}
+	c := &SaramaConsumer{
+		discovery: discovery,
+		config:    ConsumerConfig{TopicPattern: `.*\.events`},
+		logger:    slog.New(slog.NewTextHandler(os.Stdout, nil)),
+		metrics:   metrics,
+	}
+
+	if err := c.Subscribe(context.Background(), []string{"audit"}); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if got, want := c.subscribedTopics(), []string{"audit", "orders.events"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("subscribed topics = %v, want %v", got, want)
+	}
+
+	// No new topics: the session is kept
+	cancelled := false
+	c.cancelSession = func() { cancelled = true }
+	if err := c.refreshSubscription(); err != nil {
+		t.Fatalf("refreshSubscription() error = %v", err)
+	}
+	if cancelled || len(metrics.discovered) != 0 {
+		t.Error("expected no resubscription without new topics")
+	}
+
+	// A new matching topic ends the session so the group rejoins with it
+	lister.topics = append(lister.topics, "payments.events", "payments.events-dlq")
+	if err := c.refreshSubscription(); err != nil {
+		t.Fatalf("refreshSubscription() error = %v", err)
+	}
+	if !cancelled {
+		t.Error("expected the consumer group session to be cancelled")
+	}
+	if got, want := c.subscribedTopics(), []string{"audit", "orders.events", "payments.events"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("subscribed topics = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(metrics.discovered, []string{"payments.events"}) || metrics.subscribed != 3 {
+		t.Errorf("metrics discovered = %v, subscribed = %v", metrics.discovered, metrics.subscribed)
+	}
+}
+
+func TestSaramaConsumer_PatternSubscriptionNoTopics(t *testing.T) {
+	discovery, err := newTopicDiscovery(&fakeTopicLister{topics: []string{"clickstream"}}, `.*\.events`, nil)
+	if err != nil {
+		t.Fatalf("newTopicDiscovery() error = %v", err)
+	}
+	c := &SaramaConsumer{
+		discovery: discovery,
+		logger:    slog.New(slog.NewTextHandler(os.Stdout, nil)),
+	}
+	if err := c.Subscribe(context.Background(), nil); err == nil {
+		t.Error("expected error when no topics match the pattern")
+	}
+}
+
+func TestSaramaConsumer_RetryPatternSubscription(t *testing.T) {
+	lister := &fakeTopicLister{topics: []string{"orders.events"}}
+	pattern := RetryTopicPattern(nil, `.*\.events`, "-retry")
+	discovery, err := newTopicDiscovery(lister, pattern, nil)
+	if err != nil {
+		t.Fatalf("newTopicDiscovery() error = %v", err)
+	}
+	c := &SaramaConsumer{
+		discovery:   discovery,
+		config:      ConsumerConfig{TopicPattern: pattern, Retry: true},
+		logger:      slog.New(slog.NewTextHandler(os.Stdout, nil)),
+		topicsAdded: make(chan struct{}, 1),
+	}
+
+	// Retry topics that do not exist yet are not an error
+	if err := c.Subscribe(context.Background(), nil); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if topics := c.Topics(); len(topics) != 0 {
+		t.Errorf("subscribed topics = %v, want none", topics)
+	}
+
+	// They are picked up once created
+	lister.topics = append(lister.topics, "orders.events-retry-1")
+	if err := c.refreshSubscription(); err != nil {
+		t.Fatalf("refreshSubscription() error = %v", err)
+	}
+	if got, want := c.Topics(), []string{"orders.events-retry-1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("subscribed topics = %v, want %v", got, want)
+	}
+	select {
+	case <-c.topicsAdded:
+	default:
+		t.Error("expected added topics to be signalled")
+	}
+}
+
+func TestKafkaVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    sarama.KafkaVersion
+		wantErr bool
+	}{
+		{version: "", want: sarama.V2_8_0_0},
+		{version: "3.6.0", want: sarama.V3_6_0_0},
+		{version: "not-a-version", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			got, err := kafkaVersion(tt.version)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("kafkaVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("kafkaVersion() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBalanceStrategy(t *testing.T) {
+	tests := []struct {
+		name     string
+		wantName string
+		wantErr  bool
+	}{
+		{name: "", wantName: sarama.RoundRobinBalanceStrategyName},
+		{name: RebalanceRoundRobin, wantName: sarama.RoundRobinBalanceStrategyName},
+		{name: RebalanceRange, wantName: sarama.RangeBalanceStrategyName},
+		{name: RebalanceSticky, wantName: sarama.StickyBalanceStrategyName},
+		{name: "cooperative", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := balanceStrategy(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("balanceStrategy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.Name() != tt.wantName {
+				t.Errorf("balanceStrategy() = %s, want %s", got.Name(), tt.wantName)
+			}
+		})
+	}
+}
+
+func TestNewConsumerSaramaConfig(t *testing.T) {
+	base := ConsumerConfig{
+		GroupID:             "test-group",
+		SecurityProtocol:    "PLAINTEXT",
+		SessionTimeoutMS:    30000,
+		HeartbeatIntervalMS: 10000,
+	}
+
+	t.Run("tuning applied", func(t *testing.T) {
+		config := base
+		config.KafkaVersion = "3.6.0"
+		config.RebalanceStrategy = RebalanceSticky
+		config.GroupInstanceID = "event-store-0"
+		config.FetchMinBytes = 1024
+		config.FetchMaxBytes = 50 * 1024 * 1024
+		config.ChannelBufferSize = 512
+
+		saramaConfig, err := newConsumerSaramaConfig(config)
+		if err != nil {
+			t.Fatalf("newConsumerSaramaConfig() error = %v", err)
+		}
+		if saramaConfig.Version != sarama.V3_6_0_0 {
+			t.Errorf("Version = %v, want 3.6.0", saramaConfig.Version)
+		}
+		if got := saramaConfig.Consumer.Group.Rebalance.GroupStrategies[0].Name(); got != sarama.StickyBalanceStrategyName {
+			t.Errorf("rebalance strategy = %s, want sticky", got)
+		}
+		if saramaConfig.Consumer.Group.InstanceId != "event-store-0" {
+			t.Errorf("InstanceId = %s, want event-store-0", saramaConfig.Consumer.Group.InstanceId)
+		}
+		if saramaConfig.Consumer.Fetch.Min != 1024 || saramaConfig.Consumer.Fetch.Max != 50*1024*1024 {
+			t.Errorf("Fetch = %d/%d, want 1024/%d", saramaConfig.Consumer.Fetch.Min, saramaConfig.Consumer.Fetch.Max, 50*1024*1024)
+		}
+		if saramaConfig.ChannelBufferSize != 512 {
+			t.Errorf("ChannelBufferSize = %d, want 512", saramaConfig.ChannelBufferSize)
+		}
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		saramaConfig, err := newConsumerSaramaConfig(base)
+		if err != nil {
+			t.Fatalf("newConsumerSaramaConfig() error = %v", err)
+		}
+		if saramaConfig.Version != sarama.V2_8_0_0 {
+			t.Errorf("Version = %v, want 2.8.0", saramaConfig.Version)
+		}
+		if got := saramaConfig.Consumer.Group.Rebalance.GroupStrategies[0].Name(); got != sarama.RoundRobinBalanceStrategyName {
+			t.Errorf("rebalance strategy = %s, want roundrobin", got)
+		}
+		if saramaConfig.Consumer.Group.InstanceId != "" {
+			t.Errorf("InstanceId = %s, want empty", saramaConfig.Consumer.Group.InstanceId)
+		}
+	})
+
+	t.Run("static membership requires kafka 2.3", func(t *testing.T) {
+		config := base
+		config.KafkaVersion = "2.1.0"
+		config.GroupInstanceID = "event-store-0"
+		if _, err := newConsumerSaramaConfig(config); err == nil {
+			t.Error("expected error for static membership on kafka 2.1")
+		}
+	})
+
+	t.Run("invalid strategy", func(t *testing.T) {
+		config := base
+		config.RebalanceStrategy = "cooperative"
+		if _, err := newConsumerSaramaConfig(config); err == nil {
+			t.Error("expected error for unsupported strategy")
+		}
+	})
+}
+
+// fakeSession is a consumer group session that records marked messages.
+type fakeSession struct {
+	ctx    context.Context
+	marked []*sarama.ConsumerMessage
+}
+
+func (s *fakeSession) Claims() map[string][]int32               { return nil }
+func (s *fakeSession) MemberID() string                         { return "member" }
+func (s *fakeSession) GenerationID() int32                      { return 1 }
+func (s *fakeSession) MarkOffset(string, int32, int64, string)  {}
+func (s *fakeSession) Commit()                                  {}
+func (s *fakeSession) ResetOffset(string, int32, int64, string) {}
+func (s *fakeSession) Context() context.Context                 { return s.ctx }
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, _ string) {
+	s.marked = append(s.marked, msg)
+}
+
+func TestConsumerGroupHandler_HandlePoison(t *testing.T) {
+	message := &sarama.ConsumerMessage{Topic: "orders", Partition: 1, Offset: 9, Value: []byte("not json")}
+	parseErr := errors.New("invalid character")
+
+	tests := []struct {
+		name       string
+		handler    func(cancel context.CancelFunc) PoisonHandler
+		wantMarked bool
+		wantOK     bool
+	}{
+		{name: "no handler", handler: func(context.CancelFunc) PoisonHandler { return nil }, wantMarked: false, wantOK: true},
+		{
+			name: "handled",
+			handler: func(context.CancelFunc) PoisonHandler {
+				return func(ctx context.Context, raw RawMessage, err error) error {
+					if raw.Topic != "orders" || raw.Offset != 9 || string(raw.Value) != "not json" || err != parseErr {
+						return fmt.Errorf("unexpected message %+v, error %v", raw, err)
+					}
+					return nil
+				}
+			},
+			wantMarked: true,
+			wantOK:     true,
+		},
+		{
+			name: "handler fails once",
+			handler: func(context.CancelFunc) PoisonHandler {
+				calls := 0
+				return func(context.Context, RawMessage, error) error {
+					if calls++; calls == 1 {
+						return errors.New("dlq unavailable")
+					}
+					return nil
+				}
+			},
+			wantMarked: true,
+			wantOK:     true,
+		},
+		{
+			name: "session ends while handler fails",
+			handler: func(cancel context.CancelFunc) PoisonHandler {
+				return func(context.Context, RawMessage, error) error {
+					cancel()
+					return errors.New("dlq unavailable")
+				}
+			},
+			wantMarked: false,
+			wantOK:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			consumer := &SaramaConsumer{logger: slog.New(slog.NewTextHandler(os.Stdout, nil))}
+			consumer.SetPoisonHandler(tt.handler(cancel))
+			handler := &consumerGroupHandler{consumer: consumer}
+			session := &fakeSession{ctx: ctx}
+
+			ok := handler.handlePoison(session, message, parseErr)
+
+			if ok != tt.wantOK {
+				t.Errorf("handlePoison() = %v, want %v", ok, tt.wantOK)
+			}
+			if marked := len(session.marked) == 1; marked != tt.wantMarked {
+				t.Errorf("marked = %v, want %v", marked, tt.wantMarked)
+			}
+		})
+	}
+}
+
+// fakeClaim is a consumer group claim of a channel of messages.
+type fakeClaim struct {
+	messages chan *sarama.ConsumerMessage
+}
+
+func (c *fakeClaim) Topic() string                            { return "orders-retry-1" }
+func (c *fakeClaim) Partition() int32                         { return 0 }
+func (c *fakeClaim) InitialOffset() int64                     { return 0 }
+func (c *fakeClaim) HighWaterMarkOffset() int64               { return 0 }
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+func TestConsumerGroupHandler_ConsumeClaimUnparseableRetry(t *testing.T) {
+	message := &sarama.ConsumerMessage{Topic: "orders-retry-1", Offset: 3, Value: []byte("not json")}
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 1)}
+	claim.messages <- message
+	close(claim.messages)
+
+	consumer := &SaramaConsumer{
+		config: ConsumerConfig{Retry: true},
+		logger: slog.New(slog.NewTextHandler(os.Stdout, nil)),
+	}
+	var handled []RawMessage
+	consumer.SetPoisonHandler(func(ctx context.Context, raw RawMessage, err error) error {
+		handled = append(handled, raw)
+		return nil
+	})
+	handler := &consumerGroupHandler{consumer: consumer, errorChan: make(chan error, 1)}
+	session := &fakeSession{ctx: context.Background()}
+
+	if err := handler.ConsumeClaim(session, claim); err != nil {
+		t.Fatalf("ConsumeClaim() error = %v", err)
+	}
+	if len(handled) != 1 || handled[0].Offset != 3 {
+		t.Errorf("poison handler got %+v, want the retry message", handled)
+	}
+	if len(session.marked) != 1 {
+		t.Errorf("marked %d messages, want the handled retry message", len(session.marked))
+	}
+}
+
+func TestConsumerGroupHandler_ExtractHeaders(t *testing.T) {
+	handler := &consumerGroupHandler{}
+	headers := handler.extractHeaders([]*sarama.RecordHeader{
+		{Key: []byte("trace"), Value: []byte("abc")},
+		{Key: []byte(HeaderRedacted), Value: []byte("true")},
+	})
+	if headers["trace"] != "abc" || len(headers) != 1 {
+		t.Errorf("extractHeaders() = %v, want only trace", headers)
+	}
+}
@@ -0,0 +1,116 @@
+// Package kafka implements pattern-based topic discovery.
+package kafka
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// internalTopicPrefix marks Kafka internal topics such as __consumer_offsets.
+const internalTopicPrefix = "__"
+
+// topicLister lists the topics of a Kafka cluster; sarama.Client implements it.
+type topicLister interface {
+	RefreshMetadata(topics ...string) error
+	Topics() ([]string, error)
+}
+
+// topicDiscovery finds the cluster topics matching a subscription pattern.
+type topicDiscovery struct {
+	lister          topicLister
+	pattern         *regexp.Regexp
+	excludeSuffixes []string
+}
+
+// newTopicDiscovery creates a discovery for topics whose whole name matches
+// pattern. Topics ending in one of excludeSuffixes, such as DLQ topics, and
+// Kafka internal topics are never matched.
+func newTopicDiscovery(lister topicLister, pattern string, excludeSuffixes []string) (*topicDiscovery, error) {
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid topic pattern: %w", err)
+	}
+
+	var suffixes []string
+	for _, suffix := range excludeSuffixes {
+		if suffix != "" {
+			suffixes = append(suffixes, suffix)
+		}
+	}
+
+	return &topicDiscovery{
+		lister:          lister,
+		pattern:         re,
+		excludeSuffixes: suffixes,
+	}, nil
+}
+
+// discover refreshes the cluster metadata and returns the matching topics.
+func (d *topicDiscovery) discover() ([]string, error) {
+	if err := d.lister.RefreshMetadata(); err != nil {
+		return nil, fmt.Errorf("failed to refresh metadata: %w", err)
+	}
+	topics, err := d.lister.Topics()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list topics: %w", err)
+	}
+	return d.match(topics), nil
+}
+
+// match returns the sorted topics that match the pattern.
+func (d *topicDiscovery) match(topics []string) []string {
+	var matched []string
+	for _, topic := range topics {
+		if strings.HasPrefix(topic, internalTopicPrefix) || d.excluded(topic) {
+			continue
+		}
+		if d.pattern.MatchString(topic) {
+			matched = append(matched, topic)
+		}
+	}
+	sort.Strings(matched)
+	return matched
+}
+
+// excluded reports whether topic ends in an excluded suffix.
+func (d *topicDiscovery) excluded(topic string) bool {
+	for _, suffix := range d.excludeSuffixes {
+		if strings.HasSuffix(topic, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeTopics returns the sorted union of topic lists.
+func mergeTopics(lists ...[]string) []string {
+	seen := make(map[string]bool)
+	var merged []string
+	for _, topics := range lists {
+		for _, topic := range topics {
+			if !seen[topic] {
+				seen[topic] = true
+				merged = append(merged, topic)
+			}
+		}
+	}
+	sort.Strings(merged)
+	return merged
+}
+
+// addedTopics returns the topics in next that are not in current.
+func addedTopics(current, next []string) []string {
+	existing := make(map[string]bool, len(current))
+	for _, topic := range current {
+		existing[topic] = true
+	}
+	var added []string
+	for _, topic := range next {
+		if !existing[topic] {
+			added = append(added, topic)
+		}
+	}
+	return added
+}
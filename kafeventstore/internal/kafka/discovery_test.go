@@ -0,0 +1,130 @@
+package kafka
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// fakeTopicLister returns a fixed topic list for discovery tests.
+type fakeTopicLister struct {
+	topics     []string
+	err        error
+	refreshes  int
+	refreshErr error
+}
+
+func (l *fakeTopicLister) RefreshMetadata(topics ...string) error {
+	l.refreshes++
+	return l.refreshErr
+}
+
+func (l *fakeTopicLister) Topics() ([]string, error) {
+	return l.topics, l.err
+}
+
+func TestNewTopicDiscovery_InvalidPattern(t *testing.T) {
+	if _, err := newTopicDiscovery(&fakeTopicLister{}, "(orders", nil); err == nil {
+		t.Error("expected error for invalid pattern")
+	}
+}
+
+func TestTopicDiscovery_Discover(t *testing.T) {
+	lister := &fakeTopicLister{topics: []string{
+		"payments.events",
+		"orders.events",
+		"orders.events-dlq",
+		"orders.events.audit-dlq",
+		"orders.events.v2",
+		"__consumer_offsets",
+		"clickstream",
+	}}
+
+	tests := []struct {
+		name     string
+		pattern  string
+		suffixes []string
+		want     []string
+	}{
+		{
+			name:     "matches whole names and excludes DLQ topics",
+			pattern:  `.*\.events.*`,
+			suffixes: []string{"-dlq", ".audit-dlq", ""},
+			want:     []string{"orders.events", "orders.events.v2", "payments.events"},
+		},
+		{
+			name:    "anchored pattern",
+			pattern: `.*\.events`,
+			want:    []string{"orders.events", "payments.events"},
+		},
+		{
+			name:     "internal topics are never matched",
+			pattern:  `.*`,
+			suffixes: []string{"-dlq"},
+			want: []string{
+				"clickstream",
+				"orders.events",
+				"orders.events.v2",
+				"payments.events",
+			},
+		},
+		{name: "no match", pattern: `billing\..*`, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			discovery, err := newTopicDiscovery(lister, tt.pattern, tt.suffixes)
+			if err != nil {
+				t.Fatalf("newTopicDiscovery() error = %v", err)
+			}
+			got, err := discovery.discover()
+			if err != nil {
+				t.Fatalf("discover() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("discover() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTopicDiscovery_DiscoverErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		lister *fakeTopicLister
+	}{
+		{name: "metadata refresh fails", lister: &fakeTopicLister{refreshErr: errors.New("broker unavailable")}},
+		{name: "topic listing fails", lister: &fakeTopicLister{err: errors.New("broker unavailable")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			discovery, err := newTopicDiscovery(tt.lister, `.*`, nil)
+			if err != nil {
+				t.Fatalf("newTopicDiscovery() error = %v", err)
+			}
+			if _, err := discovery.discover(); err == nil {
+				t.Error("expected discover() error")
+			}
+		})
+	}
+}
+
+func TestMergeTopics(t *testing.T) {
+	got := mergeTopics([]string{"b", "a"}, []string{"a", "c"}, nil)
+	want := []string{"a", "b", "c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeTopics() = %v, want %v", got, want)
+	}
+}
+
+func TestAddedTopics(t *testing.T) {
+	got := addedTopics([]string{"a", "b"}, []string{"a", "b", "c", "d"})
+	want := []string{"c", "d"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("addedTopics() = %v, want %v", got, want)
+	}
+	if got := addedTopics([]string{"a", "b"}, []string{"a"}); got != nil {
+		t.Errorf("addedTopics() = %v, want none", got)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"sync"
 	"time"
 
@@ -19,6 +20,8 @@ import (
 var _ consumer.DLQPublisher = (*DLQPublisher)(nil)
 
 // DLQEvent represents an event published to the dead letter queue.
+// Messages that could not be parsed have no OriginalEvent; their raw key,
+// value, headers and timestamp are kept instead.
 type DLQEvent struct {
 	OriginalEvent     json.RawMessage `json:"original_event"`
 	OriginalTopic     string          `json:"original_topic"`
@@ -28,13 +31,95 @@ type DLQEvent struct {
 	FailureTimestamp  time.Time       `json:"failure_timestamp"`
 	RetryCount        int             `json:"retry_count"`
 	ProcessorID       string          `json:"processor_id"`
+
+	OriginalKey       []byte      `json:"original_key,omitempty"`
+	OriginalValue     []byte      `json:"original_value,omitempty"`
+	OriginalHeaders   []RawHeader `json:"original_headers,omitempty"`
+	OriginalTimestamp time.Time   `json:"original_timestamp,omitzero"`
+	FailureDetail     string      `json:"failure_detail,omitempty"`
+	// FailurePointer is the JSON pointer of the value of the event data that
+	// violates its schema.
+	FailurePointer string `json:"failure_pointer,omitempty"`
+
+	ErrorClass string       `json:"error_class,omitempty"`
+	Attempts   []DLQAttempt `json:"attempts,omitempty"`
+	// Redacted reports whether the data of OriginalEvent has been redacted
+	// or removed.
+	Redacted bool `json:"redacted,omitempty"`
+}
+
+// RawMessage is a Kafka message as it was consumed, before parsing.
+type RawMessage struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   []RawHeader
+	Timestamp time.Time
+}
+
+// RawHeader is a Kafka record header. Values are kept as bytes because
+// headers need not be valid UTF-8.
+type RawHeader struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// newRawMessage copies a consumed Kafka message.
+func newRawMessage(message *sarama.ConsumerMessage) RawMessage {
+	headers := make([]RawHeader, 0, len(message.Headers))
+	for _, header := range message.Headers {
+		if header == nil {
+			continue
+		}
+		headers = append(headers, RawHeader{Key: string(header.Key), Value: header.Value})
+	}
+	return RawMessage{
+		Topic:     message.Topic,
+		Partition: message.Partition,
+		Offset:    message.Offset,
+		Key:       message.Key,
+		Value:     message.Value,
+		Headers:   headers,
+		Timestamp: message.Timestamp,
+	}
+}
+
+// QuarantineHandler takes care of an event the DLQ rejected, e.g. by writing
+// it to storage. It returns an error if the event was not kept either.
+type QuarantineHandler func(ctx context.Context, dlqEvent *DLQEvent) error
+
+// DLQMetricsCollector defines metrics operations for the DLQ publisher.
+type DLQMetricsCollector interface {
+	IncDLQPublishFailures(topic string, reason string)
 }
 
 // DLQConfig contains DLQ configuration.
 type DLQConfig struct {
 	Enabled     bool
 	TopicSuffix string
-	MaxRetries  int
+	// MaxRetries is the number of retry topics a failed event passes through
+	// before it is sent to the DLQ; 0 sends failures straight to the DLQ.
+	MaxRetries int
+	// RetryTopicSuffix names the retry topics, e.g. "-retry" for orders-retry-1.
+	RetryTopicSuffix string
+	// RetryBackoff is the delay before the first retry; it doubles per attempt.
+	RetryBackoff time.Duration
+	// AutoCreateTopics creates missing DLQ and retry topics with the
+	// partitions, replication factor and retention below.
+	AutoCreateTopics       bool
+	TopicPartitions        int32
+	TopicReplicationFactor int16
+	// TopicRetention is the retention.ms of created topics; 0 keeps the
+	// broker default and a negative value keeps events forever.
+	TopicRetention time.Duration
+}
+
+// topicAdmin creates topics. It is implemented by sarama.ClusterAdmin.
+type topicAdmin interface {
+	CreateTopic(topic string, detail *sarama.TopicDetail, validateOnly bool) error
+	Close() error
 }
 
 // DLQPublisher publishes failed events to a dead letter queue.
@@ -42,6 +127,11 @@ type DLQPublisher struct {
 	producer    sarama.SyncProducer
 	config      DLQConfig
 	logger      *slog.Logger
+	metrics     DLQMetricsCollector
+	quarantine  QuarantineHandler
+	admin       topicAdmin // nil unless AutoCreateTopics is set
+	topicsMu    sync.Mutex
+	topics      map[string]bool // DLQ topics known to exist
 	mu          sync.RWMutex
 	closed      bool
 	processorID string
@@ -54,19 +144,26 @@ func NewDLQPublisher(
 	dlqConfig DLQConfig,
 	logger *slog.Logger,
 	processorID string,
+	metrics DLQMetricsCollector,
 ) (*DLQPublisher, error) {
 	if !dlqConfig.Enabled {
 		logger.Info("DLQ is disabled")
 		return &DLQPublisher{
 			config:      dlqConfig,
 			logger:      logger,
+			metrics:     metrics,
 			processorID: processorID,
 			closed:      true,
 		}, nil
 	}
 
+	version, err := kafkaVersion(securityConfig.KafkaVersion)
+	if err != nil {
+		return nil, err
+	}
+
 	saramaConfig := sarama.NewConfig()
-	saramaConfig.Version = sarama.V2_8_0_0
+	saramaConfig.Version = version
 	saramaConfig.Producer.RequiredAcks = sarama.WaitForAll
 	saramaConfig.Producer.Retry.Max = 5
 	saramaConfig.Producer.Return.Successes = true
@@ -80,31 +177,125 @@ func NewDLQPublisher(
 		return nil, fmt.Errorf("failed to configure security: %w", err)
 	}
 
-	producer, err := sarama.NewSyncProducer(bootstrapServers, saramaConfig)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create sync producer: %w", err)
+	// Topics are created through a cluster admin sharing the producer client
+	var producer sarama.SyncProducer
+	var admin topicAdmin
+	if dlqConfig.AutoCreateTopics {
+		client, err := sarama.NewClient(bootstrapServers, saramaConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create client: %w", err)
+		}
+		producer, err = sarama.NewSyncProducerFromClient(client)
+		if err != nil {
+			_ = client.Close()
+			return nil, fmt.Errorf("failed to create sync producer: %w", err)
+		}
+		admin, err = sarama.NewClusterAdminFromClient(client)
+		if err != nil {
+			_ = producer.Close()
+			_ = client.Close()
+			return nil, fmt.Errorf("failed to create cluster admin: %w", err)
+		}
+	} else {
+		producer, err = sarama.NewSyncProducer(bootstrapServers, saramaConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create sync producer: %w", err)
+		}
 	}
 
 	logger.Info("DLQ publisher created",
 		"bootstrap_servers", bootstrapServers,
 		"topic_suffix", dlqConfig.TopicSuffix,
+		"auto_create_topics", dlqConfig.AutoCreateTopics,
 	)
 
 	return &DLQPublisher{
 		producer:    producer,
 		config:      dlqConfig,
 		logger:      logger,
+		metrics:     metrics,
+		admin:       admin,
+		topics:      make(map[string]bool),
 		processorID: processorID,
 		closed:      false,
 	}, nil
 }
 
+// SetQuarantineHandler sets the handler of events the DLQ rejects. Without
+// a handler, a rejected event is reported as an error to the caller.
+func (p *DLQPublisher) SetQuarantineHandler(handler QuarantineHandler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.quarantine = handler
+}
+
 // Publish publishes a failed event to the DLQ.
 func (p *DLQPublisher) Publish(
 	ctx context.Context,
 	cloudEvent *event.CloudEvent,
 	metadata event.KafkaMetadata,
 	reason string,
+) error {
+	return p.PublishToTopic(ctx, metadata.Topic+p.config.TopicSuffix, cloudEvent, metadata, reason)
+}
+
+// PublishToTopic publishes a failed event to the given DLQ topic.
+// It is used for topics whose DLQ suffix overrides the configured one.
+func (p *DLQPublisher) PublishToTopic(
+	ctx context.Context,
+	dlqTopic string,
+	cloudEvent *event.CloudEvent,
+	metadata event.KafkaMetadata,
+	reason string,
+) error {
+	return p.PublishRejected(ctx, dlqTopic, cloudEvent, metadata, reason, nil)
+}
+
+// PublishRejected publishes an event rejected with cause to dlqTopic. The
+// cause is kept as the failure detail, together with the JSON pointer of
+// schema violations.
+func (p *DLQPublisher) PublishRejected(
+	ctx context.Context,
+	dlqTopic string,
+	cloudEvent *event.CloudEvent,
+	metadata event.KafkaMetadata,
+	reason string,
+	cause error,
+) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return errors.ErrConsumerClosed
+	}
+
+	if !p.config.Enabled {
+		p.logger.Debug("DLQ disabled, skipping publish")
+		return nil
+	}
+
+	dlqEvent, err := p.newDLQEvent(cloudEvent, metadata, reason, RetryCount(metadata))
+	if err != nil {
+		return err
+	}
+	if cause != nil {
+		dlqEvent.FailureDetail = cause.Error()
+		if validationErr, ok := cause.(*errors.ValidationError); ok {
+			dlqEvent.FailurePointer = validationErr.Pointer
+		}
+	}
+	return p.deadLetter(ctx, dlqTopic, sarama.StringEncoder(cloudEvent.ID), dlqEvent)
+}
+
+// Retry publishes an event that failed to be stored to its next retry topic,
+// or to dlqTopic once MaxRetries retries have been made. Events that cannot
+// be published to a retry topic are sent to dlqTopic instead.
+func (p *DLQPublisher) Retry(
+	ctx context.Context,
+	dlqTopic string,
+	cloudEvent *event.CloudEvent,
+	metadata event.KafkaMetadata,
+	reason string,
 ) error {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
@@ -118,27 +309,224 @@ func (p *DLQPublisher) Publish(
 		return nil
 	}
 
-	// Construct DLQ topic name
-	dlqTopic := metadata.Topic + p.config.TopicSuffix
+	retries := RetryCount(metadata)
+	dlqEvent, err := p.newDLQEvent(cloudEvent, metadata, reason, retries)
+	if err != nil {
+		return err
+	}
+	key := sarama.StringEncoder(cloudEvent.ID)
+	if retries >= p.config.MaxRetries {
+		return p.deadLetter(ctx, dlqTopic, key, dlqEvent)
+	}
+
+	attempt := retries + 1
+	retryEvent := dlqEvent
+	retryEvent.RetryCount = attempt
+	retryTopic := RetryTopic(metadata.Topic, p.config.RetryTopicSuffix, attempt)
+	retryAt := time.Now().Add(retryDelay(p.config.RetryBackoff, attempt))
+	p.ensureTopic(retryTopic)
+	if err := p.send(retryTopic, key, retryEvent, retryAt); err != nil {
+		p.logger.Warn("failed to publish to retry topic, sending to DLQ",
+			"retry_topic", retryTopic,
+			"dlq_topic", dlqTopic,
+			"event_id", cloudEvent.ID,
+			"error", err,
+		)
+		return p.deadLetter(ctx, dlqTopic, key, dlqEvent)
+	}
+	return nil
+}
 
+// PublishRaw publishes a message that could not be parsed into a CloudEvent
+// to dlqTopic. The original key, value, headers and timestamp are preserved
+// together with the parse error, so the message can be inspected and fixed.
+func (p *DLQPublisher) PublishRaw(
+	ctx context.Context,
+	dlqTopic string,
+	message RawMessage,
+	reason string,
+	parseErr error,
+) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return errors.ErrConsumerClosed
+	}
+
+	if !p.config.Enabled {
+		p.logger.Debug("DLQ disabled, skipping publish")
+		return nil
+	}
+
+	now := time.Now().UTC()
+	dlqEvent := DLQEvent{
+		OriginalTopic:     message.Topic,
+		OriginalPartition: message.Partition,
+		OriginalOffset:    message.Offset,
+		OriginalKey:       message.Key,
+		OriginalValue:     message.Value,
+		OriginalHeaders:   message.Headers,
+		OriginalTimestamp: message.Timestamp,
+		FailureReason:     reason,
+		FailureTimestamp:  now,
+		ProcessorID:       p.processorID,
+		ErrorClass:        ErrorClass(reason),
+		Attempts: []DLQAttempt{{
+			Reason:      reason,
+			ErrorClass:  ErrorClass(reason),
+			FailedAt:    now,
+			ProcessorID: p.processorID,
+		}},
+	}
+	if parseErr != nil {
+		dlqEvent.FailureDetail = parseErr.Error()
+	}
+
+	var key sarama.Encoder
+	if message.Key != nil {
+		key = sarama.ByteEncoder(message.Key)
+	}
+	return p.deadLetter(ctx, dlqTopic, key, dlqEvent)
+}
+
+// newDLQEvent creates the DLQEvent of a CloudEvent that failed to be processed.
+func (p *DLQPublisher) newDLQEvent(
+	cloudEvent *event.CloudEvent,
+	metadata event.KafkaMetadata,
+	reason string,
+	retryCount int,
+) (DLQEvent, error) {
 	// Marshal original event
 	eventData, err := json.Marshal(cloudEvent)
 	if err != nil {
-		return fmt.Errorf("failed to marshal event: %w", err)
+		return DLQEvent{}, fmt.Errorf("failed to marshal event: %w", err)
 	}
 
-	// Create DLQ event
-	dlqEvent := DLQEvent{
+	// Earlier attempts of retried events travel in the attempts header
+	now := time.Now().UTC()
+	attempts := append(parseAttempts(metadata.Headers[HeaderAttempts]), DLQAttempt{
+		Reason:      reason,
+		ErrorClass:  ErrorClass(reason),
+		FailedAt:    now,
+		ProcessorID: p.processorID,
+	})
+
+	return DLQEvent{
 		OriginalEvent:     eventData,
 		OriginalTopic:     metadata.Topic,
 		OriginalPartition: metadata.Partition,
 		OriginalOffset:    metadata.Offset,
+		OriginalKey:       metadata.Key,
 		FailureReason:     reason,
-		FailureTimestamp:  time.Now().UTC(),
-		RetryCount:        0,
+		FailureTimestamp:  now,
+		RetryCount:        retryCount,
 		ProcessorID:       p.processorID,
+		OriginalHeaders:   originalHeaders(metadata.Headers),
+		OriginalTimestamp: metadata.Timestamp,
+		ErrorClass:        ErrorClass(reason),
+		Attempts:          attempts,
+		Redacted:          IsRedacted(metadata),
+	}, nil
+}
+
+// deadLetter sends a DLQEvent to dlqTopic. An event the DLQ rejects is counted
+// and passed to the quarantine handler, and an error is returned only if the
+// handler does not keep it either. The caller must hold p.mu.
+func (p *DLQPublisher) deadLetter(ctx context.Context, dlqTopic string, key sarama.Encoder, dlqEvent DLQEvent) error {
+	p.ensureTopic(dlqTopic)
+	err := p.send(dlqTopic, key, dlqEvent, time.Time{})
+	if err == nil {
+		return nil
 	}
 
+	if p.metrics != nil {
+		p.metrics.IncDLQPublishFailures(dlqEvent.OriginalTopic, dlqEvent.FailureReason)
+	}
+	if p.quarantine == nil {
+		return err
+	}
+	if qErr := p.quarantine(ctx, &dlqEvent); qErr != nil {
+		return fmt.Errorf("%w; failed to quarantine event: %w", err, qErr)
+	}
+
+	p.logger.Warn("quarantined event rejected by the DLQ",
+		"dlq_topic", dlqTopic,
+		"original_topic", dlqEvent.OriginalTopic,
+		"original_offset", dlqEvent.OriginalOffset,
+		"reason", dlqEvent.FailureReason,
+	)
+	return nil
+}
+
+// EnsureTopics creates the missing topics among topics when AutoCreateTopics
+// is set, e.g. the retry topics before their consumer subscribes. It does
+// nothing otherwise.
+func (p *DLQPublisher) EnsureTopics(topics []string) error {
+	if p.admin == nil {
+		return nil
+	}
+
+	p.topicsMu.Lock()
+	defer p.topicsMu.Unlock()
+	for _, topic := range topics {
+		if err := p.createTopic(topic); err != nil {
+			return fmt.Errorf("failed to create topic %s: %w", topic, err)
+		}
+	}
+	return nil
+}
+
+// ensureTopic creates a missing DLQ or retry topic when AutoCreateTopics is
+// set. Each topic is created once. Failures are logged and retried on the
+// next event; the publish is attempted regardless.
+func (p *DLQPublisher) ensureTopic(topic string) {
+	if p.admin == nil {
+		return
+	}
+
+	p.topicsMu.Lock()
+	defer p.topicsMu.Unlock()
+	if err := p.createTopic(topic); err != nil {
+		p.logger.Warn("failed to create DLQ topic", "topic", topic, "error", err)
+	}
+}
+
+// createTopic creates topic unless it was created before. A topic that
+// already exists counts as created. The caller must hold p.topicsMu.
+func (p *DLQPublisher) createTopic(topic string) error {
+	if p.topics[topic] {
+		return nil
+	}
+
+	detail := &sarama.TopicDetail{
+		NumPartitions:     p.config.TopicPartitions,
+		ReplicationFactor: p.config.TopicReplicationFactor,
+	}
+	if p.config.TopicRetention != 0 {
+		retention := strconv.FormatInt(p.config.TopicRetention.Milliseconds(), 10)
+		detail.ConfigEntries = map[string]*string{"retention.ms": &retention}
+	}
+
+	err := p.admin.CreateTopic(topic, detail, false)
+	if topicErr, ok := err.(*sarama.TopicError); ok && topicErr.Err == sarama.ErrTopicAlreadyExists {
+		err = nil
+	} else if err == nil {
+		p.logger.Info("created DLQ topic",
+			"topic", topic,
+			"partitions", detail.NumPartitions,
+			"replication_factor", detail.ReplicationFactor,
+		)
+	}
+	if err != nil {
+		return err
+	}
+	p.topics[topic] = true
+	return nil
+}
+
+// send marshals a DLQEvent and sends it to topic. The caller must hold p.mu.
+func (p *DLQPublisher) send(topic string, key sarama.Encoder, dlqEvent DLQEvent, retryAt time.Time) error {
 	// Marshal DLQ event
 	dlqData, err := json.Marshal(dlqEvent)
 	if err != nil {
@@ -147,23 +535,10 @@ func (p *DLQPublisher) Publish(
 
 	// Create Kafka message
 	msg := &sarama.ProducerMessage{
-		Topic: dlqTopic,
-		Key:   sarama.StringEncoder(cloudEvent.ID),
-		Value: sarama.ByteEncoder(dlqData),
-		Headers: []sarama.RecordHeader{
-			{
-				Key:   []byte("failure_reason"),
-				Value: []byte(reason),
-			},
-			{
-				Key:   []byte("original_topic"),
-				Value: []byte(metadata.Topic),
-			},
-			{
-				Key:   []byte("processor_id"),
-				Value: []byte(p.processorID),
-			},
-		},
+		Topic:     topic,
+		Key:       key,
+		Value:     sarama.ByteEncoder(dlqData),
+		Headers:   dlqHeaders(dlqEvent, retryAt),
 		Timestamp: time.Now(),
 	}
 
@@ -172,18 +547,21 @@ func (p *DLQPublisher) Publish(
 	if err != nil {
 		p.logger.Error("failed to publish to DLQ",
 			"error", err,
-			"dlq_topic", dlqTopic,
-			"event_id", cloudEvent.ID,
+			"dlq_topic", topic,
+			"original_topic", dlqEvent.OriginalTopic,
+			"original_offset", dlqEvent.OriginalOffset,
 		)
 		return fmt.Errorf("failed to send message to DLQ: %w", err)
 	}
 
 	p.logger.Info("published event to DLQ",
-		"dlq_topic", dlqTopic,
+		"dlq_topic", topic,
 		"partition", partition,
 		"offset", offset,
-		"event_id", cloudEvent.ID,
-		"reason", reason,
+		"original_topic", dlqEvent.OriginalTopic,
+		"original_offset", dlqEvent.OriginalOffset,
+		"reason", dlqEvent.FailureReason,
+		"retry_count", dlqEvent.RetryCount,
 	)
 
 	return nil
@@ -208,6 +586,14 @@ func (p *DLQPublisher) Close() error {
 		}
 	}
 
+	// Closing the admin closes the client it shares with the producer
+	if p.admin != nil {
+		if err := p.admin.Close(); err != nil {
+			p.logger.Error("error closing cluster admin", "error", err)
+			return err
+		}
+	}
+
 	p.logger.Info("DLQ publisher closed")
 	return nil
 }
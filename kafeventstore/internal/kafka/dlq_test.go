@@ -1,10 +1,20 @@
 package kafka
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/IBM/sarama"
+	kerrors "github.com/jittakal/kafeventstore/internal/errors"
 	"github.com/jittakal/kafeventstore/pkg/event"
 )
 
@@ -393,3 +403,382 @@ func mockPublish(id int) error {
 	}
 	return nil
 }
+
+func TestDLQPublishToTopic_DisabledPublisher(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	publisher, err := NewDLQPublisher(nil, ConsumerConfig{}, DLQConfig{Enabled: false}, logger, "test", nil)
+	if err != nil {
+		t.Fatalf("NewDLQPublisher() error = %v", err)
+	}
+
+	evt := &event.CloudEvent{ID: "1"}
+	metadata := event.KafkaMetadata{Topic: "audit.events"}
+	if err := publisher.PublishToTopic(context.Background(), "audit.events.audit-dlq", evt, metadata, "validation_failed"); !errors.Is(err, kerrors.ErrConsumerClosed) {
+		t.Errorf("PublishToTopic() error = %v, want ErrConsumerClosed", err)
+	}
+	if err := publisher.Publish(context.Background(), evt, metadata, "validation_failed"); !errors.Is(err, kerrors.ErrConsumerClosed) {
+		t.Errorf("Publish() error = %v, want ErrConsumerClosed", err)
+	}
+}
+
+func TestNewRawMessage(t *testing.T) {
+	timestamp := time.Now()
+	message := &sarama.ConsumerMessage{
+		Topic:     "orders",
+		Partition: 2,
+		Offset:    7,
+		Key:       []byte("order-1"),
+		Value:     []byte{0xff, 0x00, '{'},
+		Headers: []*sarama.RecordHeader{
+			{Key: []byte("trace"), Value: []byte{0xfe}},
+			nil,
+		},
+		Timestamp: timestamp,
+	}
+
+	raw := newRawMessage(message)
+	want := RawMessage{
+		Topic:     "orders",
+		Partition: 2,
+		Offset:    7,
+		Key:       []byte("order-1"),
+		Value:     []byte{0xff, 0x00, '{'},
+		Headers:   []RawHeader{{Key: "trace", Value: []byte{0xfe}}},
+		Timestamp: timestamp,
+	}
+	if !reflect.DeepEqual(raw, want) {
+		t.Errorf("newRawMessage() = %+v, want %+v", raw, want)
+	}
+}
+
+func TestDLQPublisher_PublishRaw(t *testing.T) {
+	publisher, producer := newTestRetryPublisher(t, 0)
+	timestamp := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	raw := RawMessage{
+		Topic:     "orders",
+		Partition: 2,
+		Offset:    7,
+		Key:       []byte("order-1"),
+		Value:     []byte{0xff, 0x00, '{'},
+		Headers:   []RawHeader{{Key: "trace", Value: []byte{0xfe}}},
+		Timestamp: timestamp,
+	}
+
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		if msg.Topic != "orders-dlq" {
+			return fmt.Errorf("topic = %s, want orders-dlq", msg.Topic)
+		}
+		key, err := msg.Key.Encode()
+		if err != nil || string(key) != "order-1" {
+			return fmt.Errorf("key = %q, want order-1", key)
+		}
+
+		value, err := msg.Value.Encode()
+		if err != nil {
+			return err
+		}
+		var dlqEvent DLQEvent
+		if err := json.Unmarshal(value, &dlqEvent); err != nil {
+			return err
+		}
+		if !bytes.Equal(dlqEvent.OriginalValue, raw.Value) || !bytes.Equal(dlqEvent.OriginalKey, raw.Key) {
+			return fmt.Errorf("original key/value = %q/%q, want raw bytes", dlqEvent.OriginalKey, dlqEvent.OriginalValue)
+		}
+		if !reflect.DeepEqual(dlqEvent.OriginalHeaders, raw.Headers) {
+			return fmt.Errorf("original headers = %+v, want %+v", dlqEvent.OriginalHeaders, raw.Headers)
+		}
+		if !dlqEvent.OriginalTimestamp.Equal(timestamp) {
+			return fmt.Errorf("original timestamp = %v, want %v", dlqEvent.OriginalTimestamp, timestamp)
+		}
+		if dlqEvent.OriginalTopic != "orders" || dlqEvent.OriginalPartition != 2 || dlqEvent.OriginalOffset != 7 {
+			return fmt.Errorf("original position = %s/%d/%d, want orders/2/7", dlqEvent.OriginalTopic, dlqEvent.OriginalPartition, dlqEvent.OriginalOffset)
+		}
+		if dlqEvent.FailureReason != "deserialization_failed" || dlqEvent.FailureDetail != "invalid character" {
+			return fmt.Errorf("failure = %s/%s", dlqEvent.FailureReason, dlqEvent.FailureDetail)
+		}
+		return nil
+	})
+
+	err := publisher.PublishRaw(context.Background(), "orders-dlq", raw, "deserialization_failed", errors.New("invalid character"))
+	if err != nil {
+		t.Errorf("PublishRaw() error = %v", err)
+	}
+}
+
+func TestDLQPublisher_PublishRawDisabled(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	publisher, err := NewDLQPublisher(nil, ConsumerConfig{}, DLQConfig{Enabled: false}, logger, "test", nil)
+	if err != nil {
+		t.Fatalf("NewDLQPublisher() error = %v", err)
+	}
+
+	err = publisher.PublishRaw(context.Background(), "orders-dlq", RawMessage{Topic: "orders"}, "deserialization_failed", nil)
+	if !errors.Is(err, kerrors.ErrConsumerClosed) {
+		t.Errorf("PublishRaw() error = %v, want ErrConsumerClosed", err)
+	}
+}
+
+// fakeDLQMetrics counts DLQ publish failures.
+type fakeDLQMetrics struct {
+	failures int
+}
+
+func (m *fakeDLQMetrics) IncDLQPublishFailures(topic string, reason string) {
+	m.failures++
+}
+
+func TestDLQPublisher_Quarantine(t *testing.T) {
+	tests := []struct {
+		name           string
+		handler        bool
+		handlerErr     error
+		retryCount     string
+		wantErr        bool
+		wantQuarantine bool
+	}{
+		{name: "quarantined", handler: true, wantQuarantine: true},
+		{name: "quarantine fails", handler: true, handlerErr: errors.New("storage unavailable"), wantErr: true, wantQuarantine: true},
+		{name: "no handler", wantErr: true},
+		{name: "retries exhausted", handler: true, retryCount: "2", wantQuarantine: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			publisher, producer := newTestRetryPublisher(t, 2)
+			metrics := &fakeDLQMetrics{}
+			publisher.metrics = metrics
+			producer.ExpectSendMessageAndFail(errors.New("topic authorization failed"))
+
+			var quarantined *DLQEvent
+			if tt.handler {
+				publisher.SetQuarantineHandler(func(ctx context.Context, dlqEvent *DLQEvent) error {
+					quarantined = dlqEvent
+					return tt.handlerErr
+				})
+			}
+
+			metadata := event.KafkaMetadata{Topic: "orders", Partition: 1, Offset: 7}
+			var err error
+			if tt.retryCount != "" {
+				metadata.Headers = map[string]string{HeaderRetryCount: tt.retryCount}
+				err = publisher.Retry(t.Context(), "orders-dlq", &event.CloudEvent{ID: "evt-1"}, metadata, "storage_failed")
+			} else {
+				err = publisher.PublishToTopic(t.Context(), "orders-dlq", &event.CloudEvent{ID: "evt-1"}, metadata, "validation_failed")
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if metrics.failures != 1 {
+				t.Errorf("DLQ publish failures = %d, want 1", metrics.failures)
+			}
+			if (quarantined != nil) != tt.wantQuarantine {
+				t.Fatalf("quarantined = %+v, want quarantined %v", quarantined, tt.wantQuarantine)
+			}
+			if quarantined != nil && (quarantined.OriginalTopic != "orders" || quarantined.OriginalOffset != 7) {
+				t.Errorf("quarantined event = %+v, want orders offset 7", quarantined)
+			}
+		})
+	}
+}
+
+func TestDLQPublisher_QuarantineAfterRetryFallback(t *testing.T) {
+	publisher, producer := newTestRetryPublisher(t, 2)
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndFail(expectMessage("orders-retry-1", 1, true), errors.New("unknown topic"))
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndFail(expectMessage("orders-dlq", 0, false), errors.New("unknown topic"))
+
+	var quarantined *DLQEvent
+	publisher.SetQuarantineHandler(func(ctx context.Context, dlqEvent *DLQEvent) error {
+		quarantined = dlqEvent
+		return nil
+	})
+
+	metadata := event.KafkaMetadata{Topic: "orders"}
+	if err := publisher.Retry(t.Context(), "orders-dlq", &event.CloudEvent{ID: "evt-1"}, metadata, "storage_failed"); err != nil {
+		t.Errorf("Retry() error = %v", err)
+	}
+	if quarantined == nil || quarantined.RetryCount != 0 || quarantined.FailureReason != "storage_failed" {
+		t.Errorf("quarantined event = %+v, want storage_failed with no retries", quarantined)
+	}
+}
+
+// fakeTopicAdmin records created topics and returns queued errors.
+type fakeTopicAdmin struct {
+	created []string
+	details []*sarama.TopicDetail
+	errs    []error
+	closed  bool
+}
+
+func (a *fakeTopicAdmin) CreateTopic(topic string, detail *sarama.TopicDetail, validateOnly bool) error {
+	a.created = append(a.created, topic)
+	a.details = append(a.details, detail)
+	if len(a.errs) > 0 {
+		err := a.errs[0]
+		a.errs = a.errs[1:]
+		return err
+	}
+	return nil
+}
+
+func (a *fakeTopicAdmin) Close() error {
+	a.closed = true
+	return nil
+}
+
+func TestDLQPublisher_AutoCreateTopics(t *testing.T) {
+	publisher, producer := newTestRetryPublisher(t, 0)
+	admin := &fakeTopicAdmin{errs: []error{
+		errors.New("controller unavailable"),
+		nil,
+		&sarama.TopicError{Err: sarama.ErrTopicAlreadyExists},
+	}}
+	publisher.admin = admin
+	publisher.topics = make(map[string]bool)
+	publisher.config.TopicPartitions = 3
+	publisher.config.TopicReplicationFactor = 2
+	publisher.config.TopicRetention = 7 * 24 * time.Hour
+
+	publish := func(topic string) {
+		t.Helper()
+		producer.ExpectSendMessageAndSucceed()
+		metadata := event.KafkaMetadata{Topic: topic}
+		if err := publisher.PublishToTopic(t.Context(), topic+"-dlq", &event.CloudEvent{ID: "evt-1"}, metadata, ReasonValidationFailed); err != nil {
+			t.Fatalf("PublishToTopic() error = %v", err)
+		}
+	}
+
+	// A failed creation is retried with the next event, then cached
+	publish("orders")
+	publish("orders")
+	publish("orders")
+	// An existing topic counts as created
+	publish("payments")
+	publish("payments")
+
+	wantCreated := []string{"orders-dlq", "orders-dlq", "payments-dlq"}
+	if !reflect.DeepEqual(admin.created, wantCreated) {
+		t.Errorf("created topics = %v, want %v", admin.created, wantCreated)
+	}
+	detail := admin.details[0]
+	if detail.NumPartitions != 3 || detail.ReplicationFactor != 2 {
+		t.Errorf("topic detail = %+v, want 3 partitions and replication factor 2", detail)
+	}
+	if retention := detail.ConfigEntries["retention.ms"]; retention == nil || *retention != "604800000" {
+		t.Errorf("retention.ms = %v, want 604800000", retention)
+	}
+
+	if err := publisher.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if !admin.closed {
+		t.Error("cluster admin was not closed")
+	}
+}
+
+func TestDLQPublisher_AutoCreateRetryTopics(t *testing.T) {
+	publisher, producer := newTestRetryPublisher(t, 2)
+	admin := &fakeTopicAdmin{}
+	publisher.admin = admin
+	publisher.topics = make(map[string]bool)
+
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(expectMessage("orders-retry-1", 1, true))
+	if err := publisher.Retry(t.Context(), "orders-dlq", &event.CloudEvent{ID: "evt-1"}, event.KafkaMetadata{Topic: "orders"}, ReasonStorageFailed); err != nil {
+		t.Fatalf("Retry() error = %v", err)
+	}
+
+	if want := []string{"orders-retry-1"}; !reflect.DeepEqual(admin.created, want) {
+		t.Errorf("created topics = %v, want %v", admin.created, want)
+	}
+}
+
+func TestDLQPublisher_EnsureTopics(t *testing.T) {
+	publisher, _ := newTestRetryPublisher(t, 2)
+
+	// Without AutoCreateTopics nothing is created
+	if err := publisher.EnsureTopics([]string{"orders-retry-1"}); err != nil {
+		t.Errorf("EnsureTopics() error = %v", err)
+	}
+
+	admin := &fakeTopicAdmin{errs: []error{nil, errors.New("not authorized")}}
+	publisher.admin = admin
+	publisher.topics = make(map[string]bool)
+	topics := []string{"orders-retry-1", "orders-retry-2"}
+	if err := publisher.EnsureTopics(topics); err == nil {
+		t.Error("EnsureTopics() succeeded, want error")
+	}
+	if err := publisher.EnsureTopics(topics); err != nil {
+		t.Errorf("EnsureTopics() error = %v", err)
+	}
+
+	// Created topics are not created again
+	if want := []string{"orders-retry-1", "orders-retry-2", "orders-retry-2"}; !reflect.DeepEqual(admin.created, want) {
+		t.Errorf("created topics = %v, want %v", admin.created, want)
+	}
+}
+
+func TestDLQPublisher_EnrichesHeaders(t *testing.T) {
+	publisher, producer := newTestRetryPublisher(t, 0)
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		headers := make(map[string]string)
+		for _, header := range msg.Headers {
+			headers[string(header.Key)] = string(header.Value)
+		}
+		want := map[string]string{
+			"trace":                 "abc",
+			"ce_type":               "order.created",
+			HeaderErrorClass:        ErrorClassPermanent,
+			HeaderOriginalTimestamp: "1700000000000",
+			HeaderOriginalOffset:    "9",
+		}
+		for key, value := range want {
+			if headers[key] != value {
+				return fmt.Errorf("header %s = %q, want %q", key, headers[key], value)
+			}
+		}
+		if attempts := parseAttempts(headers[HeaderAttempts]); len(attempts) != 1 || attempts[0].Reason != ReasonValidationFailed {
+			return fmt.Errorf("attempts = %s, want one validation failure", headers[HeaderAttempts])
+		}
+		return nil
+	})
+
+	metadata := event.KafkaMetadata{
+		Topic:     "orders",
+		Offset:    9,
+		Timestamp: time.UnixMilli(1700000000000),
+		Headers:   map[string]string{"trace": "abc"},
+	}
+	cloudEvent := &event.CloudEvent{ID: "evt-1", Source: "orders", SpecVersion: "1.0", Type: "order.created"}
+	if err := publisher.PublishToTopic(t.Context(), "orders-dlq", cloudEvent, metadata, ReasonValidationFailed); err != nil {
+		t.Errorf("PublishToTopic() error = %v", err)
+	}
+}
+
+func TestDLQPublisher_PublishRejected(t *testing.T) {
+	publisher, producer := newTestRetryPublisher(t, 0)
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		value, _ := msg.Value.Encode()
+		var dlqEvent DLQEvent
+		if err := json.Unmarshal(value, &dlqEvent); err != nil {
+			return err
+		}
+		if dlqEvent.FailureReason != ReasonSchemaValidationFailed || dlqEvent.FailurePointer != "/items/0/price" {
+			return fmt.Errorf("failure = %s at %q", dlqEvent.FailureReason, dlqEvent.FailurePointer)
+		}
+		if !strings.Contains(dlqEvent.FailureDetail, "must be >= 0") {
+			return fmt.Errorf("failure_detail = %q", dlqEvent.FailureDetail)
+		}
+		for _, header := range msg.Headers {
+			if string(header.Key) == HeaderFailurePointer && string(header.Value) == "/items/0/price" {
+				return nil
+			}
+		}
+		return fmt.Errorf("missing %s header", HeaderFailurePointer)
+	})
+
+	cause := &kerrors.ValidationError{EventID: "evt-1", Field: "data", Reason: "must be >= 0", Pointer: "/items/0/price"}
+	cloudEvent := &event.CloudEvent{ID: "evt-1", Source: "orders", SpecVersion: "1.0", Type: "order.created"}
+	err := publisher.PublishRejected(t.Context(), "orders-dlq", cloudEvent, event.KafkaMetadata{Topic: "orders"}, ReasonSchemaValidationFailed, cause)
+	if err != nil {
+		t.Errorf("PublishRejected() error = %v", err)
+	}
+}
@@ -0,0 +1,283 @@
+// Package kafka implements the headers of DLQ and retry messages.
+package kafka
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/jittakal/kafeventstore/pkg/event"
+)
+
+// Headers written to DLQ and retry messages, so DLQ consumers can route and
+// triage events without unwrapping the JSON value.
+const (
+	HeaderFailureReason     = "failure_reason"
+	HeaderErrorClass        = "error_class"
+	HeaderOriginalTopic     = "original_topic"
+	HeaderOriginalPartition = "original_partition"
+	HeaderOriginalOffset    = "original_offset"
+	// HeaderOriginalTimestamp holds the Kafka timestamp of the original
+	// message as Unix time in milliseconds.
+	HeaderOriginalTimestamp = "original_timestamp"
+	HeaderProcessorID       = "processor_id"
+	// HeaderFailurePointer holds the JSON pointer of a schema violation.
+	HeaderFailurePointer = "failure_pointer"
+	// HeaderAttempts holds the JSON list of failed processing attempts.
+	HeaderAttempts = "attempts"
+	// CloudEventHeaderPrefix prefixes the CloudEvent attributes, e.g. ce_type.
+	CloudEventHeaderPrefix = "ce_"
+	// headerContentType holds the data content type of binary mode events.
+	headerContentType = "content-type"
+)
+
+// Failure reasons of DLQ events.
+const (
+	ReasonValidationFailed       = "validation_failed"
+	ReasonStorageFailed          = "storage_failed"
+	ReasonDeserializationFailed  = "deserialization_failed"
+	ReasonSchemaValidationFailed = "schema_validation_failed"
+	ReasonDecodingFailed         = "decoding_failed"
+	ReasonSchemaIncompatible     = "schema_incompatible"
+	ReasonRedactionFailed        = "redaction_failed"
+	ReasonSchemaUnavailable      = "schema_unavailable"
+)
+
+// Error classes of failure reasons.
+const (
+	// ErrorClassTransient marks failures that may succeed when the event is redriven.
+	ErrorClassTransient = "transient"
+	// ErrorClassPermanent marks failures that need the event or the configuration fixed.
+	ErrorClassPermanent = "permanent"
+)
+
+// ErrorClass returns the error class of a failure reason. Storage failures
+// and unavailable schemas are transient; all other reasons are permanent.
+func ErrorClass(reason string) string {
+	switch reason {
+	case ReasonStorageFailed, ReasonSchemaUnavailable:
+		return ErrorClassTransient
+	default:
+		return ErrorClassPermanent
+	}
+}
+
+// DLQAttempt is a failed attempt to process an event.
+type DLQAttempt struct {
+	Reason      string    `json:"reason"`
+	ErrorClass  string    `json:"error_class"`
+	FailedAt    time.Time `json:"failed_at"`
+	ProcessorID string    `json:"processor_id"`
+}
+
+// parseAttempts decodes the attempts header of a retried event. Missing or
+// invalid values yield no attempts.
+func parseAttempts(value string) []DLQAttempt {
+	if value == "" {
+		return nil
+	}
+	var attempts []DLQAttempt
+	if err := json.Unmarshal([]byte(value), &attempts); err != nil {
+		return nil
+	}
+	return attempts
+}
+
+// originalHeaders returns the headers of a consumed event sorted by key,
+// without the headers that carry retry state between retry topics.
+func originalHeaders(headers map[string]string) []RawHeader {
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		switch key {
+		case HeaderRetryCount, HeaderRetryAt, HeaderAttempts, HeaderRedacted:
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	raw := make([]RawHeader, 0, len(keys))
+	for _, key := range keys {
+		raw = append(raw, RawHeader{Key: key, Value: []byte(headers[key])})
+	}
+	return raw
+}
+
+// cloudEventHeaders returns a ce_ header for every attribute of a CloudEvent.
+func cloudEventHeaders(cloudEvent *event.CloudEvent) []sarama.RecordHeader {
+	attributes := [][2]string{
+		{"id", cloudEvent.ID},
+		{"source", cloudEvent.Source},
+		{"specversion", cloudEvent.SpecVersion},
+		{"type", cloudEvent.Type},
+	}
+	if cloudEvent.DataContentType != nil {
+		attributes = append(attributes, [2]string{"datacontenttype", *cloudEvent.DataContentType})
+	}
+	if cloudEvent.DataSchema != nil {
+		attributes = append(attributes, [2]string{"dataschema", *cloudEvent.DataSchema})
+	}
+	if cloudEvent.Subject != nil {
+		attributes = append(attributes, [2]string{"subject", *cloudEvent.Subject})
+	}
+	if cloudEvent.Time != nil {
+		attributes = append(attributes, [2]string{"time", cloudEvent.Time.UTC().Format(time.RFC3339Nano)})
+	}
+
+	extensions := make([]string, 0, len(cloudEvent.Extensions))
+	for name := range cloudEvent.Extensions {
+		extensions = append(extensions, name)
+	}
+	sort.Strings(extensions)
+	for _, name := range extensions {
+		attributes = append(attributes, [2]string{name, fmt.Sprint(cloudEvent.Extensions[name])})
+	}
+
+	headers := make([]sarama.RecordHeader, 0, len(attributes))
+	for _, attribute := range attributes {
+		if attribute[1] == "" {
+			continue
+		}
+		headers = append(headers, sarama.RecordHeader{
+			Key:   []byte(CloudEventHeaderPrefix + attribute[0]),
+			Value: []byte(attribute[1]),
+		})
+	}
+	return headers
+}
+
+// dlqHeaders returns the headers of a DLQ or retry message: the failure
+// details, the attributes of the original CloudEvent and the original
+// headers. Original headers whose key the publisher sets are dropped. A
+// non-zero retryAt adds the retry_at header.
+func dlqHeaders(dlqEvent DLQEvent, retryAt time.Time) []sarama.RecordHeader {
+	headers := []sarama.RecordHeader{
+		{Key: []byte(HeaderFailureReason), Value: []byte(dlqEvent.FailureReason)},
+		{Key: []byte(HeaderErrorClass), Value: []byte(dlqEvent.ErrorClass)},
+		{Key: []byte(HeaderOriginalTopic), Value: []byte(dlqEvent.OriginalTopic)},
+		{Key: []byte(HeaderOriginalPartition), Value: []byte(strconv.FormatInt(int64(dlqEvent.OriginalPartition), 10))},
+		{Key: []byte(HeaderOriginalOffset), Value: []byte(strconv.FormatInt(dlqEvent.OriginalOffset, 10))},
+		{Key: []byte(HeaderProcessorID), Value: []byte(dlqEvent.ProcessorID)},
+		{Key: []byte(HeaderRetryCount), Value: []byte(strconv.Itoa(dlqEvent.RetryCount))},
+	}
+	if !dlqEvent.OriginalTimestamp.IsZero() {
+		headers = append(headers, sarama.RecordHeader{
+			Key:   []byte(HeaderOriginalTimestamp),
+			Value: []byte(strconv.FormatInt(dlqEvent.OriginalTimestamp.UnixMilli(), 10)),
+		})
+	}
+	if dlqEvent.FailurePointer != "" {
+		headers = append(headers, sarama.RecordHeader{Key: []byte(HeaderFailurePointer), Value: []byte(dlqEvent.FailurePointer)})
+	}
+	if len(dlqEvent.Attempts) > 0 {
+		if attempts, err := json.Marshal(dlqEvent.Attempts); err == nil {
+			headers = append(headers, sarama.RecordHeader{Key: []byte(HeaderAttempts), Value: attempts})
+		}
+	}
+	if !retryAt.IsZero() {
+		headers = append(headers, sarama.RecordHeader{
+			Key:   []byte(HeaderRetryAt),
+			Value: []byte(strconv.FormatInt(retryAt.UnixMilli(), 10)),
+		})
+	}
+
+	// Attributes of the original event; unparseable messages have none
+	var cloudEvent event.CloudEvent
+	if len(dlqEvent.OriginalEvent) > 0 && json.Unmarshal(dlqEvent.OriginalEvent, &cloudEvent) == nil && cloudEvent.ID != "" {
+		headers = append(headers, cloudEventHeaders(&cloudEvent)...)
+	}
+
+	set := make(map[string]bool, len(headers))
+	for _, header := range headers {
+		set[string(header.Key)] = true
+	}
+	for _, header := range dlqEvent.OriginalHeaders {
+		if set[header.Key] {
+			continue
+		}
+		headers = append(headers, sarama.RecordHeader{Key: []byte(header.Key), Value: header.Value})
+	}
+	return headers
+}
+
+// isBinaryCloudEvent reports whether a message carries a CloudEvent in binary
+// mode: attributes in ce_ headers and the data as the value. Messages with a
+// structured content type are structured even when they have ce_ headers.
+func isBinaryCloudEvent(headers []*sarama.RecordHeader) bool {
+	binary := false
+	for _, header := range headers {
+		switch string(header.Key) {
+		case CloudEventHeaderPrefix + "specversion":
+			binary = true
+		case headerContentType:
+			if strings.HasPrefix(string(header.Value), "application/cloudevents") {
+				return false
+			}
+		}
+	}
+	return binary
+}
+
+// parseBinaryCloudEvent builds a binary mode CloudEvent from the ce_ headers
+// and the value of a message. The content-type header is the data content
+// type. Values that are not JSON, such as schema registry framed payloads,
+// are kept as a base64 JSON string.
+func parseBinaryCloudEvent(headers []*sarama.RecordHeader, value []byte) (*event.CloudEvent, error) {
+	var cloudEvent event.CloudEvent
+	for _, header := range headers {
+		key, headerValue := string(header.Key), string(header.Value)
+		if key == headerContentType {
+			cloudEvent.DataContentType = &headerValue
+			continue
+		}
+		name, ok := strings.CutPrefix(key, CloudEventHeaderPrefix)
+		if !ok {
+			continue
+		}
+		switch name {
+		case "id":
+			cloudEvent.ID = headerValue
+		case "source":
+			cloudEvent.Source = headerValue
+		case "specversion":
+			cloudEvent.SpecVersion = headerValue
+		case "type":
+			cloudEvent.Type = headerValue
+		case "dataschema":
+			cloudEvent.DataSchema = &headerValue
+		case "subject":
+			cloudEvent.Subject = &headerValue
+		case "time":
+			eventTime, err := time.Parse(time.RFC3339Nano, headerValue)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %stime header: %w", CloudEventHeaderPrefix, err)
+			}
+			cloudEvent.Time = &eventTime
+		case "datacontenttype":
+			// Binary mode carries it in content-type
+		default:
+			if cloudEvent.Extensions == nil {
+				cloudEvent.Extensions = make(map[string]interface{})
+			}
+			cloudEvent.Extensions[name] = headerValue
+		}
+	}
+
+	if len(value) > 0 {
+		if json.Valid(value) {
+			cloudEvent.Data = value
+		} else {
+			data, err := json.Marshal(base64.StdEncoding.EncodeToString(value))
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode binary data: %w", err)
+			}
+			cloudEvent.Data = data
+		}
+	}
+	return &cloudEvent, nil
+}
@@ -0,0 +1,241 @@
+package kafka
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/jittakal/kafeventstore/pkg/event"
+)
+
+// headerMap collects record headers by key, failing on duplicate keys.
+func headerMap(t *testing.T, headers []sarama.RecordHeader) map[string]string {
+	t.Helper()
+
+	m := make(map[string]string, len(headers))
+	for _, header := range headers {
+		if _, ok := m[string(header.Key)]; ok {
+			t.Errorf("duplicate header %s", header.Key)
+		}
+		m[string(header.Key)] = string(header.Value)
+	}
+	return m
+}
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		reason string
+		want   string
+	}{
+		{ReasonStorageFailed, ErrorClassTransient},
+		{ReasonSchemaUnavailable, ErrorClassTransient},
+		{ReasonValidationFailed, ErrorClassPermanent},
+		{ReasonDeserializationFailed, ErrorClassPermanent},
+		{"unknown", ErrorClassPermanent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.reason, func(t *testing.T) {
+			if got := ErrorClass(tt.reason); got != tt.want {
+				t.Errorf("ErrorClass() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAttempts(t *testing.T) {
+	failedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	attempts := []DLQAttempt{{Reason: ReasonStorageFailed, ErrorClass: ErrorClassTransient, FailedAt: failedAt, ProcessorID: "p1"}}
+	value, _ := json.Marshal(attempts)
+
+	if got := parseAttempts(string(value)); !reflect.DeepEqual(got, attempts) {
+		t.Errorf("parseAttempts() = %+v, want %+v", got, attempts)
+	}
+	if got := parseAttempts(""); got != nil {
+		t.Errorf("parseAttempts(\"\") = %+v, want none", got)
+	}
+	if got := parseAttempts("not json"); got != nil {
+		t.Errorf("parseAttempts(invalid) = %+v, want none", got)
+	}
+}
+
+func TestOriginalHeaders(t *testing.T) {
+	headers := map[string]string{
+		"trace":          "abc",
+		"content-type":   "application/json",
+		HeaderRetryCount: "1",
+		HeaderRetryAt:    "1700000000000",
+		HeaderAttempts:   "[]",
+		HeaderRedacted:   "true",
+	}
+
+	want := []RawHeader{
+		{Key: "content-type", Value: []byte("application/json")},
+		{Key: "trace", Value: []byte("abc")},
+	}
+	if got := originalHeaders(headers); !reflect.DeepEqual(got, want) {
+		t.Errorf("originalHeaders() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCloudEventHeaders(t *testing.T) {
+	subject := "order-1"
+	eventTime := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	cloudEvent := &event.CloudEvent{
+		ID:          "evt-1",
+		Source:      "orders",
+		SpecVersion: "1.0",
+		Type:        "order.created",
+		Subject:     &subject,
+		Time:        &eventTime,
+		Extensions:  map[string]interface{}{"tenant": "acme", "priority": 2},
+	}
+
+	want := map[string]string{
+		"ce_id":          "evt-1",
+		"ce_source":      "orders",
+		"ce_specversion": "1.0",
+		"ce_type":        "order.created",
+		"ce_subject":     "order-1",
+		"ce_time":        "2026-03-01T12:00:00Z",
+		"ce_tenant":      "acme",
+		"ce_priority":    "2",
+	}
+	if got := headerMap(t, cloudEventHeaders(cloudEvent)); !reflect.DeepEqual(got, want) {
+		t.Errorf("cloudEventHeaders() = %v, want %v", got, want)
+	}
+}
+
+func TestDLQMessageHeaders(t *testing.T) {
+	original, _ := json.Marshal(&event.CloudEvent{ID: "evt-1", Source: "orders", SpecVersion: "1.0", Type: "order.created"})
+	originalTime := time.UnixMilli(1700000000123)
+	dlqEvent := DLQEvent{
+		OriginalEvent:     original,
+		OriginalTopic:     "orders",
+		OriginalPartition: 2,
+		OriginalOffset:    42,
+		OriginalTimestamp: originalTime,
+		OriginalHeaders: []RawHeader{
+			{Key: "trace", Value: []byte("abc")},
+			{Key: "ce_id", Value: []byte("stale")},
+			{Key: HeaderFailureReason, Value: []byte("stale")},
+		},
+		FailureReason: ReasonStorageFailed,
+		ErrorClass:    ErrorClassTransient,
+		RetryCount:    1,
+		ProcessorID:   "processor-1",
+		Attempts:      []DLQAttempt{{Reason: ReasonStorageFailed, ErrorClass: ErrorClassTransient}},
+	}
+
+	headers := headerMap(t, dlqHeaders(dlqEvent, time.UnixMilli(1700000060000)))
+	want := map[string]string{
+		HeaderFailureReason:     ReasonStorageFailed,
+		HeaderErrorClass:        ErrorClassTransient,
+		HeaderOriginalTopic:     "orders",
+		HeaderOriginalPartition: "2",
+		HeaderOriginalOffset:    "42",
+		HeaderOriginalTimestamp: "1700000000123",
+		HeaderProcessorID:       "processor-1",
+		HeaderRetryCount:        "1",
+		HeaderRetryAt:           "1700000060000",
+		"ce_id":                 "evt-1",
+		"ce_source":             "orders",
+		"ce_specversion":        "1.0",
+		"ce_type":               "order.created",
+		"trace":                 "abc",
+	}
+	attempts := headers[HeaderAttempts]
+	delete(headers, HeaderAttempts)
+	if !reflect.DeepEqual(headers, want) {
+		t.Errorf("dlqHeaders() = %v, want %v", headers, want)
+	}
+	if got := parseAttempts(attempts); len(got) != 1 || got[0].Reason != ReasonStorageFailed {
+		t.Errorf("attempts header = %s, want one storage failure", attempts)
+	}
+
+	// Unparseable messages have no event attributes and no retry_at
+	raw := headerMap(t, dlqHeaders(DLQEvent{FailureReason: ReasonDeserializationFailed, OriginalValue: []byte("x")}, time.Time{}))
+	for key := range raw {
+		if key == HeaderRetryAt || key == HeaderOriginalTimestamp || strings.HasPrefix(key, CloudEventHeaderPrefix) {
+			t.Errorf("unexpected header %s for raw message", key)
+		}
+	}
+}
+
+func TestIsBinaryCloudEvent(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    bool
+	}{
+		{name: "no headers", want: false},
+		{name: "specversion header", headers: map[string]string{"ce_specversion": "1.0"}, want: true},
+		{name: "structured content type", headers: map[string]string{"ce_specversion": "1.0", "content-type": "application/cloudevents+json"}, want: false},
+		{name: "other headers", headers: map[string]string{"ce_id": "1", "content-type": "application/avro"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var headers []*sarama.RecordHeader
+			for key, value := range tt.headers {
+				headers = append(headers, &sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+			}
+			if got := isBinaryCloudEvent(headers); got != tt.want {
+				t.Errorf("isBinaryCloudEvent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBinaryCloudEvent(t *testing.T) {
+	headers := []*sarama.RecordHeader{
+		{Key: []byte("ce_id"), Value: []byte("evt-1")},
+		{Key: []byte("ce_source"), Value: []byte("orders")},
+		{Key: []byte("ce_specversion"), Value: []byte("1.0")},
+		{Key: []byte("ce_type"), Value: []byte("order.created")},
+		{Key: []byte("ce_subject"), Value: []byte("order-1")},
+		{Key: []byte("ce_time"), Value: []byte("2026-03-01T12:00:00Z")},
+		{Key: []byte("ce_tenant"), Value: []byte("acme")},
+		{Key: []byte("content-type"), Value: []byte("application/avro")},
+		{Key: []byte("traceparent"), Value: []byte("00-abc")},
+	}
+
+	tests := []struct {
+		name     string
+		value    []byte
+		wantData string
+	}{
+		{name: "json data", value: []byte(`{"total":10}`), wantData: `{"total":10}`},
+		{name: "binary data", value: []byte{0, 0, 0, 0, 1, 2}, wantData: `"AAAAAAEC"`},
+		{name: "no data", value: nil, wantData: ``},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cloudEvent, err := parseBinaryCloudEvent(headers, tt.value)
+			if err != nil {
+				t.Fatalf("parseBinaryCloudEvent() error = %v", err)
+			}
+			if cloudEvent.ID != "evt-1" || cloudEvent.Source != "orders" || cloudEvent.SpecVersion != "1.0" ||
+				cloudEvent.Type != "order.created" || *cloudEvent.Subject != "order-1" ||
+				!cloudEvent.Time.Equal(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)) ||
+				*cloudEvent.DataContentType != "application/avro" {
+				t.Errorf("parseBinaryCloudEvent() = %+v", cloudEvent)
+			}
+			if want := map[string]interface{}{"tenant": "acme"}; !reflect.DeepEqual(cloudEvent.Extensions, want) {
+				t.Errorf("Extensions = %v, want %v", cloudEvent.Extensions, want)
+			}
+			if string(cloudEvent.Data) != tt.wantData {
+				t.Errorf("Data = %s, want %s", cloudEvent.Data, tt.wantData)
+			}
+		})
+	}
+
+	invalid := []*sarama.RecordHeader{{Key: []byte("ce_specversion"), Value: []byte("1.0")}, {Key: []byte("ce_time"), Value: []byte("yesterday")}}
+	if _, err := parseBinaryCloudEvent(invalid, nil); err == nil {
+		t.Error("parseBinaryCloudEvent(invalid time) error = nil, want error")
+	}
+}
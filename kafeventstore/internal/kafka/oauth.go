@@ -0,0 +1,102 @@
+// Package kafka implements SASL/OAUTHBEARER token providers.
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/IBM/sarama"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// Ensure implementations satisfy interface at compile time.
+var (
+	_ sarama.AccessTokenProvider = (*OIDCTokenProvider)(nil)
+	_ sarama.AccessTokenProvider = (*MSKAccessTokenProvider)(nil)
+)
+
+// oidcTokenTimeout bounds a single token endpoint request.
+const oidcTokenTimeout = 30 * time.Second
+
+// OAuthConfig contains OAuth 2.0 client credentials settings for SASL/OAUTHBEARER.
+type OAuthConfig struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// Extensions are sent as SASL extensions, e.g. logicalCluster for Confluent Cloud.
+	Extensions map[string]string
+}
+
+// OIDCTokenProvider fetches access tokens from an OIDC token endpoint with
+// the client credentials grant. Tokens are cached and refreshed shortly
+// before they expire.
+type OIDCTokenProvider struct {
+	source     oauth2.TokenSource
+	extensions map[string]string
+}
+
+// NewOIDCTokenProvider creates a token provider for an OIDC token endpoint.
+func NewOIDCTokenProvider(cfg OAuthConfig) (*OIDCTokenProvider, error) {
+	if cfg.TokenURL == "" {
+		return nil, fmt.Errorf("OAuth token URL is required")
+	}
+	if cfg.ClientID == "" {
+		return nil, fmt.Errorf("OAuth client ID is required")
+	}
+
+	credentials := &clientcredentials.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		TokenURL:     cfg.TokenURL,
+		Scopes:       cfg.Scopes,
+	}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Timeout: oidcTokenTimeout})
+
+	return &OIDCTokenProvider{
+		source:     credentials.TokenSource(ctx),
+		extensions: cfg.Extensions,
+	}, nil
+}
+
+// Token returns a valid access token, fetching a new one when needed.
+func (p *OIDCTokenProvider) Token() (*sarama.AccessToken, error) {
+	token, err := p.source.Token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch OAuth token: %w", err)
+	}
+	return &sarama.AccessToken{
+		Token:      token.AccessToken,
+		Extensions: p.extensions,
+	}, nil
+}
+
+// mskBrokerRegion extracts the region from MSK broker host names such as
+// b-1.cluster.abc123.c2.kafka.eu-west-1.amazonaws.com and
+// boot-abc123.c1.kafka-serverless.eu-west-1.amazonaws.com.
+var mskBrokerRegion = regexp.MustCompile(`\.kafka(?:-serverless)?\.([a-z0-9-]+)\.amazonaws\.com(?:\.cn)?(?::\d+)?$`)
+
+// mskRegion returns the AWS region for MSK IAM authentication: the configured
+// region, else the region in the broker host names, else AWS_REGION or
+// AWS_DEFAULT_REGION.
+func mskRegion(region string, brokers []string) (string, error) {
+	if region != "" {
+		return region, nil
+	}
+	for _, broker := range brokers {
+		if match := mskBrokerRegion.FindStringSubmatch(broker); match != nil {
+			return match[1], nil
+		}
+	}
+	for _, env := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if region := os.Getenv(env); region != "" {
+			return region, nil
+		}
+	}
+	return "", fmt.Errorf("AWS region for MSK IAM is not configured and cannot be derived from the broker addresses")
+}
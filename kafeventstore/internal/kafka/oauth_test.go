@@ -0,0 +1,261 @@
+package kafka
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/IBM/sarama"
+)
+
+// mockTokenServer is a local OIDC token endpoint for the client credentials grant.
+type mockTokenServer struct {
+	*httptest.Server
+	requests  atomic.Int32
+	expiresIn int
+	lastScope atomic.Value
+}
+
+func newMockTokenServer(t *testing.T, expiresIn int) *mockTokenServer {
+	t.Helper()
+
+	s := &mockTokenServer{expiresIn: expiresIn}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		clientID, clientSecret, ok := r.BasicAuth()
+		if !ok {
+			clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+		}
+		if r.PostForm.Get("grant_type") != "client_credentials" || clientID != "event-store" || clientSecret != "secret" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid_client"}`))
+			return
+		}
+
+		n := s.requests.Add(1)
+		s.lastScope.Store(r.PostForm.Get("scope"))
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": fmt.Sprintf("token-%d", n),
+			"token_type":   "Bearer",
+			"expires_in":   s.expiresIn,
+		})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestNewOIDCTokenProvider_Validation(t *testing.T) {
+	tests := []struct {
+		name   string
+		config OAuthConfig
+	}{
+		{"missing token url", OAuthConfig{ClientID: "event-store"}},
+		{"missing client id", OAuthConfig{TokenURL: "http://localhost/token"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewOIDCTokenProvider(tt.config); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestOIDCTokenProvider_Token(t *testing.T) {
+	server := newMockTokenServer(t, 3600)
+
+	provider, err := NewOIDCTokenProvider(OAuthConfig{
+		TokenURL:     server.URL,
+		ClientID:     "event-store",
+		ClientSecret: "secret",
+		Scopes:       []string{"kafka", "events"},
+		Extensions:   map[string]string{"logicalCluster": "lkc-123"},
+	})
+	if err != nil {
+		t.Fatalf("NewOIDCTokenProvider() error = %v", err)
+	}
+
+	token, err := provider.Token()
+	if err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+	if token.Token != "token-1" {
+		t.Errorf("Token = %s, want token-1", token.Token)
+	}
+	if token.Extensions["logicalCluster"] != "lkc-123" {
+		t.Errorf("Extensions = %v, want logicalCluster", token.Extensions)
+	}
+	if scope := server.lastScope.Load(); scope != "kafka events" {
+		t.Errorf("scope = %v, want \"kafka events\"", scope)
+	}
+
+	// A valid token is cached
+	token, err = provider.Token()
+	if err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+	if token.Token != "token-1" || server.requests.Load() != 1 {
+		t.Errorf("Token = %s after %d requests, want cached token-1", token.Token, server.requests.Load())
+	}
+}
+
+func TestOIDCTokenProvider_Refresh(t *testing.T) {
+	// Tokens within the expiry margin are refreshed on every call
+	server := newMockTokenServer(t, 1)
+
+	provider, err := NewOIDCTokenProvider(OAuthConfig{
+		TokenURL:     server.URL,
+		ClientID:     "event-store",
+		ClientSecret: "secret",
+	})
+	if err != nil {
+		t.Fatalf("NewOIDCTokenProvider() error = %v", err)
+	}
+
+	first, err := provider.Token()
+	if err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+	second, err := provider.Token()
+	if err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+	if first.Token == second.Token {
+		t.Errorf("expected refreshed token, got %s twice", first.Token)
+	}
+	if server.requests.Load() != 2 {
+		t.Errorf("requests = %d, want 2", server.requests.Load())
+	}
+}
+
+func TestOIDCTokenProvider_InvalidCredentials(t *testing.T) {
+	server := newMockTokenServer(t, 3600)
+
+	provider, err := NewOIDCTokenProvider(OAuthConfig{
+		TokenURL:     server.URL,
+		ClientID:     "event-store",
+		ClientSecret: "wrong",
+	})
+	if err != nil {
+		t.Fatalf("NewOIDCTokenProvider() error = %v", err)
+	}
+	if _, err := provider.Token(); err == nil {
+		t.Error("expected error for rejected credentials")
+	}
+}
+
+func TestMSKRegion(t *testing.T) {
+	tests := []struct {
+		name    string
+		region  string
+		brokers []string
+		env     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "configured region wins",
+			region:  "ap-south-1",
+			brokers: []string{"b-1.cluster.abc123.c2.kafka.eu-west-1.amazonaws.com:9098"},
+			want:    "ap-south-1",
+		},
+		{
+			name:    "provisioned broker",
+			brokers: []string{"b-1.cluster.abc123.c2.kafka.eu-west-1.amazonaws.com:9098"},
+			want:    "eu-west-1",
+		},
+		{
+			name:    "serverless broker",
+			brokers: []string{"boot-abc123.c1.kafka-serverless.us-west-2.amazonaws.com:9098"},
+			want:    "us-west-2",
+		},
+		{
+			name:    "environment fallback",
+			brokers: []string{"localhost:9092"},
+			env:     "eu-central-1",
+			want:    "eu-central-1",
+		},
+		{
+			name:    "not derivable",
+			brokers: []string{"localhost:9092"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AWS_REGION", tt.env)
+			t.Setenv("AWS_DEFAULT_REGION", "")
+
+			got, err := mskRegion(tt.region, tt.brokers)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("mskRegion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("mskRegion() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigureSecurity_TokenProviders(t *testing.T) {
+	t.Run("msk iam region from broker", func(t *testing.T) {
+		config := sarama.NewConfig()
+		err := configureSecurity(config, ConsumerConfig{
+			BootstrapServers: []string{"b-1.cluster.abc123.c2.kafka.eu-west-1.amazonaws.com:9098"},
+			SecurityProtocol: "SASL_SSL",
+			SASLMechanism:    "AWS_MSK_IAM",
+		})
+		if err != nil {
+			t.Fatalf("configureSecurity() error = %v", err)
+		}
+		provider, ok := config.Net.SASL.TokenProvider.(*MSKAccessTokenProvider)
+		if !ok {
+			t.Fatalf("TokenProvider = %T, want *MSKAccessTokenProvider", config.Net.SASL.TokenProvider)
+		}
+		if provider.region != "eu-west-1" {
+			t.Errorf("region = %s, want eu-west-1", provider.region)
+		}
+	})
+
+	t.Run("oauthbearer", func(t *testing.T) {
+		config := sarama.NewConfig()
+		err := configureSecurity(config, ConsumerConfig{
+			BootstrapServers: []string{"localhost:9092"},
+			SecurityProtocol: "SASL_PLAINTEXT",
+			SASLMechanism:    "OAUTHBEARER",
+			OAuth:            OAuthConfig{TokenURL: "http://localhost/token", ClientID: "event-store"},
+		})
+		if err != nil {
+			t.Fatalf("configureSecurity() error = %v", err)
+		}
+		if config.Net.SASL.Mechanism != sarama.SASLTypeOAuth {
+			t.Errorf("Mechanism = %s, want %s", config.Net.SASL.Mechanism, sarama.SASLTypeOAuth)
+		}
+		if _, ok := config.Net.SASL.TokenProvider.(*OIDCTokenProvider); !ok {
+			t.Errorf("TokenProvider = %T, want *OIDCTokenProvider", config.Net.SASL.TokenProvider)
+		}
+		if err := config.Validate(); err != nil {
+			t.Errorf("Validate() error = %v", err)
+		}
+	})
+
+	t.Run("oauthbearer without token url", func(t *testing.T) {
+		err := configureSecurity(sarama.NewConfig(), ConsumerConfig{
+			SecurityProtocol: "SASL_PLAINTEXT",
+			SASLMechanism:    "OAUTHBEARER",
+		})
+		if err == nil {
+			t.Error("expected error without OAuth settings")
+		}
+	})
+}
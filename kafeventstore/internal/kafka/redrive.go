@@ -0,0 +1,447 @@
+// Package kafka implements replaying dead letter queue topics.
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/jittakal/kafeventstore/pkg/event"
+	"golang.org/x/time/rate"
+)
+
+// Ensure implementations satisfy interface at compile time.
+var (
+	_ RedriveSink = (*TopicRedriveSink)(nil)
+	_ RedriveSink = (*DryRunRedriveSink)(nil)
+)
+
+// RedriveFilter selects the DLQ events to redrive. Empty fields match everything.
+type RedriveFilter struct {
+	FailureReasons []string
+	OriginalTopics []string
+	// Since and Until bound the failure timestamp; Until is exclusive.
+	Since time.Time
+	Until time.Time
+}
+
+// Match reports whether a DLQ event passes the filter.
+func (f RedriveFilter) Match(dlqEvent *DLQEvent) bool {
+	if len(f.FailureReasons) > 0 && !slices.Contains(f.FailureReasons, dlqEvent.FailureReason) {
+		return false
+	}
+	if len(f.OriginalTopics) > 0 && !slices.Contains(f.OriginalTopics, dlqEvent.OriginalTopic) {
+		return false
+	}
+	if !f.Since.IsZero() && dlqEvent.FailureTimestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !dlqEvent.FailureTimestamp.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// RedriveConfig contains DLQ redrive settings.
+type RedriveConfig struct {
+	DLQTopic string
+	Filter   RedriveFilter
+	// RatePerSecond limits how many events are redriven per second; 0 is unlimited.
+	RatePerSecond float64
+	// Limit stops the redrive after this many matching events; 0 redrives all.
+	Limit int
+}
+
+// RedriveRecord is a DLQ event read from the DLQ topic.
+type RedriveRecord struct {
+	Event     DLQEvent
+	Key       []byte
+	Partition int32
+	Offset    int64
+}
+
+// CloudEvent decodes the original event. Dead-lettered raw messages are
+// decoded from their original value.
+func (r *RedriveRecord) CloudEvent() (*event.CloudEvent, error) {
+	data := []byte(r.Event.OriginalEvent)
+	if len(data) == 0 || string(data) == "null" {
+		data = r.Event.OriginalValue
+	}
+	var cloudEvent event.CloudEvent
+	if err := json.Unmarshal(data, &cloudEvent); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal original event: %w", err)
+	}
+	return &cloudEvent, nil
+}
+
+// RedriveSink receives the events selected for redrive.
+type RedriveSink interface {
+	// Redrive handles one DLQ event.
+	Redrive(ctx context.Context, record *RedriveRecord) error
+
+	// Flush completes any buffered work once the DLQ topic has been read.
+	Flush(ctx context.Context) error
+}
+
+// RedriveStats counts the outcome of a redrive.
+type RedriveStats struct {
+	Read     int // messages read from the DLQ topic
+	Matched  int // DLQ events that passed the filter
+	Redriven int // events the sink accepted
+	Failed   int // messages that could not be decoded or redriven
+}
+
+// offsetGetter looks up partition offsets; sarama.Client implements it.
+type offsetGetter interface {
+	GetOffset(topic string, partition int32, time int64) (int64, error)
+}
+
+// Redriver reads a DLQ topic from the oldest message up to the newest one
+// present when it starts, and hands the matching events to a sink.
+type Redriver struct {
+	consumer sarama.Consumer
+	offsets  offsetGetter
+	client   sarama.Client
+	config   RedriveConfig
+	logger   *slog.Logger
+}
+
+// NewRedriver creates a redriver for a DLQ topic.
+func NewRedriver(
+	bootstrapServers []string,
+	securityConfig ConsumerConfig,
+	config RedriveConfig,
+	logger *slog.Logger,
+) (*Redriver, error) {
+	if config.DLQTopic == "" {
+		return nil, fmt.Errorf("DLQ topic is required")
+	}
+
+	version, err := kafkaVersion(securityConfig.KafkaVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.Version = version
+	saramaConfig.Consumer.Return.Errors = true
+	if err := configureSecurity(saramaConfig, securityConfig); err != nil {
+		return nil, fmt.Errorf("failed to configure security: %w", err)
+	}
+
+	client, err := sarama.NewClient(bootstrapServers, saramaConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka client: %w", err)
+	}
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to create consumer: %w", err)
+	}
+
+	redriver := newRedriver(consumer, client, config, logger)
+	redriver.client = client
+	return redriver, nil
+}
+
+// newRedriver creates a redriver from a consumer and an offset source.
+func newRedriver(consumer sarama.Consumer, offsets offsetGetter, config RedriveConfig, logger *slog.Logger) *Redriver {
+	return &Redriver{
+		consumer: consumer,
+		offsets:  offsets,
+		config:   config,
+		logger:   logger,
+	}
+}
+
+// Run redrives the matching events of every partition to sink.
+func (r *Redriver) Run(ctx context.Context, sink RedriveSink) (RedriveStats, error) {
+	var stats RedriveStats
+
+	partitions, err := r.consumer.Partitions(r.config.DLQTopic)
+	if err != nil {
+		return stats, fmt.Errorf("failed to list partitions of %s: %w", r.config.DLQTopic, err)
+	}
+
+	limiter := rate.NewLimiter(rate.Inf, 1)
+	if r.config.RatePerSecond > 0 {
+		limiter = rate.NewLimiter(rate.Limit(r.config.RatePerSecond), 1)
+	}
+
+	for _, partition := range partitions {
+		if err := r.redrivePartition(ctx, partition, sink, limiter, &stats); err != nil {
+			return stats, err
+		}
+		if r.limitReached(stats) {
+			break
+		}
+	}
+
+	if err := sink.Flush(ctx); err != nil {
+		return stats, fmt.Errorf("failed to flush redriven events: %w", err)
+	}
+	return stats, nil
+}
+
+// redrivePartition redrives one partition up to its newest offset at start.
+func (r *Redriver) redrivePartition(
+	ctx context.Context,
+	partition int32,
+	sink RedriveSink,
+	limiter *rate.Limiter,
+	stats *RedriveStats,
+) error {
+	topic := r.config.DLQTopic
+	oldest, err := r.offsets.GetOffset(topic, partition, sarama.OffsetOldest)
+	if err != nil {
+		return fmt.Errorf("failed to get oldest offset of %s/%d: %w", topic, partition, err)
+	}
+	newest, err := r.offsets.GetOffset(topic, partition, sarama.OffsetNewest)
+	if err != nil {
+		return fmt.Errorf("failed to get newest offset of %s/%d: %w", topic, partition, err)
+	}
+	if newest <= oldest {
+		return nil
+	}
+
+	pc, err := r.consumer.ConsumePartition(topic, partition, oldest)
+	if err != nil {
+		return fmt.Errorf("failed to consume %s/%d: %w", topic, partition, err)
+	}
+	defer pc.Close()
+
+	r.logger.Info("redriving DLQ partition",
+		"topic", topic,
+		"partition", partition,
+		"from_offset", oldest,
+		"to_offset", newest-1,
+	)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case consumerErr := <-pc.Errors():
+			if consumerErr != nil {
+				return fmt.Errorf("failed to read %s/%d: %w", topic, partition, consumerErr.Err)
+			}
+		case message := <-pc.Messages():
+			if message == nil {
+				return nil
+			}
+			if err := r.redriveMessage(ctx, message, sink, limiter, stats); err != nil {
+				return err
+			}
+			if message.Offset >= newest-1 || r.limitReached(*stats) {
+				return nil
+			}
+		}
+	}
+}
+
+// redriveMessage decodes, filters and redrives one DLQ message. Only context
+// cancellation is returned as an error; failed messages are counted.
+func (r *Redriver) redriveMessage(
+	ctx context.Context,
+	message *sarama.ConsumerMessage,
+	sink RedriveSink,
+	limiter *rate.Limiter,
+	stats *RedriveStats,
+) error {
+	stats.Read++
+
+	record := &RedriveRecord{
+		Key:       message.Key,
+		Partition: message.Partition,
+		Offset:    message.Offset,
+	}
+	if err := json.Unmarshal(message.Value, &record.Event); err != nil {
+		stats.Failed++
+		r.logger.Warn("skipping undecodable DLQ message",
+			"partition", message.Partition,
+			"offset", message.Offset,
+			"error", err,
+		)
+		return nil
+	}
+	if !r.config.Filter.Match(&record.Event) {
+		return nil
+	}
+	stats.Matched++
+
+	if err := limiter.Wait(ctx); err != nil {
+		return err
+	}
+	if err := sink.Redrive(ctx, record); err != nil {
+		stats.Failed++
+		r.logger.Error("failed to redrive DLQ event",
+			"partition", message.Partition,
+			"offset", message.Offset,
+			"original_topic", record.Event.OriginalTopic,
+			"error", err,
+		)
+		return nil
+	}
+	stats.Redriven++
+	return nil
+}
+
+// limitReached reports whether the configured number of events was matched.
+func (r *Redriver) limitReached(stats RedriveStats) bool {
+	return r.config.Limit > 0 && stats.Matched >= r.config.Limit
+}
+
+// Close closes the consumer and its client.
+func (r *Redriver) Close() error {
+	if err := r.consumer.Close(); err != nil {
+		return fmt.Errorf("failed to close consumer: %w", err)
+	}
+	if r.client != nil {
+		if err := r.client.Close(); err != nil {
+			return fmt.Errorf("failed to close kafka client: %w", err)
+		}
+	}
+	return nil
+}
+
+// TopicRedriveSink republishes DLQ events to their original topics. Events are
+// sent with the bytes the original producer wrote, so they are consumed again
+// like any other message.
+type TopicRedriveSink struct {
+	producer sarama.SyncProducer
+	logger   *slog.Logger
+}
+
+// NewTopicRedriveSink creates a sink that republishes to the original topics.
+func NewTopicRedriveSink(
+	bootstrapServers []string,
+	securityConfig ConsumerConfig,
+	logger *slog.Logger,
+) (*TopicRedriveSink, error) {
+	version, err := kafkaVersion(securityConfig.KafkaVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.Version = version
+	saramaConfig.Producer.RequiredAcks = sarama.WaitForAll
+	saramaConfig.Producer.Retry.Max = 5
+	saramaConfig.Producer.Return.Successes = true
+	saramaConfig.Producer.Idempotent = true
+	saramaConfig.Net.MaxOpenRequests = 1
+	if err := configureSecurity(saramaConfig, securityConfig); err != nil {
+		return nil, fmt.Errorf("failed to configure security: %w", err)
+	}
+
+	producer, err := sarama.NewSyncProducer(bootstrapServers, saramaConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sync producer: %w", err)
+	}
+	return &TopicRedriveSink{producer: producer, logger: logger}, nil
+}
+
+// Redrive republishes one event to its original topic with its original key,
+// so it lands on the same partition as the rest of its key.
+func (s *TopicRedriveSink) Redrive(ctx context.Context, record *RedriveRecord) error {
+	dlqEvent := &record.Event
+	if dlqEvent.OriginalTopic == "" {
+		return fmt.Errorf("DLQ event has no original topic")
+	}
+
+	msg := &sarama.ProducerMessage{Topic: dlqEvent.OriginalTopic}
+	switch {
+	case len(dlqEvent.OriginalValue) > 0:
+		msg.Value = sarama.ByteEncoder(dlqEvent.OriginalValue)
+	case len(dlqEvent.OriginalEvent) > 0 && string(dlqEvent.OriginalEvent) != "null":
+		msg.Value = sarama.ByteEncoder(dlqEvent.OriginalEvent)
+	default:
+		return fmt.Errorf("DLQ event has no original event")
+	}
+	if dlqEvent.OriginalKey != nil {
+		msg.Key = sarama.ByteEncoder(dlqEvent.OriginalKey)
+	}
+	for _, header := range dlqEvent.OriginalHeaders {
+		msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: []byte(header.Key), Value: header.Value})
+	}
+
+	if _, _, err := s.producer.SendMessage(msg); err != nil {
+		return fmt.Errorf("failed to republish to %s: %w", dlqEvent.OriginalTopic, err)
+	}
+	return nil
+}
+
+// Flush does nothing; events are sent synchronously.
+func (s *TopicRedriveSink) Flush(ctx context.Context) error {
+	return nil
+}
+
+// Close closes the producer.
+func (s *TopicRedriveSink) Close() error {
+	return s.producer.Close()
+}
+
+// DryRunRedriveSink writes one JSON line per selected event instead of
+// redriving it.
+type DryRunRedriveSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewDryRunRedriveSink creates a sink that reports events to w.
+func NewDryRunRedriveSink(w io.Writer) *DryRunRedriveSink {
+	return &DryRunRedriveSink{w: w}
+}
+
+// dryRunLine is the report written for each selected event.
+type dryRunLine struct {
+	DLQPartition      int32     `json:"dlq_partition"`
+	DLQOffset         int64     `json:"dlq_offset"`
+	OriginalTopic     string    `json:"original_topic"`
+	OriginalPartition int32     `json:"original_partition"`
+	OriginalOffset    int64     `json:"original_offset"`
+	FailureReason     string    `json:"failure_reason"`
+	FailureTimestamp  time.Time `json:"failure_timestamp"`
+	RetryCount        int       `json:"retry_count"`
+	EventID           string    `json:"event_id,omitempty"`
+}
+
+// Redrive reports one event.
+func (s *DryRunRedriveSink) Redrive(ctx context.Context, record *RedriveRecord) error {
+	line := dryRunLine{
+		DLQPartition:      record.Partition,
+		DLQOffset:         record.Offset,
+		OriginalTopic:     record.Event.OriginalTopic,
+		OriginalPartition: record.Event.OriginalPartition,
+		OriginalOffset:    record.Event.OriginalOffset,
+		FailureReason:     record.Event.FailureReason,
+		FailureTimestamp:  record.Event.FailureTimestamp,
+		RetryCount:        record.Event.RetryCount,
+	}
+	if cloudEvent, err := record.CloudEvent(); err == nil {
+		line.EventID = cloudEvent.ID
+	}
+
+	data, err := json.Marshal(line)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dry-run line: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := fmt.Fprintln(s.w, string(data)); err != nil {
+		return fmt.Errorf("failed to write dry-run line: %w", err)
+	}
+	return nil
+}
+
+// Flush does nothing; lines are written immediately.
+func (s *DryRunRedriveSink) Flush(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,322 @@
+package kafka
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/jittakal/kafeventstore/pkg/event"
+)
+
+// fakeOffsets returns fixed oldest and newest offsets per partition.
+type fakeOffsets map[int32][2]int64
+
+func (f fakeOffsets) GetOffset(topic string, partition int32, time int64) (int64, error) {
+	offsets, ok := f[partition]
+	if !ok {
+		return 0, fmt.Errorf("unknown partition %d", partition)
+	}
+	if time == sarama.OffsetOldest {
+		return offsets[0], nil
+	}
+	return offsets[1], nil
+}
+
+// recordingSink records redriven events and fails for the configured event IDs.
+type recordingSink struct {
+	records []*RedriveRecord
+	failIDs map[string]bool
+	flushed bool
+}
+
+func (s *recordingSink) Redrive(ctx context.Context, record *RedriveRecord) error {
+	cloudEvent, err := record.CloudEvent()
+	if err != nil {
+		return err
+	}
+	if s.failIDs[cloudEvent.ID] {
+		return errors.New("sink failure")
+	}
+	s.records = append(s.records, record)
+	return nil
+}
+
+func (s *recordingSink) Flush(ctx context.Context) error {
+	s.flushed = true
+	return nil
+}
+
+// dlqMessage creates a DLQ topic message for an event.
+func dlqMessage(t *testing.T, id, topic, reason string, failedAt time.Time) *sarama.ConsumerMessage {
+	t.Helper()
+
+	original, err := json.Marshal(&event.CloudEvent{ID: id, Source: "test", Type: "test.event", SpecVersion: "1.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := json.Marshal(DLQEvent{
+		OriginalEvent:    original,
+		OriginalTopic:    topic,
+		FailureReason:    reason,
+		FailureTimestamp: failedAt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &sarama.ConsumerMessage{Key: []byte(id), Value: value}
+}
+
+func TestRedriveFilter_Match(t *testing.T) {
+	failedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	dlqEvent := &DLQEvent{OriginalTopic: "orders", FailureReason: "storage_failed", FailureTimestamp: failedAt}
+
+	tests := []struct {
+		name   string
+		filter RedriveFilter
+		want   bool
+	}{
+		{"empty filter", RedriveFilter{}, true},
+		{"reason matches", RedriveFilter{FailureReasons: []string{"validation_failed", "storage_failed"}}, true},
+		{"reason differs", RedriveFilter{FailureReasons: []string{"validation_failed"}}, false},
+		{"topic matches", RedriveFilter{OriginalTopics: []string{"orders"}}, true},
+		{"topic differs", RedriveFilter{OriginalTopics: []string{"payments"}}, false},
+		{"within range", RedriveFilter{Since: failedAt, Until: failedAt.Add(time.Hour)}, true},
+		{"before since", RedriveFilter{Since: failedAt.Add(time.Second)}, false},
+		{"until is exclusive", RedriveFilter{Until: failedAt}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(dlqEvent); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedriveRecord_CloudEvent(t *testing.T) {
+	parsed := &RedriveRecord{Event: DLQEvent{OriginalEvent: json.RawMessage(`{"id":"evt-1"}`)}}
+	if cloudEvent, err := parsed.CloudEvent(); err != nil || cloudEvent.ID != "evt-1" {
+		t.Errorf("CloudEvent() = %v, %v, want evt-1", cloudEvent, err)
+	}
+
+	raw := &RedriveRecord{Event: DLQEvent{OriginalEvent: json.RawMessage("null"), OriginalValue: []byte(`{"id":"evt-2"}`)}}
+	if cloudEvent, err := raw.CloudEvent(); err != nil || cloudEvent.ID != "evt-2" {
+		t.Errorf("CloudEvent() = %v, %v, want evt-2", cloudEvent, err)
+	}
+
+	poison := &RedriveRecord{Event: DLQEvent{OriginalValue: []byte("not json")}}
+	if _, err := poison.CloudEvent(); err == nil {
+		t.Error("expected error for unparseable original value")
+	}
+}
+
+func TestRedriver_Run(t *testing.T) {
+	failedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	newConsumer := func(t *testing.T) *mocks.Consumer {
+		consumer := mocks.NewConsumer(t, nil)
+		consumer.SetTopicMetadata(map[string][]int32{"orders-dlq": {0, 1}})
+		consumer.ExpectConsumePartition("orders-dlq", 0, 0).
+			YieldMessage(dlqMessage(t, "evt-1", "orders", "storage_failed", failedAt)).
+			YieldMessage(&sarama.ConsumerMessage{Value: []byte("not a DLQ event")}).
+			YieldMessage(dlqMessage(t, "evt-2", "orders", "validation_failed", failedAt))
+		consumer.ExpectConsumePartition("orders-dlq", 1, 0).
+			YieldMessage(dlqMessage(t, "evt-3", "payments", "storage_failed", failedAt)).
+			YieldMessage(dlqMessage(t, "evt-4", "orders", "storage_failed", failedAt))
+		return consumer
+	}
+	offsets := fakeOffsets{0: {0, 3}, 1: {0, 2}}
+
+	t.Run("filters and redrives", func(t *testing.T) {
+		consumer := newConsumer(t)
+		redriver := newRedriver(consumer, offsets, RedriveConfig{
+			DLQTopic: "orders-dlq",
+			Filter:   RedriveFilter{FailureReasons: []string{"storage_failed"}, OriginalTopics: []string{"orders"}},
+		}, logger)
+		sink := &recordingSink{failIDs: map[string]bool{"evt-4": true}}
+
+		stats, err := redriver.Run(context.Background(), sink)
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		want := RedriveStats{Read: 5, Matched: 2, Redriven: 1, Failed: 2}
+		if stats != want {
+			t.Errorf("stats = %+v, want %+v", stats, want)
+		}
+		if len(sink.records) != 1 || string(sink.records[0].Key) != "evt-1" {
+			t.Errorf("redriven records = %+v, want evt-1", sink.records)
+		}
+		if !sink.flushed {
+			t.Error("sink was not flushed")
+		}
+		if err := redriver.Close(); err != nil {
+			t.Errorf("Close() error = %v", err)
+		}
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		// The limit is reached before partition 1 is read
+		consumer := mocks.NewConsumer(t, nil)
+		consumer.SetTopicMetadata(map[string][]int32{"orders-dlq": {0, 1}})
+		consumer.ExpectConsumePartition("orders-dlq", 0, 0).
+			YieldMessage(dlqMessage(t, "evt-1", "orders", "storage_failed", failedAt)).
+			YieldMessage(dlqMessage(t, "evt-2", "orders", "storage_failed", failedAt))
+		redriver := newRedriver(consumer, offsets, RedriveConfig{DLQTopic: "orders-dlq", Limit: 1}, logger)
+		sink := &recordingSink{}
+
+		stats, err := redriver.Run(context.Background(), sink)
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if stats.Matched != 1 || len(sink.records) != 1 {
+			t.Errorf("stats = %+v, want one matched event", stats)
+		}
+		_ = redriver.Close()
+	})
+
+	t.Run("rate limited", func(t *testing.T) {
+		consumer := newConsumer(t)
+		redriver := newRedriver(consumer, offsets, RedriveConfig{DLQTopic: "orders-dlq", RatePerSecond: 20}, logger)
+
+		start := time.Now()
+		stats, err := redriver.Run(context.Background(), &recordingSink{})
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		// Four matching events at 20 per second take at least 150ms
+		if elapsed := time.Since(start); stats.Matched != 4 || elapsed < 150*time.Millisecond {
+			t.Errorf("matched %d events in %v, want 4 rate limited events", stats.Matched, elapsed)
+		}
+		_ = redriver.Close()
+	})
+}
+
+func TestRedriver_EmptyPartition(t *testing.T) {
+	consumer := mocks.NewConsumer(t, nil)
+	consumer.SetTopicMetadata(map[string][]int32{"orders-dlq": {0}})
+	redriver := newRedriver(consumer, fakeOffsets{0: {5, 5}}, RedriveConfig{DLQTopic: "orders-dlq"}, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
+	stats, err := redriver.Run(context.Background(), &recordingSink{})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if stats != (RedriveStats{}) {
+		t.Errorf("stats = %+v, want none", stats)
+	}
+}
+
+func TestTopicRedriveSink_Redrive(t *testing.T) {
+	tests := []struct {
+		name      string
+		record    *RedriveRecord
+		wantKey   string
+		wantValue string
+		wantErr   bool
+	}{
+		{
+			name: "parsed event",
+			record: &RedriveRecord{
+				Key:   []byte("evt-1"),
+				Event: DLQEvent{OriginalTopic: "orders", OriginalKey: []byte("order-1"), OriginalEvent: json.RawMessage(`{"id":"evt-1"}`)},
+			},
+			wantKey:   "order-1",
+			wantValue: `{"id":"evt-1"}`,
+		},
+		{
+			name: "parsed event without key",
+			record: &RedriveRecord{
+				Key:   []byte("evt-1"),
+				Event: DLQEvent{OriginalTopic: "orders", OriginalEvent: json.RawMessage(`{"id":"evt-1"}`)},
+			},
+			wantKey:   "",
+			wantValue: `{"id":"evt-1"}`,
+		},
+		{
+			name: "raw message",
+			record: &RedriveRecord{
+				Key: []byte("ignored"),
+				Event: DLQEvent{
+					OriginalTopic:   "orders",
+					OriginalEvent:   json.RawMessage("null"),
+					OriginalKey:     []byte("order-1"),
+					OriginalValue:   []byte(`{"id":"evt-2"}`),
+					OriginalHeaders: []RawHeader{{Key: "trace", Value: []byte("abc")}},
+				},
+			},
+			wantKey:   "order-1",
+			wantValue: `{"id":"evt-2"}`,
+		},
+		{name: "no original topic", record: &RedriveRecord{Event: DLQEvent{OriginalEvent: json.RawMessage(`{}`)}}, wantErr: true},
+		{name: "no original event", record: &RedriveRecord{Event: DLQEvent{OriginalTopic: "orders"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			producer := mocks.NewSyncProducer(t, nil)
+			defer producer.Close()
+			if !tt.wantErr {
+				producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+					var key []byte
+					if msg.Key != nil {
+						key, _ = msg.Key.Encode()
+					}
+					value, _ := msg.Value.Encode()
+					if msg.Topic != "orders" || string(key) != tt.wantKey || string(value) != tt.wantValue {
+						return fmt.Errorf("message = %s/%s/%s", msg.Topic, key, value)
+					}
+					if len(tt.record.Event.OriginalHeaders) != len(msg.Headers) {
+						return fmt.Errorf("headers = %v, want original headers", msg.Headers)
+					}
+					return nil
+				})
+			}
+
+			sink := &TopicRedriveSink{producer: producer, logger: slog.New(slog.NewTextHandler(os.Stdout, nil))}
+			err := sink.Redrive(context.Background(), tt.record)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Redrive() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDryRunRedriveSink(t *testing.T) {
+	var out bytes.Buffer
+	sink := NewDryRunRedriveSink(&out)
+
+	record := &RedriveRecord{
+		Partition: 1,
+		Offset:    42,
+		Event: DLQEvent{
+			OriginalEvent: json.RawMessage(`{"id":"evt-1"}`),
+			OriginalTopic: "orders",
+			FailureReason: "storage_failed",
+			RetryCount:    3,
+		},
+	}
+	if err := sink.Redrive(context.Background(), record); err != nil {
+		t.Fatalf("Redrive() error = %v", err)
+	}
+	if err := sink.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	var line dryRunLine
+	if err := json.Unmarshal([]byte(strings.TrimSpace(out.String())), &line); err != nil {
+		t.Fatalf("dry-run output %q is not JSON: %v", out.String(), err)
+	}
+	if line.EventID != "evt-1" || line.DLQOffset != 42 || line.OriginalTopic != "orders" || line.RetryCount != 3 {
+		t.Errorf("dry-run line = %+v", line)
+	}
+}
@@ -0,0 +1,169 @@
+// Package kafka implements tiered retry topics for failed events.
+package kafka
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/jittakal/kafeventstore/pkg/event"
+)
+
+// Headers written to retry and DLQ messages.
+const (
+	// HeaderRetryCount holds the number of retries an event has been through.
+	HeaderRetryCount = "retry_count"
+	// HeaderRetryAt holds the Unix time in milliseconds after which a retry
+	// message may be reprocessed.
+	HeaderRetryAt = "retry_at"
+	// HeaderRedacted marks retried events whose data has already been
+	// redacted. It is set from the DLQ record of the event; the consumer
+	// drops it from consumed messages, so producers cannot skip redaction.
+	HeaderRedacted = "redacted"
+)
+
+// RetryTopic returns the retry topic of topic for an attempt, e.g. orders-retry-2.
+func RetryTopic(topic, suffix string, attempt int) string {
+	return topic + suffix + "-" + strconv.Itoa(attempt)
+}
+
+// RetryTopicSuffixes returns the topic suffixes of retry attempts 1 to maxRetries.
+func RetryTopicSuffixes(suffix string, maxRetries int) []string {
+	suffixes := make([]string, 0, maxRetries)
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		suffixes = append(suffixes, RetryTopic("", suffix, attempt))
+	}
+	return suffixes
+}
+
+// RetryTopics returns the retry topics of topics for attempts 1 to maxRetries.
+func RetryTopics(topics []string, suffix string, maxRetries int) []string {
+	retryTopics := make([]string, 0, len(topics)*maxRetries)
+	for _, topic := range topics {
+		for _, retrySuffix := range RetryTopicSuffixes(suffix, maxRetries) {
+			retryTopics = append(retryTopics, topic+retrySuffix)
+		}
+	}
+	return retryTopics
+}
+
+// RetryTopicPattern returns the topic pattern matching the retry topics of
+// topics and of the topics matching pattern, or "" when both are empty.
+func RetryTopicPattern(topics []string, pattern, suffix string) string {
+	alternatives := make([]string, 0, len(topics)+1)
+	for _, topic := range topics {
+		alternatives = append(alternatives, regexp.QuoteMeta(topic))
+	}
+	if pattern != "" {
+		alternatives = append(alternatives, "(?:"+pattern+")")
+	}
+	if len(alternatives) == 0 {
+		return ""
+	}
+	return "(?:" + strings.Join(alternatives, "|") + ")" + regexp.QuoteMeta(suffix) + "-[0-9]+"
+}
+
+// RetriedTopic returns the topic a retry topic message was first consumed
+// from: its original_topic header, else the retry topic without its suffix.
+func RetriedTopic(message RawMessage, suffix string) string {
+	for _, header := range message.Headers {
+		if header.Key == HeaderOriginalTopic && len(header.Value) > 0 {
+			return string(header.Value)
+		}
+	}
+	i := strings.LastIndex(message.Topic, suffix+"-")
+	if i < 0 {
+		return message.Topic
+	}
+	if _, err := strconv.Atoi(message.Topic[i+len(suffix)+1:]); err != nil {
+		return message.Topic
+	}
+	return message.Topic[:i]
+}
+
+// retryDelay returns the delay before an attempt is reprocessed. The delay
+// doubles with each attempt, starting at backoff.
+func retryDelay(backoff time.Duration, attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	return backoff << (attempt - 1)
+}
+
+// RetryCount returns the number of retries recorded in the headers of an event.
+func RetryCount(metadata event.KafkaMetadata) int {
+	count, err := strconv.Atoi(metadata.Headers[HeaderRetryCount])
+	if err != nil || count < 0 {
+		return 0
+	}
+	return count
+}
+
+// IsRedacted reports whether the data of an event has already been redacted.
+func IsRedacted(metadata event.KafkaMetadata) bool {
+	return metadata.Headers[HeaderRedacted] == "true"
+}
+
+// MarkRedacted records in the headers of an event that its data has been
+// redacted, so retries and DLQ records of the event keep that state.
+func MarkRedacted(metadata *event.KafkaMetadata) {
+	if metadata.Headers == nil {
+		metadata.Headers = make(map[string]string)
+	}
+	metadata.Headers[HeaderRedacted] = "true"
+}
+
+// parseRetryMessage unwraps the DLQEvent of a retry topic message. The
+// returned metadata refers to the original topic, partition, offset, key and
+// headers, and retryAt, read from the message headers, is when the message
+// may be reprocessed.
+func parseRetryMessage(message *sarama.ConsumerMessage, headers map[string]string) (*event.CloudEvent, event.KafkaMetadata, time.Time, error) {
+	var dlqEvent DLQEvent
+	if err := json.Unmarshal(message.Value, &dlqEvent); err != nil {
+		return nil, event.KafkaMetadata{}, time.Time{}, fmt.Errorf("failed to unmarshal retry event: %w", err)
+	}
+
+	var cloudEvent event.CloudEvent
+	if err := json.Unmarshal(dlqEvent.OriginalEvent, &cloudEvent); err != nil {
+		return nil, event.KafkaMetadata{}, time.Time{}, fmt.Errorf("failed to unmarshal original event: %w", err)
+	}
+
+	var retryAt time.Time
+	if millis, err := strconv.ParseInt(headers[HeaderRetryAt], 10, 64); err == nil {
+		retryAt = time.UnixMilli(millis)
+	}
+
+	// The event gets its original headers back, plus the retry state
+	originalHeaders := make(map[string]string, len(dlqEvent.OriginalHeaders)+2)
+	for _, header := range dlqEvent.OriginalHeaders {
+		originalHeaders[header.Key] = string(header.Value)
+	}
+	originalHeaders[HeaderRetryCount] = strconv.Itoa(dlqEvent.RetryCount)
+	if dlqEvent.Redacted {
+		originalHeaders[HeaderRedacted] = "true"
+	}
+	if len(dlqEvent.Attempts) > 0 {
+		if attempts, err := json.Marshal(dlqEvent.Attempts); err == nil {
+			originalHeaders[HeaderAttempts] = string(attempts)
+		}
+	}
+
+	timestamp := dlqEvent.OriginalTimestamp
+	if timestamp.IsZero() {
+		timestamp = message.Timestamp
+	}
+
+	metadata := event.KafkaMetadata{
+		Topic:     dlqEvent.OriginalTopic,
+		Partition: dlqEvent.OriginalPartition,
+		Offset:    dlqEvent.OriginalOffset,
+		Key:       dlqEvent.OriginalKey,
+		Timestamp: timestamp,
+		Headers:   originalHeaders,
+	}
+	return &cloudEvent, metadata, retryAt, nil
+}
@@ -0,0 +1,364 @@
+package kafka
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/jittakal/kafeventstore/pkg/event"
+)
+
+func TestRetryTopics(t *testing.T) {
+	if got := RetryTopic("orders", "-retry", 2); got != "orders-retry-2" {
+		t.Errorf("RetryTopic() = %s, want orders-retry-2", got)
+	}
+
+	wantSuffixes := []string{"-retry-1", "-retry-2"}
+	if got := RetryTopicSuffixes("-retry", 2); !reflect.DeepEqual(got, wantSuffixes) {
+		t.Errorf("RetryTopicSuffixes() = %v, want %v", got, wantSuffixes)
+	}
+	if got := RetryTopicSuffixes("-retry", 0); len(got) != 0 {
+		t.Errorf("RetryTopicSuffixes() = %v, want none", got)
+	}
+
+	wantTopics := []string{"orders-retry-1", "orders-retry-2", "payments-retry-1", "payments-retry-2"}
+	if got := RetryTopics([]string{"orders", "payments"}, "-retry", 2); !reflect.DeepEqual(got, wantTopics) {
+		t.Errorf("RetryTopics() = %v, want %v", got, wantTopics)
+	}
+}
+
+func TestRetryTopicPattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		topics  []string
+		pattern string
+		match   []string
+		noMatch []string
+	}{
+		{
+			name:    "static topics",
+			topics:  []string{"orders", "pay.ments"},
+			match:   []string{"orders-retry-1", "pay.ments-retry-3"},
+			noMatch: []string{"orders", "payxments-retry-1", "orders-retry-1-dlq", "audit-retry-1"},
+		},
+		{
+			name:    "pattern",
+			pattern: `.*\.events`,
+			match:   []string{"orders.events-retry-2"},
+			noMatch: []string{"orders.events", "orders-retry-1"},
+		},
+		{
+			name:    "static topics and pattern",
+			topics:  []string{"audit"},
+			pattern: `.*\.events`,
+			match:   []string{"audit-retry-1", "orders.events-retry-1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			discovery, err := newTopicDiscovery(&fakeTopicLister{}, RetryTopicPattern(tt.topics, tt.pattern, "-retry"), nil)
+			if err != nil {
+				t.Fatalf("newTopicDiscovery() error = %v", err)
+			}
+			if got := discovery.match(append(tt.match, tt.noMatch...)); !reflect.DeepEqual(got, tt.match) {
+				t.Errorf("matched %v, want %v", got, tt.match)
+			}
+		})
+	}
+
+	if got := RetryTopicPattern(nil, "", "-retry"); got != "" {
+		t.Errorf("RetryTopicPattern() = %q, want none", got)
+	}
+}
+
+func TestRetriedTopic(t *testing.T) {
+	tests := []struct {
+		name    string
+		message RawMessage
+		want    string
+	}{
+		{name: "original topic header", message: RawMessage{Topic: "orders-retry-1", Headers: []RawHeader{{Key: HeaderOriginalTopic, Value: []byte("orders")}}}, want: "orders"},
+		{name: "retry topic", message: RawMessage{Topic: "orders-retry-2"}, want: "orders"},
+		{name: "other topic", message: RawMessage{Topic: "orders-retry-x"}, want: "orders-retry-x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RetriedTopic(tt.message, "-retry"); got != tt.want {
+				t.Errorf("RetriedTopic() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("attempt %d", tt.attempt), func(t *testing.T) {
+			if got := retryDelay(30*time.Second, tt.attempt); got != tt.want {
+				t.Errorf("retryDelay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryCount(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    int
+	}{
+		{"no headers", nil, 0},
+		{"retried", map[string]string{HeaderRetryCount: "2"}, 2},
+		{"invalid", map[string]string{HeaderRetryCount: "two"}, 0},
+		{"negative", map[string]string{HeaderRetryCount: "-1"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RetryCount(event.KafkaMetadata{Headers: tt.headers}); got != tt.want {
+				t.Errorf("RetryCount() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkRedacted(t *testing.T) {
+	var metadata event.KafkaMetadata
+	if IsRedacted(metadata) {
+		t.Error("IsRedacted() without headers = true, want false")
+	}
+	MarkRedacted(&metadata)
+	if !IsRedacted(metadata) {
+		t.Error("IsRedacted() after MarkRedacted() = false, want true")
+	}
+}
+
+func TestParseRetryMessage(t *testing.T) {
+	original, _ := json.Marshal(&event.CloudEvent{ID: "evt-1", Source: "orders", Type: "order.created", SpecVersion: "1.0"})
+	value, _ := json.Marshal(DLQEvent{
+		OriginalEvent:     original,
+		OriginalTopic:     "orders",
+		OriginalPartition: 3,
+		OriginalOffset:    42,
+		FailureReason:     "storage_failed",
+		RetryCount:        2,
+		Redacted:          true,
+	})
+	retryAt := time.Now().Add(time.Minute).Truncate(time.Millisecond)
+	message := &sarama.ConsumerMessage{Topic: "orders-retry-2", Value: value, Timestamp: time.Now()}
+	headers := map[string]string{HeaderRetryAt: strconv.FormatInt(retryAt.UnixMilli(), 10)}
+
+	cloudEvent, metadata, gotRetryAt, err := parseRetryMessage(message, headers)
+	if err != nil {
+		t.Fatalf("parseRetryMessage() error = %v", err)
+	}
+	if cloudEvent.ID != "evt-1" || cloudEvent.Type != "order.created" {
+		t.Errorf("event = %+v, want evt-1", cloudEvent)
+	}
+	if metadata.Topic != "orders" || metadata.Partition != 3 || metadata.Offset != 42 {
+		t.Errorf("metadata = %+v, want orders/3/42", metadata)
+	}
+	if RetryCount(metadata) != 2 {
+		t.Errorf("RetryCount() = %d, want 2", RetryCount(metadata))
+	}
+	if !IsRedacted(metadata) {
+		t.Error("IsRedacted() = false, want true")
+	}
+	if !gotRetryAt.Equal(retryAt) {
+		t.Errorf("retryAt = %v, want %v", gotRetryAt, retryAt)
+	}
+
+	if _, _, _, err := parseRetryMessage(&sarama.ConsumerMessage{Value: []byte("not json")}, nil); err == nil {
+		t.Error("expected error for invalid retry message")
+	}
+}
+
+// newTestRetryPublisher creates a DLQ publisher backed by a mock producer.
+func newTestRetryPublisher(t *testing.T, maxRetries int) (*DLQPublisher, *mocks.SyncProducer) {
+	t.Helper()
+
+	producer := mocks.NewSyncProducer(t, nil)
+	t.Cleanup(func() { _ = producer.Close() })
+	return &DLQPublisher{
+		producer: producer,
+		config: DLQConfig{
+			Enabled:          true,
+			TopicSuffix:      "-dlq",
+			MaxRetries:       maxRetries,
+			RetryTopicSuffix: "-retry",
+			RetryBackoff:     time.Second,
+		},
+		logger:      slog.New(slog.NewTextHandler(os.Stdout, nil)),
+		processorID: "test",
+	}, producer
+}
+
+// expectMessage returns a checker for the topic, retry count and retry delay of a message.
+func expectMessage(topic string, retryCount int, retry bool) mocks.MessageChecker {
+	return func(msg *sarama.ProducerMessage) error {
+		if msg.Topic != topic {
+			return fmt.Errorf("topic = %s, want %s", msg.Topic, topic)
+		}
+		headers := make(map[string]string)
+		for _, header := range msg.Headers {
+			headers[string(header.Key)] = string(header.Value)
+		}
+		if headers[HeaderRetryCount] != strconv.Itoa(retryCount) {
+			return fmt.Errorf("retry_count = %s, want %d", headers[HeaderRetryCount], retryCount)
+		}
+		if _, ok := headers[HeaderRetryAt]; ok != retry {
+			return fmt.Errorf("retry_at present = %v, want %v", ok, retry)
+		}
+
+		value, err := msg.Value.Encode()
+		if err != nil {
+			return err
+		}
+		var dlqEvent DLQEvent
+		if err := json.Unmarshal(value, &dlqEvent); err != nil {
+			return err
+		}
+		if dlqEvent.RetryCount != retryCount || dlqEvent.OriginalTopic != "orders" {
+			return fmt.Errorf("DLQ event = %+v, want retry count %d from orders", dlqEvent, retryCount)
+		}
+		return nil
+	}
+}
+
+func TestDLQPublisher_Retry(t *testing.T) {
+	evt := &event.CloudEvent{ID: "evt-1"}
+
+	tests := []struct {
+		name       string
+		retryCount string
+		wantTopic  string
+		wantCount  int
+		wantRetry  bool
+	}{
+		{"first failure", "", "orders-retry-1", 1, true},
+		{"second failure", "1", "orders-retry-2", 2, true},
+		{"retries exhausted", "2", "orders-dlq", 2, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			publisher, producer := newTestRetryPublisher(t, 2)
+			producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(expectMessage(tt.wantTopic, tt.wantCount, tt.wantRetry))
+
+			metadata := event.KafkaMetadata{Topic: "orders"}
+			if tt.retryCount != "" {
+				metadata.Headers = map[string]string{HeaderRetryCount: tt.retryCount}
+			}
+			if err := publisher.Retry(t.Context(), "orders-dlq", evt, metadata, "storage_failed"); err != nil {
+				t.Errorf("Retry() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestDLQPublisher_RetryKeepsAttemptsAndHeaders(t *testing.T) {
+	publisher, producer := newTestRetryPublisher(t, 1)
+
+	// The first failure goes to the retry topic
+	var retryMessage *sarama.ConsumerMessage
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		value, err := msg.Value.Encode()
+		if err != nil {
+			return err
+		}
+		retryMessage = &sarama.ConsumerMessage{Topic: msg.Topic, Value: value}
+		for _, header := range msg.Headers {
+			retryMessage.Headers = append(retryMessage.Headers, &sarama.RecordHeader{Key: header.Key, Value: header.Value})
+		}
+		return nil
+	})
+	originalTime := time.UnixMilli(1700000000000)
+	metadata := event.KafkaMetadata{Topic: "orders", Offset: 5, Key: []byte("order-1"), Timestamp: originalTime, Headers: map[string]string{"trace": "abc", HeaderRedacted: "true"}}
+	if err := publisher.Retry(t.Context(), "orders-dlq", &event.CloudEvent{ID: "evt-1"}, metadata, ReasonStorageFailed); err != nil {
+		t.Fatalf("Retry() error = %v", err)
+	}
+
+	// The retried event gets its original headers and timestamp back
+	headers := make(map[string]string)
+	for _, header := range retryMessage.Headers {
+		headers[string(header.Key)] = string(header.Value)
+	}
+	_, retried, _, err := parseRetryMessage(retryMessage, headers)
+	if err != nil {
+		t.Fatalf("parseRetryMessage() error = %v", err)
+	}
+	if retried.Headers["trace"] != "abc" || retried.Headers[HeaderFailureReason] != "" || !retried.Timestamp.Equal(originalTime) || string(retried.Key) != "order-1" {
+		t.Errorf("retried metadata = %+v, want original key, headers and timestamp", retried)
+	}
+	if !IsRedacted(retried) {
+		t.Error("retried event is not marked redacted")
+	}
+
+	// The second failure reaches the DLQ with both attempts
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		value, err := msg.Value.Encode()
+		if err != nil {
+			return err
+		}
+		var dlqEvent DLQEvent
+		if err := json.Unmarshal(value, &dlqEvent); err != nil {
+			return err
+		}
+		if len(dlqEvent.Attempts) != 2 || dlqEvent.RetryCount != 1 {
+			return fmt.Errorf("DLQ event = %+v, want 2 attempts after 1 retry", dlqEvent)
+		}
+		if len(dlqEvent.OriginalHeaders) != 1 || dlqEvent.OriginalHeaders[0].Key != "trace" {
+			return fmt.Errorf("original headers = %+v, want trace", dlqEvent.OriginalHeaders)
+		}
+		if string(dlqEvent.OriginalKey) != "order-1" {
+			return fmt.Errorf("original key = %q, want order-1", dlqEvent.OriginalKey)
+		}
+		if !dlqEvent.Redacted {
+			return fmt.Errorf("DLQ event is not marked redacted")
+		}
+		return nil
+	})
+	if err := publisher.Retry(t.Context(), "orders-dlq", &event.CloudEvent{ID: "evt-1"}, retried, ReasonStorageFailed); err != nil {
+		t.Errorf("Retry() error = %v", err)
+	}
+}
+
+func TestDLQPublisher_RetryFallsBackToDLQ(t *testing.T) {
+	publisher, producer := newTestRetryPublisher(t, 3)
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndFail(expectMessage("orders-retry-1", 1, true), errors.New("unknown topic"))
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(expectMessage("orders-dlq", 0, false))
+
+	metadata := event.KafkaMetadata{Topic: "orders"}
+	if err := publisher.Retry(t.Context(), "orders-dlq", &event.CloudEvent{ID: "evt-1"}, metadata, "storage_failed"); err != nil {
+		t.Errorf("Retry() error = %v", err)
+	}
+}
+
+func TestDLQPublisher_RetryDisabled(t *testing.T) {
+	publisher, producer := newTestRetryPublisher(t, 0)
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(expectMessage("orders-dlq", 0, false))
+
+	metadata := event.KafkaMetadata{Topic: "orders"}
+	if err := publisher.Retry(t.Context(), "orders-dlq", &event.CloudEvent{ID: "evt-1"}, metadata, "storage_failed"); err != nil {
+		t.Errorf("Retry() error = %v", err)
+	}
+}
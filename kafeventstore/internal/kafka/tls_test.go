@@ -0,0 +1,339 @@
+package kafka
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCert is a certificate and key generated for TLS tests.
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// newTestCert creates a certificate signed by parent, or a self-signed CA when parent is nil.
+func newTestCert(t *testing.T, commonName string, parent *testCert, dnsNames ...string) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatalf("failed to generate serial: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     dnsNames,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+// writeFile writes data to path, failing the test on error.
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+// startTLSServer starts a TLS server that requires client certificates signed by clientCA.
+// It returns the server address and a channel receiving each handshake result.
+func startTLSServer(t *testing.T, server *testCert, clientCA *testCert) (string, <-chan error) {
+	t.Helper()
+
+	serverCert, err := tls.X509KeyPair(server.certPEM, server.keyPEM)
+	if err != nil {
+		t.Fatalf("failed to load server key pair: %v", err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCA.cert)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+		MinVersion:   tls.VersionTLS12,
+	})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	results := make(chan error, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			results <- conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+	return listener.Addr().String(), results
+}
+
+// dialTLS performs a client handshake and returns the client or server side error.
+// TLS 1.3 client handshakes complete before the server verifies the client
+// certificate, so the server result is always awaited.
+func dialTLS(addr string, config *tls.Config, serverResults <-chan error) error {
+	conn, clientErr := tls.Dial("tcp", addr, config)
+	if clientErr == nil {
+		conn.Close()
+	}
+	serverErr := <-serverResults
+	if clientErr != nil {
+		return clientErr
+	}
+	return serverErr
+}
+
+func TestTLSVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    uint16
+		wantErr bool
+	}{
+		{version: "", want: tls.VersionTLS12},
+		{version: "1.2", want: tls.VersionTLS12},
+		{version: "1.3", want: tls.VersionTLS13},
+		{version: "1.0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			got, err := tlsVersion(tt.version)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("tlsVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("tlsVersion() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewTLSConfig_Validation(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "test-ca", nil)
+	client := newTestCert(t, "client", ca)
+	writeFile(t, filepath.Join(dir, "ca.pem"), ca.certPEM)
+	writeFile(t, filepath.Join(dir, "client.pem"), client.certPEM)
+	writeFile(t, filepath.Join(dir, "client-key.pem"), client.keyPEM)
+	writeFile(t, filepath.Join(dir, "empty.pem"), []byte("not a certificate"))
+
+	tests := []struct {
+		name    string
+		config  TLSConfig
+		wantErr bool
+	}{
+		{name: "system roots", config: TLSConfig{}, wantErr: false},
+		{name: "CA and client certificate", config: TLSConfig{
+			CAFile:   filepath.Join(dir, "ca.pem"),
+			CertFile: filepath.Join(dir, "client.pem"),
+			KeyFile:  filepath.Join(dir, "client-key.pem"),
+		}, wantErr: false},
+		{name: "missing CA file", config: TLSConfig{CAFile: filepath.Join(dir, "missing.pem")}, wantErr: true},
+		{name: "CA file without certificates", config: TLSConfig{CAFile: filepath.Join(dir, "empty.pem")}, wantErr: true},
+		{name: "cert without key", config: TLSConfig{CertFile: filepath.Join(dir, "client.pem")}, wantErr: true},
+		{name: "unsupported min version", config: TLSConfig{MinVersion: "1.1"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTLSConfig(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newTLSConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewTLSConfig_InsecureIsOptIn(t *testing.T) {
+	config, err := newTLSConfig(TLSConfig{MinVersion: "1.3"})
+	if err != nil {
+		t.Fatalf("newTLSConfig() error = %v", err)
+	}
+	if config.InsecureSkipVerify {
+		t.Error("expected certificate verification by default")
+	}
+	if config.MinVersion != tls.VersionTLS13 {
+		t.Errorf("MinVersion = %d, want TLS 1.3", config.MinVersion)
+	}
+
+	config, err = newTLSConfig(TLSConfig{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("newTLSConfig() error = %v", err)
+	}
+	if !config.InsecureSkipVerify || config.VerifyConnection != nil {
+		t.Error("expected verification disabled when explicitly requested")
+	}
+}
+
+func TestNewTLSConfig_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "test-ca", nil)
+	otherCA := newTestCert(t, "other-ca", nil)
+	server := newTestCert(t, "broker", ca, "kafka.example.com")
+	client := newTestCert(t, "client", ca)
+
+	caFile := filepath.Join(dir, "ca.pem")
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+	writeFile(t, caFile, ca.certPEM)
+	writeFile(t, certFile, client.certPEM)
+	writeFile(t, keyFile, client.keyPEM)
+
+	addr, serverResults := startTLSServer(t, server, ca)
+
+	tests := []struct {
+		name       string
+		caPEM      []byte
+		serverName string
+		wantErr    bool
+	}{
+		{name: "trusted CA", caPEM: ca.certPEM, serverName: "kafka.example.com", wantErr: false},
+		{name: "host name mismatch", caPEM: ca.certPEM, serverName: "other.example.com", wantErr: true},
+		{name: "untrusted CA", caPEM: otherCA.certPEM, serverName: "kafka.example.com", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeFile(t, caFile, tt.caPEM)
+			config, err := newTLSConfig(TLSConfig{
+				CAFile:     caFile,
+				CertFile:   certFile,
+				KeyFile:    keyFile,
+				ServerName: tt.serverName,
+			})
+			if err != nil {
+				t.Fatalf("newTLSConfig() error = %v", err)
+			}
+
+			err = dialTLS(addr, config, serverResults)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("handshake error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewTLSConfig_ReloadsCertificates(t *testing.T) {
+	dir := t.TempDir()
+	oldCA := newTestCert(t, "old-ca", nil)
+	newCA := newTestCert(t, "new-ca", nil)
+
+	caFile := filepath.Join(dir, "ca.pem")
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+	oldClient := newTestCert(t, "client", oldCA)
+	writeFile(t, caFile, oldCA.certPEM)
+	writeFile(t, certFile, oldClient.certPEM)
+	writeFile(t, keyFile, oldClient.keyPEM)
+
+	config, err := newTLSConfig(TLSConfig{
+		CAFile:     caFile,
+		CertFile:   certFile,
+		KeyFile:    keyFile,
+		ServerName: "kafka.example.com",
+	})
+	if err != nil {
+		t.Fatalf("newTLSConfig() error = %v", err)
+	}
+
+	// The broker and the client certificate are both rotated to a new CA
+	addr, serverResults := startTLSServer(t, newTestCert(t, "broker", newCA, "kafka.example.com"), newCA)
+	if err := dialTLS(addr, config, serverResults); err == nil {
+		t.Fatal("expected handshake to fail before the certificates are rotated")
+	}
+
+	newClient := newTestCert(t, "client", newCA)
+	future := time.Now().Add(time.Minute)
+	for path, data := range map[string][]byte{caFile: newCA.certPEM, certFile: newClient.certPEM, keyFile: newClient.keyPEM} {
+		writeFile(t, path, data)
+		if err := os.Chtimes(path, future, future); err != nil {
+			t.Fatalf("failed to touch %s: %v", path, err)
+		}
+	}
+
+	if err := dialTLS(addr, config, serverResults); err != nil {
+		t.Errorf("handshake after rotation error = %v", err)
+	}
+}
+
+func TestCertReloader_KeepsLastGoodCertificate(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "test-ca", nil)
+	client := newTestCert(t, "client", ca)
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+	writeFile(t, certFile, client.certPEM)
+	writeFile(t, keyFile, client.keyPEM)
+
+	reloader := &certReloader{certFile: certFile, keyFile: keyFile}
+	first, err := reloader.certificate()
+	if err != nil {
+		t.Fatalf("certificate() error = %v", err)
+	}
+
+	// A partially written secret must not break new connections
+	writeFile(t, certFile, []byte("garbage"))
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(certFile, future, future); err != nil {
+		t.Fatalf("failed to touch cert file: %v", err)
+	}
+	got, err := reloader.certificate()
+	if err != nil {
+		t.Fatalf("certificate() error = %v", err)
+	}
+	if got != first {
+		t.Error("expected the last loaded certificate to be kept")
+	}
+}
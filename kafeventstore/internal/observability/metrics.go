@@ -17,12 +17,17 @@ type Metrics struct {
 	RebalanceDuration  *prometheus.HistogramVec
 	PartitionsAssigned *prometheus.GaugeVec
 	CommitLatency      *prometheus.HistogramVec
+	TopicsDiscovered   *prometheus.CounterVec
+	SubscribedTopics   prometheus.Gauge
 
 	// Processing metrics
 	EventsProcessed    *prometheus.CounterVec
 	ProcessingDuration *prometheus.HistogramVec
 	BufferSize         *prometheus.GaugeVec
 	BufferRecordCount  *prometheus.GaugeVec
+	ValidationFailures *prometheus.CounterVec
+	SchemaChanges      *prometheus.CounterVec
+	FieldsRedacted     *prometheus.CounterVec
 
 	// Storage metrics
 	FilesWritten         *prometheus.CounterVec
@@ -30,6 +35,14 @@ type Metrics struct {
 	StorageWriteDuration *prometheus.HistogramVec
 	FileSize             *prometheus.HistogramVec
 	StorageErrors        *prometheus.CounterVec
+
+	// Multi-sink metrics
+	SinkWrites        *prometheus.CounterVec
+	SinkWriteDuration *prometheus.HistogramVec
+
+	// DLQ metrics
+	DLQPublishFailures *prometheus.CounterVec
+	EventsQuarantined  *prometheus.CounterVec
 }
 
 // NewMetrics creates and registers all Prometheus metrics.
@@ -89,6 +102,19 @@ func NewMetrics(registry *prometheus.Registry) *Metrics {
 			},
 			[]string{"topic", "partition"},
 		),
+		TopicsDiscovered: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_topics_discovered_total",
+				Help: "Total number of topics added to the subscription by pattern discovery",
+			},
+			[]string{"topic"},
+		),
+		SubscribedTopics: factory.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "kafka_subscribed_topics",
+				Help: "Number of topics the consumer is subscribed to",
+			},
+		),
 
 		// Processing metrics
 		EventsProcessed: factory.NewCounterVec(
@@ -121,6 +147,28 @@ func NewMetrics(registry *prometheus.Registry) *Metrics {
 			[]string{"topic", "partition"},
 		),
 
+		ValidationFailures: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "events_validation_failures_total",
+				Help: "Total number of events rejected by validation",
+			},
+			[]string{"field", "reason"},
+		),
+		SchemaChanges: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "schema_changes_total",
+				Help: "Total number of event schema changes by kind: version, epoch or rejected",
+			},
+			[]string{"topic", "type", "change"},
+		),
+		FieldsRedacted: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "fields_redacted_total",
+				Help: "Total number of event data fields redacted, by rule path and action",
+			},
+			[]string{"path", "action"},
+		),
+
 		// Storage metrics
 		FilesWritten: factory.NewCounterVec(
 			prometheus.CounterOpts{
@@ -160,6 +208,39 @@ func NewMetrics(registry *prometheus.Registry) *Metrics {
 			},
 			[]string{"backend", "error_type"},
 		),
+
+		// Multi-sink metrics
+		SinkWrites: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "sink_writes_total",
+				Help: "Total number of batch writes per storage sink",
+			},
+			[]string{"sink", "status"},
+		),
+		SinkWriteDuration: factory.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "sink_write_duration_seconds",
+				Help:    "Duration of batch writes per storage sink",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"sink"},
+		),
+
+		// DLQ metrics
+		DLQPublishFailures: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "dlq_publish_failures_total",
+				Help: "Total number of events the DLQ rejected",
+			},
+			[]string{"topic", "reason"},
+		),
+		EventsQuarantined: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "events_quarantined_total",
+				Help: "Total number of rejected DLQ events written to the storage quarantine",
+			},
+			[]string{"topic", "reason", "status"},
+		),
 	}
 }
 
@@ -193,6 +274,16 @@ func (m *Metrics) SetPartitionsAssigned(topic string, count float64) {
 	m.PartitionsAssigned.WithLabelValues(topic).Set(count)
 }
 
+// IncTopicsDiscovered increments the discovered topics counter.
+func (m *Metrics) IncTopicsDiscovered(topic string) {
+	m.TopicsDiscovered.WithLabelValues(topic).Inc()
+}
+
+// SetSubscribedTopics sets the number of subscribed topics.
+func (m *Metrics) SetSubscribedTopics(count float64) {
+	m.SubscribedTopics.Set(count)
+}
+
 // IncFilesWritten increments files written counter.
 func (m *Metrics) IncFilesWritten(topic string, partition int32, format string, status string) {
 	m.FilesWritten.WithLabelValues(topic, fmt.Sprintf("%d", partition), format, status).Inc()
@@ -212,3 +303,38 @@ func (m *Metrics) ObserveStorageWriteDuration(topic string, partition int32, dur
 func (m *Metrics) IncStorageErrors(backend string, operation string) {
 	m.StorageErrors.WithLabelValues(backend, operation).Inc()
 }
+
+// IncValidationFailures increments the counter of events rejected by validation.
+func (m *Metrics) IncValidationFailures(field string, reason string) {
+	m.ValidationFailures.WithLabelValues(field, reason).Inc()
+}
+
+// IncSchemaChanges increments the counter of schema changes of an event type.
+func (m *Metrics) IncSchemaChanges(topic string, eventType string, change string) {
+	m.SchemaChanges.WithLabelValues(topic, eventType, change).Inc()
+}
+
+// AddFieldsRedacted adds count to the counter of redacted event data fields.
+func (m *Metrics) AddFieldsRedacted(path string, action string, count int) {
+	m.FieldsRedacted.WithLabelValues(path, action).Add(float64(count))
+}
+
+// IncSinkWrites increments the batch writes counter of a storage sink.
+func (m *Metrics) IncSinkWrites(sink string, status string) {
+	m.SinkWrites.WithLabelValues(sink, status).Inc()
+}
+
+// ObserveSinkWriteDuration observes the batch write duration of a storage sink.
+func (m *Metrics) ObserveSinkWriteDuration(sink string, duration float64) {
+	m.SinkWriteDuration.WithLabelValues(sink).Observe(duration)
+}
+
+// IncDLQPublishFailures increments the counter of events the DLQ rejected.
+func (m *Metrics) IncDLQPublishFailures(topic string, reason string) {
+	m.DLQPublishFailures.WithLabelValues(topic, reason).Inc()
+}
+
+// IncEventsQuarantined increments the counter of events written to the storage quarantine.
+func (m *Metrics) IncEventsQuarantined(topic string, reason string, status string) {
+	m.EventsQuarantined.WithLabelValues(topic, reason, status).Inc()
+}
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestNewMetrics(t *testing.T) {
@@ -59,6 +60,79 @@ func TestMetrics_IncStorageErrors(t *testing.T) {
 	metrics.IncStorageErrors("file", "write")
 }
 
+func TestMetrics_SinkWrites(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics := NewMetrics(registry)
+
+	metrics.IncSinkWrites("s3-primary", "success")
+	metrics.IncSinkWrites("gcs-mirror", "error")
+	metrics.ObserveSinkWriteDuration("s3-primary", 0.25)
+
+	if got := testutil.ToFloat64(metrics.SinkWrites.WithLabelValues("gcs-mirror", "error")); got != 1 {
+		t.Errorf("sink_writes_total{gcs-mirror,error} = %v, want 1", got)
+	}
+}
+
+func TestMetrics_DLQFailures(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics := NewMetrics(registry)
+
+	metrics.IncDLQPublishFailures("orders", "storage_failed")
+	metrics.IncEventsQuarantined("orders", "storage_failed", "success")
+	metrics.IncEventsQuarantined("orders", "storage_failed", "error")
+
+	if got := testutil.ToFloat64(metrics.DLQPublishFailures.WithLabelValues("orders", "storage_failed")); got != 1 {
+		t.Errorf("dlq_publish_failures_total{orders,storage_failed} = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(metrics.EventsQuarantined.WithLabelValues("orders", "storage_failed", "error")); got != 1 {
+		t.Errorf("events_quarantined_total{orders,storage_failed,error} = %v, want 1", got)
+	}
+}
+
+func TestMetrics_ValidationFailures(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics := NewMetrics(registry)
+
+	metrics.IncValidationFailures("source", "not_allowed")
+	metrics.IncValidationFailures("source", "not_allowed")
+	metrics.IncValidationFailures("time", "too_old")
+
+	if got := testutil.ToFloat64(metrics.ValidationFailures.WithLabelValues("source", "not_allowed")); got != 2 {
+		t.Errorf("events_validation_failures_total{source,not_allowed} = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(metrics.ValidationFailures.WithLabelValues("time", "too_old")); got != 1 {
+		t.Errorf("events_validation_failures_total{time,too_old} = %v, want 1", got)
+	}
+}
+
+func TestMetrics_SchemaChanges(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics := NewMetrics(registry)
+
+	metrics.IncSchemaChanges("orders", "order.created", "version")
+	metrics.IncSchemaChanges("orders", "order.created", "version")
+	metrics.IncSchemaChanges("orders", "order.created", "epoch")
+
+	if got := testutil.ToFloat64(metrics.SchemaChanges.WithLabelValues("orders", "order.created", "version")); got != 2 {
+		t.Errorf("schema_changes_total{orders,order.created,version} = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(metrics.SchemaChanges.WithLabelValues("orders", "order.created", "epoch")); got != 1 {
+		t.Errorf("schema_changes_total{orders,order.created,epoch} = %v, want 1", got)
+	}
+}
+
+func TestMetrics_FieldsRedacted(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics := NewMetrics(registry)
+
+	metrics.AddFieldsRedacted("$.memberEmail", "sha256", 1)
+	metrics.AddFieldsRedacted("$.memberEmail", "sha256", 2)
+
+	if got := testutil.ToFloat64(metrics.FieldsRedacted.WithLabelValues("$.memberEmail", "sha256")); got != 3 {
+		t.Errorf("fields_redacted_total{$.memberEmail,sha256} = %v, want 3", got)
+	}
+}
+
 func TestMetrics_ObserveCommitLatency(t *testing.T) {
 	registry := prometheus.NewRegistry()
 	metrics := NewMetrics(registry)
@@ -141,6 +215,21 @@ func TestMetrics_SetPartitionsAssigned(t *testing.T) {
 	metrics.SetPartitionsAssigned("another-topic", 10.0)
 }
 
+func TestMetrics_TopicDiscovery(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics := NewMetrics(registry)
+
+	metrics.IncTopicsDiscovered("payments.events")
+	metrics.SetSubscribedTopics(3)
+
+	if got := testutil.ToFloat64(metrics.TopicsDiscovered.WithLabelValues("payments.events")); got != 1 {
+		t.Errorf("kafka_topics_discovered_total{payments.events} = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(metrics.SubscribedTopics); got != 3 {
+		t.Errorf("kafka_subscribed_topics = %v, want 3", got)
+	}
+}
+
 func TestMetrics_MultipleTopicsAndPartitions(t *testing.T) {
 	registry := prometheus.NewRegistry()
 	metrics := NewMetrics(registry)
@@ -0,0 +1,181 @@
+// Package redact implements the JSON paths that select the values to redact.
+package redact
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// segmentKind is the kind of a path segment.
+type segmentKind int
+
+const (
+	segmentField    segmentKind = iota // .name or ['name']
+	segmentIndex                       // [n]
+	segmentWildcard                    // .* or [*]
+)
+
+// segment is a step of a path into JSON data.
+type segment struct {
+	kind  segmentKind
+	name  string
+	index int
+}
+
+// Path is a JSON path into event data, such as $.member.email,
+// $.loans[*].memberName or $['member-id']. It selects object fields by name,
+// array items by index and all fields or items with *.
+type Path struct {
+	text     string
+	segments []segment
+}
+
+// ParsePath parses a JSON path. The leading $ is optional; the path must
+// select below the root.
+func ParsePath(text string) (Path, error) {
+	rest := text
+	if rest == "$" || strings.HasPrefix(rest, "$.") || strings.HasPrefix(rest, "$[") {
+		rest = rest[1:]
+	} else if rest != "" && rest[0] != '.' && rest[0] != '[' {
+		// Paths without $ start with a field name
+		rest = "." + rest
+	}
+
+	var segments []segment
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			name := rest[1 : end+1]
+			if name == "" {
+				return Path{}, fmt.Errorf("invalid path %s: empty field name", text)
+			}
+			if name == "*" {
+				segments = append(segments, segment{kind: segmentWildcard})
+			} else {
+				segments = append(segments, segment{kind: segmentField, name: name})
+			}
+			rest = rest[end+1:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return Path{}, fmt.Errorf("invalid path %s: unclosed bracket", text)
+			}
+			selector := rest[1:end]
+			switch {
+			case selector == "*":
+				segments = append(segments, segment{kind: segmentWildcard})
+			case len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0]:
+				segments = append(segments, segment{kind: segmentField, name: selector[1 : len(selector)-1]})
+			default:
+				index, err := strconv.Atoi(selector)
+				if err != nil || index < 0 {
+					return Path{}, fmt.Errorf("invalid path %s: invalid selector [%s]", text, selector)
+				}
+				segments = append(segments, segment{kind: segmentIndex, index: index})
+			}
+			rest = rest[end+1:]
+		default:
+			return Path{}, fmt.Errorf("invalid path %s: unexpected %q", text, rest[0])
+		}
+	}
+
+	if len(segments) == 0 {
+		return Path{}, fmt.Errorf("invalid path %s: the path must select a field", text)
+	}
+	return Path{text: text, segments: segments}, nil
+}
+
+// String returns the path as written.
+func (p Path) String() string {
+	return p.text
+}
+
+// visitFunc returns the new value of a selected value, or keep false to
+// remove it.
+type visitFunc func(value any) (replacement any, keep bool, err error)
+
+// apply calls visit for every value the path selects in data decoded from
+// JSON and returns the modified data. Missing fields and items select nothing.
+func (p Path) apply(data any, visit visitFunc) (any, error) {
+	result, _, err := applySegments(data, p.segments, visit)
+	return result, err
+}
+
+// applySegments applies the remaining segments to node.
+func applySegments(node any, segments []segment, visit visitFunc) (any, bool, error) {
+	if len(segments) == 0 {
+		return visit(node)
+	}
+	seg, rest := segments[0], segments[1:]
+
+	switch n := node.(type) {
+	case map[string]any:
+		switch seg.kind {
+		case segmentField:
+			child, ok := n[seg.name]
+			if !ok {
+				return n, true, nil
+			}
+			if err := applyField(n, seg.name, child, rest, visit); err != nil {
+				return nil, false, err
+			}
+		case segmentWildcard:
+			for name, child := range n {
+				if err := applyField(n, name, child, rest, visit); err != nil {
+					return nil, false, err
+				}
+			}
+		}
+		return n, true, nil
+	case []any:
+		switch seg.kind {
+		case segmentIndex:
+			if seg.index >= len(n) {
+				return n, true, nil
+			}
+			value, keep, err := applySegments(n[seg.index], rest, visit)
+			if err != nil {
+				return nil, false, err
+			}
+			if !keep {
+				return append(n[:seg.index:seg.index], n[seg.index+1:]...), true, nil
+			}
+			n[seg.index] = value
+		case segmentWildcard:
+			items := n[:0]
+			for _, item := range n {
+				value, keep, err := applySegments(item, rest, visit)
+				if err != nil {
+					return nil, false, err
+				}
+				if keep {
+					items = append(items, value)
+				}
+			}
+			return items, true, nil
+		}
+		return n, true, nil
+	default:
+		// Scalars have no fields or items
+		return node, true, nil
+	}
+}
+
+// applyField applies the remaining segments to a field of object.
+func applyField(object map[string]any, name string, child any, segments []segment, visit visitFunc) error {
+	value, keep, err := applySegments(child, segments, visit)
+	if err != nil {
+		return err
+	}
+	if keep {
+		object[name] = value
+	} else {
+		delete(object, name)
+	}
+	return nil
+}
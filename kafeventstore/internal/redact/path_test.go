@@ -0,0 +1,110 @@
+package redact
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    []segment
+		wantErr bool
+	}{
+		{path: "$.memberEmail", want: []segment{{kind: segmentField, name: "memberEmail"}}},
+		{path: "memberEmail", want: []segment{{kind: segmentField, name: "memberEmail"}}},
+		{path: "$.member.email", want: []segment{{kind: segmentField, name: "member"}, {kind: segmentField, name: "email"}}},
+		{path: "$.loans[*].memberName", want: []segment{{kind: segmentField, name: "loans"}, {kind: segmentWildcard}, {kind: segmentField, name: "memberName"}}},
+		{path: "$.loans[2]", want: []segment{{kind: segmentField, name: "loans"}, {kind: segmentIndex, index: 2}}},
+		{path: "$['member-id']", want: []segment{{kind: segmentField, name: "member-id"}}},
+		{path: `$["a.b"].*`, want: []segment{{kind: segmentField, name: "a.b"}, {kind: segmentWildcard}}},
+		{path: "$", wantErr: true},
+		{path: "", wantErr: true},
+		{path: "$.a..b", wantErr: true},
+		{path: "$.a[", wantErr: true},
+		{path: "$.a[-1]", wantErr: true},
+		{path: "$.a[x]", wantErr: true},
+		{path: "$id", want: []segment{{kind: segmentField, name: "$id"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := ParsePath(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got.segments) != len(tt.want) {
+				t.Fatalf("ParsePath() = %+v, want %+v", got.segments, tt.want)
+			}
+			for i := range tt.want {
+				if got.segments[i] != tt.want[i] {
+					t.Errorf("segment %d = %+v, want %+v", i, got.segments[i], tt.want[i])
+				}
+			}
+			if got.String() != tt.path {
+				t.Errorf("String() = %s, want %s", got.String(), tt.path)
+			}
+		})
+	}
+}
+
+func TestPath_Apply(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		data string
+		want string
+	}{
+		{name: "field", path: "$.a", data: `{"a":"x","b":"y"}`, want: `{"a":"X","b":"y"}`},
+		{name: "nested field", path: "$.a.b", data: `{"a":{"b":"x"}}`, want: `{"a":{"b":"X"}}`},
+		{name: "missing field", path: "$.a.c", data: `{"a":{"b":"x"}}`, want: `{"a":{"b":"x"}}`},
+		{name: "field of scalar", path: "$.a.b", data: `{"a":"x"}`, want: `{"a":"x"}`},
+		{name: "all items", path: "$.a[*].b", data: `{"a":[{"b":"x"},{"c":"y"},{"b":"z"}]}`, want: `{"a":[{"b":"X"},{"c":"y"},{"b":"Z"}]}`},
+		{name: "item", path: "$.a[1]", data: `{"a":["x","y"]}`, want: `{"a":["x","Y"]}`},
+		{name: "item out of range", path: "$.a[5]", data: `{"a":["x"]}`, want: `{"a":["x"]}`},
+		{name: "all fields", path: "$.a.*", data: `{"a":{"b":"x","c":"y"}}`, want: `{"a":{"b":"X","c":"Y"}}`},
+		{name: "remove field", path: "$.a.drop", data: `{"a":{"drop":"drop","b":"y"}}`, want: `{"a":{"b":"y"}}`},
+		{name: "remove items", path: "$.a[*]", data: `{"a":["drop","x","drop"]}`, want: `{"a":["X"]}`},
+		{name: "remove item", path: "$.a[0]", data: `{"a":["drop","x"]}`, want: `{"a":["x"]}`},
+		{name: "root array", path: "$[*].a", data: `[{"a":"x"}]`, want: `[{"a":"X"}]`},
+	}
+
+	// upper upper-cases strings and removes "drop"
+	upper := func(value any) (any, bool, error) {
+		s, _ := value.(string)
+		if s == "drop" {
+			return nil, false, nil
+		}
+		b := []byte(s)
+		for i := range b {
+			if b[i] >= 'a' && b[i] <= 'z' {
+				b[i] -= 'a' - 'A'
+			}
+		}
+		return string(b), true, nil
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := ParsePath(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var data any
+			if err := json.Unmarshal([]byte(tt.data), &data); err != nil {
+				t.Fatal(err)
+			}
+			result, err := path.apply(data, upper)
+			if err != nil {
+				t.Fatalf("apply() error = %v", err)
+			}
+			got, _ := json.Marshal(result)
+			if string(got) != tt.want {
+				t.Errorf("apply() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
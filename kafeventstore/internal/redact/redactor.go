@@ -0,0 +1,248 @@
+// Package redact implements redaction of personal data in CloudEvent data
+// before events are stored.
+package redact
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jittakal/kafeventstore/pkg/event"
+)
+
+// Ensure implementation satisfies interface at compile time.
+var _ event.Transformer = (*Redactor)(nil)
+
+// Action is how a rule redacts the values it selects.
+type Action string
+
+const (
+	// ActionDrop removes the field or array item.
+	ActionDrop Action = "drop"
+	// ActionSHA256 replaces the value with the hex SHA-256 digest of the salt
+	// followed by the value.
+	ActionSHA256 Action = "sha256"
+	// ActionTokenize replaces the value with a short token keyed by the salt,
+	// stable across events so redacted values can still be joined.
+	ActionTokenize Action = "tokenize"
+	// ActionTruncate keeps the first Length characters of the value.
+	ActionTruncate Action = "truncate"
+	// ActionMask replaces the matches of Pattern in the value.
+	ActionMask Action = "mask"
+)
+
+// TokenPrefix prefixes the tokens of tokenized values.
+const TokenPrefix = "tok_"
+
+// Rule redacts the values a path selects in the data of events of a type.
+type Rule struct {
+	// Type matches event types exactly, or by prefix when it ends in *;
+	// empty matches every type.
+	Type   string
+	Path   string
+	Action Action
+	// Length is the number of characters truncate keeps.
+	Length int
+	// Pattern is the regular expression mask replaces.
+	Pattern string
+	// Replacement replaces each match of Pattern and may refer to its
+	// groups as $1; empty masks every matched character with *.
+	Replacement string
+}
+
+// Config configures a Redactor.
+type Config struct {
+	// Salt keys the sha256 and tokenize actions.
+	Salt  string
+	Rules []Rule
+}
+
+// MetricsCollector defines redaction metrics operations.
+type MetricsCollector interface {
+	AddFieldsRedacted(path string, action string, count int)
+}
+
+// compiledRule is a rule with its path and pattern parsed.
+type compiledRule struct {
+	Rule
+	path    Path
+	pattern *regexp.Regexp
+}
+
+// Redactor redacts event data with rules. Values selected by several rules
+// are redacted by each in order. It is safe for concurrent use.
+type Redactor struct {
+	salt    []byte
+	rules   []compiledRule
+	metrics MetricsCollector
+}
+
+// NewRedactor creates a redactor of config.
+func NewRedactor(config Config, metrics MetricsCollector) (*Redactor, error) {
+	rules := make([]compiledRule, 0, len(config.Rules))
+	for i, rule := range config.Rules {
+		path, err := ParsePath(rule.Path)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+		compiled := compiledRule{Rule: rule, path: path}
+
+		switch rule.Action {
+		case ActionDrop:
+		case ActionSHA256, ActionTokenize:
+			if config.Salt == "" {
+				return nil, fmt.Errorf("rule %d: %s requires a salt", i, rule.Action)
+			}
+		case ActionTruncate:
+			if rule.Length < 0 {
+				return nil, fmt.Errorf("rule %d: truncate length must be non-negative", i)
+			}
+		case ActionMask:
+			if rule.Pattern == "" {
+				return nil, fmt.Errorf("rule %d: mask requires a pattern", i)
+			}
+			compiled.pattern, err = regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: invalid mask pattern: %w", i, err)
+			}
+		default:
+			return nil, fmt.Errorf("rule %d: unsupported action: %s", i, rule.Action)
+		}
+		rules = append(rules, compiled)
+	}
+
+	return &Redactor{
+		salt:    []byte(config.Salt),
+		rules:   rules,
+		metrics: metrics,
+	}, nil
+}
+
+// Transform redacts the data of e with the rules of its type. Data of types
+// with rules must be JSON; events whose data cannot be redacted are rejected
+// rather than stored unredacted. Data is re-encoded only when a value was
+// redacted.
+func (r *Redactor) Transform(e *event.CloudEvent) error {
+	var rules []*compiledRule
+	for i := range r.rules {
+		if matchType(r.rules[i].Type, e.Type) {
+			rules = append(rules, &r.rules[i])
+		}
+	}
+	if len(rules) == 0 || len(e.Data) == 0 {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(e.Data))
+	decoder.UseNumber()
+	var data any
+	if err := decoder.Decode(&data); err != nil {
+		return fmt.Errorf("failed to redact event %s: data is not JSON: %w", e.ID, err)
+	}
+
+	// Fields are counted once the whole event is redacted
+	counts := make([]int, len(rules))
+	for i, rule := range rules {
+		var err error
+		data, err = rule.path.apply(data, func(value any) (any, bool, error) {
+			if value == nil {
+				return nil, true, nil
+			}
+			counts[i]++
+			return r.redact(rule, value)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to redact %s of event %s: %w", rule.Path, e.ID, err)
+		}
+	}
+
+	redacted := 0
+	for i, rule := range rules {
+		redacted += counts[i]
+		if r.metrics != nil && counts[i] > 0 {
+			r.metrics.AddFieldsRedacted(rule.Path, string(rule.Action), counts[i])
+		}
+	}
+	if redacted == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(data); err != nil {
+		return fmt.Errorf("failed to encode redacted data of event %s: %w", e.ID, err)
+	}
+	e.Data = json.RawMessage(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+	return nil
+}
+
+// redact returns the redacted value of a non-null value.
+func (r *Redactor) redact(rule *compiledRule, value any) (any, bool, error) {
+	if rule.Action == ActionDrop {
+		return nil, false, nil
+	}
+	text, err := valueText(value)
+	if err != nil {
+		return nil, false, err
+	}
+
+	switch rule.Action {
+	case ActionSHA256:
+		digest := sha256.Sum256(append(append([]byte(nil), r.salt...), text...))
+		return hex.EncodeToString(digest[:]), true, nil
+	case ActionTokenize:
+		mac := hmac.New(sha256.New, r.salt)
+		mac.Write([]byte(text))
+		return TokenPrefix + hex.EncodeToString(mac.Sum(nil)[:16]), true, nil
+	case ActionTruncate:
+		if utf8.RuneCountInString(text) <= rule.Length {
+			return text, true, nil
+		}
+		return string([]rune(text)[:rule.Length]), true, nil
+	case ActionMask:
+		if rule.Replacement != "" {
+			return rule.pattern.ReplaceAllString(text, rule.Replacement), true, nil
+		}
+		return rule.pattern.ReplaceAllStringFunc(text, func(match string) string {
+			return strings.Repeat("*", utf8.RuneCountInString(match))
+		}), true, nil
+	}
+	return nil, false, fmt.Errorf("unsupported action: %s", rule.Action)
+}
+
+// valueText returns the text of a scalar value: strings as they are, numbers
+// and booleans as written in JSON. Objects and arrays cannot be redacted as
+// text; select their fields or items instead.
+func valueText(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		if v {
+			return "true", nil
+		}
+		return "false", nil
+	case map[string]any:
+		return "", fmt.Errorf("cannot redact an object as text")
+	case []any:
+		return "", fmt.Errorf("cannot redact an array as text")
+	}
+	return "", fmt.Errorf("unsupported value %T", value)
+}
+
+// matchType reports whether an event type matches a rule type.
+func matchType(pattern, eventType string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(eventType, prefix)
+	}
+	return pattern == "" || pattern == eventType
+}
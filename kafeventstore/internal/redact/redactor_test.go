@@ -0,0 +1,234 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/jittakal/kafeventstore/pkg/event"
+)
+
+// mockMetrics records redacted field counts by path and action.
+type mockMetrics struct {
+	redacted map[string]int
+}
+
+func (m *mockMetrics) AddFieldsRedacted(path string, action string, count int) {
+	if m.redacted == nil {
+		m.redacted = make(map[string]int)
+	}
+	m.redacted[path+" "+action] += count
+}
+
+func newEvent(eventType, data string) *event.CloudEvent {
+	return &event.CloudEvent{
+		ID:          "1",
+		Source:      "library",
+		SpecVersion: "1.0",
+		Type:        eventType,
+		Data:        json.RawMessage(data),
+	}
+}
+
+func sha256Hex(s string) string {
+	digest := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(digest[:])
+}
+
+func TestRedactor_Transform(t *testing.T) {
+	mac := hmac.New(sha256.New, []byte("pepper"))
+	mac.Write([]byte("m-42"))
+	token := TokenPrefix + hex.EncodeToString(mac.Sum(nil)[:16])
+
+	tests := []struct {
+		name  string
+		rules []Rule
+		data  string
+		want  string
+	}{
+		{
+			name:  "drop",
+			rules: []Rule{{Path: "$.memberName", Action: ActionDrop}},
+			data:  `{"memberName":"Ada Lovelace","isbn":"978-0"}`,
+			want:  `{"isbn":"978-0"}`,
+		},
+		{
+			name:  "sha256 with salt",
+			rules: []Rule{{Path: "$.memberEmail", Action: ActionSHA256}},
+			data:  `{"memberEmail":"ada@example.com"}`,
+			want:  `{"memberEmail":"` + sha256Hex("pepperada@example.com") + `"}`,
+		},
+		{
+			name:  "tokenize",
+			rules: []Rule{{Path: "$.member.id", Action: ActionTokenize}},
+			data:  `{"member":{"id":"m-42"}}`,
+			want:  `{"member":{"id":"` + token + `"}}`,
+		},
+		{
+			name:  "truncate",
+			rules: []Rule{{Path: "$.postcode", Action: ActionTruncate, Length: 3}, {Path: "$.city", Action: ActionTruncate, Length: 10}},
+			data:  `{"postcode":"SW1A 1AA","city":"Zürich"}`,
+			want:  `{"city":"Zürich","postcode":"SW1"}`,
+		},
+		{
+			name:  "mask characters",
+			rules: []Rule{{Path: "$.card", Action: ActionMask, Pattern: `\d{4} `}},
+			data:  `{"card":"4111 1111"}`,
+			want:  `{"card":"*****1111"}`,
+		},
+		{
+			name:  "mask with replacement",
+			rules: []Rule{{Path: "$.memberEmail", Action: ActionMask, Pattern: `^(.)[^@]*@`, Replacement: "${1}***@"}},
+			data:  `{"memberEmail":"ada@example.com"}`,
+			want:  `{"memberEmail":"a***@example.com"}`,
+		},
+		{
+			name:  "array items and numbers",
+			rules: []Rule{{Path: "$.loans[*].memberId", Action: ActionTruncate, Length: 2}},
+			data:  `{"loans":[{"memberId":12345},{"memberId":null},{"isbn":"978-0"}]}`,
+			want:  `{"loans":[{"memberId":"12"},{"memberId":null},{"isbn":"978-0"}]}`,
+		},
+		{
+			name:  "rules of other types",
+			rules: []Rule{{Type: "com.library.payments.*", Path: "$.memberEmail", Action: ActionDrop}},
+			data:  `{"memberEmail":"ada@example.com"}`,
+			want:  `{"memberEmail":"ada@example.com"}`,
+		},
+		{
+			name:  "nothing selected keeps the data as written",
+			rules: []Rule{{Path: "$.memberEmail", Action: ActionDrop}},
+			data:  `{ "isbn": "978-0", "price": 1.50 }`,
+			want:  `{ "isbn": "978-0", "price": 1.50 }`,
+		},
+		{
+			name:  "numbers and html kept",
+			rules: []Rule{{Path: "$.memberName", Action: ActionDrop}},
+			data:  `{"memberName":"x","price":1.50,"note":"<b>&</b>"}`,
+			want:  `{"note":"<b>&</b>","price":1.50}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redactor, err := NewRedactor(Config{Salt: "pepper", Rules: tt.rules}, nil)
+			if err != nil {
+				t.Fatalf("NewRedactor() error = %v", err)
+			}
+			e := newEvent("com.library.books.issued", tt.data)
+			if err := redactor.Transform(e); err != nil {
+				t.Fatalf("Transform() error = %v", err)
+			}
+			if string(e.Data) != tt.want {
+				t.Errorf("Transform() data = %s, want %s", e.Data, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactor_TransformErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+		data string
+	}{
+		{name: "data is not json", rule: Rule{Path: "$.a", Action: ActionDrop}, data: `a=1`},
+		{name: "object as text", rule: Rule{Path: "$.a", Action: ActionSHA256}, data: `{"a":{"b":1}}`},
+		{name: "array as text", rule: Rule{Path: "$.a", Action: ActionMask, Pattern: "."}, data: `{"a":[1]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redactor, err := NewRedactor(Config{Salt: "pepper", Rules: []Rule{tt.rule}}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			e := newEvent("com.library.books.issued", tt.data)
+			if err := redactor.Transform(e); err == nil {
+				t.Error("Transform() error = nil, want error")
+			}
+			if string(e.Data) != tt.data {
+				t.Errorf("data = %s after a failed redaction, want it unchanged", e.Data)
+			}
+		})
+	}
+}
+
+func TestRedactor_Metrics(t *testing.T) {
+	metrics := &mockMetrics{}
+	redactor, err := NewRedactor(Config{Salt: "pepper", Rules: []Rule{
+		{Type: "com.library.*", Path: "$.loans[*].memberEmail", Action: ActionSHA256},
+		{Type: "com.library.books.issued", Path: "$.memberName", Action: ActionDrop},
+		{Path: "$.missing", Action: ActionDrop},
+	}}, metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := newEvent("com.library.books.issued", `{"memberName":"Ada","loans":[{"memberEmail":"a@x"},{"memberEmail":"b@x"}]}`)
+	if err := redactor.Transform(e); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(e.Data), "@x") || strings.Contains(string(e.Data), "Ada") {
+		t.Errorf("data = %s, want redacted", e.Data)
+	}
+
+	want := map[string]int{
+		"$.loans[*].memberEmail sha256": 2,
+		"$.memberName drop":             1,
+	}
+	if len(metrics.redacted) != len(want) {
+		t.Errorf("redacted = %v, want %v", metrics.redacted, want)
+	}
+	for key, count := range want {
+		if metrics.redacted[key] != count {
+			t.Errorf("redacted[%s] = %d, want %d", key, metrics.redacted[key], count)
+		}
+	}
+}
+
+func TestNewRedactor_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		salt string
+		rule Rule
+	}{
+		{name: "invalid path", rule: Rule{Path: "$", Action: ActionDrop}},
+		{name: "sha256 without salt", rule: Rule{Path: "$.a", Action: ActionSHA256}},
+		{name: "tokenize without salt", rule: Rule{Path: "$.a", Action: ActionTokenize}},
+		{name: "negative length", salt: "s", rule: Rule{Path: "$.a", Action: ActionTruncate, Length: -1}},
+		{name: "mask without pattern", rule: Rule{Path: "$.a", Action: ActionMask}},
+		{name: "invalid pattern", rule: Rule{Path: "$.a", Action: ActionMask, Pattern: "("}},
+		{name: "unsupported action", rule: Rule{Path: "$.a", Action: "encrypt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRedactor(Config{Salt: tt.salt, Rules: []Rule{tt.rule}}, nil); err == nil {
+				t.Error("NewRedactor() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestMatchType(t *testing.T) {
+	tests := []struct {
+		pattern   string
+		eventType string
+		want      bool
+	}{
+		{pattern: "", eventType: "com.library.books.issued", want: true},
+		{pattern: "com.library.books.issued", eventType: "com.library.books.issued", want: true},
+		{pattern: "com.library.*", eventType: "com.library.books.issued", want: true},
+		{pattern: "com.library.books", eventType: "com.library.books.issued", want: false},
+		{pattern: "com.shop.*", eventType: "com.library.books.issued", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := matchType(tt.pattern, tt.eventType); got != tt.want {
+			t.Errorf("matchType(%q, %q) = %v, want %v", tt.pattern, tt.eventType, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+// Package schema implements the resolution and caching of event schemas.
+package schema
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jittakal/kafeventstore/pkg/event"
+)
+
+const (
+	// maxSchemaBytes limits the size of fetched schema documents.
+	maxSchemaBytes = 4 << 20
+	// maxCachedSchemas bounds the compiled schemas a registry keeps, since
+	// every distinct dataschema of consumed events adds one. It bounds the
+	// remembered failures too.
+	maxCachedSchemas = 1000
+
+	// failureBackoff is how long a schema that failed to load is not fetched
+	// again; it doubles with each failure up to maxFailureBackoff.
+	failureBackoff    = 5 * time.Second
+	maxFailureBackoff = 5 * time.Minute
+)
+
+// Fetcher loads schema documents by reference.
+type Fetcher interface {
+	Fetch(ctx context.Context, ref string) ([]byte, error)
+}
+
+// Ensure implementations satisfy interfaces.
+var (
+	_ Fetcher = (*DirFetcher)(nil)
+	_ Fetcher = (*HTTPFetcher)(nil)
+)
+
+// DirFetcher loads schemas from a local directory. References are paths
+// relative to the directory; for URLs only the path is used, so
+// https://schemas.example.com/orders/v1.json loads <dir>/orders/v1.json.
+type DirFetcher struct {
+	dir string
+}
+
+// NewDirFetcher creates a fetcher of the schemas under dir.
+func NewDirFetcher(dir string) *DirFetcher {
+	return &DirFetcher{dir: dir}
+}
+
+// Fetch reads the schema file of ref. References cannot escape the directory.
+func (f *DirFetcher) Fetch(_ context.Context, ref string) ([]byte, error) {
+	name := ref
+	if u, err := url.Parse(ref); err == nil && u.Scheme != "" {
+		name = u.Path
+	}
+	file := filepath.Join(f.dir, filepath.FromSlash(path.Clean("/"+name)))
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema %s: %w", ref, err)
+	}
+	return data, nil
+}
+
+// HTTPFetcher loads schemas from an HTTP schema registry. References are
+// resolved against the registry URL and must stay under it, so events cannot
+// make the fetcher request other hosts or paths, also through redirects.
+type HTTPFetcher struct {
+	base   *url.URL
+	prefix string // path prefix every schema URL must have
+	client *http.Client
+}
+
+// NewHTTPFetcher creates a fetcher of the schemas of the registry at baseURL.
+func NewHTTPFetcher(baseURL string, timeout time.Duration) (*HTTPFetcher, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema registry URL: %w", err)
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported schema registry URL scheme: %s", base.Scheme)
+	}
+
+	// Relative references resolve into the directory of the base path
+	f := &HTTPFetcher{base: base, prefix: base.Path[:strings.LastIndex(base.Path, "/")+1]}
+	if f.prefix == "" {
+		f.prefix = "/"
+	}
+	f.client = &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("stopped after %d redirects", len(via))
+			}
+			return f.checkURL(req.URL)
+		},
+	}
+	return f, nil
+}
+
+// checkURL rejects URLs outside the schema registry.
+func (f *HTTPFetcher) checkURL(u *url.URL) error {
+	if u.Scheme != f.base.Scheme || u.Host != f.base.Host || u.User != nil ||
+		!strings.HasPrefix(path.Clean("/"+u.Path), f.prefix) {
+		return fmt.Errorf("schema %s is outside the schema registry %s", u.Redacted(), f.base.Redacted())
+	}
+	return nil
+}
+
+// Fetch downloads the schema of ref. References outside the registry URL
+// are rejected without a request.
+func (f *HTTPFetcher) Fetch(ctx context.Context, ref string) ([]byte, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse schema reference %s: %w", ref, err)
+	}
+	u = f.base.ResolveReference(u)
+	if err := f.checkURL(u); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema request: %w", err)
+	}
+	req.Header.Set("Accept", "application/schema+json, application/json")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch schema %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch schema %s: status %d", u, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSchemaBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema %s: %w", u, err)
+	}
+	return data, nil
+}
+
+// Registry resolves the schema of events and caches compiled schemas by
+// reference. At most maxCachedSchemas schemas are cached; a full cache drops
+// an arbitrary schema, which is compiled again on its next use. Failures are
+// remembered with a growing backoff, so events of an unavailable schema do
+// not each wait for a fetch. It is safe for concurrent use.
+type Registry struct {
+	fetcher Fetcher
+	types   map[string]string
+	now     func() time.Time
+
+	mu       sync.Mutex
+	schemas  map[string]*Schema
+	failures map[string]*failure
+}
+
+// failure is a schema that failed to load.
+type failure struct {
+	err     error
+	count   int
+	retryAt time.Time
+}
+
+// NewRegistry creates a registry loading schemas with fetcher. types maps
+// event types to the schema reference of events without a dataschema.
+func NewRegistry(fetcher Fetcher, types map[string]string) *Registry {
+	return &Registry{
+		fetcher:  fetcher,
+		types:    types,
+		now:      time.Now,
+		schemas:  make(map[string]*Schema),
+		failures: make(map[string]*failure),
+	}
+}
+
+// Ref returns the schema reference of an event: its dataschema, or the
+// schema mapped to its type. It returns "" when the event has no schema.
+func (r *Registry) Ref(cloudEvent *event.CloudEvent) string {
+	if cloudEvent.DataSchema != nil && *cloudEvent.DataSchema != "" {
+		return *cloudEvent.DataSchema
+	}
+	return r.types[cloudEvent.Type]
+}
+
+// Get returns the compiled schema of ref, fetching and compiling it on first
+// use. A schema that failed to load returns its last error until its backoff
+// has passed, and is then fetched again.
+func (r *Registry) Get(ctx context.Context, ref string) (*Schema, error) {
+	r.mu.Lock()
+	schema, ok := r.schemas[ref]
+	failed := r.failures[ref]
+	r.mu.Unlock()
+	if ok {
+		return schema, nil
+	}
+	if failed != nil && r.now().Before(failed.retryAt) {
+		return nil, fmt.Errorf("schema %s is unavailable until %s: %w", ref, failed.retryAt.Format(time.RFC3339), failed.err)
+	}
+
+	schema, err := r.load(ctx, ref)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		// Failures of a cancelled fetch say nothing about the schema
+		if ctx.Err() == nil {
+			r.fail(ref, err)
+		}
+		return nil, err
+	}
+	delete(r.failures, ref)
+	if cached, ok := r.schemas[ref]; ok {
+		return cached, nil
+	}
+	if len(r.schemas) >= maxCachedSchemas {
+		for evicted := range r.schemas {
+			delete(r.schemas, evicted)
+			break
+		}
+	}
+	r.schemas[ref] = schema
+	return schema, nil
+}
+
+// load fetches and compiles the schema of ref.
+func (r *Registry) load(ctx context.Context, ref string) (*Schema, error) {
+	document, err := r.fetcher.Fetch(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := Compile(document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile schema %s: %w", ref, err)
+	}
+	return schema, nil
+}
+
+// fail remembers a failure to load ref. The caller must hold r.mu.
+func (r *Registry) fail(ref string, err error) {
+	failed, ok := r.failures[ref]
+	if !ok {
+		if len(r.failures) >= maxCachedSchemas {
+			for evicted := range r.failures {
+				delete(r.failures, evicted)
+				break
+			}
+		}
+		failed = &failure{}
+		r.failures[ref] = failed
+	}
+	failed.err = err
+	failed.count++
+	backoff := maxFailureBackoff
+	if failed.count < 10 {
+		backoff = min(failureBackoff<<(failed.count-1), maxFailureBackoff)
+	}
+	failed.retryAt = r.now().Add(backoff)
+}
@@ -0,0 +1,231 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jittakal/kafeventstore/pkg/event"
+)
+
+func TestDirFetcher_Fetch(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "orders"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "orders", "v1.json"), []byte(`{"type": "object"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fetcher := NewDirFetcher(dir)
+
+	for _, ref := range []string{"orders/v1.json", "/orders/v1.json", "https://schemas.example.com/orders/v1.json", "file:///orders/v1.json"} {
+		if data, err := fetcher.Fetch(context.Background(), ref); err != nil || string(data) != `{"type": "object"}` {
+			t.Errorf("Fetch(%s) = %s, %v", ref, data, err)
+		}
+	}
+
+	// References cannot escape the directory
+	if err := os.WriteFile(filepath.Join(filepath.Dir(dir), "secret.json"), []byte(`{}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fetcher.Fetch(context.Background(), "../secret.json"); err == nil {
+		t.Error("Fetch(../secret.json) error = nil, want error")
+	}
+}
+
+func TestHTTPFetcher_Fetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/schemas/orders/v1.json" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"type": "object"}`))
+	}))
+	defer server.Close()
+
+	fetcher, err := NewHTTPFetcher(server.URL+"/schemas/", time.Second)
+	if err != nil {
+		t.Fatalf("NewHTTPFetcher() error = %v", err)
+	}
+
+	for _, ref := range []string{"orders/v1.json", server.URL + "/schemas/orders/v1.json"} {
+		if data, err := fetcher.Fetch(context.Background(), ref); err != nil || string(data) != `{"type": "object"}` {
+			t.Errorf("Fetch(%s) = %s, %v", ref, data, err)
+		}
+	}
+
+	// References outside the registry are never requested
+	for _, ref := range []string{
+		"http://169.254.169.254/latest/meta-data/",
+		server.URL + "/admin/orders.json",
+		"../admin/orders.json",
+		"//internal.example.com/schemas/orders/v1.json",
+		strings.Replace(server.URL, "http://", "http://user:pass@", 1) + "/schemas/orders/v1.json",
+	} {
+		if _, err := fetcher.Fetch(context.Background(), ref); err == nil || !strings.Contains(err.Error(), "outside the schema registry") {
+			t.Errorf("Fetch(%s) error = %v, want outside the schema registry", ref, err)
+		}
+	}
+	if _, err := fetcher.Fetch(context.Background(), "missing.json"); err == nil {
+		t.Error("Fetch(missing.json) error = nil, want error")
+	}
+
+	if _, err := NewHTTPFetcher("ftp://registry", time.Second); err == nil {
+		t.Error("NewHTTPFetcher(ftp) error = nil, want error")
+	}
+}
+
+// countingFetcher serves a schema document and counts fetches.
+type countingFetcher struct {
+	document string
+	err      error
+	fetches  atomic.Int32
+}
+
+func (f *countingFetcher) Fetch(context.Context, string) ([]byte, error) {
+	f.fetches.Add(1)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []byte(f.document), nil
+}
+
+func TestRegistry_Ref(t *testing.T) {
+	registry := NewRegistry(&countingFetcher{}, map[string]string{"order.created": "orders/v1.json"})
+	dataSchema := "https://schemas.example.com/orders/v2.json"
+
+	tests := []struct {
+		name  string
+		event *event.CloudEvent
+		want  string
+	}{
+		{"dataschema", &event.CloudEvent{Type: "order.created", DataSchema: &dataSchema}, dataSchema},
+		{"type mapping", &event.CloudEvent{Type: "order.created"}, "orders/v1.json"},
+		{"no schema", &event.CloudEvent{Type: "order.cancelled"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := registry.Ref(tt.event); got != tt.want {
+				t.Errorf("Ref() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPFetcher_FetchRedirect(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"type": "object"}`))
+	}))
+	defer other.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/schemas/moved.json":
+			http.Redirect(w, r, "/schemas/orders/v1.json", http.StatusFound)
+		case "/schemas/away.json":
+			http.Redirect(w, r, other.URL+"/schemas/orders/v1.json", http.StatusFound)
+		default:
+			_, _ = w.Write([]byte(`{"type": "object"}`))
+		}
+	}))
+	defer server.Close()
+
+	fetcher, err := NewHTTPFetcher(server.URL+"/schemas/", time.Second)
+	if err != nil {
+		t.Fatalf("NewHTTPFetcher() error = %v", err)
+	}
+	if _, err := fetcher.Fetch(context.Background(), "moved.json"); err != nil {
+		t.Errorf("Fetch() of a redirect within the registry error = %v", err)
+	}
+	if _, err := fetcher.Fetch(context.Background(), "away.json"); err == nil {
+		t.Error("Fetch() followed a redirect outside the registry")
+	}
+}
+
+func TestRegistry_Get(t *testing.T) {
+	fetcher := &countingFetcher{document: `{"type": "object"}`}
+	registry := NewRegistry(fetcher, nil)
+
+	first, err := registry.Get(context.Background(), "orders/v1.json")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	second, err := registry.Get(context.Background(), "orders/v1.json")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if first != second || fetcher.fetches.Load() != 1 {
+		t.Errorf("expected the compiled schema to be cached, fetched %d times", fetcher.fetches.Load())
+	}
+
+	// Failures are remembered until their backoff has passed
+	failing := &countingFetcher{err: errors.New("registry unavailable")}
+	now := time.Unix(0, 0)
+	registry = NewRegistry(failing, nil)
+	registry.now = func() time.Time { return now }
+	steps := []struct {
+		advance     time.Duration
+		wantFetches int32
+	}{
+		{0, 1},
+		{failureBackoff - time.Second, 1},
+		{time.Second, 2},
+		{failureBackoff, 2},
+		{failureBackoff, 3},
+	}
+	for i, step := range steps {
+		now = now.Add(step.advance)
+		if _, err := registry.Get(context.Background(), "orders/v1.json"); !errors.Is(err, failing.err) {
+			t.Errorf("step %d: Get() error = %v, want %v", i, err, failing.err)
+		}
+		if got := failing.fetches.Load(); got != step.wantFetches {
+			t.Errorf("step %d: fetched %d times, want %d", i, got, step.wantFetches)
+		}
+	}
+
+	// A successful fetch forgets the failure
+	now = now.Add(maxFailureBackoff)
+	failing.err = nil
+	failing.document = `{"type": "object"}`
+	if _, err := registry.Get(context.Background(), "orders/v1.json"); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if len(registry.failures) != 0 {
+		t.Errorf("remembered %d failures after success, want 0", len(registry.failures))
+	}
+
+	// Cancelled fetches are not remembered
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	registry = NewRegistry(&countingFetcher{err: context.Canceled}, nil)
+	if _, err := registry.Get(cancelled, "orders/v1.json"); err == nil {
+		t.Error("Get() error = nil, want error")
+	}
+	if len(registry.failures) != 0 {
+		t.Errorf("remembered %d cancelled failures, want 0", len(registry.failures))
+	}
+
+	if _, err := NewRegistry(&countingFetcher{document: `{"pattern": "("}`}, nil).Get(context.Background(), "bad.json"); err == nil {
+		t.Error("Get() of invalid schema error = nil, want error")
+	}
+}
+
+func TestRegistry_GetBoundsCache(t *testing.T) {
+	registry := NewRegistry(&countingFetcher{document: `{"type": "object"}`}, nil)
+	for i := 0; i < maxCachedSchemas+10; i++ {
+		if _, err := registry.Get(context.Background(), fmt.Sprintf("orders/v%d.json", i)); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+	}
+	if len(registry.schemas) != maxCachedSchemas {
+		t.Errorf("cached %d schemas, want %d", len(registry.schemas), maxCachedSchemas)
+	}
+}
@@ -0,0 +1,803 @@
+// Package schema validates JSON documents against JSON Schemas.
+//
+// It supports the validation keywords of JSON Schema drafts 4 to 2020-12:
+// type, enum, const, the numeric, string, array and object constraints
+// including contains, propertyNames and dependencies, the allOf/anyOf/oneOf/
+// not and if/then/else combinators, boolean schemas and $ref to JSON pointers
+// within the same document. Annotations such as format and description are
+// ignored. Schemas using keywords it cannot apply, such as
+// unevaluatedProperties, $dynamicRef or an $id below the root, fail to
+// compile rather than validating less than they say.
+//
+// Patterns are ECMA-262 regular expressions, which are compiled with Go's
+// RE2 syntax. \uXXXX escapes are translated; lookarounds and backreferences,
+// which RE2 cannot express, fail to compile.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Violation is the first part of a document that does not match its schema.
+type Violation struct {
+	// Pointer is the JSON pointer of the violating value; empty for the
+	// whole document.
+	Pointer string
+	Message string
+}
+
+func (v *Violation) Error() string {
+	pointer := v.Pointer
+	if pointer == "" {
+		pointer = "(root)"
+	}
+	return fmt.Sprintf("%s: %s", pointer, v.Message)
+}
+
+// Schema is a compiled JSON Schema.
+type Schema struct {
+	root *node
+}
+
+// node is a compiled schema or subschema.
+type node struct {
+	boolean *bool // set for the true and false schemas
+	ref     *node
+
+	types    []string
+	enum     []interface{}
+	constant *interface{}
+
+	// Numbers
+	minimum          *float64
+	maximum          *float64
+	exclusiveMinimum *float64
+	exclusiveMaximum *float64
+	multipleOf       *float64
+
+	// Strings
+	minLength *int
+	maxLength *int
+	pattern   *regexp.Regexp
+
+	// Arrays
+	prefixItems []*node
+	items       *node
+	minItems    *int
+	maxItems    *int
+	uniqueItems bool
+	contains    *node
+	minContains *int
+	maxContains *int
+
+	// Objects
+	properties           map[string]*node
+	patternProperties    []patternNode
+	additionalProperties *node
+	required             []string
+	minProperties        *int
+	maxProperties        *int
+	propertyNames        *node
+	dependentRequired    map[string][]string
+	dependentSchemas     map[string]*node
+
+	// Combinators
+	allOf []*node
+	anyOf []*node
+	oneOf []*node
+	not   *node
+	when  *node // if
+	then  *node
+	other *node // else
+}
+
+// unsupportedKeywords are keywords whose meaning the compiler cannot apply.
+// Ignoring them would accept documents the schema rejects.
+var unsupportedKeywords = []string{
+	"unevaluatedProperties",
+	"unevaluatedItems",
+	"$dynamicRef",
+	"$dynamicAnchor",
+	"$recursiveRef",
+	"$recursiveAnchor",
+}
+
+type patternNode struct {
+	pattern *regexp.Regexp
+	node    *node
+}
+
+// Compile compiles a JSON Schema document.
+func Compile(document []byte) (*Schema, error) {
+	var raw interface{}
+	if err := json.Unmarshal(document, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse schema: %w", err)
+	}
+	c := &compiler{document: raw, nodes: make(map[string]*node)}
+	root, err := c.compile(raw, "")
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{root: root}, nil
+}
+
+// Validate returns the first violation of instance, a document decoded with
+// encoding/json, or nil when it matches the schema.
+func (s *Schema) Validate(instance interface{}) *Violation {
+	return s.root.validate(instance, "")
+}
+
+// compiler compiles the subschemas of a document. Nodes are memoized by
+// their JSON pointer, so recursive references compile once.
+type compiler struct {
+	document interface{}
+	nodes    map[string]*node
+}
+
+func (c *compiler) compile(value interface{}, pointer string) (*node, error) {
+	if n, ok := c.nodes[pointer]; ok {
+		return n, nil
+	}
+	n := &node{}
+	c.nodes[pointer] = n
+
+	switch v := value.(type) {
+	case bool:
+		n.boolean = &v
+		return n, nil
+	case map[string]interface{}:
+		if err := c.compileObject(n, v, pointer); err != nil {
+			return nil, err
+		}
+		return n, nil
+	default:
+		return nil, fmt.Errorf("schema at %q must be an object or a boolean", pointer)
+	}
+}
+
+func (c *compiler) compileObject(n *node, s map[string]interface{}, pointer string) error {
+	for _, keyword := range unsupportedKeywords {
+		if _, ok := s[keyword]; ok {
+			return fmt.Errorf("unsupported keyword %s at %q", keyword, pointer)
+		}
+	}
+	// An $id below the root starts a document of its own, changing what
+	// the references within it resolve against
+	if _, ok := s["$id"]; ok && pointer != "" {
+		return fmt.Errorf("unsupported keyword $id at %q: only the root may have an $id", pointer)
+	}
+
+	var err error
+	sub := func(key string) (*node, error) {
+		value, ok := s[key]
+		if !ok {
+			return nil, nil
+		}
+		return c.compile(value, pointer+"/"+escape(key))
+	}
+	subs := func(key string) ([]*node, error) {
+		value, ok := s[key]
+		if !ok {
+			return nil, nil
+		}
+		list, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s at %q must be an array", key, pointer)
+		}
+		nodes := make([]*node, 0, len(list))
+		for i, item := range list {
+			child, err := c.compile(item, fmt.Sprintf("%s/%s/%d", pointer, escape(key), i))
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, child)
+		}
+		return nodes, nil
+	}
+
+	if ref, ok := s["$ref"].(string); ok {
+		target, err := c.resolve(ref)
+		if err != nil {
+			return err
+		}
+		if n.ref, err = c.compile(target, strings.TrimPrefix(ref, "#")); err != nil {
+			return err
+		}
+	}
+
+	switch t := s["type"].(type) {
+	case string:
+		n.types = []string{t}
+	case []interface{}:
+		for _, item := range t {
+			name, ok := item.(string)
+			if !ok {
+				return fmt.Errorf("type at %q must be a string or an array of strings", pointer)
+			}
+			n.types = append(n.types, name)
+		}
+	}
+	if enum, ok := s["enum"].([]interface{}); ok {
+		n.enum = enum
+	}
+	if constant, ok := s["const"]; ok {
+		n.constant = &constant
+	}
+
+	n.minimum = number(s, "minimum")
+	n.maximum = number(s, "maximum")
+	n.multipleOf = number(s, "multipleOf")
+	// Draft 4 uses booleans that make minimum and maximum exclusive
+	switch exclusive := s["exclusiveMinimum"].(type) {
+	case float64:
+		n.exclusiveMinimum = &exclusive
+	case bool:
+		if exclusive {
+			n.exclusiveMinimum, n.minimum = n.minimum, nil
+		}
+	}
+	switch exclusive := s["exclusiveMaximum"].(type) {
+	case float64:
+		n.exclusiveMaximum = &exclusive
+	case bool:
+		if exclusive {
+			n.exclusiveMaximum, n.maximum = n.maximum, nil
+		}
+	}
+
+	n.minLength = integer(s, "minLength")
+	n.maxLength = integer(s, "maxLength")
+	if pattern, ok := s["pattern"].(string); ok {
+		if n.pattern, err = compilePattern(pattern); err != nil {
+			return fmt.Errorf("invalid pattern at %q: %w", pointer, err)
+		}
+	}
+
+	if n.prefixItems, err = subs("prefixItems"); err != nil {
+		return err
+	}
+	// Before draft 2020-12, an items array lists the item schemas by
+	// position and additionalItems applies to the rest
+	if _, ok := s["items"].([]interface{}); ok {
+		if n.prefixItems, err = subs("items"); err != nil {
+			return err
+		}
+		if n.items, err = sub("additionalItems"); err != nil {
+			return err
+		}
+	} else if n.items, err = sub("items"); err != nil {
+		return err
+	}
+	n.minItems = integer(s, "minItems")
+	n.maxItems = integer(s, "maxItems")
+	n.uniqueItems, _ = s["uniqueItems"].(bool)
+	if n.contains, err = sub("contains"); err != nil {
+		return err
+	}
+	n.minContains = integer(s, "minContains")
+	n.maxContains = integer(s, "maxContains")
+
+	if properties, ok := s["properties"].(map[string]interface{}); ok {
+		n.properties = make(map[string]*node, len(properties))
+		for name, value := range properties {
+			if n.properties[name], err = c.compile(value, pointer+"/properties/"+escape(name)); err != nil {
+				return err
+			}
+		}
+	}
+	if patterns, ok := s["patternProperties"].(map[string]interface{}); ok {
+		for _, expr := range sortedKeys(patterns) {
+			pattern, err := compilePattern(expr)
+			if err != nil {
+				return fmt.Errorf("invalid patternProperties at %q: %w", pointer, err)
+			}
+			child, err := c.compile(patterns[expr], pointer+"/patternProperties/"+escape(expr))
+			if err != nil {
+				return err
+			}
+			n.patternProperties = append(n.patternProperties, patternNode{pattern: pattern, node: child})
+		}
+	}
+	if n.additionalProperties, err = sub("additionalProperties"); err != nil {
+		return err
+	}
+	if required, ok := s["required"].([]interface{}); ok {
+		for _, item := range required {
+			if name, ok := item.(string); ok {
+				n.required = append(n.required, name)
+			}
+		}
+	}
+	n.minProperties = integer(s, "minProperties")
+	n.maxProperties = integer(s, "maxProperties")
+	if n.propertyNames, err = sub("propertyNames"); err != nil {
+		return err
+	}
+	if err := c.compileDependencies(n, s, pointer); err != nil {
+		return err
+	}
+
+	if n.allOf, err = subs("allOf"); err != nil {
+		return err
+	}
+	if n.anyOf, err = subs("anyOf"); err != nil {
+		return err
+	}
+	if n.oneOf, err = subs("oneOf"); err != nil {
+		return err
+	}
+	if n.not, err = sub("not"); err != nil {
+		return err
+	}
+	if n.when, err = sub("if"); err != nil {
+		return err
+	}
+	if n.then, err = sub("then"); err != nil {
+		return err
+	}
+	if n.other, err = sub("else"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// compileDependencies compiles dependentRequired and dependentSchemas, and
+// dependencies, which holds both before draft 2019-09.
+func (c *compiler) compileDependencies(n *node, s map[string]interface{}, pointer string) error {
+	for _, keyword := range []string{"dependencies", "dependentRequired", "dependentSchemas"} {
+		dependencies, ok := s[keyword].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, name := range sortedKeys(dependencies) {
+			switch dependency := dependencies[name].(type) {
+			case []interface{}:
+				if keyword == "dependentSchemas" {
+					return fmt.Errorf("%s of %q at %q must be a schema", keyword, name, pointer)
+				}
+				if n.dependentRequired == nil {
+					n.dependentRequired = make(map[string][]string)
+				}
+				for _, item := range dependency {
+					required, ok := item.(string)
+					if !ok {
+						return fmt.Errorf("%s of %q at %q must be an array of strings", keyword, name, pointer)
+					}
+					n.dependentRequired[name] = append(n.dependentRequired[name], required)
+				}
+			default:
+				if keyword == "dependentRequired" {
+					return fmt.Errorf("%s of %q at %q must be an array of strings", keyword, name, pointer)
+				}
+				child, err := c.compile(dependency, pointer+"/"+keyword+"/"+escape(name))
+				if err != nil {
+					return err
+				}
+				if n.dependentSchemas == nil {
+					n.dependentSchemas = make(map[string]*node)
+				}
+				n.dependentSchemas[name] = child
+			}
+		}
+	}
+	return nil
+}
+
+// resolve returns the subschema a $ref points to within the document.
+func (c *compiler) resolve(ref string) (interface{}, error) {
+	return ResolveRef(c.document, ref)
+}
+
+// ResolveRef returns the value a $ref points to within document. Only
+// references within the document are supported: "#" and JSON pointers such
+// as "#/$defs/address", which may be percent-encoded.
+func ResolveRef(document interface{}, ref string) (interface{}, error) {
+	fragment, ok := strings.CutPrefix(ref, "#")
+	if !ok || (fragment != "" && !strings.HasPrefix(fragment, "/")) {
+		return nil, fmt.Errorf("unsupported $ref %q: only references within the schema are supported", ref)
+	}
+	fragment, err := url.PathUnescape(fragment)
+	if err != nil {
+		return nil, fmt.Errorf("invalid $ref %q: %w", ref, err)
+	}
+	if fragment == "" {
+		return document, nil
+	}
+	value := document
+	for _, token := range strings.Split(fragment, "/")[1:] {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		switch v := value.(type) {
+		case map[string]interface{}:
+			child, ok := v[token]
+			if !ok {
+				return nil, fmt.Errorf("unresolvable $ref %q", ref)
+			}
+			value = child
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, fmt.Errorf("unresolvable $ref %q", ref)
+			}
+			value = v[i]
+		default:
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+	}
+	return value, nil
+}
+
+func (n *node) validate(value interface{}, pointer string) *Violation {
+	fail := func(format string, args ...interface{}) *Violation {
+		return &Violation{Pointer: pointer, Message: fmt.Sprintf(format, args...)}
+	}
+
+	if n.boolean != nil {
+		if !*n.boolean {
+			return fail("no value is allowed")
+		}
+		return nil
+	}
+	if n.ref != nil {
+		if v := n.ref.validate(value, pointer); v != nil {
+			return v
+		}
+	}
+
+	if len(n.types) > 0 && !matchesType(n.types, value) {
+		return fail("expected %s, got %s", strings.Join(n.types, " or "), typeOf(value))
+	}
+	if n.constant != nil && !equal(*n.constant, value) {
+		return fail("must be %s", encode(*n.constant))
+	}
+	if n.enum != nil && !contains(n.enum, value) {
+		return fail("must be one of %s", encode(n.enum))
+	}
+
+	switch v := value.(type) {
+	case float64:
+		if violation := n.validateNumber(v, fail); violation != nil {
+			return violation
+		}
+	case string:
+		length := utf8.RuneCountInString(v)
+		if n.minLength != nil && length < *n.minLength {
+			return fail("must be at least %d characters long", *n.minLength)
+		}
+		if n.maxLength != nil && length > *n.maxLength {
+			return fail("must be at most %d characters long", *n.maxLength)
+		}
+		if n.pattern != nil && !n.pattern.MatchString(v) {
+			return fail("must match pattern %q", n.pattern.String())
+		}
+	case []interface{}:
+		if violation := n.validateArray(v, pointer, fail); violation != nil {
+			return violation
+		}
+	case map[string]interface{}:
+		if violation := n.validateObject(v, pointer, fail); violation != nil {
+			return violation
+		}
+	}
+
+	for _, child := range n.allOf {
+		if v := child.validate(value, pointer); v != nil {
+			return v
+		}
+	}
+	if len(n.anyOf) > 0 {
+		matched := false
+		for _, child := range n.anyOf {
+			if child.validate(value, pointer) == nil {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fail("must match at least one schema of anyOf")
+		}
+	}
+	if len(n.oneOf) > 0 {
+		matches := 0
+		for _, child := range n.oneOf {
+			if child.validate(value, pointer) == nil {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return fail("must match exactly one schema of oneOf, matched %d", matches)
+		}
+	}
+	if n.not != nil && n.not.validate(value, pointer) == nil {
+		return fail("must not match the schema of not")
+	}
+	if n.when != nil {
+		branch := n.other
+		if n.when.validate(value, pointer) == nil {
+			branch = n.then
+		}
+		if branch != nil {
+			if v := branch.validate(value, pointer); v != nil {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+func (n *node) validateNumber(v float64, fail func(string, ...interface{}) *Violation) *Violation {
+	if n.minimum != nil && v < *n.minimum {
+		return fail("must be >= %v", *n.minimum)
+	}
+	if n.maximum != nil && v > *n.maximum {
+		return fail("must be <= %v", *n.maximum)
+	}
+	if n.exclusiveMinimum != nil && v <= *n.exclusiveMinimum {
+		return fail("must be > %v", *n.exclusiveMinimum)
+	}
+	if n.exclusiveMaximum != nil && v >= *n.exclusiveMaximum {
+		return fail("must be < %v", *n.exclusiveMaximum)
+	}
+	if n.multipleOf != nil && *n.multipleOf > 0 {
+		quotient := v / *n.multipleOf
+		if math.Abs(quotient-math.Round(quotient)) > 1e-9 {
+			return fail("must be a multiple of %v", *n.multipleOf)
+		}
+	}
+	return nil
+}
+
+func (n *node) validateArray(items []interface{}, pointer string, fail func(string, ...interface{}) *Violation) *Violation {
+	if n.minItems != nil && len(items) < *n.minItems {
+		return fail("must have at least %d items", *n.minItems)
+	}
+	if n.maxItems != nil && len(items) > *n.maxItems {
+		return fail("must have at most %d items", *n.maxItems)
+	}
+	if n.uniqueItems {
+		for i := range items {
+			for j := i + 1; j < len(items); j++ {
+				if equal(items[i], items[j]) {
+					return fail("items %d and %d must be unique", i, j)
+				}
+			}
+		}
+	}
+	for i, item := range items {
+		child := n.items
+		if i < len(n.prefixItems) {
+			child = n.prefixItems[i]
+		}
+		if child == nil {
+			continue
+		}
+		if v := child.validate(item, fmt.Sprintf("%s/%d", pointer, i)); v != nil {
+			return v
+		}
+	}
+	if n.contains != nil {
+		matches := 0
+		for _, item := range items {
+			if n.contains.validate(item, pointer) == nil {
+				matches++
+			}
+		}
+		minContains := 1
+		if n.minContains != nil {
+			minContains = *n.minContains
+		}
+		if matches < minContains {
+			return fail("must contain at least %d items matching the schema of contains, contains %d", minContains, matches)
+		}
+		if n.maxContains != nil && matches > *n.maxContains {
+			return fail("must contain at most %d items matching the schema of contains, contains %d", *n.maxContains, matches)
+		}
+	}
+	return nil
+}
+
+func (n *node) validateObject(object map[string]interface{}, pointer string, fail func(string, ...interface{}) *Violation) *Violation {
+	for _, name := range n.required {
+		if _, ok := object[name]; !ok {
+			return &Violation{Pointer: pointer + "/" + escape(name), Message: "required property is missing"}
+		}
+	}
+	if n.minProperties != nil && len(object) < *n.minProperties {
+		return fail("must have at least %d properties", *n.minProperties)
+	}
+	if n.maxProperties != nil && len(object) > *n.maxProperties {
+		return fail("must have at most %d properties", *n.maxProperties)
+	}
+
+	for _, name := range sortedKeys(object) {
+		value := object[name]
+		childPointer := pointer + "/" + escape(name)
+		for _, required := range n.dependentRequired[name] {
+			if _, ok := object[required]; !ok {
+				return &Violation{Pointer: pointer + "/" + escape(required), Message: fmt.Sprintf("required property is missing, since %q is present", name)}
+			}
+		}
+		if child, ok := n.dependentSchemas[name]; ok {
+			if v := child.validate(object, pointer); v != nil {
+				return v
+			}
+		}
+		if n.propertyNames != nil {
+			if v := n.propertyNames.validate(name, childPointer); v != nil {
+				return &Violation{Pointer: childPointer, Message: fmt.Sprintf("property name is not allowed: %s", v.Message)}
+			}
+		}
+		matched := false
+		if child, ok := n.properties[name]; ok {
+			matched = true
+			if v := child.validate(value, childPointer); v != nil {
+				return v
+			}
+		}
+		for _, pattern := range n.patternProperties {
+			if !pattern.pattern.MatchString(name) {
+				continue
+			}
+			matched = true
+			if v := pattern.node.validate(value, childPointer); v != nil {
+				return v
+			}
+		}
+		if !matched && n.additionalProperties != nil {
+			if n.additionalProperties.boolean != nil && !*n.additionalProperties.boolean {
+				return &Violation{Pointer: childPointer, Message: "additional property is not allowed"}
+			}
+			if v := n.additionalProperties.validate(value, childPointer); v != nil {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+// typeOf returns the JSON Schema type of a decoded JSON value.
+func typeOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func matchesType(types []string, value interface{}) bool {
+	actual := typeOf(value)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func equal(a, b interface{}) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+func contains(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if equal(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func encode(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+func number(s map[string]interface{}, key string) *float64 {
+	if v, ok := s[key].(float64); ok {
+		return &v
+	}
+	return nil
+}
+
+func integer(s map[string]interface{}, key string) *int {
+	if v, ok := s[key].(float64); ok {
+		i := int(v)
+		return &i
+	}
+	return nil
+}
+
+// compilePattern compiles the ECMA-262 regular expression of a pattern or
+// patternProperties keyword with RE2. Syntax that RE2 lacks is rejected
+// rather than compiled into an expression that matches differently.
+func compilePattern(expr string) (*regexp.Regexp, error) {
+	unsupported := func(what string) (*regexp.Regexp, error) {
+		return nil, fmt.Errorf("unsupported regular expression %q: %s are not supported (patterns are compiled with RE2)", expr, what)
+	}
+
+	var b strings.Builder
+	inClass := false
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case c == '\\' && i+1 < len(expr):
+			next := expr[i+1]
+			switch {
+			case next == 'u' && i+6 <= len(expr) && isHex(expr[i+2:i+6]):
+				b.WriteString(`\x{` + expr[i+2:i+6] + `}`)
+				i += 5
+				continue
+			case !inClass && next >= '1' && next <= '9':
+				return unsupported("backreferences")
+			case !inClass && next == 'k' && strings.HasPrefix(expr[i+2:], "<"):
+				return unsupported("named backreferences")
+			}
+			b.WriteByte(c)
+			b.WriteByte(next)
+			i++
+			continue
+		case c == '[' && !inClass:
+			inClass = true
+		case c == ']' && inClass:
+			inClass = false
+		case c == '(' && !inClass:
+			for _, lookaround := range []string{"(?=", "(?!", "(?<=", "(?<!"} {
+				if strings.HasPrefix(expr[i:], lookaround) {
+					return unsupported("lookaround assertions")
+				}
+			}
+		}
+		b.WriteByte(c)
+	}
+	return regexp.Compile(b.String())
+}
+
+// isHex reports whether s is a non-empty string of hexadecimal digits.
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// escape escapes a JSON pointer token.
+func escape(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
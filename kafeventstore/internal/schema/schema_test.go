@@ -0,0 +1,240 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const orderSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"required": ["id", "items"],
+	"properties": {
+		"id": {"type": "string", "pattern": "^ord-[0-9]+$"},
+		"status": {"enum": ["new", "paid"]},
+		"items": {
+			"type": "array",
+			"minItems": 1,
+			"items": {"$ref": "#/$defs/item"}
+		},
+		"notes": {"type": ["string", "null"], "maxLength": 5}
+	},
+	"additionalProperties": false,
+	"$defs": {
+		"item": {
+			"type": "object",
+			"required": ["sku", "price"],
+			"properties": {
+				"sku": {"type": "string", "minLength": 1},
+				"price": {"type": "number", "exclusiveMinimum": 0},
+				"quantity": {"type": "integer", "minimum": 1, "maximum": 100}
+			}
+		}
+	}
+}`
+
+func decode(t *testing.T, document string) interface{} {
+	t.Helper()
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(document), &value); err != nil {
+		t.Fatalf("failed to decode %s: %v", document, err)
+	}
+	return value
+}
+
+func TestSchema_Validate(t *testing.T) {
+	schema, err := Compile([]byte(orderSchema))
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		document string
+		pointer  string
+		valid    bool
+	}{
+		{name: "valid", document: `{"id": "ord-1", "status": "paid", "items": [{"sku": "a", "price": 1.5, "quantity": 2}], "notes": null}`, valid: true},
+		{name: "not an object", document: `[]`, pointer: ""},
+		{name: "missing required", document: `{"id": "ord-1"}`, pointer: "/items"},
+		{name: "pattern", document: `{"id": "order-1", "items": [{"sku": "a", "price": 1}]}`, pointer: "/id"},
+		{name: "enum", document: `{"id": "ord-1", "status": "lost", "items": [{"sku": "a", "price": 1}]}`, pointer: "/status"},
+		{name: "min items", document: `{"id": "ord-1", "items": []}`, pointer: "/items"},
+		{name: "referenced item", document: `{"id": "ord-1", "items": [{"sku": "a", "price": 1}, {"sku": "b", "price": 0}]}`, pointer: "/items/1/price"},
+		{name: "integer", document: `{"id": "ord-1", "items": [{"sku": "a", "price": 1, "quantity": 1.5}]}`, pointer: "/items/0/quantity"},
+		{name: "maximum", document: `{"id": "ord-1", "items": [{"sku": "a", "price": 1, "quantity": 101}]}`, pointer: "/items/0/quantity"},
+		{name: "type list", document: `{"id": "ord-1", "items": [{"sku": "a", "price": 1}], "notes": 3}`, pointer: "/notes"},
+		{name: "max length", document: `{"id": "ord-1", "items": [{"sku": "a", "price": 1}], "notes": "too long"}`, pointer: "/notes"},
+		{name: "additional property", document: `{"id": "ord-1", "items": [{"sku": "a", "price": 1}], "extra/key": 1}`, pointer: "/extra~1key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violation := schema.Validate(decode(t, tt.document))
+			if tt.valid {
+				if violation != nil {
+					t.Errorf("Validate() = %v, want nil", violation)
+				}
+				return
+			}
+			if violation == nil {
+				t.Fatal("Validate() = nil, want violation")
+			}
+			if violation.Pointer != tt.pointer {
+				t.Errorf("Validate() pointer = %q, want %q (%v)", violation.Pointer, tt.pointer, violation)
+			}
+		})
+	}
+}
+
+func TestSchema_Combinators(t *testing.T) {
+	tests := []struct {
+		name     string
+		schema   string
+		document string
+		valid    bool
+	}{
+		{"allOf", `{"allOf": [{"type": "number"}, {"minimum": 2}]}`, `1`, false},
+		{"anyOf", `{"anyOf": [{"type": "string"}, {"type": "number"}]}`, `1`, true},
+		{"anyOf none", `{"anyOf": [{"type": "string"}, {"type": "boolean"}]}`, `1`, false},
+		{"oneOf", `{"oneOf": [{"type": "number"}, {"type": "integer"}]}`, `1`, false},
+		{"not", `{"not": {"type": "null"}}`, `null`, false},
+		{"if then", `{"if": {"properties": {"kind": {"const": "a"}}}, "then": {"required": ["a"]}, "else": {"required": ["b"]}}`, `{"kind": "a", "b": 1}`, false},
+		{"if else", `{"if": {"properties": {"kind": {"const": "a"}}}, "then": {"required": ["a"]}, "else": {"required": ["b"]}}`, `{"kind": "x", "b": 1}`, true},
+		{"false schema", `false`, `1`, false},
+		{"true schema", `true`, `1`, true},
+		{"const", `{"const": {"a": [1, 2]}}`, `{"a": [1, 2]}`, true},
+		{"unique items", `{"uniqueItems": true}`, `[1, "1", 1]`, false},
+		{"multiple of", `{"multipleOf": 0.1}`, `0.3`, true},
+		{"pattern properties", `{"patternProperties": {"^x-": {"type": "string"}}, "additionalProperties": false}`, `{"x-a": 1}`, false},
+		{"draft 4 exclusive", `{"minimum": 1, "exclusiveMinimum": true}`, `1`, false},
+		{"draft 4 items", `{"items": [{"type": "string"}], "additionalItems": false}`, `["a", 1]`, false},
+		{"recursive", `{"type": "object", "properties": {"child": {"$ref": "#"}}, "required": ["name"]}`, `{"name": "a", "child": {"child": {}}}`, false},
+		{"contains", `{"contains": {"type": "string"}}`, `[1, 2]`, false},
+		{"contains match", `{"contains": {"type": "string"}}`, `[1, "a"]`, true},
+		{"max contains", `{"contains": {"type": "string"}, "maxContains": 1}`, `["a", "b"]`, false},
+		{"min contains zero", `{"contains": {"type": "string"}, "minContains": 0}`, `[]`, true},
+		{"property names", `{"propertyNames": {"pattern": "^[a-z]+$"}}`, `{"Bad": 1}`, false},
+		{"dependent required", `{"dependentRequired": {"card": ["billing"]}}`, `{"card": 1}`, false},
+		{"dependent required absent", `{"dependentRequired": {"card": ["billing"]}}`, `{"cash": 1}`, true},
+		{"dependent schemas", `{"dependentSchemas": {"card": {"required": ["billing"]}}}`, `{"card": 1}`, false},
+		{"draft 7 dependencies", `{"dependencies": {"card": ["billing"], "cash": {"maxProperties": 1}}}`, `{"cash": 1, "tip": 2}`, false},
+		{"root id", `{"$id": "https://example.com/order.json", "properties": {"a": {"$ref": "#/$defs/a"}}, "$defs": {"a": {"type": "string"}}}`, `{"a": 1}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := Compile([]byte(tt.schema))
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			violation := schema.Validate(decode(t, tt.document))
+			if (violation == nil) != tt.valid {
+				t.Errorf("Validate() = %v, want valid %v", violation, tt.valid)
+			}
+		})
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+	}{
+		{"invalid JSON", `{`},
+		{"not a schema", `1`},
+		{"invalid pattern", `{"pattern": "("}`},
+		{"remote ref", `{"$ref": "https://example.com/other.json"}`},
+		{"unresolvable ref", `{"$ref": "#/$defs/missing"}`},
+		{"unevaluated properties", `{"properties": {"a": true}, "unevaluatedProperties": false}`},
+		{"nested unevaluated items", `{"items": {"unevaluatedItems": false}}`},
+		{"dynamic ref", `{"$dynamicRef": "#node"}`},
+		{"nested id", `{"$defs": {"a": {"$id": "a.json"}}, "$ref": "#/$defs/a"}`},
+		{"anchor ref", `{"$defs": {"a": {"$anchor": "a"}}, "$ref": "#a"}`},
+		{"invalid dependent required", `{"dependentRequired": {"a": {"type": "string"}}}`},
+		{"lookahead", `{"pattern": "^(?=.*[0-9]).{8,}$"}`},
+		{"negative lookbehind", `{"patternProperties": {"(?<!x)y": true}}`},
+		{"backreference", `{"pattern": "^(a)\\1$"}`},
+		{"named backreference", `{"pattern": "^(?<a>x)\\k<a>$"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Compile([]byte(tt.schema)); err == nil {
+				t.Error("Compile() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestCompilePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		match   string
+		noMatch string
+	}{
+		{pattern: `^caf\u00e9$`, match: "café", noMatch: "cafe"},
+		{pattern: `^[(?=]+$`, match: "(?=", noMatch: "a"},
+		{pattern: `^(?<year>[0-9]{4})$`, match: "2026", noMatch: "26"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			re, err := compilePattern(tt.pattern)
+			if err != nil {
+				t.Fatalf("compilePattern() error = %v", err)
+			}
+			if !re.MatchString(tt.match) || re.MatchString(tt.noMatch) {
+				t.Errorf("compilePattern() = %s, want it to match %q but not %q", re, tt.match, tt.noMatch)
+			}
+		})
+	}
+}
+
+func TestViolation_Error(t *testing.T) {
+	if got := (&Violation{Message: "expected object, got array"}).Error(); got != "(root): expected object, got array" {
+		t.Errorf("Error() = %q", got)
+	}
+	if got := (&Violation{Pointer: "/id", Message: "required property is missing"}).Error(); got != "/id: required property is missing" {
+		t.Errorf("Error() = %q", got)
+	}
+}
+
+func TestResolveRef(t *testing.T) {
+	var document interface{}
+	if err := json.Unmarshal([]byte(`{"$defs": {"a/b": {"type": "string"}, "list": [{"type": "integer"}], "c d": {"type": "boolean"}}}`), &document); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{ref: "#/$defs/a~1b", want: "string"},
+		{ref: "#/$defs/list/0", want: "integer"},
+		{ref: "#/$defs/c%20d", want: "boolean"},
+		{ref: "#/$defs/missing", wantErr: true},
+		{ref: "#/$defs/list/1", wantErr: true},
+		{ref: "#defs", wantErr: true},
+		{ref: "https://schemas.example.com/orders.json", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, err := ResolveRef(document, tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveRef() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				if typ := got.(map[string]interface{})["type"]; typ != tt.want {
+					t.Errorf("ResolveRef() type = %v, want %s", typ, tt.want)
+				}
+			}
+		})
+	}
+	if got, err := ResolveRef(document, "#"); err != nil || got == nil {
+		t.Errorf("ResolveRef(#) = %v, %v, want the document", got, err)
+	}
+}
@@ -0,0 +1,108 @@
+// Package storage implements atomic file commits for the filesystem writer.
+package storage
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// tempFilePrefix hides in-progress files from Spark and Hive readers,
+	// which skip names starting with "." or "_".
+	tempFilePrefix = "."
+
+	// tempFileSuffix marks files that have not been committed yet.
+	tempFileSuffix = ".tmp"
+)
+
+// tempFileName returns the in-progress name of a file in the same directory.
+func tempFileName(name string) string {
+	return tempFilePrefix + name + tempFileSuffix
+}
+
+// isTempFileName reports whether name is an in-progress file name.
+func isTempFileName(name string) bool {
+	return strings.HasPrefix(name, tempFilePrefix) && strings.HasSuffix(name, tempFileSuffix)
+}
+
+// commitFile makes a fully written temp file visible under finalPath.
+// The file contents are synced before the rename and the directory entry
+// after it, so readers either see the complete file or no file at all.
+func commitFile(tempPath, finalPath string) error {
+	if err := syncFile(tempPath); err != nil {
+		return err
+	}
+	if err := os.Rename(tempPath, finalPath); err != nil {
+		return fmt.Errorf("failed to rename file: %w", err)
+	}
+	return syncDir(filepath.Dir(finalPath))
+}
+
+// writeFileAtomic writes data to a temp file next to path and commits it.
+func writeFileAtomic(path string, data []byte) error {
+	tempPath := filepath.Join(filepath.Dir(path), tempFileName(filepath.Base(path)))
+	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := commitFile(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	return nil
+}
+
+// syncFile flushes a file's contents to stable storage.
+func syncFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open file for sync: %w", err)
+	}
+	defer file.Close()
+
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync file: %w", err)
+	}
+	return nil
+}
+
+// syncDir flushes a directory so that renames within it survive a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open directory for sync: %w", err)
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync directory: %w", err)
+	}
+	return nil
+}
+
+// removeOrphanedTempFiles deletes in-progress files left under root by a
+// previous process that stopped before committing them.
+// It returns the paths that were removed.
+func removeOrphanedTempFiles(root string) ([]string, error) {
+	var removed []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isTempFileName(d.Name()) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove temp file %s: %w", path, err)
+		}
+		removed = append(removed, path)
+		return nil
+	})
+	if err != nil {
+		return removed, fmt.Errorf("failed to clean up temp files: %w", err)
+	}
+	return removed, nil
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsTempFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{name: tempFileName("events_20251218_100000_001.parquet"), want: true},
+		{name: tempFileName(SuccessMarker), want: true},
+		{name: "events_20251218_100000_001.parquet", want: false},
+		{name: ManifestName("events_20251218_100000_001.parquet"), want: false},
+		{name: ".hidden", want: false},
+		{name: "data.tmp", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTempFileName(tt.name); got != tt.want {
+				t.Errorf("isTempFileName(%q) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.json")
+
+	if err := writeFileAtomic(path, []byte(`{"a":1}`)); err != nil {
+		t.Fatalf("writeFileAtomic() error = %v", err)
+	}
+	if err := writeFileAtomic(path, []byte(`{"a":2}`)); err != nil {
+		t.Fatalf("writeFileAtomic() overwrite error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(data) != `{"a":2}` {
+		t.Errorf("file contents = %s, want {\"a\":2}", data)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("expected only the committed file, got %d entries", len(entries))
+	}
+}
+
+func TestCommitFile_MissingTempFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := commitFile(filepath.Join(dir, tempFileName("missing")), filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected error for missing temp file")
+	}
+}
+
+func TestRemoveOrphanedTempFiles(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "topic", "v1", "dt=2025-12-18", "pid=1")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]bool{
+		filepath.Join(root, tempFileName("a.avro")):      true,
+		filepath.Join(nested, tempFileName("b.parquet")): true,
+		filepath.Join(nested, "b.parquet"):               false,
+		filepath.Join(nested, ManifestName("b.parquet")): false,
+		filepath.Join(nested, SuccessMarker):             false,
+	}
+	for path := range files {
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := removeOrphanedTempFiles(root)
+	if err != nil {
+		t.Fatalf("removeOrphanedTempFiles() error = %v", err)
+	}
+	if len(removed) != 2 {
+		t.Errorf("removed %d files, want 2: %v", len(removed), removed)
+	}
+
+	for path, orphan := range files {
+		_, err := os.Stat(path)
+		if orphan && !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed", path)
+		}
+		if !orphan && err != nil {
+			t.Errorf("expected %s to be kept: %v", path, err)
+		}
+	}
+}
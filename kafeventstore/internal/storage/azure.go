@@ -4,6 +4,7 @@ package storage
 import (
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
@@ -11,31 +12,140 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
 
 	"github.com/jittakal/kafeventstore/internal/encoder"
+	"github.com/jittakal/kafeventstore/internal/envelope"
 	"github.com/jittakal/kafeventstore/pkg/event"
 	"github.com/jittakal/kafeventstore/pkg/storage"
 )
 
 // Ensure implementation satisfies interface at compile time.
-var _ storage.Writer = (*AzureWriter)(nil)
+var (
+	_ storage.Writer       = (*AzureWriter)(nil)
+	_ storage.MarkerWriter = (*AzureWriter)(nil)
+	_ storage.MarkerReader = (*AzureWriter)(nil)
+
+	_ storage.ConditionalMarkerStore = (*AzureWriter)(nil)
+)
+
+// Azure authentication methods.
+const (
+	// AzureAuthSharedKey authenticates with the storage account key.
+	AzureAuthSharedKey = "shared_key"
+	// AzureAuthSAS authenticates with a shared access signature token.
+	AzureAuthSAS = "sas"
+	// AzureAuthDefaultCredential authenticates with DefaultAzureCredential,
+	// which covers workload identity, managed identity, service principal
+	// environment variables and the Azure CLI.
+	AzureAuthDefaultCredential = "default_credential"
+)
 
 // AzureConfig contains Azure Blob Storage configuration.
 type AzureConfig struct {
 	AccountName   string
 	AccountKey    string
+	SASToken      string
 	ContainerName string
-	Endpoint      string
+	// AuthMethod selects how to authenticate. When empty, SAS is used if a
+	// token is set, then the account key, then DefaultAzureCredential.
+	AuthMethod string
+	// Endpoint is the blob service URL, e.g. http://127.0.0.1:10000/devstoreaccount1
+	// for Azurite. Defaults to https://<account>.blob.core.windows.net/.
+	Endpoint   string
+	Provenance encoder.Provenance
+	Metadata   ObjectMetadataConfig
+	// TypedSchemas adds typed data columns to Parquet files of one event type.
+	TypedSchemas *encoder.TypedSchemas
+	// Encryptor encrypts files before they are uploaded; nil uploads them as encoded.
+	Encryptor *envelope.Encryptor
+}
+
+// Validate validates Azure configuration.
+func (c AzureConfig) Validate() error {
+	if c.AccountName == "" && c.Endpoint == "" {
+		return fmt.Errorf("azure account name or endpoint is required")
+	}
+	if c.ContainerName == "" {
+		return fmt.Errorf("azure container name is required")
+	}
+
+	switch c.authMethod() {
+	case AzureAuthSharedKey:
+		if c.AccountName == "" || c.AccountKey == "" {
+			return fmt.Errorf("azure account name and key are required for %s auth", AzureAuthSharedKey)
+		}
+	case AzureAuthSAS:
+		if c.SASToken == "" {
+			return fmt.Errorf("azure SAS token is required for %s auth", AzureAuthSAS)
+		}
+	case AzureAuthDefaultCredential:
+	default:
+		return fmt.Errorf("unsupported azure auth method: %s", c.AuthMethod)
+	}
+	return nil
+}
+
+// authMethod returns the configured auth method or infers it from the
+// credentials that are set.
+func (c AzureConfig) authMethod() string {
+	switch {
+	case c.AuthMethod != "":
+		return c.AuthMethod
+	case c.SASToken != "":
+		return AzureAuthSAS
+	case c.AccountKey != "":
+		return AzureAuthSharedKey
+	default:
+		return AzureAuthDefaultCredential
+	}
+}
+
+// serviceURL returns the blob service URL with a trailing slash.
+func (c AzureConfig) serviceURL() string {
+	if c.Endpoint == "" {
+		return fmt.Sprintf("https://%s.blob.core.windows.net/", c.AccountName)
+	}
+	return strings.TrimSuffix(c.Endpoint, "/") + "/"
+}
+
+// newAzureClient creates a blob client for the configured auth method.
+func newAzureClient(cfg AzureConfig) (*azblob.Client, error) {
+	serviceURL := cfg.serviceURL()
+
+	switch cfg.authMethod() {
+	case AzureAuthSharedKey:
+		cred, err := azblob.NewSharedKeyCredential(cfg.AccountName, cfg.AccountKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create shared key credential: %w", err)
+		}
+		return azblob.NewClientWithSharedKeyCredential(serviceURL, cred, nil)
+	case AzureAuthSAS:
+		return azblob.NewClientWithNoCredential(serviceURL+"?"+strings.TrimPrefix(cfg.SASToken, "?"), nil)
+	default:
+		cred, err := azidentity.NewDefaultAzureCredential(nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create default Azure credential: %w", err)
+		}
+		return azblob.NewClient(serviceURL, cred, nil)
+	}
 }
 
 // AzureWriter implements storage.Writer for Azure Blob Storage.
-// It supports both managed identity and access key authentication,
-// with automatic blob creation and hierarchical path organization.
+// It supports DefaultAzureCredential (managed and workload identity), SAS token
+// and account key authentication, with automatic blob creation and
+// hierarchical path organization.
 type AzureWriter struct {
 	client         *azblob.Client
 	containerName  string
+	objectMetadata ObjectMetadataConfig
 	encoderFactory *encoder.Factory
+	encryptor      *envelope.Encryptor
 	logger         *slog.Logger
 	metrics        MetricsCollector
 	mu             sync.RWMutex
@@ -49,24 +159,23 @@ func NewAzureWriter(
 	logger *slog.Logger,
 	metrics MetricsCollector,
 ) (*AzureWriter, error) {
-	// Build connection string
-	var connectionString string
-	if cfg.Endpoint != "" {
-		connectionString = fmt.Sprintf("DefaultEndpointsProtocol=https;AccountName=%s;AccountKey=%s;BlobEndpoint=%s",
-			cfg.AccountName, cfg.AccountKey, cfg.Endpoint)
-	} else {
-		connectionString = fmt.Sprintf("DefaultEndpointsProtocol=https;AccountName=%s;AccountKey=%s;EndpointSuffix=core.windows.net",
-			cfg.AccountName, cfg.AccountKey)
+	if err := cfg.Metadata.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid object metadata config: %w", err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid Azure config: %w", err)
 	}
 
 	// Create Azure client
-	client, err := azblob.NewClientFromConnectionString(connectionString, nil)
+	client, err := newAzureClient(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Azure client: %w", err)
 	}
 
 	// Create encoder factory
-	encoderFactory := encoder.NewFactory(format, compression)
+	encoderFactory := encoder.NewFactory(format, compression,
+		encoder.WithProvenance(cfg.Provenance), encoder.WithTypedSchemas(cfg.TypedSchemas))
 
 	// Validate encoder can be created
 	if _, err := encoderFactory.CreateEncoder(); err != nil {
@@ -76,14 +185,19 @@ func NewAzureWriter(
 	logger.Info("Azure writer created",
 		"container", cfg.ContainerName,
 		"account", cfg.AccountName,
+		"endpoint", cfg.serviceURL(),
+		"auth_method", cfg.authMethod(),
 		"format", format,
 		"compression", compression,
+		"encrypted", cfg.Encryptor != nil,
 	)
 
 	return &AzureWriter{
 		client:         client,
 		containerName:  cfg.ContainerName,
+		objectMetadata: cfg.Metadata,
 		encoderFactory: encoderFactory,
+		encryptor:      cfg.Encryptor,
 		logger:         logger,
 		metrics:        metrics,
 	}, nil
@@ -110,24 +224,16 @@ func (w *AzureWriter) Write(ctx context.Context, records []event.Record, path st
 	}
 
 	// Parse Azure URI to extract blob path
-	blobPath := path
-	if strings.HasPrefix(path, "wasbs://") {
-		// Remove wasbs:// prefix and container
-		pathWithoutProtocol := strings.TrimPrefix(path, "wasbs://")
-		parts := strings.SplitN(pathWithoutProtocol, "/", 2)
-		if len(parts) == 2 {
-			blobPath = parts[1]
-		} else {
-			blobPath = ""
-		}
-	}
+	blobDir := objectPrefix(path, "wasbs")
 
 	// Generate timestamped filename: events_YYYYMMDD_HHMMSS_NNN.{ext}
 	now := time.Now()
 	timestamp := now.Format("20060102_150405")
 	filename := fmt.Sprintf("events_%s_%03d%s", timestamp, now.Nanosecond()/1000000, enc.FileExtension())
-	blobPath = blobPath + filename
-	blobPath = strings.TrimPrefix(blobPath, "/")
+	if w.encryptor != nil {
+		filename += envelope.FileSuffix
+	}
+	blobPath := objectKey(blobDir, filename)
 
 	// Encode to temporary file
 	tempDir := os.TempDir()
@@ -142,6 +248,18 @@ func (w *AzureWriter) Write(ctx context.Context, records []event.Record, path st
 	}
 	defer os.Remove(tempFile)
 
+	// Encrypt the encoded file before it leaves the process
+	var env *envelope.Envelope
+	if w.encryptor != nil {
+		env, err = encryptFile(ctx, w.encryptor, tempFile, stats)
+		if err != nil {
+			if w.metrics != nil {
+				w.metrics.IncStorageErrors("azure", "encrypt")
+			}
+			return 0, err
+		}
+	}
+
 	// Open the file for upload
 	file, err := os.Open(tempFile)
 	if err != nil {
@@ -152,8 +270,20 @@ func (w *AzureWriter) Write(ctx context.Context, records []event.Record, path st
 	}
 	defer file.Close()
 
-	// Upload to Azure Blob
-	_, err = w.client.UploadFile(ctx, w.containerName, blobPath, file, nil)
+	// Build the manifest first so its offsets can be attached to the upload
+	manifest, manifestErr := newFileManifest(tempFile, filename, records, stats, format, enc.SchemaVersion())
+	if manifest != nil {
+		manifest.Encryption = env
+	}
+
+	// Upload to Azure Blob with content type, metadata, index tags and Content-MD5
+	blobContentType := encryptedContentType(format, env)
+	headers := &blob.HTTPHeaders{BlobContentType: &blobContentType}
+	if stats.Checksums != nil {
+		headers.BlobContentMD5 = stats.Checksums.MD5
+	}
+	err = w.uploadBlob(ctx, blobPath, file, stats, headers,
+		azureMetadata(withEnvelope(w.objectMetadata.objectMetadata(manifest), env)), w.objectMetadata.objectTags(manifest))
 	if err != nil {
 		if w.metrics != nil {
 			w.metrics.IncStorageErrors("azure", "upload")
@@ -161,6 +291,21 @@ func (w *AzureWriter) Write(ctx context.Context, records []event.Record, path st
 		return 0, fmt.Errorf("failed to upload to Azure Blob: %w", err)
 	}
 
+	// Upload sidecar manifest; failures are logged but do not fail the write
+	var manifestData []byte
+	if manifestErr == nil {
+		manifestData, manifestErr = manifest.Marshal()
+	}
+	if manifestErr == nil {
+		manifestErr = w.putBlob(ctx, objectKey(blobDir, ManifestName(filename)), manifestData)
+	}
+	if manifestErr != nil {
+		if w.metrics != nil {
+			w.metrics.IncStorageErrors("azure", "manifest")
+		}
+		w.logger.Error("failed to write manifest", "container", w.containerName, "blob", blobPath, "error", manifestErr)
+	}
+
 	duration := time.Since(startTime)
 
 	w.logger.Info("wrote records to Azure Blob",
@@ -184,6 +329,151 @@ func (w *AzureWriter) Write(ctx context.Context, records []event.Record, path st
 	return stats.SizeBytes, nil
 }
 
+// WriteMarker writes a small marker blob under the given path.
+func (w *AzureWriter) WriteMarker(ctx context.Context, path string, name string, data []byte) error {
+	blobPath := objectKey(objectPrefix(path, "wasbs"), name)
+	if err := w.putBlob(ctx, blobPath, data); err != nil {
+		if w.metrics != nil {
+			w.metrics.IncStorageErrors("azure", "marker")
+		}
+		return err
+	}
+
+	w.logger.Debug("wrote marker blob", "container", w.containerName, "blob", blobPath)
+	return nil
+}
+
+// ReadMarker reads a marker blob under the given path.
+func (w *AzureWriter) ReadMarker(ctx context.Context, path string, name string) ([]byte, error) {
+	blobPath := objectKey(objectPrefix(path, "wasbs"), name)
+	response, err := w.client.DownloadStream(ctx, w.containerName, blobPath, nil)
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return nil, storage.ErrMarkerNotFound
+	}
+	if err != nil {
+		if w.metrics != nil {
+			w.metrics.IncStorageErrors("azure", "read_marker")
+		}
+		return nil, fmt.Errorf("failed to download Azure blob %s: %w", blobPath, err)
+	}
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Azure blob %s: %w", blobPath, err)
+	}
+	return data, nil
+}
+
+// ReadMarkerVersion reads a marker blob with its ETag as its version.
+func (w *AzureWriter) ReadMarkerVersion(ctx context.Context, path string, name string) ([]byte, string, error) {
+	blobPath := objectKey(objectPrefix(path, "wasbs"), name)
+	response, err := w.client.DownloadStream(ctx, w.containerName, blobPath, nil)
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return nil, "", storage.ErrMarkerNotFound
+	}
+	if err != nil {
+		if w.metrics != nil {
+			w.metrics.IncStorageErrors("azure", "read_marker")
+		}
+		return nil, "", fmt.Errorf("failed to download Azure blob %s: %w", blobPath, err)
+	}
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read Azure blob %s: %w", blobPath, err)
+	}
+	var version string
+	if response.ETag != nil {
+		version = string(*response.ETag)
+	}
+	return data, version, nil
+}
+
+// WriteMarkerIf writes a marker blob with an If-Match condition on its ETag,
+// or If-None-Match for a new blob.
+func (w *AzureWriter) WriteMarkerIf(ctx context.Context, path string, name string, data []byte, version string) (string, error) {
+	blobPath := objectKey(objectPrefix(path, "wasbs"), name)
+	conditions := &blob.ModifiedAccessConditions{}
+	if version == "" {
+		etag := azcore.ETagAny
+		conditions.IfNoneMatch = &etag
+	} else {
+		etag := azcore.ETag(version)
+		conditions.IfMatch = &etag
+	}
+
+	response, err := w.client.UploadBuffer(ctx, w.containerName, blobPath, data, &azblob.UploadBufferOptions{
+		AccessConditions: &blob.AccessConditions{ModifiedAccessConditions: conditions},
+	})
+	if bloberror.HasCode(err, bloberror.ConditionNotMet, bloberror.BlobAlreadyExists) {
+		return "", storage.ErrMarkerConflict
+	}
+	if err != nil {
+		if w.metrics != nil {
+			w.metrics.IncStorageErrors("azure", "marker")
+		}
+		return "", fmt.Errorf("failed to upload Azure blob %s: %w", blobPath, err)
+	}
+	if response.ETag == nil {
+		return "", nil
+	}
+	return string(*response.ETag), nil
+}
+
+// uploadBlob uploads an encoded file as a block blob.
+// Files that fit in a single Put Blob request are sent with a transactional
+// Content-MD5 so the service rejects corrupted uploads. Larger files are
+// uploaded in blocks with the MD5 stored as the blob Content-MD5 property.
+func (w *AzureWriter) uploadBlob(
+	ctx context.Context,
+	blobPath string,
+	file *os.File,
+	stats *event.FileStats,
+	headers *blob.HTTPHeaders,
+	metadata map[string]*string,
+	tags map[string]string,
+) error {
+	if stats.Checksums != nil && stats.SizeBytes <= blockblob.MaxUploadBlobBytes {
+		blockBlobClient := w.client.ServiceClient().NewContainerClient(w.containerName).NewBlockBlobClient(blobPath)
+		_, err := blockBlobClient.Upload(ctx, file, &blockblob.UploadOptions{
+			HTTPHeaders:             headers,
+			Metadata:                metadata,
+			Tags:                    tags,
+			TransactionalValidation: blob.TransferValidationTypeMD5(stats.Checksums.MD5),
+		})
+		return err
+	}
+
+	_, err := w.client.UploadFile(ctx, w.containerName, blobPath, file, &azblob.UploadFileOptions{
+		HTTPHeaders: headers,
+		Metadata:    metadata,
+		Tags:        tags,
+	})
+	return err
+}
+
+// putBlob uploads a small in-memory blob to the container.
+func (w *AzureWriter) putBlob(ctx context.Context, blobPath string, data []byte) error {
+	if _, err := w.client.UploadBuffer(ctx, w.containerName, blobPath, data, nil); err != nil {
+		return fmt.Errorf("failed to upload Azure blob %s: %w", blobPath, err)
+	}
+	return nil
+}
+
+// azureMetadata converts metadata to the pointer map used by the Azure SDK.
+func azureMetadata(metadata map[string]string) map[string]*string {
+	if len(metadata) == 0 {
+		return nil
+	}
+	result := make(map[string]*string, len(metadata))
+	for key, value := range metadata {
+		result[key] = &value
+	}
+	return result
+}
+
 // Close closes the Azure writer.
 func (w *AzureWriter) Close() error {
 	w.logger.Info("Azure writer closed")
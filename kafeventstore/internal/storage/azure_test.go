@@ -3,6 +3,8 @@ package storage
 import (
 	"context"
 	"errors"
+	"log/slog"
+	"os"
 	"testing"
 	"time"
 
@@ -86,6 +88,145 @@ func validateAzureConfig(config AzureConfig) error {
 	return nil
 }
 
+func TestAzureConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  AzureConfig
+		wantErr bool
+	}{
+		{
+			name:    "shared key",
+			config:  AzureConfig{AccountName: "testaccount", AccountKey: "dGVzdGtleQ==", ContainerName: "events"},
+			wantErr: false,
+		},
+		{
+			name:    "SAS token",
+			config:  AzureConfig{AccountName: "testaccount", SASToken: "sv=2020-08-04&sig=abc", ContainerName: "events"},
+			wantErr: false,
+		},
+		{
+			name:    "default credential",
+			config:  AzureConfig{AccountName: "testaccount", ContainerName: "events"},
+			wantErr: false,
+		},
+		{
+			name:    "endpoint without account name",
+			config:  AzureConfig{Endpoint: "https://custom.example.com", SASToken: "sig=abc", ContainerName: "events"},
+			wantErr: false,
+		},
+		{
+			name:    "missing account name and endpoint",
+			config:  AzureConfig{ContainerName: "events"},
+			wantErr: true,
+		},
+		{
+			name:    "missing container",
+			config:  AzureConfig{AccountName: "testaccount"},
+			wantErr: true,
+		},
+		{
+			name:    "shared key without key",
+			config:  AzureConfig{AccountName: "testaccount", ContainerName: "events", AuthMethod: AzureAuthSharedKey},
+			wantErr: true,
+		},
+		{
+			name:    "SAS without token",
+			config:  AzureConfig{AccountName: "testaccount", ContainerName: "events", AuthMethod: AzureAuthSAS},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported auth method",
+			config:  AzureConfig{AccountName: "testaccount", ContainerName: "events", AuthMethod: "password"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAzureConfig_AuthMethod(t *testing.T) {
+	tests := []struct {
+		name   string
+		config AzureConfig
+		want   string
+	}{
+		{"explicit method wins", AzureConfig{AuthMethod: AzureAuthDefaultCredential, AccountKey: "key"}, AzureAuthDefaultCredential},
+		{"SAS token", AzureConfig{SASToken: "sig=abc", AccountKey: "key"}, AzureAuthSAS},
+		{"account key", AzureConfig{AccountKey: "key"}, AzureAuthSharedKey},
+		{"no credentials", AzureConfig{}, AzureAuthDefaultCredential},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.authMethod(); got != tt.want {
+				t.Errorf("authMethod() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAzureConfig_ServiceURL(t *testing.T) {
+	tests := []struct {
+		name   string
+		config AzureConfig
+		want   string
+	}{
+		{"public cloud", AzureConfig{AccountName: "testaccount"}, "https://testaccount.blob.core.windows.net/"},
+		{"azurite", AzureConfig{AccountName: "devstoreaccount1", Endpoint: "http://127.0.0.1:10000/devstoreaccount1"}, "http://127.0.0.1:10000/devstoreaccount1/"},
+		{"trailing slash", AzureConfig{Endpoint: "https://custom.example.com/"}, "https://custom.example.com/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.serviceURL(); got != tt.want {
+				t.Errorf("serviceURL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewAzureWriter_AuthMethods(t *testing.T) {
+	base := AzureConfig{
+		AccountName:   "devstoreaccount1",
+		ContainerName: "events",
+		Endpoint:      "http://127.0.0.1:10000/devstoreaccount1",
+	}
+
+	shared := base
+	shared.AccountKey = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+	sas := base
+	sas.SASToken = "?sv=2020-08-04&ss=b&srt=sco&sp=rwdlac&sig=signature"
+
+	tests := []struct {
+		name   string
+		config AzureConfig
+		want   string
+	}{
+		{"shared key", shared, "http://127.0.0.1:10000/devstoreaccount1/"},
+		{"SAS token", sas, "http://127.0.0.1:10000/devstoreaccount1/?sv=2020-08-04&ss=b&srt=sco&sp=rwdlac&sig=signature"},
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer, err := NewAzureWriter(tt.config, event.FormatParquet, "snappy", logger, nil)
+			if err != nil {
+				t.Fatalf("NewAzureWriter() error = %v", err)
+			}
+			if got := writer.client.URL(); got != tt.want {
+				t.Errorf("client URL = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestAzurePath_Construction(t *testing.T) {
 	tests := []struct {
 		name      string
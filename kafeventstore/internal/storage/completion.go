@@ -0,0 +1,184 @@
+// Package storage implements partition completion tracking.
+package storage
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jittakal/kafeventstore/pkg/event"
+)
+
+// CompletionStateName is the name of the marker, under the completion path of
+// a partition, that persists its completion state across restarts.
+const CompletionStateName = "state.json"
+
+// MinIdleTimeout is the shortest time a partition must go without events
+// before AdvanceIdle treats it as idle, so backfills are not cut short.
+const MinIdleTimeout = 5 * time.Minute
+
+// CompletionState is the persisted completion state of a partition: its
+// watermark and the paths whose window has not closed yet.
+type CompletionState struct {
+	Watermark time.Time            `json:"watermark"`
+	Pending   map[string]time.Time `json:"pending"`
+}
+
+// CompletionTracker tracks event-time watermarks per Kafka partition and reports
+// storage paths whose partition window has closed.
+// A window is complete once the partition watermark, minus the allowed lateness,
+// reaches the window end. Callers should only ask for completed paths after all
+// buffered records of the partition have been flushed.
+type CompletionTracker struct {
+	allowedLateness time.Duration
+	watermarks      map[event.PartitionID]time.Time
+	pending         map[event.PartitionID]map[string]time.Time
+	lastSeen        map[event.PartitionID]time.Time
+	mu              sync.Mutex
+}
+
+// NewCompletionTracker creates a new completion tracker.
+func NewCompletionTracker(allowedLateness time.Duration) *CompletionTracker {
+	return &CompletionTracker{
+		allowedLateness: allowedLateness,
+		watermarks:      make(map[event.PartitionID]time.Time),
+		pending:         make(map[event.PartitionID]map[string]time.Time),
+		lastSeen:        make(map[event.PartitionID]time.Time),
+	}
+}
+
+// Observe advances the watermark of a partition to eventTime if it is later
+// than the current watermark.
+func (t *CompletionTracker) Observe(partitionID event.PartitionID, eventTime time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.lastSeen[partitionID] = time.Now()
+	if eventTime.After(t.watermarks[partitionID]) {
+		t.watermarks[partitionID] = eventTime
+	}
+}
+
+// AdvanceIdle advances the watermark of every partition with pending paths
+// that has not observed an event for the allowed lateness, or MinIdleTimeout
+// if longer, to now, so the windows of partitions that went idle still close.
+func (t *CompletionTracker) AdvanceIdle(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	idleTimeout := max(t.allowedLateness, MinIdleTimeout)
+
+	for partitionID := range t.pending {
+		lastSeen, seen := t.lastSeen[partitionID]
+		if !seen {
+			// Restored partitions are idle from the time they were restored
+			t.lastSeen[partitionID] = now
+			continue
+		}
+		if now.Sub(lastSeen) >= idleTimeout && now.After(t.watermarks[partitionID]) {
+			t.watermarks[partitionID] = now
+		}
+	}
+}
+
+// Partitions returns the partitions with pending paths.
+func (t *CompletionTracker) Partitions() []event.PartitionID {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	partitions := make([]event.PartitionID, 0, len(t.pending))
+	for partitionID := range t.pending {
+		partitions = append(partitions, partitionID)
+	}
+	sort.Slice(partitions, func(i, j int) bool {
+		if partitions[i].Topic != partitions[j].Topic {
+			return partitions[i].Topic < partitions[j].Topic
+		}
+		return partitions[i].Partition < partitions[j].Partition
+	})
+	return partitions
+}
+
+// State returns a copy of the completion state of a partition.
+func (t *CompletionTracker) State(partitionID event.PartitionID) CompletionState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pending := make(map[string]time.Time, len(t.pending[partitionID]))
+	for path, windowEnd := range t.pending[partitionID] {
+		pending[path] = windowEnd
+	}
+	return CompletionState{Watermark: t.watermarks[partitionID], Pending: pending}
+}
+
+// Restore merges a persisted completion state of a partition: the watermark
+// advances to the persisted one and its pending paths are tracked again.
+func (t *CompletionTracker) Restore(partitionID event.PartitionID, state CompletionState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if state.Watermark.After(t.watermarks[partitionID]) {
+		t.watermarks[partitionID] = state.Watermark
+	}
+	if len(state.Pending) == 0 {
+		return
+	}
+	paths, exists := t.pending[partitionID]
+	if !exists {
+		paths = make(map[string]time.Time, len(state.Pending))
+		t.pending[partitionID] = paths
+	}
+	for path, windowEnd := range state.Pending {
+		if _, tracked := paths[path]; !tracked {
+			paths[path] = windowEnd
+		}
+	}
+}
+
+// Watermark returns the current watermark of a partition.
+func (t *CompletionTracker) Watermark(partitionID event.PartitionID) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.watermarks[partitionID]
+}
+
+// Track registers a written storage path whose window closes at windowEnd.
+func (t *CompletionTracker) Track(partitionID event.PartitionID, path string, windowEnd time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	paths, exists := t.pending[partitionID]
+	if !exists {
+		paths = make(map[string]time.Time)
+		t.pending[partitionID] = paths
+	}
+	paths[path] = windowEnd
+}
+
+// Complete returns the tracked paths of a partition whose window has closed
+// and stops tracking them. Paths are returned in lexical order.
+func (t *CompletionTracker) Complete(partitionID event.PartitionID) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	paths := t.pending[partitionID]
+	if len(paths) == 0 {
+		return nil
+	}
+
+	cutoff := t.watermarks[partitionID].Add(-t.allowedLateness)
+	var completed []string
+	for path, windowEnd := range paths {
+		if !cutoff.Before(windowEnd) {
+			completed = append(completed, path)
+			delete(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		delete(t.pending, partitionID)
+	}
+
+	sort.Strings(completed)
+	return completed
+}
@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jittakal/kafeventstore/pkg/event"
+)
+
+func TestCompletionTracker_Complete(t *testing.T) {
+	pid := event.PartitionID{Topic: "orders", Partition: 0}
+	day1End := time.Date(2025, 12, 19, 0, 0, 0, 0, time.UTC)
+	day2End := day1End.AddDate(0, 0, 1)
+
+	tracker := NewCompletionTracker(10 * time.Minute)
+	tracker.Track(pid, "s3://b/orders/v1/dt=2025-12-18/pid=0/", day1End)
+	tracker.Track(pid, "s3://b/orders/v1/dt=2025-12-19/pid=0/", day2End)
+
+	// Watermark before the end of day 1
+	tracker.Observe(pid, day1End.Add(-time.Hour))
+	if got := tracker.Complete(pid); len(got) != 0 {
+		t.Fatalf("Complete() = %v, want none", got)
+	}
+
+	// Past day 1 but within allowed lateness
+	tracker.Observe(pid, day1End.Add(5*time.Minute))
+	if got := tracker.Complete(pid); len(got) != 0 {
+		t.Fatalf("Complete() within lateness = %v, want none", got)
+	}
+
+	// Past day 1 plus allowed lateness
+	tracker.Observe(pid, day1End.Add(10*time.Minute))
+	got := tracker.Complete(pid)
+	if len(got) != 1 || got[0] != "s3://b/orders/v1/dt=2025-12-18/pid=0/" {
+		t.Fatalf("Complete() = %v, want day 1 path", got)
+	}
+
+	// Completed paths are reported once
+	if got := tracker.Complete(pid); len(got) != 0 {
+		t.Errorf("Complete() second call = %v, want none", got)
+	}
+}
+
+func TestCompletionTracker_WatermarkIsMonotonic(t *testing.T) {
+	pid := event.PartitionID{Topic: "orders", Partition: 1}
+	now := time.Now()
+
+	tracker := NewCompletionTracker(0)
+	tracker.Observe(pid, now)
+	tracker.Observe(pid, now.Add(-time.Hour))
+
+	if got := tracker.Watermark(pid); !got.Equal(now) {
+		t.Errorf("Watermark() = %v, want %v", got, now)
+	}
+}
+
+func TestCompletionTracker_PartitionsAreIndependent(t *testing.T) {
+	p0 := event.PartitionID{Topic: "orders", Partition: 0}
+	p1 := event.PartitionID{Topic: "orders", Partition: 1}
+	windowEnd := time.Date(2025, 12, 19, 0, 0, 0, 0, time.UTC)
+
+	tracker := NewCompletionTracker(0)
+	tracker.Track(p0, "p0", windowEnd)
+	tracker.Track(p1, "p1", windowEnd)
+	tracker.Observe(p0, windowEnd)
+
+	if got := tracker.Complete(p1); len(got) != 0 {
+		t.Errorf("Complete(p1) = %v, want none", got)
+	}
+	if got := tracker.Complete(p0); len(got) != 1 {
+		t.Errorf("Complete(p0) = %v, want one path", got)
+	}
+}
+
+func TestCompletionTracker_AdvanceIdle(t *testing.T) {
+	active := event.PartitionID{Topic: "orders", Partition: 0}
+	idle := event.PartitionID{Topic: "orders", Partition: 1}
+	windowEnd := time.Date(2025, 12, 19, 0, 0, 0, 0, time.UTC)
+
+	tracker := NewCompletionTracker(10 * time.Minute)
+	for _, pid := range []event.PartitionID{active, idle} {
+		tracker.Observe(pid, windowEnd.Add(-time.Hour))
+		tracker.Track(pid, "path", windowEnd)
+	}
+
+	// Partitions that saw events recently are not idle
+	now := time.Now()
+	tracker.AdvanceIdle(now)
+	if got := tracker.Complete(idle); len(got) != 0 {
+		t.Fatalf("Complete() of a recently active partition = %v, want none", got)
+	}
+
+	// Partitions without events for the idle timeout advance to now
+	tracker.Observe(active, windowEnd.Add(-time.Minute))
+	tracker.mu.Lock()
+	tracker.lastSeen[idle] = now.Add(-MinIdleTimeout - 10*time.Minute)
+	tracker.mu.Unlock()
+	tracker.AdvanceIdle(now)
+
+	if got := tracker.Complete(idle); len(got) != 1 {
+		t.Errorf("Complete() of an idle partition = %v, want its path", got)
+	}
+	if got := tracker.Complete(active); len(got) != 0 {
+		t.Errorf("Complete() of an active partition = %v, want none", got)
+	}
+}
+
+func TestCompletionTracker_StateAndRestore(t *testing.T) {
+	pid := event.PartitionID{Topic: "orders", Partition: 0}
+	day1End := time.Date(2025, 12, 19, 0, 0, 0, 0, time.UTC)
+	day2End := day1End.AddDate(0, 0, 1)
+
+	tracker := NewCompletionTracker(0)
+	tracker.Observe(pid, day1End.Add(-time.Hour))
+	tracker.Track(pid, "dt=2025-12-18/", day1End)
+
+	state := tracker.State(pid)
+	if !state.Watermark.Equal(day1End.Add(-time.Hour)) || len(state.Pending) != 1 {
+		t.Fatalf("State() = %+v", state)
+	}
+
+	// The state is a copy
+	state.Pending["other"] = day2End
+	if got := tracker.State(pid); len(got.Pending) != 1 {
+		t.Errorf("State() shares its pending paths with the tracker")
+	}
+
+	// A new tracker picks up the pending paths and the watermark
+	restored := NewCompletionTracker(0)
+	restored.Track(pid, "dt=2025-12-19/", day2End)
+	restored.Restore(pid, tracker.State(pid))
+	if got := restored.Partitions(); len(got) != 1 || got[0] != pid {
+		t.Errorf("Partitions() = %v, want %v", got, pid)
+	}
+	if got := restored.Watermark(pid); !got.Equal(day1End.Add(-time.Hour)) {
+		t.Errorf("Watermark() = %v, want the persisted watermark", got)
+	}
+
+	restored.Observe(pid, day1End)
+	if got := restored.Complete(pid); len(got) != 1 || got[0] != "dt=2025-12-18/" {
+		t.Errorf("Complete() = %v, want the restored path", got)
+	}
+	if got := restored.State(pid).Pending; len(got) != 1 {
+		t.Errorf("pending after Complete() = %v, want the day 2 path", got)
+	}
+
+	// Restored partitions become idle once nothing is observed after the restore
+	idle := event.PartitionID{Topic: "orders", Partition: 2}
+	restored.Restore(idle, CompletionState{Pending: map[string]time.Time{"p": day1End}})
+	restored.AdvanceIdle(time.Now())
+	if got := restored.Complete(idle); len(got) != 0 {
+		t.Errorf("Complete() right after restore = %v, want none", got)
+	}
+	restored.AdvanceIdle(time.Now().Add(MinIdleTimeout + time.Minute))
+	if got := restored.Complete(idle); len(got) != 1 {
+		t.Errorf("Complete() after the idle timeout = %v, want the restored path", got)
+	}
+}
@@ -0,0 +1,91 @@
+// Package storage implements client-side encryption of encoded files.
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"maps"
+	"os"
+
+	"github.com/jittakal/kafeventstore/internal/envelope"
+	"github.com/jittakal/kafeventstore/pkg/event"
+)
+
+// encryptFile encrypts an encoded file in place with a new data key and
+// returns its envelope. The size and checksums in stats are replaced with
+// those of the encrypted file, which is what is uploaded and verified.
+func encryptFile(ctx context.Context, encryptor *envelope.Encryptor, path string, stats *event.FileStats) (*envelope.Envelope, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open encoded file: %w", err)
+	}
+	defer src.Close()
+
+	// The temp suffix lets orphaned files be removed like other temp files
+	encryptedPath := path + envelope.FileSuffix + tempFileSuffix
+	dst, err := os.Create(encryptedPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create encrypted file: %w", err)
+	}
+
+	crc, md5Hash, sha256Hash := crc32.New(crc32.MakeTable(crc32.Castagnoli)), md5.New(), sha256.New()
+	counter := &countingWriter{}
+	env, err := encryptor.Encrypt(ctx, io.MultiWriter(dst, crc, md5Hash, sha256Hash, counter), src)
+	if closeErr := dst.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close encrypted file: %w", closeErr)
+	}
+	if err == nil {
+		err = os.Rename(encryptedPath, path)
+	}
+	if err != nil {
+		os.Remove(encryptedPath)
+		return nil, fmt.Errorf("failed to encrypt file: %w", err)
+	}
+
+	if stats != nil {
+		stats.SizeBytes = counter.n
+		stats.Checksums = &event.Checksums{
+			CRC32C: crc.Sum32(),
+			MD5:    md5Hash.Sum(nil),
+			SHA256: sha256Hash.Sum(nil),
+		}
+	}
+	return env, nil
+}
+
+// withEnvelope returns object metadata with the envelope of an encrypted
+// file added. The envelope is attached even when object metadata is disabled,
+// since the file cannot be decrypted without it.
+func withEnvelope(metadata map[string]string, env *envelope.Envelope) map[string]string {
+	if env == nil {
+		return metadata
+	}
+	merged := make(map[string]string, len(metadata)+3)
+	maps.Copy(merged, metadata)
+	maps.Copy(merged, env.Metadata())
+	return merged
+}
+
+// countingWriter counts the bytes written to it.
+type countingWriter struct {
+	n int64
+}
+
+// Write implements io.Writer.
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+// encryptedContentType returns the content type of a data file, which is
+// opaque once encrypted.
+func encryptedContentType(format event.FileFormat, env *envelope.Envelope) string {
+	if env != nil {
+		return "application/octet-stream"
+	}
+	return contentType(format)
+}
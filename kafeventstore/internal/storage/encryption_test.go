@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jittakal/kafeventstore/internal/envelope"
+	"github.com/jittakal/kafeventstore/pkg/event"
+)
+
+// newTestKeyProvider returns a keyfile provider with a KEK in a temp file.
+func newTestKeyProvider(t *testing.T) *envelope.KeyfileProvider {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "kek")
+	if err := os.WriteFile(path, []byte(strings.Repeat("ab", envelope.KeySize)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	provider, err := envelope.NewKeyfileProvider(path)
+	if err != nil {
+		t.Fatalf("NewKeyfileProvider() error = %v", err)
+	}
+	return provider
+}
+
+// decryptFile returns the plaintext of an encrypted file.
+func decryptFile(t *testing.T, provider envelope.KeyProvider, env *envelope.Envelope, path string) []byte {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var plaintext bytes.Buffer
+	if err := envelope.NewDecryptor(provider).Decrypt(context.Background(), env, &plaintext, file); err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	return plaintext.Bytes()
+}
+
+func TestEncryptFile(t *testing.T) {
+	provider := newTestKeyProvider(t)
+	path := filepath.Join(t.TempDir(), "events.parquet")
+	plaintext := []byte(strings.Repeat("encoded records ", 10000))
+	if err := os.WriteFile(path, plaintext, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := &event.FileStats{RecordCount: 2, SizeBytes: int64(len(plaintext))}
+	env, err := encryptFile(context.Background(), envelope.NewEncryptor(provider), path, stats)
+	if err != nil {
+		t.Fatalf("encryptFile() error = %v", err)
+	}
+	if env.KeyID != provider.KeyID() {
+		t.Errorf("key ID = %s, want %s", env.KeyID, provider.KeyID())
+	}
+
+	ciphertext, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(ciphertext, []byte("encoded records")) {
+		t.Error("encrypted file contains plaintext")
+	}
+
+	// Stats describe the encrypted file
+	if stats.SizeBytes != int64(len(ciphertext)) {
+		t.Errorf("SizeBytes = %d, want %d", stats.SizeBytes, len(ciphertext))
+	}
+	wantMD5 := md5.Sum(ciphertext)
+	wantSHA256 := sha256.Sum256(ciphertext)
+	if stats.Checksums == nil ||
+		stats.Checksums.CRC32C != crc32.Checksum(ciphertext, crc32.MakeTable(crc32.Castagnoli)) ||
+		!bytes.Equal(stats.Checksums.MD5, wantMD5[:]) ||
+		hex.EncodeToString(stats.Checksums.SHA256) != hex.EncodeToString(wantSHA256[:]) {
+		t.Errorf("checksums = %+v, want checksums of the encrypted file", stats.Checksums)
+	}
+	if stats.RecordCount != 2 {
+		t.Errorf("RecordCount = %d, want 2", stats.RecordCount)
+	}
+
+	if got := decryptFile(t, provider, env, path); !bytes.Equal(got, plaintext) {
+		t.Error("decrypted file differs from the encoded file")
+	}
+
+	// No intermediate file is left behind
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("directory has %d entries, want 1", len(entries))
+	}
+}
+
+func TestEncryptFile_MissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.parquet")
+	if _, err := encryptFile(context.Background(), envelope.NewEncryptor(newTestKeyProvider(t)), path, &event.FileStats{}); err == nil {
+		t.Error("encryptFile() of a missing file succeeded")
+	}
+}
+
+func TestWithEnvelope(t *testing.T) {
+	env := &envelope.Envelope{Algorithm: envelope.Algorithm, KeyID: "keyfile:01", WrappedKey: []byte{1}}
+
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		env      *envelope.Envelope
+		wantLen  int
+	}{
+		{name: "not encrypted", metadata: map[string]string{ObjectMetaTopic: "loans"}, env: nil, wantLen: 1},
+		{name: "metadata disabled", metadata: nil, env: env, wantLen: 3},
+		{name: "metadata enabled", metadata: map[string]string{ObjectMetaTopic: "loans"}, env: env, wantLen: 4},
+		{name: "envelope wins", metadata: map[string]string{envelope.MetaKeyID: "other"}, env: env, wantLen: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := withEnvelope(tt.metadata, tt.env)
+			if len(got) != tt.wantLen {
+				t.Errorf("withEnvelope() = %v, want %d entries", got, tt.wantLen)
+			}
+			if tt.env != nil && got[envelope.MetaKeyID] != tt.env.KeyID {
+				t.Errorf("key ID = %s, want %s", got[envelope.MetaKeyID], tt.env.KeyID)
+			}
+		})
+	}
+}
+
+func TestEncryptedContentType(t *testing.T) {
+	env := &envelope.Envelope{}
+	if got := encryptedContentType(event.FormatAvro, nil); got != "application/avro" {
+		t.Errorf("content type = %s, want application/avro", got)
+	}
+	if got := encryptedContentType(event.FormatAvro, env); got != "application/octet-stream" {
+		t.Errorf("encrypted content type = %s, want application/octet-stream", got)
+	}
+}
@@ -0,0 +1,350 @@
+// Package storage implements fan-out of batches to multiple storage sinks.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jittakal/kafeventstore/pkg/event"
+	"github.com/jittakal/kafeventstore/pkg/storage"
+)
+
+// Ensure implementation satisfies interface at compile time.
+var (
+	_ storage.Writer       = (*FanoutWriter)(nil)
+	_ storage.MarkerWriter = (*FanoutWriter)(nil)
+	_ storage.MarkerReader = (*FanoutWriter)(nil)
+
+	_ storage.ConditionalMarkerStore = (*FanoutWriter)(nil)
+)
+
+// Sink success policies.
+const (
+	// SinkRequired fails the batch when the sink cannot be written.
+	SinkRequired = "required"
+	// SinkBestEffort logs and counts sink failures without failing the batch.
+	SinkBestEffort = "best_effort"
+)
+
+// Retries of required sinks within a single Write.
+const (
+	// sinkWriteAttempts is how often a required sink is written before the
+	// batch fails.
+	sinkWriteAttempts = 3
+	// defaultSinkRetryBackoff is the delay before the first retry; it doubles
+	// per attempt.
+	defaultSinkRetryBackoff = time.Second
+)
+
+// SinkWriter is implemented by every storage backend writer used as a sink.
+type SinkWriter interface {
+	storage.Writer
+	storage.MarkerWriter
+	storage.MarkerReader
+	storage.ConditionalMarkerStore
+}
+
+// SinkMetricsCollector defines per-sink metrics operations.
+type SinkMetricsCollector interface {
+	IncSinkWrites(sink string, status string)
+	ObserveSinkWriteDuration(sink string, duration float64)
+}
+
+// Sink is a storage destination with its own writer, router and file format.
+type Sink struct {
+	Name   string
+	Writer SinkWriter
+	Router *DefaultRouter
+	Format event.FileFormat
+	// Policy is SinkRequired or SinkBestEffort; empty means SinkRequired.
+	Policy string
+}
+
+// FanoutWriter writes every batch to all configured sinks concurrently.
+// Paths passed to Write and WriteMarker are routed by the primary router and
+// rebased onto each sink's router prefix, so all sinks share the same
+// topic/version/dt/pid layout under their own bucket and base path.
+// Each sink encodes with its own format; the format argument of Write is ignored.
+//
+// A required sink that fails is retried on its own, so the sinks that already
+// have the batch do not get it twice. A batch that still fails is retried by
+// the caller on every sink, which duplicates its file in the sinks that
+// succeeded: sinks are written at least once.
+type FanoutWriter struct {
+	sinks        []Sink
+	primary      *DefaultRouter
+	logger       *slog.Logger
+	metrics      SinkMetricsCollector
+	retryBackoff time.Duration
+}
+
+// NewFanoutWriter creates a writer that fans batches out to sinks.
+// Paths are expected to be routed by primary.
+func NewFanoutWriter(
+	sinks []Sink,
+	primary *DefaultRouter,
+	logger *slog.Logger,
+	metrics SinkMetricsCollector,
+) (*FanoutWriter, error) {
+	if len(sinks) == 0 {
+		return nil, fmt.Errorf("at least one sink is required")
+	}
+	if primary == nil {
+		return nil, fmt.Errorf("primary router is required")
+	}
+	sinks = append([]Sink(nil), sinks...)
+
+	names := make(map[string]bool, len(sinks))
+	for i, sink := range sinks {
+		if sink.Name == "" {
+			return nil, fmt.Errorf("sink %d: name is required", i)
+		}
+		if names[sink.Name] {
+			return nil, fmt.Errorf("duplicate sink name: %s", sink.Name)
+		}
+		names[sink.Name] = true
+
+		if sink.Writer == nil || sink.Router == nil {
+			return nil, fmt.Errorf("sink %s: writer and router are required", sink.Name)
+		}
+		switch sink.Policy {
+		case "":
+			sinks[i].Policy = SinkRequired
+		case SinkRequired, SinkBestEffort:
+		default:
+			return nil, fmt.Errorf("sink %s: unsupported policy: %s", sink.Name, sink.Policy)
+		}
+	}
+
+	for _, sink := range sinks {
+		logger.Info("storage sink configured",
+			"sink", sink.Name,
+			"prefix", sink.Router.Prefix(),
+			"format", sink.Format,
+			"policy", sink.Policy,
+		)
+	}
+
+	return &FanoutWriter{
+		sinks:        sinks,
+		primary:      primary,
+		logger:       logger,
+		metrics:      metrics,
+		retryBackoff: defaultSinkRetryBackoff,
+	}, nil
+}
+
+// sinkResult is the outcome of writing a batch to one sink.
+type sinkResult struct {
+	bytes int64
+	err   error
+}
+
+// Write writes records to every sink and returns the total bytes written.
+// Required sinks that fail are retried, without rewriting the other sinks,
+// up to sinkWriteAttempts times. It fails if a required sink still fails or
+// if no sink succeeds.
+func (w *FanoutWriter) Write(
+	ctx context.Context,
+	records []event.Record,
+	path string,
+	_ event.FileFormat,
+) (int64, error) {
+	results := make([]sinkResult, len(w.sinks))
+	pending := make([]int, len(w.sinks))
+	for i := range w.sinks {
+		pending[i] = i
+	}
+
+	backoff := w.retryBackoff
+	for attempt := 1; ; attempt++ {
+		w.writeSinks(ctx, pending, records, path, results)
+
+		// Only failed required sinks are retried
+		var failed []int
+		for _, i := range pending {
+			if results[i].err != nil && w.sinks[i].Policy == SinkRequired {
+				failed = append(failed, i)
+			}
+		}
+		if len(failed) == 0 || attempt == sinkWriteAttempts {
+			break
+		}
+		pending = failed
+
+		for _, i := range failed {
+			w.logger.Warn("required sink write failed, retrying",
+				"sink", w.sinks[i].Name,
+				"path", w.sinkPath(w.sinks[i], path),
+				"attempt", attempt,
+				"retry_in", backoff,
+				"error", results[i].err,
+			)
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		backoff *= 2
+	}
+
+	var total int64
+	var succeeded int
+	var errs []error
+	for i, sink := range w.sinks {
+		result := results[i]
+		if result.err == nil {
+			total += result.bytes
+			succeeded++
+			continue
+		}
+
+		if sink.Policy == SinkBestEffort {
+			w.logger.Warn("best-effort sink write failed",
+				"sink", sink.Name,
+				"path", w.sinkPath(sink, path),
+				"error", result.err,
+			)
+			continue
+		}
+		errs = append(errs, fmt.Errorf("sink %s: %w", sink.Name, result.err))
+	}
+
+	if len(errs) > 0 {
+		return total, fmt.Errorf("failed to write to required sinks: %w", errors.Join(errs...))
+	}
+	if succeeded == 0 {
+		return 0, fmt.Errorf("failed to write to any sink")
+	}
+	return total, nil
+}
+
+// writeSinks writes records to the sinks at indexes concurrently and stores
+// the outcomes in results.
+func (w *FanoutWriter) writeSinks(ctx context.Context, indexes []int, records []event.Record, path string, results []sinkResult) {
+	var wg sync.WaitGroup
+	for _, i := range indexes {
+		wg.Add(1)
+		go func(i int, sink Sink) {
+			defer wg.Done()
+
+			startTime := time.Now()
+			bytes, err := sink.Writer.Write(ctx, records, w.sinkPath(sink, path), sink.Format)
+			results[i] = sinkResult{bytes: bytes, err: err}
+
+			if w.metrics != nil {
+				status := "success"
+				if err != nil {
+					status = "error"
+				}
+				w.metrics.IncSinkWrites(sink.Name, status)
+				w.metrics.ObserveSinkWriteDuration(sink.Name, time.Since(startTime).Seconds())
+			}
+		}(i, w.sinks[i])
+	}
+	wg.Wait()
+}
+
+// WriteMarker writes a marker to every sink.
+// Best-effort sink failures are logged; required sink failures are returned.
+func (w *FanoutWriter) WriteMarker(ctx context.Context, path string, name string, data []byte) error {
+	var errs []error
+	for _, sink := range w.sinks {
+		sinkPath := w.sinkPath(sink, path)
+		if err := sink.Writer.WriteMarker(ctx, sinkPath, name, data); err != nil {
+			if sink.Policy == SinkBestEffort {
+				w.logger.Warn("best-effort sink marker write failed",
+					"sink", sink.Name,
+					"path", sinkPath,
+					"error", err,
+				)
+				continue
+			}
+			errs = append(errs, fmt.Errorf("sink %s: %w", sink.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ReadMarker reads a marker from the first sink that has it, in sink order.
+func (w *FanoutWriter) ReadMarker(ctx context.Context, path string, name string) ([]byte, error) {
+	var errs []error
+	for _, sink := range w.sinks {
+		data, err := sink.Writer.ReadMarker(ctx, w.sinkPath(sink, path), name)
+		if err == nil {
+			return data, nil
+		}
+		if !errors.Is(err, storage.ErrMarkerNotFound) {
+			errs = append(errs, fmt.Errorf("sink %s: %w", sink.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return nil, storage.ErrMarkerNotFound
+}
+
+// ReadMarkerVersion reads a marker and its version from the first sink, which
+// holds the markers several processes update.
+func (w *FanoutWriter) ReadMarkerVersion(ctx context.Context, path string, name string) ([]byte, string, error) {
+	sink := w.sinks[0]
+	data, version, err := sink.Writer.ReadMarkerVersion(ctx, w.sinkPath(sink, path), name)
+	if err != nil && !errors.Is(err, storage.ErrMarkerNotFound) {
+		return nil, "", fmt.Errorf("sink %s: %w", sink.Name, err)
+	}
+	return data, version, err
+}
+
+// WriteMarkerIf writes a marker to the first sink if its version is still
+// version, then copies it to the other sinks like WriteMarker. The first
+// sink decides conflicts; the copies only mirror it.
+func (w *FanoutWriter) WriteMarkerIf(ctx context.Context, path string, name string, data []byte, version string) (string, error) {
+	sink := w.sinks[0]
+	written, err := sink.Writer.WriteMarkerIf(ctx, w.sinkPath(sink, path), name, data, version)
+	if err != nil {
+		return "", fmt.Errorf("sink %s: %w", sink.Name, err)
+	}
+
+	var errs []error
+	for _, sink := range w.sinks[1:] {
+		sinkPath := w.sinkPath(sink, path)
+		if err := sink.Writer.WriteMarker(ctx, sinkPath, name, data); err != nil {
+			if sink.Policy == SinkBestEffort {
+				w.logger.Warn("best-effort sink marker write failed",
+					"sink", sink.Name,
+					"path", sinkPath,
+					"error", err,
+				)
+				continue
+			}
+			errs = append(errs, fmt.Errorf("sink %s: %w", sink.Name, err))
+		}
+	}
+	return written, errors.Join(errs...)
+}
+
+// Close closes every sink writer.
+func (w *FanoutWriter) Close() error {
+	var errs []error
+	for _, sink := range w.sinks {
+		if err := sink.Writer.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", sink.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sinkPath rebases a path routed by the primary router onto a sink's prefix.
+func (w *FanoutWriter) sinkPath(sink Sink, path string) string {
+	return sink.Router.Prefix() + strings.TrimPrefix(path, w.primary.Prefix())
+}
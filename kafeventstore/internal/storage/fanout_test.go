@@ -0,0 +1,436 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jittakal/kafeventstore/pkg/event"
+	"github.com/jittakal/kafeventstore/pkg/storage"
+)
+
+// fakeSinkWriter records writes and markers for fan-out tests.
+type fakeSinkWriter struct {
+	mu       sync.Mutex
+	err      error
+	failures int // writes that fail before err applies
+	paths    []string
+	formats  []event.FileFormat
+	markers  []string
+	data     map[string][]byte // marker contents by path and name
+	versions map[string]int    // marker write counts by path and name
+	closed   bool
+}
+
+func (w *fakeSinkWriter) Write(ctx context.Context, records []event.Record, path string, format event.FileFormat) (int64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.failures > 0 {
+		w.failures--
+		return 0, errors.New("temporarily unavailable")
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.paths = append(w.paths, path)
+	w.formats = append(w.formats, format)
+	return 100, nil
+}
+
+func (w *fakeSinkWriter) WriteMarker(ctx context.Context, path string, name string, data []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	w.markers = append(w.markers, path+name)
+	if w.data == nil {
+		w.data = make(map[string][]byte)
+	}
+	w.data[path+name] = data
+	if w.versions == nil {
+		w.versions = make(map[string]int)
+	}
+	w.versions[path+name]++
+	return nil
+}
+
+func (w *fakeSinkWriter) ReadMarkerVersion(ctx context.Context, path string, name string) ([]byte, string, error) {
+	data, err := w.ReadMarker(ctx, path, name)
+	if err != nil {
+		return nil, "", err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return data, strconv.Itoa(w.versions[path+name]), nil
+}
+
+func (w *fakeSinkWriter) WriteMarkerIf(ctx context.Context, path string, name string, data []byte, version string) (string, error) {
+	w.mu.Lock()
+	current := ""
+	if _, ok := w.data[path+name]; ok {
+		current = strconv.Itoa(w.versions[path+name])
+	}
+	w.mu.Unlock()
+	if current != version {
+		return "", storage.ErrMarkerConflict
+	}
+	if err := w.WriteMarker(ctx, path, name, data); err != nil {
+		return "", err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return strconv.Itoa(w.versions[path+name]), nil
+}
+
+func (w *fakeSinkWriter) ReadMarker(ctx context.Context, path string, name string) ([]byte, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return nil, w.err
+	}
+	data, ok := w.data[path+name]
+	if !ok {
+		return nil, storage.ErrMarkerNotFound
+	}
+	return data, nil
+}
+
+func (w *fakeSinkWriter) Close() error {
+	w.closed = true
+	return nil
+}
+
+// mockSinkMetrics implements SinkMetricsCollector for testing.
+type mockSinkMetrics struct {
+	mu        sync.Mutex
+	writes    map[string]int
+	durations int
+}
+
+func (m *mockSinkMetrics) IncSinkWrites(sink string, status string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.writes == nil {
+		m.writes = make(map[string]int)
+	}
+	m.writes[sink+"/"+status]++
+}
+
+func (m *mockSinkMetrics) ObserveSinkWriteDuration(sink string, duration float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.durations++
+}
+
+func fanoutTestRecords() []event.Record {
+	return []event.Record{{
+		Event: &event.CloudEvent{SpecVersion: "1.0", ID: "1", Source: "src", Type: "t", Data: []byte(`{}`)},
+		Kafka: event.KafkaMetadata{Topic: "orders", Partition: 0, Offset: 1},
+	}}
+}
+
+func TestNewFanoutWriter_Validation(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	router := NewRouter("s3", "bucket", "", "v1")
+	writer := &fakeSinkWriter{}
+
+	tests := []struct {
+		name    string
+		sinks   []Sink
+		wantErr bool
+	}{
+		{name: "no sinks", sinks: nil, wantErr: true},
+		{name: "missing name", sinks: []Sink{{Writer: writer, Router: router}}, wantErr: true},
+		{
+			name:    "duplicate names",
+			sinks:   []Sink{{Name: "a", Writer: writer, Router: router}, {Name: "a", Writer: writer, Router: router}},
+			wantErr: true,
+		},
+		{name: "missing writer", sinks: []Sink{{Name: "a", Router: router}}, wantErr: true},
+		{name: "unknown policy", sinks: []Sink{{Name: "a", Writer: writer, Router: router, Policy: "sometimes"}}, wantErr: true},
+		{name: "valid", sinks: []Sink{{Name: "a", Writer: writer, Router: router}}, wantErr: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewFanoutWriter(tt.sinks, router, logger, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewFanoutWriter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFanoutWriter_Write(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	primary := NewRouter("s3", "events-prod", "raw", "v1")
+	mirror := NewRouter("gs", "events-mirror", "", "v1")
+	partitionID := event.PartitionID{Topic: "orders", Partition: 0}
+	path := primary.Route(partitionID, 1734566400, "1.0")
+
+	tests := []struct {
+		name          string
+		primaryErr    error
+		mirrorErr     error
+		mirrorPolicy  string
+		wantErr       bool
+		wantBytes     int64
+		wantDurations int
+	}{
+		{name: "all succeed", wantBytes: 200, wantDurations: 2},
+		{name: "best-effort sink fails", mirrorErr: errors.New("unavailable"), mirrorPolicy: SinkBestEffort, wantBytes: 100, wantDurations: 2},
+		// The failed required sink is retried alone
+		{name: "required sink fails", mirrorErr: errors.New("unavailable"), mirrorPolicy: SinkRequired, wantErr: true, wantDurations: 1 + sinkWriteAttempts},
+		{name: "only best-effort sink succeeds", primaryErr: errors.New("unavailable"), mirrorPolicy: SinkBestEffort, wantErr: true, wantDurations: 1 + sinkWriteAttempts},
+		{
+			name:          "all best-effort sinks fail",
+			primaryErr:    errors.New("unavailable"),
+			mirrorErr:     errors.New("unavailable"),
+			mirrorPolicy:  SinkBestEffort,
+			wantErr:       true,
+			wantDurations: 1 + sinkWriteAttempts,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primaryWriter := &fakeSinkWriter{err: tt.primaryErr}
+			mirrorWriter := &fakeSinkWriter{err: tt.mirrorErr}
+			metrics := &mockSinkMetrics{}
+
+			writer, err := NewFanoutWriter([]Sink{
+				{Name: "s3", Writer: primaryWriter, Router: primary, Format: event.FormatParquet},
+				{Name: "gcs", Writer: mirrorWriter, Router: mirror, Format: event.FormatAvro, Policy: tt.mirrorPolicy},
+			}, primary, logger, metrics)
+			if err != nil {
+				t.Fatalf("NewFanoutWriter() error = %v", err)
+			}
+			writer.retryBackoff = time.Millisecond
+
+			bytes, err := writer.Write(context.Background(), fanoutTestRecords(), path, event.FormatParquet)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Write() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && bytes != tt.wantBytes {
+				t.Errorf("Write() bytes = %d, want %d", bytes, tt.wantBytes)
+			}
+			if metrics.durations != tt.wantDurations {
+				t.Errorf("observed %d sink durations, want %d", metrics.durations, tt.wantDurations)
+			}
+
+			if tt.mirrorErr == nil {
+				want := "gs://events-mirror//orders/v10/dt=2024-12-19/pid=0/"
+				if len(mirrorWriter.paths) != 1 || mirrorWriter.paths[0] != want {
+					t.Errorf("mirror paths = %v, want [%s]", mirrorWriter.paths, want)
+				}
+				if mirrorWriter.formats[0] != event.FormatAvro {
+					t.Errorf("mirror format = %v, want avro", mirrorWriter.formats[0])
+				}
+				if metrics.writes["gcs/success"] != 1 {
+					t.Errorf("gcs success writes = %d, want 1", metrics.writes["gcs/success"])
+				}
+			} else {
+				// Every attempt of a required sink is counted
+				wantErrors := 1
+				if tt.mirrorPolicy == SinkRequired {
+					wantErrors = sinkWriteAttempts
+				}
+				if metrics.writes["gcs/error"] != wantErrors {
+					t.Errorf("gcs error writes = %d, want %d", metrics.writes["gcs/error"], wantErrors)
+				}
+			}
+		})
+	}
+}
+
+func TestFanoutWriter_WriteRetriesFailedSinks(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	primary := NewRouter("s3", "events-prod", "raw", "v1")
+	mirror := NewRouter("gs", "events-mirror", "", "v1")
+	path := primary.Route(event.PartitionID{Topic: "orders", Partition: 0}, 1734566400, "1.0")
+
+	primaryWriter := &fakeSinkWriter{}
+	mirrorWriter := &fakeSinkWriter{failures: sinkWriteAttempts - 1}
+	writer, err := NewFanoutWriter([]Sink{
+		{Name: "s3", Writer: primaryWriter, Router: primary, Format: event.FormatParquet},
+		{Name: "gcs", Writer: mirrorWriter, Router: mirror, Format: event.FormatParquet},
+	}, primary, logger, nil)
+	if err != nil {
+		t.Fatalf("NewFanoutWriter() error = %v", err)
+	}
+	writer.retryBackoff = time.Millisecond
+
+	bytes, err := writer.Write(context.Background(), fanoutTestRecords(), path, event.FormatParquet)
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if bytes != 200 {
+		t.Errorf("Write() bytes = %d, want 200", bytes)
+	}
+	// The sink that succeeded first is not written again
+	if len(primaryWriter.paths) != 1 || len(mirrorWriter.paths) != 1 {
+		t.Errorf("writes = %d primary, %d mirror, want 1 each", len(primaryWriter.paths), len(mirrorWriter.paths))
+	}
+}
+
+func TestFanoutWriter_WriteMarker(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	primary := NewRouter("s3", "events-prod", "raw", "v1")
+	mirror := NewRouter("file", "", "", "v1")
+	path := primary.Route(event.PartitionID{Topic: "orders", Partition: 2}, 1734566400, "")
+
+	primaryWriter := &fakeSinkWriter{}
+	mirrorWriter := &fakeSinkWriter{err: errors.New("disk full")}
+
+	writer, err := NewFanoutWriter([]Sink{
+		{Name: "s3", Writer: primaryWriter, Router: primary},
+		{Name: "file", Writer: mirrorWriter, Router: mirror, Policy: SinkBestEffort},
+	}, primary, logger, nil)
+	if err != nil {
+		t.Fatalf("NewFanoutWriter() error = %v", err)
+	}
+
+	if err := writer.WriteMarker(context.Background(), path, SuccessMarker, nil); err != nil {
+		t.Fatalf("WriteMarker() error = %v, want best-effort failure ignored", err)
+	}
+	if want := path + SuccessMarker; len(primaryWriter.markers) != 1 || primaryWriter.markers[0] != want {
+		t.Errorf("primary markers = %v, want [%s]", primaryWriter.markers, want)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+	if !primaryWriter.closed || !mirrorWriter.closed {
+		t.Error("expected all sink writers to be closed")
+	}
+}
+
+func TestFanoutWriter_ReadMarker(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	primary := NewRouter("s3", "events-prod", "raw", "v1")
+	mirror := NewRouter("file", "", "", "v1")
+	path := primary.Route(event.PartitionID{Topic: "orders", Partition: 2}, 1734566400, "")
+
+	primaryWriter := &fakeSinkWriter{}
+	mirrorWriter := &fakeSinkWriter{}
+	mirrorPath := mirror.Prefix() + strings.TrimPrefix(path, primary.Prefix())
+	if err := mirrorWriter.WriteMarker(context.Background(), mirrorPath, "history.json", []byte("mirror")); err != nil {
+		t.Fatal(err)
+	}
+
+	writer, err := NewFanoutWriter([]Sink{
+		{Name: "s3", Writer: primaryWriter, Router: primary},
+		{Name: "file", Writer: mirrorWriter, Router: mirror},
+	}, primary, logger, nil)
+	if err != nil {
+		t.Fatalf("NewFanoutWriter() error = %v", err)
+	}
+
+	// Sinks are read in order, falling back to the next when a marker is missing
+	data, err := writer.ReadMarker(context.Background(), path, "history.json")
+	if err != nil || string(data) != "mirror" {
+		t.Errorf("ReadMarker() = %q, %v, want mirror", data, err)
+	}
+
+	if err := writer.WriteMarker(context.Background(), path, "history.json", []byte("primary")); err != nil {
+		t.Fatal(err)
+	}
+	data, err = writer.ReadMarker(context.Background(), path, "history.json")
+	if err != nil || string(data) != "primary" {
+		t.Errorf("ReadMarker() = %q, %v, want primary", data, err)
+	}
+
+	if _, err := writer.ReadMarker(context.Background(), path, "missing.json"); !errors.Is(err, storage.ErrMarkerNotFound) {
+		t.Errorf("ReadMarker() error = %v, want ErrMarkerNotFound", err)
+	}
+
+	mirrorWriter.err = errors.New("disk failed")
+	if _, err := writer.ReadMarker(context.Background(), path, "missing.json"); err == nil || errors.Is(err, storage.ErrMarkerNotFound) {
+		t.Errorf("ReadMarker() error = %v, want sink error", err)
+	}
+}
+
+func TestFanoutWriter_WriteMarkerIf(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	primary := NewRouter("s3", "events-prod", "raw", "v1")
+	mirror := NewRouter("file", "", "", "v1")
+	path := primary.Route(event.PartitionID{Topic: "orders", Partition: 2}, 1734566400, "")
+
+	primaryWriter := &fakeSinkWriter{}
+	mirrorWriter := &fakeSinkWriter{}
+	writer, err := NewFanoutWriter([]Sink{
+		{Name: "s3", Writer: primaryWriter, Router: primary},
+		{Name: "file", Writer: mirrorWriter, Router: mirror, Policy: SinkBestEffort},
+	}, primary, logger, nil)
+	if err != nil {
+		t.Fatalf("NewFanoutWriter() error = %v", err)
+	}
+
+	version, err := writer.WriteMarkerIf(context.Background(), path, "history.json", []byte("v1"), "")
+	if err != nil {
+		t.Fatalf("WriteMarkerIf() error = %v", err)
+	}
+	if _, err := writer.WriteMarkerIf(context.Background(), path, "history.json", []byte("v1"), ""); !errors.Is(err, storage.ErrMarkerConflict) {
+		t.Errorf("WriteMarkerIf() of an existing marker error = %v, want ErrMarkerConflict", err)
+	}
+
+	// The first sink decides conflicts; the others get copies
+	mirrorWriter.err = errors.New("disk failed")
+	if _, err := writer.WriteMarkerIf(context.Background(), path, "history.json", []byte("v2"), version); err != nil {
+		t.Fatalf("WriteMarkerIf() error = %v, want best-effort failure ignored", err)
+	}
+	if _, err := writer.WriteMarkerIf(context.Background(), path, "history.json", []byte("v3"), version); !errors.Is(err, storage.ErrMarkerConflict) {
+		t.Errorf("WriteMarkerIf() of a stale version error = %v, want ErrMarkerConflict", err)
+	}
+	data, _, err := writer.ReadMarkerVersion(context.Background(), path, "history.json")
+	if err != nil || string(data) != "v2" {
+		t.Errorf("ReadMarkerVersion() = %q, %v, want v2", data, err)
+	}
+	if len(mirrorWriter.markers) != 1 {
+		t.Errorf("mirror markers = %v, want the first copy", mirrorWriter.markers)
+	}
+}
+
+func TestFanoutWriter_FileSinks(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	primaryDir, mirrorDir := t.TempDir(), t.TempDir()
+
+	primaryWriter, err := NewFileWriter(FileConfig{BasePath: primaryDir}, event.FormatParquet, "snappy", logger, nil)
+	if err != nil {
+		t.Fatalf("NewFileWriter() error = %v", err)
+	}
+	mirrorWriter, err := NewFileWriter(FileConfig{BasePath: mirrorDir}, event.FormatAvro, "deflate", logger, nil)
+	if err != nil {
+		t.Fatalf("NewFileWriter() error = %v", err)
+	}
+
+	router := NewRouter("file", "", "", "v1")
+	writer, err := NewFanoutWriter([]Sink{
+		{Name: "primary", Writer: primaryWriter, Router: router, Format: event.FormatParquet},
+		{Name: "mirror", Writer: mirrorWriter, Router: router, Format: event.FormatAvro},
+	}, router, logger, nil)
+	if err != nil {
+		t.Fatalf("NewFanoutWriter() error = %v", err)
+	}
+
+	path := router.Route(event.PartitionID{Topic: "orders", Partition: 0}, 1734566400, "")
+	if _, err := writer.Write(context.Background(), fanoutTestRecords(), path, event.FormatParquet); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	partitionDir := filepath.Join("orders", "v1", "dt=2024-12-19", "pid=0")
+	for dir, pattern := range map[string]string{primaryDir: "events_*.parquet", mirrorDir: "events_*.avro"} {
+		files, _ := filepath.Glob(filepath.Join(dir, partitionDir, pattern))
+		if len(files) != 1 {
+			t.Errorf("expected 1 %s file in %s, got %d", pattern, dir, len(files))
+		}
+	}
+}
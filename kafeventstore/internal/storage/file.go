@@ -3,7 +3,11 @@ package storage
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log/slog"
 	"os"
 	"path/filepath"
@@ -12,12 +16,19 @@ import (
 	"time"
 
 	"github.com/jittakal/kafeventstore/internal/encoder"
+	"github.com/jittakal/kafeventstore/internal/envelope"
 	"github.com/jittakal/kafeventstore/pkg/event"
 	"github.com/jittakal/kafeventstore/pkg/storage"
 )
 
 // Ensure implementation satisfies interface at compile time.
-var _ storage.Writer = (*FileWriter)(nil)
+var (
+	_ storage.Writer       = (*FileWriter)(nil)
+	_ storage.MarkerWriter = (*FileWriter)(nil)
+	_ storage.MarkerReader = (*FileWriter)(nil)
+
+	_ storage.ConditionalMarkerStore = (*FileWriter)(nil)
+)
 
 // MetricsCollector defines metrics operations for storage.
 type MetricsCollector interface {
@@ -29,15 +40,24 @@ type MetricsCollector interface {
 
 // FileConfig contains local filesystem configuration.
 type FileConfig struct {
-	BasePath string
+	BasePath   string
+	Provenance encoder.Provenance
+	// TypedSchemas adds typed data columns to Parquet files of one event type.
+	TypedSchemas *encoder.TypedSchemas
+	// Encryptor encrypts files before they are committed; nil stores them as
+	// encoded. The envelope is kept in the sidecar manifest.
+	Encryptor *envelope.Encryptor
 }
 
 // FileWriter implements storage.Writer for local filesystem storage.
 // It provides thread-safe file writing with support for multiple formats (Avro, Parquet)
 // and compression options. Files are organized in a hierarchical directory structure.
+// Each file is written to a hidden temp file, synced and renamed into place, so
+// a crash never leaves a truncated file visible in a partition directory.
 type FileWriter struct {
 	basePath       string
 	encoderFactory *encoder.Factory
+	encryptor      *envelope.Encryptor
 	logger         *slog.Logger
 	metrics        MetricsCollector
 	mu             sync.RWMutex
@@ -58,8 +78,18 @@ func NewFileWriter(
 		return nil, fmt.Errorf("failed to create base path: %w", err)
 	}
 
+	// Remove files left uncommitted by a previous run
+	removed, err := removeOrphanedTempFiles(config.BasePath)
+	if len(removed) > 0 {
+		logger.Warn("removed orphaned temp files", "base_path", config.BasePath, "count", len(removed))
+	}
+	if err != nil {
+		return nil, err
+	}
+
 	// Create encoder factory
-	encoderFactory := encoder.NewFactory(format, compression)
+	encoderFactory := encoder.NewFactory(format, compression,
+		encoder.WithProvenance(config.Provenance), encoder.WithTypedSchemas(config.TypedSchemas))
 
 	// Validate encoder can be created
 	if _, err := encoderFactory.CreateEncoder(); err != nil {
@@ -70,11 +100,13 @@ func NewFileWriter(
 		"base_path", config.BasePath,
 		"format", format,
 		"compression", compression,
+		"encrypted", config.Encryptor != nil,
 	)
 
 	return &FileWriter{
 		basePath:       config.BasePath,
 		encoderFactory: encoderFactory,
+		encryptor:      config.Encryptor,
 		logger:         logger,
 		metrics:        metrics,
 	}, nil
@@ -124,6 +156,9 @@ func (w *FileWriter) Write(
 	}
 
 	filename := fmt.Sprintf("events_%s_%03d%s", timestamp, w.fileSequence, fileEncoder.FileExtension())
+	if w.encryptor != nil {
+		filename += envelope.FileSuffix
+	}
 
 	// Convert relative path to absolute and add timestamped filename
 	dir := filepath.Join(w.basePath, cleanPath)
@@ -137,15 +172,45 @@ func (w *FileWriter) Write(
 		return 0, fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	// Encode and write records
-	stats, err := fileEncoder.Encode(fullPath, records)
+	// Encode to a temp file in the same directory, then rename it into place
+	// so readers never see a partially written file
+	tempPath := filepath.Join(dir, tempFileName(filename))
+	stats, err := fileEncoder.Encode(tempPath, records)
 	if err != nil {
+		os.Remove(tempPath)
 		if w.metrics != nil {
 			w.metrics.IncStorageErrors("file", "encode")
 		}
 		return 0, fmt.Errorf("failed to encode records: %w", err)
 	}
 
+	var env *envelope.Envelope
+	if w.encryptor != nil {
+		env, err = encryptFile(ctx, w.encryptor, tempPath, stats)
+		if err != nil {
+			os.Remove(tempPath)
+			if w.metrics != nil {
+				w.metrics.IncStorageErrors("file", "encrypt")
+			}
+			return 0, err
+		}
+	}
+
+	if err := commitFile(tempPath, fullPath); err != nil {
+		os.Remove(tempPath)
+		if w.metrics != nil {
+			w.metrics.IncStorageErrors("file", "commit")
+		}
+		return 0, fmt.Errorf("failed to commit file: %w", err)
+	}
+
+	// Write sidecar manifest. An encrypted file cannot be decrypted without
+	// the envelope in its manifest, so it is removed if the manifest fails.
+	if err := w.writeManifest(fullPath, dir, filename, records, stats, format, fileEncoder.SchemaVersion(), env); err != nil && env != nil {
+		os.Remove(fullPath)
+		return 0, fmt.Errorf("failed to write manifest of encrypted file: %w", err)
+	}
+
 	duration := time.Since(startTime)
 
 	w.logger.Info("wrote records to file",
@@ -169,6 +234,134 @@ func (w *FileWriter) Write(
 	return stats.SizeBytes, nil
 }
 
+// writeManifest writes the sidecar manifest for an encoded file.
+// Manifest failures are logged and counted; they fail the data write only
+// when the file is encrypted.
+func (w *FileWriter) writeManifest(
+	filePath string,
+	dir string,
+	filename string,
+	records []event.Record,
+	stats *event.FileStats,
+	format event.FileFormat,
+	schemaVersion string,
+	env *envelope.Envelope,
+) error {
+	var data []byte
+	manifest, err := newFileManifest(filePath, filename, records, stats, format, schemaVersion)
+	if err == nil {
+		manifest.Encryption = env
+		data, err = manifest.Marshal()
+	}
+	if err == nil {
+		err = writeFileAtomic(filepath.Join(dir, ManifestName(filename)), data)
+	}
+	if err != nil {
+		if w.metrics != nil {
+			w.metrics.IncStorageErrors("file", "manifest")
+		}
+		w.logger.Error("failed to write manifest", "path", filePath, "error", err)
+	}
+	return err
+}
+
+// WriteMarker writes a marker file into the directory for the given path.
+func (w *FileWriter) WriteMarker(ctx context.Context, path string, name string, data []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	dir := filepath.Join(w.basePath, strings.TrimPrefix(path, "file://"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		if w.metrics != nil {
+			w.metrics.IncStorageErrors("file", "mkdir")
+		}
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if err := writeFileAtomic(filepath.Join(dir, name), data); err != nil {
+		if w.metrics != nil {
+			w.metrics.IncStorageErrors("file", "marker")
+		}
+		return fmt.Errorf("failed to write marker: %w", err)
+	}
+
+	w.logger.Debug("wrote marker file", "dir", dir, "name", name)
+	return nil
+}
+
+// ReadMarker reads a marker file from the directory for the given path.
+func (w *FileWriter) ReadMarker(ctx context.Context, path string, name string) ([]byte, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	data, err := os.ReadFile(filepath.Join(w.basePath, strings.TrimPrefix(path, "file://"), name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, storage.ErrMarkerNotFound
+	}
+	if err != nil {
+		if w.metrics != nil {
+			w.metrics.IncStorageErrors("file", "read_marker")
+		}
+		return nil, fmt.Errorf("failed to read marker: %w", err)
+	}
+	return data, nil
+}
+
+// ReadMarkerVersion reads a marker file with the SHA-256 of its content as
+// its version.
+func (w *FileWriter) ReadMarkerVersion(ctx context.Context, path string, name string) ([]byte, string, error) {
+	data, err := w.ReadMarker(ctx, path, name)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, contentVersion(data), nil
+}
+
+// WriteMarkerIf writes a marker file if its content is still the version
+// read by ReadMarkerVersion. The check only holds against writers of this
+// process; files shared by several processes have no such guarantee.
+func (w *FileWriter) WriteMarkerIf(ctx context.Context, path string, name string, data []byte, version string) (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	dir := filepath.Join(w.basePath, strings.TrimPrefix(path, "file://"))
+	current, err := os.ReadFile(filepath.Join(dir, name))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		if version != "" {
+			return "", storage.ErrMarkerConflict
+		}
+	case err != nil:
+		if w.metrics != nil {
+			w.metrics.IncStorageErrors("file", "read_marker")
+		}
+		return "", fmt.Errorf("failed to read marker: %w", err)
+	case version == "" || contentVersion(current) != version:
+		return "", storage.ErrMarkerConflict
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		if w.metrics != nil {
+			w.metrics.IncStorageErrors("file", "mkdir")
+		}
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(dir, name), data); err != nil {
+		if w.metrics != nil {
+			w.metrics.IncStorageErrors("file", "marker")
+		}
+		return "", fmt.Errorf("failed to write marker: %w", err)
+	}
+	return contentVersion(data), nil
+}
+
+// contentVersion is the version token of a marker file: the hex SHA-256 of
+// its content.
+func contentVersion(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
 // Close closes the writer.
 func (w *FileWriter) Close() error {
 	w.logger.Info("closing filesystem writer")
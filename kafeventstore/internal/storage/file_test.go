@@ -1,14 +1,19 @@
 package storage
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/jittakal/kafeventstore/internal/envelope"
 	"github.com/jittakal/kafeventstore/pkg/event"
+	"github.com/jittakal/kafeventstore/pkg/storage"
 )
 
 // mockMetricsCollector implements MetricsCollector for testing
@@ -209,6 +214,18 @@ func TestFileWriter_Write(t *testing.T) {
 					t.Errorf("expected files in directory %s", dirPath)
 				}
 
+				// Verify no uncommitted temp files were left behind
+				temps, _ := filepath.Glob(filepath.Join(dirPath, tempFilePrefix+"*"+tempFileSuffix))
+				if len(temps) != 0 {
+					t.Errorf("expected no temp files in %s, got %v", dirPath, temps)
+				}
+
+				// Verify a sidecar manifest was written for the data file
+				manifests, _ := filepath.Glob(filepath.Join(dirPath, "_*"+ManifestSuffix))
+				if len(manifests) != 1 {
+					t.Errorf("expected 1 manifest in %s, got %d", dirPath, len(manifests))
+				}
+
 				// Verify metrics were updated
 				if metrics.filesWritten != 1 {
 					t.Errorf("filesWritten = %d, want 1", metrics.filesWritten)
@@ -224,6 +241,195 @@ func TestFileWriter_Write(t *testing.T) {
 	}
 }
 
+func TestFileWriter_Write_Encrypted(t *testing.T) {
+	basePath := t.TempDir()
+	provider := newTestKeyProvider(t)
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	writer, err := NewFileWriter(
+		FileConfig{BasePath: basePath, Encryptor: envelope.NewEncryptor(provider)},
+		event.FormatAvro,
+		"null",
+		logger,
+		nil,
+	)
+	if err != nil {
+		t.Fatalf("NewFileWriter() failed: %v", err)
+	}
+
+	now := time.Now()
+	records := []event.Record{{
+		Event: &event.CloudEvent{
+			SpecVersion: "1.0",
+			Type:        "test.event",
+			Source:      "test-source",
+			ID:          "test-id-1",
+			Time:        &now,
+			Data:        []byte(`{"member": "jane@example.com"}`),
+		},
+		Kafka: event.KafkaMetadata{Topic: "test-topic", Offset: 100, Timestamp: now},
+	}}
+
+	size, err := writer.Write(context.Background(), records, "test-topic/pid=0", event.FormatAvro)
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	dir := filepath.Join(basePath, "test-topic/pid=0")
+	files, _ := filepath.Glob(filepath.Join(dir, "events_*.avro"+envelope.FileSuffix))
+	if len(files) != 1 {
+		t.Fatalf("expected 1 encrypted data file, got %v", files)
+	}
+	ciphertext, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != int64(len(ciphertext)) {
+		t.Errorf("Write() size = %d, want encrypted size %d", size, len(ciphertext))
+	}
+	if bytes.Contains(ciphertext, []byte("jane@example.com")) {
+		t.Error("data file contains plaintext event data")
+	}
+
+	// The manifest holds the envelope needed to decrypt the file
+	data, err := os.ReadFile(filepath.Join(dir, ManifestName(filepath.Base(files[0]))))
+	if err != nil {
+		t.Fatalf("failed to read manifest: %v", err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Encryption == nil || manifest.Encryption.KeyID != provider.KeyID() {
+		t.Fatalf("manifest encryption = %+v, want envelope of %s", manifest.Encryption, provider.KeyID())
+	}
+	if manifest.SizeBytes != size {
+		t.Errorf("manifest size = %d, want %d", manifest.SizeBytes, size)
+	}
+
+	plaintext := decryptFile(t, provider, manifest.Encryption, files[0])
+	if !bytes.HasPrefix(plaintext, []byte("Obj\x01")) {
+		t.Error("decrypted file is not an Avro container file")
+	}
+	if !bytes.Contains(plaintext, []byte("jane@example.com")) {
+		t.Error("decrypted file does not contain the event data")
+	}
+}
+
+func TestNewFileWriter_RemovesOrphanedTempFiles(t *testing.T) {
+	basePath := t.TempDir()
+	dir := filepath.Join(basePath, "topic/v1/dt=2025-12-18/pid=0")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	orphan := filepath.Join(dir, tempFileName("events_20251218_100000_001.parquet"))
+	committed := filepath.Join(dir, "events_20251218_100000_002.parquet")
+	for _, path := range []string{orphan, committed} {
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	if _, err := NewFileWriter(FileConfig{BasePath: basePath}, event.FormatParquet, "snappy", logger, nil); err != nil {
+		t.Fatalf("NewFileWriter() failed: %v", err)
+	}
+
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Errorf("expected orphaned temp file %s to be removed", orphan)
+	}
+	if _, err := os.Stat(committed); err != nil {
+		t.Errorf("expected committed file %s to be kept: %v", committed, err)
+	}
+}
+
+func TestFileWriter_WriteMarker(t *testing.T) {
+	basePath := t.TempDir()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	writer, err := NewFileWriter(FileConfig{BasePath: basePath}, event.FormatParquet, "snappy", logger, nil)
+	if err != nil {
+		t.Fatalf("NewFileWriter() failed: %v", err)
+	}
+
+	path := "file:///topic/v1/dt=2025-12-18/pid=0/"
+	if err := writer.WriteMarker(context.Background(), path, SuccessMarker, nil); err != nil {
+		t.Fatalf("WriteMarker() error = %v", err)
+	}
+
+	markerPath := filepath.Join(basePath, "topic/v1/dt=2025-12-18/pid=0", SuccessMarker)
+	if _, err := os.Stat(markerPath); err != nil {
+		t.Errorf("expected marker at %s: %v", markerPath, err)
+	}
+}
+
+func TestFileWriter_ReadMarker(t *testing.T) {
+	basePath := t.TempDir()
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	writer, err := NewFileWriter(FileConfig{BasePath: basePath}, event.FormatParquet, "snappy", logger, nil)
+	if err != nil {
+		t.Fatalf("NewFileWriter() failed: %v", err)
+	}
+
+	path := "file:///_schemas/topic/type=created/"
+	if _, err := writer.ReadMarker(context.Background(), path, "history.json"); !errors.Is(err, storage.ErrMarkerNotFound) {
+		t.Fatalf("ReadMarker() error = %v, want ErrMarkerNotFound", err)
+	}
+
+	if err := writer.WriteMarker(context.Background(), path, "history.json", []byte(`{"versions":[]}`)); err != nil {
+		t.Fatalf("WriteMarker() error = %v", err)
+	}
+	data, err := writer.ReadMarker(context.Background(), path, "history.json")
+	if err != nil {
+		t.Fatalf("ReadMarker() error = %v", err)
+	}
+	if string(data) != `{"versions":[]}` {
+		t.Errorf("ReadMarker() = %s", data)
+	}
+}
+
+func TestFileWriter_WriteMarkerIf(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	writer, err := NewFileWriter(FileConfig{BasePath: t.TempDir()}, event.FormatParquet, "snappy", logger, nil)
+	if err != nil {
+		t.Fatalf("NewFileWriter() failed: %v", err)
+	}
+	path := "file:///_schemas/topic/type=created/"
+
+	if _, _, err := writer.ReadMarkerVersion(context.Background(), path, "history.json"); !errors.Is(err, storage.ErrMarkerNotFound) {
+		t.Fatalf("ReadMarkerVersion() error = %v, want ErrMarkerNotFound", err)
+	}
+	if _, err := writer.WriteMarkerIf(context.Background(), path, "history.json", []byte("v1"), "stale"); !errors.Is(err, storage.ErrMarkerConflict) {
+		t.Errorf("WriteMarkerIf() of a missing marker error = %v, want ErrMarkerConflict", err)
+	}
+	written, err := writer.WriteMarkerIf(context.Background(), path, "history.json", []byte("v1"), "")
+	if err != nil {
+		t.Fatalf("WriteMarkerIf() error = %v", err)
+	}
+	data, version, err := writer.ReadMarkerVersion(context.Background(), path, "history.json")
+	if err != nil || string(data) != "v1" || version != written {
+		t.Fatalf("ReadMarkerVersion() = %q, %q, %v, want v1 at %q", data, version, err, written)
+	}
+
+	tests := []struct {
+		name    string
+		version string
+		wantErr error
+	}{
+		{"create existing", "", storage.ErrMarkerConflict},
+		{"stale version", "stale", storage.ErrMarkerConflict},
+		{"current version", version, nil},
+		{"replaced version", version, storage.ErrMarkerConflict},
+	}
+	for _, tt := range tests {
+		if _, err := writer.WriteMarkerIf(context.Background(), path, "history.json", []byte("v2"), tt.version); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: WriteMarkerIf() error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
 func TestFileWriter_Close(t *testing.T) {
 	basePath := filepath.Join(os.TempDir(), "test-file-writer-close")
 	defer os.RemoveAll(basePath)
@@ -3,34 +3,122 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"net/http"
 	"os"
 	"path/filepath"
-	"strings"
+	"strconv"
 	"sync"
 	"time"
 
 	"cloud.google.com/go/storage"
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 
 	"github.com/jittakal/kafeventstore/internal/encoder"
+	"github.com/jittakal/kafeventstore/internal/envelope"
 	"github.com/jittakal/kafeventstore/pkg/event"
 	pkgstorage "github.com/jittakal/kafeventstore/pkg/storage"
 )
 
 // Ensure implementation satisfies interface at compile time.
-var _ pkgstorage.Writer = (*GCSWriter)(nil)
+var (
+	_ pkgstorage.Writer       = (*GCSWriter)(nil)
+	_ pkgstorage.MarkerWriter = (*GCSWriter)(nil)
+	_ pkgstorage.MarkerReader = (*GCSWriter)(nil)
+
+	_ pkgstorage.ConditionalMarkerStore = (*GCSWriter)(nil)
+)
+
+// GCS retry policies.
+const (
+	// GCSRetryIdempotent retries only idempotent operations (SDK default).
+	GCSRetryIdempotent = "idempotent"
+	// GCSRetryAlways retries all operations, including uploads without preconditions.
+	GCSRetryAlways = "always"
+	// GCSRetryNever disables retries.
+	GCSRetryNever = "never"
+)
 
 // GCSConfig contains Google Cloud Storage configuration.
 type GCSConfig struct {
-	Bucket               string
-	ProjectID            string
-	CredentialsFile      string
-	CredentialsJSON      string
+	Bucket          string
+	ProjectID       string
+	CredentialsFile string
+	CredentialsJSON string
+	// Endpoint overrides the JSON API endpoint, e.g. a Private Service Connect
+	// endpoint or http://localhost:4443/storage/v1/ for fake-gcs-server.
 	Endpoint             string
 	UseDefaultCredential bool
+	// WithoutAuthentication sends unauthenticated requests, for emulators.
+	WithoutAuthentication bool
+	// ChunkSize is the upload buffer size in bytes. Objects larger than one
+	// chunk use resumable uploads; 0 uses the SDK default of 16MiB.
+	ChunkSize  int
+	Retry      GCSRetryConfig
+	Provenance encoder.Provenance
+	Metadata   ObjectMetadataConfig
+	// TypedSchemas adds typed data columns to Parquet files of one event type.
+	TypedSchemas *encoder.TypedSchemas
+	// Encryptor encrypts files before they are uploaded; nil uploads them as encoded.
+	Encryptor *envelope.Encryptor
+}
+
+// GCSRetryConfig configures retries of GCS object operations.
+// Zero values keep the SDK defaults.
+type GCSRetryConfig struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	Policy         string
+}
+
+// Validate validates GCS retry configuration.
+func (c GCSRetryConfig) Validate() error {
+	if c.MaxAttempts < 0 {
+		return fmt.Errorf("gcs retry max attempts must be non-negative, got %d", c.MaxAttempts)
+	}
+	if c.InitialBackoff < 0 || c.MaxBackoff < 0 {
+		return fmt.Errorf("gcs retry backoff must be non-negative")
+	}
+	if c.Multiplier != 0 && c.Multiplier <= 1 {
+		return fmt.Errorf("gcs retry multiplier must be greater than 1, got %v", c.Multiplier)
+	}
+	switch c.Policy {
+	case "", GCSRetryIdempotent, GCSRetryAlways, GCSRetryNever:
+	default:
+		return fmt.Errorf("unsupported gcs retry policy: %s", c.Policy)
+	}
+	return nil
+}
+
+// options returns the SDK retry options for the configuration.
+func (c GCSRetryConfig) options() []storage.RetryOption {
+	var opts []storage.RetryOption
+	if c.MaxAttempts > 0 {
+		opts = append(opts, storage.WithMaxAttempts(c.MaxAttempts))
+	}
+	if c.InitialBackoff > 0 || c.MaxBackoff > 0 || c.Multiplier > 0 {
+		opts = append(opts, storage.WithBackoff(gax.Backoff{
+			Initial:    c.InitialBackoff,
+			Max:        c.MaxBackoff,
+			Multiplier: c.Multiplier,
+		}))
+	}
+	switch c.Policy {
+	case GCSRetryAlways:
+		opts = append(opts, storage.WithPolicy(storage.RetryAlways))
+	case GCSRetryNever:
+		opts = append(opts, storage.WithPolicy(storage.RetryNever))
+	case GCSRetryIdempotent:
+		opts = append(opts, storage.WithPolicy(storage.RetryIdempotent))
+	}
+	return opts
 }
 
 // GCSWriter implements storage.Writer for Google Cloud Storage.
@@ -39,7 +127,10 @@ type GCSConfig struct {
 type GCSWriter struct {
 	client         *storage.Client
 	bucket         string
+	chunkSize      int
+	objectMetadata ObjectMetadataConfig
 	encoderFactory *encoder.Factory
+	encryptor      *envelope.Encryptor
 	logger         *slog.Logger
 	metrics        MetricsCollector
 	mu             sync.RWMutex
@@ -55,13 +146,27 @@ func NewGCSWriter(
 ) (*GCSWriter, error) {
 	ctx := context.Background()
 
+	if err := cfg.Metadata.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid object metadata config: %w", err)
+	}
+	if cfg.ChunkSize < 0 {
+		return nil, fmt.Errorf("gcs chunk size must be non-negative, got %d", cfg.ChunkSize)
+	}
+	if err := cfg.Retry.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid GCS retry config: %w", err)
+	}
+
 	// Determine authentication method
 	var clientOpts []option.ClientOption
 	if cfg.Endpoint != "" {
 		clientOpts = append(clientOpts, option.WithEndpoint(cfg.Endpoint))
 	}
 
-	if cfg.UseDefaultCredential {
+	if cfg.WithoutAuthentication {
+		// Emulators such as fake-gcs-server accept unauthenticated requests
+		clientOpts = append(clientOpts, option.WithoutAuthentication())
+		logger.Info("using unauthenticated GCS client", "endpoint", cfg.Endpoint)
+	} else if cfg.UseDefaultCredential {
 		// Use default credentials (ADC)
 		// This will use GOOGLE_APPLICATION_CREDENTIALS env var or default service account
 		logger.Info("using default GCP credentials")
@@ -83,9 +188,13 @@ func NewGCSWriter(
 	if err != nil {
 		return nil, fmt.Errorf("failed to create GCS client: %w", err)
 	}
+	if retryOpts := cfg.Retry.options(); len(retryOpts) > 0 {
+		client.SetRetry(retryOpts...)
+	}
 
 	// Create encoder factory
-	encoderFactory := encoder.NewFactory(format, compression)
+	encoderFactory := encoder.NewFactory(format, compression,
+		encoder.WithProvenance(cfg.Provenance), encoder.WithTypedSchemas(cfg.TypedSchemas))
 
 	// Validate encoder can be created
 	if _, err := encoderFactory.CreateEncoder(); err != nil {
@@ -97,12 +206,16 @@ func NewGCSWriter(
 		"project_id", cfg.ProjectID,
 		"format", format,
 		"compression", compression,
+		"encrypted", cfg.Encryptor != nil,
 	)
 
 	return &GCSWriter{
 		client:         client,
 		bucket:         cfg.Bucket,
+		chunkSize:      cfg.ChunkSize,
+		objectMetadata: cfg.Metadata,
 		encoderFactory: encoderFactory,
+		encryptor:      cfg.Encryptor,
 		logger:         logger,
 		metrics:        metrics,
 	}, nil
@@ -135,24 +248,16 @@ func (w *GCSWriter) Write(
 
 	// Parse GCS URI to extract object path
 	// Path format: gs://bucket/object/path or just object/path
-	objectPath := path
-	if strings.HasPrefix(path, "gs://") {
-		// Remove gs:// prefix and bucket
-		pathWithoutProtocol := strings.TrimPrefix(path, "gs://")
-		parts := strings.SplitN(pathWithoutProtocol, "/", 2)
-		if len(parts) == 2 {
-			objectPath = parts[1]
-		} else {
-			objectPath = ""
-		}
-	}
+	objectDir := objectPrefix(path, "gs")
 
 	// Generate timestamped filename: events_YYYYMMDD_HHMMSS_NNN.{ext}
 	now := time.Now()
 	timestamp := now.Format("20060102_150405")
 	filename := fmt.Sprintf("events_%s_%03d%s", timestamp, now.Nanosecond()/1000000, enc.FileExtension())
-	objectPath = objectPath + filename
-	objectPath = strings.TrimPrefix(objectPath, "/")
+	if w.encryptor != nil {
+		filename += envelope.FileSuffix
+	}
+	objectPath := objectKey(objectDir, filename)
 
 	// Encode to temporary file
 	tempDir := os.TempDir()
@@ -167,6 +272,18 @@ func (w *GCSWriter) Write(
 	}
 	defer os.Remove(tempFile)
 
+	// Encrypt the encoded file before it leaves the process
+	var env *envelope.Envelope
+	if w.encryptor != nil {
+		env, err = encryptFile(ctx, w.encryptor, tempFile, stats)
+		if err != nil {
+			if w.metrics != nil {
+				w.metrics.IncStorageErrors("gcs", "encrypt")
+			}
+			return 0, err
+		}
+	}
+
 	// Open the file for upload
 	file, err := os.Open(tempFile)
 	if err != nil {
@@ -177,18 +294,27 @@ func (w *GCSWriter) Write(
 	}
 	defer file.Close()
 
+	// Build the manifest first so its offsets can be attached to the upload
+	manifest, manifestErr := newFileManifest(tempFile, filename, records, stats, format, enc.SchemaVersion())
+	if manifest != nil {
+		manifest.Encryption = env
+	}
+
 	// Create GCS object writer
 	obj := w.client.Bucket(w.bucket).Object(objectPath)
 	gcsWriter := obj.NewWriter(ctx)
 
-	// Set content type based on format
-	switch format {
-	case event.FormatParquet:
-		gcsWriter.ContentType = "application/octet-stream"
-	case event.FormatAvro:
-		gcsWriter.ContentType = "application/avro"
-	default:
-		gcsWriter.ContentType = "application/octet-stream"
+	// Set content type based on format and attach object metadata
+	gcsWriter.ContentType = encryptedContentType(format, env)
+	gcsWriter.Metadata = withEnvelope(w.objectMetadata.objectMetadata(manifest), env)
+	if w.chunkSize > 0 {
+		gcsWriter.ChunkSize = w.chunkSize
+	}
+	// Send the encoder checksums so GCS rejects corrupted uploads
+	if stats.Checksums != nil {
+		gcsWriter.CRC32C = stats.Checksums.CRC32C
+		gcsWriter.SendCRC32C = true
+		gcsWriter.MD5 = stats.Checksums.MD5
 	}
 
 	// Copy file to GCS
@@ -209,6 +335,21 @@ func (w *GCSWriter) Write(
 		return 0, fmt.Errorf("failed to close GCS writer: %w", err)
 	}
 
+	// Upload sidecar manifest; failures are logged but do not fail the write
+	var manifestData []byte
+	if manifestErr == nil {
+		manifestData, manifestErr = manifest.Marshal()
+	}
+	if manifestErr == nil {
+		manifestErr = w.putObject(ctx, objectKey(objectDir, ManifestName(filename)), manifestData)
+	}
+	if manifestErr != nil {
+		if w.metrics != nil {
+			w.metrics.IncStorageErrors("gcs", "manifest")
+		}
+		w.logger.Error("failed to write manifest", "bucket", w.bucket, "object", objectPath, "error", manifestErr)
+	}
+
 	duration := time.Since(startTime)
 
 	w.logger.Info("wrote records to GCS",
@@ -233,6 +374,111 @@ func (w *GCSWriter) Write(
 	return stats.SizeBytes, nil
 }
 
+// WriteMarker writes a small marker object under the given path.
+func (w *GCSWriter) WriteMarker(ctx context.Context, path string, name string, data []byte) error {
+	objectPath := objectKey(objectPrefix(path, "gs"), name)
+	if err := w.putObject(ctx, objectPath, data); err != nil {
+		if w.metrics != nil {
+			w.metrics.IncStorageErrors("gcs", "marker")
+		}
+		return err
+	}
+
+	w.logger.Debug("wrote marker object", "bucket", w.bucket, "object", objectPath)
+	return nil
+}
+
+// ReadMarker reads a marker object under the given path.
+func (w *GCSWriter) ReadMarker(ctx context.Context, path string, name string) ([]byte, error) {
+	objectPath := objectKey(objectPrefix(path, "gs"), name)
+	reader, err := w.client.Bucket(w.bucket).Object(objectPath).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, pkgstorage.ErrMarkerNotFound
+	}
+	if err != nil {
+		if w.metrics != nil {
+			w.metrics.IncStorageErrors("gcs", "read_marker")
+		}
+		return nil, fmt.Errorf("failed to open GCS object %s: %w", objectPath, err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GCS object %s: %w", objectPath, err)
+	}
+	return data, nil
+}
+
+// ReadMarkerVersion reads a marker object with its generation as its version.
+func (w *GCSWriter) ReadMarkerVersion(ctx context.Context, path string, name string) ([]byte, string, error) {
+	objectPath := objectKey(objectPrefix(path, "gs"), name)
+	reader, err := w.client.Bucket(w.bucket).Object(objectPath).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, "", pkgstorage.ErrMarkerNotFound
+	}
+	if err != nil {
+		if w.metrics != nil {
+			w.metrics.IncStorageErrors("gcs", "read_marker")
+		}
+		return nil, "", fmt.Errorf("failed to open GCS object %s: %w", objectPath, err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read GCS object %s: %w", objectPath, err)
+	}
+	return data, strconv.FormatInt(reader.Attrs.Generation, 10), nil
+}
+
+// WriteMarkerIf writes a marker object with a generation precondition, or a
+// does-not-exist precondition for a new object.
+func (w *GCSWriter) WriteMarkerIf(ctx context.Context, path string, name string, data []byte, version string) (string, error) {
+	objectPath := objectKey(objectPrefix(path, "gs"), name)
+	conditions := storage.Conditions{DoesNotExist: true}
+	if version != "" {
+		generation, err := strconv.ParseInt(version, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid GCS object generation %q: %w", version, err)
+		}
+		conditions = storage.Conditions{GenerationMatch: generation}
+	}
+
+	gcsWriter := w.client.Bucket(w.bucket).Object(objectPath).If(conditions).NewWriter(ctx)
+	gcsWriter.ContentType = "application/json"
+	_, err := gcsWriter.Write(data)
+	if closeErr := gcsWriter.Close(); err == nil {
+		err = closeErr
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed {
+		return "", pkgstorage.ErrMarkerConflict
+	}
+	if err != nil {
+		if w.metrics != nil {
+			w.metrics.IncStorageErrors("gcs", "marker")
+		}
+		return "", fmt.Errorf("failed to write GCS object %s: %w", objectPath, err)
+	}
+	return strconv.FormatInt(gcsWriter.Attrs().Generation, 10), nil
+}
+
+// putObject uploads a small in-memory object to the bucket.
+func (w *GCSWriter) putObject(ctx context.Context, objectPath string, data []byte) error {
+	gcsWriter := w.client.Bucket(w.bucket).Object(objectPath).NewWriter(ctx)
+	gcsWriter.ContentType = "application/json"
+
+	if _, err := gcsWriter.Write(data); err != nil {
+		gcsWriter.Close()
+		return fmt.Errorf("failed to write GCS object %s: %w", objectPath, err)
+	}
+	if err := gcsWriter.Close(); err != nil {
+		return fmt.Errorf("failed to close GCS object %s: %w", objectPath, err)
+	}
+	return nil
+}
+
 // Close closes the GCS writer.
 func (w *GCSWriter) Close() error {
 	w.logger.Info("closing GCS writer")
@@ -3,6 +3,8 @@ package storage
 import (
 	"context"
 	"errors"
+	"log/slog"
+	"os"
 	"testing"
 	"time"
 
@@ -140,6 +142,89 @@ func normalizeGCSPath(path, bucket string) string {
 	return path
 }
 
+func TestGCSRetryConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  GCSRetryConfig
+		wantErr bool
+	}{
+		{name: "defaults", config: GCSRetryConfig{}, wantErr: false},
+		{
+			name: "custom backoff",
+			config: GCSRetryConfig{
+				MaxAttempts:    5,
+				InitialBackoff: 100 * time.Millisecond,
+				MaxBackoff:     5 * time.Second,
+				Multiplier:     2,
+				Policy:         GCSRetryAlways,
+			},
+			wantErr: false,
+		},
+		{name: "negative attempts", config: GCSRetryConfig{MaxAttempts: -1}, wantErr: true},
+		{name: "negative backoff", config: GCSRetryConfig{InitialBackoff: -time.Second}, wantErr: true},
+		{name: "multiplier not above 1", config: GCSRetryConfig{Multiplier: 1}, wantErr: true},
+		{name: "unknown policy", config: GCSRetryConfig{Policy: "sometimes"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGCSRetryConfig_Options(t *testing.T) {
+	tests := []struct {
+		name   string
+		config GCSRetryConfig
+		want   int
+	}{
+		{"defaults", GCSRetryConfig{}, 0},
+		{"max attempts", GCSRetryConfig{MaxAttempts: 3}, 1},
+		{"backoff", GCSRetryConfig{InitialBackoff: time.Second, Multiplier: 2}, 1},
+		{"all settings", GCSRetryConfig{MaxAttempts: 3, MaxBackoff: time.Minute, Policy: GCSRetryNever}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := len(tt.config.options()); got != tt.want {
+				t.Errorf("len(options()) = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewGCSWriter_Emulator(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	writer, err := NewGCSWriter(GCSConfig{
+		Bucket:                "events",
+		Endpoint:              "http://localhost:4443/storage/v1/",
+		WithoutAuthentication: true,
+		ChunkSize:             256 * 1024,
+		Retry:                 GCSRetryConfig{MaxAttempts: 3, Policy: GCSRetryAlways},
+	}, event.FormatParquet, "snappy", logger, nil)
+	if err != nil {
+		t.Fatalf("NewGCSWriter() error = %v", err)
+	}
+	defer writer.Close()
+
+	if writer.chunkSize != 256*1024 {
+		t.Errorf("chunkSize = %d, want %d", writer.chunkSize, 256*1024)
+	}
+
+	if _, err := NewGCSWriter(GCSConfig{
+		Bucket:                "events",
+		WithoutAuthentication: true,
+		ChunkSize:             -1,
+	}, event.FormatParquet, "snappy", logger, nil); err == nil {
+		t.Error("expected error for negative chunk size")
+	}
+}
+
 func TestGCSWriter_Interface(t *testing.T) {
 	// This test ensures GCSWriter implements the storage.Writer interface
 	var _ interface {
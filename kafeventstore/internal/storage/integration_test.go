@@ -0,0 +1,217 @@
+//go:build integration
+
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	gcs "cloud.google.com/go/storage"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/jittakal/kafeventstore/pkg/event"
+)
+
+// Integration tests run against local emulators:
+//
+//	S3:    MinIO           S3_TEST_ENDPOINT=http://localhost:9000 (plus AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY)
+//	GCS:   fake-gcs-server GCS_TEST_ENDPOINT=http://localhost:4443/storage/v1/
+//	Azure: Azurite         AZURITE_BLOB_ENDPOINT=http://127.0.0.1:10000/devstoreaccount1
+//
+// Run with: make test-integration
+
+const (
+	integrationBucket = "kafeventstore-it"
+
+	// azuriteAccountKey is the well-known Azurite development account key.
+	azuriteAccountKey = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+)
+
+var integrationMetadata = ObjectMetadataConfig{
+	Enabled: true,
+	Tags:    map[string]string{"env": "integration"},
+}
+
+func integrationRecords() []event.Record {
+	now := time.Now().UTC()
+	return []event.Record{
+		{
+			Event: &event.CloudEvent{SpecVersion: "1.0", ID: "it-1", Source: "it", Type: "it.event", Time: &now, Data: []byte(`{"n":1}`)},
+			Kafka: event.KafkaMetadata{Topic: "it-topic", Partition: 1, Offset: 500, Timestamp: now},
+		},
+		{
+			Event: &event.CloudEvent{SpecVersion: "1.0", ID: "it-2", Source: "it", Type: "it.event", Time: &now, Data: []byte(`{"n":2}`)},
+			Kafka: event.KafkaMetadata{Topic: "it-topic", Partition: 1, Offset: 501, Timestamp: now},
+		},
+	}
+}
+
+func integrationLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(os.Stdout, nil))
+}
+
+func assertObjectMetadata(t *testing.T, metadata map[string]string) {
+	t.Helper()
+	want := map[string]string{
+		ObjectMetaTopic:       "it-topic",
+		ObjectMetaPartition:   "1",
+		ObjectMetaOffsetMin:   "500",
+		ObjectMetaOffsetMax:   "501",
+		ObjectMetaRecordCount: "2",
+		ObjectMetaFormat:      "parquet",
+		"env":                 "integration",
+	}
+	for key, value := range want {
+		// Some services normalize metadata key casing
+		got, ok := metadata[key]
+		if !ok {
+			for k, v := range metadata {
+				if strings.EqualFold(k, key) {
+					got, ok = v, true
+				}
+			}
+		}
+		if !ok || got != value {
+			t.Errorf("metadata[%q] = %q, want %q", key, got, value)
+		}
+	}
+}
+
+func TestIntegration_S3ObjectMetadata(t *testing.T) {
+	endpoint := os.Getenv("S3_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("S3_TEST_ENDPOINT not set")
+	}
+	ctx := context.Background()
+
+	writer, err := NewS3Writer(S3Config{
+		Bucket:       integrationBucket,
+		Region:       "us-east-1",
+		Endpoint:     endpoint,
+		UsePathStyle: true,
+		Metadata:     integrationMetadata,
+	}, event.FormatParquet, "snappy", integrationLogger(), nil)
+	if err != nil {
+		t.Fatalf("NewS3Writer() error = %v", err)
+	}
+	_, _ = writer.client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(integrationBucket)})
+
+	prefix := "it/s3/" + time.Now().Format("150405.000000") + "/"
+	if _, err := writer.Write(ctx, integrationRecords(), "s3://"+integrationBucket+"/"+prefix, event.FormatParquet); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	list, err := writer.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(integrationBucket),
+		Prefix: aws.String(prefix + "events_"),
+	})
+	if err != nil || len(list.Contents) != 1 {
+		t.Fatalf("expected one data object under %s: %v", prefix, err)
+	}
+	key := list.Contents[0].Key
+
+	head, err := writer.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(integrationBucket), Key: key})
+	if err != nil {
+		t.Fatalf("HeadObject() error = %v", err)
+	}
+	assertObjectMetadata(t, head.Metadata)
+
+	tagging, err := writer.client.GetObjectTagging(ctx, &s3.GetObjectTaggingInput{Bucket: aws.String(integrationBucket), Key: key})
+	if err != nil {
+		t.Fatalf("GetObjectTagging() error = %v", err)
+	}
+	if len(tagging.TagSet) != 3 {
+		t.Errorf("len(TagSet) = %d, want 3", len(tagging.TagSet))
+	}
+}
+
+func TestIntegration_GCSObjectMetadata(t *testing.T) {
+	endpoint := os.Getenv("GCS_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("GCS_TEST_ENDPOINT not set")
+	}
+	ctx := context.Background()
+
+	writer, err := NewGCSWriter(GCSConfig{
+		Bucket:                integrationBucket,
+		ProjectID:             "test-project",
+		Endpoint:              endpoint,
+		WithoutAuthentication: true,
+		Retry:                 GCSRetryConfig{MaxAttempts: 3, Policy: GCSRetryAlways},
+		Metadata:              integrationMetadata,
+	}, event.FormatParquet, "snappy", integrationLogger(), nil)
+	if err != nil {
+		t.Fatalf("NewGCSWriter() error = %v", err)
+	}
+	_ = writer.client.Bucket(integrationBucket).Create(ctx, "test-project", nil)
+
+	prefix := "it/gcs/" + time.Now().Format("150405.000000") + "/"
+	if _, err := writer.Write(ctx, integrationRecords(), "gs://"+integrationBucket+"/"+prefix, event.FormatParquet); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	it := writer.client.Bucket(integrationBucket).Objects(ctx, &gcs.Query{Prefix: prefix + "events_"})
+	attrs, err := it.Next()
+	if err != nil {
+		t.Fatalf("expected one data object under %s: %v", prefix, err)
+	}
+	assertObjectMetadata(t, attrs.Metadata)
+	if attrs.ContentType != "application/octet-stream" {
+		t.Errorf("ContentType = %q", attrs.ContentType)
+	}
+}
+
+func TestIntegration_AzureObjectMetadata(t *testing.T) {
+	endpoint := os.Getenv("AZURITE_BLOB_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("AZURITE_BLOB_ENDPOINT not set")
+	}
+	ctx := context.Background()
+
+	writer, err := NewAzureWriter(AzureConfig{
+		AccountName:   "devstoreaccount1",
+		AccountKey:    azuriteAccountKey,
+		ContainerName: integrationBucket,
+		Endpoint:      endpoint,
+		Metadata:      integrationMetadata,
+	}, event.FormatParquet, "snappy", integrationLogger(), nil)
+	if err != nil {
+		t.Fatalf("NewAzureWriter() error = %v", err)
+	}
+	_, _ = writer.client.CreateContainer(ctx, integrationBucket, nil)
+
+	prefix := "it/azure/" + time.Now().Format("150405.000000") + "/"
+	if _, err := writer.Write(ctx, integrationRecords(), "wasbs://"+integrationBucket+"/"+prefix, event.FormatParquet); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	containerClient := writer.client.ServiceClient().NewContainerClient(integrationBucket)
+	pager := containerClient.NewListBlobsFlatPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			t.Fatalf("ListBlobs() error = %v", err)
+		}
+		for _, item := range page.Segment.BlobItems {
+			if !strings.HasPrefix(*item.Name, prefix+"events_") {
+				continue
+			}
+			props, err := containerClient.NewBlobClient(*item.Name).GetProperties(ctx, nil)
+			if err != nil {
+				t.Fatalf("GetProperties() error = %v", err)
+			}
+			metadata := make(map[string]string, len(props.Metadata))
+			for key, value := range props.Metadata {
+				metadata[key] = *value
+			}
+			assertObjectMetadata(t, metadata)
+			return
+		}
+	}
+	t.Fatalf("expected one data blob under %s", prefix)
+}
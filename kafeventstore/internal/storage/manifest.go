@@ -0,0 +1,187 @@
+// Package storage implements per-file manifests and partition completion markers.
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jittakal/kafeventstore/internal/envelope"
+	"github.com/jittakal/kafeventstore/pkg/event"
+)
+
+const (
+	// ManifestSuffix is appended to a data file name to form its sidecar manifest name.
+	ManifestSuffix = ".manifest.json"
+
+	// SuccessMarker is the name of the marker written into a partition directory
+	// once the event-time watermark has passed the end of its window.
+	SuccessMarker = "_SUCCESS"
+)
+
+// Manifest describes the contents of a single data file.
+// It is written as a sidecar JSON object next to the data file so that batch
+// jobs can tell which Kafka offsets and event times a file holds.
+type Manifest struct {
+	File          string    `json:"file"`
+	Topic         string    `json:"topic"`
+	Partition     int32     `json:"partition"`
+	FirstOffset   int64     `json:"first_offset"`
+	LastOffset    int64     `json:"last_offset"`
+	RecordCount   int       `json:"record_count"`
+	MinEventTime  time.Time `json:"min_event_time"`
+	MaxEventTime  time.Time `json:"max_event_time"`
+	SizeBytes     int64     `json:"size_bytes"`
+	Checksum      string    `json:"checksum"`
+	CRC32C        string    `json:"crc32c,omitempty"`
+	MD5           string    `json:"md5,omitempty"`
+	Format        string    `json:"format"`
+	SchemaVersion string    `json:"schema_version"`
+	CreatedAt     time.Time `json:"created_at"`
+	// Encryption is the envelope of an encrypted file; the size and
+	// checksums are then those of the encrypted file.
+	Encryption *envelope.Envelope `json:"encryption,omitempty"`
+}
+
+// NewManifest builds a manifest for records encoded into fileName.
+// Records are expected to belong to a single topic partition.
+func NewManifest(
+	fileName string,
+	records []event.Record,
+	stats *event.FileStats,
+	format event.FileFormat,
+	schemaVersion string,
+	checksum string,
+) *Manifest {
+	m := &Manifest{
+		File:          fileName,
+		RecordCount:   len(records),
+		Checksum:      checksum,
+		Format:        string(format),
+		SchemaVersion: schemaVersion,
+		CreatedAt:     time.Now().UTC(),
+	}
+	if stats != nil {
+		m.SizeBytes = stats.SizeBytes
+		if stats.Checksums != nil {
+			m.CRC32C = base64CRC32C(stats.Checksums.CRC32C)
+			m.MD5 = base64.StdEncoding.EncodeToString(stats.Checksums.MD5)
+		}
+	}
+	if len(records) == 0 {
+		return m
+	}
+
+	m.Topic = records[0].Kafka.Topic
+	m.Partition = records[0].Kafka.Partition
+	m.FirstOffset = records[0].Kafka.Offset
+	m.LastOffset = records[0].Kafka.Offset
+	m.MinEventTime = records[0].GetEventTime().UTC()
+	m.MaxEventTime = m.MinEventTime
+
+	for _, record := range records[1:] {
+		if record.Kafka.Offset < m.FirstOffset {
+			m.FirstOffset = record.Kafka.Offset
+		}
+		if record.Kafka.Offset > m.LastOffset {
+			m.LastOffset = record.Kafka.Offset
+		}
+		eventTime := record.GetEventTime().UTC()
+		if eventTime.Before(m.MinEventTime) {
+			m.MinEventTime = eventTime
+		}
+		if eventTime.After(m.MaxEventTime) {
+			m.MaxEventTime = eventTime
+		}
+	}
+
+	return m
+}
+
+// Marshal returns the JSON encoding of the manifest.
+func (m *Manifest) Marshal() ([]byte, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	return data, nil
+}
+
+// ManifestName returns the sidecar manifest name for a data file.
+// The leading underscore keeps manifests hidden from Spark and Hive readers.
+func ManifestName(fileName string) string {
+	return "_" + fileName + ManifestSuffix
+}
+
+// fileChecksum returns the SHA-256 checksum of a file as "sha256:<hex>".
+func fileChecksum(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", fmt.Errorf("failed to hash file: %w", err)
+	}
+	return "sha256:" + hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// base64CRC32C returns the base64 encoding of a big-endian CRC32C checksum,
+// the representation used by S3 and GCS.
+func base64CRC32C(crc uint32) string {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], crc)
+	return base64.StdEncoding.EncodeToString(buf[:])
+}
+
+// newFileManifest returns the manifest of an encoded file.
+// The SHA-256 checksum computed by the encoder is used when available;
+// otherwise the file is read back and hashed.
+func newFileManifest(
+	filePath string,
+	fileName string,
+	records []event.Record,
+	stats *event.FileStats,
+	format event.FileFormat,
+	schemaVersion string,
+) (*Manifest, error) {
+	if stats != nil && stats.Checksums != nil && len(stats.Checksums.SHA256) > 0 {
+		checksum := "sha256:" + hex.EncodeToString(stats.Checksums.SHA256)
+		return NewManifest(fileName, records, stats, format, schemaVersion, checksum), nil
+	}
+
+	checksum, err := fileChecksum(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return NewManifest(fileName, records, stats, format, schemaVersion, checksum), nil
+}
+
+// objectPrefix strips the "scheme://bucket/" prefix from a routed storage path
+// and returns the remaining object key prefix.
+// Paths without the scheme prefix are returned unchanged.
+func objectPrefix(path, scheme string) string {
+	prefix := scheme + "://"
+	if !strings.HasPrefix(path, prefix) {
+		return path
+	}
+	parts := strings.SplitN(strings.TrimPrefix(path, prefix), "/", 2)
+	if len(parts) == 2 {
+		return parts[1]
+	}
+	return ""
+}
+
+// objectKey joins an object key prefix and name, dropping any leading slash.
+func objectKey(prefix, name string) string {
+	return strings.TrimPrefix(prefix+name, "/")
+}
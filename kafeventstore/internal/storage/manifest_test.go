@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jittakal/kafeventstore/pkg/event"
+)
+
+func TestNewManifest(t *testing.T) {
+	early := time.Date(2025, 12, 18, 10, 0, 0, 0, time.UTC)
+	late := time.Date(2025, 12, 18, 11, 0, 0, 0, time.UTC)
+
+	records := []event.Record{
+		{
+			Event: &event.CloudEvent{ID: "1", Time: &late},
+			Kafka: event.KafkaMetadata{Topic: "orders", Partition: 2, Offset: 101},
+		},
+		{
+			Event: &event.CloudEvent{ID: "2", Time: &early},
+			Kafka: event.KafkaMetadata{Topic: "orders", Partition: 2, Offset: 100},
+		},
+		{
+			Event: &event.CloudEvent{ID: "3"},
+			Kafka: event.KafkaMetadata{Topic: "orders", Partition: 2, Offset: 102, Timestamp: late.Add(time.Minute)},
+		},
+	}
+	stats := &event.FileStats{RecordCount: 3, SizeBytes: 2048}
+
+	m := NewManifest("events_1.parquet", records, stats, event.FormatParquet, "1", "sha256:abc")
+
+	if m.Topic != "orders" || m.Partition != 2 {
+		t.Errorf("topic/partition = %s/%d, want orders/2", m.Topic, m.Partition)
+	}
+	if m.FirstOffset != 100 || m.LastOffset != 102 {
+		t.Errorf("offsets = %d-%d, want 100-102", m.FirstOffset, m.LastOffset)
+	}
+	if m.RecordCount != 3 {
+		t.Errorf("RecordCount = %d, want 3", m.RecordCount)
+	}
+	if !m.MinEventTime.Equal(early) {
+		t.Errorf("MinEventTime = %v, want %v", m.MinEventTime, early)
+	}
+	if !m.MaxEventTime.Equal(late.Add(time.Minute)) {
+		t.Errorf("MaxEventTime = %v, want Kafka timestamp fallback %v", m.MaxEventTime, late.Add(time.Minute))
+	}
+	if m.SizeBytes != 2048 {
+		t.Errorf("SizeBytes = %d, want 2048", m.SizeBytes)
+	}
+	if m.Checksum != "sha256:abc" || m.SchemaVersion != "1" || m.Format != "parquet" {
+		t.Errorf("unexpected checksum/schema/format: %s/%s/%s", m.Checksum, m.SchemaVersion, m.Format)
+	}
+}
+
+func TestManifest_Marshal(t *testing.T) {
+	m := NewManifest("events_1.avro", nil, nil, event.FormatAvro, "1", "")
+
+	data, err := m.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("manifest is not valid JSON: %v", err)
+	}
+	for _, key := range []string{"file", "topic", "partition", "first_offset", "last_offset", "record_count",
+		"min_event_time", "max_event_time", "size_bytes", "checksum", "schema_version"} {
+		if _, ok := decoded[key]; !ok {
+			t.Errorf("manifest missing key %q", key)
+		}
+	}
+}
+
+func TestManifestName(t *testing.T) {
+	got := ManifestName("events_20251218_103000_001.parquet")
+	want := "_events_20251218_103000_001.parquet.manifest.json"
+	if got != want {
+		t.Errorf("ManifestName() = %v, want %v", got, want)
+	}
+}
+
+func TestFileChecksum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.bin")
+	if err := os.WriteFile(path, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := fileChecksum(path)
+	if err != nil {
+		t.Fatalf("fileChecksum() error = %v", err)
+	}
+	want := "sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	if got != want {
+		t.Errorf("fileChecksum() = %v, want %v", got, want)
+	}
+
+	if _, err := fileChecksum(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
+
+func TestNewFileManifest_UsesEncoderChecksums(t *testing.T) {
+	stats := &event.FileStats{
+		SizeBytes: 5,
+		Checksums: &event.Checksums{
+			CRC32C: 0x9a71bb4c,
+			MD5:    []byte{0x5d, 0x41, 0x40, 0x2a},
+			SHA256: []byte{0xde, 0xad, 0xbe, 0xef},
+		},
+	}
+
+	// The data file does not exist, so the checksum must come from stats
+	m, err := newFileManifest(filepath.Join(t.TempDir(), "missing"), "events_1.avro", nil, stats, event.FormatAvro, "1")
+	if err != nil {
+		t.Fatalf("newFileManifest() error = %v", err)
+	}
+	if m.Checksum != "sha256:deadbeef" {
+		t.Errorf("Checksum = %v, want sha256:deadbeef", m.Checksum)
+	}
+	if m.CRC32C != "mnG7TA==" {
+		t.Errorf("CRC32C = %v, want mnG7TA==", m.CRC32C)
+	}
+	if m.MD5 != "XUFAKg==" {
+		t.Errorf("MD5 = %v, want XUFAKg==", m.MD5)
+	}
+}
+
+func TestObjectPrefix(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		scheme string
+		want   string
+	}{
+		{"s3 uri", "s3://bucket/base/topic/v1/dt=2025-12-18/pid=0/", "s3", "base/topic/v1/dt=2025-12-18/pid=0/"},
+		{"empty base path", "gs://bucket//topic/v1/", "gs", "/topic/v1/"},
+		{"bucket only", "wasbs://container", "wasbs", ""},
+		{"plain key", "topic/v1/", "s3", "topic/v1/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := objectPrefix(tt.path, tt.scheme); got != tt.want {
+				t.Errorf("objectPrefix() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := objectKey("/topic/v1/", "events.parquet"); strings.HasPrefix(got, "/") {
+		t.Errorf("objectKey() = %v, want no leading slash", got)
+	}
+}
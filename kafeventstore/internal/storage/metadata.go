@@ -0,0 +1,100 @@
+// Package storage implements object metadata and tags for cloud uploads.
+package storage
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/jittakal/kafeventstore/pkg/event"
+)
+
+// Object metadata keys attached to uploaded data files.
+// Keys use underscores so they are valid Azure metadata names.
+const (
+	ObjectMetaTopic         = "kafka_topic"
+	ObjectMetaPartition     = "kafka_partition"
+	ObjectMetaOffsetMin     = "kafka_offset_min"
+	ObjectMetaOffsetMax     = "kafka_offset_max"
+	ObjectMetaRecordCount   = "record_count"
+	ObjectMetaFormat        = "format"
+	ObjectMetaSchemaVersion = "schema_version"
+)
+
+// MaxStaticObjectTags is the number of custom tags that fit in the S3 and Azure
+// limit of 10 tags per object alongside the topic and format tags.
+const MaxStaticObjectTags = 8
+
+// ObjectMetadataConfig configures the metadata and tags attached to uploaded objects.
+type ObjectMetadataConfig struct {
+	// Enabled attaches Kafka provenance metadata and tags to data files.
+	Enabled bool
+	// Tags are static custom tags applied to every data file, e.g. for
+	// lifecycle rules and cost allocation.
+	Tags map[string]string
+}
+
+// Validate validates object metadata configuration.
+func (c ObjectMetadataConfig) Validate() error {
+	if len(c.Tags) > MaxStaticObjectTags {
+		return fmt.Errorf("at most %d static object tags are supported, got %d", MaxStaticObjectTags, len(c.Tags))
+	}
+	return nil
+}
+
+// objectMetadata returns the user metadata for a data file described by manifest.
+// Static tags are included so they are visible on backends without object tags.
+func (c ObjectMetadataConfig) objectMetadata(manifest *Manifest) map[string]string {
+	if !c.Enabled || manifest == nil {
+		return nil
+	}
+
+	metadata := make(map[string]string, len(c.Tags)+7)
+	for key, value := range c.Tags {
+		metadata[key] = value
+	}
+	metadata[ObjectMetaTopic] = manifest.Topic
+	metadata[ObjectMetaPartition] = strconv.FormatInt(int64(manifest.Partition), 10)
+	metadata[ObjectMetaOffsetMin] = strconv.FormatInt(manifest.FirstOffset, 10)
+	metadata[ObjectMetaOffsetMax] = strconv.FormatInt(manifest.LastOffset, 10)
+	metadata[ObjectMetaRecordCount] = strconv.Itoa(manifest.RecordCount)
+	metadata[ObjectMetaFormat] = manifest.Format
+	metadata[ObjectMetaSchemaVersion] = manifest.SchemaVersion
+	return metadata
+}
+
+// objectTags returns the object tags for a data file described by manifest.
+// Tags are limited to the static tags plus topic and format.
+func (c ObjectMetadataConfig) objectTags(manifest *Manifest) map[string]string {
+	if !c.Enabled || manifest == nil {
+		return nil
+	}
+
+	tags := make(map[string]string, len(c.Tags)+2)
+	for key, value := range c.Tags {
+		tags[key] = value
+	}
+	tags[ObjectMetaTopic] = manifest.Topic
+	tags[ObjectMetaFormat] = manifest.Format
+	return tags
+}
+
+// encodeTagging encodes tags as an S3 URL query string ("k1=v1&k2=v2").
+func encodeTagging(tags map[string]string) string {
+	values := url.Values{}
+	for key, value := range tags {
+		values.Set(key, value)
+	}
+	return values.Encode()
+}
+
+// contentType returns the MIME type of a data file format.
+// Parquet has no widely recognized MIME type, so it is uploaded as binary.
+func contentType(format event.FileFormat) string {
+	switch format {
+	case event.FormatAvro:
+		return "application/avro"
+	default:
+		return "application/octet-stream"
+	}
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/jittakal/kafeventstore/pkg/event"
+)
+
+func testManifest() *Manifest {
+	return &Manifest{
+		Topic:         "orders",
+		Partition:     4,
+		FirstOffset:   10,
+		LastOffset:    19,
+		RecordCount:   10,
+		Format:        "parquet",
+		SchemaVersion: "1",
+	}
+}
+
+func TestObjectMetadataConfig_ObjectMetadata(t *testing.T) {
+	cfg := ObjectMetadataConfig{
+		Enabled: true,
+		Tags:    map[string]string{"cost_center": "analytics"},
+	}
+
+	metadata := cfg.objectMetadata(testManifest())
+
+	want := map[string]string{
+		ObjectMetaTopic:         "orders",
+		ObjectMetaPartition:     "4",
+		ObjectMetaOffsetMin:     "10",
+		ObjectMetaOffsetMax:     "19",
+		ObjectMetaRecordCount:   "10",
+		ObjectMetaFormat:        "parquet",
+		ObjectMetaSchemaVersion: "1",
+		"cost_center":           "analytics",
+	}
+	for key, value := range want {
+		if metadata[key] != value {
+			t.Errorf("metadata[%q] = %q, want %q", key, metadata[key], value)
+		}
+	}
+}
+
+func TestObjectMetadataConfig_ObjectTags(t *testing.T) {
+	cfg := ObjectMetadataConfig{
+		Enabled: true,
+		Tags:    map[string]string{"env": "dev"},
+	}
+
+	tags := cfg.objectTags(testManifest())
+
+	if len(tags) != 3 {
+		t.Fatalf("len(tags) = %d, want 3", len(tags))
+	}
+	if tags["env"] != "dev" || tags[ObjectMetaTopic] != "orders" || tags[ObjectMetaFormat] != "parquet" {
+		t.Errorf("unexpected tags: %v", tags)
+	}
+}
+
+func TestObjectMetadataConfig_Disabled(t *testing.T) {
+	cfg := ObjectMetadataConfig{Tags: map[string]string{"env": "dev"}}
+
+	if got := cfg.objectMetadata(testManifest()); got != nil {
+		t.Errorf("objectMetadata() = %v, want nil when disabled", got)
+	}
+	if got := cfg.objectTags(testManifest()); got != nil {
+		t.Errorf("objectTags() = %v, want nil when disabled", got)
+	}
+
+	enabled := ObjectMetadataConfig{Enabled: true}
+	if got := enabled.objectMetadata(nil); got != nil {
+		t.Errorf("objectMetadata(nil) = %v, want nil", got)
+	}
+}
+
+func TestObjectMetadataConfig_Validate(t *testing.T) {
+	tags := make(map[string]string)
+	for i := 0; i < MaxStaticObjectTags; i++ {
+		tags[string(rune('a'+i))] = "v"
+	}
+
+	if err := (ObjectMetadataConfig{Tags: tags}).Validate(); err != nil {
+		t.Errorf("Validate() with %d tags error = %v", len(tags), err)
+	}
+
+	tags["overflow"] = "v"
+	if err := (ObjectMetadataConfig{Tags: tags}).Validate(); err == nil {
+		t.Error("Validate() expected error when exceeding tag limit")
+	}
+}
+
+func TestEncodeTagging(t *testing.T) {
+	encoded := encodeTagging(map[string]string{"kafka_topic": "orders", "team": "data & ml"})
+
+	values, err := url.ParseQuery(encoded)
+	if err != nil {
+		t.Fatalf("ParseQuery() error = %v", err)
+	}
+	if values.Get("kafka_topic") != "orders" || values.Get("team") != "data & ml" {
+		t.Errorf("encodeTagging() = %v", encoded)
+	}
+}
+
+func TestContentType(t *testing.T) {
+	if got := contentType(event.FormatAvro); got != "application/avro" {
+		t.Errorf("contentType(avro) = %v", got)
+	}
+	if got := contentType(event.FormatParquet); got != "application/octet-stream" {
+		t.Errorf("contentType(parquet) = %v", got)
+	}
+}
+
+func TestAzureMetadata(t *testing.T) {
+	if got := azureMetadata(nil); got != nil {
+		t.Errorf("azureMetadata(nil) = %v, want nil", got)
+	}
+
+	got := azureMetadata(map[string]string{"a": "1", "b": "2"})
+	if len(got) != 2 || *got["a"] != "1" || *got["b"] != "2" {
+		t.Errorf("azureMetadata() returned unexpected values")
+	}
+}
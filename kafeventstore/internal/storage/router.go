@@ -3,6 +3,8 @@ package storage
 
 import (
 	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/jittakal/kafeventstore/pkg/event"
@@ -39,36 +41,116 @@ func NewRouter(protocol, bucket, basePath, version string) *DefaultRouter {
 // If specVersion is provided, it overrides the default version.
 // SpecVersion transformation: "1.0" -> "v10", "1.1" -> "v11", "2.0" -> "v20", etc.
 func (r *DefaultRouter) Route(partitionID event.PartitionID, timestamp int64, specVersion string) string {
-	t := time.Unix(timestamp, 0).UTC()
-	date := t.Format("2006-01-02")
-
-	// Use spec_version from event if provided, otherwise use default version
-	version := r.version
-	if specVersion != "" {
-		// Transform spec version: remove dots and prepend 'v'
-		// Examples: "1.0" -> "v10", "1.1" -> "v11", "2.0" -> "v20"
-		versionStr := ""
-		for _, ch := range specVersion {
-			if ch != '.' {
-				versionStr += string(ch)
-			}
-		}
-		if versionStr != "" {
-			version = "v" + versionStr
-		}
+	return r.RouteSchema(partitionID, timestamp, specVersion, "", 0)
+}
+
+// RouteType returns the storage path for the events of one type of a
+// partition, which are written to files of their own.
+// Format: protocol://bucket/basePath/topic/version/type=T/dt=YYYY-MM-DD/pid=N/
+// The type is path-escaped; versions follow Route.
+func (r *DefaultRouter) RouteType(partitionID event.PartitionID, timestamp int64, specVersion, eventType string) string {
+	return r.RouteSchema(partitionID, timestamp, specVersion, eventType, 0)
+}
+
+// RouteSchema returns the storage path for events whose data schema is at
+// the given epoch, which starts after every incompatible schema change.
+// Epochs after the first get a version of their own: "v10" -> "v10_s2".
+// A non-empty eventType routes like RouteType.
+func (r *DefaultRouter) RouteSchema(partitionID event.PartitionID, timestamp int64, specVersion, eventType string, epoch int) string {
+	date := time.Unix(timestamp, 0).UTC().Format("2006-01-02")
+
+	version := r.specVersion(specVersion)
+	if epoch > 1 {
+		version = fmt.Sprintf("%s_s%d", version, epoch)
+	}
+	typeSegment := ""
+	if eventType != "" {
+		typeSegment = "type=" + url.PathEscape(eventType) + "/"
 	}
 
-	return fmt.Sprintf("%s://%s/%s/%s/%s/dt=%s/pid=%d/",
-		r.protocol,
-		r.bucket,
-		r.basePath,
+	return fmt.Sprintf("%s%s/%s/%sdt=%s/pid=%d/",
+		r.Prefix(),
 		partitionID.Topic,
 		version,
+		typeSegment,
 		date,
 		partitionID.Partition,
 	)
 }
 
+// specVersion returns the path version of a CloudEvents spec version, or the
+// default version if it is empty.
+func (r *DefaultRouter) specVersion(specVersion string) string {
+	// Transform spec version: remove dots and prepend 'v'
+	// Examples: "1.0" -> "v10", "1.1" -> "v11", "2.0" -> "v20"
+	if versionStr := strings.ReplaceAll(specVersion, ".", ""); versionStr != "" {
+		return "v" + versionStr
+	}
+	return r.version
+}
+
+// Prefix returns the part of every routed path that precedes the topic.
+// Format: protocol://bucket/basePath/
+func (r *DefaultRouter) Prefix() string {
+	return fmt.Sprintf("%s://%s/%s/", r.protocol, r.bucket, r.basePath)
+}
+
+// QuarantinePrefix is the directory, under the base path, that holds events
+// which could not be published to the DLQ.
+const QuarantinePrefix = "_quarantine"
+
+// QuarantinePath returns the storage path for quarantined events of a partition
+// that failed for reason at the given time.
+// Format: protocol://bucket/basePath/_quarantine/topic/reason=R/dt=YYYY-MM-DD/pid=N/
+func (r *DefaultRouter) QuarantinePath(partitionID event.PartitionID, reason string, failedAt time.Time) string {
+	return fmt.Sprintf("%s%s/%s/reason=%s/dt=%s/pid=%d/",
+		r.Prefix(),
+		QuarantinePrefix,
+		partitionID.Topic,
+		reason,
+		failedAt.UTC().Format("2006-01-02"),
+		partitionID.Partition,
+	)
+}
+
+// SchemaPrefix is the directory, under the base path, that holds the schema
+// history of event types.
+const SchemaPrefix = "_schemas"
+
+// SchemaPath returns the storage path for the schema history of an event type
+// of a topic. The type is path-escaped.
+// Format: protocol://bucket/basePath/_schemas/topic/type=T/
+func (r *DefaultRouter) SchemaPath(topic, eventType string) string {
+	return fmt.Sprintf("%s%s/%s/type=%s/",
+		r.Prefix(),
+		SchemaPrefix,
+		topic,
+		url.PathEscape(eventType),
+	)
+}
+
+// CompletionPrefix is the directory, under the base path, that holds the
+// completion state of partitions.
+const CompletionPrefix = "_completion"
+
+// CompletionPath returns the storage path for the completion state of a partition.
+// Format: protocol://bucket/basePath/_completion/topic/pid=N/
+func (r *DefaultRouter) CompletionPath(partitionID event.PartitionID) string {
+	return fmt.Sprintf("%s%s/%s/pid=%d/",
+		r.Prefix(),
+		CompletionPrefix,
+		partitionID.Topic,
+		partitionID.Partition,
+	)
+}
+
+// WindowEnd returns the end of the partition window containing the given timestamp.
+// Paths are partitioned by UTC day, so the window ends at the next UTC midnight.
+func (r *DefaultRouter) WindowEnd(timestamp int64) time.Time {
+	t := time.Unix(timestamp, 0).UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1)
+}
+
 // NewPolicy creates a new rotation policy (alias for NewCompositePolicy).
 func NewPolicy(config PolicyConfig) *CompositePolicy {
 	return NewCompositePolicy(config)
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -77,6 +78,151 @@ func TestDefaultRouter_Route(t *testing.T) {
 	}
 }
 
+func TestDefaultRouter_RouteType(t *testing.T) {
+	router := NewRouter("s3", "test-bucket", "base", "v1")
+	partitionID := event.PartitionID{Topic: "test-topic", Partition: 3}
+	timestamp := time.Date(2025, 12, 18, 10, 30, 0, 0, time.UTC).Unix()
+
+	tests := []struct {
+		name        string
+		specVersion string
+		eventType   string
+		want        string
+	}{
+		{
+			name:        "default version when specVersion is empty",
+			specVersion: "",
+			eventType:   "com.library.books.issued",
+			want:        "s3://test-bucket/base/test-topic/v1/type=com.library.books.issued/dt=2025-12-18/pid=3/",
+		},
+		{
+			name:        "spec version 1.0 transforms to v10",
+			specVersion: "1.0",
+			eventType:   "com.library.books.issued",
+			want:        "s3://test-bucket/base/test-topic/v10/type=com.library.books.issued/dt=2025-12-18/pid=3/",
+		},
+		{
+			name:        "type is path-escaped",
+			specVersion: "1.0",
+			eventType:   "books/issued v2",
+			want:        "s3://test-bucket/base/test-topic/v10/type=books%2Fissued%20v2/dt=2025-12-18/pid=3/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := router.RouteType(partitionID, timestamp, tt.specVersion, tt.eventType)
+			if got != tt.want {
+				t.Errorf("RouteType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultRouter_RouteSchema(t *testing.T) {
+	router := NewRouter("s3", "test-bucket", "base", "v1")
+	partitionID := event.PartitionID{Topic: "test-topic", Partition: 3}
+	timestamp := time.Date(2025, 12, 18, 10, 30, 0, 0, time.UTC).Unix()
+
+	tests := []struct {
+		name        string
+		specVersion string
+		eventType   string
+		epoch       int
+		want        string
+	}{
+		{
+			name:        "first epoch keeps the version",
+			specVersion: "1.0",
+			epoch:       1,
+			want:        "s3://test-bucket/base/test-topic/v10/dt=2025-12-18/pid=3/",
+		},
+		{
+			name:        "later epochs get a version of their own",
+			specVersion: "1.0",
+			epoch:       2,
+			want:        "s3://test-bucket/base/test-topic/v10_s2/dt=2025-12-18/pid=3/",
+		},
+		{
+			name:        "default version",
+			specVersion: "",
+			epoch:       3,
+			want:        "s3://test-bucket/base/test-topic/v1_s3/dt=2025-12-18/pid=3/",
+		},
+		{
+			name:        "typed events",
+			specVersion: "1.0",
+			eventType:   "com.library.books.issued",
+			epoch:       2,
+			want:        "s3://test-bucket/base/test-topic/v10_s2/type=com.library.books.issued/dt=2025-12-18/pid=3/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := router.RouteSchema(partitionID, timestamp, tt.specVersion, tt.eventType, tt.epoch)
+			if got != tt.want {
+				t.Errorf("RouteSchema() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultRouter_SchemaPath(t *testing.T) {
+	router := NewRouter("s3", "test-bucket", "base", "v1")
+
+	got := router.SchemaPath("orders", "com.example/order created")
+	want := "s3://test-bucket/base/_schemas/orders/type=com.example%2Forder%20created/"
+	if got != want {
+		t.Errorf("SchemaPath() = %v, want %v", got, want)
+	}
+}
+
+func TestDefaultRouter_CompletionPath(t *testing.T) {
+	router := NewRouter("s3", "test-bucket", "base", "v1")
+
+	got := router.CompletionPath(event.PartitionID{Topic: "orders", Partition: 3})
+	want := "s3://test-bucket/base/_completion/orders/pid=3/"
+	if got != want {
+		t.Errorf("CompletionPath() = %v, want %v", got, want)
+	}
+}
+
+func TestDefaultRouter_WindowEnd(t *testing.T) {
+	router := NewRouter("s3", "test-bucket", "base", "v1")
+
+	timestamp := time.Date(2025, 12, 18, 23, 59, 59, 0, time.UTC).Unix()
+	want := time.Date(2025, 12, 19, 0, 0, 0, 0, time.UTC)
+
+	if got := router.WindowEnd(timestamp); !got.Equal(want) {
+		t.Errorf("WindowEnd() = %v, want %v", got, want)
+	}
+}
+
+func TestDefaultRouter_Prefix(t *testing.T) {
+	router := NewRouter("s3", "test-bucket", "base", "v1")
+
+	if got, want := router.Prefix(), "s3://test-bucket/base/"; got != want {
+		t.Errorf("Prefix() = %v, want %v", got, want)
+	}
+
+	path := router.Route(event.PartitionID{Topic: "orders", Partition: 1}, 0, "")
+	if !strings.HasPrefix(path, router.Prefix()) {
+		t.Errorf("Route() = %v, want prefix %v", path, router.Prefix())
+	}
+}
+
+func TestDefaultRouter_QuarantinePath(t *testing.T) {
+	router := NewRouter("s3", "test-bucket", "base", "v1")
+
+	failedAt := time.Date(2025, 12, 18, 23, 30, 0, 0, time.FixedZone("UTC-2", -2*60*60))
+	got := router.QuarantinePath(event.PartitionID{Topic: "orders", Partition: 3}, "storage_failed", failedAt)
+	want := "s3://test-bucket/base/_quarantine/orders/reason=storage_failed/dt=2025-12-19/pid=3/"
+	if got != want {
+		t.Errorf("QuarantinePath() = %v, want %v", got, want)
+	}
+}
+
 func TestNewPolicy(t *testing.T) {
 	config := PolicyConfig{
 		MaxFileSizeMB:      100,
@@ -2,12 +2,16 @@
 package storage
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"net/http"
 	"os"
 	"path/filepath"
-	"strings"
 	"sync"
 	"time"
 
@@ -18,12 +22,23 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 
 	"github.com/jittakal/kafeventstore/internal/encoder"
+	"github.com/jittakal/kafeventstore/internal/envelope"
 	"github.com/jittakal/kafeventstore/pkg/event"
 	"github.com/jittakal/kafeventstore/pkg/storage"
 )
 
+// s3PartSize is the multipart upload part size. Files smaller than one part
+// are uploaded with a single PutObject request.
+const s3PartSize = 10 * 1024 * 1024
+
 // Ensure implementation satisfies interface at compile time.
-var _ storage.Writer = (*S3Writer)(nil)
+var (
+	_ storage.Writer       = (*S3Writer)(nil)
+	_ storage.MarkerWriter = (*S3Writer)(nil)
+	_ storage.MarkerReader = (*S3Writer)(nil)
+
+	_ storage.ConditionalMarkerStore = (*S3Writer)(nil)
+)
 
 // S3Config contains AWS S3 configuration.
 type S3Config struct {
@@ -33,6 +48,12 @@ type S3Config struct {
 	UsePathStyle bool
 	SSEEnabled   bool
 	SSEKMSKeyID  string
+	Provenance   encoder.Provenance
+	Metadata     ObjectMetadataConfig
+	// TypedSchemas adds typed data columns to Parquet files of one event type.
+	TypedSchemas *encoder.TypedSchemas
+	// Encryptor encrypts files before they are uploaded; nil uploads them as encoded.
+	Encryptor *envelope.Encryptor
 }
 
 // S3Writer implements storage.Writer for AWS S3 storage.
@@ -45,7 +66,9 @@ type S3Writer struct {
 	region         string
 	sseEnabled     bool
 	sseKMSKeyID    string
+	objectMetadata ObjectMetadataConfig
 	encoderFactory *encoder.Factory
+	encryptor      *envelope.Encryptor
 	logger         *slog.Logger
 	metrics        MetricsCollector
 	mu             sync.RWMutex
@@ -59,6 +82,10 @@ func NewS3Writer(
 	logger *slog.Logger,
 	metrics MetricsCollector,
 ) (*S3Writer, error) {
+	if err := cfg.Metadata.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid object metadata config: %w", err)
+	}
+
 	// Load AWS config
 	ctx := context.Background()
 	awsConfig, err := config.LoadDefaultConfig(ctx,
@@ -78,12 +105,13 @@ func NewS3Writer(
 
 	// Create uploader with multipart upload support
 	uploader := manager.NewUploader(s3Client, func(u *manager.Uploader) {
-		u.PartSize = 10 * 1024 * 1024 // 10MB parts
-		u.Concurrency = 5             // 5 concurrent uploads
+		u.PartSize = s3PartSize // 10MB parts
+		u.Concurrency = 5       // 5 concurrent uploads
 	})
 
 	// Create encoder factory
-	encoderFactory := encoder.NewFactory(format, compression)
+	encoderFactory := encoder.NewFactory(format, compression,
+		encoder.WithProvenance(cfg.Provenance), encoder.WithTypedSchemas(cfg.TypedSchemas))
 
 	// Validate encoder can be created
 	if _, err := encoderFactory.CreateEncoder(); err != nil {
@@ -96,6 +124,7 @@ func NewS3Writer(
 		"format", format,
 		"compression", compression,
 		"sse_enabled", cfg.SSEEnabled,
+		"encrypted", cfg.Encryptor != nil,
 	)
 
 	return &S3Writer{
@@ -105,7 +134,9 @@ func NewS3Writer(
 		region:         cfg.Region,
 		sseEnabled:     cfg.SSEEnabled,
 		sseKMSKeyID:    cfg.SSEKMSKeyID,
+		objectMetadata: cfg.Metadata,
 		encoderFactory: encoderFactory,
+		encryptor:      cfg.Encryptor,
 		logger:         logger,
 		metrics:        metrics,
 	}, nil
@@ -138,25 +169,16 @@ func (w *S3Writer) Write(
 
 	// Parse S3 URI to extract key
 	// Path format: s3://bucket/key/path or just key/path
-	s3Key := path
-	if strings.HasPrefix(path, "s3://") {
-		// Remove s3:// prefix
-		pathWithoutProtocol := strings.TrimPrefix(path, "s3://")
-		// Remove bucket name (first segment)
-		parts := strings.SplitN(pathWithoutProtocol, "/", 2)
-		if len(parts) == 2 {
-			s3Key = parts[1]
-		} else {
-			s3Key = ""
-		}
-	}
+	keyPrefix := objectPrefix(path, "s3")
 
 	// Generate timestamped filename: events_YYYYMMDD_HHMMSS_NNN.{ext}
 	now := time.Now()
 	timestamp := now.Format("20060102_150405")
 	filename := fmt.Sprintf("events_%s_%03d%s", timestamp, now.Nanosecond()/1000000, fileEncoder.FileExtension())
-	s3Key = s3Key + filename
-	s3Key = strings.TrimPrefix(s3Key, "/")
+	if w.encryptor != nil {
+		filename += envelope.FileSuffix
+	}
+	s3Key := objectKey(keyPrefix, filename)
 
 	// Encode to temporary file
 	tempDir := os.TempDir()
@@ -171,6 +193,18 @@ func (w *S3Writer) Write(
 	}
 	defer os.Remove(tempFile)
 
+	// Encrypt the encoded file before it leaves the process
+	var env *envelope.Envelope
+	if w.encryptor != nil {
+		env, err = encryptFile(ctx, w.encryptor, tempFile, stats)
+		if err != nil {
+			if w.metrics != nil {
+				w.metrics.IncStorageErrors("s3", "encrypt")
+			}
+			return 0, err
+		}
+	}
+
 	// Open the file for upload
 	file, err := os.Open(tempFile)
 	if err != nil {
@@ -181,22 +215,29 @@ func (w *S3Writer) Write(
 	}
 	defer file.Close()
 
+	// Build the manifest first so its offsets can be attached to the upload
+	manifest, manifestErr := newFileManifest(tempFile, filename, records, stats, format, fileEncoder.SchemaVersion())
+	if manifest != nil {
+		manifest.Encryption = env
+	}
+
 	// Prepare upload input
 	uploadInput := &s3.PutObjectInput{
-		Bucket: aws.String(w.bucket),
-		Key:    aws.String(s3Key),
-		Body:   file,
+		Bucket:      aws.String(w.bucket),
+		Key:         aws.String(s3Key),
+		Body:        file,
+		ContentType: aws.String(encryptedContentType(format, env)),
+		Metadata:    withEnvelope(w.objectMetadata.objectMetadata(manifest), env),
+	}
+	if tags := w.objectMetadata.objectTags(manifest); len(tags) > 0 {
+		uploadInput.Tagging = aws.String(encodeTagging(tags))
 	}
 
+	// Add integrity checksums so S3 rejects corrupted uploads
+	applyS3Checksums(uploadInput, stats)
+
 	// Add SSE if enabled
-	if w.sseEnabled {
-		if w.sseKMSKeyID != "" {
-			uploadInput.ServerSideEncryption = types.ServerSideEncryptionAwsKms
-			uploadInput.SSEKMSKeyId = aws.String(w.sseKMSKeyID)
-		} else {
-			uploadInput.ServerSideEncryption = types.ServerSideEncryptionAes256
-		}
-	}
+	w.applySSE(uploadInput)
 
 	// Upload to S3
 	result, err := w.uploader.Upload(ctx, uploadInput)
@@ -207,6 +248,21 @@ func (w *S3Writer) Write(
 		return 0, fmt.Errorf("failed to upload to S3: %w", err)
 	}
 
+	// Upload sidecar manifest; failures are logged but do not fail the write
+	var manifestData []byte
+	if manifestErr == nil {
+		manifestData, manifestErr = manifest.Marshal()
+	}
+	if manifestErr == nil {
+		manifestErr = w.putObject(ctx, objectKey(keyPrefix, ManifestName(filename)), manifestData)
+	}
+	if manifestErr != nil {
+		if w.metrics != nil {
+			w.metrics.IncStorageErrors("s3", "manifest")
+		}
+		w.logger.Error("failed to write manifest", "bucket", w.bucket, "key", s3Key, "error", manifestErr)
+	}
+
 	duration := time.Since(startTime)
 
 	w.logger.Info("wrote records to S3",
@@ -232,6 +288,143 @@ func (w *S3Writer) Write(
 	return stats.SizeBytes, nil
 }
 
+// WriteMarker writes a small marker object under the given path.
+func (w *S3Writer) WriteMarker(ctx context.Context, path string, name string, data []byte) error {
+	key := objectKey(objectPrefix(path, "s3"), name)
+	if err := w.putObject(ctx, key, data); err != nil {
+		if w.metrics != nil {
+			w.metrics.IncStorageErrors("s3", "marker")
+		}
+		return err
+	}
+
+	w.logger.Debug("wrote marker object", "bucket", w.bucket, "key", key)
+	return nil
+}
+
+// ReadMarker reads a marker object under the given path.
+func (w *S3Writer) ReadMarker(ctx context.Context, path string, name string) ([]byte, error) {
+	key := objectKey(objectPrefix(path, "s3"), name)
+	output, err := w.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(w.bucket),
+		Key:    aws.String(key),
+	})
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, storage.ErrMarkerNotFound
+	}
+	if err != nil {
+		if w.metrics != nil {
+			w.metrics.IncStorageErrors("s3", "read_marker")
+		}
+		return nil, fmt.Errorf("failed to get S3 object %s: %w", key, err)
+	}
+	defer output.Body.Close()
+
+	data, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read S3 object %s: %w", key, err)
+	}
+	return data, nil
+}
+
+// ReadMarkerVersion reads a marker object with its ETag as its version.
+func (w *S3Writer) ReadMarkerVersion(ctx context.Context, path string, name string) ([]byte, string, error) {
+	key := objectKey(objectPrefix(path, "s3"), name)
+	output, err := w.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(w.bucket),
+		Key:    aws.String(key),
+	})
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, "", storage.ErrMarkerNotFound
+	}
+	if err != nil {
+		if w.metrics != nil {
+			w.metrics.IncStorageErrors("s3", "read_marker")
+		}
+		return nil, "", fmt.Errorf("failed to get S3 object %s: %w", key, err)
+	}
+	defer output.Body.Close()
+
+	data, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read S3 object %s: %w", key, err)
+	}
+	return data, aws.ToString(output.ETag), nil
+}
+
+// WriteMarkerIf writes a marker object with an If-Match precondition on its
+// ETag, or If-None-Match for a new object.
+func (w *S3Writer) WriteMarkerIf(ctx context.Context, path string, name string, data []byte, version string) (string, error) {
+	key := objectKey(objectPrefix(path, "s3"), name)
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(w.bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	}
+	if version == "" {
+		input.IfNoneMatch = aws.String("*")
+	} else {
+		input.IfMatch = aws.String(version)
+	}
+	w.applySSE(input)
+
+	output, err := w.client.PutObject(ctx, input)
+	var statusErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &statusErr) && (statusErr.HTTPStatusCode() == http.StatusPreconditionFailed || statusErr.HTTPStatusCode() == http.StatusConflict) {
+		return "", storage.ErrMarkerConflict
+	}
+	if err != nil {
+		if w.metrics != nil {
+			w.metrics.IncStorageErrors("s3", "marker")
+		}
+		return "", fmt.Errorf("failed to put S3 object %s: %w", key, err)
+	}
+	return aws.ToString(output.ETag), nil
+}
+
+// putObject uploads a small in-memory object to the bucket.
+func (w *S3Writer) putObject(ctx context.Context, key string, data []byte) error {
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(w.bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	}
+	w.applySSE(input)
+
+	if _, err := w.client.PutObject(ctx, input); err != nil {
+		return fmt.Errorf("failed to put S3 object %s: %w", key, err)
+	}
+	return nil
+}
+
+// applyS3Checksums sets the checksums S3 validates an upload against.
+// Single-part uploads carry the CRC32C and Content-MD5 computed by the encoder.
+// Multipart uploads only accept per-part checksums, so the SDK is asked to
+// compute a CRC32C for every part instead.
+func applyS3Checksums(input *s3.PutObjectInput, stats *event.FileStats) {
+	input.ChecksumAlgorithm = types.ChecksumAlgorithmCrc32c
+	if stats == nil || stats.Checksums == nil || stats.SizeBytes >= s3PartSize {
+		return
+	}
+	input.ChecksumCRC32C = aws.String(base64CRC32C(stats.Checksums.CRC32C))
+	input.ContentMD5 = aws.String(base64.StdEncoding.EncodeToString(stats.Checksums.MD5))
+}
+
+// applySSE sets server-side encryption on an upload if enabled.
+func (w *S3Writer) applySSE(input *s3.PutObjectInput) {
+	if !w.sseEnabled {
+		return
+	}
+	if w.sseKMSKeyID != "" {
+		input.ServerSideEncryption = types.ServerSideEncryptionAwsKms
+		input.SSEKMSKeyId = aws.String(w.sseKMSKeyID)
+	} else {
+		input.ServerSideEncryption = types.ServerSideEncryptionAes256
+	}
+}
+
 // Close closes the S3 writer.
 func (w *S3Writer) Close() error {
 	w.logger.Info("closing S3 writer")
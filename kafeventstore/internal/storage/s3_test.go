@@ -6,6 +6,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
 	"github.com/jittakal/kafeventstore/pkg/event"
 )
 
@@ -208,6 +212,49 @@ func TestS3Writer_SSE(t *testing.T) {
 	}
 }
 
+func TestApplyS3Checksums(t *testing.T) {
+	checksums := &event.Checksums{CRC32C: 0x9a71bb4c, MD5: []byte{0x5d, 0x41, 0x40, 0x2a}}
+
+	tests := []struct {
+		name       string
+		stats      *event.FileStats
+		wantCRC32C string
+		wantMD5    string
+	}{
+		{
+			name:       "single part upload carries precomputed checksums",
+			stats:      &event.FileStats{SizeBytes: 1024, Checksums: checksums},
+			wantCRC32C: "mnG7TA==",
+			wantMD5:    "XUFAKg==",
+		},
+		{
+			name:  "multipart upload uses per-part checksums",
+			stats: &event.FileStats{SizeBytes: s3PartSize, Checksums: checksums},
+		},
+		{
+			name:  "no encoder checksums",
+			stats: &event.FileStats{SizeBytes: 1024},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := &s3.PutObjectInput{}
+			applyS3Checksums(input, tt.stats)
+
+			if input.ChecksumAlgorithm != types.ChecksumAlgorithmCrc32c {
+				t.Errorf("ChecksumAlgorithm = %v, want CRC32C", input.ChecksumAlgorithm)
+			}
+			if got := aws.ToString(input.ChecksumCRC32C); got != tt.wantCRC32C {
+				t.Errorf("ChecksumCRC32C = %q, want %q", got, tt.wantCRC32C)
+			}
+			if got := aws.ToString(input.ContentMD5); got != tt.wantMD5 {
+				t.Errorf("ContentMD5 = %q, want %q", got, tt.wantMD5)
+			}
+		})
+	}
+}
+
 func TestS3Writer_PathStyle(t *testing.T) {
 	tests := []struct {
 		name         string
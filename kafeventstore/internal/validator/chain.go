@@ -0,0 +1,91 @@
+// Package validator implements a validation chain for CloudEvents.
+package validator
+
+import (
+	"context"
+	stderrors "errors"
+
+	"github.com/jittakal/kafeventstore/internal/errors"
+	"github.com/jittakal/kafeventstore/pkg/event"
+)
+
+// MetricsCollector defines metrics operations for event validation.
+type MetricsCollector interface {
+	IncValidationFailures(field string, reason string)
+}
+
+// ContextValidator is a validator whose checks do I/O bounded by a context,
+// such as loading a schema.
+type ContextValidator interface {
+	ValidateContext(ctx context.Context, e *event.CloudEvent) error
+}
+
+// Chain runs validators in order and stops at the first rejection.
+type Chain struct {
+	validators []event.Validator
+	metrics    MetricsCollector
+}
+
+// NewChain creates a validation chain of validators.
+func NewChain(metrics MetricsCollector, validators ...event.Validator) *Chain {
+	return &Chain{validators: validators, metrics: metrics}
+}
+
+// NewRulesChain creates a chain of the CloudEvents specification checks
+// followed by the enabled rules.
+func NewRulesChain(rules Rules, metrics MetricsCollector) *Chain {
+	validators := []event.Validator{NewCloudEventsValidator()}
+	if len(rules.AllowedSources) > 0 || len(rules.DeniedSources) > 0 {
+		validators = append(validators, NewSourceValidator(rules.AllowedSources, rules.DeniedSources))
+	}
+	if len(rules.AllowedTypes) > 0 || len(rules.DeniedTypes) > 0 {
+		validators = append(validators, NewTypeValidator(rules.AllowedTypes, rules.DeniedTypes))
+	}
+	if len(rules.RequiredExtensions) > 0 {
+		validators = append(validators, NewExtensionsValidator(rules.RequiredExtensions))
+	}
+	if rules.MaxDataBytes > 0 {
+		validators = append(validators, NewDataSizeValidator(rules.MaxDataBytes))
+	}
+	if rules.MaxPastSkew > 0 || rules.MaxFutureSkew > 0 {
+		validators = append(validators, NewTimeSkewValidator(rules.MaxPastSkew, rules.MaxFutureSkew))
+	}
+	if rules.Schemas != nil {
+		validators = append(validators, NewSchemaValidator(rules.Schemas))
+	}
+	return NewChain(metrics, validators...)
+}
+
+// Validate returns the first rejection of the chain and counts it by field
+// and reason. Errors other than ValidationError are counted as field "event"
+// with reason "invalid".
+func (c *Chain) Validate(e *event.CloudEvent) error {
+	return c.ValidateContext(context.Background(), e)
+}
+
+// ValidateContext is Validate passing ctx to the validators that take one.
+func (c *Chain) ValidateContext(ctx context.Context, e *event.CloudEvent) error {
+	for _, validator := range c.validators {
+		var err error
+		if contextValidator, ok := validator.(ContextValidator); ok {
+			err = contextValidator.ValidateContext(ctx, e)
+		} else {
+			err = validator.Validate(e)
+		}
+		if err != nil {
+			if c.metrics != nil {
+				field, reason := "event", "invalid"
+				var validationErr *errors.ValidationError
+				if stderrors.As(err, &validationErr) {
+					field = validationErr.Field
+					if validationErr.Code != "" {
+						reason = validationErr.Code
+					}
+				}
+				c.metrics.IncValidationFailures(field, reason)
+			}
+			return err
+		}
+	}
+	return nil
+}
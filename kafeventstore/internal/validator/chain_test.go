@@ -0,0 +1,132 @@
+package validator
+
+import (
+	stderrors "errors"
+	"testing"
+	"time"
+
+	"github.com/jittakal/kafeventstore/pkg/event"
+)
+
+type fakeValidationMetrics struct {
+	failures map[string]int
+}
+
+func (m *fakeValidationMetrics) IncValidationFailures(field, reason string) {
+	if m.failures == nil {
+		m.failures = make(map[string]int)
+	}
+	m.failures[field+"/"+reason]++
+}
+
+type validatorFunc func(*event.CloudEvent) error
+
+func (f validatorFunc) Validate(e *event.CloudEvent) error { return f(e) }
+
+func TestChain_Validate(t *testing.T) {
+	metrics := &fakeValidationMetrics{}
+	var calls int
+	counting := validatorFunc(func(*event.CloudEvent) error {
+		calls++
+		return nil
+	})
+	failing := validatorFunc(func(*event.CloudEvent) error { return stderrors.New("boom") })
+
+	chain := NewChain(metrics, counting, NewDataSizeValidator(2), counting)
+	if err := chain.Validate(&event.CloudEvent{Data: []byte("12")}); err != nil {
+		t.Fatalf("Validate() error = %v, want nil", err)
+	}
+	if calls != 2 {
+		t.Errorf("validators called %d times, want 2", calls)
+	}
+
+	// The chain stops at the first rejection
+	calls = 0
+	if err := chain.Validate(&event.CloudEvent{Data: []byte("123")}); err == nil {
+		t.Fatal("Validate() = nil, want error")
+	}
+	if calls != 1 {
+		t.Errorf("validators called %d times, want 1", calls)
+	}
+
+	if err := NewChain(metrics, failing).Validate(&event.CloudEvent{}); err == nil {
+		t.Fatal("Validate() = nil, want error")
+	}
+
+	want := map[string]int{"data/" + CodeTooLarge: 1, "event/invalid": 1}
+	for key, count := range want {
+		if metrics.failures[key] != count {
+			t.Errorf("failures[%s] = %d, want %d", key, metrics.failures[key], count)
+		}
+	}
+
+	// Metrics are optional
+	if err := NewChain(nil, failing).Validate(&event.CloudEvent{}); err == nil {
+		t.Error("Validate() without metrics = nil, want error")
+	}
+}
+
+func TestNewRulesChain(t *testing.T) {
+	now := time.Now()
+	valid := func() *event.CloudEvent {
+		return &event.CloudEvent{
+			ID:          "evt-1",
+			Source:      "/billing/invoices",
+			SpecVersion: "1.0",
+			Type:        "invoice.created",
+			Time:        &now,
+			Data:        []byte(`{}`),
+			Extensions:  map[string]interface{}{"tenant": "acme"},
+		}
+	}
+	rules := Rules{
+		AllowedSources:     []string{"/billing/*"},
+		DeniedTypes:        []string{"debug.*"},
+		RequiredExtensions: []string{"tenant"},
+		MaxDataBytes:       16,
+		MaxPastSkew:        time.Hour,
+		MaxFutureSkew:      time.Minute,
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(*event.CloudEvent)
+		field  string
+		code   string
+	}{
+		{"valid", func(*event.CloudEvent) {}, "", ""},
+		{"spec violation", func(e *event.CloudEvent) { e.ID = "" }, "id", CodeMissing},
+		{"source not allowed", func(e *event.CloudEvent) { e.Source = "/orders" }, "source", CodeNotAllowed},
+		{"type denied", func(e *event.CloudEvent) { e.Type = "debug.ping" }, "type", CodeDenied},
+		{"extension missing", func(e *event.CloudEvent) { e.Extensions = nil }, "tenant", CodeMissing},
+		{"data too large", func(e *event.CloudEvent) { e.Data = []byte(`{"amount": 100000}`) }, "data", CodeTooLarge},
+		{"time too old", func(e *event.CloudEvent) {
+			old := now.Add(-2 * time.Hour)
+			e.Time = &old
+		}, "time", CodeTooOld},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := &fakeValidationMetrics{}
+			cloudEvent := valid()
+			tt.mutate(cloudEvent)
+
+			err := NewRulesChain(rules, metrics).Validate(cloudEvent)
+			if got := validationCode(t, err); got != tt.code {
+				t.Errorf("Validate() code = %q, want %q", got, tt.code)
+			}
+			if tt.code != "" && metrics.failures[tt.field+"/"+tt.code] != 1 {
+				t.Errorf("failures = %v, want %s/%s", metrics.failures, tt.field, tt.code)
+			}
+		})
+	}
+
+	// Without rules only the specification is checked
+	cloudEvent := valid()
+	cloudEvent.Source = "/orders"
+	cloudEvent.Extensions = nil
+	if err := NewRulesChain(Rules{}, nil).Validate(cloudEvent); err != nil {
+		t.Errorf("Validate() without rules error = %v, want nil", err)
+	}
+}
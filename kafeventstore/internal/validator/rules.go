@@ -0,0 +1,204 @@
+// Package validator implements configurable validation rules for CloudEvents.
+package validator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jittakal/kafeventstore/internal/errors"
+	"github.com/jittakal/kafeventstore/internal/schema"
+	"github.com/jittakal/kafeventstore/pkg/event"
+)
+
+// Rules configures the validation rules applied after the specification checks.
+// Zero values disable a rule.
+type Rules struct {
+	AllowedSources     []string
+	DeniedSources      []string
+	AllowedTypes       []string
+	DeniedTypes        []string
+	RequiredExtensions []string
+	// MaxDataBytes limits the size of the event data.
+	MaxDataBytes int
+	// MaxPastSkew and MaxFutureSkew bound how far the event time may be
+	// before or after the current time.
+	MaxPastSkew   time.Duration
+	MaxFutureSkew time.Duration
+	// Schemas validates event data against JSON Schemas when set.
+	Schemas *schema.Registry
+}
+
+// ListValidator checks an attribute against allow and deny lists. Entries
+// ending in * match by prefix; all others must match exactly.
+type ListValidator struct {
+	field     string
+	attribute func(*event.CloudEvent) string
+	allow     []string
+	deny      []string
+}
+
+// NewSourceValidator creates a validator of the source attribute.
+func NewSourceValidator(allow, deny []string) *ListValidator {
+	return &ListValidator{
+		field:     "source",
+		attribute: func(e *event.CloudEvent) string { return e.Source },
+		allow:     allow,
+		deny:      deny,
+	}
+}
+
+// NewTypeValidator creates a validator of the type attribute.
+func NewTypeValidator(allow, deny []string) *ListValidator {
+	return &ListValidator{
+		field:     "type",
+		attribute: func(e *event.CloudEvent) string { return e.Type },
+		allow:     allow,
+		deny:      deny,
+	}
+}
+
+// Validate rejects events whose attribute is denied, or not allowed when an
+// allow list is set. Deny entries take precedence.
+func (v *ListValidator) Validate(e *event.CloudEvent) error {
+	value := v.attribute(e)
+	if matchAny(v.deny, value) {
+		return &errors.ValidationError{
+			EventID: e.ID,
+			Field:   v.field,
+			Reason:  fmt.Sprintf("%s is denied: %s", v.field, value),
+			Code:    CodeDenied,
+		}
+	}
+	if len(v.allow) > 0 && !matchAny(v.allow, value) {
+		return &errors.ValidationError{
+			EventID: e.ID,
+			Field:   v.field,
+			Reason:  fmt.Sprintf("%s is not allowed: %s", v.field, value),
+			Code:    CodeNotAllowed,
+		}
+	}
+	return nil
+}
+
+// matchAny reports whether value matches any pattern.
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(value, prefix) {
+				return true
+			}
+		} else if pattern == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtensionsValidator requires extension attributes to be present.
+type ExtensionsValidator struct {
+	required []string
+}
+
+// NewExtensionsValidator creates a validator requiring the named extensions.
+func NewExtensionsValidator(required []string) *ExtensionsValidator {
+	return &ExtensionsValidator{required: required}
+}
+
+// Validate rejects events missing a required extension.
+func (v *ExtensionsValidator) Validate(e *event.CloudEvent) error {
+	for _, name := range v.required {
+		if value, ok := e.Extensions[name]; !ok || value == nil {
+			return &errors.ValidationError{
+				EventID: e.ID,
+				Field:   name,
+				Reason:  "required extension is missing",
+				Code:    CodeMissing,
+			}
+		}
+	}
+	return nil
+}
+
+// DataSizeValidator limits the size of the event data.
+type DataSizeValidator struct {
+	maxBytes int
+}
+
+// NewDataSizeValidator creates a validator rejecting data over maxBytes.
+func NewDataSizeValidator(maxBytes int) *DataSizeValidator {
+	return &DataSizeValidator{maxBytes: maxBytes}
+}
+
+// Validate rejects events whose data exceeds the limit.
+func (v *DataSizeValidator) Validate(e *event.CloudEvent) error {
+	if len(e.Data) > v.maxBytes {
+		return &errors.ValidationError{
+			EventID: e.ID,
+			Field:   "data",
+			Reason:  fmt.Sprintf("data is %d bytes (max %d)", len(e.Data), v.maxBytes),
+			Code:    CodeTooLarge,
+		}
+	}
+	return nil
+}
+
+// referenceTimeKey is the context key of the time events are checked against.
+type referenceTimeKey struct{}
+
+// WithReferenceTime returns a context in which events are checked for time
+// skew against t instead of the current time, e.g. the time a redriven event
+// was first consumed.
+func WithReferenceTime(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, referenceTimeKey{}, t)
+}
+
+// TimeSkewValidator bounds the event time around the current time, or the
+// reference time of the context. Events without a time pass.
+type TimeSkewValidator struct {
+	maxPast   time.Duration
+	maxFuture time.Duration
+	now       func() time.Time
+}
+
+// NewTimeSkewValidator creates a validator allowing event times up to maxPast
+// before and maxFuture after now. A zero bound is not checked.
+func NewTimeSkewValidator(maxPast, maxFuture time.Duration) *TimeSkewValidator {
+	return &TimeSkewValidator{maxPast: maxPast, maxFuture: maxFuture, now: time.Now}
+}
+
+// Validate rejects events whose time is outside the window.
+func (v *TimeSkewValidator) Validate(e *event.CloudEvent) error {
+	return v.ValidateContext(context.Background(), e)
+}
+
+// ValidateContext rejects events whose time is outside the window around
+// the reference time of ctx, or the current time without one.
+func (v *TimeSkewValidator) ValidateContext(ctx context.Context, e *event.CloudEvent) error {
+	if e.Time == nil {
+		return nil
+	}
+	reference, ok := ctx.Value(referenceTimeKey{}).(time.Time)
+	if !ok || reference.IsZero() {
+		reference = v.now()
+	}
+	skew := e.Time.Sub(reference)
+	if v.maxPast > 0 && -skew > v.maxPast {
+		return &errors.ValidationError{
+			EventID: e.ID,
+			Field:   "time",
+			Reason:  fmt.Sprintf("time is %s in the past (max %s)", -skew, v.maxPast),
+			Code:    CodeTooOld,
+		}
+	}
+	if v.maxFuture > 0 && skew > v.maxFuture {
+		return &errors.ValidationError{
+			EventID: e.ID,
+			Field:   "time",
+			Reason:  fmt.Sprintf("time is %s in the future (max %s)", skew, v.maxFuture),
+			Code:    CodeInFuture,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package validator
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+	"time"
+
+	"github.com/jittakal/kafeventstore/internal/errors"
+	"github.com/jittakal/kafeventstore/pkg/event"
+)
+
+// validationCode returns the code of a validation error, or "" for nil.
+func validationCode(t *testing.T, err error) string {
+	t.Helper()
+
+	if err == nil {
+		return ""
+	}
+	var validationErr *errors.ValidationError
+	if !stderrors.As(err, &validationErr) {
+		t.Fatalf("error = %v, want ValidationError", err)
+	}
+	return validationErr.Code
+}
+
+func TestListValidator_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		allow  []string
+		deny   []string
+		source string
+		want   string
+	}{
+		{"no lists", nil, nil, "orders", ""},
+		{"allowed exactly", []string{"orders"}, nil, "orders", ""},
+		{"allowed by prefix", []string{"/billing/*"}, nil, "/billing/invoices", ""},
+		{"not allowed", []string{"orders", "/billing/*"}, nil, "payments", CodeNotAllowed},
+		{"denied exactly", nil, []string{"test"}, "test", CodeDenied},
+		{"denied by prefix", nil, []string{"/internal/*"}, "/internal/jobs", CodeDenied},
+		{"deny takes precedence", []string{"/internal/*"}, []string{"/internal/debug"}, "/internal/debug", CodeDenied},
+		{"prefix without star is exact", []string{"/billing/"}, nil, "/billing/invoices", CodeNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewSourceValidator(tt.allow, tt.deny).Validate(&event.CloudEvent{ID: "evt-1", Source: tt.source})
+			if got := validationCode(t, err); got != tt.want {
+				t.Errorf("Validate() code = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	err := NewTypeValidator(nil, []string{"debug.*"}).Validate(&event.CloudEvent{ID: "evt-1", Type: "debug.ping"})
+	var validationErr *errors.ValidationError
+	if !stderrors.As(err, &validationErr) || validationErr.Field != "type" {
+		t.Errorf("type Validate() = %v, want type rejection", err)
+	}
+}
+
+func TestExtensionsValidator_Validate(t *testing.T) {
+	validator := NewExtensionsValidator([]string{"tenant", "traceparent"})
+
+	if err := validator.Validate(&event.CloudEvent{Extensions: map[string]interface{}{"tenant": "acme", "traceparent": "00-abc"}}); err != nil {
+		t.Errorf("Validate() error = %v, want nil", err)
+	}
+
+	err := validator.Validate(&event.CloudEvent{Extensions: map[string]interface{}{"tenant": "acme"}})
+	var validationErr *errors.ValidationError
+	if !stderrors.As(err, &validationErr) || validationErr.Field != "traceparent" || validationErr.Code != CodeMissing {
+		t.Errorf("Validate() = %v, want missing traceparent", err)
+	}
+
+	if err := validator.Validate(&event.CloudEvent{}); err == nil {
+		t.Error("Validate() without extensions = nil, want error")
+	}
+}
+
+func TestDataSizeValidator_Validate(t *testing.T) {
+	validator := NewDataSizeValidator(4)
+
+	if err := validator.Validate(&event.CloudEvent{Data: []byte("1234")}); err != nil {
+		t.Errorf("Validate() at limit error = %v, want nil", err)
+	}
+	if got := validationCode(t, validator.Validate(&event.CloudEvent{Data: []byte("12345")})); got != CodeTooLarge {
+		t.Errorf("Validate() over limit code = %q, want %q", got, CodeTooLarge)
+	}
+}
+
+func TestTimeSkewValidator_Validate(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		eventTime := now.Add(d)
+		return &eventTime
+	}
+
+	tests := []struct {
+		name      string
+		maxPast   time.Duration
+		maxFuture time.Duration
+		time      *time.Time
+		want      string
+	}{
+		{"no time", time.Hour, time.Minute, nil, ""},
+		{"within window", time.Hour, time.Minute, at(-30 * time.Minute), ""},
+		{"too old", time.Hour, time.Minute, at(-2 * time.Hour), CodeTooOld},
+		{"in future", time.Hour, time.Minute, at(5 * time.Minute), CodeInFuture},
+		{"past unbounded", 0, time.Minute, at(-24 * time.Hour), ""},
+		{"future unbounded", time.Hour, 0, at(24 * time.Hour), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator := NewTimeSkewValidator(tt.maxPast, tt.maxFuture)
+			validator.now = func() time.Time { return now }
+
+			if got := validationCode(t, validator.Validate(&event.CloudEvent{Time: tt.time})); got != tt.want {
+				t.Errorf("Validate() code = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimeSkewValidator_ValidateContext(t *testing.T) {
+	consumedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	eventTime := consumedAt.Add(-time.Minute)
+	chain := NewRulesChain(Rules{MaxPastSkew: time.Hour, MaxFutureSkew: time.Minute}, nil)
+	cloudEvent := &event.CloudEvent{ID: "1", Source: "shop", SpecVersion: "1.0", Type: "order.created", Time: &eventTime}
+
+	// A redriven event is checked against the time it was first consumed,
+	// however long it stayed in the DLQ
+	if err := chain.ValidateContext(WithReferenceTime(context.Background(), consumedAt), cloudEvent); err != nil {
+		t.Errorf("ValidateContext() error = %v, want the redriven event accepted", err)
+	}
+	if got := validationCode(t, chain.ValidateContext(context.Background(), cloudEvent)); got != CodeTooOld {
+		t.Errorf("ValidateContext() code = %q without a reference time, want %q", got, CodeTooOld)
+	}
+	if got := validationCode(t, chain.ValidateContext(WithReferenceTime(context.Background(), consumedAt.Add(-2*time.Hour)), cloudEvent)); got != CodeInFuture {
+		t.Errorf("ValidateContext() code = %q, want %q", got, CodeInFuture)
+	}
+}
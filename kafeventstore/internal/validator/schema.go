@@ -0,0 +1,110 @@
+// Package validator implements JSON Schema validation of event data.
+package validator
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jittakal/kafeventstore/internal/errors"
+	"github.com/jittakal/kafeventstore/internal/schema"
+	"github.com/jittakal/kafeventstore/pkg/event"
+)
+
+// schemaFetchTimeout bounds loading a schema that is not cached yet, within
+// the deadline of the caller.
+const schemaFetchTimeout = 30 * time.Second
+
+// SchemaValidator validates the data of events against the JSON Schema of
+// their dataschema or type. Events without a schema or with non-JSON data
+// pass.
+type SchemaValidator struct {
+	registry *schema.Registry
+}
+
+// NewSchemaValidator creates a validator of the schemas of registry.
+func NewSchemaValidator(registry *schema.Registry) *SchemaValidator {
+	return &SchemaValidator{registry: registry}
+}
+
+// Validate rejects events whose schema cannot be loaded or whose data does
+// not match it. Violations carry the JSON pointer of the violating value.
+func (v *SchemaValidator) Validate(e *event.CloudEvent) error {
+	return v.ValidateContext(context.Background(), e)
+}
+
+// ValidateContext is Validate loading the schema within ctx. Schemas that
+// cannot be loaded are rejected with CodeSchemaUnavailable, which
+// IsSchemaUnavailable reports; the event itself may be valid.
+func (v *SchemaValidator) ValidateContext(ctx context.Context, e *event.CloudEvent) error {
+	ref := v.registry.Ref(e)
+	if ref == "" || len(e.Data) == 0 || !isJSONContentType(e.DataContentType) {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, schemaFetchTimeout)
+	defer cancel()
+
+	s, err := v.registry.Get(ctx, ref)
+	if err != nil {
+		return &errors.ValidationError{
+			EventID: e.ID,
+			Field:   "dataschema",
+			Reason:  fmt.Sprintf("schema is unavailable: %v", err),
+			Code:    CodeSchemaUnavailable,
+		}
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(e.Data, &data); err != nil {
+		return &errors.ValidationError{
+			EventID: e.ID,
+			Field:   "data",
+			Reason:  fmt.Sprintf("data is not valid JSON: %v", err),
+			Code:    CodeSchemaViolation,
+		}
+	}
+	if violation := s.Validate(data); violation != nil {
+		return &errors.ValidationError{
+			EventID: e.ID,
+			Field:   "data",
+			Reason:  fmt.Sprintf("data does not match schema %s: %v", ref, violation),
+			Code:    CodeSchemaViolation,
+			Pointer: violation.Pointer,
+		}
+	}
+	return nil
+}
+
+// isJSONContentType reports whether data of the content type is JSON.
+// Events without a content type hold JSON data.
+func isJSONContentType(contentType *string) bool {
+	if contentType == nil || *contentType == "" {
+		return true
+	}
+	mediaType, _, _ := strings.Cut(strings.ToLower(*contentType), ";")
+	mediaType = strings.TrimSpace(mediaType)
+	return mediaType == "application/json" || mediaType == "text/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// IsSchemaError reports whether err is a rejection of event data by the
+// schema validator.
+func IsSchemaError(err error) bool {
+	return hasCode(err, CodeSchemaViolation)
+}
+
+// IsSchemaUnavailable reports whether err is a failure to load the schema of
+// an event. Such failures are transient; the event should be retried rather
+// than rejected.
+func IsSchemaUnavailable(err error) bool {
+	return hasCode(err, CodeSchemaUnavailable)
+}
+
+// hasCode reports whether err is a validation error with code.
+func hasCode(err error, code string) bool {
+	var validationErr *errors.ValidationError
+	return stderrors.As(err, &validationErr) && validationErr.Code == code
+}
@@ -0,0 +1,100 @@
+package validator
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+
+	"github.com/jittakal/kafeventstore/internal/errors"
+	"github.com/jittakal/kafeventstore/internal/schema"
+	"github.com/jittakal/kafeventstore/pkg/event"
+)
+
+// mapFetcher serves schema documents by reference.
+type mapFetcher map[string]string
+
+func (f mapFetcher) Fetch(_ context.Context, ref string) ([]byte, error) {
+	document, ok := f[ref]
+	if !ok {
+		return nil, stderrors.New("schema not found")
+	}
+	return []byte(document), nil
+}
+
+func TestSchemaValidator_Validate(t *testing.T) {
+	registry := schema.NewRegistry(mapFetcher{
+		"orders/v1.json": `{"type": "object", "required": ["id"], "properties": {"amount": {"type": "number", "minimum": 0}}}`,
+	}, map[string]string{"order.created": "orders/v1.json", "order.deleted": "orders/missing.json"})
+	validator := NewSchemaValidator(registry)
+
+	text := "text/plain"
+	cloudEventsJSON := "application/cloudevents+json; charset=utf-8"
+	dataSchema := "orders/v1.json"
+
+	tests := []struct {
+		name    string
+		event   *event.CloudEvent
+		code    string
+		pointer string
+	}{
+		{name: "valid", event: &event.CloudEvent{Type: "order.created", Data: []byte(`{"id": "1", "amount": 5}`)}},
+		{name: "no schema", event: &event.CloudEvent{Type: "order.shipped", Data: []byte(`[]`)}},
+		{name: "no data", event: &event.CloudEvent{Type: "order.created"}},
+		{name: "not JSON content", event: &event.CloudEvent{Type: "order.created", DataContentType: &text, Data: []byte(`[]`)}},
+		{name: "dataschema", event: &event.CloudEvent{Type: "other", DataSchema: &dataSchema, Data: []byte(`{}`)}, code: CodeSchemaViolation, pointer: "/id"},
+		{name: "violation", event: &event.CloudEvent{Type: "order.created", DataContentType: &cloudEventsJSON, Data: []byte(`{"id": "1", "amount": -1}`)}, code: CodeSchemaViolation, pointer: "/amount"},
+		{name: "invalid JSON", event: &event.CloudEvent{Type: "order.created", Data: []byte(`{`)}, code: CodeSchemaViolation},
+		{name: "unavailable schema", event: &event.CloudEvent{Type: "order.deleted", Data: []byte(`{}`)}, code: CodeSchemaUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validator.Validate(tt.event)
+			if got := validationCode(t, err); got != tt.code {
+				t.Fatalf("Validate() code = %q, want %q (%v)", got, tt.code, err)
+			}
+			if err == nil {
+				return
+			}
+			if got := IsSchemaError(err); got != (tt.code == CodeSchemaViolation) {
+				t.Errorf("IsSchemaError(%v) = %v", err, got)
+			}
+			if got := IsSchemaUnavailable(err); got != (tt.code == CodeSchemaUnavailable) {
+				t.Errorf("IsSchemaUnavailable(%v) = %v", err, got)
+			}
+			var validationErr *errors.ValidationError
+			if stderrors.As(err, &validationErr) && validationErr.Pointer != tt.pointer {
+				t.Errorf("Validate() pointer = %q, want %q", validationErr.Pointer, tt.pointer)
+			}
+		})
+	}
+
+	if IsSchemaError(NewDataSizeValidator(0).Validate(&event.CloudEvent{Data: []byte("1")})) {
+		t.Error("IsSchemaError() of a size rejection = true, want false")
+	}
+}
+
+// contextFetcher fails with the error of the context it is given.
+type contextFetcher struct{}
+
+func (contextFetcher) Fetch(ctx context.Context, _ string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return []byte(`{"type": "object"}`), nil
+}
+
+func TestSchemaValidator_ValidateContext(t *testing.T) {
+	registry := schema.NewRegistry(contextFetcher{}, map[string]string{"order.created": "orders/v1.json"})
+	chain := NewChain(nil, NewSchemaValidator(registry))
+	evt := &event.CloudEvent{Type: "order.created", Data: []byte(`{}`)}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := chain.ValidateContext(cancelled, evt); !IsSchemaUnavailable(err) {
+		t.Errorf("ValidateContext() with a cancelled context error = %v, want unavailable schema", err)
+	}
+	if err := chain.ValidateContext(context.Background(), evt); err != nil {
+		t.Errorf("ValidateContext() error = %v, want nil", err)
+	}
+}
@@ -8,6 +8,35 @@ import (
 	"github.com/jittakal/kafeventstore/pkg/event"
 )
 
+// Ensure implementations satisfy interfaces.
+var (
+	_ event.Validator = (*CloudEventsValidator)(nil)
+	_ event.Validator = (*Chain)(nil)
+	_ event.Validator = (*ListValidator)(nil)
+	_ event.Validator = (*ExtensionsValidator)(nil)
+	_ event.Validator = (*DataSizeValidator)(nil)
+	_ event.Validator = (*TimeSkewValidator)(nil)
+	_ event.Validator = (*SchemaValidator)(nil)
+
+	_ ContextValidator = (*Chain)(nil)
+	_ ContextValidator = (*SchemaValidator)(nil)
+	_ ContextValidator = (*TimeSkewValidator)(nil)
+)
+
+// Codes of validation errors, used as the reason label of rejection metrics.
+const (
+	CodeMissing     = "missing"
+	CodeUnsupported = "unsupported"
+	CodeNotAllowed  = "not_allowed"
+	CodeDenied      = "denied"
+	CodeTooLarge    = "too_large"
+	CodeTooOld      = "too_old"
+	CodeInFuture    = "in_future"
+
+	CodeSchemaViolation   = "schema_violation"
+	CodeSchemaUnavailable = "schema_unavailable"
+)
+
 // CloudEventsValidator validates CloudEvents according to the specification.
 type CloudEventsValidator struct{}
 
@@ -18,11 +47,20 @@ func NewCloudEventsValidator() *CloudEventsValidator {
 
 // Validate validates a CloudEvent.
 func (v *CloudEventsValidator) Validate(e *event.CloudEvent) error {
+	if e == nil {
+		return &errors.ValidationError{
+			Field:  "event",
+			Reason: "event is nil",
+			Code:   CodeMissing,
+		}
+	}
+
 	if e.ID == "" {
 		return &errors.ValidationError{
 			EventID: e.ID,
 			Field:   "id",
 			Reason:  "required field is missing",
+			Code:    CodeMissing,
 		}
 	}
 
@@ -31,6 +69,7 @@ func (v *CloudEventsValidator) Validate(e *event.CloudEvent) error {
 			EventID: e.ID,
 			Field:   "source",
 			Reason:  "required field is missing",
+			Code:    CodeMissing,
 		}
 	}
 
@@ -39,6 +78,7 @@ func (v *CloudEventsValidator) Validate(e *event.CloudEvent) error {
 			EventID: e.ID,
 			Field:   "specversion",
 			Reason:  "required field is missing",
+			Code:    CodeMissing,
 		}
 	}
 
@@ -47,6 +87,7 @@ func (v *CloudEventsValidator) Validate(e *event.CloudEvent) error {
 			EventID: e.ID,
 			Field:   "type",
 			Reason:  "required field is missing",
+			Code:    CodeMissing,
 		}
 	}
 
@@ -61,6 +102,7 @@ func (v *CloudEventsValidator) Validate(e *event.CloudEvent) error {
 			EventID: e.ID,
 			Field:   "specversion",
 			Reason:  fmt.Sprintf("unsupported version: %s (supported: 1.0)", e.SpecVersion),
+			Code:    CodeUnsupported,
 		}
 	}
 
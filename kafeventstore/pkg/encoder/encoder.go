@@ -13,4 +13,7 @@ type Encoder interface {
 
 	// FileExtension returns the file extension (e.g., ".parquet", ".avro").
 	FileExtension() string
+
+	// SchemaVersion returns the version of the record schema written to files.
+	SchemaVersion() string
 }
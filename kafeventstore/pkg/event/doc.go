@@ -55,6 +55,13 @@
 //	    Validate(event *CloudEvent) error
 //	}
 //
+// Valid events may then be modified in place by a Transformer, such as one
+// redacting personal data:
+//
+//	type Transformer interface {
+//	    Transform(event *CloudEvent) error
+//	}
+//
 // # Time Utilities
 //
 // Records provide convenient methods for extracting event timestamps:
@@ -28,10 +28,86 @@ type CloudEvent struct {
 	// Event data - can be any JSON value (object, array, string, number, etc.)
 	Data json.RawMessage `json:"data,omitempty"`
 
-	// Extension attributes
+	// Extension attributes, encoded as top-level JSON attributes
 	Extensions map[string]interface{} `json:"-"`
 }
 
+// cloudEventAttributes are the attributes decoded into CloudEvent fields; any
+// other top-level attribute is an extension.
+var cloudEventAttributes = map[string]bool{
+	"id":              true,
+	"source":          true,
+	"specversion":     true,
+	"type":            true,
+	"datacontenttype": true,
+	"dataschema":      true,
+	"subject":         true,
+	"time":            true,
+	"data":            true,
+	"data_base64":     true,
+}
+
+// cloudEventJSON has the fields of CloudEvent without its JSON methods.
+type cloudEventJSON CloudEvent
+
+// MarshalJSON encodes the event with its extensions as top-level attributes.
+func (e CloudEvent) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(cloudEventJSON(e))
+	if err != nil || len(e.Extensions) == 0 {
+		return data, err
+	}
+
+	attributes := make(map[string]json.RawMessage, len(e.Extensions)+8)
+	if err := json.Unmarshal(data, &attributes); err != nil {
+		return nil, err
+	}
+	for name, value := range e.Extensions {
+		if cloudEventAttributes[name] {
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal extension %s: %w", name, err)
+		}
+		attributes[name] = encoded
+	}
+	return json.Marshal(attributes)
+}
+
+// UnmarshalJSON decodes the event, collecting unknown top-level attributes
+// into Extensions.
+func (e *CloudEvent) UnmarshalJSON(data []byte) error {
+	var decoded cloudEventJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	var attributes map[string]json.RawMessage
+	if err := json.Unmarshal(data, &attributes); err != nil {
+		return err
+	}
+	// Binary data is carried as a base64 JSON string
+	if raw, ok := attributes["data_base64"]; ok && len(decoded.Data) == 0 {
+		decoded.Data = raw
+	}
+	for name, raw := range attributes {
+		if cloudEventAttributes[name] {
+			continue
+		}
+		var value interface{}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return fmt.Errorf("failed to unmarshal extension %s: %w", name, err)
+		}
+		if decoded.Extensions == nil {
+			decoded.Extensions = make(map[string]interface{})
+		}
+		decoded.Extensions[name] = value
+	}
+
+	*e = CloudEvent(decoded)
+	return nil
+}
+
 // KafkaMetadata contains Kafka-specific metadata for an event.
 type KafkaMetadata struct {
 	Topic     string
@@ -67,6 +143,16 @@ type FileStats struct {
 	SizeBytes      int64
 	FirstWriteTime time.Time
 	LastWriteTime  time.Time
+	// Checksums of the encoded file, computed while it was written.
+	// Nil when the encoder does not compute checksums.
+	Checksums *Checksums
+}
+
+// Checksums contains integrity checksums of an encoded file.
+type Checksums struct {
+	CRC32C uint32 // CRC32 with the Castagnoli polynomial
+	MD5    []byte
+	SHA256 []byte
 }
 
 // FileFormat represents the storage file format.
@@ -83,6 +169,12 @@ type Validator interface {
 	Validate(event *CloudEvent) error
 }
 
+// Transformer transforms CloudEvents before they are buffered.
+type Transformer interface {
+	// Transform modifies the event in place.
+	Transform(event *CloudEvent) error
+}
+
 // ConsumedEvent represents an event consumed from Kafka.
 type ConsumedEvent struct {
 	Event      *CloudEvent
@@ -1,6 +1,8 @@
 package event
 
 import (
+	"encoding/json"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -93,6 +95,56 @@ func TestCloudEvent_Validation(t *testing.T) {
 	}
 }
 
+func TestCloudEvent_JSONExtensions(t *testing.T) {
+	data := []byte(`{"id":"evt-1","source":"orders","specversion":"1.0","type":"order.created",` +
+		`"data":{"total":10},"tenant":"acme","priority":2}`)
+
+	var decoded CloudEvent
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if decoded.ID != "evt-1" || string(decoded.Data) != `{"total":10}` {
+		t.Errorf("decoded event = %+v", decoded)
+	}
+	wantExtensions := map[string]interface{}{"tenant": "acme", "priority": float64(2)}
+	if !reflect.DeepEqual(decoded.Extensions, wantExtensions) {
+		t.Errorf("Extensions = %v, want %v", decoded.Extensions, wantExtensions)
+	}
+
+	encoded, err := json.Marshal(&decoded)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var roundTrip CloudEvent
+	if err := json.Unmarshal(encoded, &roundTrip); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(roundTrip, decoded) {
+		t.Errorf("round trip = %+v, want %+v", roundTrip, decoded)
+	}
+
+	// Events without extensions keep the plain encoding
+	plain, err := json.Marshal(CloudEvent{ID: "evt-2", Source: "s", SpecVersion: "1.0", Type: "t"})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if want := `{"id":"evt-2","source":"s","specversion":"1.0","type":"t"}`; string(plain) != want {
+		t.Errorf("Marshal() = %s, want %s", plain, want)
+	}
+}
+
+func TestCloudEvent_JSONDataBase64(t *testing.T) {
+	data := []byte(`{"id":"evt-1","source":"orders","specversion":"1.0","type":"order.created","data_base64":"AAAAAAE="}`)
+
+	var decoded CloudEvent
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if string(decoded.Data) != `"AAAAAAE="` || decoded.Extensions != nil {
+		t.Errorf("decoded event = %+v", decoded)
+	}
+}
+
 func TestRecord_Creation(t *testing.T) {
 	now := time.Now()
 	event := &CloudEvent{
@@ -6,10 +6,18 @@ package storage
 
 import (
 	"context"
+	"errors"
 
 	"github.com/jittakal/kafeventstore/pkg/event"
 )
 
+// ErrMarkerNotFound is returned when a marker object does not exist.
+var ErrMarkerNotFound = errors.New("marker not found")
+
+// ErrMarkerConflict is returned by a conditional marker write when the marker
+// has changed since it was read.
+var ErrMarkerConflict = errors.New("marker changed concurrently")
+
 // Writer writes event records to storage.
 type Writer interface {
 	// Write writes records to storage at the specified path.
@@ -20,6 +28,36 @@ type Writer interface {
 	Close() error
 }
 
+// MarkerWriter writes small auxiliary objects, such as completion markers,
+// into a storage path alongside data files.
+type MarkerWriter interface {
+	// WriteMarker writes data as an object named name under the specified path.
+	WriteMarker(ctx context.Context, path string, name string, data []byte) error
+}
+
+// MarkerReader reads auxiliary objects written by a MarkerWriter.
+type MarkerReader interface {
+	// ReadMarker reads the object named name under the specified path.
+	// Returns ErrMarkerNotFound if it does not exist.
+	ReadMarker(ctx context.Context, path string, name string) ([]byte, error)
+}
+
+// ConditionalMarkerStore reads and writes markers that several processes
+// update, with optimistic concurrency: a write only succeeds if the marker is
+// still the version that was read.
+type ConditionalMarkerStore interface {
+	// ReadMarkerVersion reads the object named name under the specified path
+	// with an opaque token of its version, such as its ETag.
+	// Returns ErrMarkerNotFound if it does not exist.
+	ReadMarkerVersion(ctx context.Context, path string, name string) ([]byte, string, error)
+
+	// WriteMarkerIf writes data as the object named name under the specified
+	// path if its version is still version or, for an empty version, if it
+	// does not exist. Returns the token of the written version, or
+	// ErrMarkerConflict if the condition does not hold.
+	WriteMarkerIf(ctx context.Context, path string, name string, data []byte, version string) (string, error)
+}
+
 // Router determines storage paths for events based on partitioning strategy.
 type Router interface {
 	// Route returns the storage path for a partition at a given time.